@@ -0,0 +1,119 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+const (
+	adminOperationPrefix  = "admin"
+	adminHandlerRouteName = adminOperationPrefix + "Handler"
+)
+
+// adminRoutes are the extension endpoints served when WithAdminAPI is given.
+var adminRoutes = []route{
+	{OperationAdminListInstances, []string{"GET"}, "/admin/service_instances"},
+	{OperationAdminSweepOrphans, []string{"POST"}, "/admin/orphan_sweep"},
+	{OperationAdminDebug, []string{"GET", "PUT"}, "/admin/debug"},
+	{OperationAdminUpgradeCandidates, []string{"GET"}, "/admin/upgrade_candidates"},
+	{OperationAdminRestoreInstance, []string{"POST"}, "/admin/service_instances/{instance_id}/restore"},
+	{OperationAdminCatalogDiff, []string{"POST"}, "/admin/catalog_diff"},
+}
+
+// WithAdminAPI serves the admin extension endpoints, such as
+// GET /admin/service_instances, which are meant for operators rather than
+// the platform. They are protected by adminAuth instead of the broker
+// credentials, so that platform credentials cannot be used to take an
+// inventory of the broker; adminAuth must not be nil. The endpoints respond
+// with 501 Not Implemented when the broker does not implement the matching
+// optional interface, such as InstanceLister.
+func WithAdminAPI(adminAuth func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.adminAuthMiddleware = adminAuth
+	}
+}
+
+type adminHandler struct {
+	pathPrefix string
+	handler    http.Handler
+}
+
+// WithAdminHandler serves handler, such as expvar.Handler or a metrics
+// exporter, under pathPrefix alongside the admin extension endpoints, behind
+// the admin authentication. Like them, it is only served when the admin API
+// is enabled with WithAdminAPI. handler is given the full path. NewWithOptions
+// panics if pathPrefix does not start with a slash or overlaps the broker API.
+func WithAdminHandler(pathPrefix string, handler http.Handler) Option {
+	return func(c *config) {
+		c.adminHandlers = append(c.adminHandlers, adminHandler{pathPrefix: pathPrefix, handler: handler})
+	}
+}
+
+func attachAdminHandlers(router *mux.Router, handlers []adminHandler) {
+	for _, h := range handlers {
+		prefix := strings.TrimSuffix(h.pathPrefix, "/")
+		if !strings.HasPrefix(prefix, "/") {
+			panic(fmt.Sprintf("admin handler path prefix %q must start with a slash", h.pathPrefix))
+		}
+		if prefix == "/v2" || strings.HasPrefix(prefix, "/v2/") {
+			panic(fmt.Sprintf("admin handler path prefix %q overlaps the broker API", h.pathPrefix))
+		}
+
+		router.Path(prefix).Handler(h.handler).Name(adminHandlerRouteName)
+		router.PathPrefix(prefix + "/").Handler(h.handler).Name(adminHandlerRouteName)
+	}
+}
+
+// authenticate returns the middleware applying the admin authentication to
+// the admin routes and the broker authentication, if any, to the others,
+// except for the anonymous routes.
+func (c *config) authenticate() middlewareFunc {
+	if c.adminAuthMiddleware == nil && c.dashboard == nil && !c.healthEndpoint && !c.anonymousCatalog {
+		return c.authMiddleware
+	}
+	return func(next http.Handler) http.Handler {
+		brokerHandler := next
+		if c.authMiddleware != nil {
+			brokerHandler = c.authMiddleware(next)
+		}
+		adminHandler := next
+		if c.adminAuthMiddleware != nil {
+			adminHandler = c.adminAuthMiddleware(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case c.isAnonymousRoute(req):
+				next.ServeHTTP(w, req.WithContext(contextkeys.WithAnonymous(req.Context())))
+			case isAdminRoute(req):
+				adminHandler.ServeHTTP(w, req)
+			default:
+				brokerHandler.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
+func isAdminRoute(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	return route != nil && strings.HasPrefix(route.GetName(), adminOperationPrefix)
+}
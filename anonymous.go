@@ -0,0 +1,88 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	healthPath      = "/health"
+	healthRouteName = "health"
+)
+
+// WithAnonymousCatalog serves GET /v2/catalog without authentication, for
+// marketplaces which mirror the catalog publicly. Every other endpoint stays
+// protected by the broker credentials. The dashboard_client secrets of the
+// services are left out of the public catalog; requests with credentials,
+// such as those of the platform, are authenticated as before and get them.
+func WithAnonymousCatalog() Option {
+	return func(c *config) {
+		c.anonymousCatalog = true
+	}
+}
+
+// WithHealthEndpoint serves GET /health, which responds with 200 and
+// {"status":"ok"} without calling the broker. It is not authenticated, so
+// that load balancers and orchestrators can probe it.
+func WithHealthEndpoint() Option {
+	return func(c *config) {
+		c.healthEndpoint = true
+	}
+}
+
+func attachHealth(router *mux.Router) {
+	router.Path(healthPath).Methods(http.MethodGet).HandlerFunc(serveHealth).Name(healthRouteName)
+}
+
+func serveHealth(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(healthResponse{Status: "ok"})
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// isHealthRoute reports whether the request is for the health endpoint, which
+// does not reach the broker and so does not count towards the circuit
+// breaker.
+func isHealthRoute(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	return route != nil && route.GetName() == healthRouteName
+}
+
+// isAnonymousRoute reports whether the request is for a route served without
+// authentication: the dashboard, which authenticates its own users, the
+// health endpoint, and the catalog when it is public and the request has no
+// credentials.
+func (c *config) isAnonymousRoute(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return false
+	}
+	switch route.GetName() {
+	case dashboardRouteName, healthRouteName:
+		return true
+	case string(OperationCatalog):
+		return c.anonymousCatalog && req.Header.Get("Authorization") == ""
+	}
+	return false
+}
@@ -16,787 +16,158 @@
 package brokerapi
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
 	"net/http"
-	"strconv"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/gorilla/mux"
-	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
 	"github.com/sharma-tapas/brokerapi/middlewares/originating_identity_header"
 	"github.com/sharma-tapas/brokerapi/middlewares/x_region_header"
 )
 
-const (
-	provisionLogKey            = "provision"
-	deprovisionLogKey          = "deprovision"
-	bindLogKey                 = "bind"
-	getBindLogKey              = "getBinding"
-	getInstanceLogKey          = "getInstance"
-	unbindLogKey               = "unbind"
-	updateLogKey               = "update"
-	lastOperationLogKey        = "lastOperation"
-	lastBindingOperationLogKey = "lastBindingOperation"
-	catalogLogKey              = "catalog"
-
-	instanceIDLogKey      = "instance-id"
-	instanceDetailsLogKey = "instance-details"
-	bindingIDLogKey       = "binding-id"
-
-	invalidServiceDetailsErrorKey = "invalid-service-details"
-	invalidBindDetailsErrorKey    = "invalid-bind-details"
-	instanceLimitReachedErrorKey  = "instance-limit-reached"
-	instanceAlreadyExistsErrorKey = "instance-already-exists"
-	bindingAlreadyExistsErrorKey  = "binding-already-exists"
-	instanceMissingErrorKey       = "instance-missing"
-	bindingMissingErrorKey        = "binding-missing"
-	bindingNotFoundErrorKey       = "binding-not-found"
-	asyncRequiredKey              = "async-required"
-	planChangeNotSupportedKey     = "plan-change-not-supported"
-	unknownErrorKey               = "unknown-error"
-	invalidRawParamsKey           = "invalid-raw-params"
-	appGuidNotProvidedErrorKey    = "app-guid-not-provided"
-	apiVersionInvalidKey          = "broker-api-version-invalid"
-	serviceIdMissingKey           = "service-id-missing"
-	planIdMissingKey              = "plan-id-missing"
-	invalidServiceID              = "invalid-service-id"
-	invalidPlanID                 = "invalid-plan-id"
-	concurrentAccessKey           = "get-instance-during-update"
-	maintenanceInfoConflictKey    = "maintenance-info-conflict"
-)
-
-var (
-	serviceIdError        = errors.New("service_id missing")
-	planIdError           = errors.New("plan_id missing")
-	invalidServiceIDError = errors.New("service-id not in the catalog")
-	invalidPlanIDError    = errors.New("plan-id not in the catalog")
-)
-
 type BrokerCredentials struct {
 	Username string
 	Password string
 }
 
 func New(serviceBroker ServiceBroker, logger lager.Logger, brokerCredentials BrokerCredentials) http.Handler {
-	router := mux.NewRouter()
-	AttachRoutes(router, serviceBroker, logger)
-
-	authMiddleware := auth.NewWrapper(brokerCredentials.Username, brokerCredentials.Password).Wrap
-	router.Use(authMiddleware)
-	router.Use(originating_identity_header.AddToContext)
-	router.Use(x_region_header.AddToContext)
-
-	return router
-}
-
-func AttachRoutes(router *mux.Router, serviceBroker ServiceBroker, logger lager.Logger) {
-	handler := serviceBrokerHandler{serviceBroker: serviceBroker, logger: logger}
-	router.HandleFunc("/v2/catalog", handler.catalog).Methods("GET")
-
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.getInstance).Methods("GET")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.provision).Methods("PUT")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.deprovision).Methods("DELETE")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/last_operation", handler.lastOperation).Methods("GET")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.update).Methods("PATCH")
-
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/service_bindings/{binding_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.getBinding).Methods("GET")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/service_bindings/{binding_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.bind).Methods("PUT")
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/service_bindings/{binding_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}", handler.unbind).Methods("DELETE")
-
-	router.HandleFunc("/v2/service_instances/{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/service_bindings/{binding_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}/last_operation", handler.lastBindingOperation).Methods("GET")
-}
-
-type serviceBrokerHandler struct {
-	serviceBroker ServiceBroker
-	logger        lager.Logger
+	return NewWithOptions(serviceBroker, logger, WithBrokerCredentials(brokerCredentials))
 }
 
-func (h serviceBrokerHandler) catalog(w http.ResponseWriter, req *http.Request) {
-	logger := h.logger.Session(catalogLogKey, lager.Data{})
+// NewWithOptions returns an http.Handler serving the broker API, configured by opts.
+// Unless WithBrokerCredentials or WithCustomAuth is given, requests are not authenticated.
+func NewWithOptions(serviceBroker ServiceBroker, logger lager.Logger, opts ...Option) http.Handler {
+	cfg := newConfig(opts)
 
-	if _, err := checkBrokerAPIVersionHdr(req); err != nil {
-		logger.Error("Check failed", err)
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	router, attach := cfg.router, attachRoutes
+	if router == nil {
+		// The routes of a router of our own are not mounted under a prefix,
+		// so their paths can be matched without regular expressions.
+		router, attach = mux.NewRouter(), attachPrecompiledRoutes
 	}
-
-	services, err := h.serviceBroker.Services(req.Context())
-	if err != nil {
-		h.respond(w, http.StatusInternalServerError, ErrorResponse{
-			Description: err.Error(),
-		})
-		return
-	}
-
-	catalog := CatalogResponse{
-		Services: services,
-	}
-
-	h.respond(w, http.StatusOK, catalog)
-}
-
-func (h serviceBrokerHandler) provision(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-
-	logger := h.logger.Session(provisionLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
-
-	if _, err := checkBrokerAPIVersionHdr(req); err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	endpoints := newEndpointHandlers(serviceBroker, logger, cfg)
+	attach(router, endpoints, routes)
+	if cfg.adminAuthMiddleware != nil {
+		attach(router, endpoints, adminRoutes)
+		attachAdminHandlers(router, cfg.adminHandlers)
 	}
-
-	var details ProvisionDetails
-	if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
-		logger.Error(invalidServiceDetailsErrorKey, err)
-		h.respond(w, http.StatusUnprocessableEntity, ErrorResponse{
-			Description: err.Error(),
-		})
-		return
-	}
-
-	if details.ServiceID == "" {
-		logger.Error(serviceIdMissingKey, serviceIdError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: serviceIdError.Error(),
-		})
-		return
-	}
-
-	if details.PlanID == "" {
-		logger.Error(planIdMissingKey, planIdError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: planIdError.Error(),
-		})
-		return
+	if cfg.dashboard != nil {
+		attachDashboard(router, cfg.dashboard)
 	}
-
-	valid := false
-	services, _ := h.serviceBroker.Services(req.Context())
-	for _, service := range services {
-		if service.ID == details.ServiceID {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		logger.Error(invalidServiceID, invalidServiceIDError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: invalidServiceIDError.Error(),
-		})
-		return
-	}
-
-	valid = false
-	for _, service := range services {
-		for _, plan := range service.Plans {
-			if plan.ID == details.PlanID {
-				valid = true
-				break
-			}
-		}
-	}
-	if !valid {
-		logger.Error(invalidPlanID, invalidPlanIDError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: invalidPlanIDError.Error(),
-		})
-		return
-	}
-
-	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
-
-	logger = logger.WithData(lager.Data{
-		instanceDetailsLogKey: details,
-	})
-
-	provisionResponse, err := h.serviceBroker.Provision(req.Context(), instanceID, details, asyncAllowed)
-
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	if provisionResponse.IsAsync {
-		h.respond(w, http.StatusAccepted, ProvisioningResponse{
-			DashboardURL:  provisionResponse.DashboardURL,
-			OperationData: provisionResponse.OperationData,
-		})
-	} else {
-		h.respond(w, http.StatusCreated, ProvisioningResponse{
-			DashboardURL: provisionResponse.DashboardURL,
-		})
+	if cfg.healthEndpoint {
+		attachHealth(router)
 	}
+	return cfg.wrap(router, logger)
 }
 
-func (h serviceBrokerHandler) update(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-
-	logger := h.logger.Session(updateLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
-
-	if _, err := checkBrokerAPIVersionHdr(req); err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-
-	var details UpdateDetails
-	if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
-		h.logger.Error(invalidServiceDetailsErrorKey, err)
-		h.respond(w, http.StatusUnprocessableEntity, ErrorResponse{
-			Description: err.Error(),
-		})
-		return
-	}
-
-	if details.ServiceID == "" {
-		logger.Error(serviceIdMissingKey, serviceIdError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: serviceIdError.Error(),
-		})
-		return
-	}
-
-	acceptsIncompleteFlag, _ := strconv.ParseBool(req.URL.Query().Get("accepts_incomplete"))
-
-	updateServiceSpec, err := h.serviceBroker.Update(req.Context(), instanceID, details, acceptsIncompleteFlag)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			h.logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(h.logger), err.ErrorResponse())
-		default:
-			h.logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	statusCode := http.StatusOK
-	if updateServiceSpec.IsAsync {
-		statusCode = http.StatusAccepted
-	}
-	h.respond(w, statusCode, UpdateResponse{
-		OperationData: updateServiceSpec.OperationData,
-		DashboardURL:  updateServiceSpec.DashboardURL,
-	})
+// Handlers are the handlers returned by NewHandlers.
+type Handlers struct {
+	// Broker serves the broker API, and the dashboard and the health
+	// endpoint when they are enabled.
+	Broker http.Handler
+	// Admin serves the admin extension endpoints and the handlers added
+	// with WithAdminHandler, when enabled with WithAdminAPI, and the health
+	// endpoint when it is enabled. It responds with 404 to anything else.
+	Admin http.Handler
 }
 
-func (h serviceBrokerHandler) deprovision(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	logger := h.logger.Session(deprovisionLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
+// NewHandlers is like NewWithOptions, but serves the admin extension
+// endpoints from a second handler instead of alongside the broker API, so
+// that they can be served on another listener, such as one bound to a private
+// address or requiring client certificates. Both handlers share the broker,
+// the middlewares and the other options. WithRouter only applies to the
+// Broker handler.
+func NewHandlers(serviceBroker ServiceBroker, logger lager.Logger, opts ...Option) Handlers {
+	cfg := newConfig(opts)
 
-	if _, err := checkBrokerAPIVersionHdr(req); err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	brokerRouter, attach := cfg.router, attachRoutes
+	if brokerRouter == nil {
+		brokerRouter, attach = mux.NewRouter(), attachPrecompiledRoutes
 	}
+	adminRouter := mux.NewRouter()
 
-	details := DeprovisionDetails{
-		PlanID:    req.FormValue("plan_id"),
-		ServiceID: req.FormValue("service_id"),
+	endpoints := newEndpointHandlers(serviceBroker, logger, cfg)
+	attach(brokerRouter, endpoints, routes)
+	if cfg.adminAuthMiddleware != nil {
+		attachPrecompiledRoutes(adminRouter, endpoints, adminRoutes)
+		attachAdminHandlers(adminRouter, cfg.adminHandlers)
 	}
-
-	if details.ServiceID == "" {
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: serviceIdError.Error(),
-		})
-		logger.Error(serviceIdMissingKey, serviceIdError)
-		return
+	if cfg.dashboard != nil {
+		attachDashboard(brokerRouter, cfg.dashboard)
 	}
-
-	if details.PlanID == "" {
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: planIdError.Error(),
-		})
-		logger.Error(planIdMissingKey, planIdError)
-		return
+	if cfg.healthEndpoint {
+		attachHealth(brokerRouter)
+		attachHealth(adminRouter)
 	}
-
-	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
-
-	deprovisionSpec, err := h.serviceBroker.Deprovision(req.Context(), instanceID, details, asyncAllowed)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	if deprovisionSpec.IsAsync {
-		h.respond(w, http.StatusAccepted, DeprovisionResponse{OperationData: deprovisionSpec.OperationData})
-	} else {
-		h.respond(w, http.StatusOK, EmptyResponse{})
+	return Handlers{
+		Broker: cfg.wrap(brokerRouter, logger),
+		Admin:  cfg.wrap(adminRouter, logger),
 	}
 }
 
-func (h serviceBrokerHandler) getInstance(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-
-	logger := h.logger.Session(getInstanceLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
-
-	versionCompatibility, err := checkBrokerAPIVersionHdr(req)
-	if err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-	if versionCompatibility.Minor < 14 {
-		err = errors.New("get instance endpoint only supported starting with OSB version 2.14")
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+func newConfig(opts []Option) *config {
+	cfg := newDefaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
 	}
-
-	instanceDetails, err := h.serviceBroker.GetInstance(req.Context(), instanceID)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	h.respond(w, http.StatusOK, GetInstanceResponse{
-		ServiceID:    instanceDetails.ServiceID,
-		PlanID:       instanceDetails.PlanID,
-		DashboardURL: instanceDetails.DashboardURL,
-		Parameters:   instanceDetails.Parameters,
-	})
+	return cfg
 }
 
-func (h serviceBrokerHandler) getBinding(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	bindingID := vars["binding_id"]
-
-	logger := h.logger.Session(getBindLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-		bindingIDLogKey:  bindingID,
-	})
-
-	versionCompatibility, err := checkBrokerAPIVersionHdr(req)
-	if err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+// wrap adds the middlewares to router, and strips the path prefix.
+func (c *config) wrap(router *mux.Router, logger lager.Logger) http.Handler {
+	for _, middleware := range c.middlewares {
+		router.Use(mux.MiddlewareFunc(middleware))
 	}
-	if versionCompatibility.Minor < 14 {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: "get binding endpoint only supported starting with OSB version 2.14",
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	if authMiddleware := c.authenticate(); authMiddleware != nil {
+		router.Use(mux.MiddlewareFunc(authMiddleware))
 	}
-
-	binding, err := h.serviceBroker.GetBinding(req.Context(), instanceID, bindingID)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
+	for _, middleware := range c.authenticatedMiddlewares {
+		router.Use(mux.MiddlewareFunc(middleware))
 	}
-
-	h.respond(w, http.StatusOK, GetBindingResponse{
-		BindingResponse: BindingResponse{
-			Credentials:     binding.Credentials,
-			SyslogDrainURL:  binding.SyslogDrainURL,
-			RouteServiceURL: binding.RouteServiceURL,
-			VolumeMounts:    binding.VolumeMounts,
-		},
-		Parameters: binding.Parameters,
-	})
-}
-
-func (h serviceBrokerHandler) bind(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	bindingID := vars["binding_id"]
-
-	logger := h.logger.Session(bindLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-		bindingIDLogKey:  bindingID,
-	})
-
-	versionCompatibility, err := checkBrokerAPIVersionHdr(req)
-	if err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-
-	var details BindDetails
-	if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
-		logger.Error(invalidBindDetailsErrorKey, err)
-		h.respond(w, http.StatusUnprocessableEntity, ErrorResponse{
-			Description: err.Error(),
-		})
-		return
-	}
-
-	if details.ServiceID == "" {
-		logger.Error(serviceIdMissingKey, serviceIdError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: serviceIdError.Error(),
-		})
-		return
-	}
-
-	if details.PlanID == "" {
-		logger.Error(planIdMissingKey, planIdError)
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: planIdError.Error(),
-		})
-		return
-	}
-
-	asyncAllowed := false
-	if versionCompatibility.Minor >= 14 {
-		asyncAllowed = req.FormValue("accepts_incomplete") == "true"
-	}
-
-	binding, err := h.serviceBroker.Bind(req.Context(), instanceID, bindingID, details, asyncAllowed)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			statusCode := err.ValidatedStatusCode(logger)
-			errorResponse := err.ErrorResponse()
-			if err == ErrInstanceDoesNotExist {
-				// work around ErrInstanceDoesNotExist having different pre-refactor behaviour to other actions
-				errorResponse = ErrorResponse{
-					Description: err.Error(),
-				}
-				statusCode = http.StatusNotFound
-			}
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, statusCode, errorResponse)
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	if binding.IsAsync {
-		h.respond(w, http.StatusAccepted, AsyncBindResponse{
-			OperationData: binding.OperationData,
-		})
-		return
-	}
-
-	if versionCompatibility.Minor == 8 || versionCompatibility.Minor == 9 {
-		experimentalVols := []ExperimentalVolumeMount{}
-
-		for _, vol := range binding.VolumeMounts {
-			experimentalConfig, err := json.Marshal(vol.Device.MountConfig)
-			if err != nil {
-				logger.Error(unknownErrorKey, err)
-				h.respond(w, http.StatusInternalServerError, ErrorResponse{Description: err.Error()})
-				return
-			}
-
-			experimentalVols = append(experimentalVols, ExperimentalVolumeMount{
-				ContainerPath: vol.ContainerDir,
-				Mode:          vol.Mode,
-				Private: ExperimentalVolumeMountPrivate{
-					Driver:  vol.Driver,
-					GroupID: vol.Device.VolumeId,
-					Config:  string(experimentalConfig),
-				},
-			})
-		}
-
-		experimentalBinding := ExperimentalVolumeMountBindingResponse{
-			Credentials:     binding.Credentials,
-			RouteServiceURL: binding.RouteServiceURL,
-			SyslogDrainURL:  binding.SyslogDrainURL,
-			VolumeMounts:    experimentalVols,
-		}
-		h.respond(w, http.StatusCreated, experimentalBinding)
-		return
-	}
-
-	h.respond(w, http.StatusCreated, BindingResponse{
-		Credentials:     binding.Credentials,
-		SyslogDrainURL:  binding.SyslogDrainURL,
-		RouteServiceURL: binding.RouteServiceURL,
-		VolumeMounts:    binding.VolumeMounts,
-	})
-}
-
-func (h serviceBrokerHandler) unbind(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	bindingID := vars["binding_id"]
-
-	logger := h.logger.Session(unbindLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-		bindingIDLogKey:  bindingID,
-	})
-
-	versionCompatibility, err := checkBrokerAPIVersionHdr(req)
-	if err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-
-	details := UnbindDetails{
-		PlanID:    req.FormValue("plan_id"),
-		ServiceID: req.FormValue("service_id"),
-	}
-
-	if details.ServiceID == "" {
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: serviceIdError.Error(),
-		})
-		logger.Error(serviceIdMissingKey, serviceIdError)
-		return
-	}
-
-	if details.PlanID == "" {
-		h.respond(w, http.StatusBadRequest, ErrorResponse{
-			Description: planIdError.Error(),
-		})
-		logger.Error(planIdMissingKey, planIdError)
-		return
-	}
-
-	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
-	if asyncAllowed && versionCompatibility.Minor < 14 {
-		h.respond(w, http.StatusUnprocessableEntity, ErrorResponse{
-			Description: "async unbinding only supported from OSB version 2.14 and up",
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-
-	unbindResponse, err := h.serviceBroker.Unbind(req.Context(), instanceID, bindingID, details, asyncAllowed)
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	if unbindResponse.IsAsync {
-		h.respond(w, http.StatusAccepted, UnbindResponse{
-			OperationData: unbindResponse.OperationData,
-		})
-	} else {
-		h.respond(w, http.StatusOK, EmptyResponse{})
-	}
-
-}
-
-func (h serviceBrokerHandler) lastBindingOperation(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	bindingID := vars["binding_id"]
-	pollDetails := PollDetails{
-		PlanID:        req.FormValue("plan_id"),
-		ServiceID:     req.FormValue("service_id"),
-		OperationData: req.FormValue("operation"),
+	router.Use(originating_identity_header.AddToContext)
+	router.Use(x_region_header.AddToContext)
+	router.Use(contextkeys.AddToContext)
+	if c.timeouts.enabled() {
+		router.Use(timeoutMiddleware(c.timeouts))
 	}
-
-	logger := h.logger.Session(lastBindingOperationLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
-
-	versionCompatibility, err := checkBrokerAPIVersionHdr(req)
-	if err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	if len(c.deprecations) > 0 {
+		router.Use(deprecationMiddleware(c.deprecations, logger))
 	}
-	if versionCompatibility.Minor < 14 {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: "get binding endpoint only supported starting with OSB version 2.14",
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
+	// Requests turned away by the concurrency limits are not failures of the
+	// broker, so the circuit breaker must not see them.
+	if c.concurrencyLimits.enabled() {
+		router.Use(c.concurrencyLimits.middleware(logger))
 	}
-
-	logger.Info("starting-check-for-binding-operation")
-
-	lastOperation, err := h.serviceBroker.LastBindingOperation(req.Context(), instanceID, bindingID, pollDetails)
-
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
+	if c.circuitBreaker != nil {
+		router.Use(c.circuitBreaker.middleware(logger))
 	}
 
-	logger.WithData(lager.Data{"state": lastOperation.State}).Info("done-check-for-binding-operation")
-
-	lastOperationResponse := LastOperationResponse{
-		State:       lastOperation.State,
-		Description: lastOperation.Description,
+	if c.pathPrefix != "" {
+		return stripPathPrefix(c.pathPrefix, router)
 	}
-	h.respond(w, http.StatusOK, lastOperationResponse)
-
+	return router
 }
 
-func (h serviceBrokerHandler) lastOperation(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	instanceID := vars["instance_id"]
-	pollDetails := PollDetails{
-		PlanID:        req.FormValue("plan_id"),
-		ServiceID:     req.FormValue("service_id"),
-		OperationData: req.FormValue("operation"),
-	}
-
-	logger := h.logger.Session(lastOperationLogKey, lager.Data{
-		instanceIDLogKey: instanceID,
-	})
-
-	if _, err := checkBrokerAPIVersionHdr(req); err != nil {
-		h.respond(w, http.StatusPreconditionFailed, ErrorResponse{
-			Description: err.Error(),
-		})
-		logger.Error(apiVersionInvalidKey, err)
-		return
-	}
-
-	logger.Info("starting-check-for-operation")
-
-	lastOperation, err := h.serviceBroker.LastOperation(req.Context(), instanceID, pollDetails)
-
-	if err != nil {
-		switch err := err.(type) {
-		case *FailureResponse:
-			logger.Error(err.LoggerAction(), err)
-			h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
-		default:
-			logger.Error(unknownErrorKey, err)
-			h.respond(w, http.StatusInternalServerError, ErrorResponse{
-				Description: err.Error(),
-			})
-		}
-		return
-	}
-
-	logger.WithData(lager.Data{"state": lastOperation.State}).Info("done-check-for-operation")
-
-	lastOperationResponse := LastOperationResponse{
-		State:       lastOperation.State,
-		Description: lastOperation.Description,
-	}
-
-	h.respond(w, http.StatusOK, lastOperationResponse)
+func AttachRoutes(router *mux.Router, serviceBroker ServiceBroker, logger lager.Logger) {
+	attachRoutes(router, NewEndpointHandlers(serviceBroker, logger), routes)
 }
 
-func (h serviceBrokerHandler) respond(w http.ResponseWriter, status int, response interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-
-	encoder := json.NewEncoder(w)
-	err := encoder.Encode(response)
-	if err != nil {
-		h.logger.Error("encoding response", err, lager.Data{"status": status, "response": response})
+func attachRoutes(router *mux.Router, endpoints *EndpointHandlers, routes []route) {
+	for _, route := range routes {
+		router.Handle(route.path, endpoints.Handler(route.operation)).Methods(route.methods...).Name(string(route.operation))
 	}
 }
 
-type brokerVersion struct {
-	Major int
-	Minor int
-}
-
-func checkBrokerAPIVersionHdr(req *http.Request) (brokerVersion, error) {
-	var version brokerVersion
-	apiVersion := req.Header.Get("X-Broker-API-Version")
-	if apiVersion == "" {
-		return version, errors.New("X-Broker-API-Version Header not set")
-	}
-	if n, err := fmt.Sscanf(apiVersion, "%d.%d", &version.Major, &version.Minor); err != nil || n < 2 {
-		return version, errors.New("X-Broker-API-Version Header must contain a version")
-	}
-
-	if version.Major != 2 {
-		return version, errors.New("X-Broker-API-Version Header must be 2.x")
+// attachPrecompiledRoutes attaches routes which match paths with a
+// pathTemplate. Their mux.Route has no path template of its own, and the full
+// request path is matched, so they only work on a router which is not a
+// subrouter.
+func attachPrecompiledRoutes(router *mux.Router, endpoints *EndpointHandlers, routes []route) {
+	for _, route := range routes {
+		router.NewRoute().
+			Methods(route.methods...).
+			MatcherFunc(parsePathTemplate(route.path).matcher(route.methods)).
+			Handler(endpoints.Handler(route.operation)).
+			Name(string(route.operation))
 	}
-	return version, nil
 }
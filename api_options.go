@@ -0,0 +1,280 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/handlers"
+)
+
+type middlewareFunc func(http.Handler) http.Handler
+
+type config struct {
+	router                   *mux.Router
+	authMiddleware           middlewareFunc
+	adminAuthMiddleware      middlewareFunc
+	locks                    LockManager
+	timeouts                 timeouts
+	eventSinks               []LifecycleEventSink
+	errorReporter            ErrorReporter
+	credentialsOpener        CredentialsOpener
+	credentialsTransformers  []CredentialsTransformer
+	parameterDecoders        []handlers.ParameterDecoderRule
+	quotas                   QuotaChecker
+	meter                    Meter
+	middlewares              []middlewareFunc
+	authenticatedMiddlewares []middlewareFunc
+
+	strictResponses bool
+	logLevel        lager.LogLevel
+	logLevelSink    *lager.ReconfigurableSink
+	debugToggles    map[string]DebugToggle
+
+	minimumAPIVersion string
+	deprecations      []Deprecation
+
+	circuitBreaker    *circuitBreaker
+	concurrencyLimits concurrencyLimits
+
+	dashboard  *dashboard
+	pathPrefix string
+
+	anonymousCatalog bool
+	healthEndpoint   bool
+	adminHandlers    []adminHandler
+
+	retrieveParameters        bool
+	parametersOmittedServices []string
+	previousParameters        bool
+	dynamicPlans              bool
+}
+
+// Option configures the handler returned by NewWithOptions.
+type Option func(*config)
+
+// WithBrokerCredentials protects the broker endpoints with static basic auth credentials.
+func WithBrokerCredentials(brokerCredentials BrokerCredentials) Option {
+	return func(c *config) {
+		c.authMiddleware = auth.NewWrapper(brokerCredentials.Username, brokerCredentials.Password).Wrap
+	}
+}
+
+// WithCustomAuth replaces the default basic auth check with the given middleware,
+// for example the Wrap method of an auth.Wrapper whose credentials are reloaded
+// by an auth.CredentialsFileWatcher.
+func WithCustomAuth(authMiddleware func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.authMiddleware = authMiddleware
+	}
+}
+
+// WithMiddleware adds a middleware to the broker routes. Middlewares run in the
+// order they are given, before authentication, so that they also see requests
+// which are rejected; the matched route is available through mux.CurrentRoute.
+func WithMiddleware(middleware func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, middleware)
+	}
+}
+
+// WithAuthenticatedMiddleware adds a middleware to the broker routes which runs
+// after authentication, in the order given, and so only sees requests whose
+// credentials were accepted. contextkeys.Principal holds the identity they
+// were accepted for, such as the key of rate_limit.Principal.
+func WithAuthenticatedMiddleware(middleware func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.authenticatedMiddlewares = append(c.authenticatedMiddlewares, middleware)
+	}
+}
+
+// WithRouter attaches the broker routes to an existing router instead of a new one.
+// They are registered with mux path templates, so that they also work on a
+// subrouter, whereas the routes of a new router match paths without regular
+// expressions.
+func WithRouter(router *mux.Router) Option {
+	return func(c *config) {
+		c.router = router
+	}
+}
+
+// WithErrorReporter registers an ErrorReporter.
+func WithErrorReporter(reporter ErrorReporter) Option {
+	return func(c *config) {
+		c.errorReporter = reporter
+	}
+}
+
+// WithCredentialsOpener decrypts the credentials returned by Bind and
+// GetBinding with opener, such as an envelope.KeyRing, before they are sent
+// to the platform.
+func WithCredentialsOpener(opener CredentialsOpener) Option {
+	return func(c *config) {
+		c.credentialsOpener = opener
+	}
+}
+
+// WithCredentialsTransformer changes the credentials returned by Bind and
+// GetBinding with transformer before they are sent to the platform. Several
+// transformers run in the order they are given, after the CredentialsOpener.
+func WithCredentialsTransformer(transformer CredentialsTransformer) Option {
+	return func(c *config) {
+		c.credentialsTransformers = append(c.credentialsTransformers, transformer)
+	}
+}
+
+// WithParameterDecoder converts the parameters of provision, update and bind
+// requests for serviceID and planID with decoder before they are passed to
+// the broker, so that brokers can accept parameters in formats other than a
+// JSON object. An empty serviceID or planID matches every service or plan,
+// and decoders matching the same request run in the order they are given.
+func WithParameterDecoder(serviceID, planID string, decoder ParameterDecoder) Option {
+	return func(c *config) {
+		c.parameterDecoders = append(c.parameterDecoders, handlers.ParameterDecoderRule{
+			ServiceID: serviceID,
+			PlanID:    planID,
+			Decoder:   decoder,
+		})
+	}
+}
+
+// WithQuotas checks each provision, and each update changing the plan of an
+// instance, against checker, such as a quota.Checker, before the broker is
+// called. Requests going over quota fail with a 422 and the error
+// "QuotaExceeded".
+func WithQuotas(checker QuotaChecker) Option {
+	return func(c *config) {
+		c.quotas = checker
+	}
+}
+
+// WithMeter passes a UsageRecord to meter whenever the broker completes a
+// provision, a deprovision or an update changing the plan of an instance, so
+// that providers can bill for the plans in use. Asynchronous operations are
+// recorded when a poll reports that they succeeded. The metering package has a
+// Meter writing the records to a file.
+func WithMeter(meter Meter) Option {
+	return func(c *config) {
+		c.meter = meter
+	}
+}
+
+// WithStrictResponseValidation checks what the ServiceBroker returns before it
+// is sent to the platform. Responses which break the Open Service Broker API,
+// such as a malformed dashboard_url, an operation string longer than 10,000
+// characters, an unknown last operation state or a binding without
+// credentials, are replaced by a 500 whose description lists every violation.
+// The violations are also passed to the ErrorReporter, if one is registered.
+func WithStrictResponseValidation() Option {
+	return func(c *config) {
+		c.strictResponses = true
+	}
+}
+
+// WithLogLevel sets the lowest level the handlers log at. Lines below it are
+// dropped before their data is built, which matters for brokers polled often:
+// successful last_operation polls only log at lager.INFO, so with lager.ERROR
+// they cost no logging allocations. The default, lager.DEBUG, logs everything
+// and leaves filtering to the sinks.
+func WithLogLevel(level lager.LogLevel) Option {
+	return func(c *config) {
+		c.logLevel = level
+	}
+}
+
+// WithReconfigurableLogLevel makes the handlers log at the current level of
+// sink instead of a fixed one, so that the level can be changed while the
+// broker runs, for example with the /admin/debug endpoint of WithAdminAPI.
+// The sink should be registered with the logger given to the handler.
+func WithReconfigurableLogLevel(sink *lager.ReconfigurableSink) Option {
+	return func(c *config) {
+		c.logLevelSink = sink
+	}
+}
+
+// WithDebugToggle lets operators switch toggle, such as a debug_dump.Dumper,
+// on and off under name with the /admin/debug endpoint of WithAdminAPI.
+func WithDebugToggle(name string, toggle DebugToggle) Option {
+	return func(c *config) {
+		if c.debugToggles == nil {
+			c.debugToggles = map[string]DebugToggle{}
+		}
+		c.debugToggles[name] = toggle
+	}
+}
+
+// WithMinimumAPIVersion responds with 412 Precondition Failed to requests whose
+// X-Broker-API-Version is older than version, such as "2.13", describing the
+// versions the broker supports. NewWithOptions panics if version is not of the
+// form "2.minor".
+func WithMinimumAPIVersion(version string) Option {
+	return func(c *config) {
+		c.minimumAPIVersion = version
+	}
+}
+
+// WithParameterRetrieval returns the parameters of instances and bindings from
+// the fetch endpoints, which leave them out by default. They are only returned
+// for services whose catalog entry sets InstancesRetrievable or
+// BindingsRetrievable, and never for the services with omittedServiceIDs,
+// which suits services taking secrets as parameters.
+func WithParameterRetrieval(omittedServiceIDs ...string) Option {
+	return func(c *config) {
+		c.retrieveParameters = true
+		c.parametersOmittedServices = append(c.parametersOmittedServices, omittedServiceIDs...)
+	}
+}
+
+// WithPreviousParameters passes the parameters an instance had before an
+// update to Update, in PreviousValues.Parameters, so that the broker can tell
+// what changed with UpdateDetails.ParametersDiff. They are fetched with
+// GetInstance while the instance is locked, and the update fails with the
+// error of GetInstance if it fails.
+func WithPreviousParameters() Option {
+	return func(c *config) {
+		c.previousParameters = true
+	}
+}
+
+// WithDynamicPlans accepts provision, update and bind requests for services and
+// plans which are not in the catalog, for brokers which create plans on demand.
+// By default such requests are rejected with a 400 before the broker is
+// called.
+func WithDynamicPlans() Option {
+	return func(c *config) {
+		c.dynamicPlans = true
+	}
+}
+
+func newDefaultConfig() *config {
+	return &config{}
+}
+
+// WithInstanceLocking serializes the requests which change an instance or its
+// bindings: provision, update, deprovision, bind and unbind. While one of them
+// is being served, other requests for the same instance get a 422
+// ConcurrencyError, which the platform retries. Use a LockManager shared by
+// all replicas, such as locks.NewPostgres, when the broker is scaled
+// horizontally. The lock covers the request only, not the remainder of an
+// asynchronous operation.
+func WithInstanceLocking(locks LockManager) Option {
+	return func(c *config) {
+		c.locks = locks
+	}
+}
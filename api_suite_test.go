@@ -16,39 +16,13 @@
 package brokerapi_test
 
 import (
-	"fmt"
-	"io/ioutil"
-	"path"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/pborman/uuid"
 )
 
 func TestAPI(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "API Suite")
 }
-
-func fixture(name string) string {
-	filePath := path.Join("fixtures", name)
-	contents, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		panic(fmt.Sprintf("Could not read fixture: %s", name))
-	}
-
-	return string(contents)
-}
-
-func uniqueID() string {
-	return uuid.NewRandom().String()
-}
-
-func uniqueInstanceID() string {
-	return uniqueID()
-}
-
-func uniqueBindingID() string {
-	return uniqueID()
-}
@@ -18,21 +18,40 @@ package brokerapi_test
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/drewolson/testflight"
 	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	pkgerrors "github.com/pkg/errors"
 	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/credentialtransform"
+	"github.com/sharma-tapas/brokerapi/envelope"
 	"github.com/sharma-tapas/brokerapi/fakes"
+	"github.com/sharma-tapas/brokerapi/locks"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+	"github.com/sharma-tapas/brokerapi/middlewares/debug_dump"
+	"github.com/sharma-tapas/brokerapi/paramdecode"
+	"github.com/sharma-tapas/brokerapi/paramversion"
+	"github.com/sharma-tapas/brokerapi/quota"
+	"github.com/sharma-tapas/brokerapi/softdelete"
+	"github.com/sharma-tapas/brokerapi/state"
 )
 
 var _ = Describe("Service Broker API", func() {
@@ -113,7 +132,14 @@ var _ = Describe("Service Broker API", func() {
 			response := makeRequest()
 
 			header := response.Header().Get("Content-Type")
-			Ω(header).Should(Equal("application/json"))
+			Ω(header).Should(Equal("application/json; charset=utf-8"))
+		})
+
+		It("has a Content-Length header matching the body", func() {
+			response := makeRequest()
+
+			header := response.Header().Get("Content-Length")
+			Ω(header).Should(Equal(fmt.Sprintf("%d", response.Body.Len())))
 		})
 	})
 
@@ -189,6 +215,30 @@ var _ = Describe("Service Broker API", func() {
 		})
 	})
 
+	Describe("concurrent requests", func() {
+		It("records every call on the fake broker", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < fakeServiceBroker.InstanceLimit; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					recorder := httptest.NewRecorder()
+					body := fmt.Sprintf(`{"service_id":"%s","plan_id":"plan-id"}`, fakeServiceBroker.ServiceID)
+					request, _ := http.NewRequest("PUT", "/v2/service_instances/"+brokertest.UniqueInstanceID(), strings.NewReader(body))
+					request.Header.Add("X-Broker-API-Version", "2.14")
+					request.SetBasicAuth(credentials.Username, credentials.Password)
+					brokerAPI.ServeHTTP(recorder, request)
+					Expect(recorder.Code).To(Equal(http.StatusCreated))
+				}()
+			}
+			wg.Wait()
+
+			Expect(fakeServiceBroker.GetProvisionedInstanceIDs()).To(HaveLen(fakeServiceBroker.InstanceLimit))
+		})
+	})
+
 	Describe("authentication", func() {
 		makeRequestWithoutAuth := func() *testflight.Response {
 			response := &testflight.Response{}
@@ -249,6 +299,30 @@ var _ = Describe("Service Broker API", func() {
 				"broker should not have been hit when authentication failed",
 			)
 		})
+
+		Context("when the credentials are rotated", func() {
+			var wrapper *auth.Wrapper
+
+			BeforeEach(func() {
+				wrapper = auth.NewWrapper(credentials.Username, credentials.Password)
+				brokerAPI = brokerapi.NewWithOptions(fakeServiceBroker, brokerLogger, brokerapi.WithCustomAuth(wrapper.Wrap))
+				wrapper.SetCredentials("rotated-username", "rotated-password")
+			})
+
+			It("accepts the new credentials", func() {
+				recorder := httptest.NewRecorder()
+				request, _ := http.NewRequest("GET", "/v2/catalog", nil)
+				request.Header.Add("X-Broker-API-Version", "2.14")
+				request.SetBasicAuth("rotated-username", "rotated-password")
+				brokerAPI.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(200))
+			})
+
+			It("returns 401 for the old credentials", func() {
+				response := makeRequestWithAuth(credentials.Username, credentials.Password)
+				Expect(response.StatusCode).To(Equal(401))
+			})
+		})
 	})
 
 	Describe("OriginatingIdentityHeader", func() {
@@ -271,97 +345,2721 @@ var _ = Describe("Service Broker API", func() {
 			req.SetBasicAuth(credentials.Username, credentials.Password)
 		})
 
-		AfterEach(func() {
-			testServer.Close()
+		AfterEach(func() {
+			testServer.Close()
+		})
+
+		When("X-Broker-API-Originating-Identity is passed", func() {
+			It("Adds it to the context", func() {
+				originatingIdentity := "Originating Identity Name"
+				req.Header.Add("X-Broker-API-Originating-Identity", originatingIdentity)
+
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+				ctx := fakeServiceBroker.ServicesArgsForCall(0)
+				value, ok := contextkeys.OriginatingIdentity(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(value).To(Equal(originatingIdentity))
+				Expect(ctx.Value("originatingIdentity")).To(Equal(originatingIdentity), "the deprecated string key is still set")
+			})
+		})
+		When("X-Broker-API-Originating-Identity is not passed", func() {
+			It("Adds empty originatingIdentity to the context", func() {
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+				ctx := fakeServiceBroker.ServicesArgsForCall(0)
+				value, ok := contextkeys.OriginatingIdentity(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(value).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("XRegionHeader", func() {
+
+		var (
+			fakeServiceBroker *fakes.AutoFakeServiceBroker
+			req               *http.Request
+			testServer        *httptest.Server
+		)
+
+		BeforeEach(func() {
+			fakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			brokerAPI = brokerapi.New(fakeServiceBroker, brokerLogger, credentials)
+
+			testServer = httptest.NewServer(brokerAPI)
+			var err error
+			req, err = http.NewRequest("GET", testServer.URL+"/v2/catalog", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Add("X-Broker-API-Version", "2.14")
+			req.SetBasicAuth(credentials.Username, credentials.Password)
+		})
+
+		AfterEach(func() {
+			testServer.Close()
+		})
+
+		When("X-Region is passed", func() {
+			It("Adds it to the context", func() {
+				region := "India"
+				req.Header.Add("X-Bluemix-Region", region)
+
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+				ctx := fakeServiceBroker.ServicesArgsForCall(0)
+				value, ok := contextkeys.Region(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(value).To(Equal(region))
+				Expect(ctx.Value("X-Region")).To(Equal(region), "the deprecated string key is still set")
+			})
+		})
+		When("X-Region is passed", func() {
+			It("Adds it to the context", func() {
+				region := "India"
+				req.Header.Add("X-Region", region)
+
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+				ctx := fakeServiceBroker.ServicesArgsForCall(0)
+				value, ok := contextkeys.Region(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(value).To(Equal(region))
+
+			})
+		})
+		When("X-Region is not passed", func() {
+			It("Adds empty X-Region to the context", func() {
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+				ctx := fakeServiceBroker.ServicesArgsForCall(0)
+				value, ok := contextkeys.Region(ctx)
+				Expect(ok).To(BeTrue())
+				Expect(value).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("request deadlines", func() {
+		var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+		makeCatalogRequest := func(headers map[string]string) context.Context {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("GET", "/v2/catalog", nil)
+			request.Header.Add("X-Broker-API-Version", "2.14")
+			for name, value := range headers {
+				request.Header.Add(name, value)
+			}
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+			brokerAPI.ServeHTTP(recorder, request)
+
+			Expect(autoFakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
+			return autoFakeServiceBroker.ServicesArgsForCall(0)
+		}
+
+		newBrokerAPI := func(opts ...brokerapi.Option) {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			opts = append(opts, brokerapi.WithBrokerCredentials(credentials))
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, opts...)
+		}
+
+		It("does not set a deadline by default", func() {
+			newBrokerAPI()
+
+			_, hasDeadline := makeCatalogRequest(nil).Deadline()
+			Expect(hasDeadline).To(BeFalse())
+		})
+
+		It("applies the default timeout", func() {
+			newBrokerAPI(brokerapi.WithDefaultTimeout(time.Minute))
+
+			deadline, hasDeadline := makeCatalogRequest(nil).Deadline()
+			Expect(hasDeadline).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), 5*time.Second))
+		})
+
+		It("prefers the timeout configured for the operation", func() {
+			newBrokerAPI(
+				brokerapi.WithDefaultTimeout(time.Minute),
+				brokerapi.WithOperationTimeout(brokerapi.OperationCatalog, 10*time.Second),
+			)
+
+			deadline, _ := makeCatalogRequest(nil).Deadline()
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(10*time.Second), 5*time.Second))
+		})
+
+		It("ignores timeouts configured for other operations", func() {
+			newBrokerAPI(brokerapi.WithOperationTimeout(brokerapi.OperationProvision, 10*time.Second))
+
+			_, hasDeadline := makeCatalogRequest(nil).Deadline()
+			Expect(hasDeadline).To(BeFalse())
+		})
+
+		Context("when the platform sends a timeout hint", func() {
+			It("uses the hint when no timeout is configured", func() {
+				newBrokerAPI(brokerapi.WithTimeoutHeader("X-Broker-API-Request-Timeout"))
+
+				deadline, _ := makeCatalogRequest(map[string]string{"X-Broker-API-Request-Timeout": "30"}).Deadline()
+				Expect(deadline).To(BeTemporally("~", time.Now().Add(30*time.Second), 5*time.Second))
+			})
+
+			It("accepts duration strings", func() {
+				newBrokerAPI(brokerapi.WithTimeoutHeader("X-Broker-API-Request-Timeout"))
+
+				deadline, _ := makeCatalogRequest(map[string]string{"X-Broker-API-Request-Timeout": "2m"}).Deadline()
+				Expect(deadline).To(BeTemporally("~", time.Now().Add(2*time.Minute), 5*time.Second))
+			})
+
+			It("does not extend the configured timeout", func() {
+				newBrokerAPI(
+					brokerapi.WithDefaultTimeout(10*time.Second),
+					brokerapi.WithTimeoutHeader("X-Broker-API-Request-Timeout"),
+				)
+
+				deadline, _ := makeCatalogRequest(map[string]string{"X-Broker-API-Request-Timeout": "600"}).Deadline()
+				Expect(deadline).To(BeTemporally("~", time.Now().Add(10*time.Second), 5*time.Second))
+			})
+
+			It("ignores invalid hints", func() {
+				newBrokerAPI(brokerapi.WithTimeoutHeader("X-Broker-API-Request-Timeout"))
+
+				_, hasDeadline := makeCatalogRequest(map[string]string{"X-Broker-API-Request-Timeout": "soon"}).Deadline()
+				Expect(hasDeadline).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("request cancellation", func() {
+		var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+		makeProvisionRequest := func(ctx context.Context) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			body := `{"service_id":"service-id","plan_id":"plan-id"}`
+			request, _ := http.NewRequest("PUT", "/v2/service_instances/instance-id", strings.NewReader(body))
+			request.Header.Add("X-Broker-API-Version", "2.14")
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+			brokerAPI.ServeHTTP(recorder, request.WithContext(ctx))
+			return recorder
+		}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials)
+		})
+
+		It("does not call the broker when the platform has already disconnected", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			response := makeProvisionRequest(ctx)
+			Expect(response.Code).To(Equal(499))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(0))
+			Expect(lastLogLine().Message).To(ContainSubstring(".provision.request-cancelled"))
+		})
+
+		It("cancels the broker context when the platform disconnects", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var brokerCtx context.Context
+			autoFakeServiceBroker.ProvisionStub = func(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+				brokerCtx = ctx
+				cancel()
+				return brokerapi.ProvisionedServiceSpec{}, ctx.Err()
+			}
+
+			makeProvisionRequest(ctx)
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(1))
+			Expect(brokerCtx.Err()).To(Equal(context.Canceled))
+		})
+	})
+
+	Describe("lifecycle events", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			events                chan brokerapi.LifecycleEvent
+		)
+
+		makeRequest := func(method, path, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest(method, path, strings.NewReader(body))
+			request.Header.Add("X-Broker-API-Version", "2.14")
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+			brokerAPI.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			events = make(chan brokerapi.LifecycleEvent, 10)
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithLifecycleEventSink(lifecycleEventSinkFunc(func(ctx context.Context, event brokerapi.LifecycleEvent) error {
+					events <- event
+					return nil
+				})),
+			)
+		})
+
+		It("emits an event when an instance is provisioned", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "op"}, nil)
+
+			response := makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{"service_id":"service-id","plan_id":"plan-id"}`)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+
+			var event brokerapi.LifecycleEvent
+			Eventually(events).Should(Receive(&event))
+			Expect(event.Type).To(Equal(brokerapi.EventInstanceProvisioned))
+			Expect(event.InstanceID).To(Equal("instance-id"))
+			Expect(event.ServiceID).To(Equal("service-id"))
+			Expect(event.PlanID).To(Equal("plan-id"))
+			Expect(event.IsAsync).To(BeTrue())
+			Expect(event.OperationData).To(Equal("op"))
+			Expect(event.Time).NotTo(BeZero())
+		})
+
+		It("tags provision events with the service, context and parameter tags", func() {
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Tags: []string{"mysql", "relational"}, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+
+			response := makeRequest("PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"plan-id","context":{"tags":["team-a","mysql"]},"parameters":{"tags":["billing"]}}`)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+
+			var event brokerapi.LifecycleEvent
+			Eventually(events).Should(Receive(&event))
+			Expect(event.Tags).To(Equal([]string{"mysql", "relational", "team-a", "billing"}))
+		})
+
+		It("emits an event when a binding is deleted", func() {
+			response := makeRequest("DELETE", "/v2/service_instances/instance-id/service_bindings/binding-id?service_id=service-id&plan_id=plan-id", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var event brokerapi.LifecycleEvent
+			Eventually(events).Should(Receive(&event))
+			Expect(event.Type).To(Equal(brokerapi.EventBindingDeleted))
+			Expect(event.InstanceID).To(Equal("instance-id"))
+			Expect(event.BindingID).To(Equal("binding-id"))
+		})
+
+		It("emits an event when polling reports a failed operation", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed, Description: "out of capacity"}, nil)
+
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation?operation=op", "")
+
+			var event brokerapi.LifecycleEvent
+			Eventually(events).Should(Receive(&event))
+			Expect(event.Type).To(Equal(brokerapi.EventOperationFailed))
+			Expect(event.OperationData).To(Equal("op"))
+			Expect(event.Description).To(Equal("out of capacity"))
+		})
+
+		It("emits the end of an operation once however often it is polled", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation?operation=op", "")
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation?operation=op", "")
+			Eventually(events).Should(Receive())
+			Consistently(events).ShouldNot(Receive())
+
+			makeRequest("GET", "/v2/service_instances/other-instance-id/last_operation?operation=op", "")
+			Eventually(events).Should(Receive())
+		})
+
+		It("emits the end of a new operation accepted with the same operation data", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true}, nil)
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed}, nil)
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation", "")
+			Eventually(events).Should(Receive())
+
+			makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{"service_id":"service-id","plan_id":"plan-id"}`)
+			Eventually(events).Should(Receive())
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation", "")
+			Eventually(events).Should(Receive())
+		})
+
+		It("does not emit events while an operation is in progress", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation", "")
+			Consistently(events).ShouldNot(Receive())
+		})
+
+		It("does not emit events when the broker fails", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, errors.New("boom"))
+
+			makeRequest("PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"plan-id"}`)
+			Consistently(events).ShouldNot(Receive())
+		})
+
+		It("does not publish failed operations", func() {
+			published := make(chan brokerapi.LifecycleEvent, 10)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithPublisher(publisherFunc(func(ctx context.Context, event brokerapi.LifecycleEvent) error {
+					published <- event
+					return nil
+				})),
+			)
+
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed}, nil)
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation?operation=op-1", "")
+			Consistently(published).ShouldNot(Receive())
+
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation?operation=op-2", "")
+			Eventually(published).Should(Receive(WithTransform(func(e brokerapi.LifecycleEvent) brokerapi.LifecycleEventType {
+				return e.Type
+			}, Equal(brokerapi.EventOperationSucceeded))))
+		})
+
+		It("logs sink errors", func() {
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithLifecycleEventSink(lifecycleEventSinkFunc(func(ctx context.Context, event brokerapi.LifecycleEvent) error {
+					return errors.New("sink unavailable")
+				})),
+			)
+
+			makeRequest("PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"plan-id"}`)
+			Eventually(brokerLogger.LogMessages).Should(ContainElement("broker-api.provision.emit-lifecycle-event-failed"))
+		})
+	})
+
+	Describe("usage metering", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			records               chan brokerapi.UsageRecord
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			records = make(chan brokerapi.UsageRecord, 10)
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "small"}, {ID: "large"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithMeter(meterFunc(func(ctx context.Context, record brokerapi.UsageRecord) error {
+					records <- record
+					return nil
+				})),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("starts the use of a plan when an instance is provisioned", func() {
+			response := tester.Do("PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"small","organization_guid":"org-guid","space_guid":"space-guid"}`)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+
+			var record brokerapi.UsageRecord
+			Eventually(records).Should(Receive(&record))
+			Expect(record.Time).NotTo(BeZero())
+			record.Time = time.Time{}
+			Expect(record).To(Equal(brokerapi.UsageRecord{
+				Type:             brokerapi.UsageStarted,
+				InstanceID:       "instance-id",
+				ServiceID:        "service-id",
+				PlanID:           "small",
+				OrganizationGUID: "org-guid",
+				SpaceGUID:        "space-guid",
+			}))
+		})
+
+		It("stops the use of the previous plan and starts the new one when the plan changes", func() {
+			response := tester.Do("PATCH", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"large","previous_values":{"plan_id":"small","organization_id":"org-guid","space_id":"space-guid"}}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var stopped, started brokerapi.UsageRecord
+			Eventually(records).Should(Receive(&stopped))
+			Eventually(records).Should(Receive(&started))
+			Expect(stopped.Type).To(Equal(brokerapi.UsageStopped))
+			Expect(stopped.PlanID).To(Equal("small"))
+			Expect(started.Type).To(Equal(brokerapi.UsageStarted))
+			Expect(started.PlanID).To(Equal("large"))
+			Expect(started.OrganizationGUID).To(Equal("org-guid"))
+		})
+
+		It("records nothing for updates keeping the plan", func() {
+			response := tester.Do("PATCH", "/v2/service_instances/instance-id", `{"service_id":"service-id","parameters":{"size":2},"previous_values":{"plan_id":"small"}}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Consistently(records).ShouldNot(Receive())
+		})
+
+		It("stops the use of the plan when an instance is deprovisioned", func() {
+			response := tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=small", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var record brokerapi.UsageRecord
+			Eventually(records).Should(Receive(&record))
+			Expect(record.Type).To(Equal(brokerapi.UsageStopped))
+			Expect(record.InstanceID).To(Equal("instance-id"))
+			Expect(record.PlanID).To(Equal("small"))
+		})
+
+		It("records nothing when the broker fails", func() {
+			autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{}, errors.New("boom"))
+
+			tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=small", nil)
+			Consistently(records).ShouldNot(Receive())
+		})
+
+		Context("when operations are asynchronous", func() {
+			provision := func() {
+				autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "provision-op"}, nil)
+				response := tester.Do("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{"service_id":"service-id","plan_id":"small","organization_guid":"org-guid","space_guid":"space-guid"}`)
+				Expect(response.Code).To(Equal(http.StatusAccepted))
+			}
+
+			poll := func(operation string) int {
+				return tester.Do("GET", "/v2/service_instances/instance-id/last_operation?operation="+operation, nil).Code
+			}
+
+			It("records the usage once a poll reports that the operation succeeded", func() {
+				provision()
+				autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+				Expect(poll("provision-op")).To(Equal(http.StatusOK))
+				Consistently(records).ShouldNot(Receive())
+
+				autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+				Expect(poll("provision-op")).To(Equal(http.StatusOK))
+				Expect(poll("provision-op")).To(Equal(http.StatusOK))
+
+				var record brokerapi.UsageRecord
+				Eventually(records).Should(Receive(&record))
+				Expect(record.Type).To(Equal(brokerapi.UsageStarted))
+				Expect(record.PlanID).To(Equal("small"))
+				Expect(record.OrganizationGUID).To(Equal("org-guid"))
+				Consistently(records).ShouldNot(Receive())
+			})
+
+			It("records nothing for operations which fail", func() {
+				provision()
+				autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Failed}, nil)
+				Expect(poll("provision-op")).To(Equal(http.StatusOK))
+				Consistently(records).ShouldNot(Receive())
+			})
+
+			It("stops the use of the plan when the instance of a deprovision is gone", func() {
+				autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: "deprovision-op"}, nil)
+				response := tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=small&accepts_incomplete=true", nil)
+				Expect(response.Code).To(Equal(http.StatusAccepted))
+				Consistently(records).ShouldNot(Receive())
+
+				autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{}, brokerapi.ErrInstanceDoesNotExist)
+				Expect(poll("deprovision-op")).To(Equal(http.StatusGone))
+
+				var record brokerapi.UsageRecord
+				Eventually(records).Should(Receive(&record))
+				Expect(record.Type).To(Equal(brokerapi.UsageStopped))
+				Expect(record.PlanID).To(Equal("small"))
+			})
+		})
+	})
+
+	Describe("custom middlewares", func() {
+		It("runs them before authentication, in the order given", func() {
+			var calls []string
+			middleware := func(name string) func(http.Handler) http.Handler {
+				return func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						calls = append(calls, name)
+						next.ServeHTTP(w, req)
+					})
+				}
+			}
+			brokerAPI = brokerapi.NewWithOptions(fakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithMiddleware(middleware("first")),
+				brokerapi.WithMiddleware(middleware("second")),
+			)
+
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("GET", "/v2/catalog", nil)
+			brokerAPI.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(calls).To(Equal([]string{"first", "second"}))
+		})
+	})
+
+	Describe("error reporting", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			reports               []brokerapi.ErrorReport
+		)
+
+		makeProvisionRequest := func() *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			body := `{"service_id":"service-id","plan_id":"plan-id"}`
+			request, _ := http.NewRequest("PUT", "/v2/service_instances/instance-id", strings.NewReader(body))
+			request.Header.Add("X-Broker-API-Version", "2.14")
+			request.Header.Add("X-Broker-API-Request-Identity", "request-id")
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+			brokerAPI.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		BeforeEach(func() {
+			reports = nil
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithErrorReporter(errorReporterFunc(func(ctx context.Context, report brokerapi.ErrorReport) {
+					reports = append(reports, report)
+				})),
+			)
+		})
+
+		It("reports unknown broker errors with the request metadata", func() {
+			brokerErr := pkgerrors.Wrap(errors.New("connection refused"), "creating database")
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, brokerErr)
+
+			response := makeProvisionRequest()
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+
+			Expect(reports).To(HaveLen(1))
+			report := reports[0]
+			Expect(report.Operation).To(Equal(brokerapi.OperationProvision))
+			Expect(report.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(report.Method).To(Equal("PUT"))
+			Expect(report.Path).To(Equal("/v2/service_instances/instance-id"))
+			Expect(report.InstanceID).To(Equal("instance-id"))
+			Expect(report.APIVersion).To(Equal("2.14"))
+			Expect(report.RequestIdentity).To(Equal("request-id"))
+			Expect(report.Err).To(Equal(brokerErr))
+			Expect(report.Chain()).To(HaveLen(3))
+			Expect(report.Chain()[2]).To(MatchError("connection refused"))
+		})
+
+		It("reports failure responses with a 5xx status", func() {
+			cause := errors.New("upstream unavailable")
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, brokerapi.NewFailureResponse(cause, http.StatusBadGateway, "upstream"))
+
+			response := makeProvisionRequest()
+			Expect(response.Code).To(Equal(http.StatusBadGateway))
+
+			Expect(reports).To(HaveLen(1))
+			Expect(reports[0].StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(reports[0].Chain()).To(ConsistOf(reports[0].Err, cause))
+		})
+
+		It("does not report client errors", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, brokerapi.ErrInstanceAlreadyExists)
+
+			response := makeProvisionRequest()
+			Expect(response.Code).To(Equal(http.StatusConflict))
+			Expect(reports).To(BeEmpty())
+		})
+	})
+
+	Describe("strict response validation", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		details := map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithStrictResponseValidation(),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("passes valid responses through", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{DashboardURL: "https://dashboard.example.com"}, nil)
+
+			response := tester.Provision("instance-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Body.String()).To(MatchJSON(`{"dashboard_url":"https://dashboard.example.com"}`))
+		})
+
+		It("rejects a dashboard_url which is not an absolute URL", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{DashboardURL: "dashboard"}, nil)
+
+			response := tester.Provision("instance-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"invalid broker response: dashboard_url \"dashboard\" is not an absolute URL"}`))
+			Expect(lastLogLine().Message).To(ContainSubstring("invalid-broker-response"))
+		})
+
+		It("rejects operation strings longer than 10,000 characters", func() {
+			autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: strings.Repeat("x", 10001)}, nil)
+
+			response := tester.Deprovision("instance-id", "service-id", "plan-id", true)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring("operation is 10001 characters long, the maximum is 10000"))
+		})
+
+		It("rejects unknown last operation states", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: "done"}, nil)
+
+			response := tester.LastOperation("instance-id", "")
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring(`state \"done\" is not one of`))
+		})
+
+		It("rejects failure details on operations which have not failed", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded, InstanceUsable: brokerapi.BoolPtr(true)}, nil)
+
+			response := tester.LastOperation("instance-id", "")
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring(`instance_usable is only allowed when the state is \"failed\"`))
+		})
+
+		It("sends the progress of operations in the description", func() {
+			progress := brokerapi.StepProgress(2, 5, "creating database")
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress, Description: "Provisioning", Progress: &progress}, nil)
+			autoFakeServiceBroker.LastBindingOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress, Progress: &progress}, nil)
+
+			response := tester.LastOperation("instance-id", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"state":"in progress","description":"Provisioning: creating database (step 2/5, 20%)"}`))
+
+			response = tester.LastBindingOperation("instance-id", "binding-id", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"state":"in progress","description":"creating database (step 2/5, 20%)"}`))
+		})
+
+		It("rejects invalid progress", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded, Progress: &brokerapi.Progress{Step: 6, TotalSteps: 5, Percent: 120}}, nil)
+
+			response := tester.LastOperation("instance-id", "")
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"invalid broker response: progress is only allowed when the state is \"in progress\"; progress percent 120 is not between 0 and 100; progress step 6 is not between 1 and 5"}`))
+		})
+
+		It("rejects a binding without credentials", func() {
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{}, nil)
+
+			response := tester.Bind("instance-id", "binding-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring("binding has no credentials"))
+		})
+
+		It("lists every violation of a response", func() {
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{
+				Credentials:     "credentials",
+				RouteServiceURL: "http://route.example.com",
+			}, nil)
+
+			response := tester.Bind("instance-id", "binding-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"invalid broker response: route_service_url \"http://route.example.com\" must use https; route_service_url is returned but the service does not require \"route_forwarding\""}`))
+		})
+
+		It("does not validate responses unless enabled", func() {
+			tester = brokertest.New(brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials), credentials.Username, credentials.Password)
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{}, nil)
+
+			response := tester.Bind("instance-id", "binding-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+		})
+	})
+
+	Describe("credentials opener", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			ring                  *envelope.KeyRing
+			tester                brokertest.BrokerTester
+		)
+
+		details := map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+
+		BeforeEach(func() {
+			var err error
+			ring, err = envelope.NewKeyRing("key-1", bytes.Repeat([]byte{1}, envelope.KeySize))
+			Expect(err).NotTo(HaveOccurred())
+
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, BindingsRetrievable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithCredentialsOpener(ring),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("decrypts the credentials of new and fetched bindings", func() {
+			sealed, err := ring.SealCredentials(map[string]string{"password": "secret"})
+			Expect(err).NotTo(HaveOccurred())
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: sealed}, nil)
+			autoFakeServiceBroker.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: sealed}, nil)
+
+			response := tester.Bind("instance-id", "binding-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+
+			response = tester.GetBinding("instance-id", "binding-id")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+		})
+
+		It("passes credentials which are not sealed on unchanged", func() {
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: map[string]string{"password": "plain"}}, nil)
+
+			response := tester.Bind("instance-id", "binding-id", details, false)
+			Expect(response.Body.String()).To(MatchJSON(`{"credentials":{"password":"plain"}}`))
+		})
+
+		It("responds with a 500 when the credentials cannot be decrypted", func() {
+			autoFakeServiceBroker.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: envelope.Sealed("v1.key-2.a.b")}, nil)
+
+			response := tester.GetBinding("instance-id", "binding-id")
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the binding credentials could not be decrypted"}`))
+			Expect(lastLogLine().Message).To(ContainSubstring("open-credentials-failed"))
+		})
+	})
+
+	Describe("chaos broker", func() {
+		var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+		details := map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+
+		newTester := func(config fakes.ChaosConfig) (*fakes.ChaosBroker, brokertest.BrokerTester) {
+			chaos := fakes.NewChaosBroker(autoFakeServiceBroker, config)
+			return chaos, brokertest.New(brokerapi.New(chaos, brokerLogger, credentials), credentials.Username, credentials.Password)
+		}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+		})
+
+		It("fails every nth call of an operation", func() {
+			chaos, tester := newTester(fakes.ChaosConfig{FailEvery: 2})
+
+			Expect(tester.Provision("instance-1", details, false).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Provision("instance-2", details, false).Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(tester.Provision("instance-3", details, false).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+			Expect(chaos.Calls(brokerapi.OperationProvision)).To(Equal(3))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(2))
+		})
+
+		It("fails calls at random", func() {
+			_, tester := newTester(fakes.ChaosConfig{FailureRate: 1, Operations: []brokerapi.Operation{brokerapi.OperationBind}})
+			Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Bind("instance-id", "binding-id", details, false).Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("delays calls until their context is done", func() {
+			_, tester := newTester(fakes.ChaosConfig{Latency: 10 * time.Millisecond})
+			start := time.Now()
+			tester.Provision("instance-id", details, false)
+			Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+
+			chaos := fakes.NewChaosBroker(autoFakeServiceBroker, fakes.ChaosConfig{Latency: time.Hour})
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := chaos.GetInstance(ctx, "instance-id")
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("finishes asynchronous operations after a number of polls", func() {
+			_, tester := newTester(fakes.ChaosConfig{AsyncPolls: 2, AsyncOutcome: brokerapi.Failed, AsyncDescription: "quota exceeded"})
+
+			response := tester.Provision("instance-id", details, true)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+			Expect(response.Body.String()).To(MatchJSON(`{"operation":"chaos-1"}`))
+
+			Expect(tester.LastOperation("instance-id", "chaos-1").Body.String()).To(MatchJSON(`{"state":"in progress","description":"step 1/2"}`))
+			Expect(tester.LastOperation("instance-id", "chaos-1").Body.String()).To(MatchJSON(`{"state":"in progress","description":"step 2/2, 50%"}`))
+			Expect(tester.LastOperation("instance-id", "chaos-1").Body.String()).To(MatchJSON(`{"state":"failed","description":"quota exceeded"}`))
+			Expect(autoFakeServiceBroker.LastOperationCallCount()).To(BeZero())
+		})
+
+		It("completes operations synchronously when the platform does not accept asynchronous ones", func() {
+			_, tester := newTester(fakes.ChaosConfig{AsyncPolls: 2})
+			Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+		})
+	})
+
+	Describe("circuit breaker", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, errors.New("backend down"))
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithCircuitBreaker(2, 50*time.Millisecond),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("fails fast once the threshold of failures in a row is reached", func() {
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusInternalServerError))
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusInternalServerError))
+
+			response := tester.GetInstance("instance-id")
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Header().Get("Retry-After")).To(Equal("1"))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker is unavailable, try again later"}`))
+			Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(Equal(2))
+			Expect(brokerLogger.LogMessages()).To(ContainElement(ContainSubstring("circuit-breaker.circuit-opened")))
+		})
+
+		It("does not open on failures which are not in a row", func() {
+			tester.GetInstance("instance-id")
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("closes again once a request succeeds after the cool-down", func() {
+			tester.GetInstance("instance-id")
+			tester.GetInstance("instance-id")
+			Expect(tester.Catalog().Code).To(Equal(http.StatusServiceUnavailable))
+
+			time.Sleep(50 * time.Millisecond)
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+			Expect(brokerLogger.LogMessages()).To(ContainElement(ContainSubstring("circuit-breaker.circuit-closed")))
+		})
+
+		It("stays open when the request after the cool-down fails", func() {
+			tester.GetInstance("instance-id")
+			tester.GetInstance("instance-id")
+
+			time.Sleep(50 * time.Millisecond)
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusInternalServerError))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("releases a probe whose handler panics", func() {
+			tester.GetInstance("instance-id")
+			tester.GetInstance("instance-id")
+
+			time.Sleep(50 * time.Millisecond)
+			autoFakeServiceBroker.GetInstanceStub = func(ctx context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
+				panic("broker bug")
+			}
+			Expect(func() { tester.GetInstance("instance-id") }).To(Panic())
+			Expect(tester.Catalog().Code).To(Equal(http.StatusServiceUnavailable))
+
+			time.Sleep(50 * time.Millisecond)
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not count extensions the broker does not implement as failures", func() {
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{},
+				brokerapi.NewFailureResponse(errors.New("not implemented"), http.StatusNotImplemented, "get-instance"))
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusNotImplemented))
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusNotImplemented))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("concurrency limits", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			started               chan string
+			release               chan struct{}
+			details               map[string]string
+		)
+
+		newTester := func(opts ...brokerapi.Option) brokertest.BrokerTester {
+			opts = append([]brokerapi.Option{brokerapi.WithBrokerCredentials(credentials)}, opts...)
+			return brokertest.New(brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, opts...), credentials.Username, credentials.Password)
+		}
+
+		BeforeEach(func() {
+			started = make(chan string, 10)
+			release = make(chan struct{})
+			details = map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			// Requests still in flight when a test ends are released after the
+			// next test has set up new channels, so they keep their own.
+			started, release := started, release
+			autoFakeServiceBroker.ProvisionStub = func(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+				started <- instanceID
+				<-release
+				return brokerapi.ProvisionedServiceSpec{}, nil
+			}
+		})
+
+		AfterEach(func() {
+			close(release)
+		})
+
+		It("rejects state-changing requests over the limit once the queue timeout passes", func() {
+			tester := newTester(brokerapi.WithConcurrencyLimit(1, 20*time.Millisecond))
+			go tester.Provision("instance-1", details, false)
+			Eventually(started).Should(Receive())
+
+			response := tester.Bind("instance-1", "binding-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Header().Get("Retry-After")).To(Equal("1"))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker is serving too many requests, try again later"}`))
+			Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(0))
+			Expect(brokerLogger.LogMessages()).To(ContainElement("broker-api.concurrency-limit.request-rejected"))
+		})
+
+		It("serves queued requests when a request finishes in time", func() {
+			tester := newTester(brokerapi.WithConcurrencyLimit(1, time.Second))
+			go tester.Provision("instance-1", details, false)
+			Eventually(started).Should(Receive())
+
+			codes := make(chan int, 1)
+			go func() { codes <- tester.Provision("instance-2", details, false).Code }()
+			Consistently(started, 50*time.Millisecond).ShouldNot(Receive())
+
+			release <- struct{}{}
+			Eventually(started).Should(Receive(Equal("instance-2")))
+			release <- struct{}{}
+			Eventually(codes).Should(Receive(Equal(http.StatusCreated)))
+		})
+
+		It("limits operations separately", func() {
+			tester := newTester(brokerapi.WithOperationConcurrencyLimit(brokerapi.OperationProvision, 1))
+			go tester.Provision("instance-1", details, false)
+			Eventually(started).Should(Receive())
+
+			Expect(tester.Provision("instance-2", details, false).Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(tester.Bind("instance-1", "binding-id", details, false).Code).To(Equal(http.StatusCreated))
+		})
+
+		It("does not limit requests which only read state", func() {
+			tester := newTester(brokerapi.WithConcurrencyLimit(1, 10*time.Millisecond))
+			go tester.Provision("instance-1", details, false)
+			Eventually(started).Should(Receive())
+
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+			Expect(tester.LastOperation("instance-1", "").Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not open the circuit breaker for rejected requests", func() {
+			tester := newTester(
+				brokerapi.WithConcurrencyLimit(1, 10*time.Millisecond),
+				brokerapi.WithCircuitBreaker(1, time.Minute),
+			)
+			go tester.Provision("instance-1", details, false)
+			Eventually(started).Should(Receive())
+
+			Expect(tester.Provision("instance-2", details, false).Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("timeouts", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", InstancesRetrievable: true, Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("responds with a 504 when the broker returns context.DeadlineExceeded", func() {
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, context.DeadlineExceeded)
+
+			response := tester.GetInstance("instance-id")
+			Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+			Expect(response.Body.String()).To(MatchJSON(`{"error":"OperationTimedOut","description":"the broker did not complete the operation in time"}`))
+			Expect(lastLogLine().Message).To(ContainSubstring("operation-timed-out"))
+		})
+
+		It("responds with a 504 when the deadline error is wrapped", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, fmt.Errorf("creating database: %w", context.DeadlineExceeded))
+
+			response := tester.Provision("instance-id", map[string]string{"service_id": "service-id", "plan_id": "plan-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+		})
+
+		It("responds with a 504 when the broker returns ErrOperationTimedOut", func() {
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{}, brokerapi.ErrOperationTimedOut)
+
+			response := tester.Bind("instance-id", "binding-id", map[string]string{"service_id": "service-id", "plan_id": "plan-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+			Expect(response.Body.String()).To(ContainSubstring("OperationTimedOut"))
+		})
+
+		It("keeps the status of failure responses wrapping a deadline", func() {
+			failure := brokerapi.NewFailureResponse(context.DeadlineExceeded, http.StatusServiceUnavailable, "backend-slow")
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, failure)
+
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Describe("service unavailable", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", InstancesRetrievable: true, Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("responds with a 503 for ErrServiceUnavailable", func() {
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, fmt.Errorf("maintenance: %w", brokerapi.ErrServiceUnavailable))
+
+			response := tester.GetInstance("instance-id")
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Header().Get("Retry-After")).To(BeEmpty())
+			Expect(response.Body.String()).To(MatchJSON(`{"error":"ServiceUnavailable","description":"the service is temporarily unavailable, try again later"}`))
+		})
+
+		It("sends the Retry-After header in whole seconds", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, brokerapi.NewServiceUnavailable(1500*time.Millisecond))
+
+			response := tester.Provision("instance-id", map[string]string{"service_id": "service-id", "plan_id": "plan-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Header().Get("Retry-After")).To(Equal("2"))
+		})
+
+		It("sends the Retry-After header from bind", func() {
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{}, brokerapi.NewServiceUnavailable(time.Minute))
+
+			response := tester.Bind("instance-id", "binding-id", map[string]string{"service_id": "service-id", "plan_id": "plan-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Header().Get("Retry-After")).To(Equal("60"))
+		})
+	})
+
+	Describe("quotas", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			store                 *state.Memory
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "small"}, {ID: "large"}}},
+			}, nil)
+			store = state.NewMemory()
+			Expect(store.CreateInstance(context.Background(), state.Instance{ID: "existing", ServiceID: "service-id", PlanID: "small"})).To(Succeed())
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithQuotas(quota.New(store, quota.Limits{Plans: map[string]int{"small": 1}})),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("rejects provisions over quota without calling the broker", func() {
+			response := tester.Provision("instance-id", map[string]string{"service_id": "service-id", "plan_id": "small"}, false)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(MatchJSON(`{"error":"QuotaExceeded","description":"The quota for this service plan has been exceeded. Please contact your Operator for help."}`))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(BeZero())
+			Expect(lastLogLine().Message).To(ContainSubstring("quota-exceeded"))
+		})
+
+		It("provisions instances of plans under quota", func() {
+			response := tester.Provision("instance-id", map[string]string{"service_id": "service-id", "plan_id": "large"}, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(1))
+		})
+
+		It("rejects plan changes over quota", func() {
+			details := map[string]interface{}{
+				"service_id":      "service-id",
+				"plan_id":         "small",
+				"previous_values": map[string]string{"plan_id": "large"},
+			}
+			Expect(tester.Update("instance-id", details, false).Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(autoFakeServiceBroker.UpdateCallCount()).To(BeZero())
+		})
+
+		It("does not check updates keeping the plan", func() {
+			details := map[string]interface{}{
+				"service_id":      "service-id",
+				"plan_id":         "small",
+				"previous_values": map[string]string{"plan_id": "small"},
+			}
+			Expect(tester.Update("instance-id", details, false).Code).To(Equal(http.StatusOK))
+			Expect(autoFakeServiceBroker.UpdateCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("hints", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			tester = brokertest.New(brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials), credentials.Username, credentials.Password)
+		})
+
+		It("sends the quota hints of a provision as headers", func() {
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{Hints: brokerapi.QuotaHints(10, 4)}, nil)
+
+			response := tester.Provision("instance-id", map[string]string{"service_id": "service-id", "plan_id": "plan-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Header().Get("X-Broker-Quota-Limit")).To(Equal("10"))
+			Expect(response.Header().Get("X-Broker-Quota-Remaining")).To(Equal("6"))
+			Expect(response.Header()).NotTo(HaveKey("X-Broker-Ratelimit-Remaining"))
+		})
+
+		It("sends the hints of deprovisions, binds and unbinds", func() {
+			remaining := 0
+			hints := brokerapi.Hints{QuotaRemaining: &remaining, RateLimitRemaining: &remaining}
+			autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{Hints: hints}, nil)
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: map[string]string{}, Hints: hints}, nil)
+			autoFakeServiceBroker.UnbindReturns(brokerapi.UnbindSpec{Hints: hints}, nil)
+
+			for _, response := range []*httptest.ResponseRecorder{
+				tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=plan-id", nil),
+				tester.Do("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{"service_id":"service-id","plan_id":"plan-id"}`),
+				tester.Do("DELETE", "/v2/service_instances/instance-id/service_bindings/binding-id?service_id=service-id&plan_id=plan-id", nil),
+			} {
+				Expect(response.Header().Get("X-Broker-Quota-Remaining")).To(Equal("0"))
+				Expect(response.Header().Get("X-Broker-RateLimit-Remaining")).To(Equal("0"))
+			}
+		})
+
+		It("does not send hints the broker left out", func() {
+			response := tester.Update("instance-id", map[string]string{"service_id": "service-id"}, false)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header()).NotTo(HaveKey("X-Broker-Quota-Remaining"))
+		})
+	})
+
+	Describe("previous parameters", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		updateBody := `{"service_id":"service-id","parameters":{"size":2}}`
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id"}}, nil)
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{
+				Parameters: map[string]interface{}{"size": 1, "region": "eu"},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithPreviousParameters(),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("passes the parameters of the instance before the update to Update", func() {
+			Expect(tester.Do("PATCH", "/v2/service_instances/instance-id", updateBody).Code).To(Equal(http.StatusOK))
+
+			Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(Equal(1))
+			Expect(autoFakeServiceBroker.UpdateCallCount()).To(Equal(1))
+			_, _, details, _ := autoFakeServiceBroker.UpdateArgsForCall(0)
+			Expect(details.PreviousValues.Parameters).To(MatchJSON(`{"size":1,"region":"eu"}`))
+
+			diff, err := details.ParametersDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.Names()).To(Equal([]string{"size"}))
+		})
+
+		It("fails the update when the parameters cannot be fetched", func() {
+			autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.ErrInstanceDoesNotExist)
+
+			Expect(tester.Do("PATCH", "/v2/service_instances/instance-id", updateBody).Code).To(Equal(http.StatusGone))
+			Expect(autoFakeServiceBroker.UpdateCallCount()).To(BeZero())
+		})
+
+		It("does not fetch the parameters unless enabled", func() {
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+
+			brokertest.New(brokerAPI, credentials.Username, credentials.Password).Do("PATCH", "/v2/service_instances/instance-id", updateBody)
+			Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(BeZero())
+			_, _, details, _ := autoFakeServiceBroker.UpdateArgsForCall(0)
+			Expect(details.PreviousValues.Parameters).To(BeNil())
+		})
+	})
+
+	Describe("request bodies which cannot be decoded", func() {
+		var tester brokertest.BrokerTester
+
+		BeforeEach(func() {
+			brokerAPI = brokerapi.NewWithOptions(new(fakes.AutoFakeServiceBroker), brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("returns a 400 when the JSON ends early", func() {
+			response := tester.Do("PATCH", "/v2/service_instances/instance-id", `{"service_id":"service-id"`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(MatchJSON(`{
+				"error": "MalformedJSON",
+				"description": "the request body is not valid JSON: unexpected end of input"
+			}`))
+		})
+
+		It("returns a 422 naming the field holding a value of the wrong type", func() {
+			response := tester.Do("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{"service_id":"service-id","plan_id":"plan-id","app_guid":7}`)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(MatchJSON(`{
+				"error": "InvalidDetails",
+				"description": "app_guid must be of type string, not number",
+				"offset": 59,
+				"field": "app_guid"
+			}`))
+		})
+
+		It("names nested fields by their path", func() {
+			response := tester.Do("PATCH", "/v2/service_instances/instance-id", `{"service_id":"service-id","previous_values":{"plan_id":false}}`)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring(`"field":"previous_values.plan_id"`))
+		})
+	})
+
+	Describe("dynamic plans", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "small"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithDynamicPlans(),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("passes requests for plans which are not in the catalog to the broker", func() {
+			details := `{"service_id":"service-id","plan_id":"custom-4cpu","organization_guid":"org","space_guid":"space"}`
+			Expect(tester.Do("PUT", "/v2/service_instances/instance-id", details).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Do("PATCH", "/v2/service_instances/instance-id", details).Code).To(Equal(http.StatusOK))
+			Expect(tester.Do("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", details).Code).To(Equal(http.StatusCreated))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(1))
+			Expect(autoFakeServiceBroker.UpdateCallCount()).To(Equal(1))
+			Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(1))
+		})
+
+		It("still requires an app_guid to bind plans of services which require an app", func() {
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{
+				ID:       "service-id",
+				Bindable: true,
+				Requires: []brokerapi.RequiredPermission{brokerapi.PermissionApp},
+				Plans:    []brokerapi.ServicePlan{{ID: "small"}},
+			}}, nil)
+
+			response := tester.Do("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{"service_id":"service-id","plan_id":"custom-4cpu"}`)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring(`"error":"RequiresApp"`))
+			Expect(autoFakeServiceBroker.BindCallCount()).To(BeZero())
+		})
+
+		It("still requires a service_id and a plan_id", func() {
+			response := tester.Do("PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","organization_guid":"org","space_guid":"space"}`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"plan_id missing"}`))
+		})
+	})
+
+	Describe("catalog errors", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns(nil, errors.New("catalog unavailable"))
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		table.DescribeTable("responds with the error of the broker when the catalog cannot be fetched",
+			func(method, path, body string) {
+				response := tester.Do(method, path, body)
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"catalog unavailable"}`))
+				Expect(autoFakeServiceBroker.ProvisionCallCount() + autoFakeServiceBroker.UpdateCallCount() + autoFakeServiceBroker.BindCallCount()).To(BeZero())
+				Expect(autoFakeServiceBroker.GetInstanceCallCount() + autoFakeServiceBroker.GetBindingCallCount()).To(BeZero())
+			},
+			table.Entry("provision", "PUT", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"small","organization_guid":"org","space_guid":"space"}`),
+			table.Entry("update", "PATCH", "/v2/service_instances/instance-id", `{"service_id":"service-id","plan_id":"small"}`),
+			table.Entry("bind", "PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{"service_id":"service-id","plan_id":"small"}`),
+			table.Entry("fetch instance", "GET", "/v2/service_instances/instance-id", ""),
+			table.Entry("fetch binding", "GET", "/v2/service_instances/instance-id/service_bindings/binding-id", ""),
+		)
+	})
+
+	Describe("dry runs", func() {
+		var (
+			validator *validatingBroker
+			tester    brokertest.BrokerTester
+		)
+
+		provisionBody := `{"service_id":"service-id","plan_id":"small","organization_guid":"org","space_guid":"space","parameters":{"size":3}}`
+		bindBody := `{"service_id":"service-id","plan_id":"small","app_guid":"app"}`
+
+		BeforeEach(func() {
+			validator = &validatingBroker{AutoFakeServiceBroker: new(fakes.AutoFakeServiceBroker)}
+			validator.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "small"}, {ID: "large"}}},
+			}, nil)
+			store := state.NewMemory()
+			Expect(store.CreateInstance(context.Background(), state.Instance{ID: "existing", ServiceID: "service-id", PlanID: "small"})).To(Succeed())
+			brokerAPI = brokerapi.NewWithOptions(validator, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithQuotas(quota.New(store, quota.Limits{Plans: map[string]int{"small": 1}})),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("validates a provision without provisioning", func() {
+			validator.result = brokerapi.DryRunResult{Description: "would create a 3 node cluster"}
+
+			response := tester.Do("PUT", "/v2/service_instances/instance-id?dry_run=true", strings.Replace(provisionBody, "small", "large", 1))
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"dry_run":true,"description":"would create a 3 node cluster"}`))
+			Expect(validator.validated).To(Equal([]string{"provision instance-id"}))
+			Expect(validator.provisionDetails.RawParameters).To(MatchJSON(`{"size":3}`))
+			Expect(validator.ProvisionCallCount()).To(BeZero())
+		})
+
+		It("responds with the error the request would fail with", func() {
+			validator.err = brokerapi.ErrRawParamsInvalid
+
+			response := tester.Do("PUT", "/v2/service_instances/instance-id?dry_run=true", strings.Replace(provisionBody, "small", "large", 1))
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(validator.ProvisionCallCount()).To(BeZero())
+		})
+
+		It("checks the quota", func() {
+			response := tester.Do("PUT", "/v2/service_instances/instance-id?dry_run=true", provisionBody)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring("QuotaExceeded"))
+			Expect(validator.validated).To(BeEmpty())
+		})
+
+		It("validates updates and binds", func() {
+			response := tester.Do("PATCH", "/v2/service_instances/existing?dry_run=true", `{"service_id":"service-id","plan_id":"large","previous_values":{"plan_id":"small"}}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"dry_run":true}`))
+
+			response = tester.Do("PUT", "/v2/service_instances/existing/service_bindings/binding-id?dry_run=true", bindBody)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			Expect(validator.validated).To(Equal([]string{"update existing", "bind existing binding-id"}))
+			Expect(validator.UpdateCallCount()).To(BeZero())
+			Expect(validator.BindCallCount()).To(BeZero())
+		})
+
+		It("neither meters dry runs nor counts their failures in the circuit breaker", func() {
+			records := make(chan brokerapi.UsageRecord, 10)
+			brokerAPI = brokerapi.NewWithOptions(validator, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithCircuitBreaker(1, time.Minute),
+				brokerapi.WithMeter(meterFunc(func(ctx context.Context, record brokerapi.UsageRecord) error {
+					records <- record
+					return nil
+				})),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+
+			validator.err = errors.New("backend down")
+			Expect(tester.Do("PUT", "/v2/service_instances/instance-id?dry_run=true", provisionBody).Code).To(Equal(http.StatusInternalServerError))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+
+			validator.err = nil
+			Expect(tester.Do("PUT", "/v2/service_instances/instance-id?dry_run=true", strings.Replace(provisionBody, "small", "large", 1)).Code).To(Equal(http.StatusOK))
+			Consistently(records, 50*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("responds with 501 when the broker does not support dry runs", func() {
+			fakeBroker := new(fakes.AutoFakeServiceBroker)
+			fakeBroker.ServicesReturns(validator.Services(context.Background()))
+			brokerAPI = brokerapi.NewWithOptions(fakeBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+
+			response := brokertest.New(brokerAPI, credentials.Username, credentials.Password).Do("PUT", "/v2/service_instances/instance-id?dry_run=true", provisionBody)
+			Expect(response.Code).To(Equal(http.StatusNotImplemented))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker does not support dry runs of this request"}`))
+			Expect(fakeBroker.ProvisionCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("Kubernetes service catalog requests", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+			k8sContext            map[string]string
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: map[string]string{"user": "u"}}, nil)
+			autoFakeServiceBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "op"}, nil)
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+
+			userInfo := base64.StdEncoding.EncodeToString([]byte(`{"username":"system:serviceaccount:catalog:controller","uid":"uid-1","groups":["system:serviceaccounts"]}`))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password).
+				WithAPIVersion("2.13").
+				WithHeader("X-Broker-API-Originating-Identity", "kubernetes "+userInfo)
+			k8sContext = map[string]string{"platform": "kubernetes", "namespace": "default", "clusterid": "cluster-1", "instance_name": "db"}
+		})
+
+		It("provisions without organization and space GUIDs", func() {
+			var identity brokerapi.OriginatingIdentity
+			autoFakeServiceBroker.ProvisionStub = func(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+				header, _ := contextkeys.OriginatingIdentity(ctx)
+				identity, _ = brokerapi.ParseOriginatingIdentity(header)
+				return brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "op"}, nil
+			}
+
+			response := tester.Provision("instance-id", map[string]interface{}{
+				"service_id": "service-id",
+				"plan_id":    "plan-id",
+				"context":    k8sContext,
+				"parameters": map[string]interface{}{},
+			}, true)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+
+			_, _, details, asyncAllowed := autoFakeServiceBroker.ProvisionArgsForCall(0)
+			Expect(asyncAllowed).To(BeTrue())
+			Expect(details.OrganizationGUID).To(BeEmpty())
+			platformContext, err := details.PlatformContext()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(platformContext.IsKubernetes()).To(BeTrue())
+			Expect(platformContext.Namespace).To(Equal("default"))
+			Expect(identity.Username).To(Equal("system:serviceaccount:catalog:controller"))
+			Expect(identity.Groups).To(ConsistOf("system:serviceaccounts"))
+		})
+
+		It("polls the last operation", func() {
+			response := tester.LastOperation("instance-id", "op")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"state":"succeeded"}`))
+		})
+
+		It("binds without an app GUID", func() {
+			response := tester.Bind("instance-id", "binding-id", map[string]interface{}{
+				"service_id":    "service-id",
+				"plan_id":       "plan-id",
+				"context":       k8sContext,
+				"bind_resource": map[string]interface{}{},
+			}, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+
+			_, _, _, details, _ := autoFakeServiceBroker.BindArgsForCall(0)
+			Expect(details.AppGUID).To(BeEmpty())
+			platformContext, err := details.PlatformContext()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(platformContext.ClusterID).To(Equal("cluster-1"))
+		})
+
+		It("deprovisions with the IDs in the query", func() {
+			response := tester.Deprovision("instance-id", "service-id", "plan-id", true)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			_, _, details, _ := autoFakeServiceBroker.DeprovisionArgsForCall(0)
+			Expect(details.ServiceID).To(Equal("service-id"))
+		})
+	})
+
+	Describe("platform detection", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+			platform              string
+		)
+
+		BeforeEach(func() {
+			platform = ""
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", InstancesRetrievable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			autoFakeServiceBroker.ProvisionStub = func(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+				platform = brokerapi.Platform(ctx)
+				return brokerapi.ProvisionedServiceSpec{}, nil
+			}
+			autoFakeServiceBroker.GetInstanceStub = func(ctx context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
+				platform = brokerapi.Platform(ctx)
+				return brokerapi.GetInstanceDetailsSpec{}, nil
+			}
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		provision := func(tester brokertest.BrokerTester, platformContext map[string]string) {
+			details := map[string]interface{}{"service_id": "service-id", "plan_id": "plan-id"}
+			if platformContext != nil {
+				details["context"] = platformContext
+			}
+			Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+		}
+
+		It("uses the platform of the context object", func() {
+			provision(tester.WithHeader("X-Broker-API-Originating-Identity", "cloudfoundry e30="), map[string]string{"platform": "kubernetes"})
+			Expect(platform).To(Equal(brokerapi.PlatformKubernetes))
+		})
+
+		It("uses the platform of the originating identity", func() {
+			tester.WithHeader("X-Broker-API-Originating-Identity", "kubernetes e30=").GetInstance("instance-id")
+			Expect(platform).To(Equal(brokerapi.PlatformKubernetes))
+		})
+
+		It("recognises Cloud Foundry by its X-Api-Info-Location header", func() {
+			tester.WithHeader("X-Api-Info-Location", "api.example.com/v2/info").GetInstance("instance-id")
+			Expect(platform).To(Equal(brokerapi.PlatformCloudFoundry))
+		})
+
+		It("classifies unknown platforms as other", func() {
+			provision(tester, map[string]string{"platform": "sapcp"})
+			Expect(platform).To(Equal(brokerapi.PlatformOther))
+
+			provision(tester, nil)
+			Expect(platform).To(Equal(brokerapi.PlatformOther))
+		})
+	})
+
+	Describe("credentials transformers", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+			bindDetails           map[string]interface{}
+		)
+
+		newTester := func(transformers ...brokerapi.CredentialsTransformer) brokertest.BrokerTester {
+			options := []brokerapi.Option{brokerapi.WithBrokerCredentials(credentials)}
+			for _, transformer := range transformers {
+				options = append(options, brokerapi.WithCredentialsTransformer(transformer))
+			}
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, options...)
+			return brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, BindingsRetrievable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			credentials := map[string]interface{}{"uri": "mysql://user:pass@db:3306/app", "tls": map[string]interface{}{"ca": "cert"}}
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: credentials}, nil)
+			autoFakeServiceBroker.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: credentials}, nil)
+			bindDetails = map[string]interface{}{"service_id": "service-id", "plan_id": "plan-id"}
+			tester = newTester(
+				credentialtransform.AddJDBCURL("uri", credentialtransform.DefaultJDBCURLKey),
+				credentialtransform.FlattenForKubernetes(),
+			)
+		})
+
+		It("transforms the credentials in order for the platform of the request", func() {
+			response := tester.WithHeader("X-Broker-API-Originating-Identity", "kubernetes e30=").Bind("instance-id", "binding-id", bindDetails, false)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Body.String()).To(MatchJSON(`{"credentials": {
+				"uri": "mysql://user:pass@db:3306/app",
+				"jdbcUrl": "jdbc:mysql://db:3306/app?password=pass\u0026user=user",
+				"tls_ca": "cert"
+			}}`))
+		})
+
+		It("transforms the credentials returned by GetBinding", func() {
+			response := tester.WithAPIVersion("2.14").GetBinding("instance-id", "binding-id")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"credentials": {
+				"uri": "mysql://user:pass@db:3306/app",
+				"jdbcUrl": "jdbc:mysql://db:3306/app?password=pass\u0026user=user",
+				"tls": {"ca": "cert"}
+			}}`))
+		})
+
+		It("responds with 500 when a transformer fails", func() {
+			tester = newTester(brokerapi.CredentialsTransformerFunc(func(ctx context.Context, credentials interface{}) (interface{}, error) {
+				return nil, errors.New("boom")
+			}))
+			response := tester.Bind("instance-id", "binding-id", bindDetails, false)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description": "the binding credentials could not be transformed"}`))
+			Expect(lastLogLine().Message).To(ContainSubstring("transform-credentials-failed"))
+		})
+	})
+
+	Describe("parameter decoders", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+			details               map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "legacy-plan"}, {ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithParameterDecoder("service-id", "legacy-plan", paramdecode.YAMLString()),
+				brokerapi.WithParameterDecoder("", "", paramdecode.RenameKeys(map[string]string{"db_size": "size"})),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+			details = map[string]interface{}{
+				"service_id": "service-id",
+				"plan_id":    "legacy-plan",
+				"parameters": "db_size: large",
+			}
+		})
+
+		It("decodes the parameters of provisions with the decoders of the plan in order", func() {
+			Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+			_, _, provisionDetails, _ := autoFakeServiceBroker.ProvisionArgsForCall(0)
+			Expect(provisionDetails.RawParameters).To(MatchJSON(`{"size": "large"}`))
+		})
+
+		It("decodes the parameters of updates with the plan they were provisioned with", func() {
+			details["plan_id"] = ""
+			details["previous_values"] = map[string]string{"plan_id": "legacy-plan"}
+			Expect(tester.Update("instance-id", details, false).Code).To(Equal(http.StatusOK))
+			_, _, updateDetails, _ := autoFakeServiceBroker.UpdateArgsForCall(0)
+			Expect(updateDetails.RawParameters).To(MatchJSON(`{"size": "large"}`))
+		})
+
+		It("decodes the parameters of bindings", func() {
+			details["parameters"] = map[string]string{"db_size": "small"}
+			details["plan_id"] = "plan-id"
+			Expect(tester.Bind("instance-id", "binding-id", details, false).Code).To(Equal(http.StatusCreated))
+			_, _, _, bindDetails, _ := autoFakeServiceBroker.BindArgsForCall(0)
+			Expect(bindDetails.RawParameters).To(MatchJSON(`{"size": "small"}`))
+		})
+
+		It("only uses the decoders of the plan of the request", func() {
+			details["plan_id"] = "plan-id"
+			response := tester.Provision("instance-id", details, false)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(MatchJSON(`{"description": "the parameters could not be decoded: parameters must be an object"}`))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(0))
+			Expect(lastLogLine().Message).To(ContainSubstring("decode-parameters-failed"))
+		})
+
+		Context("with versioned parameter schemas", func() {
+			BeforeEach(func() {
+				versions := paramversion.New().Add(2, func(parameters map[string]interface{}) error {
+					parameters["replicas"] = parameters["nodes"]
+					delete(parameters, "nodes")
+					return nil
+				})
+				brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithParameterDecoder("service-id", "plan-id", versions),
+				)
+				tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+				details["plan_id"] = "plan-id"
+			})
+
+			It("migrates parameters of older versions to the current one", func() {
+				details["parameters"] = map[string]interface{}{"nodes": 3}
+				Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+				_, _, provisionDetails, _ := autoFakeServiceBroker.ProvisionArgsForCall(0)
+				Expect(provisionDetails.RawParameters).To(MatchJSON(`{"replicas": 3}`))
+			})
+
+			It("rejects parameters of versions the broker does not know", func() {
+				details["parameters"] = map[string]interface{}{"schema_version": 3}
+				response := tester.Provision("instance-id", details, false)
+				Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(response.Body.String()).To(MatchJSON(`{"description": "the parameters could not be decoded: schema_version 3 is newer than the latest version, 2"}`))
+			})
+		})
+	})
+
+	Describe("debug settings", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			logs                  *gbytes.Buffer
+			sink                  *lager.ReconfigurableSink
+			dumper                *debug_dump.Dumper
+			adminTester           brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+			logs = gbytes.NewBuffer()
+			sink = lager.NewReconfigurableSink(lager.NewWriterSink(logs, lager.DEBUG), lager.ERROR)
+			logger := lager.NewLogger("broker")
+			logger.RegisterSink(sink)
+			dumper = debug_dump.New(logger)
+
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, logger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				brokerapi.WithReconfigurableLogLevel(sink),
+				brokerapi.WithDebugToggle("dump_requests", dumper),
+				brokerapi.WithMiddleware(dumper.Wrap),
+			)
+			adminTester = brokertest.New(brokerAPI, "admin", "admin-password")
+		})
+
+		It("shows the log level and the toggles", func() {
+			response := adminTester.Do("GET", "/admin/debug", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"log_level": "error", "toggles": {"dump_requests": false}}`))
+		})
+
+		It("changes the log level and the toggles at runtime", func() {
+			tester := brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+			tester.LastOperation("instance-id", "")
+			Expect(logs.Contents()).NotTo(ContainSubstring("request-dump"))
+			Expect(logs.Contents()).NotTo(ContainSubstring("broker.lastOperation"))
+
+			response := adminTester.Do("PUT", "/admin/debug", `{"log_level": "DEBUG", "toggles": {"dump_requests": true}}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"log_level": "debug", "toggles": {"dump_requests": true}}`))
+			Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+			Expect(dumper.Enabled()).To(BeTrue())
+			Expect(logs).To(gbytes.Say("debug-settings-changed"))
+
+			tester.LastOperation("instance-id", "")
+			Expect(logs).To(gbytes.Say("broker.lastOperation"))
+			Expect(logs).To(gbytes.Say("request-dump"))
+		})
+
+		It("changes nothing when a change is invalid", func() {
+			response := adminTester.Do("PUT", "/admin/debug", `{"log_level": "debug", "toggles": {"unknown": true}}`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(MatchJSON(`{"description": "unknown debug toggle \"unknown\""}`))
+			Expect(sink.GetMinLevel()).To(Equal(lager.ERROR))
+
+			response = adminTester.Do("PUT", "/admin/debug", `{"log_level": "verbose"}`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("cannot change a fixed log level", func() {
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				brokerapi.WithLogLevel(lager.ERROR),
+			)
+			adminTester = brokertest.New(brokerAPI, "admin", "admin-password")
+
+			response := adminTester.Do("PUT", "/admin/debug", `{"log_level": "debug"}`)
+			Expect(response.Code).To(Equal(http.StatusNotImplemented))
+			Expect(adminTester.Do("GET", "/admin/debug", nil).Body.String()).To(MatchJSON(`{"log_level": "error", "toggles": {}}`))
+		})
+
+		It("requires the admin credentials", func() {
+			tester := brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+			Expect(tester.Do("GET", "/admin/debug", nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("anonymous routes", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		newTester := func(opts ...brokerapi.Option) {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id"}}, nil)
+			opts = append(opts, brokerapi.WithBrokerCredentials(credentials))
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, opts...)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password).WithoutAuth()
+		}
+
+		It("serves the catalog without authentication when it is public", func() {
+			newTester(brokerapi.WithAnonymousCatalog())
+
+			response := tester.Catalog()
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(ContainSubstring(`"id":"service-id"`))
+			Expect(tester.GetInstance("instance-id").Code).To(Equal(http.StatusUnauthorized))
+			Expect(tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=plan-id", nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("leaves the dashboard client secrets out of the public catalog", func() {
+			newTester(brokerapi.WithAnonymousCatalog())
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{
+				ID:              "service-id",
+				DashboardClient: &brokerapi.ServiceDashboardClient{ID: "client-id", Secret: "client-secret", RedirectURI: "https://dashboard.example.com"},
+			}}, nil)
+
+			response := tester.Catalog()
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(ContainSubstring(`"id":"client-id"`))
+			Expect(response.Body.String()).NotTo(ContainSubstring("client-secret"))
+
+			authenticated := brokertest.New(brokerAPI, credentials.Username, credentials.Password).Catalog()
+			Expect(authenticated.Body.String()).To(ContainSubstring(`"secret":"client-secret"`))
+			Expect(brokertest.New(brokerAPI, "wrong", "wrong").Catalog().Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("authenticates the catalog by default", func() {
+			newTester()
+
+			Expect(tester.Catalog().Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("serves the health endpoint without authentication or calling the broker", func() {
+			newTester(brokerapi.WithHealthEndpoint())
+
+			response := tester.Do("GET", "/health", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"status":"ok"}`))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusUnauthorized))
+			Expect(autoFakeServiceBroker.ServicesCallCount()).To(BeZero())
+		})
+
+		It("does not serve the health endpoint unless enabled", func() {
+			newTester()
+
+			Expect(tester.Do("GET", "/health", nil).Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("dashboard", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			middlewarePaths       []string
+		)
+
+		newBrokerAPI := func(opts ...brokerapi.Option) {
+			opts = append([]brokerapi.Option{
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithMiddleware(func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						middlewarePaths = append(middlewarePaths, req.URL.Path)
+						next.ServeHTTP(w, req)
+					})
+				}),
+			}, opts...)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, opts...)
+		}
+
+		dashboardHandler := http.StripPrefix("/dashboard", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if _, _, ok := req.BasicAuth(); ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("dashboard " + req.URL.Path))
+		}))
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			middlewarePaths = nil
+			newBrokerAPI(brokerapi.WithDashboard("/dashboard", dashboardHandler))
+		})
+
+		It("serves the dashboard without the broker authentication", func() {
+			response := brokertest.New(brokerAPI, "", "").WithoutAuth().Do("GET", "/dashboard/instances/instance-id", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal("dashboard /instances/instance-id"))
+
+			response = brokertest.New(brokerAPI, "", "").WithoutAuth().Do("GET", "/dashboard", nil)
+			Expect(response.Body.String()).To(Equal("dashboard "))
+		})
+
+		It("runs the broker middlewares for dashboard requests", func() {
+			brokertest.New(brokerAPI, "", "").WithoutAuth().Do("GET", "/dashboard/", nil)
+			Expect(middlewarePaths).To(Equal([]string{"/dashboard/"}))
+		})
+
+		It("keeps the broker API authenticated", func() {
+			response := brokertest.New(brokerAPI, "", "").WithoutAuth().Catalog()
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			Expect(brokertest.New(brokerAPI, credentials.Username, credentials.Password).Catalog().Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not serve paths which only start like the prefix", func() {
+			response := brokertest.New(brokerAPI, "", "").WithoutAuth().Do("GET", "/dashboards", nil)
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("panics when the prefix overlaps the broker API", func() {
+			Expect(func() { newBrokerAPI(brokerapi.WithDashboard("/v2/dashboard", dashboardHandler)) }).To(Panic())
+			Expect(func() { newBrokerAPI(brokerapi.WithDashboard("dashboard", dashboardHandler)) }).To(Panic())
+		})
+	})
+
+	Describe("path prefix", func() {
+		var (
+			lister          *instanceListingBroker
+			externalPaths   []string
+			middlewarePaths []string
+		)
+
+		BeforeEach(func() {
+			lister = &instanceListingBroker{AutoFakeServiceBroker: new(fakes.AutoFakeServiceBroker)}
+			externalPaths, middlewarePaths = nil, nil
+			brokerAPI = brokerapi.NewWithOptions(lister, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				brokerapi.WithPathPrefix("/broker-name/"),
+				brokerapi.WithMiddleware(func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						externalPaths = append(externalPaths, contextkeys.ExternalPath(req))
+						middlewarePaths = append(middlewarePaths, req.URL.Path)
+						next.ServeHTTP(w, req)
+					})
+				}),
+			)
+		})
+
+		It("serves the broker API under the prefix", func() {
+			response := brokertest.New(brokerAPI, credentials.Username, credentials.Password).Do("GET", "/broker-name/v2/catalog", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(middlewarePaths).To(Equal([]string{"/v2/catalog"}))
+			Expect(externalPaths).To(Equal([]string{"/broker-name/v2/catalog"}))
+		})
+
+		It("does not serve requests without the prefix", func() {
+			tester := brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+			Expect(tester.Catalog().Code).To(Equal(http.StatusNotFound))
+			Expect(tester.Do("GET", "/broker-names/v2/catalog", nil).Code).To(Equal(http.StatusNotFound))
+			Expect(middlewarePaths).To(BeEmpty())
+		})
+
+		It("keeps the prefix in generated links", func() {
+			lister.list = brokerapi.InstanceList{NextCursor: "instance-1"}
+
+			response := brokertest.New(brokerAPI, "admin", "admin-password").Do("GET", "/broker-name/admin/service_instances?limit=1", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Link")).To(Equal(`</broker-name/admin/service_instances?cursor=instance-1&limit=1>; rel="next"`))
+		})
+
+		It("panics when the prefix does not start with a slash", func() {
+			Expect(func() {
+				brokerapi.NewWithOptions(lister, brokerLogger, brokerapi.WithPathPrefix("broker-name"))
+			}).To(Panic())
+		})
+	})
+
+	Describe("admin API", func() {
+		var (
+			lister      *instanceListingBroker
+			tester      brokertest.BrokerTester
+			adminTester brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			lister = &instanceListingBroker{AutoFakeServiceBroker: new(fakes.AutoFakeServiceBroker)}
+			brokerAPI = brokerapi.NewWithOptions(lister, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+			adminTester = brokertest.New(brokerAPI, "admin", "admin-password")
+		})
+
+		It("lists the instances of the broker", func() {
+			lister.list = brokerapi.InstanceList{
+				Instances: []brokerapi.InstanceSummary{
+					{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "plan-id", DashboardURL: "https://dashboard.example.com/1"},
+				},
+				NextCursor: "instance-1",
+			}
+
+			response := adminTester.Do("GET", "/admin/service_instances?limit=1&cursor=instance-0", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{
+				"service_instances": [
+					{"instance_id": "instance-1", "service_id": "service-id", "plan_id": "plan-id", "dashboard_url": "https://dashboard.example.com/1"}
+				],
+				"next_cursor": "instance-1"
+			}`))
+			Expect(lister.request).To(Equal(brokerapi.ListInstancesRequest{Limit: 1, Cursor: "instance-0"}))
+			Expect(response.Header().Get("Link")).To(Equal(`</admin/service_instances?cursor=instance-1&limit=1>; rel="next"`))
+		})
+
+		It("responds with an empty list when there are no instances", func() {
+			response := adminTester.Do("GET", "/admin/service_instances", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"service_instances":[]}`))
+			Expect(response.Header().Get("Link")).To(BeEmpty())
+		})
+
+		It("defaults and caps the page size", func() {
+			adminTester.Do("GET", "/admin/service_instances", nil)
+			Expect(lister.request.Limit).To(Equal(100))
+
+			adminTester.Do("GET", "/admin/service_instances?limit=5000", nil)
+			Expect(lister.request.Limit).To(Equal(1000))
+		})
+
+		It("rejects an invalid limit", func() {
+			response := adminTester.Do("GET", "/admin/service_instances?limit=0", nil)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"limit must be a positive integer"}`))
+		})
+
+		It("responds with the error of the broker", func() {
+			lister.err = errors.New("database unavailable")
+
+			response := adminTester.Do("GET", "/admin/service_instances", nil)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"database unavailable"}`))
+		})
+
+		It("requires the admin credentials", func() {
+			Expect(tester.Do("GET", "/admin/service_instances", nil).Code).To(Equal(http.StatusUnauthorized))
+			Expect(adminTester.WithoutAuth().Do("GET", "/admin/service_instances", nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("does not accept the admin credentials on the broker endpoints", func() {
+			Expect(adminTester.Catalog().Code).To(Equal(http.StatusUnauthorized))
+			Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+		})
+
+		It("responds with 501 when the broker cannot list instances", func() {
+			brokerAPI = brokerapi.NewWithOptions(new(fakes.AutoFakeServiceBroker), brokerLogger,
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+			)
+
+			response := brokertest.New(brokerAPI, "admin", "admin-password").Do("GET", "/admin/service_instances", nil)
+			Expect(response.Code).To(Equal(http.StatusNotImplemented))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker does not support listing service instances"}`))
+		})
+
+		Describe("orphan sweep", func() {
+			var remover *orphanRemovingBroker
+
+			BeforeEach(func() {
+				remover = &orphanRemovingBroker{instanceListingBroker: lister, errs: map[string]error{}}
+				brokerAPI = brokerapi.NewWithOptions(remover, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				)
+				adminTester = brokertest.New(brokerAPI, "admin", "admin-password")
+
+				lister.list = brokerapi.InstanceList{Instances: []brokerapi.InstanceSummary{
+					{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "plan-id"},
+					{InstanceID: "instance-2", ServiceID: "service-id", PlanID: "plan-id"},
+					{InstanceID: "instance-3", ServiceID: "service-id", PlanID: "plan-id"},
+				}}
+			})
+
+			It("lists the instances the platform does not know about", func() {
+				response := adminTester.Do("POST", "/admin/orphan_sweep", `{"platform_instance_ids":["instance-2"]}`)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{
+					"orphaned_instances": [
+						{"instance_id": "instance-1", "service_id": "service-id", "plan_id": "plan-id"},
+						{"instance_id": "instance-3", "service_id": "service-id", "plan_id": "plan-id"}
+					]
+				}`))
+				Expect(remover.removed).To(BeEmpty())
+			})
+
+			It("deletes the orphans when asked to, reporting each failure", func() {
+				remover.errs["instance-3"] = errors.New("volume is busy")
+
+				response := adminTester.Do("POST", "/admin/orphan_sweep", `{"platform_instance_ids":["instance-2"],"delete":true}`)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{
+					"orphaned_instances": [
+						{"instance_id": "instance-1", "service_id": "service-id", "plan_id": "plan-id"},
+						{"instance_id": "instance-3", "service_id": "service-id", "plan_id": "plan-id"}
+					],
+					"deleted_instance_ids": ["instance-1"],
+					"failed_deletions": [{"instance_id": "instance-3", "description": "volume is busy"}]
+				}`))
+				Expect(remover.removed).To(Equal([]string{"instance-1"}))
+			})
+
+			It("requires the platform instance IDs", func() {
+				response := adminTester.Do("POST", "/admin/orphan_sweep", `{"delete":true}`)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"platform_instance_ids missing"}`))
+				Expect(remover.removed).To(BeEmpty())
+			})
+
+			It("responds with 501 when asked to delete by a broker which cannot", func() {
+				brokerAPI = brokerapi.NewWithOptions(lister, brokerLogger,
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				)
+
+				response := brokertest.New(brokerAPI, "admin", "admin-password").Do("POST", "/admin/orphan_sweep", `{"platform_instance_ids":[],"delete":true}`)
+				Expect(response.Code).To(Equal(http.StatusNotImplemented))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker does not support deleting orphaned service instances"}`))
+			})
+		})
+
+		Describe("upgrade candidates", func() {
+			BeforeEach(func() {
+				lister.ServicesReturns([]brokerapi.Service{{
+					ID: "service-id",
+					Plans: []brokerapi.ServicePlan{
+						{ID: "plan-id", MaintenanceInfo: &brokerapi.MaintenanceInfo{Private: "v2"}},
+						{ID: "other-plan-id", MaintenanceInfo: &brokerapi.MaintenanceInfo{Private: "v2"}},
+					},
+				}}, nil)
+				lister.list = brokerapi.InstanceList{Instances: []brokerapi.InstanceSummary{
+					{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "plan-id", MaintenanceInfo: &brokerapi.MaintenanceInfo{Private: "v1"}},
+					{InstanceID: "instance-2", ServiceID: "service-id", PlanID: "plan-id", MaintenanceInfo: &brokerapi.MaintenanceInfo{Private: "v2"}},
+					{InstanceID: "instance-3", ServiceID: "service-id", PlanID: "other-plan-id"},
+				}}
+			})
+
+			It("lists the instances behind the maintenance_info of their plan", func() {
+				response := adminTester.Do("GET", "/admin/upgrade_candidates", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{
+					"upgrade_candidates": [
+						{"instance_id": "instance-1", "service_id": "service-id", "plan_id": "plan-id", "maintenance_info": {"private": "v1"}, "target_maintenance_info": {"private": "v2"}},
+						{"instance_id": "instance-3", "service_id": "service-id", "plan_id": "other-plan-id", "target_maintenance_info": {"private": "v2"}}
+					]
+				}`))
+			})
+
+			It("lists the candidates a page at a time", func() {
+				response := adminTester.Do("GET", "/admin/upgrade_candidates?limit=1", nil)
+				Expect(response.Body.String()).To(ContainSubstring(`"instance_id":"instance-1"`))
+				Expect(response.Body.String()).To(ContainSubstring(`"next_cursor":"instance-1"`))
+				Expect(response.Header().Get("Link")).To(Equal(`</admin/upgrade_candidates?cursor=instance-1&limit=1>; rel="next"`))
+
+				response = adminTester.Do("GET", "/admin/upgrade_candidates?limit=1&cursor=instance-1", nil)
+				Expect(response.Body.String()).To(ContainSubstring(`"instance_id":"instance-3"`))
+				Expect(response.Body.String()).NotTo(ContainSubstring("next_cursor"))
+			})
+
+			It("narrows the list to a plan", func() {
+				response := adminTester.Do("GET", "/admin/upgrade_candidates?service_id=service-id&plan_id=other-plan-id", nil)
+				Expect(response.Body.String()).NotTo(ContainSubstring("instance-1"))
+				Expect(response.Body.String()).To(ContainSubstring("instance-3"))
+			})
+
+			It("responds with an empty list when every instance is up to date", func() {
+				lister.list = brokerapi.InstanceList{}
+
+				response := adminTester.Do("GET", "/admin/upgrade_candidates", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{"upgrade_candidates":[]}`))
+			})
+
+			It("responds with the error of the broker", func() {
+				lister.ServicesReturns(nil, errors.New("catalog unavailable"))
+
+				response := adminTester.Do("GET", "/admin/upgrade_candidates", nil)
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"catalog unavailable"}`))
+			})
+		})
+
+		Describe("restoring instances", func() {
+			var softDeleting *softdelete.Broker
+
+			BeforeEach(func() {
+				softDeleting = softdelete.New(lister, time.Hour, brokerLogger)
+				brokerAPI = brokerapi.NewWithOptions(softDeleting, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				)
+				tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+				adminTester = brokertest.New(brokerAPI, "admin", "admin-password")
+			})
+
+			It("restores an instance whose deletion is held back", func() {
+				Expect(tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=plan-id", nil).Code).To(Equal(http.StatusOK))
+				Expect(softDeleting.Pending(context.Background())).To(HaveLen(1))
+
+				response := adminTester.Do("POST", "/admin/service_instances/instance-id/restore", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{}`))
+				Expect(softDeleting.Pending(context.Background())).To(BeEmpty())
+				Expect(lister.DeprovisionCallCount()).To(BeZero())
+			})
+
+			It("leaves instances whose deletion is held back out of the inventory", func() {
+				lister.list = brokerapi.InstanceList{Instances: []brokerapi.InstanceSummary{
+					{InstanceID: "instance-id", ServiceID: "service-id", PlanID: "plan-id"},
+					{InstanceID: "other-instance-id", ServiceID: "service-id", PlanID: "plan-id"},
+				}}
+				Expect(tester.Do("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=plan-id", nil).Code).To(Equal(http.StatusOK))
+
+				response := adminTester.Do("GET", "/admin/service_instances", nil)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).NotTo(ContainSubstring(`"instance-id"`))
+				Expect(response.Body.String()).To(ContainSubstring(`"other-instance-id"`))
+			})
+
+			It("returns 404 for instances which are not awaiting deletion", func() {
+				response := adminTester.Do("POST", "/admin/service_instances/instance-id/restore", nil)
+				Expect(response.Code).To(Equal(http.StatusNotFound))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"instance is not awaiting deletion"}`))
+			})
+
+			It("is protected by the admin authentication", func() {
+				Expect(tester.Do("POST", "/admin/service_instances/instance-id/restore", nil).Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		It("returns 501 for restores when the broker does not hold back deletions", func() {
+			response := adminTester.Do("POST", "/admin/service_instances/instance-id/restore", nil)
+			Expect(response.Code).To(Equal(http.StatusNotImplemented))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"the broker does not support restoring deleted service instances"}`))
+		})
+
+		Describe("catalog diff", func() {
+			BeforeEach(func() {
+				lister.ServicesReturns([]brokerapi.Service{{
+					ID:    "service-id",
+					Plans: []brokerapi.ServicePlan{{ID: "plan-id", Name: "small"}, {ID: "other-plan-id", Name: "large"}},
+				}}, nil)
+				lister.list = brokerapi.InstanceList{Instances: []brokerapi.InstanceSummary{
+					{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "other-plan-id"},
+				}}
+			})
+
+			It("compares the catalog of the broker with the one in the request", func() {
+				response := adminTester.Do("POST", "/admin/catalog_diff", `{"services":[{"id":"service-id","plans":[{"id":"plan-id","name":"small","description":"A small plan"},{"id":"new-plan-id","name":"medium"}]}]}`)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(MatchJSON(`{
+					"added_services": [],
+					"removed_services": [],
+					"added_plans": [{"service_id": "service-id", "plan_id": "new-plan-id", "plan_name": "medium"}],
+					"removed_plans": [{"service_id": "service-id", "plan_id": "other-plan-id", "plan_name": "large"}],
+					"changed_plans": [{"service_id": "service-id", "plan_id": "plan-id", "plan_name": "small", "changed_fields": ["description"]}],
+					"breaking_changes": [{"service_id": "service-id", "plan_id": "other-plan-id", "plan_name": "large", "instances": 1, "description": "plan \"large\" is removed while instances use it"}],
+					"instances_checked": true
+				}`))
+			})
+
+			It("does not check for breaking changes when the broker cannot list its instances", func() {
+				brokerAPI = brokerapi.NewWithOptions(lister.AutoFakeServiceBroker, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				)
+
+				response := brokertest.New(brokerAPI, "admin", "admin-password").Do("POST", "/admin/catalog_diff", `{"services":[]}`)
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(ContainSubstring(`"removed_services":["service-id"]`))
+				Expect(response.Body.String()).To(ContainSubstring(`"breaking_changes":[]`))
+				Expect(response.Body.String()).To(ContainSubstring(`"instances_checked":false`))
+			})
+
+			It("requires the services of the new catalog", func() {
+				response := adminTester.Do("POST", "/admin/catalog_diff", `{}`)
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"services missing"}`))
+			})
+
+			It("is protected by the admin authentication", func() {
+				Expect(tester.Do("POST", "/admin/catalog_diff", `{"services":[]}`).Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		It("serves admin handlers behind the admin authentication", func() {
+			metrics := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("requests " + req.URL.Path))
+			})
+			brokerAPI = brokerapi.NewWithOptions(lister, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+				brokerapi.WithAdminHandler("/debug/", metrics),
+			)
+
+			response := brokertest.New(brokerAPI, "admin", "admin-password").Do("GET", "/debug/vars", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal("requests /debug/vars"))
+			Expect(brokertest.New(brokerAPI, credentials.Username, credentials.Password).Do("GET", "/debug/vars", nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("panics when an admin handler overlaps the broker API", func() {
+			Expect(func() {
+				brokerapi.NewWithOptions(lister, brokerLogger,
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+					brokerapi.WithAdminHandler("/v2/metrics", http.NotFoundHandler()),
+				)
+			}).To(Panic())
+		})
+
+		Describe("on a separate handler", func() {
+			var handlers brokerapi.Handlers
+
+			BeforeEach(func() {
+				handlers = brokerapi.NewHandlers(lister, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+					brokerapi.WithAdminHandler("/debug", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						w.Write([]byte("metrics"))
+					})),
+					brokerapi.WithHealthEndpoint(),
+				)
+				lister.list = brokerapi.InstanceList{Instances: []brokerapi.InstanceSummary{{InstanceID: "instance-1"}}}
+			})
+
+			It("serves the admin endpoints only from the admin handler", func() {
+				adminTester := brokertest.New(handlers.Admin, "admin", "admin-password")
+				Expect(adminTester.Do("GET", "/admin/service_instances", nil).Body.String()).To(ContainSubstring("instance-1"))
+				Expect(adminTester.Do("GET", "/debug", nil).Body.String()).To(Equal("metrics"))
+				Expect(adminTester.WithoutAuth().Do("GET", "/admin/service_instances", nil).Code).To(Equal(http.StatusUnauthorized))
+
+				brokerTester := brokertest.New(handlers.Broker, "admin", "admin-password")
+				Expect(brokerTester.Do("GET", "/admin/service_instances", nil).Code).To(Equal(http.StatusNotFound))
+				Expect(brokerTester.Do("GET", "/debug", nil).Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("serves the broker API only from the broker handler", func() {
+				Expect(brokertest.New(handlers.Broker, credentials.Username, credentials.Password).Catalog().Code).To(Equal(http.StatusOK))
+				Expect(brokertest.New(handlers.Admin, credentials.Username, credentials.Password).Catalog().Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("serves the health endpoint from both", func() {
+				Expect(brokertest.New(handlers.Broker, "", "").WithoutAuth().Do("GET", "/health", nil).Code).To(Equal(http.StatusOK))
+				Expect(brokertest.New(handlers.Admin, "", "").WithoutAuth().Do("GET", "/health", nil).Code).To(Equal(http.StatusOK))
+			})
+
+			It("keeps admin failures out of the circuit breaker of the broker API", func() {
+				handlers = brokerapi.NewHandlers(lister, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithAdminAPI(auth.NewWrapper("admin", "admin-password").Wrap),
+					brokerapi.WithAdminHandler("/debug", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						w.WriteHeader(http.StatusInternalServerError)
+					})),
+					brokerapi.WithCircuitBreaker(2, time.Minute),
+				)
+
+				adminTester := brokertest.New(handlers.Admin, "admin", "admin-password")
+				for i := 0; i < 3; i++ {
+					Expect(adminTester.Do("GET", "/debug", nil).Code).To(Equal(http.StatusInternalServerError))
+				}
+				Expect(brokertest.New(handlers.Broker, credentials.Username, credentials.Password).Catalog().Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		It("is not served unless enabled", func() {
+			brokerAPI = brokerapi.NewWithOptions(lister, brokerLogger, brokerapi.WithBrokerCredentials(credentials))
+
+			response := brokertest.New(brokerAPI, credentials.Username, credentials.Password).Do("GET", "/admin/service_instances", nil)
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("minimum API version", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithMinimumAPIVersion("2.13"),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("rejects older versions with a description of the supported versions", func() {
+			response := tester.WithAPIVersion("2.12").LastOperation("instance-id", "")
+
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(response.Body.String()).To(MatchJSON(`{"description":"X-Broker-API-Version 2.12 is not supported, this broker supports versions 2.13 to 2.x"}`))
+			Expect(lastLogLine().Message).To(ContainSubstring("broker-api.lastOperation.broker-api-version-invalid"))
+			Expect(autoFakeServiceBroker.LastOperationCallCount()).To(Equal(0))
+		})
+
+		It("serves the minimum version and newer ones", func() {
+			Expect(tester.WithAPIVersion("2.13").Catalog().Code).To(Equal(http.StatusOK))
+			Expect(tester.WithAPIVersion("2.15").Catalog().Code).To(Equal(http.StatusOK))
+		})
+
+		It("panics when the minimum version is malformed", func() {
+			Expect(func() {
+				brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, brokerapi.WithMinimumAPIVersion("3"))
+			}).To(Panic())
+		})
+	})
+
+	Describe("deprecation notices", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		sunset := time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithDeprecation(brokerapi.Deprecation{
+					BeforeAPIVersion: "2.14",
+					Message:          "API versions older than 2.14 are deprecated",
+					Sunset:           sunset,
+				}),
+				brokerapi.WithDeprecation(brokerapi.Deprecation{
+					Operations: []brokerapi.Operation{brokerapi.OperationLastOperation},
+					Message:    "polling without an operation is deprecated",
+				}),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		It("announces version deprecations to older clients and logs them", func() {
+			response := tester.WithAPIVersion("2.13").Catalog()
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Deprecation")).To(Equal("true"))
+			Expect(response.Header()["Warning"]).To(Equal([]string{`299 - "API versions older than 2.14 are deprecated"`}))
+			Expect(response.Header().Get("Sunset")).To(Equal("Mon, 01 Mar 2027 00:00:00 GMT"))
+
+			Expect(lastLogLine().Message).To(Equal("broker-api.deprecation.deprecated-request"))
+			Expect(lastLogLine().Data).To(HaveKeyWithValue("operation", "catalog"))
+			Expect(lastLogLine().Data).To(HaveKeyWithValue("api-version", "2.13"))
+		})
+
+		It("announces operation deprecations on that operation only", func() {
+			response := tester.WithAPIVersion("2.14").LastOperation("instance-id", "")
+			Expect(response.Header()["Warning"]).To(Equal([]string{`299 - "polling without an operation is deprecated"`}))
+			Expect(response.Header().Get("Sunset")).To(BeEmpty())
+
+			response = tester.WithAPIVersion("2.14").Catalog()
+			Expect(response.Header().Get("Deprecation")).To(BeEmpty())
+			Expect(response.Header().Get("Warning")).To(BeEmpty())
+		})
+
+		It("sends every notice which applies", func() {
+			response := tester.WithAPIVersion("2.13").LastOperation("instance-id", "")
+			Expect(response.Header()["Warning"]).To(HaveLen(2))
+		})
+
+		It("panics when the version of a notice is malformed", func() {
+			Expect(func() {
+				brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+					brokerapi.WithDeprecation(brokerapi.Deprecation{BeforeAPIVersion: "two"}))
+			}).To(Panic())
+		})
+	})
+
+	Describe("content negotiation", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
+		)
+
+		details := map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			brokerAPI = brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
+
+		table.DescribeTable("accepts requests which allow JSON responses",
+			func(accept string) {
+				response := tester.WithHeader("Accept", accept).Catalog()
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+			},
+			table.Entry("no Accept header", ""),
+			table.Entry("application/json", "application/json"),
+			table.Entry("mixed case and parameters", "Application/JSON; charset=UTF-8"),
+			table.Entry("any type", "*/*"),
+			table.Entry("any application type", "application/*"),
+			table.Entry("a list with weights", "text/html, application/json;q=0.5"),
+		)
+
+		table.DescribeTable("responds with 406 to requests which do not allow JSON responses",
+			func(accept string) {
+				response := tester.WithHeader("Accept", accept).Catalog()
+				Expect(response.Code).To(Equal(http.StatusNotAcceptable))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"responses are only available as application/json"}`))
+				Expect(lastLogLine().Message).To(ContainSubstring("broker-api.not-acceptable"))
+				Expect(autoFakeServiceBroker.ServicesCallCount()).To(Equal(0))
+			},
+			table.Entry("another type", "text/html"),
+			table.Entry("JSON with a zero weight", "application/json;q=0, text/plain"),
+			table.Entry("JSON in another charset", "application/json; charset=iso-8859-1"),
+		)
+
+		It("accepts JSON bodies with Content-Type parameters", func() {
+			req := tester.NewRequest("PUT", "/v2/service_instances/instance-id", details)
+			req.Header.Set("Content-Type", "Application/JSON;charset=UTF-8")
+			response := httptest.NewRecorder()
+			brokerAPI.ServeHTTP(response, req)
+
+			Expect(response.Code).To(Equal(http.StatusCreated))
 		})
 
-		When("X-Broker-API-Originating-Identity is passed", func() {
-			It("Adds it to the context", func() {
-				originatingIdentity := "Originating Identity Name"
-				req.Header.Add("X-Broker-API-Originating-Identity", originatingIdentity)
+		It("responds with 415 to bodies which are not JSON", func() {
+			req := tester.NewRequest("PUT", "/v2/service_instances/instance-id", details)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			response := httptest.NewRecorder()
+			brokerAPI.ServeHTTP(response, req)
 
-				_, err := http.DefaultClient.Do(req)
-				Expect(err).NotTo(HaveOccurred())
+			Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(Equal(0))
+		})
 
-				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
-				ctx := fakeServiceBroker.ServicesArgsForCall(0)
-				Expect(ctx.Value("originatingIdentity")).To(Equal(originatingIdentity))
+		It("responds with 415 to JSON in another charset", func() {
+			req := tester.NewRequest("PUT", "/v2/service_instances/instance-id", details)
+			req.Header.Set("Content-Type", "application/json; charset=utf-16")
+			response := httptest.NewRecorder()
+			brokerAPI.ServeHTTP(response, req)
 
-			})
+			Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
 		})
-		When("X-Broker-API-Originating-Identity is not passed", func() {
-			It("Adds empty originatingIdentity to the context", func() {
-				_, err := http.DefaultClient.Do(req)
-				Expect(err).NotTo(HaveOccurred())
 
-				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
-				ctx := fakeServiceBroker.ServicesArgsForCall(0)
-				Expect(ctx.Value("originatingIdentity")).To(Equal(""))
-			})
+		It("ignores the content type of requests without a body", func() {
+			autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{}, nil)
+			req := tester.NewRequest("DELETE", "/v2/service_instances/instance-id?service_id=service-id&plan_id=plan-id", nil)
+			req.Header.Set("Content-Type", "text/plain")
+			response := httptest.NewRecorder()
+			brokerAPI.ServeHTTP(response, req)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(autoFakeServiceBroker.DeprovisionCallCount()).To(Equal(1))
 		})
 	})
 
-	Describe("XRegionHeader", func() {
-
+	Describe("log level", func() {
 		var (
-			fakeServiceBroker *fakes.AutoFakeServiceBroker
-			req               *http.Request
-			testServer        *httptest.Server
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			tester                brokertest.BrokerTester
 		)
 
 		BeforeEach(func() {
-			fakeServiceBroker = new(fakes.AutoFakeServiceBroker)
-			brokerAPI = brokerapi.New(fakeServiceBroker, brokerLogger, credentials)
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithLogLevel(lager.ERROR),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
+		})
 
-			testServer = httptest.NewServer(brokerAPI)
-			var err error
-			req, err = http.NewRequest("GET", testServer.URL+"/v2/catalog", nil)
-			Expect(err).NotTo(HaveOccurred())
-			req.Header.Add("X-Broker-API-Version", "2.14")
-			req.SetBasicAuth(credentials.Username, credentials.Password)
+		It("logs nothing for successful last operation polls", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+			Expect(tester.LastOperation("instance-id", "").Code).To(Equal(http.StatusOK))
+			Expect(brokerLogger.Logs()).To(BeEmpty())
 		})
 
-		AfterEach(func() {
-			testServer.Close()
+		It("logs successful polls at info", func() {
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithLogLevel(lager.INFO),
+			)
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+
+			Expect(brokertest.New(brokerAPI, credentials.Username, credentials.Password).LastOperation("instance-id", "").Code).To(Equal(http.StatusOK))
+			Expect(brokerLogger.LogMessages()).To(ContainElement(ContainSubstring("lastOperation.starting-check-for-operation")))
+			Expect(brokerLogger.LogMessages()).To(ContainElement(ContainSubstring("lastOperation.done-check-for-operation")))
 		})
 
-		When("X-Region is passed", func() {
-			It("Adds it to the context", func() {
-				region := "India"
-				req.Header.Add("X-Bluemix-Region", region)
+		It("logs failures with the session data", func() {
+			autoFakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{}, errors.New("some error"))
 
-				_, err := http.DefaultClient.Do(req)
-				Expect(err).NotTo(HaveOccurred())
+			Expect(tester.LastOperation("instance-id", "").Code).To(Equal(http.StatusInternalServerError))
+			logs := brokerLogger.Logs()
+			Expect(logs).To(HaveLen(1))
+			Expect(logs[0].Message).To(ContainSubstring(".lastOperation.unknown-error"))
+			Expect(logs[0].Data["instance-id"]).To(Equal("instance-id"))
+		})
+	})
 
-				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
-				ctx := fakeServiceBroker.ServicesArgsForCall(0)
-				Expect(ctx.Value("X-Region")).To(Equal(region))
+	Describe("instance locking", func() {
+		var (
+			autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+			lockManager           *locks.Memory
+			tester                brokertest.BrokerTester
+		)
 
-			})
+		details := map[string]string{"service_id": "service-id", "plan_id": "plan-id", "app_guid": "app-guid"}
+
+		BeforeEach(func() {
+			autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+			autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+				{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+			}, nil)
+			autoFakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: "credentials"}, nil)
+			lockManager = locks.NewMemory()
+			brokerAPI = brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+				brokerapi.WithBrokerCredentials(credentials),
+				brokerapi.WithInstanceLocking(lockManager),
+			)
+			tester = brokertest.New(brokerAPI, credentials.Username, credentials.Password)
 		})
-		When("X-Region is passed", func() {
-			It("Adds it to the context", func() {
-				region := "India"
-				req.Header.Add("X-Region", region)
 
-				_, err := http.DefaultClient.Do(req)
-				Expect(err).NotTo(HaveOccurred())
+		It("releases the lock once the request has been served", func() {
+			Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Bind("instance-id", "binding-id", details, false).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Deprovision("instance-id", "service-id", "plan-id", false).Code).To(Equal(http.StatusOK))
+		})
 
-				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
-				ctx := fakeServiceBroker.ServicesArgsForCall(0)
-				Expect(ctx.Value("X-Region")).To(Equal(region))
+		It("rejects changes to an instance while the lock is held elsewhere", func() {
+			release, err := lockManager.TryLock(context.Background(), "instance-id")
+			Expect(err).NotTo(HaveOccurred())
+			defer release()
+
+			for _, response := range []*httptest.ResponseRecorder{
+				tester.Provision("instance-id", details, false),
+				tester.Update("instance-id", details, false),
+				tester.Deprovision("instance-id", "service-id", "plan-id", false),
+				tester.Bind("instance-id", "binding-id", details, false),
+				tester.Unbind("instance-id", "binding-id", "service-id", "plan-id", false),
+			} {
+				Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(response.Body.String()).To(MatchJSON(`{"error":"ConcurrencyError","description":"another operation is in progress for this instance"}`))
+			}
+			Expect(autoFakeServiceBroker.ProvisionCallCount()).To(BeZero())
+			Expect(autoFakeServiceBroker.UnbindCallCount()).To(BeZero())
 
-			})
+			Expect(tester.Provision("another-instance-id", details, false).Code).To(Equal(http.StatusCreated))
 		})
-		When("X-Region is not passed", func() {
-			It("Adds empty X-Region to the context", func() {
-				_, err := http.DefaultClient.Do(req)
-				Expect(err).NotTo(HaveOccurred())
 
-				Expect(fakeServiceBroker.ServicesCallCount()).To(Equal(1), "Services was not called")
-				ctx := fakeServiceBroker.ServicesArgsForCall(0)
-				Expect(ctx.Value("X-Region")).To(Equal(""))
-			})
+		It("does not lock reads", func() {
+			release, _ := lockManager.TryLock(context.Background(), "instance-id")
+			defer release()
+
+			Expect(tester.LastOperation("instance-id", "").Code).To(Equal(http.StatusOK))
 		})
 	})
 
@@ -389,7 +3087,7 @@ var _ = Describe("Service Broker API", func() {
 
 		It("returns valid catalog json", func() {
 			response := makeCatalogRequest("2.14", false)
-			Expect(response.Body).To(MatchJSON(fixture("catalog.json")))
+			Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/catalog.json"))
 		})
 
 		It("returns a 500", func() {
@@ -398,6 +3096,40 @@ var _ = Describe("Service Broker API", func() {
 			Expect(response.Body.String()).To(MatchJSON(`{ "description": "something went wrong!" }`))
 		})
 
+		It("returns an ETag which changes with the catalog", func() {
+			etag := makeCatalogRequest("2.14", false).Header().Get("ETag")
+			Expect(etag).To(MatchRegexp(`^"[0-9a-f]{32}"$`))
+			Expect(makeCatalogRequest("2.14", false).Header().Get("ETag")).To(Equal(etag))
+
+			brokerAPI = brokerapi.New(&fakes.FakeServiceBroker{
+				ServiceID: "another-service-id",
+				PlanID:    "another-plan-id",
+			}, brokerLogger, credentials)
+			Expect(makeCatalogRequest("2.14", false).Header().Get("ETag")).NotTo(Equal(etag))
+		})
+
+		Context("HEAD requests", func() {
+			makeCatalogHeadRequest := func() *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				request, _ := http.NewRequest(http.MethodHead, "/v2/catalog", nil)
+				request.Header.Add("X-Broker-API-Version", "2.14")
+				request.SetBasicAuth(credentials.Username, credentials.Password)
+				brokerAPI.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			It("returns the headers of the catalog response without a body", func() {
+				getResponse := makeCatalogRequest("2.14", false)
+				headResponse := makeCatalogHeadRequest()
+
+				Expect(headResponse.Code).To(Equal(http.StatusOK))
+				Expect(headResponse.Body.Len()).To(BeZero())
+				Expect(headResponse.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				Expect(headResponse.Header().Get("Content-Length")).To(Equal(strconv.Itoa(getResponse.Body.Len())))
+				Expect(headResponse.Header().Get("ETag")).To(Equal(getResponse.Header().Get("ETag")))
+			})
+		})
+
 		Context("the request is malformed", func() {
 			It("missing header X-Broker-API-Version", func() {
 				response := makeCatalogRequest("", false)
@@ -456,7 +3188,7 @@ var _ = Describe("Service Broker API", func() {
 			var provisionDetails map[string]interface{}
 
 			BeforeEach(func() {
-				instanceID = uniqueInstanceID()
+				instanceID = brokertest.UniqueInstanceID()
 				provisionDetails = map[string]interface{}{
 					"service_id":        fakeServiceBroker.ServiceID,
 					"plan_id":           "plan-id",
@@ -496,9 +3228,13 @@ var _ = Describe("Service Broker API", func() {
 				makeInstanceProvisioningRequest(instanceID, provisionDetails, "")
 				Expect(fakeServiceBroker.ProvisionedInstanceIDs).To(ContainElement(instanceID))
 				fakeServiceBroker.DashboardURL = "https://example.com/dashboard/some-instance"
+				brokerAPI = brokerapi.NewWithOptions(fakeServiceBroker, brokerLogger,
+					brokerapi.WithBrokerCredentials(credentials),
+					brokerapi.WithParameterRetrieval(),
+				)
 				resp := makeGetInstanceRequest(instanceID)
 				Expect(fakeServiceBroker.GetInstanceIDs).To(ContainElement(instanceID))
-				Expect(resp.Body).To(MatchJSON(fixture("get_instance.json")))
+				Expect(resp.Body).To(brokertest.MatchJSONFixture("fixtures/get_instance.json"))
 			})
 
 			Context("when the broker returns some operation data", func() {
@@ -510,7 +3246,7 @@ var _ = Describe("Service Broker API", func() {
 						PlanID:                fakeServiceBroker.PlanID,
 					}
 					fakeAsyncServiceBroker := &fakes.FakeAsyncServiceBroker{
-						FakeServiceBroker:    *fakeServiceBroker,
+						FakeServiceBroker:    fakeServiceBroker,
 						ShouldProvisionAsync: true,
 					}
 					brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
@@ -518,7 +3254,7 @@ var _ = Describe("Service Broker API", func() {
 
 				It("returns the operation data to the cloud controller", func() {
 					resp := makeInstanceProvisioningRequest(instanceID, provisionDetails, "")
-					Expect(resp.Body).To(MatchJSON(fixture("operation_data_response.json")))
+					Expect(resp.Body).To(brokertest.MatchJSONFixture("fixtures/operation_data_response.json"))
 				})
 			})
 
@@ -581,7 +3317,7 @@ var _ = Describe("Service Broker API", func() {
 
 				It("returns empty json", func() {
 					response := makeInstanceProvisioningRequest(instanceID, provisionDetails, "")
-					Expect(response.Body).To(MatchJSON(fixture("provisioning.json")))
+					Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/provisioning.json"))
 				})
 
 				Context("when the broker returns a dashboard URL", func() {
@@ -591,14 +3327,14 @@ var _ = Describe("Service Broker API", func() {
 
 					It("returns json with dashboard URL", func() {
 						response := makeInstanceProvisioningRequest(instanceID, provisionDetails, "")
-						Expect(response.Body).To(MatchJSON(fixture("provisioning_with_dashboard.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/provisioning_with_dashboard.json"))
 					})
 				})
 
 				Context("when the instance limit has been reached", func() {
 					BeforeEach(func() {
 						for i := 0; i < fakeServiceBroker.InstanceLimit; i++ {
-							makeInstanceProvisioningRequest(uniqueInstanceID(), provisionDetails, "")
+							makeInstanceProvisioningRequest(brokertest.UniqueInstanceID(), provisionDetails, "")
 						}
 					})
 
@@ -609,7 +3345,7 @@ var _ = Describe("Service Broker API", func() {
 
 					It("returns json with a description field and a useful error message", func() {
 						response := makeInstanceProvisioningRequest(instanceID, provisionDetails, "")
-						Expect(response.Body).To(MatchJSON(fixture("instance_limit_error.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/instance_limit_error.json"))
 					})
 
 					It("logs an appropriate error", func() {
@@ -712,9 +3448,14 @@ var _ = Describe("Service Broker API", func() {
 						return response
 					}
 
-					It("returns a 422 bad request", func() {
+					It("returns a 400 naming the offset of the syntax error", func() {
 						response := makeBadInstanceProvisioningRequest(instanceID)
-						Expect(response.StatusCode).Should(Equal(http.StatusUnprocessableEntity))
+						Expect(response.StatusCode).Should(Equal(http.StatusBadRequest))
+						Expect(response.Body).To(MatchJSON(`{
+							"error": "MalformedJSON",
+							"description": "the request body is not valid JSON: invalid character '{' looking for beginning of object key string at offset 2",
+							"offset": 2
+						}`))
 					})
 
 					It("logs a message", func() {
@@ -775,7 +3516,7 @@ var _ = Describe("Service Broker API", func() {
 								PlanID:        fakeServiceBroker.PlanID,
 							}
 							fakeAsyncServiceBroker := &fakes.FakeAsyncServiceBroker{
-								FakeServiceBroker:    *fakeServiceBroker,
+								FakeServiceBroker:    fakeServiceBroker,
 								ShouldProvisionAsync: true,
 							}
 							brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
@@ -785,6 +3526,19 @@ var _ = Describe("Service Broker API", func() {
 							response := makeInstanceProvisioningRequestWithAcceptsIncomplete(instanceID, provisionDetails, true)
 							Expect(response.StatusCode).To(Equal(http.StatusAccepted))
 						})
+
+						Context("when the broker already knows the dashboard URL", func() {
+							BeforeEach(func() {
+								fakeServiceBroker.DashboardURL = "some-dashboard-url"
+								fakeServiceBroker.OperationDataToReturn = "some-operation-data"
+							})
+
+							It("returns the dashboard URL and operation with the 202", func() {
+								response := makeInstanceProvisioningRequestWithAcceptsIncomplete(instanceID, provisionDetails, true)
+								Expect(response.StatusCode).To(Equal(http.StatusAccepted))
+								Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/async_provisioning_with_dashboard.json"))
+							})
+						})
 					})
 
 					Context("when the broker chooses to provision synchronously", func() {
@@ -795,7 +3549,7 @@ var _ = Describe("Service Broker API", func() {
 								PlanID:        fakeServiceBroker.PlanID,
 							}
 							fakeAsyncServiceBroker := &fakes.FakeAsyncServiceBroker{
-								FakeServiceBroker:    *fakeServiceBroker,
+								FakeServiceBroker:    fakeServiceBroker,
 								ShouldProvisionAsync: false,
 							}
 							brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
@@ -822,7 +3576,7 @@ var _ = Describe("Service Broker API", func() {
 								PlanID:        fakeServiceBroker.PlanID,
 							}
 							fakeAsyncServiceBroker := &fakes.FakeAsyncOnlyServiceBroker{
-								FakeServiceBroker: *fakeServiceBroker,
+								FakeServiceBroker: fakeServiceBroker,
 							}
 							brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 						})
@@ -831,7 +3585,7 @@ var _ = Describe("Service Broker API", func() {
 							acceptsIncomplete := false
 							response := makeInstanceProvisioningRequestWithAcceptsIncomplete(instanceID, provisionDetails, acceptsIncomplete)
 							Expect(response.StatusCode).To(Equal(http.StatusUnprocessableEntity))
-							Expect(response.Body).To(MatchJSON(fixture("async_required.json")))
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/async_required.json"))
 						})
 					})
 				})
@@ -850,7 +3604,7 @@ var _ = Describe("Service Broker API", func() {
 								PlanID:        fakeServiceBroker.PlanID,
 							}
 							fakeAsyncServiceBroker := &fakes.FakeAsyncOnlyServiceBroker{
-								FakeServiceBroker: *fakeServiceBroker,
+								FakeServiceBroker: fakeServiceBroker,
 							}
 							brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 						})
@@ -859,7 +3613,7 @@ var _ = Describe("Service Broker API", func() {
 							acceptsIncomplete := false
 							response := makeInstanceProvisioningRequestWithAcceptsIncomplete(instanceID, provisionDetails, acceptsIncomplete)
 							Expect(response.StatusCode).To(Equal(http.StatusUnprocessableEntity))
-							Expect(response.Body).To(MatchJSON(fixture("async_required.json")))
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/async_required.json"))
 						})
 					})
 				})
@@ -946,7 +3700,9 @@ var _ = Describe("Service Broker API", func() {
 			}
 
 			BeforeEach(func() {
-				instanceID = uniqueInstanceID()
+				fakeServiceBroker.ServiceID = "some-service-id"
+				fakeServiceBroker.PlanID = "new-plan"
+				instanceID = brokertest.UniqueInstanceID()
 				details = map[string]interface{}{
 					"service_id": "some-service-id",
 					"plan_id":    "new-plan",
@@ -958,6 +3714,12 @@ var _ = Describe("Service Broker API", func() {
 						"plan_id":         "old-plan",
 						"organization_id": "org-id",
 						"space_id":        "space-id",
+						"maintenance_info": map[string]interface{}{
+							"public": map[string]string{
+								"k8s-version": "0.0.1-alpha1",
+							},
+							"private": "an older sha thing",
+						},
 					},
 					"context": map[string]interface{}{
 						"new-context": "new-context-value",
@@ -998,6 +3760,28 @@ var _ = Describe("Service Broker API", func() {
 					Expect(lastLogLine().Message).To(ContainSubstring(".update.service-id-missing"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("service_id missing"))
 				})
+
+				It("service_id not in the catalog", func() {
+					details["service_id"] = "not-in-the-catalogue"
+					response := makeInstanceUpdateRequest("instance-id", details, queryString, "2.14")
+					Expect(response.StatusCode).To(Equal(400))
+					Expect(lastLogLine().Message).To(ContainSubstring(".update.invalid-service-id"))
+					Expect(fakeServiceBroker.UpdatedInstanceIDs).NotTo(ContainElement("instance-id"))
+				})
+
+				It("plan_id not a plan of the service", func() {
+					details["plan_id"] = "not-in-the-catalogue"
+					response := makeInstanceUpdateRequest("instance-id", details, queryString, "2.14")
+					Expect(response.StatusCode).To(Equal(400))
+					Expect(response.Body).To(MatchJSON(`{"description":"plan-id not in the catalog: service \"some-service-id\" has no plan \"not-in-the-catalogue\""}`))
+					Expect(fakeServiceBroker.UpdatedInstanceIDs).NotTo(ContainElement("instance-id"))
+				})
+
+				It("no plan_id when the plan is not changing", func() {
+					delete(details, "plan_id")
+					response := makeInstanceUpdateRequest("instance-id", details, queryString, "2.14")
+					Expect(response.StatusCode).To(Equal(200))
+				})
 			})
 
 			Context("when the broker returns no error", func() {
@@ -1007,7 +3791,7 @@ var _ = Describe("Service Broker API", func() {
 					})
 
 					It("returns JSON content type", func() {
-						Expect(response.RawResponse.Header.Get("Content-Type")).To(Equal("application/json"))
+						Expect(response.RawResponse.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
 					})
 
 					It("returns empty JSON body", func() {
@@ -1023,6 +3807,10 @@ var _ = Describe("Service Broker API", func() {
 							ServiceID: "service-id",
 							OrgID:     "org-id",
 							SpaceID:   "space-id",
+							MaintenanceInfo: &brokerapi.MaintenanceInfo{
+								Public:  map[string]string{"k8s-version": "0.0.1-alpha1"},
+								Private: "an older sha thing",
+							},
 						},
 						))
 						Expect(fakeServiceBroker.UpdateDetails.RawParameters).To(Equal(json.RawMessage(`{"new-param":"new-param-value"}`)))
@@ -1080,7 +3868,7 @@ var _ = Describe("Service Broker API", func() {
 						})
 
 						It("returns the operation data to the cloud controller", func() {
-							Expect(response.Body).To(MatchJSON(fixture("operation_data_response.json")))
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/operation_data_response.json"))
 						})
 					})
 				})
@@ -1092,7 +3880,7 @@ var _ = Describe("Service Broker API", func() {
 
 					It("returns json with dashboard URL", func() {
 						response := makeInstanceUpdateRequest(instanceID, details, "", "2.14")
-						Expect(response.Body).To(MatchJSON(fixture("updating_with_dashboard.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/updating_with_dashboard.json"))
 					})
 				})
 
@@ -1153,8 +3941,42 @@ var _ = Describe("Service Broker API", func() {
 		})
 
 		Describe("deprovisioning", func() {
+			Context("when the instance is still being provisioned", func() {
+				var (
+					autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+					tester                brokertest.BrokerTester
+				)
+
+				BeforeEach(func() {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{}, brokerapi.ErrProvisionInProgress)
+				})
+
+				It("rejects the request with a ConcurrencyError", func() {
+					tester = brokertest.New(brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials), credentials.Username, credentials.Password)
+
+					response := tester.Deprovision("instance-id", "service-id", "plan-id", true)
+					Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+					Expect(response.Body.String()).To(MatchJSON(`{"error":"ConcurrencyError","description":"instance is being provisioned and cannot be deleted"}`))
+				})
+
+				It("cancels the provision when the broker supports it", func() {
+					canceller := &provisionCancellingBroker{
+						AutoFakeServiceBroker: autoFakeServiceBroker,
+						spec:                  brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: "cancel"},
+					}
+					tester = brokertest.New(brokerapi.New(canceller, brokerLogger, credentials), credentials.Username, credentials.Password)
+
+					response := tester.Deprovision("instance-id", "service-id", "plan-id", true)
+					Expect(response.Code).To(Equal(http.StatusAccepted))
+					Expect(response.Body.String()).To(MatchJSON(`{"operation":"cancel"}`))
+					Expect(canceller.cancelled).To(Equal("instance-id"))
+					Expect(canceller.asyncAllowed).To(BeTrue())
+				})
+			})
+
 			It("calls Deprovision on the service broker with the instance id", func() {
-				instanceID := uniqueInstanceID()
+				instanceID := brokertest.UniqueInstanceID()
 				makeInstanceDeprovisioningRequest(instanceID, "")
 				Expect(fakeServiceBroker.DeprovisionedInstanceIDs).To(ContainElement(instanceID))
 			})
@@ -1164,7 +3986,7 @@ var _ = Describe("Service Broker API", func() {
 				var provisionDetails map[string]interface{}
 
 				BeforeEach(func() {
-					instanceID = uniqueInstanceID()
+					instanceID = brokertest.UniqueInstanceID()
 
 					provisionDetails = map[string]interface{}{
 						"service_id":        fakeServiceBroker.ServiceID,
@@ -1204,7 +4026,7 @@ var _ = Describe("Service Broker API", func() {
 				Context("when the broker can only operate asynchronously", func() {
 					BeforeEach(func() {
 						fakeAsyncServiceBroker := &fakes.FakeAsyncOnlyServiceBroker{
-							FakeServiceBroker: *fakeServiceBroker,
+							FakeServiceBroker: fakeServiceBroker,
 						}
 						brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 					})
@@ -1214,7 +4036,7 @@ var _ = Describe("Service Broker API", func() {
 
 						It("returns a descriptive error", func() {
 							response := makeInstanceDeprovisioningRequest(instanceID, "")
-							Expect(response.Body).To(MatchJSON(fixture("async_required.json")))
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/async_required.json"))
 						})
 					})
 
@@ -1227,7 +4049,7 @@ var _ = Describe("Service Broker API", func() {
 						BeforeEach(func() {
 							fakeServiceBroker.OperationDataToReturn = "some-operation-data"
 							fakeAsyncServiceBroker := &fakes.FakeAsyncOnlyServiceBroker{
-								FakeServiceBroker: *fakeServiceBroker,
+								FakeServiceBroker: fakeServiceBroker,
 							}
 							brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 						})
@@ -1236,7 +4058,7 @@ var _ = Describe("Service Broker API", func() {
 
 						It("returns the operation data to the cloud controller", func() {
 							response := makeInstanceDeprovisioningRequest(instanceID, "accepts_incomplete=true")
-							Expect(response.Body).To(MatchJSON(fixture("operation_data_response.json")))
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/operation_data_response.json"))
 						})
 					})
 				})
@@ -1244,7 +4066,7 @@ var _ = Describe("Service Broker API", func() {
 				Context("when the broker can operate both synchronously and asynchronously", func() {
 					BeforeEach(func() {
 						fakeAsyncServiceBroker := &fakes.FakeAsyncServiceBroker{
-							FakeServiceBroker: *fakeServiceBroker,
+							FakeServiceBroker: fakeServiceBroker,
 						}
 						brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 					})
@@ -1275,17 +4097,17 @@ var _ = Describe("Service Broker API", func() {
 				var instanceID string
 
 				It("returns a 410", func() {
-					response := makeInstanceDeprovisioningRequest(uniqueInstanceID(), "")
+					response := makeInstanceDeprovisioningRequest(brokertest.UniqueInstanceID(), "")
 					Expect(response.StatusCode).To(Equal(410))
 				})
 
 				It("returns an empty JSON object", func() {
-					response := makeInstanceDeprovisioningRequest(uniqueInstanceID(), "")
+					response := makeInstanceDeprovisioningRequest(brokertest.UniqueInstanceID(), "")
 					Expect(response.Body).To(MatchJSON(`{}`))
 				})
 
 				It("logs an appropriate error", func() {
-					instanceID = uniqueInstanceID()
+					instanceID = brokertest.UniqueInstanceID()
 					makeInstanceDeprovisioningRequest(instanceID, "")
 					Expect(lastLogLine().Message).To(ContainSubstring(".deprovision.instance-missing"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("instance does not exist"))
@@ -1297,7 +4119,7 @@ var _ = Describe("Service Broker API", func() {
 				var provisionDetails map[string]interface{}
 
 				BeforeEach(func() {
-					instanceID = uniqueInstanceID()
+					instanceID = brokertest.UniqueInstanceID()
 					provisionDetails = map[string]interface{}{
 						"plan_id":           "plan-id",
 						"organization_guid": "organization-guid",
@@ -1409,6 +4231,161 @@ var _ = Describe("Service Broker API", func() {
 				Expect(lastLogLine().Data["error"]).To(ContainSubstring("failed to get instance"))
 			})
 
+			Context("when the catalog does not advertise instances_retrievable", func() {
+				var (
+					autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+					tester                brokertest.BrokerTester
+				)
+
+				BeforeEach(func() {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{ID: "retrievable-id", InstancesRetrievable: true},
+						{ID: "other-id"},
+					}, nil)
+					tester = brokertest.New(brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials), credentials.Username, credentials.Password)
+				})
+
+				It("rejects the request for a service which does not advertise it", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id?service_id=other-id", nil)
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+					Expect(response.Body.String()).To(MatchJSON(`{"description":"service does not support fetching instances"}`))
+					Expect(lastLogLine().Message).To(ContainSubstring("broker-api.getInstance.instance-not-retrievable"))
+					Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(Equal(0))
+				})
+
+				It("allows the request for a service which advertises it", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id?service_id=retrievable-id", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(Equal(1))
+				})
+
+				It("allows the request without a service_id when any service advertises it", func() {
+					response := tester.GetInstance("instance-id")
+					Expect(response.Code).To(Equal(http.StatusOK))
+				})
+
+				It("rejects the request without a service_id when no service advertises it", func() {
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "other-id"}}, nil)
+
+					response := tester.GetInstance("instance-id")
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+				})
+			})
+
+			Context("parameter retrieval", func() {
+				var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+				newTester := func(opts ...brokerapi.Option) brokertest.BrokerTester {
+					opts = append(opts, brokerapi.WithBrokerCredentials(credentials))
+					return brokertest.New(brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger, opts...), credentials.Username, credentials.Password)
+				}
+
+				BeforeEach(func() {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{ID: "service-id", InstancesRetrievable: true, BindingsRetrievable: true},
+						{ID: "secret-service-id", InstancesRetrievable: true, BindingsRetrievable: true},
+					}, nil)
+					autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{
+						ServiceID:  "service-id",
+						PlanID:     "plan-id",
+						Parameters: map[string]string{"size": "large"},
+					}, nil)
+					autoFakeServiceBroker.GetBindingReturns(brokerapi.GetBindingSpec{
+						Credentials: map[string]string{"password": "secret"},
+						Parameters:  map[string]string{"role": "reader"},
+					}, nil)
+				})
+
+				It("leaves the parameters out unless the broker opts in", func() {
+					tester := newTester()
+					Expect(tester.GetInstance("instance-id").Body.String()).To(MatchJSON(`{"service_id":"service-id","plan_id":"plan-id"}`))
+					Expect(tester.GetBinding("instance-id", "binding-id").Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+				})
+
+				It("returns the parameters when the broker opts in", func() {
+					tester := newTester(brokerapi.WithParameterRetrieval())
+					Expect(tester.GetInstance("instance-id").Body.String()).To(MatchJSON(`{"service_id":"service-id","plan_id":"plan-id","parameters":{"size":"large"}}`))
+					Expect(tester.GetBinding("instance-id", "binding-id").Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"},"parameters":{"role":"reader"}}`))
+				})
+
+				It("leaves the parameters out when the service may not advertise retrieval", func() {
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{ID: "service-id", InstancesRetrievable: true, BindingsRetrievable: true},
+						{ID: "other-id", InstancesRetrievable: true},
+					}, nil)
+					tester := newTester(brokerapi.WithParameterRetrieval())
+
+					Expect(tester.GetBinding("instance-id", "binding-id").Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+					Expect(tester.Do("GET", "/v2/service_instances/instance-id/service_bindings/binding-id?service_id=service-id", nil).Body.String()).To(ContainSubstring("parameters"))
+				})
+
+				It("never returns the parameters of omitted services", func() {
+					tester := newTester(brokerapi.WithParameterRetrieval("secret-service-id"))
+
+					Expect(tester.Do("GET", "/v2/service_instances/instance-id?service_id=secret-service-id", nil).Body.String()).NotTo(ContainSubstring("parameters"))
+					Expect(tester.Do("GET", "/v2/service_instances/instance-id/service_bindings/binding-id?service_id=secret-service-id", nil).Body.String()).NotTo(ContainSubstring("parameters"))
+					Expect(tester.GetBinding("instance-id", "binding-id").Body.String()).NotTo(ContainSubstring("parameters"))
+					Expect(tester.GetInstance("instance-id").Body.String()).To(ContainSubstring("parameters"))
+				})
+			})
+
+			Context("with the fields query parameter", func() {
+				var (
+					autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+					tester                brokertest.BrokerTester
+				)
+
+				BeforeEach(func() {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{
+						ServiceID:    "service-id",
+						PlanID:       "plan-id",
+						DashboardURL: "https://example.com/dashboard",
+						Parameters:   map[string]string{"size": "large"},
+					}, nil)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id", InstancesRetrievable: true}}, nil)
+					tester = brokertest.New(brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+						brokerapi.WithBrokerCredentials(credentials),
+						brokerapi.WithParameterRetrieval(),
+					), credentials.Username, credentials.Password)
+				})
+
+				It("merges the service tags with the tags of the instance", func() {
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id", Tags: []string{"mysql"}, InstancesRetrievable: true}}, nil)
+					autoFakeServiceBroker.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{
+						ServiceID: "service-id",
+						PlanID:    "plan-id",
+						Tags:      []string{"team-a", "mysql"},
+					}, nil)
+
+					response := tester.Do("GET", "/v2/service_instances/instance-id?fields=tags", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(response.Body.String()).To(MatchJSON(`{"tags":["mysql","team-a"]}`))
+				})
+
+				It("only returns the requested fields", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id?fields=parameters", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(response.Body.String()).To(MatchJSON(`{"parameters":{"size":"large"}}`))
+				})
+
+				It("accepts a list of fields", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id?fields=service_id,plan_id", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(response.Body.String()).To(MatchJSON(`{"service_id":"service-id","plan_id":"plan-id"}`))
+				})
+
+				It("rejects unknown fields", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id?fields=parameters,credentials", nil)
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+					Expect(response.Body.String()).To(MatchJSON(`{"description":"unknown field \"credentials\", expected one of service_id, plan_id, dashboard_url, parameters, tags"}`))
+					Expect(lastLogLine().Message).To(ContainSubstring("broker-api.getInstance.invalid-fields"))
+					Expect(autoFakeServiceBroker.GetInstanceCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("the request is malformed", func() {
 				It("missing header X-Broker-API-Version", func() {
 					apiVersion = ""
@@ -1532,12 +4509,12 @@ var _ = Describe("Service Broker API", func() {
 			)
 
 			BeforeEach(func() {
-				instanceID = uniqueInstanceID()
-				bindingID = uniqueBindingID()
+				instanceID = brokertest.UniqueInstanceID()
+				bindingID = brokertest.UniqueBindingID()
 				details = map[string]interface{}{
 					"app_guid":   "app_guid",
-					"plan_id":    "plan_id",
-					"service_id": "service_id",
+					"plan_id":    fakeServiceBroker.PlanID,
+					"service_id": fakeServiceBroker.ServiceID,
 					"parameters": map[string]interface{}{
 						"new-param": "new-param-value",
 					},
@@ -1546,7 +4523,7 @@ var _ = Describe("Service Broker API", func() {
 
 			Context("the request is malformed", func() {
 				BeforeEach(func() {
-					bindingID = uniqueBindingID()
+					bindingID = brokertest.UniqueBindingID()
 				})
 
 				It("missing header X-Broker-API-Version", func() {
@@ -1576,6 +4553,169 @@ var _ = Describe("Service Broker API", func() {
 					Expect(lastLogLine().Message).To(ContainSubstring(".bind.plan-id-missing"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("plan_id missing"))
 				})
+
+				It("service_id not in the catalog", func() {
+					details["service_id"] = "not-in-the-catalogue"
+					response := makeBindingRequest(instanceID, bindingID, details)
+					Expect(response.StatusCode).To(Equal(400))
+					Expect(response.Body).To(MatchJSON(`{"description":"service-id not in the catalog: \"not-in-the-catalogue\""}`))
+					Expect(lastLogLine().Message).To(ContainSubstring(".bind.invalid-service-id"))
+					Expect(fakeServiceBroker.BoundBindingIDs).To(BeEmpty())
+				})
+
+				It("plan_id not a plan of the service", func() {
+					details["plan_id"] = "not-in-the-catalogue"
+					response := makeBindingRequest(instanceID, bindingID, details)
+					Expect(response.StatusCode).To(Equal(400))
+					Expect(lastLogLine().Message).To(ContainSubstring(".bind.invalid-plan-id"))
+					Expect(lastLogLine().Data["error"]).To(Equal(fmt.Sprintf(`plan-id not in the catalog: service %q has no plan "not-in-the-catalogue"`, fakeServiceBroker.ServiceID)))
+					Expect(fakeServiceBroker.BoundBindingIDs).To(BeEmpty())
+				})
+			})
+
+			Context("when the plan overrides the service bindable flag", func() {
+				var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+				setBindable := func(serviceBindable bool, planBindable *bool) {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{
+							ID:       "service-id",
+							Bindable: serviceBindable,
+							Plans: []brokerapi.ServicePlan{
+								{ID: "plan-id", Bindable: planBindable},
+							},
+						},
+					}, nil)
+					brokerAPI = brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials)
+				}
+
+				bindDetails := map[string]interface{}{"service_id": "service-id", "plan_id": "plan-id"}
+
+				It("rejects the bind when the plan is not bindable", func() {
+					setBindable(true, brokerapi.BindableValue(false))
+
+					response := makeBindingRequest(instanceID, bindingID, bindDetails)
+					Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(response.Body).To(MatchJSON(`{"description":"plan is not bindable"}`))
+					Expect(lastLogLine().Message).To(ContainSubstring(".bind.plan-not-bindable"))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(0))
+				})
+
+				It("allows the bind when the plan is bindable on a non-bindable service", func() {
+					setBindable(false, brokerapi.BindableValue(true))
+
+					response := makeBindingRequest(instanceID, bindingID, bindDetails)
+					Expect(response.StatusCode).To(Equal(http.StatusCreated))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(1))
+				})
+
+				It("falls back to the service flag when the plan does not set one", func() {
+					setBindable(false, nil)
+
+					response := makeBindingRequest(instanceID, bindingID, bindDetails)
+					Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the service requires an app", func() {
+				var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+				BeforeEach(func() {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{
+							ID:       "service-id",
+							Bindable: true,
+							Requires: []brokerapi.RequiredPermission{brokerapi.PermissionApp},
+							Plans:    []brokerapi.ServicePlan{{ID: "plan-id"}},
+						},
+					}, nil)
+					brokerAPI = brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials)
+				})
+
+				It("rejects binds without an app_guid with RequiresApp", func() {
+					response := makeBindingRequest(instanceID, bindingID, map[string]interface{}{
+						"service_id":    "service-id",
+						"plan_id":       "plan-id",
+						"bind_resource": map[string]interface{}{"route": "example.com"},
+					})
+					Expect(response.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+					Expect(response.Body).To(MatchJSON(`{"error":"RequiresApp","description":"This service supports generation of credentials through binding an application only."}`))
+					Expect(lastLogLine().Message).To(ContainSubstring(".bind.requires-app"))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(0))
+				})
+
+				It("accepts the app_guid of the bind_resource or the deprecated top-level one", func() {
+					response := makeBindingRequest(instanceID, bindingID, map[string]interface{}{
+						"service_id":    "service-id",
+						"plan_id":       "plan-id",
+						"bind_resource": map[string]interface{}{"app_guid": "app-guid"},
+					})
+					Expect(response.StatusCode).To(Equal(http.StatusCreated))
+
+					response = makeBindingRequest(instanceID, bindingID, map[string]interface{}{
+						"service_id": "service-id",
+						"plan_id":    "plan-id",
+						"app_guid":   "app-guid",
+					})
+					Expect(response.StatusCode).To(Equal(http.StatusCreated))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(2))
+				})
+
+				It("does not apply to services which do not require an app", func() {
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{ID: "service-id", Bindable: true, Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}},
+					}, nil)
+
+					response := makeBindingRequest(instanceID, bindingID, map[string]interface{}{"service_id": "service-id", "plan_id": "plan-id"})
+					Expect(response.StatusCode).To(Equal(http.StatusCreated))
+				})
+			})
+
+			Context("when the bind rotates an existing binding", func() {
+				var autoFakeServiceBroker *fakes.AutoFakeServiceBroker
+
+				setRotatable := func(rotatable bool) {
+					autoFakeServiceBroker = new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{
+						{
+							ID:       "service-id",
+							Bindable: true,
+							Plans: []brokerapi.ServicePlan{
+								{ID: "plan-id", BindingRotatable: rotatable},
+							},
+						},
+					}, nil)
+					brokerAPI = brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials)
+				}
+
+				rotateDetails := map[string]interface{}{
+					"service_id":             "service-id",
+					"plan_id":                "plan-id",
+					"predecessor_binding_id": "old-binding-id",
+				}
+
+				It("rejects the bind when the plan is not binding rotatable", func() {
+					setRotatable(false)
+
+					response := makeBindingRequest(instanceID, bindingID, rotateDetails)
+					Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(response.Body).To(MatchJSON(`{"description":"plan does not support binding rotation"}`))
+					Expect(lastLogLine().Message).To(ContainSubstring(".bind.plan-not-binding-rotatable"))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(0))
+				})
+
+				It("passes the predecessor binding ID to the broker when the plan is binding rotatable", func() {
+					setRotatable(true)
+
+					response := makeBindingRequest(instanceID, bindingID, rotateDetails)
+					Expect(response.StatusCode).To(Equal(http.StatusCreated))
+					Expect(autoFakeServiceBroker.BindCallCount()).To(Equal(1))
+					_, _, _, details, _ := autoFakeServiceBroker.BindArgsForCall(0)
+					Expect(details.PredecessorBindingID).To(Equal("old-binding-id"))
+				})
 			})
 
 			Context("when the associated instance exists", func() {
@@ -1585,8 +4725,8 @@ var _ = Describe("Service Broker API", func() {
 					Expect(fakeServiceBroker.BoundBindingIDs).To(ContainElement(bindingID))
 					Expect(fakeServiceBroker.BoundBindingDetails).To(Equal(brokerapi.BindDetails{
 						AppGUID:       "app_guid",
-						PlanID:        "plan_id",
-						ServiceID:     "service_id",
+						PlanID:        fakeServiceBroker.PlanID,
+						ServiceID:     fakeServiceBroker.ServiceID,
 						RawParameters: json.RawMessage(`{"new-param":"new-param-value"}`),
 					}))
 				})
@@ -1599,12 +4739,12 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns the credentials returned by Bind", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
-					Expect(response.Body).To(MatchJSON(fixture("binding.json")))
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
+					Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding.json"))
 				})
 
 				It("returns a 201", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.StatusCode).To(Equal(201))
 				})
 
@@ -1614,8 +4754,8 @@ var _ = Describe("Service Broker API", func() {
 					})
 
 					It("responds with the syslog drain url", func() {
-						response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
-						Expect(response.Body).To(MatchJSON(fixture("binding_with_syslog.json")))
+						response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding_with_syslog.json"))
 					})
 				})
 
@@ -1625,8 +4765,8 @@ var _ = Describe("Service Broker API", func() {
 					})
 
 					It("responds with the route service url", func() {
-						response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
-						Expect(response.Body).To(MatchJSON(fixture("binding_with_route_service.json")))
+						response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding_with_route_service.json"))
 					})
 				})
 
@@ -1646,29 +4786,29 @@ var _ = Describe("Service Broker API", func() {
 
 					Context("when the broker API version is greater than 2.9", func() {
 						It("responds with a volume mount", func() {
-							response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
-							Expect(response.Body).To(MatchJSON(fixture("binding_with_volume_mounts.json")))
+							response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding_with_volume_mounts.json"))
 						})
 					})
 
 					Context("when the broker API version is 2.9", func() {
 						It("responds with an experimental volume mount", func() {
-							response := makeBindingRequestWithSpecificAPIVersion(uniqueInstanceID(), uniqueBindingID(), details, "2.9", false)
-							Expect(response.Body).To(MatchJSON(fixture("binding_with_experimental_volume_mounts.json")))
+							response := makeBindingRequestWithSpecificAPIVersion(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details, "2.9", false)
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding_with_experimental_volume_mounts.json"))
 						})
 					})
 
 					Context("when the broker API version is 2.8", func() {
 						It("responds with an experimental volume mount", func() {
-							response := makeBindingRequestWithSpecificAPIVersion(uniqueInstanceID(), uniqueBindingID(), details, "2.8", false)
-							Expect(response.Body).To(MatchJSON(fixture("binding_with_experimental_volume_mounts.json")))
+							response := makeBindingRequestWithSpecificAPIVersion(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details, "2.8", false)
+							Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding_with_experimental_volume_mounts.json"))
 						})
 					})
 				})
 
 				Context("when no bind details are being passed", func() {
 					It("returns a 422", func() {
-						response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), nil)
+						response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), nil)
 						Expect(response.StatusCode).To(Equal(http.StatusUnprocessableEntity))
 					})
 				})
@@ -1773,18 +4913,18 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns a 404", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.StatusCode).To(Equal(404))
 				})
 
 				It("returns an error JSON object", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.Body).To(MatchJSON(`{"description":"instance does not exist"}`))
 				})
 
 				It("logs an appropriate error", func() {
-					instanceID = uniqueInstanceID()
-					makeBindingRequest(instanceID, uniqueBindingID(), details)
+					instanceID = brokertest.UniqueInstanceID()
+					makeBindingRequest(instanceID, brokertest.UniqueBindingID(), details)
 					Expect(lastLogLine().Message).To(ContainSubstring(".bind.instance-missing"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("instance does not exist"))
 				})
@@ -1798,19 +4938,19 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns a 409", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.StatusCode).To(Equal(409))
 				})
 
 				It("returns an error JSON object", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.Body).To(MatchJSON(`{"description":"binding already exists"}`))
 				})
 
 				It("logs an appropriate error", func() {
-					instanceID = uniqueInstanceID()
-					makeBindingRequest(instanceID, uniqueBindingID(), details)
-					makeBindingRequest(instanceID, uniqueBindingID(), details)
+					instanceID = brokertest.UniqueInstanceID()
+					makeBindingRequest(instanceID, brokertest.UniqueBindingID(), details)
+					makeBindingRequest(instanceID, brokertest.UniqueBindingID(), details)
 
 					Expect(lastLogLine().Message).To(ContainSubstring(".bind.binding-already-exists"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("binding already exists"))
@@ -1823,13 +4963,13 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns a generic 500 error response", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.StatusCode).To(Equal(500))
 					Expect(response.Body).To(MatchJSON(`{"description":"unknown error"}`))
 				})
 
 				It("logs a detailed error message", func() {
-					makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 
 					Expect(lastLogLine().Message).To(ContainSubstring(".bind.unknown-error"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("unknown error"))
@@ -1846,17 +4986,17 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns status teapot", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.StatusCode).To(Equal(http.StatusTeapot))
 				})
 
 				It("returns json with a description field and a useful error message", func() {
-					response := makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					response := makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(response.Body).To(MatchJSON(`{"description":"I failed in unique and interesting ways"}`))
 				})
 
 				It("logs an appropriate error", func() {
-					makeBindingRequest(uniqueInstanceID(), uniqueBindingID(), details)
+					makeBindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID(), details)
 					Expect(lastLogLine().Message).To(ContainSubstring(".bind.interesting-failure"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("I failed in unique and interesting ways"))
 				})
@@ -1869,7 +5009,7 @@ var _ = Describe("Service Broker API", func() {
 
 				BeforeEach(func() {
 					fakeAsyncServiceBroker = &fakes.FakeAsyncServiceBroker{
-						FakeServiceBroker: *fakeServiceBroker,
+						FakeServiceBroker: fakeServiceBroker,
 					}
 					brokerAPI = brokerapi.New(fakeAsyncServiceBroker, brokerLogger, credentials)
 				})
@@ -1878,7 +5018,7 @@ var _ = Describe("Service Broker API", func() {
 					It("successfully returns a sync binding response", func() {
 						response := makeBindingRequestWithSpecificAPIVersion(instanceID, bindingID, details, "2.13", true)
 						Expect(response.StatusCode).To(Equal(http.StatusCreated))
-						Expect(response.Body).To(MatchJSON(fixture("binding.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding.json"))
 					})
 
 					It("fails for LastBindingOperation request", func() {
@@ -1900,7 +5040,7 @@ var _ = Describe("Service Broker API", func() {
 					It("returns an appropriate status code and operation data", func() {
 						response := makeAsyncBindingRequest(instanceID, bindingID, details)
 						Expect(response.StatusCode).To(Equal(http.StatusAccepted))
-						Expect(response.Body).To(MatchJSON(fixture("async_bind_response.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/async_bind_response.json"))
 					})
 
 					It("can be polled with lastBindingOperation", func() {
@@ -1908,13 +5048,13 @@ var _ = Describe("Service Broker API", func() {
 						fakeAsyncServiceBroker.LastOperationDescription = "some description"
 						response := makeLastBindingOperationRequestWithSpecificAPIVersion(instanceID, bindingID, "2.14")
 						Expect(response.StatusCode).To(Equal(http.StatusOK))
-						Expect(response.Body).To(MatchJSON(fixture("last_operation_succeeded.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/last_operation_succeeded.json"))
 					})
 
 					It("returns the binding for the async request on getBinding", func() {
 						response := makeGetBindingRequestWithSpecificAPIVersion(instanceID, bindingID, "2.14")
 						Expect(response.StatusCode).To(Equal(http.StatusOK))
-						Expect(response.Body).To(MatchJSON(fixture("binding.json")))
+						Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/binding.json"))
 					})
 				})
 			})
@@ -1945,7 +5085,7 @@ var _ = Describe("Service Broker API", func() {
 				var provisionDetails map[string]interface{}
 
 				BeforeEach(func() {
-					instanceID = uniqueInstanceID()
+					instanceID = brokertest.UniqueInstanceID()
 					provisionDetails = map[string]interface{}{
 						"service_id":        fakeServiceBroker.ServiceID,
 						"plan_id":           "plan-id",
@@ -1959,7 +5099,7 @@ var _ = Describe("Service Broker API", func() {
 					var bindingID string
 
 					BeforeEach(func() {
-						bindingID = uniqueBindingID()
+						bindingID = brokertest.UniqueBindingID()
 						makeBindingRequest(instanceID, bindingID, map[string]interface{}{})
 					})
 
@@ -1996,9 +5136,9 @@ var _ = Describe("Service Broker API", func() {
 					var bindingID string
 
 					BeforeEach(func() {
-						bindingID = uniqueBindingID()
+						bindingID = brokertest.UniqueBindingID()
 						makeBindingRequest(instanceID, bindingID, map[string]interface{}{
-							"service_id": "service_id", "plan_id": "plan_id",
+							"service_id": fakeServiceBroker.ServiceID, "plan_id": fakeServiceBroker.PlanID,
 						})
 					})
 
@@ -2047,18 +5187,18 @@ var _ = Describe("Service Broker API", func() {
 				var instanceID string
 
 				It("returns a 410", func() {
-					response := makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					response := makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(response.StatusCode).To(Equal(http.StatusGone))
 				})
 
 				It("returns an empty JSON object", func() {
-					response := makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					response := makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(response.Body).To(MatchJSON(`{}`))
 				})
 
 				It("logs an appropriate error", func() {
-					instanceID = uniqueInstanceID()
-					makeUnbindingRequest(instanceID, uniqueBindingID())
+					instanceID = brokertest.UniqueInstanceID()
+					makeUnbindingRequest(instanceID, brokertest.UniqueBindingID())
 
 					Expect(lastLogLine().Message).To(ContainSubstring(".unbind.instance-missing"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("instance does not exist"))
@@ -2071,13 +5211,13 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns a generic 500 error response", func() {
-					response := makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					response := makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(response.StatusCode).To(Equal(500))
 					Expect(response.Body).To(MatchJSON(`{"description":"unknown error"}`))
 				})
 
 				It("logs a detailed error message", func() {
-					makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 
 					Expect(lastLogLine().Message).To(ContainSubstring(".unbind.unknown-error"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("unknown error"))
@@ -2094,17 +5234,17 @@ var _ = Describe("Service Broker API", func() {
 				})
 
 				It("returns status teapot", func() {
-					response := makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					response := makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(response.StatusCode).To(Equal(http.StatusTeapot))
 				})
 
 				It("returns json with a description field and a useful error message", func() {
-					response := makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					response := makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(response.Body).To(MatchJSON(`{"description":"I failed in unique and interesting ways"}`))
 				})
 
 				It("logs an appropriate error", func() {
-					makeUnbindingRequest(uniqueInstanceID(), uniqueBindingID())
+					makeUnbindingRequest(brokertest.UniqueInstanceID(), brokertest.UniqueBindingID())
 					Expect(lastLogLine().Message).To(ContainSubstring(".unbind.interesting-failure"))
 					Expect(lastLogLine().Data["error"]).To(ContainSubstring("I failed in unique and interesting ways"))
 				})
@@ -2162,7 +5302,24 @@ var _ = Describe("Service Broker API", func() {
 				Expect(logs[1].Data["state"]).To(ContainSubstring(string(fakeServiceBroker.LastOperationState)))
 
 				Expect(response.StatusCode).To(Equal(200))
-				Expect(response.Body).To(MatchJSON(fixture("last_operation_succeeded.json")))
+				Expect(response.Body).To(brokertest.MatchJSONFixture("fixtures/last_operation_succeeded.json"))
+			})
+
+			It("returns the failure details of a failed operation", func() {
+				fakeServiceBroker.LastOperationState = "failed"
+				fakeServiceBroker.LastOperationDescription = "out of capacity"
+				fakeServiceBroker.LastOperationInstanceUsable = brokerapi.BoolPtr(true)
+				fakeServiceBroker.LastOperationUpdateRepeatable = brokerapi.BoolPtr(false)
+
+				response := makeLastOperationRequest("instanceID", "", "2.15")
+
+				Expect(response.StatusCode).To(Equal(200))
+				Expect(response.Body).To(MatchJSON(`{
+					"state": "failed",
+					"description": "out of capacity",
+					"instance_usable": true,
+					"update_repeatable": false
+				}`))
 			})
 
 			It("should return a 410 and log in case the instance id is not found", func() {
@@ -2241,6 +5398,42 @@ var _ = Describe("Service Broker API", func() {
 		})
 
 		Describe("get binding", func() {
+			Context("with the fields query parameter", func() {
+				var tester brokertest.BrokerTester
+
+				BeforeEach(func() {
+					autoFakeServiceBroker := new(fakes.AutoFakeServiceBroker)
+					autoFakeServiceBroker.GetBindingReturns(brokerapi.GetBindingSpec{
+						Credentials:    map[string]string{"password": "secret"},
+						SyslogDrainURL: "syslog://example.com",
+						Parameters:     map[string]string{"role": "reader"},
+					}, nil)
+					autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id", BindingsRetrievable: true}}, nil)
+					tester = brokertest.New(brokerapi.NewWithOptions(autoFakeServiceBroker, brokerLogger,
+						brokerapi.WithBrokerCredentials(credentials),
+						brokerapi.WithParameterRetrieval(),
+					), credentials.Username, credentials.Password)
+				})
+
+				It("only returns the requested fields", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id/service_bindings/binding-id?fields=credentials", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(response.Body.String()).To(MatchJSON(`{"credentials":{"password":"secret"}}`))
+				})
+
+				It("leaves out fields which the broker did not set", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id/service_bindings/binding-id?fields=parameters,route_service_url", nil)
+					Expect(response.Code).To(Equal(http.StatusOK))
+					Expect(response.Body.String()).To(MatchJSON(`{"parameters":{"role":"reader"}}`))
+				})
+
+				It("rejects unknown fields", func() {
+					response := tester.Do("GET", "/v2/service_instances/instance-id/service_bindings/binding-id?fields=dashboard_url", nil)
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+					Expect(lastLogLine().Message).To(ContainSubstring("broker-api.getBinding.invalid-fields"))
+				})
+			})
+
 			It("responds with 500 when the broker fails with an unknown error", func() {
 				fakeServiceBroker.GetBindingError = errors.New("something failed")
 
@@ -2258,6 +5451,109 @@ var _ = Describe("Service Broker API", func() {
 				Expect(lastLogLine().Message).To(ContainSubstring("broker-api.getBinding.fire"))
 				Expect(lastLogLine().Data["error"]).To(ContainSubstring("some error"))
 			})
+
+			It("rejects the request when the service does not advertise bindings_retrievable", func() {
+				autoFakeServiceBroker := new(fakes.AutoFakeServiceBroker)
+				autoFakeServiceBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id", InstancesRetrievable: true}}, nil)
+				tester := brokertest.New(brokerapi.New(autoFakeServiceBroker, brokerLogger, credentials), credentials.Username, credentials.Password)
+
+				response := tester.GetBinding("some-instance", "some-binding")
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(response.Body.String()).To(MatchJSON(`{"description":"service does not support fetching bindings"}`))
+				Expect(lastLogLine().Message).To(ContainSubstring("broker-api.getBinding.binding-not-retrievable"))
+				Expect(autoFakeServiceBroker.GetBindingCallCount()).To(Equal(0))
+			})
 		})
 	})
 })
+
+type lifecycleEventSinkFunc func(ctx context.Context, event brokerapi.LifecycleEvent) error
+
+func (f lifecycleEventSinkFunc) Emit(ctx context.Context, event brokerapi.LifecycleEvent) error {
+	return f(ctx, event)
+}
+
+type meterFunc func(ctx context.Context, record brokerapi.UsageRecord) error
+
+func (f meterFunc) RecordUsage(ctx context.Context, record brokerapi.UsageRecord) error {
+	return f(ctx, record)
+}
+
+type publisherFunc func(ctx context.Context, event brokerapi.LifecycleEvent) error
+
+func (f publisherFunc) Publish(ctx context.Context, event brokerapi.LifecycleEvent) error {
+	return f(ctx, event)
+}
+
+type errorReporterFunc func(ctx context.Context, report brokerapi.ErrorReport)
+
+func (f errorReporterFunc) ReportError(ctx context.Context, report brokerapi.ErrorReport) {
+	f(ctx, report)
+}
+
+type instanceListingBroker struct {
+	*fakes.AutoFakeServiceBroker
+
+	list    brokerapi.InstanceList
+	err     error
+	request brokerapi.ListInstancesRequest
+}
+
+func (b *instanceListingBroker) ListInstances(ctx context.Context, request brokerapi.ListInstancesRequest) (brokerapi.InstanceList, error) {
+	b.request = request
+	return b.list, b.err
+}
+
+type orphanRemovingBroker struct {
+	*instanceListingBroker
+
+	errs    map[string]error
+	removed []string
+}
+
+func (b *orphanRemovingBroker) RemoveOrphanedInstance(ctx context.Context, instanceID string) error {
+	if err := b.errs[instanceID]; err != nil {
+		return err
+	}
+	b.removed = append(b.removed, instanceID)
+	return nil
+}
+
+type validatingBroker struct {
+	*fakes.AutoFakeServiceBroker
+
+	result           brokerapi.DryRunResult
+	err              error
+	validated        []string
+	provisionDetails brokerapi.ProvisionDetails
+}
+
+func (b *validatingBroker) ValidateProvision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails) (brokerapi.DryRunResult, error) {
+	b.validated = append(b.validated, "provision "+instanceID)
+	b.provisionDetails = details
+	return b.result, b.err
+}
+
+func (b *validatingBroker) ValidateUpdate(ctx context.Context, instanceID string, details brokerapi.UpdateDetails) (brokerapi.DryRunResult, error) {
+	b.validated = append(b.validated, "update "+instanceID)
+	return b.result, b.err
+}
+
+func (b *validatingBroker) ValidateBind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails) (brokerapi.DryRunResult, error) {
+	b.validated = append(b.validated, "bind "+instanceID+" "+bindingID)
+	return b.result, b.err
+}
+
+type provisionCancellingBroker struct {
+	*fakes.AutoFakeServiceBroker
+
+	spec         brokerapi.DeprovisionServiceSpec
+	cancelled    string
+	asyncAllowed bool
+}
+
+func (b *provisionCancellingBroker) CancelProvision(ctx context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	b.cancelled = instanceID
+	b.asyncAllowed = asyncAllowed
+	return b.spec, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records an audit trail of the requests made to the broker.
+package audit
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+const writeRecordErrorKey = "write-audit-record-failed"
+
+// Record describes a single request to the broker API. DryRun is set for
+// requests made with the dry_run=true extension, which changed nothing.
+type Record struct {
+	Time                time.Time `json:"time"`
+	Operation           string    `json:"operation,omitempty"`
+	Method              string    `json:"method"`
+	Path                string    `json:"path"`
+	Status              int       `json:"status"`
+	DryRun              bool      `json:"dry_run,omitempty"`
+	InstanceID          string    `json:"instance_id,omitempty"`
+	BindingID           string    `json:"binding_id,omitempty"`
+	ClientIP            string    `json:"client_ip"`
+	User                string    `json:"user,omitempty"`
+	OriginatingIdentity string    `json:"originating_identity,omitempty"`
+	RequestIdentity     string    `json:"request_identity,omitempty"`
+	DurationSeconds     float64   `json:"duration_seconds"`
+}
+
+// Sink stores audit records.
+type Sink interface {
+	Write(record Record) error
+}
+
+// Middleware returns a middleware writing a Record to sink for every request.
+// Add it to the broker with brokerapi.WithMiddleware so that the operation and
+// IDs of the matched route are recorded. Errors from the sink are logged and
+// do not affect the response.
+func Middleware(sink Sink, logger lager.Logger) func(http.Handler) http.Handler {
+	logger = logger.Session("audit")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			recorder := statusrecorder.New(w)
+
+			next.ServeHTTP(recorder, req)
+
+			vars := mux.Vars(req)
+			record := Record{
+				Time:                start.UTC(),
+				Method:              req.Method,
+				Path:                contextkeys.ExternalPath(req),
+				Status:              recorder.Status(),
+				DryRun:              req.URL.Query().Get("dry_run") == "true",
+				InstanceID:          vars["instance_id"],
+				BindingID:           vars["binding_id"],
+				ClientIP:            client_ip.FromRequest(req),
+				OriginatingIdentity: req.Header.Get("X-Broker-API-Originating-Identity"),
+				RequestIdentity:     req.Header.Get("X-Broker-API-Request-Identity"),
+				DurationSeconds:     time.Since(start).Seconds(),
+			}
+			if route := mux.CurrentRoute(req); route != nil {
+				record.Operation = route.GetName()
+			}
+			if user, _, ok := req.BasicAuth(); ok {
+				record.User = user
+			}
+
+			if err := sink.Write(record); err != nil {
+				logger.Error(writeRecordErrorKey, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/audit"
+)
+
+type fakeSink struct {
+	records []audit.Record
+	err     error
+}
+
+func (s *fakeSink) Write(record audit.Record) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+var _ = Describe("Middleware", func() {
+	var (
+		sink   *fakeSink
+		logger *lagertest.TestLogger
+		router *mux.Router
+	)
+
+	makeRequest := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest("DELETE", "/v2/service_instances/instance-id/service_bindings/binding-id", nil)
+		request.RemoteAddr = "192.0.2.1:4000"
+		request.SetBasicAuth("admin", "secret")
+		request.Header.Set("X-Broker-API-Originating-Identity", "cloudfoundry eyJ1c2VyX2lkIjoiYWJjIn0=")
+		request.Header.Set("X-Broker-API-Request-Identity", "request-id")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		sink = &fakeSink{}
+		logger = lagertest.NewTestLogger("broker")
+		router = mux.NewRouter()
+		router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		}).Methods("DELETE").Name("unbind")
+		router.Use(audit.Middleware(sink, logger))
+	})
+
+	It("records the request", func() {
+		makeRequest()
+
+		Expect(sink.records).To(HaveLen(1))
+		record := sink.records[0]
+		Expect(record.Time).NotTo(BeZero())
+		Expect(record.Operation).To(Equal("unbind"))
+		Expect(record.Method).To(Equal("DELETE"))
+		Expect(record.Path).To(Equal("/v2/service_instances/instance-id/service_bindings/binding-id"))
+		Expect(record.Status).To(Equal(http.StatusGone))
+		Expect(record.InstanceID).To(Equal("instance-id"))
+		Expect(record.BindingID).To(Equal("binding-id"))
+		Expect(record.ClientIP).To(Equal("192.0.2.1"))
+		Expect(record.User).To(Equal("admin"))
+		Expect(record.OriginatingIdentity).To(Equal("cloudfoundry eyJ1c2VyX2lkIjoiYWJjIn0="))
+		Expect(record.RequestIdentity).To(Equal("request-id"))
+	})
+
+	It("marks dry runs", func() {
+		makeRequest()
+		request := httptest.NewRequest("DELETE", "/v2/service_instances/instance-id/service_bindings/binding-id?dry_run=true", nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		Expect(sink.records).To(HaveLen(2))
+		Expect(sink.records[0].DryRun).To(BeFalse())
+		Expect(sink.records[1].DryRun).To(BeTrue())
+	})
+
+	It("logs sink errors without failing the request", func() {
+		sink.err = errors.New("disk full")
+
+		Expect(makeRequest().Code).To(Equal(http.StatusGone))
+		Expect(logger.LogMessages()).To(ContainElement("broker.audit.write-audit-record-failed"))
+	})
+})
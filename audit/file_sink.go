@@ -0,0 +1,185 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls when a FileSink flushes records to stable storage.
+type FsyncPolicy int
+
+const (
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever FsyncPolicy = iota
+	// FsyncOnRotate syncs a file before it is rotated or closed.
+	FsyncOnRotate
+	// FsyncAlways syncs after every record, so that no acknowledged record
+	// is lost if the machine crashes.
+	FsyncAlways
+)
+
+const rotatedTimeLayout = "20060102T150405.000000000"
+
+var errSinkClosed = errors.New("audit file sink is closed")
+
+// FileSinkConfig configures a FileSink.
+type FileSinkConfig struct {
+	// Path is the file records are appended to.
+	Path string
+	// MaxBytes rotates the file before a record would take it above this
+	// size. Zero disables size based rotation.
+	MaxBytes int64
+	// MaxAge rotates the file once it has been written to for this long.
+	// Zero disables time based rotation.
+	MaxAge time.Duration
+	Fsync  FsyncPolicy
+}
+
+// FileSink appends records as JSON lines to a file. Rotated files are renamed
+// to Path followed by the time of rotation, e.g. audit.log.20190102T030405.000000000,
+// and are never modified again.
+type FileSink struct {
+	config FileSinkConfig
+
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, errors.New("audit file sink requires a path")
+	}
+
+	s := &FileSink{config: config}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return errSinkClosed
+	}
+	// A failed rotation leaves the current file open, and the record is
+	// appended to it rather than dropped; the error is still returned so that
+	// it is reported.
+	var rotateErr error
+	if s.shouldRotate(int64(len(line))) {
+		rotateErr = s.rotate()
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.config.Fsync == FsyncAlways {
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+	return rotateErr
+}
+
+// Close closes the current file. Records written afterwards are rejected.
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.closeFile()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) shouldRotate(lineSize int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.config.MaxBytes > 0 && s.size+lineSize > s.config.MaxBytes {
+		return true
+	}
+	return s.config.MaxAge > 0 && time.Since(s.openedAt) >= s.config.MaxAge
+}
+
+// rotate renames the current file and opens a new one at Path. The current
+// file is only closed once the new one is open, so that s.file stays usable
+// whichever step fails.
+func (s *FileSink) rotate() error {
+	if s.config.Fsync != FsyncNever {
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	rotatedPath := fmt.Sprintf("%s.%s", s.config.Path, time.Now().UTC().Format(rotatedTimeLayout))
+	if err := os.Rename(s.config.Path, rotatedPath); err != nil {
+		return err
+	}
+	current := s.file
+	if err := s.open(); err != nil {
+		return err
+	}
+	return current.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *FileSink) closeFile() error {
+	var syncErr error
+	if s.config.Fsync != FsyncNever {
+		syncErr = s.file.Sync()
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
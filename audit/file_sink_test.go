@@ -0,0 +1,159 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/audit"
+)
+
+var _ = Describe("FileSink", func() {
+	var (
+		dir  string
+		path string
+		sink *audit.FileSink
+	)
+
+	readRecords := func(path string) []audit.Record {
+		file, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		var records []audit.Record
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record audit.Record
+			Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+			records = append(records, record)
+		}
+		return records
+	}
+
+	rotatedFiles := func() []string {
+		matches, err := filepath.Glob(path + ".*")
+		Expect(err).NotTo(HaveOccurred())
+		return matches
+	}
+
+	newSink := func(config audit.FileSinkConfig) *audit.FileSink {
+		config.Path = path
+		s, err := audit.NewFileSink(config)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "audit")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "audit.log")
+	})
+
+	AfterEach(func() {
+		if sink != nil {
+			sink.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("appends records as JSON lines", func() {
+		Expect(ioutil.WriteFile(path, []byte(`{"method":"GET"}`+"\n"), 0600)).To(Succeed())
+
+		sink = newSink(audit.FileSinkConfig{Fsync: audit.FsyncAlways})
+		Expect(sink.Write(audit.Record{Method: "PUT", InstanceID: "instance-id"})).To(Succeed())
+
+		records := readRecords(path)
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].Method).To(Equal("GET"))
+		Expect(records[1].InstanceID).To(Equal("instance-id"))
+	})
+
+	It("creates the file with restricted permissions", func() {
+		sink = newSink(audit.FileSinkConfig{})
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("rotates files which would exceed MaxBytes", func() {
+		sink = newSink(audit.FileSinkConfig{MaxBytes: 200, Fsync: audit.FsyncOnRotate})
+		for i := 0; i < 3; i++ {
+			Expect(sink.Write(audit.Record{Method: "PUT", InstanceID: "some-long-instance-id"})).To(Succeed())
+		}
+
+		Expect(rotatedFiles()).NotTo(BeEmpty())
+		total := len(readRecords(path))
+		for _, rotated := range rotatedFiles() {
+			info, err := os.Stat(rotated)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Size()).To(BeNumerically("<=", 200))
+			total += len(readRecords(rotated))
+		}
+		Expect(total).To(Equal(3))
+	})
+
+	It("rotates files older than MaxAge", func() {
+		sink = newSink(audit.FileSinkConfig{MaxAge: 20 * time.Millisecond})
+		Expect(sink.Write(audit.Record{Method: "PUT"})).To(Succeed())
+		Expect(rotatedFiles()).To(BeEmpty())
+
+		time.Sleep(30 * time.Millisecond)
+		Expect(sink.Write(audit.Record{Method: "DELETE"})).To(Succeed())
+
+		Expect(rotatedFiles()).To(HaveLen(1))
+		Expect(readRecords(rotatedFiles()[0])[0].Method).To(Equal("PUT"))
+		Expect(readRecords(path)[0].Method).To(Equal("DELETE"))
+	})
+
+	It("keeps appending to the current file when it cannot be rotated", func() {
+		sink = newSink(audit.FileSinkConfig{MaxBytes: 100})
+		Expect(sink.Write(audit.Record{Method: "PUT", InstanceID: "some-long-instance-id"})).To(Succeed())
+
+		// The rename fails once the file is gone from Path, while the open
+		// file stays reachable through the link.
+		linked := filepath.Join(dir, "linked.log")
+		Expect(os.Link(path, linked)).To(Succeed())
+		Expect(os.Remove(path)).To(Succeed())
+
+		Expect(sink.Write(audit.Record{Method: "DELETE", InstanceID: "some-long-instance-id"})).NotTo(Succeed())
+		Expect(sink.Write(audit.Record{Method: "PATCH", InstanceID: "some-long-instance-id"})).NotTo(Succeed())
+
+		records := readRecords(linked)
+		Expect(records).To(HaveLen(3))
+		Expect(records[2].Method).To(Equal("PATCH"))
+	})
+
+	It("rejects records once closed", func() {
+		sink = newSink(audit.FileSinkConfig{})
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(sink.Write(audit.Record{})).To(MatchError("audit file sink is closed"))
+	})
+
+	It("requires a path", func() {
+		_, err := audit.NewFileSink(audit.FileSinkConfig{})
+		Expect(err).To(HaveOccurred())
+	})
+})
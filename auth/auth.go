@@ -16,51 +16,165 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base64"
 	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
 )
 
 type Wrapper struct {
-	username []byte
-	password []byte
+	credentials atomic.Value
+	challenge   string
+
+	lookup    CredentialsLookup
+	compare   PasswordComparer
+	dummyHash []byte
+	logger    lager.Logger
+}
+
+type hashedCredentials struct {
+	// name is the username in the clear, stored as the principal of the
+	// requests accepted.
+	name     string
+	username [sha256.Size]byte
+	password [sha256.Size]byte
 }
 
+// DefaultRealm is the realm of the WWW-Authenticate challenge sent by a
+// Wrapper unless another is set with WithRealm or WithChallenge.
+const DefaultRealm = "Service Broker"
+
 func NewWrapper(username, password string) *Wrapper {
-	u := sha256.Sum256([]byte(username))
-	p := sha256.Sum256([]byte(password))
-	return &Wrapper{username: u[:], password: p[:]}
+	wrapper := &Wrapper{challenge: basicChallenge(DefaultRealm)}
+	wrapper.SetCredentials(username, password)
+	return wrapper
+}
+
+// SetCredentials replaces the credentials accepted by the wrapper. It is safe
+// to call while requests are being served, allowing credentials to be rotated
+// without rebuilding the handler.
+func (wrapper *Wrapper) SetCredentials(username, password string) {
+	wrapper.credentials.Store(&hashedCredentials{
+		name:     username,
+		username: sha256.Sum256([]byte(username)),
+		password: sha256.Sum256([]byte(password)),
+	})
 }
 
+// WithRealm sets the realm of the Basic challenge sent in the WWW-Authenticate
+// header of 401 responses.
+func (wrapper *Wrapper) WithRealm(realm string) *Wrapper {
+	wrapper.challenge = basicChallenge(realm)
+	return wrapper
+}
+
+// WithChallenge replaces the WWW-Authenticate header of 401 responses, for
+// example with `Bearer realm="broker"`. An empty challenge leaves the header
+// out, as some deployments behind token-issuing proxies prefer.
+func (wrapper *Wrapper) WithChallenge(challenge string) *Wrapper {
+	wrapper.challenge = challenge
+	return wrapper
+}
+
+func basicChallenge(realm string) string {
+	return `Basic realm="` + quotedStringEscaper.Replace(realm) + `", charset="UTF-8"`
+}
+
+var quotedStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 const notAuthorized = "Not Authorized"
 
-func (wrapper *Wrapper) Wrap(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !authorized(wrapper, r) {
-			http.Error(w, notAuthorized, http.StatusUnauthorized)
-			return
-		}
+func (wrapper *Wrapper) unauthorized(w http.ResponseWriter) {
+	if wrapper.challenge != "" {
+		w.Header().Set("WWW-Authenticate", wrapper.challenge)
+	}
+	http.Error(w, notAuthorized, http.StatusUnauthorized)
+}
 
-		handler.ServeHTTP(w, r)
-	})
+func (wrapper *Wrapper) Wrap(handler http.Handler) http.Handler {
+	return wrapper.WrapFunc(handler.ServeHTTP)
 }
 
 func (wrapper *Wrapper) WrapFunc(handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !authorized(wrapper, r) {
-			http.Error(w, notAuthorized, http.StatusUnauthorized)
-			return
+		var principal string
+		if wrapper.lookup != nil {
+			username, ok, err := wrapper.lookupAuthorized(r)
+			if err != nil {
+				wrapper.logger.Error(lookupFailedKey, err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				wrapper.unauthorized(w)
+				return
+			}
+			principal = username
+		} else {
+			username, ok := authorized(wrapper, r)
+			if !ok {
+				wrapper.unauthorized(w)
+				return
+			}
+			principal = username
 		}
 
-		handlerFunc(w, r)
+		handlerFunc(w, r.WithContext(contextkeys.WithPrincipal(r.Context(), principal)))
 	})
 }
 
-func authorized(wrapper *Wrapper, r *http.Request) bool {
-	username, password, isOk := r.BasicAuth()
-	u := sha256.Sum256([]byte(username))
-	p := sha256.Sum256([]byte(password))
-	return isOk &&
-		subtle.ConstantTimeCompare(wrapper.username, u[:]) == 1 &&
-		subtle.ConstantTimeCompare(wrapper.password, p[:]) == 1
+// authorized returns the username of the request if its credentials match.
+func authorized(wrapper *Wrapper, r *http.Request) (string, bool) {
+	credentials := wrapper.credentials.Load().(*hashedCredentials)
+	u, p, isOk := hashedBasicAuth(r.Header.Get("Authorization"))
+	ok := isOk &&
+		subtle.ConstantTimeCompare(credentials.username[:], u[:]) == 1 &&
+		subtle.ConstantTimeCompare(credentials.password[:], p[:]) == 1
+	return credentials.name, ok
+}
+
+const (
+	basicAuthPrefix = "Basic "
+
+	// maxStackCredentialsSize bounds the encoded credentials that are parsed
+	// without a heap allocation; anything longer falls back to a heap buffer.
+	maxStackCredentialsSize = 256
+)
+
+// hashedBasicAuth parses the Authorization header like http.Request.BasicAuth,
+// but returns the SHA-256 sums of the username and password so that the decoded
+// credentials never have to leave the stack.
+func hashedBasicAuth(header string) (username, password [sha256.Size]byte, ok bool) {
+	if len(header) < len(basicAuthPrefix) || !strings.EqualFold(header[:len(basicAuthPrefix)], basicAuthPrefix) {
+		return username, password, false
+	}
+	encoded := header[len(basicAuthPrefix):]
+
+	var encodedBuffer, decodedBuffer [maxStackCredentialsSize]byte
+	var src, dst []byte
+	if len(encoded) <= maxStackCredentialsSize {
+		src = encodedBuffer[:copy(encodedBuffer[:], encoded)]
+		dst = decodedBuffer[:]
+	} else {
+		src = []byte(encoded)
+		dst = make([]byte, base64.StdEncoding.DecodedLen(len(src)))
+	}
+
+	n, err := base64.StdEncoding.Decode(dst, src)
+	if err != nil {
+		return username, password, false
+	}
+	credentials := dst[:n]
+
+	separator := bytes.IndexByte(credentials, ':')
+	if separator < 0 {
+		return username, password, false
+	}
+	return sha256.Sum256(credentials[:separator]), sha256.Sum256(credentials[separator+1:]), true
 }
@@ -16,12 +16,15 @@
 package auth_test
 
 import (
+	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
 )
 
 var _ = Describe("Auth Wrapper", func() {
@@ -60,6 +63,15 @@ var _ = Describe("Auth Wrapper", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusCreated))
 		})
 
+		It("stores the accepted username as the principal", func() {
+			var principal string
+			handler := auth.NewWrapper(username, password).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, _ = contextkeys.Principal(r.Context())
+			}))
+			handler.ServeHTTP(httpRecorder, newRequest(username, password))
+			Expect(principal).To(Equal(username))
+		})
+
 		It("fails when the username is empty", func() {
 			request := newRequest("", password)
 			wrappedHandler.ServeHTTP(httpRecorder, request)
@@ -77,6 +89,113 @@ var _ = Describe("Auth Wrapper", func() {
 			wrappedHandler.ServeHTTP(httpRecorder, request)
 			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
 		})
+
+		It("accepts the scheme case-insensitively", func() {
+			request := newRequest(username, password)
+			request.Header.Set("Authorization", strings.Replace(request.Header.Get("Authorization"), "Basic", "bAsIc", 1))
+			wrappedHandler.ServeHTTP(httpRecorder, request)
+			Expect(httpRecorder.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("fails when the credentials are not valid base64", func() {
+			request := newRequest(username, password)
+			request.Header.Set("Authorization", "Basic not-base64!")
+			wrappedHandler.ServeHTTP(httpRecorder, request)
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("fails when the decoded credentials have no separator", func() {
+			request := newRequest(username, password)
+			request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("usernamepassword")))
+			wrappedHandler.ServeHTTP(httpRecorder, request)
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		Context("when the credentials are very long", func() {
+			BeforeEach(func() {
+				password = strings.Repeat("p", 1024)
+				handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusCreated)
+				})
+				wrappedHandler = auth.NewWrapper(username, password).Wrap(handler)
+			})
+
+			It("works when the credentials are correct", func() {
+				request := newRequest(username, password)
+				wrappedHandler.ServeHTTP(httpRecorder, request)
+				Expect(httpRecorder.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("fails when the credentials are wrong", func() {
+				request := newRequest(username, password+"x")
+				wrappedHandler.ServeHTTP(httpRecorder, request)
+				Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	})
+
+	Describe("rotated credentials", func() {
+		var (
+			wrapper        *auth.Wrapper
+			wrappedHandler http.Handler
+		)
+
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			})
+			wrapper = auth.NewWrapper(username, password)
+			wrappedHandler = wrapper.Wrap(handler)
+			wrapper.SetCredentials("new-username", "new-password")
+		})
+
+		It("works with the new credentials", func() {
+			request := newRequest("new-username", "new-password")
+			wrappedHandler.ServeHTTP(httpRecorder, request)
+			Expect(httpRecorder.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("fails with the old credentials", func() {
+			request := newRequest(username, password)
+			wrappedHandler.ServeHTTP(httpRecorder, request)
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("authentication challenge", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		It("challenges for basic auth in the default realm", func() {
+			auth.NewWrapper(username, password).Wrap(handler).ServeHTTP(httpRecorder, newRequest("thats", "apar"))
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(httpRecorder.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="Service Broker", charset="UTF-8"`))
+		})
+
+		It("uses the configured realm", func() {
+			wrapper := auth.NewWrapper(username, password).WithRealm(`the "broker"`)
+			wrapper.WrapFunc(handler).ServeHTTP(httpRecorder, newRequest("thats", "apar"))
+			Expect(httpRecorder.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="the \"broker\"", charset="UTF-8"`))
+		})
+
+		It("uses the configured challenge", func() {
+			wrapper := auth.NewWrapper(username, password).WithChallenge(`Bearer realm="broker"`)
+			wrapper.Wrap(handler).ServeHTTP(httpRecorder, newRequest("thats", "apar"))
+			Expect(httpRecorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer realm="broker"`))
+		})
+
+		It("leaves the challenge out when it is empty", func() {
+			wrapper := auth.NewWrapper(username, password).WithChallenge("")
+			wrapper.Wrap(handler).ServeHTTP(httpRecorder, newRequest("thats", "apar"))
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(httpRecorder.Header()).NotTo(HaveKey("Www-Authenticate"))
+		})
+
+		It("does not challenge authorized requests", func() {
+			auth.NewWrapper(username, password).Wrap(handler).ServeHTTP(httpRecorder, newRequest(username, password))
+			Expect(httpRecorder.Header()).NotTo(HaveKey("Www-Authenticate"))
+		})
 	})
 
 	Describe("wrapped handlerFunc", func() {
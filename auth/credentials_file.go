@@ -0,0 +1,194 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	usernameFileName = "username"
+	passwordFileName = "password"
+
+	defaultCredentialsPollInterval = 10 * time.Second
+)
+
+var errEmptyCredentials = errors.New("credentials file must contain a username and a password")
+
+type fileCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// NewWrapperFromFile returns a Wrapper using the credentials stored at path.
+// See ReadCredentialsFile for the supported layouts.
+func NewWrapperFromFile(path string) (*Wrapper, error) {
+	username, password, err := ReadCredentialsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWrapper(username, password), nil
+}
+
+// ReadCredentialsFile reads basic auth credentials from path, which is either
+// a JSON file of the form {"username": "...", "password": "..."}, or a
+// directory containing "username" and "password" files, as produced when a
+// Kubernetes secret is mounted into a pod.
+func ReadCredentialsFile(path string) (username, password string, err error) {
+	contents, err := readCredentialsContents(path)
+	if err != nil {
+		return "", "", err
+	}
+	return parseCredentials(path, contents)
+}
+
+func parseCredentials(path string, contents []byte) (username, password string, err error) {
+	var credentials fileCredentials
+	if err := json.Unmarshal(contents, &credentials); err != nil {
+		return "", "", fmt.Errorf("could not parse credentials file %s: %s", path, err)
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return "", "", errEmptyCredentials
+	}
+	return credentials.Username, credentials.Password, nil
+}
+
+// readCredentialsContents returns the credentials at path in their JSON form,
+// so that both layouts can be compared for changes in the same way.
+func readCredentialsContents(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return ioutil.ReadFile(path)
+	}
+
+	username, err := ioutil.ReadFile(filepath.Join(path, usernameFileName))
+	if err != nil {
+		return nil, err
+	}
+	password, err := ioutil.ReadFile(filepath.Join(path, passwordFileName))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fileCredentials{
+		Username: string(bytes.TrimRight(username, "\r\n")),
+		Password: string(bytes.TrimRight(password, "\r\n")),
+	})
+}
+
+// CredentialsFileWatcher keeps the credentials of a Wrapper in sync with a
+// credentials file, reloading them when the file changes or when the process
+// receives SIGHUP.
+type CredentialsFileWatcher struct {
+	wrapper      *Wrapper
+	path         string
+	logger       lager.Logger
+	pollInterval time.Duration
+
+	mutex        sync.Mutex
+	lastChecksum [sha256.Size]byte
+}
+
+// NewCredentialsFileWatcher returns a watcher which updates wrapper from the
+// credentials file at path. The file is checked for changes every 10 seconds
+// unless a different interval is set with WithPollInterval.
+func NewCredentialsFileWatcher(wrapper *Wrapper, path string, logger lager.Logger) *CredentialsFileWatcher {
+	return &CredentialsFileWatcher{
+		wrapper:      wrapper,
+		path:         path,
+		logger:       logger.Session("credentials-file-watcher", lager.Data{"path": path}),
+		pollInterval: defaultCredentialsPollInterval,
+	}
+}
+
+// WithPollInterval sets how often the credentials file is checked for changes.
+func (w *CredentialsFileWatcher) WithPollInterval(interval time.Duration) *CredentialsFileWatcher {
+	w.pollInterval = interval
+	return w
+}
+
+// Reload reads the credentials file and, if it has changed since the last
+// successful reload, updates the wrapper. When the file cannot be read or is
+// invalid the previous credentials remain in effect.
+func (w *CredentialsFileWatcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	contents, err := readCredentialsContents(w.path)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(contents)
+	if checksum == w.lastChecksum {
+		return nil
+	}
+
+	username, password, err := parseCredentials(w.path, contents)
+	if err != nil {
+		return err
+	}
+
+	w.wrapper.SetCredentials(username, password)
+	w.lastChecksum = checksum
+	w.logger.Info("credentials-reloaded")
+	return nil
+}
+
+// Run reloads the credentials whenever the file changes or SIGHUP is received,
+// until ctx is cancelled.
+func (w *CredentialsFileWatcher) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	w.reloadAndLog()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			w.reloadAndLog()
+		case <-ticker.C:
+			w.reloadAndLog()
+		}
+	}
+}
+
+func (w *CredentialsFileWatcher) reloadAndLog() {
+	if err := w.Reload(); err != nil {
+		w.logger.Error("reloading-credentials-failed", err)
+	}
+}
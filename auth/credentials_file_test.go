@@ -0,0 +1,135 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/auth"
+)
+
+var _ = Describe("Credentials file", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "credentials")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	writeFile := func(path, contents string) {
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	statusFor := func(wrapper *auth.Wrapper, username, password string) int {
+		handler := wrapper.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		request, err := http.NewRequest("GET", "", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.SetBasicAuth(username, password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	Describe("ReadCredentialsFile", func() {
+		It("reads credentials from a JSON file", func() {
+			path := filepath.Join(tempDir, "credentials.json")
+			writeFile(path, `{"username":"admin","password":"secret"}`)
+
+			username, password, err := auth.ReadCredentialsFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(username).To(Equal("admin"))
+			Expect(password).To(Equal("secret"))
+		})
+
+		It("reads credentials from a mounted secret directory", func() {
+			writeFile(filepath.Join(tempDir, "username"), "admin\n")
+			writeFile(filepath.Join(tempDir, "password"), "secret\n")
+
+			username, password, err := auth.ReadCredentialsFile(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(username).To(Equal("admin"))
+			Expect(password).To(Equal("secret"))
+		})
+
+		It("fails when the password is missing", func() {
+			path := filepath.Join(tempDir, "credentials.json")
+			writeFile(path, `{"username":"admin"}`)
+
+			_, _, err := auth.ReadCredentialsFile(path)
+			Expect(err).To(MatchError("credentials file must contain a username and a password"))
+		})
+
+		It("fails when the file is not valid JSON", func() {
+			path := filepath.Join(tempDir, "credentials.json")
+			writeFile(path, `username: admin`)
+
+			_, _, err := auth.ReadCredentialsFile(path)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails when the file does not exist", func() {
+			_, err := auth.NewWrapperFromFile(filepath.Join(tempDir, "missing.json"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CredentialsFileWatcher", func() {
+		var (
+			path    string
+			wrapper *auth.Wrapper
+			watcher *auth.CredentialsFileWatcher
+		)
+
+		BeforeEach(func() {
+			path = filepath.Join(tempDir, "credentials.json")
+			writeFile(path, `{"username":"admin","password":"secret"}`)
+
+			var err error
+			wrapper, err = auth.NewWrapperFromFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			watcher = auth.NewCredentialsFileWatcher(wrapper, path, lagertest.NewTestLogger("watcher"))
+		})
+
+		It("picks up rotated credentials on reload", func() {
+			writeFile(path, `{"username":"admin","password":"rotated"}`)
+			Expect(watcher.Reload()).To(Succeed())
+
+			Expect(statusFor(wrapper, "admin", "rotated")).To(Equal(http.StatusOK))
+			Expect(statusFor(wrapper, "admin", "secret")).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("keeps the previous credentials when the file becomes invalid", func() {
+			writeFile(path, `{"username":"admin"}`)
+			Expect(watcher.Reload()).NotTo(Succeed())
+
+			Expect(statusFor(wrapper, "admin", "secret")).To(Equal(http.StatusOK))
+		})
+	})
+})
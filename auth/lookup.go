@@ -0,0 +1,112 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const lookupFailedKey = "credentials-lookup-failed"
+
+// ErrPasswordMismatch is returned by CompareSHA256Password when the password
+// does not match.
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// CredentialsLookup returns the hashed password of username, and whether the
+// user is allowed to use the broker. It is called for every request, so that
+// credentials can be kept in a database or fetched per tenant, and should
+// cache them when fetching is slow. An error fails the request with a 500
+// rather than rejecting the credentials.
+type CredentialsLookup func(ctx context.Context, username string) (hashedPassword []byte, allowed bool, err error)
+
+// PasswordComparer returns nil when password matches hashedPassword.
+// bcrypt.CompareHashAndPassword from golang.org/x/crypto/bcrypt is one.
+type PasswordComparer func(hashedPassword, password []byte) error
+
+// NewLookupWrapper returns a Wrapper accepting the credentials returned by
+// lookup, comparing passwords with compare, or with CompareSHA256Password if
+// compare is nil. SetCredentials has no effect on it. The passwords of unknown
+// or disallowed users are compared with a dummy hash, so that the time taken
+// to reject them does not tell which usernames exist; with a comparer other
+// than CompareSHA256Password, set one in its format with WithDummyHash.
+func NewLookupWrapper(lookup CredentialsLookup, compare PasswordComparer, logger lager.Logger) *Wrapper {
+	wrapper := &Wrapper{
+		challenge: basicChallenge(DefaultRealm),
+		lookup:    lookup,
+		compare:   compare,
+		logger:    logger.Session("auth"),
+	}
+	if compare == nil {
+		wrapper.compare = CompareSHA256Password
+		wrapper.dummyHash = SHA256Password("")
+	}
+	return wrapper
+}
+
+// WithDummyHash sets the hash the passwords of unknown or disallowed users are
+// compared with, such as a bcrypt hash of a random password generated at
+// startup. It must be in the format the comparer expects, so that comparing
+// with it takes as long as comparing with the hash of a real user.
+func (wrapper *Wrapper) WithDummyHash(hashedPassword []byte) *Wrapper {
+	wrapper.dummyHash = hashedPassword
+	return wrapper
+}
+
+// lookupAuthorized returns the username of the request if the lookup accepts
+// its credentials.
+func (wrapper *Wrapper) lookupAuthorized(r *http.Request) (string, bool, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false, nil
+	}
+	hashedPassword, allowed, err := wrapper.lookup(r.Context(), username)
+	if err != nil {
+		return "", false, err
+	}
+	if !allowed {
+		wrapper.compare(wrapper.dummyHash, []byte(password))
+		return "", false, nil
+	}
+	return username, wrapper.compare(hashedPassword, []byte(password)) == nil, nil
+}
+
+// SHA256Password returns the hex encoded SHA-256 sum of password, as compared
+// by CompareSHA256Password. An unsalted sum is only suitable for long random
+// passwords, such as those generated for each platform; use a comparer such as
+// bcrypt for passwords chosen by people.
+func SHA256Password(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// CompareSHA256Password compares password with a sum returned by
+// SHA256Password in constant time.
+func CompareSHA256Password(hashedPassword, password []byte) error {
+	sum := sha256.Sum256(password)
+	expected := make([]byte, hex.EncodedLen(len(sum)))
+	hex.Encode(expected, sum[:])
+	if subtle.ConstantTimeCompare(expected, hashedPassword) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/auth"
+)
+
+var _ = Describe("Lookup Wrapper", func() {
+	var (
+		users         map[string][]byte
+		disabled      map[string]bool
+		lookupErr     error
+		lookupContext context.Context
+		logger        *lagertest.TestLogger
+		handler       http.Handler
+	)
+
+	lookup := func(ctx context.Context, username string) ([]byte, bool, error) {
+		lookupContext = ctx
+		hashedPassword, found := users[username]
+		return hashedPassword, found && !disabled[username], lookupErr
+	}
+
+	serve := func(username, password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request = request.WithContext(context.WithValue(request.Context(), "tenant", "tenant-1"))
+		if username != "" {
+			request.SetBasicAuth(username, password)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		users = map[string][]byte{
+			"platform-1": auth.SHA256Password("password-1"),
+			"platform-2": auth.SHA256Password("password-2"),
+		}
+		disabled = map[string]bool{}
+		lookupErr = nil
+		logger = lagertest.NewTestLogger("broker")
+		handler = auth.NewLookupWrapper(lookup, nil, logger).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}))
+	})
+
+	It("accepts the credentials of each user", func() {
+		Expect(serve("platform-1", "password-1").Code).To(Equal(http.StatusCreated))
+		Expect(serve("platform-2", "password-2").Code).To(Equal(http.StatusCreated))
+		Expect(lookupContext.Value("tenant")).To(Equal("tenant-1"))
+	})
+
+	It("rejects the password of another user", func() {
+		response := serve("platform-1", "password-2")
+		Expect(response.Code).To(Equal(http.StatusUnauthorized))
+		Expect(response.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="Service Broker", charset="UTF-8"`))
+	})
+
+	It("rejects unknown users and requests without credentials", func() {
+		Expect(serve("platform-3", "password-1").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("", "").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects users which are not allowed", func() {
+		disabled["platform-1"] = true
+		Expect(serve("platform-1", "password-1").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("fails the request when the lookup fails", func() {
+		lookupErr = errors.New("database unavailable")
+
+		Expect(serve("platform-1", "password-1").Code).To(Equal(http.StatusInternalServerError))
+		Expect(logger.Logs()).To(HaveLen(1))
+		Expect(logger.Logs()[0].Message).To(Equal("broker.auth.credentials-lookup-failed"))
+	})
+
+	It("compares the passwords of unknown and disallowed users with the dummy hash", func() {
+		var compared []string
+		compare := func(hashedPassword, password []byte) error {
+			compared = append(compared, string(hashedPassword))
+			return errors.New("mismatch")
+		}
+		handler = auth.NewLookupWrapper(lookup, compare, logger).
+			WithDummyHash([]byte("dummy")).
+			Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		disabled["platform-2"] = true
+		Expect(serve("platform-3", "password-1").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("platform-2", "password-2").Code).To(Equal(http.StatusUnauthorized))
+		Expect(compared).To(Equal([]string{"dummy", "dummy"}))
+	})
+
+	It("compares passwords with the given comparer", func() {
+		users["platform-1"] = []byte("plain:password-1")
+		compare := func(hashedPassword, password []byte) error {
+			if string(hashedPassword) != "plain:"+string(password) {
+				return errors.New("mismatch")
+			}
+			return nil
+		}
+		handler = auth.NewLookupWrapper(lookup, compare, logger).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		Expect(serve("platform-1", "password-1").Code).To(Equal(http.StatusOK))
+		Expect(serve("platform-1", "password-2").Code).To(Equal(http.StatusUnauthorized))
+	})
+})
+
+var _ = Describe("CompareSHA256Password", func() {
+	It("matches the sum of the password only", func() {
+		hashedPassword := auth.SHA256Password("password")
+		Expect(auth.CompareSHA256Password(hashedPassword, []byte("password"))).To(Succeed())
+		Expect(auth.CompareSHA256Password(hashedPassword, []byte("Password"))).To(MatchError(auth.ErrPasswordMismatch))
+	})
+})
@@ -0,0 +1,242 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package brokertest provides helpers for testing service brokers built with
+// brokerapi: a BrokerTester which sends authenticated Open Service Broker API
+// requests to a handler, generators for unique instance and binding IDs, and a
+// matcher which compares a response body with a JSON fixture.
+package brokertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+	"github.com/pborman/uuid"
+)
+
+// DefaultAPIVersion is the X-Broker-API-Version sent by a new BrokerTester.
+const DefaultAPIVersion = "2.14"
+
+// BrokerTester sends requests to a broker handler, with basic auth
+// credentials and the X-Broker-API-Version header set. The With methods
+// return a modified copy, so a BrokerTester can be shared between tests.
+type BrokerTester struct {
+	handler    http.Handler
+	username   string
+	password   string
+	apiVersion string
+	header     http.Header
+}
+
+// New returns a BrokerTester which sends requests to handler with the given
+// basic auth credentials.
+func New(handler http.Handler, username, password string) BrokerTester {
+	return BrokerTester{
+		handler:    handler,
+		username:   username,
+		password:   password,
+		apiVersion: DefaultAPIVersion,
+		header:     http.Header{},
+	}
+}
+
+// WithAPIVersion sets the X-Broker-API-Version header. An empty version
+// omits the header.
+func (t BrokerTester) WithAPIVersion(version string) BrokerTester {
+	t.apiVersion = version
+	return t
+}
+
+// WithoutAuth sends requests without credentials.
+func (t BrokerTester) WithoutAuth() BrokerTester {
+	t.username, t.password = "", ""
+	return t
+}
+
+// WithHeader adds a header to every request, such as
+// X-Broker-API-Originating-Identity.
+func (t BrokerTester) WithHeader(key, value string) BrokerTester {
+	header := http.Header{}
+	for k, v := range t.header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Add(key, value)
+	t.header = header
+	return t
+}
+
+// NewRequest builds a request for path. body may be nil, a string or []byte
+// sent as is, or any other value, which is encoded as JSON.
+func (t BrokerTester) NewRequest(method, path string, body interface{}) *http.Request {
+	req := httptest.NewRequest(method, path, encodeBody(body))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if t.apiVersion != "" {
+		req.Header.Set("X-Broker-API-Version", t.apiVersion)
+	}
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	for key, values := range t.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req
+}
+
+// Do sends a request to the handler and returns the recorded response.
+func (t BrokerTester) Do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, t.NewRequest(method, path, body))
+	return recorder
+}
+
+// Catalog sends GET /v2/catalog.
+func (t BrokerTester) Catalog() *httptest.ResponseRecorder {
+	return t.Do(http.MethodGet, "/v2/catalog", nil)
+}
+
+// Provision sends PUT /v2/service_instances/:instance_id.
+func (t BrokerTester) Provision(instanceID string, details interface{}, acceptsIncomplete bool) *httptest.ResponseRecorder {
+	return t.Do(http.MethodPut, withQuery(instancePath(instanceID), acceptsIncompleteQuery(acceptsIncomplete)), details)
+}
+
+// Update sends PATCH /v2/service_instances/:instance_id.
+func (t BrokerTester) Update(instanceID string, details interface{}, acceptsIncomplete bool) *httptest.ResponseRecorder {
+	return t.Do(http.MethodPatch, withQuery(instancePath(instanceID), acceptsIncompleteQuery(acceptsIncomplete)), details)
+}
+
+// Deprovision sends DELETE /v2/service_instances/:instance_id.
+func (t BrokerTester) Deprovision(instanceID, serviceID, planID string, acceptsIncomplete bool) *httptest.ResponseRecorder {
+	query := acceptsIncompleteQuery(acceptsIncomplete)
+	query.Set("service_id", serviceID)
+	query.Set("plan_id", planID)
+	return t.Do(http.MethodDelete, withQuery(instancePath(instanceID), query), nil)
+}
+
+// GetInstance sends GET /v2/service_instances/:instance_id.
+func (t BrokerTester) GetInstance(instanceID string) *httptest.ResponseRecorder {
+	return t.Do(http.MethodGet, instancePath(instanceID), nil)
+}
+
+// LastOperation sends GET /v2/service_instances/:instance_id/last_operation,
+// with the operation query parameter when operation is not empty.
+func (t BrokerTester) LastOperation(instanceID, operation string) *httptest.ResponseRecorder {
+	return t.Do(http.MethodGet, withQuery(instancePath(instanceID)+"/last_operation", operationQuery(operation)), nil)
+}
+
+// Bind sends PUT /v2/service_instances/:instance_id/service_bindings/:binding_id.
+func (t BrokerTester) Bind(instanceID, bindingID string, details interface{}, acceptsIncomplete bool) *httptest.ResponseRecorder {
+	return t.Do(http.MethodPut, withQuery(bindingPath(instanceID, bindingID), acceptsIncompleteQuery(acceptsIncomplete)), details)
+}
+
+// Unbind sends DELETE /v2/service_instances/:instance_id/service_bindings/:binding_id.
+func (t BrokerTester) Unbind(instanceID, bindingID, serviceID, planID string, acceptsIncomplete bool) *httptest.ResponseRecorder {
+	query := acceptsIncompleteQuery(acceptsIncomplete)
+	query.Set("service_id", serviceID)
+	query.Set("plan_id", planID)
+	return t.Do(http.MethodDelete, withQuery(bindingPath(instanceID, bindingID), query), nil)
+}
+
+// GetBinding sends GET /v2/service_instances/:instance_id/service_bindings/:binding_id.
+func (t BrokerTester) GetBinding(instanceID, bindingID string) *httptest.ResponseRecorder {
+	return t.Do(http.MethodGet, bindingPath(instanceID, bindingID), nil)
+}
+
+// LastBindingOperation sends
+// GET /v2/service_instances/:instance_id/service_bindings/:binding_id/last_operation,
+// with the operation query parameter when operation is not empty.
+func (t BrokerTester) LastBindingOperation(instanceID, bindingID, operation string) *httptest.ResponseRecorder {
+	return t.Do(http.MethodGet, withQuery(bindingPath(instanceID, bindingID)+"/last_operation", operationQuery(operation)), nil)
+}
+
+// UniqueInstanceID returns a new random instance ID.
+func UniqueInstanceID() string {
+	return uuid.NewRandom().String()
+}
+
+// UniqueBindingID returns a new random binding ID.
+func UniqueBindingID() string {
+	return uuid.NewRandom().String()
+}
+
+// MatchJSONFixture succeeds when the actual value, a JSON string, []byte or
+// response body, is equivalent to the JSON in the file at path.
+func MatchJSONFixture(path string) types.GomegaMatcher {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("Could not read fixture: %s", path))
+	}
+	return gomega.MatchJSON(contents)
+}
+
+func encodeBody(body interface{}) io.Reader {
+	switch body := body.(type) {
+	case nil:
+		return nil
+	case string:
+		return strings.NewReader(body)
+	case []byte:
+		return bytes.NewReader(body)
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			panic(fmt.Sprintf("Could not encode request body: %s", err))
+		}
+		return bytes.NewReader(encoded)
+	}
+}
+
+func instancePath(instanceID string) string {
+	return "/v2/service_instances/" + instanceID
+}
+
+func bindingPath(instanceID, bindingID string) string {
+	return instancePath(instanceID) + "/service_bindings/" + bindingID
+}
+
+func acceptsIncompleteQuery(acceptsIncomplete bool) url.Values {
+	query := url.Values{}
+	if acceptsIncomplete {
+		query.Set("accepts_incomplete", "true")
+	}
+	return query
+}
+
+func operationQuery(operation string) url.Values {
+	query := url.Values{}
+	if operation != "" {
+		query.Set("operation", operation)
+	}
+	return query
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokertest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+)
+
+var _ = Describe("BrokerTester", func() {
+	var (
+		received *http.Request
+		body     string
+		tester   brokertest.BrokerTester
+	)
+
+	BeforeEach(func() {
+		received = nil
+		body = ""
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			received = req
+			contents, _ := ioutil.ReadAll(req.Body)
+			body = string(contents)
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(`{"ok":true}`))
+		})
+		tester = brokertest.New(handler, "username", "password")
+	})
+
+	It("sends basic auth and the API version header", func() {
+		response := tester.Catalog()
+
+		Expect(response.Code).To(Equal(http.StatusTeapot))
+		Expect(response.Body.String()).To(MatchJSON(`{"ok":true}`))
+		Expect(received.Method).To(Equal("GET"))
+		Expect(received.URL.Path).To(Equal("/v2/catalog"))
+		Expect(received.Header.Get("X-Broker-API-Version")).To(Equal(brokertest.DefaultAPIVersion))
+		username, password, ok := received.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(username).To(Equal("username"))
+		Expect(password).To(Equal("password"))
+	})
+
+	It("encodes the details as JSON", func() {
+		tester.Provision("instance-id", map[string]string{"service_id": "service-id"}, true)
+
+		Expect(received.Method).To(Equal("PUT"))
+		Expect(received.URL.String()).To(Equal("/v2/service_instances/instance-id?accepts_incomplete=true"))
+		Expect(received.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(body).To(MatchJSON(`{"service_id":"service-id"}`))
+	})
+
+	It("sends string bodies unchanged", func() {
+		tester.Update("instance-id", `{"plan_id":`, false)
+
+		Expect(received.Method).To(Equal("PATCH"))
+		Expect(received.URL.String()).To(Equal("/v2/service_instances/instance-id"))
+		Expect(body).To(Equal(`{"plan_id":`))
+	})
+
+	It("builds the paths of the binding endpoints", func() {
+		tester.Bind("instance-id", "binding-id", nil, false)
+		Expect(received.URL.String()).To(Equal("/v2/service_instances/instance-id/service_bindings/binding-id"))
+
+		tester.Unbind("instance-id", "binding-id", "service-id", "plan-id", true)
+		Expect(received.Method).To(Equal("DELETE"))
+		Expect(received.URL.Query().Get("service_id")).To(Equal("service-id"))
+		Expect(received.URL.Query().Get("plan_id")).To(Equal("plan-id"))
+		Expect(received.URL.Query().Get("accepts_incomplete")).To(Equal("true"))
+
+		tester.LastBindingOperation("instance-id", "binding-id", "op")
+		Expect(received.URL.String()).To(Equal("/v2/service_instances/instance-id/service_bindings/binding-id/last_operation?operation=op"))
+	})
+
+	It("omits empty operation parameters when polling", func() {
+		tester.LastOperation("instance-id", "")
+		Expect(received.URL.String()).To(Equal("/v2/service_instances/instance-id/last_operation"))
+	})
+
+	It("returns modified copies", func() {
+		modified := tester.WithAPIVersion("2.13").WithHeader("X-Broker-API-Originating-Identity", "cloudfoundry id").WithoutAuth()
+
+		modified.GetInstance("instance-id")
+		Expect(received.Header.Get("X-Broker-API-Version")).To(Equal("2.13"))
+		Expect(received.Header.Get("X-Broker-API-Originating-Identity")).To(Equal("cloudfoundry id"))
+		_, _, ok := received.BasicAuth()
+		Expect(ok).To(BeFalse())
+
+		tester.GetInstance("instance-id")
+		Expect(received.Header.Get("X-Broker-API-Version")).To(Equal(brokertest.DefaultAPIVersion))
+		Expect(received.Header).NotTo(HaveKey("X-Broker-Api-Originating-Identity"))
+	})
+
+	It("omits the API version header when it is empty", func() {
+		tester.WithAPIVersion("").Catalog()
+		Expect(received.Header).NotTo(HaveKey("X-Broker-Api-Version"))
+	})
+})
+
+var _ = Describe("UniqueInstanceID", func() {
+	It("returns a different ID each time", func() {
+		Expect(brokertest.UniqueInstanceID()).NotTo(Equal(brokertest.UniqueInstanceID()))
+		Expect(brokertest.UniqueBindingID()).NotTo(Equal(brokertest.UniqueBindingID()))
+	})
+})
+
+var _ = Describe("MatchJSONFixture", func() {
+	It("compares the actual JSON with the fixture", func() {
+		Expect(`{"operation":"some-operation-data"}`).To(brokertest.MatchJSONFixture("../fixtures/operation_data_response.json"))
+		Expect(`{}`).NotTo(brokertest.MatchJSONFixture("../fixtures/operation_data_response.json"))
+	})
+})
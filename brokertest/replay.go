@@ -0,0 +1,176 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"reflect"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/recording"
+)
+
+// Mismatch is a replayed exchange to which the broker responded differently
+// than when it was recorded.
+type Mismatch struct {
+	// Index is the position of the exchange in the recording.
+	Index    int
+	Exchange recording.Exchange
+	Status   int
+	Body     string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("exchange %d, %s %s: expected %d %s, got %d %s",
+		m.Index, m.Exchange.Request.Method, m.Exchange.Request.URI,
+		m.Exchange.Response.Status, m.Exchange.Response.Body, m.Status, m.Body,
+	)
+}
+
+// Replay sends the recorded requests to the handler in order, and returns the
+// exchanges whose response status or body differ from the recording. The
+// credentials of the BrokerTester replace the redacted Authorization header,
+// redacted fields of request bodies, such as parameters, are left out, and
+// redacted values in recorded response bodies match any value.
+func (t BrokerTester) Replay(exchanges []recording.Exchange) []Mismatch {
+	var mismatches []Mismatch
+	for i, exchange := range exchanges {
+		var body interface{}
+		if exchange.Request.Body != "" {
+			body = withoutRedacted(exchange.Request.Body)
+		}
+		req := t.NewRequest(exchange.Request.Method, exchange.Request.URI, body)
+		for key, values := range exchange.Request.Header {
+			if key == "Authorization" || key == "Content-Length" || containsRedacted(values) {
+				continue
+			}
+			req.Header[key] = values
+		}
+
+		recorder := httptest.NewRecorder()
+		t.handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != exchange.Response.Status || !bodiesMatch(exchange.Response.Body, recorder.Body.String()) {
+			mismatches = append(mismatches, Mismatch{
+				Index:    i,
+				Exchange: exchange,
+				Status:   recorder.Code,
+				Body:     recorder.Body.String(),
+			})
+		}
+	}
+	return mismatches
+}
+
+// ReplayFile replays the exchanges in a file written by a recording.Recorder.
+func (t BrokerTester) ReplayFile(path string) ([]Mismatch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	exchanges, err := recording.ReadExchanges(file)
+	if err != nil {
+		return nil, err
+	}
+	return t.Replay(exchanges), nil
+}
+
+// withoutRedacted removes the redacted fields from a JSON body. Other bodies
+// are returned as they are.
+func withoutRedacted(body string) string {
+	var value interface{}
+	if json.Unmarshal([]byte(body), &value) != nil {
+		return body
+	}
+	stripped, err := json.Marshal(stripRedacted(value))
+	if err != nil {
+		return body
+	}
+	return string(stripped)
+}
+
+func stripRedacted(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if field == recording.Redacted {
+				delete(value, key)
+			} else {
+				value[key] = stripRedacted(field)
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = stripRedacted(element)
+		}
+	}
+	return value
+}
+
+func containsRedacted(values []string) bool {
+	for _, value := range values {
+		if value == recording.Redacted {
+			return true
+		}
+	}
+	return false
+}
+
+// bodiesMatch compares JSON bodies structurally, and other bodies as strings.
+func bodiesMatch(recorded, actual string) bool {
+	var recordedValue, actualValue interface{}
+	if json.Unmarshal([]byte(recorded), &recordedValue) != nil || json.Unmarshal([]byte(actual), &actualValue) != nil {
+		return recorded == actual
+	}
+	return valuesMatch(recordedValue, actualValue)
+}
+
+func valuesMatch(recorded, actual interface{}) bool {
+	if recorded == recording.Redacted {
+		return true
+	}
+	switch recorded := recorded.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok || len(recorded) != len(actual) {
+			return false
+		}
+		for key, value := range recorded {
+			actualValue, ok := actual[key]
+			if !ok || !valuesMatch(value, actualValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(recorded) != len(actual) {
+			return false
+		}
+		for i := range recorded {
+			if !valuesMatch(recorded[i], actual[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(recorded, actual)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokertest_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/middlewares/recording"
+)
+
+var _ = Describe("Replay", func() {
+	var (
+		password  string
+		handler   http.Handler
+		exchanges []recording.Exchange
+		bodies    []string
+	)
+
+	BeforeEach(func() {
+		password = "first-secret"
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if _, given, _ := req.BasicAuth(); given != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"credentials":{"password":%q},"path":%q}`, password, req.URL.Path)
+		})
+
+		out := &bytes.Buffer{}
+		recorder := recording.New(out)
+		tester := brokertest.New(recorder.Wrap(handler), "username", "password")
+		tester.Bind("instance", "binding", map[string]interface{}{"plan_id": "plan", "parameters": map[string]string{"password": "hunter2"}}, false)
+		tester.GetBinding("instance", "binding")
+
+		var err error
+		exchanges, err = recording.ReadExchanges(out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exchanges).To(HaveLen(2))
+		bodies = nil
+	})
+
+	It("matches responses which differ only in redacted values", func() {
+		password = "second-secret"
+
+		mismatches := brokertest.New(handler, "username", "password").Replay(exchanges)
+
+		Expect(mismatches).To(BeEmpty())
+	})
+
+	It("leaves redacted fields out of the requests", func() {
+		brokertest.New(handler, "username", "password").Replay(exchanges)
+
+		Expect(bodies[0]).To(MatchJSON(`{"plan_id":"plan"}`))
+	})
+
+	It("reports exchanges whose response changed", func() {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		})
+
+		mismatches := brokertest.New(handler, "username", "password").Replay(exchanges)
+
+		Expect(mismatches).To(HaveLen(2))
+		Expect(mismatches[1].Index).To(Equal(1))
+		Expect(mismatches[1].Status).To(Equal(http.StatusOK))
+		Expect(mismatches[1].Body).To(Equal(`{}`))
+		Expect(mismatches[1].String()).To(ContainSubstring("GET /v2/service_instances/instance/service_bindings/binding"))
+	})
+
+	It("replays a recording file", func() {
+		buffer := &bytes.Buffer{}
+		recorder := recording.New(buffer)
+		brokertest.New(recorder.Wrap(handler), "username", "password").Catalog()
+		dir, err := ioutil.TempDir("", "replay")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "recording.jsonl")
+		Expect(ioutil.WriteFile(path, buffer.Bytes(), 0600)).To(Succeed())
+
+		mismatches, err := brokertest.New(handler, "username", "password").ReplayFile(path)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mismatches).To(BeEmpty())
+	})
+})
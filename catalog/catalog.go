@@ -0,0 +1,304 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package catalog builds the services of a broker's catalog from annotated Go
+// types, so that a catalog defined in code lives next to the parameter types
+// the broker decodes:
+//
+//	type Catalog struct {
+//		MySQL struct {
+//			catalog.Service `id:"3c1e..." name:"mysql" description:"MySQL databases" bindable:"true" tags:"sql,mysql" displayName:"MySQL"`
+//
+//			Small catalog.Plan `id:"8a2f..." name:"small" description:"A small database" free:"true"`
+//			Large struct {
+//				catalog.Plan `id:"e07b..." name:"large" description:"A large database" free:"false" bullets:"100 GB|Daily backups"`
+//				Provision ProvisionParameters `schema:"instance_create"`
+//			}
+//		}
+//	}
+//
+//	services, err := catalog.Generate(Catalog{})
+//
+// Each field whose type embeds Service is a service, and each of its fields
+// which is, or embeds, a Plan is one of its plans. Plan fields tagged with
+// schema:"instance_create", "instance_update" or "binding_create" give the
+// parameter schemas of the plan, generated by the schema package. Services
+// and plans keep the order of their fields.
+//
+// The tags of an embedded Service are id, name, description, bindable,
+// instances_retrievable, bindings_retrievable, plan_updateable, tags and
+// requires (comma separated), displayName, imageUrl, longDescription,
+// providerDisplayName, documentationUrl, supportUrl and shareable. Those of a
+// Plan are id, name, description, free, bindable, plan_updateable,
+// binding_rotatable, displayName and bullets (separated by |).
+package catalog
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/schema"
+)
+
+// Service marks a struct as a service of the catalog. Its annotations are the
+// tags of the embedded field.
+type Service struct{}
+
+// Plan marks a field as a plan of its service. Its annotations are the tags of
+// the field, or of the embedded Plan when the field is a struct.
+type Plan struct{}
+
+var (
+	serviceType = reflect.TypeOf(Service{})
+	planType    = reflect.TypeOf(Plan{})
+)
+
+// Generate returns the services described by v, a struct or a pointer to one
+// whose fields are services. A struct which itself embeds Service gives a
+// single service.
+func Generate(v interface{}) ([]domain.Service, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("the catalog must be described by a struct, not %v", t)
+	}
+
+	if _, ok := embedded(t, serviceType); ok {
+		service, err := generateService(t)
+		if err != nil {
+			return nil, err
+		}
+		return []domain.Service{service}, nil
+	}
+
+	var services []domain.Service
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() != reflect.Struct {
+			continue
+		}
+		if _, ok := embedded(field.Type, serviceType); !ok {
+			continue
+		}
+		service, err := generateService(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field.Name, err)
+		}
+		services = append(services, service)
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("%s has no fields embedding catalog.Service", t)
+	}
+	return services, nil
+}
+
+// MustGenerate is like Generate but panics on error.
+func MustGenerate(v interface{}) []domain.Service {
+	services, err := Generate(v)
+	if err != nil {
+		panic(err)
+	}
+	return services
+}
+
+// embedded returns the field of t which embeds marker.
+func embedded(t reflect.Type, marker reflect.Type) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Anonymous && field.Type == marker {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func generateService(t reflect.Type) (domain.Service, error) {
+	marker, _ := embedded(t, serviceType)
+	tags := tagReader{tag: marker.Tag}
+
+	service := domain.Service{
+		ID:                   tags.required("id"),
+		Name:                 tags.required("name"),
+		Description:          tags.required("description"),
+		Bindable:             tags.bool("bindable"),
+		InstancesRetrievable: tags.bool("instances_retrievable"),
+		BindingsRetrievable:  tags.bool("bindings_retrievable"),
+		PlanUpdatable:        tags.bool("plan_updateable"),
+		Tags:                 tags.list("tags", ","),
+	}
+	for _, permission := range tags.list("requires", ",") {
+		service.Requires = append(service.Requires, domain.RequiredPermission(permission))
+	}
+
+	metadata := domain.ServiceMetadata{
+		DisplayName:         tags.string("displayName"),
+		ImageUrl:            tags.string("imageUrl"),
+		LongDescription:     tags.string("longDescription"),
+		ProviderDisplayName: tags.string("providerDisplayName"),
+		DocumentationUrl:    tags.string("documentationUrl"),
+		SupportUrl:          tags.string("supportUrl"),
+		Shareable:           tags.optionalBool("shareable"),
+	}
+	if !reflect.DeepEqual(metadata, domain.ServiceMetadata{}) {
+		service.Metadata = &metadata
+	}
+	if tags.err != nil {
+		return domain.Service{}, tags.err
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			continue
+		}
+		plan, ok, err := generatePlan(field)
+		if err != nil {
+			return domain.Service{}, fmt.Errorf("plan %s: %s", field.Name, err)
+		}
+		if ok {
+			service.Plans = append(service.Plans, plan)
+		}
+	}
+	if len(service.Plans) == 0 {
+		return domain.Service{}, fmt.Errorf("service %q has no plans", service.Name)
+	}
+	return service, nil
+}
+
+// generatePlan returns the plan described by field, and false if field is not
+// a plan.
+func generatePlan(field reflect.StructField) (domain.ServicePlan, bool, error) {
+	tag := field.Tag
+	if field.Type != planType {
+		if field.Type.Kind() != reflect.Struct {
+			return domain.ServicePlan{}, false, nil
+		}
+		marker, ok := embedded(field.Type, planType)
+		if !ok {
+			return domain.ServicePlan{}, false, nil
+		}
+		tag = marker.Tag
+	}
+	tags := tagReader{tag: tag}
+
+	plan := domain.ServicePlan{
+		ID:               tags.required("id"),
+		Name:             tags.required("name"),
+		Description:      tags.required("description"),
+		Free:             tags.optionalBool("free"),
+		Bindable:         tags.optionalBool("bindable"),
+		PlanUpdatable:    tags.optionalBool("plan_updateable"),
+		BindingRotatable: tags.bool("binding_rotatable"),
+	}
+	metadata := domain.ServicePlanMetadata{
+		DisplayName: tags.string("displayName"),
+		Bullets:     tags.list("bullets", "|"),
+	}
+	if metadata.DisplayName != "" || len(metadata.Bullets) > 0 {
+		plan.Metadata = &metadata
+	}
+	if tags.err != nil {
+		return domain.ServicePlan{}, false, tags.err
+	}
+
+	if field.Type != planType {
+		schemas, err := planSchemas(field.Type)
+		if err != nil {
+			return domain.ServicePlan{}, false, err
+		}
+		plan.Schemas = schemas
+	}
+	return plan, true, nil
+}
+
+func planSchemas(t reflect.Type) (*domain.ServiceSchemas, error) {
+	builder := schema.NewBuilder()
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		kind, ok := field.Tag.Lookup("schema")
+		if !ok {
+			continue
+		}
+		parameters := reflect.Zero(field.Type).Interface()
+		switch kind {
+		case "instance_create":
+			builder.InstanceCreate(parameters)
+		case "instance_update":
+			builder.InstanceUpdate(parameters)
+		case "binding_create":
+			builder.BindingCreate(parameters)
+		default:
+			return nil, fmt.Errorf("field %s: unknown schema %q, expected instance_create, instance_update or binding_create", field.Name, kind)
+		}
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	return builder.Build()
+}
+
+// tagReader reads the annotations of a service or plan, keeping the first
+// error so that they can be read in a single expression.
+type tagReader struct {
+	tag reflect.StructTag
+	err error
+}
+
+func (r *tagReader) string(key string) string {
+	return r.tag.Get(key)
+}
+
+func (r *tagReader) required(key string) string {
+	value := r.tag.Get(key)
+	if value == "" && r.err == nil {
+		r.err = fmt.Errorf("the %s tag is required", key)
+	}
+	return value
+}
+
+func (r *tagReader) optionalBool(key string) *bool {
+	value, ok := r.tag.Lookup(key)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("the %s tag must be true or false, not %q", key, value)
+	}
+	return &parsed
+}
+
+func (r *tagReader) bool(key string) bool {
+	value := r.optionalBool(key)
+	return value != nil && *value
+}
+
+func (r *tagReader) list(key, separator string) []string {
+	value := r.tag.Get(key)
+	if value == "" {
+		return nil
+	}
+	items := strings.Split(value, separator)
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/catalog"
+	"github.com/sharma-tapas/brokerapi/cataloglint"
+)
+
+type ProvisionParameters struct {
+	Size string `json:"size" jsonschema:"required,enum=small|large"`
+}
+
+type Catalog struct {
+	MySQL struct {
+		catalog.Service `id:"mysql-id" name:"mysql" description:"MySQL databases" bindable:"true" plan_updateable:"true" tags:"sql, mysql" requires:"syslog_drain" displayName:"MySQL" documentationUrl:"https://example.com/docs" shareable:"false"`
+
+		Small catalog.Plan `id:"small-id" name:"small" description:"A small database" free:"true"`
+		Large struct {
+			catalog.Plan `id:"large-id" name:"large" description:"A large database" free:"false" bindable:"false" displayName:"Large" bullets:"100 GB, SSD|Daily backups"`
+			Provision    ProvisionParameters `schema:"instance_create"`
+		}
+	}
+	Redis struct {
+		catalog.Service `id:"redis-id" name:"redis" description:"Redis caches"`
+
+		Shared catalog.Plan `id:"shared-id" name:"shared" description:"A shared cache"`
+	}
+	Version string
+}
+
+var _ = Describe("Generate", func() {
+	It("describes a service for each annotated field", func() {
+		services, err := catalog.Generate(&Catalog{})
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := json.Marshal(services)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encoded).To(MatchJSON(`[
+			{
+				"id": "mysql-id",
+				"name": "mysql",
+				"description": "MySQL databases",
+				"bindable": true,
+				"plan_updateable": true,
+				"tags": ["sql", "mysql"],
+				"requires": ["syslog_drain"],
+				"metadata": {"displayName": "MySQL", "documentationUrl": "https://example.com/docs", "shareable": false},
+				"plans": [
+					{"id": "small-id", "name": "small", "description": "A small database", "free": true},
+					{
+						"id": "large-id",
+						"name": "large",
+						"description": "A large database",
+						"free": false,
+						"bindable": false,
+						"metadata": {"displayName": "Large", "bullets": ["100 GB, SSD", "Daily backups"]},
+						"schemas": {
+							"service_instance": {
+								"create": {"parameters": {
+									"$schema": "http://json-schema.org/draft-04/schema#",
+									"type": "object",
+									"properties": {"size": {"type": "string", "enum": ["small", "large"]}},
+									"required": ["size"]
+								}},
+								"update": {"parameters": null}
+							},
+							"service_binding": {"create": {"parameters": null}}
+						}
+					}
+				]
+			},
+			{
+				"id": "redis-id",
+				"name": "redis",
+				"description": "Redis caches",
+				"bindable": false,
+				"plan_updateable": false,
+				"plans": [{"id": "shared-id", "name": "shared", "description": "A shared cache"}]
+			}
+		]`))
+	})
+
+	It("produces a catalog without lint errors", func() {
+		services := catalog.MustGenerate(Catalog{})
+		diagnostics := cataloglint.Lint(services)
+		Expect(cataloglint.HasErrors(diagnostics)).To(BeFalse(), "%v", diagnostics)
+	})
+
+	It("accepts a single service", func() {
+		services, err := catalog.Generate(struct {
+			catalog.Service `id:"id" name:"service" description:"A service"`
+			Plan            catalog.Plan `id:"plan-id" name:"plan" description:"A plan"`
+		}{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].Plans[0].ID).To(Equal("plan-id"))
+	})
+
+	It("rejects services without the required annotations", func() {
+		_, err := catalog.Generate(struct {
+			Service struct {
+				catalog.Service `id:"id" name:"service"`
+				Plan            catalog.Plan `id:"plan-id" name:"plan" description:"A plan"`
+			}
+		}{})
+		Expect(err).To(MatchError("Service: the description tag is required"))
+	})
+
+	It("rejects services without plans", func() {
+		_, err := catalog.Generate(struct {
+			catalog.Service `id:"id" name:"service" description:"A service"`
+		}{})
+		Expect(err).To(MatchError(`service "service" has no plans`))
+	})
+
+	It("rejects malformed annotations", func() {
+		_, err := catalog.Generate(struct {
+			catalog.Service `id:"id" name:"service" description:"A service"`
+			Plan            catalog.Plan `id:"plan-id" name:"plan" description:"A plan" free:"yes"`
+		}{})
+		Expect(err).To(MatchError(`plan Plan: the free tag must be true or false, not "yes"`))
+
+		_, err = catalog.Generate(struct {
+			catalog.Service `id:"id" name:"service" description:"A service"`
+			Plan            struct {
+				catalog.Plan `id:"plan-id" name:"plan" description:"A plan"`
+				Bind         ProvisionParameters `schema:"bind"`
+			}
+		}{})
+		Expect(err).To(MatchError(ContainSubstring(`unknown schema "bind"`)))
+	})
+
+	It("rejects types which describe no services", func() {
+		_, err := catalog.Generate(struct{ Name string }{})
+		Expect(err).To(MatchError(ContainSubstring("has no fields embedding catalog.Service")))
+
+		_, err = catalog.Generate("catalog")
+		Expect(err).To(MatchError("the catalog must be described by a struct, not string"))
+	})
+})
@@ -0,0 +1,263 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/cataloglint"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/internal/yamljson"
+	"github.com/sharma-tapas/brokerapi/interpolate"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultFilePollInterval = 10 * time.Second
+
+var catalogExtensions = map[string]bool{".json": true, ".yml": true, ".yaml": true}
+
+// FileProvider serves the catalog held in a file, or in the files of a
+// directory, and reloads it when they change or the process receives SIGHUP.
+// A broker returns its services from Services:
+//
+//	func (b *Broker) Services(ctx context.Context) ([]domain.Service, error) {
+//		return b.catalog.Services(ctx)
+//	}
+type FileProvider struct {
+	path         string
+	logger       lager.Logger
+	pollInterval time.Duration
+
+	mutex        sync.RWMutex
+	services     []domain.Service
+	lastChecksum [sha256.Size]byte
+}
+
+// NewFileProvider reads the catalog at path, which is either a file or a
+// directory whose .json, .yml and .yaml files are read in name order. Each
+// file is in the format of the GET /v2/catalog response body, in JSON or
+// YAML, and the services of all files make up the catalog. References to
+// environment variables and secret files are substituted as described in the
+// interpolate package, so plan IDs can differ between environments. The
+// catalog must be free of cataloglint errors.
+func NewFileProvider(path string, logger lager.Logger) (*FileProvider, error) {
+	p := &FileProvider{
+		path:         path,
+		logger:       logger.Session("catalog-file-provider", lager.Data{"path": path}),
+		pollInterval: defaultFilePollInterval,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WithPollInterval sets how often the catalog files are checked for changes.
+func (p *FileProvider) WithPollInterval(interval time.Duration) *FileProvider {
+	p.pollInterval = interval
+	return p
+}
+
+// Services returns the catalog loaded last.
+func (p *FileProvider) Services(ctx context.Context) ([]domain.Service, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.services, nil
+}
+
+// Reload reads the catalog files and, if they or the values substituted into
+// them have changed since the last successful reload, replaces the catalog. When they cannot be read, cannot
+// be parsed or have lint errors, the previous catalog is kept.
+func (p *FileProvider) Reload() error {
+	files, err := catalogFiles(p.path)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.New()
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		if contents[i], err = readCatalogFile(file); err != nil {
+			return err
+		}
+		fmt.Fprintf(checksum, "%s\x00%d\x00", file, len(contents[i]))
+		checksum.Write(contents[i])
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], checksum.Sum(nil))
+
+	p.mutex.RLock()
+	unchanged := sum == p.lastChecksum
+	p.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	var services []domain.Service
+	for i, file := range files {
+		fileServices, err := parseCatalogFile(file, contents[i])
+		if err != nil {
+			return err
+		}
+		services = append(services, fileServices...)
+	}
+	diagnostics := cataloglint.Lint(services)
+	if cataloglint.HasErrors(diagnostics) {
+		return lintError(diagnostics)
+	}
+
+	p.mutex.Lock()
+	p.services = services
+	p.lastChecksum = sum
+	p.mutex.Unlock()
+	p.logger.Info("catalog-reloaded", lager.Data{"services": len(services)})
+	return nil
+}
+
+// Run reloads the catalog whenever its files change or SIGHUP is received,
+// until ctx is cancelled.
+func (p *FileProvider) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			p.reloadAndLog()
+		case <-ticker.C:
+			p.reloadAndLog()
+		}
+	}
+}
+
+func (p *FileProvider) reloadAndLog() {
+	if err := p.Reload(); err != nil {
+		p.logger.Error("reloading-catalog-failed", err)
+	}
+}
+
+// ReadServices reads the catalog at path as NewFileProvider does, for tools
+// working on catalog files, such as comparing two versions of a catalog with
+// domain.DiffCatalogs before rolling one out. It does not lint the catalog.
+func ReadServices(path string) ([]domain.Service, error) {
+	files, err := catalogFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []domain.Service
+	for _, file := range files {
+		contents, err := readCatalogFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileServices, err := parseCatalogFile(file, contents)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, fileServices...)
+	}
+	return services, nil
+}
+
+func catalogFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && catalogExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no catalog files found in %s", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readCatalogFile(file string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := interpolate.Expand(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not interpolate catalog file %s: %s", file, err)
+	}
+	return contents, nil
+}
+
+// parseCatalogFile parses JSON and YAML alike. YAML is converted to JSON
+// first, so that the json tags and unmarshalers of the domain types apply.
+func parseCatalogFile(path string, contents []byte) ([]domain.Service, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, fmt.Errorf("could not parse catalog file %s: %s", path, err)
+	}
+	asJSON, err := json.Marshal(yamljson.JSONCompatible(document))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse catalog file %s: %s", path, err)
+	}
+
+	var catalog struct {
+		Services []domain.Service `json:"services"`
+	}
+	if err := json.Unmarshal(asJSON, &catalog); err != nil {
+		return nil, fmt.Errorf("could not parse catalog file %s: %s", path, err)
+	}
+	return catalog.Services, nil
+}
+
+func lintError(diagnostics []cataloglint.Diagnostic) error {
+	var messages []string
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == cataloglint.Error {
+			messages = append(messages, diagnostic.String())
+		}
+	}
+	return errors.New("invalid catalog: " + strings.Join(messages, "; "))
+}
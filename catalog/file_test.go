@@ -0,0 +1,187 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/catalog"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+const yamlCatalog = `
+services:
+- id: mysql-id
+  name: mysql
+  description: MySQL databases
+  bindable: true
+  metadata:
+    displayName: MySQL
+  plans:
+  - id: small-id
+    name: small
+    description: A small database
+    free: true
+`
+
+const jsonCatalog = `{"services": [{"id": "redis-id", "name": "redis", "description": "Redis", "bindable": false,
+	"plans": [{"id": "cache-id", "name": "cache", "description": "A cache"}]}]}`
+
+var _ = Describe("FileProvider", func() {
+	var (
+		dir    string
+		logger *lagertest.TestLogger
+	)
+
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	serviceNames := func(provider *catalog.FileProvider) []string {
+		services, err := provider.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "catalog")
+		Expect(err).NotTo(HaveOccurred())
+		logger = lagertest.NewTestLogger("catalog")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("reads YAML catalogs", func() {
+		provider, err := catalog.NewFileProvider(writeFile("catalog.yml", yamlCatalog), logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		services, err := provider.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].ID).To(Equal("mysql-id"))
+		Expect(services[0].Metadata.DisplayName).To(Equal("MySQL"))
+		Expect(services[0].Plans[0].Free).To(Equal(domain.FreeValue(true)))
+	})
+
+	It("reads the catalog files of a directory in name order", func() {
+		writeFile("b.json", jsonCatalog)
+		writeFile("a.yaml", yamlCatalog)
+		writeFile("README.md", "not a catalog")
+
+		provider, err := catalog.NewFileProvider(dir, logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serviceNames(provider)).To(Equal([]string{"mysql", "redis"}))
+	})
+
+	It("substitutes environment variables", func() {
+		Expect(os.Setenv("CATALOG_TEST_PLAN_ID", "plan-from-env")).To(Succeed())
+		defer os.Unsetenv("CATALOG_TEST_PLAN_ID")
+		path := writeFile("catalog.yml", strings.Replace(yamlCatalog, "small-id", "${CATALOG_TEST_PLAN_ID}", 1))
+
+		provider, err := catalog.NewFileProvider(path, logger)
+		Expect(err).NotTo(HaveOccurred())
+		services, _ := provider.Services(context.Background())
+		Expect(services[0].Plans[0].ID).To(Equal("plan-from-env"))
+	})
+
+	It("fails when a referenced variable is missing", func() {
+		path := writeFile("catalog.yml", strings.Replace(yamlCatalog, "small-id", "${CATALOG_TEST_MISSING}", 1))
+
+		_, err := catalog.NewFileProvider(path, logger)
+		Expect(err).To(MatchError(ContainSubstring("missing environment variables CATALOG_TEST_MISSING")))
+	})
+
+	It("rejects catalogs with lint errors", func() {
+		_, err := catalog.NewFileProvider(writeFile("catalog.json", `{"services": [{"id": "id", "name": "No Plans"}]}`), logger)
+		Expect(err).To(MatchError(ContainSubstring("invalid catalog")))
+	})
+
+	It("rejects files which cannot be parsed", func() {
+		_, err := catalog.NewFileProvider(writeFile("catalog.yml", "services: [\n"), logger)
+		Expect(err).To(MatchError(ContainSubstring("could not parse catalog file")))
+	})
+
+	It("rejects directories without catalog files", func() {
+		_, err := catalog.NewFileProvider(dir, logger)
+		Expect(err).To(MatchError(ContainSubstring("no catalog files found")))
+	})
+
+	Describe("Reload", func() {
+		var (
+			path     string
+			provider *catalog.FileProvider
+		)
+
+		BeforeEach(func() {
+			path = writeFile("catalog.yml", yamlCatalog)
+			var err error
+			provider, err = catalog.NewFileProvider(path, logger)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("serves the new catalog once the file changes", func() {
+			writeFile("catalog.yml", jsonCatalog)
+			Expect(provider.Reload()).To(Succeed())
+			Expect(serviceNames(provider)).To(Equal([]string{"redis"}))
+			Expect(logger.LogMessages()).To(ContainElement("catalog.catalog-file-provider.catalog-reloaded"))
+		})
+
+		It("keeps the previous catalog when the new one is invalid", func() {
+			writeFile("catalog.yml", `{"services": []}`)
+			Expect(provider.Reload()).To(MatchError(ContainSubstring("no services")))
+			Expect(serviceNames(provider)).To(Equal([]string{"mysql"}))
+		})
+
+		It("polls the file while running", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go provider.WithPollInterval(10 * time.Millisecond).Run(ctx)
+
+			writeFile("catalog.yml", jsonCatalog)
+			Eventually(func() []string { return serviceNames(provider) }).Should(Equal([]string{"redis"}))
+		})
+	})
+
+	Describe("ReadServices", func() {
+		It("reads the services of every catalog file without serving them", func() {
+			writeFile("a.yml", yamlCatalog)
+			writeFile("b.json", jsonCatalog)
+
+			services, err := catalog.ReadServices(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(HaveLen(2))
+			Expect(services[0].ID).To(Equal("mysql-id"))
+			Expect(services[1].ID).To(Equal("redis-id"))
+		})
+	})
+})
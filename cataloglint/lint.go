@@ -0,0 +1,254 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cataloglint checks a service broker catalog for problems which the
+// platform would reject, and for gaps which make the offering harder to use in
+// a marketplace. Each Diagnostic names the field it is about, so that it can be
+// traced back to the catalog source.
+package cataloglint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity string
+
+const (
+	// Error marks a catalog which breaks the Open Service Broker API.
+	Error Severity = "error"
+	// Warning marks a catalog which is valid but incomplete.
+	Warning Severity = "warning"
+)
+
+// Diagnostic is a single problem found in a catalog.
+type Diagnostic struct {
+	// File is the catalog file, when the catalog was read by LintFile.
+	File string
+	// Field is the path of the offending field, such as
+	// "services[0].plans[1].metadata.displayName".
+	Field    string
+	Severity Severity
+	// Rule identifies the check which failed, such as "duplicate-plan-name".
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	location := d.Field
+	if d.File != "" {
+		location = d.File + ": " + location
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", location, d.Severity, d.Message, d.Rule)
+}
+
+// HasErrors reports whether any of diagnostics is an Error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// LintFile reads a catalog file, in the format of the GET /v2/catalog
+// response body, and lints it.
+func LintFile(path string) ([]Diagnostic, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog struct {
+		Services []domain.Service `json:"services"`
+	}
+	if err := json.Unmarshal(contents, &catalog); err != nil {
+		return nil, fmt.Errorf("could not parse catalog file %s: %s", path, err)
+	}
+
+	diagnostics := Lint(catalog.Services)
+	for i := range diagnostics {
+		diagnostics[i].File = path
+	}
+	return diagnostics, nil
+}
+
+// cliFriendlyName matches the names the Open Service Broker API recommends for
+// services and plans: lowercase, without spaces.
+var cliFriendlyName = regexp.MustCompile(`^[a-z0-9]+([-_.][a-z0-9]+)*$`)
+
+type linter struct {
+	diagnostics []Diagnostic
+	ids         map[string]string
+	names       map[string]string
+}
+
+// Lint checks services and returns the problems found, in catalog order.
+func Lint(services []domain.Service) []Diagnostic {
+	l := &linter{
+		ids:   map[string]string{},
+		names: map[string]string{},
+	}
+	if len(services) == 0 {
+		l.report("services", Error, "no-services", "the catalog has no services")
+	}
+	for i, service := range services {
+		l.lintService(fmt.Sprintf("services[%d]", i), service)
+	}
+	return l.diagnostics
+}
+
+func (l *linter) report(field string, severity Severity, rule, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Field:    field,
+		Severity: severity,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		l.report(field, Error, "missing-field", "the field is required")
+	}
+}
+
+// uniqueID checks that id is not used by any other service or plan, as
+// platforms key both by ID alone.
+func (l *linter) uniqueID(field, id string) {
+	if id == "" {
+		return
+	}
+	if other, ok := l.ids[id]; ok {
+		l.report(field, Error, "duplicate-id", "id %q is already used by %s", id, other)
+		return
+	}
+	l.ids[id] = field
+}
+
+func (l *linter) lintService(field string, service domain.Service) {
+	l.required(field+".id", service.ID)
+	l.uniqueID(field+".id", service.ID)
+	l.required(field+".name", service.Name)
+	l.required(field+".description", service.Description)
+
+	if service.Name != "" {
+		if other, ok := l.names[service.Name]; ok {
+			l.report(field+".name", Error, "duplicate-service-name", "name %q is already used by %s", service.Name, other)
+		} else {
+			l.names[service.Name] = field
+		}
+		if !cliFriendlyName.MatchString(service.Name) {
+			l.report(field+".name", Warning, "cli-unfriendly-name", "name %q should be lowercase without spaces", service.Name)
+		}
+	}
+
+	tags := map[string]bool{}
+	for i, tag := range service.Tags {
+		if tags[tag] {
+			l.report(fmt.Sprintf("%s.tags[%d]", field, i), Warning, "duplicate-tag", "tag %q is listed more than once", tag)
+		}
+		tags[tag] = true
+	}
+
+	for i, permission := range service.Requires {
+		switch permission {
+		case domain.PermissionRouteForwarding, domain.PermissionSyslogDrain, domain.PermissionVolumeMount, domain.PermissionApp:
+		default:
+			l.report(fmt.Sprintf("%s.requires[%d]", field, i), Error, "unknown-permission", "%q is not a known permission", permission)
+		}
+	}
+
+	if client := service.DashboardClient; client != nil {
+		l.required(field+".dashboard_client.id", client.ID)
+		l.required(field+".dashboard_client.secret", client.Secret)
+		l.url(field+".dashboard_client.redirect_uri", client.RedirectURI)
+	}
+
+	if service.Metadata == nil || service.Metadata.DisplayName == "" {
+		l.report(field+".metadata.displayName", Warning, "missing-display-name", "the service has no display name for the marketplace")
+	}
+	if metadata := service.Metadata; metadata != nil {
+		l.url(field+".metadata.imageUrl", metadata.ImageUrl)
+		l.url(field+".metadata.documentationUrl", metadata.DocumentationUrl)
+		l.url(field+".metadata.supportUrl", metadata.SupportUrl)
+	}
+
+	if len(service.Plans) == 0 {
+		l.report(field+".plans", Error, "no-plans", "the service has no plans")
+	}
+	planNames := map[string]string{}
+	for i, plan := range service.Plans {
+		planField := fmt.Sprintf("%s.plans[%d]", field, i)
+		if other, ok := planNames[plan.Name]; ok {
+			l.report(planField+".name", Error, "duplicate-plan-name", "name %q is already used by %s", plan.Name, other)
+		} else if plan.Name != "" {
+			planNames[plan.Name] = planField
+		}
+		l.lintPlan(planField, plan)
+	}
+}
+
+func (l *linter) lintPlan(field string, plan domain.ServicePlan) {
+	l.required(field+".id", plan.ID)
+	l.uniqueID(field+".id", plan.ID)
+	l.required(field+".name", plan.Name)
+	l.required(field+".description", plan.Description)
+
+	if plan.Name != "" && !cliFriendlyName.MatchString(plan.Name) {
+		l.report(field+".name", Warning, "cli-unfriendly-name", "name %q should be lowercase without spaces", plan.Name)
+	}
+
+	if plan.Metadata == nil || plan.Metadata.DisplayName == "" {
+		l.report(field+".metadata.displayName", Warning, "missing-display-name", "the plan has no display name for the marketplace")
+	}
+	if plan.Metadata != nil {
+		for i, cost := range plan.Metadata.Costs {
+			costField := fmt.Sprintf("%s.metadata.costs[%d]", field, i)
+			if cost.Unit == "" {
+				l.report(costField+".unit", Warning, "incomplete-cost", "the cost has no unit")
+			}
+			if len(cost.Amount) == 0 {
+				l.report(costField+".amount", Warning, "incomplete-cost", "the cost has no amount")
+			}
+		}
+	}
+	if plan.Free != nil && !*plan.Free && (plan.Metadata == nil || len(plan.Metadata.Costs) == 0) {
+		l.report(field+".metadata.costs", Warning, "missing-costs", "the plan is not free but lists no costs")
+	}
+
+	if schemas := plan.Schemas; schemas != nil {
+		l.schema(field+".schemas.service_instance.create.parameters", schemas.Instance.Create.Parameters)
+		l.schema(field+".schemas.service_instance.update.parameters", schemas.Instance.Update.Parameters)
+		l.schema(field+".schemas.service_binding.create.parameters", schemas.Binding.Create.Parameters)
+	}
+}
+
+func (l *linter) url(field, value string) {
+	if value == "" {
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		l.report(field, Warning, "invalid-url", "%q is not an absolute URL", value)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cataloglint_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/cataloglint"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("Lint", func() {
+	var services []domain.Service
+
+	BeforeEach(func() {
+		services = []domain.Service{{
+			ID:          "service-id",
+			Name:        "database",
+			Description: "a database",
+			Metadata:    &domain.ServiceMetadata{DisplayName: "Database"},
+			Plans: []domain.ServicePlan{{
+				ID:          "plan-id",
+				Name:        "small",
+				Description: "a small database",
+				Metadata:    &domain.ServicePlanMetadata{DisplayName: "Small"},
+			}},
+		}}
+	})
+
+	// diagnosticsFor returns the field, severity and rule of each diagnostic.
+	diagnosticsFor := func(services []domain.Service) []string {
+		var found []string
+		for _, diagnostic := range cataloglint.Lint(services) {
+			found = append(found, diagnostic.Field+" "+string(diagnostic.Severity)+" "+diagnostic.Rule)
+		}
+		return found
+	}
+
+	It("accepts a complete catalog", func() {
+		Expect(cataloglint.Lint(services)).To(BeEmpty())
+	})
+
+	It("reports an empty catalog", func() {
+		Expect(diagnosticsFor(nil)).To(ConsistOf("services error no-services"))
+	})
+
+	It("reports missing required fields", func() {
+		services[0].Description = ""
+		services[0].Plans[0].ID = ""
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[0].description error missing-field",
+			"services[0].plans[0].id error missing-field",
+		))
+	})
+
+	It("reports missing display names", func() {
+		services[0].Metadata = nil
+		services[0].Plans[0].Metadata.DisplayName = ""
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[0].metadata.displayName warning missing-display-name",
+			"services[0].plans[0].metadata.displayName warning missing-display-name",
+		))
+	})
+
+	It("reports duplicate plan names within a service", func() {
+		plan := services[0].Plans[0]
+		plan.ID = "other-plan-id"
+		services[0].Plans = append(services[0].Plans, plan)
+
+		diagnostics := cataloglint.Lint(services)
+		Expect(diagnostics).To(HaveLen(1))
+		Expect(diagnostics[0].Field).To(Equal("services[0].plans[1].name"))
+		Expect(diagnostics[0].Rule).To(Equal("duplicate-plan-name"))
+		Expect(diagnostics[0].Message).To(Equal(`name "small" is already used by services[0].plans[0]`))
+	})
+
+	It("reports IDs which are not unique across services and plans", func() {
+		other := services[0]
+		other.Name = "cache"
+		other.Plans = []domain.ServicePlan{services[0].Plans[0]}
+		other.Plans[0].ID = "service-id"
+		services = append(services, other)
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[1].id error duplicate-id",
+			"services[1].plans[0].id error duplicate-id",
+		))
+	})
+
+	It("reports duplicate service names and names which are not CLI friendly", func() {
+		other := services[0]
+		other.ID = "other-service-id"
+		other.Plans = []domain.ServicePlan{{ID: "other-plan-id", Name: "Large Plan", Description: "large", Metadata: services[0].Plans[0].Metadata}}
+		services = append(services, other)
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[1].name error duplicate-service-name",
+			"services[1].plans[0].name warning cli-unfriendly-name",
+		))
+	})
+
+	It("reports unknown permissions, invalid URLs and incomplete dashboard clients", func() {
+		services[0].Requires = []domain.RequiredPermission{domain.PermissionSyslogDrain, "logging"}
+		services[0].Metadata.ImageUrl = "logo.png"
+		services[0].DashboardClient = &domain.ServiceDashboardClient{ID: "client", RedirectURI: "https://dashboard.example.com"}
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[0].requires[1] error unknown-permission",
+			"services[0].metadata.imageUrl warning invalid-url",
+			"services[0].dashboard_client.secret error missing-field",
+		))
+	})
+
+	It("reports paid plans without costs", func() {
+		services[0].Plans[0].Free = domain.FreeValue(false)
+
+		Expect(diagnosticsFor(services)).To(ConsistOf(
+			"services[0].plans[0].metadata.costs warning missing-costs",
+		))
+	})
+
+	Describe("schemas", func() {
+		lintSchema := func(parameters map[string]interface{}) []string {
+			services[0].Plans[0].Schemas = &domain.ServiceSchemas{
+				Instance: domain.ServiceInstanceSchema{
+					Create: domain.Schema{Parameters: parameters},
+				},
+			}
+			return diagnosticsFor(services)
+		}
+
+		const field = "services[0].plans[0].schemas.service_instance.create.parameters"
+
+		It("accepts a valid schema", func() {
+			Expect(lintSchema(map[string]interface{}{
+				"$schema": "http://json-schema.org/draft-04/schema#",
+				"type":    "object",
+				"properties": map[string]interface{}{
+					"size": map[string]interface{}{"type": "integer", "minimum": 1},
+					"tier": map[string]interface{}{"enum": []string{"gold", "silver"}},
+				},
+				"required": []string{"size"},
+			})).To(BeEmpty())
+		})
+
+		It("requires the schema version", func() {
+			Expect(lintSchema(map[string]interface{}{"type": "object"})).To(ConsistOf(
+				field + ".$schema error missing-schema-version",
+			))
+		})
+
+		It("reports invalid keywords with the path of the offending subschema", func() {
+			Expect(lintSchema(map[string]interface{}{
+				"$schema": "http://json-schema.org/draft-04/schema#",
+				"type":    "map",
+				"properties": map[string]interface{}{
+					"size": map[string]interface{}{"type": "integer", "minimum": "one"},
+					"name": "string",
+				},
+				"required": "size",
+				"enum":     []string{},
+			})).To(ConsistOf(
+				field+".type error invalid-schema",
+				field+".properties.size.minimum error invalid-schema",
+				field+".properties.name error invalid-schema",
+				field+".required error invalid-schema",
+				field+".enum error invalid-schema",
+			))
+		})
+	})
+
+	Describe("Diagnostic", func() {
+		It("formats the file, field and rule", func() {
+			diagnostic := cataloglint.Diagnostic{
+				File:     "catalog.json",
+				Field:    "services[0].name",
+				Severity: cataloglint.Error,
+				Rule:     "missing-field",
+				Message:  "the field is required",
+			}
+			Expect(diagnostic.String()).To(Equal("catalog.json: services[0].name: error: the field is required (missing-field)"))
+		})
+	})
+
+	Describe("HasErrors", func() {
+		It("ignores warnings", func() {
+			Expect(cataloglint.HasErrors([]cataloglint.Diagnostic{{Severity: cataloglint.Warning}})).To(BeFalse())
+			Expect(cataloglint.HasErrors([]cataloglint.Diagnostic{{Severity: cataloglint.Warning}, {Severity: cataloglint.Error}})).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("LintFile", func() {
+	It("accepts the fixture catalog", func() {
+		Expect(cataloglint.LintFile("../fixtures/catalog.json")).To(BeEmpty())
+	})
+
+	It("records the file in each diagnostic", func() {
+		dir, err := ioutil.TempDir("", "cataloglint")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "catalog.json")
+		Expect(ioutil.WriteFile(path, []byte(`{"services":[]}`), 0600)).To(Succeed())
+
+		diagnostics, err := cataloglint.LintFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diagnostics).To(HaveLen(1))
+		Expect(diagnostics[0].File).To(Equal(path))
+	})
+
+	It("returns an error for a file which is not a catalog", func() {
+		dir, err := ioutil.TempDir("", "cataloglint")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "catalog.json")
+		Expect(ioutil.WriteFile(path, []byte(`not json`), 0600)).To(Succeed())
+
+		_, err = cataloglint.LintFile(path)
+		Expect(err).To(MatchError(ContainSubstring("could not parse catalog file")))
+	})
+})
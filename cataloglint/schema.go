@@ -0,0 +1,196 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cataloglint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var jsonSchemaTypes = map[string]bool{
+	"array":   true,
+	"boolean": true,
+	"integer": true,
+	"null":    true,
+	"number":  true,
+	"object":  true,
+	"string":  true,
+}
+
+// schema checks that parameters is a structurally valid JSON Schema. It does
+// not implement a full meta-schema validation, but catches the mistakes which
+// make platforms reject or misrender a schema.
+func (l *linter) schema(field string, parameters map[string]interface{}) {
+	if parameters == nil {
+		return
+	}
+
+	// Normalise Go values, such as []string or int, to their JSON form.
+	var document interface{}
+	encoded, err := json.Marshal(parameters)
+	if err == nil {
+		err = json.Unmarshal(encoded, &document)
+	}
+	if err != nil {
+		l.report(field, Error, "invalid-schema", "the schema cannot be encoded as JSON: %s", err)
+		return
+	}
+
+	if version, ok := parameters["$schema"].(string); !ok || version == "" {
+		l.report(field+".$schema", Error, "missing-schema-version", "the schema must declare its JSON Schema version in $schema")
+	}
+	l.subschema(field, document)
+}
+
+func (l *linter) subschema(field string, value interface{}) {
+	if _, ok := value.(bool); ok {
+		return
+	}
+	node, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(field, Error, "invalid-schema", "a schema must be an object")
+		return
+	}
+
+	keywords := make([]string, 0, len(node))
+	for keyword := range node {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		value := node[keyword]
+		keywordField := field + "." + keyword
+		switch keyword {
+		case "type":
+			l.schemaType(keywordField, value)
+		case "properties", "patternProperties", "definitions":
+			l.schemaMap(keywordField, value)
+		case "items":
+			if items, ok := value.([]interface{}); ok {
+				for i, item := range items {
+					l.subschema(fmt.Sprintf("%s[%d]", keywordField, i), item)
+				}
+			} else {
+				l.subschema(keywordField, value)
+			}
+		case "additionalProperties", "additionalItems", "not":
+			l.subschema(keywordField, value)
+		case "allOf", "anyOf", "oneOf":
+			items, ok := value.([]interface{})
+			if !ok || len(items) == 0 {
+				l.report(keywordField, Error, "invalid-schema", "%s must be a non-empty array of schemas", keyword)
+				continue
+			}
+			for i, item := range items {
+				l.subschema(fmt.Sprintf("%s[%d]", keywordField, i), item)
+			}
+		case "required":
+			l.schemaRequired(keywordField, value)
+		case "enum":
+			if items, ok := value.([]interface{}); !ok || len(items) == 0 {
+				l.report(keywordField, Error, "invalid-schema", "enum must be a non-empty array")
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			if number, ok := value.(float64); !ok || number < 0 || number != float64(int64(number)) {
+				l.report(keywordField, Error, "invalid-schema", "%s must be a non-negative integer", keyword)
+			}
+		case "minimum", "maximum":
+			if _, ok := value.(float64); !ok {
+				l.report(keywordField, Error, "invalid-schema", "%s must be a number", keyword)
+			}
+		case "exclusiveMinimum", "exclusiveMaximum":
+			switch value.(type) {
+			case float64, bool:
+			default:
+				l.report(keywordField, Error, "invalid-schema", "%s must be a number or a boolean", keyword)
+			}
+		case "multipleOf":
+			if number, ok := value.(float64); !ok || number <= 0 {
+				l.report(keywordField, Error, "invalid-schema", "multipleOf must be a number greater than 0")
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				l.report(keywordField, Error, "invalid-schema", "pattern must be a string")
+				continue
+			}
+			// Go regular expressions are close to, but not the same as, the
+			// ECMA 262 dialect JSON Schema uses, so this is only a warning.
+			if _, err := regexp.Compile(pattern); err != nil {
+				l.report(keywordField, Warning, "invalid-pattern", "pattern %q may not be a valid regular expression: %s", pattern, err)
+			}
+		}
+	}
+}
+
+func (l *linter) schemaType(field string, value interface{}) {
+	var types []interface{}
+	switch value := value.(type) {
+	case string:
+		types = []interface{}{value}
+	case []interface{}:
+		types = value
+	}
+	if len(types) == 0 {
+		l.report(field, Error, "invalid-schema", "type must be a type name or a non-empty array of type names")
+		return
+	}
+	for _, t := range types {
+		name, _ := t.(string)
+		if !jsonSchemaTypes[name] {
+			l.report(field, Error, "invalid-schema", "%v is not a JSON Schema type", t)
+		}
+	}
+}
+
+func (l *linter) schemaMap(field string, value interface{}) {
+	properties, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(field, Error, "invalid-schema", "the value must be an object of schemas")
+		return
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.subschema(field+"."+name, properties[name])
+	}
+}
+
+func (l *linter) schemaRequired(field string, value interface{}) {
+	items, ok := value.([]interface{})
+	if !ok {
+		l.report(field, Error, "invalid-schema", "required must be an array of property names")
+		return
+	}
+	seen := map[string]bool{}
+	for i, item := range items {
+		name, ok := item.(string)
+		if !ok {
+			l.report(fmt.Sprintf("%s[%d]", field, i), Error, "invalid-schema", "required must only contain property names")
+			continue
+		}
+		if seen[name] {
+			l.report(fmt.Sprintf("%s[%d]", field, i), Error, "invalid-schema", "required lists %q more than once", name)
+		}
+		seen[name] = true
+	}
+}
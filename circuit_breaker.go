@@ -0,0 +1,159 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+const circuitOpenDescription = "the broker is unavailable, try again later"
+
+// WithCircuitBreaker fails requests fast with a 503 and a Retry-After header
+// once failureThreshold requests in a row have failed with a 500, 502, 503 or
+// 504, or panicked, rather than letting requests pile up while the backend of
+// the broker is down. After coolDown a single request is let through: the
+// circuit closes again if it succeeds, and stays open for another coolDown
+// otherwise. Dashboard, health and admin requests, and dry runs, are neither
+// counted nor turned away, so that the failures of operator tooling cannot
+// open the circuit of the broker API and operators can still reach the admin
+// API while it is open.
+func WithCircuitBreaker(failureThreshold int, coolDown time.Duration) Option {
+	return func(c *config) {
+		c.circuitBreaker = &circuitBreaker{threshold: failureThreshold, coolDown: coolDown}
+	}
+}
+
+type circuitBreaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	mutex    sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a request may be served, and when it may not, how
+// long until the circuit lets a request through again. probe is true for the
+// request let through after the cool-down.
+func (b *circuitBreaker) allow(now time.Time) (allowed, probe bool, retryAfter time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.open {
+		return true, false, 0
+	}
+	if elapsed := now.Sub(b.openedAt); elapsed < b.coolDown {
+		return false, false, b.coolDown - elapsed
+	}
+	if b.probing {
+		return false, false, b.coolDown
+	}
+	b.probing = true
+	return true, true, 0
+}
+
+// record counts the outcome of a request, and reports whether it opened or
+// closed the circuit.
+func (b *circuitBreaker) record(now time.Time, probe, failed bool) (opened, closed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if !failed {
+		closed = b.open && probe
+		b.failures = 0
+		b.open = b.open && !probe
+		return false, closed
+	}
+
+	b.failures++
+	if probe || (!b.open && b.failures >= b.threshold) {
+		opened = !b.open
+		b.open = true
+		b.openedAt = now
+	}
+	return opened, false
+}
+
+func (b *circuitBreaker) middleware(logger lager.Logger) mux.MiddlewareFunc {
+	logger = logger.Session("circuit-breaker")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if isDashboardRoute(req) || isHealthRoute(req) || isAdminRoute(req) || isDryRun(req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			allowed, probe, retryAfter := b.allow(time.Now())
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(ErrorResponse{Description: circuitOpenDescription})
+				return
+			}
+
+			// The outcome is recorded in a defer so that a probe whose
+			// handler panics is released, and counted as a failure, rather
+			// than holding the circuit open for good.
+			recorder := statusrecorder.New(w)
+			failed := true
+			defer func() {
+				opened, closed := b.record(time.Now(), probe, failed)
+				switch {
+				case opened:
+					logger.Error("circuit-opened", nil, lager.Data{"status": recorder.Status()})
+				case closed:
+					logger.Info("circuit-closed")
+				}
+			}()
+
+			next.ServeHTTP(recorder, req)
+			failed = isBackendFailure(recorder.Status())
+		})
+	}
+}
+
+// isBackendFailure reports whether status says the broker or its backend
+// failed. Other 5xx responses, such as the 501 of an extension the broker
+// does not implement, say nothing about its health.
+func isBackendFailure(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isDryRun reports whether the request was made with the dry_run=true
+// extension, which asks the broker what it would do without doing it.
+func isDryRun(req *http.Request) bool {
+	return req.URL.Query().Get("dry_run") == "true"
+}
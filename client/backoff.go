@@ -0,0 +1,93 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff is an exponential backoff between the attempts of a request: the
+// first retry waits Initial, and each one after waits Multiplier times longer
+// than the one before, up to Max. Zero fields other than Jitter take the
+// values of DefaultBackoff.
+type Backoff struct {
+	// Attempts is the number of times the request is made, the first one
+	// included.
+	Attempts   int
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+
+	// Jitter shortens each delay by a random fraction of up to Jitter, between
+	// 0 and 1, so that clients which failed together do not retry together.
+	Jitter float64
+}
+
+// DefaultBackoff makes five attempts, waiting about one second before the
+// first retry and at most 30 seconds between attempts.
+var DefaultBackoff = Backoff{
+	Attempts:   5,
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+func (b Backoff) withDefaults() Backoff {
+	if b.Attempts <= 0 {
+		b.Attempts = DefaultBackoff.Attempts
+	}
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultBackoff.Max
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = DefaultBackoff.Multiplier
+	}
+	return b
+}
+
+// delay returns how long to wait before the given retry, counting from 1.
+func (b Backoff) delay(retry int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 1; i < retry && delay < float64(b.Max); i++ {
+		delay *= b.Multiplier
+	}
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		delay -= delay * math.Min(b.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// sleep waits for delay, returning false when ctx is done first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
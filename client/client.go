@@ -0,0 +1,460 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client calls Open Service Broker API endpoints. A Client implements
+// domain.ServiceBroker, so a broker can pass requests on to another broker,
+// and errors returned by the other broker are returned as *Error values,
+// which unwrap to the *apiresponses.FailureResponse the handlers send on
+// unchanged.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// DefaultAPIVersion is the X-Broker-API-Version sent unless another is set
+// with WithAPIVersion.
+const DefaultAPIVersion = "2.14"
+
+const brokerErrorKey = "broker-error"
+
+var _ domain.ServiceBroker = (*Client)(nil)
+
+// Client calls the endpoints of a broker. It is safe for concurrent use.
+type Client struct {
+	url        *url.URL
+	username   string
+	password   string
+	httpClient *http.Client
+	transport  http.RoundTripper
+	timeout    time.Duration
+	apiVersion string
+	identity   *OriginatingIdentity
+
+	orphanMitigation *OrphanMitigation
+	retries          *Backoff
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the client used to make requests. By default requests
+// are made through a transport with the DefaultTransportConfig.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIVersion sets the X-Broker-API-Version of the requests.
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		c.apiVersion = version
+	}
+}
+
+// WithOriginatingIdentity sets the identity sent with requests whose context
+// carries none. See OriginatingIdentity for the identities taken from the
+// context.
+func WithOriginatingIdentity(identity OriginatingIdentity) Option {
+	return func(c *Client) {
+		c.identity = &identity
+	}
+}
+
+// WithRetries retries the requests which are safe to repeat, those fetching
+// the catalog, instances, bindings and last operations, when they fail in
+// transit or the broker responds with 408, 429, 502, 503 or 504. Retries wait
+// as long as the Retry-After header of the response asks, up to the Max of
+// backoff, or otherwise as long as backoff says. A request whose context
+// would be done before the next attempt fails straight away.
+func WithRetries(backoff Backoff) Option {
+	return func(c *Client) {
+		backoff = backoff.withDefaults()
+		c.retries = &backoff
+	}
+}
+
+// New returns a Client for the broker at brokerURL, such as
+// "https://broker.example.com", authenticating with basic auth.
+func New(brokerURL, username, password string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker URL: %s", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid broker URL %q: the scheme must be http or https", brokerURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	c := &Client{
+		url:        parsed,
+		username:   username,
+		password:   password,
+		transport:  defaultTransport,
+		apiVersion: DefaultAPIVersion,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Transport: c.transport, Timeout: c.timeout}
+	}
+	return c, nil
+}
+
+func (c *Client) Services(ctx context.Context) ([]domain.Service, error) {
+	var response apiresponses.CatalogResponse
+	if _, err := c.do(ctx, http.MethodGet, "/v2/catalog", nil, nil, &response, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return response.Services, nil
+}
+
+func (c *Client) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	var response apiresponses.ProvisioningResponse
+	status, err := c.do(ctx, http.MethodPut, instancePath(instanceID), acceptsIncomplete(asyncAllowed), details, &response,
+		http.StatusOK, http.StatusCreated, http.StatusAccepted)
+	if err != nil {
+		c.mitigateInstanceOrphan(ctx, status, err, instanceID, details)
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	return domain.ProvisionedServiceSpec{
+		IsAsync:       status == http.StatusAccepted,
+		DashboardURL:  response.DashboardURL,
+		OperationData: response.OperationData,
+	}, nil
+}
+
+func (c *Client) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	query := acceptsIncomplete(asyncAllowed)
+	query.Set("service_id", details.ServiceID)
+	query.Set("plan_id", details.PlanID)
+
+	var response apiresponses.DeprovisionResponse
+	status, err := c.do(ctx, http.MethodDelete, instancePath(instanceID), query, nil, &response, http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		return domain.DeprovisionServiceSpec{}, goneAs(err, apiresponses.ErrInstanceDoesNotExist)
+	}
+	return domain.DeprovisionServiceSpec{
+		IsAsync:       status == http.StatusAccepted,
+		OperationData: response.OperationData,
+	}, nil
+}
+
+func (c *Client) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	var response apiresponses.GetInstanceResponse
+	if _, err := c.do(ctx, http.MethodGet, instancePath(instanceID), nil, nil, &response, http.StatusOK); err != nil {
+		return domain.GetInstanceDetailsSpec{}, err
+	}
+	return domain.GetInstanceDetailsSpec{
+		ServiceID:    response.ServiceID,
+		PlanID:       response.PlanID,
+		DashboardURL: response.DashboardURL,
+		Parameters:   response.Parameters,
+	}, nil
+}
+
+func (c *Client) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	var response apiresponses.UpdateResponse
+	status, err := c.do(ctx, http.MethodPatch, instancePath(instanceID), acceptsIncomplete(asyncAllowed), details, &response,
+		http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	return domain.UpdateServiceSpec{
+		IsAsync:       status == http.StatusAccepted,
+		DashboardURL:  response.DashboardURL,
+		OperationData: response.OperationData,
+	}, nil
+}
+
+func (c *Client) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	operation, err := c.lastOperation(ctx, instancePath(instanceID)+"/last_operation", details)
+	return operation, goneAs(err, apiresponses.ErrInstanceDoesNotExist)
+}
+
+func (c *Client) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, asyncAllowed bool) (domain.Binding, error) {
+	// A 202 only has an operation, which BindingResponse does not decode, so
+	// both are decoded from the same body.
+	var response struct {
+		apiresponses.BindingResponse
+		OperationData string `json:"operation"`
+	}
+	status, err := c.do(ctx, http.MethodPut, bindingPath(instanceID, bindingID), acceptsIncomplete(asyncAllowed), details, &response,
+		http.StatusOK, http.StatusCreated, http.StatusAccepted)
+	if err != nil {
+		c.mitigateBindingOrphan(ctx, status, err, instanceID, bindingID, details)
+		return domain.Binding{}, err
+	}
+	return domain.Binding{
+		IsAsync:         status == http.StatusAccepted,
+		OperationData:   response.OperationData,
+		Credentials:     response.Credentials,
+		SyslogDrainURL:  response.SyslogDrainURL,
+		RouteServiceURL: response.RouteServiceURL,
+		VolumeMounts:    response.VolumeMounts,
+	}, nil
+}
+
+func (c *Client) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	query := acceptsIncomplete(asyncAllowed)
+	query.Set("service_id", details.ServiceID)
+	query.Set("plan_id", details.PlanID)
+
+	var response apiresponses.UnbindResponse
+	status, err := c.do(ctx, http.MethodDelete, bindingPath(instanceID, bindingID), query, nil, &response, http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		return domain.UnbindSpec{}, goneAs(err, apiresponses.ErrBindingDoesNotExist)
+	}
+	return domain.UnbindSpec{
+		IsAsync:       status == http.StatusAccepted,
+		OperationData: response.OperationData,
+	}, nil
+}
+
+func (c *Client) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	var response apiresponses.GetBindingResponse
+	if _, err := c.do(ctx, http.MethodGet, bindingPath(instanceID, bindingID), nil, nil, &response, http.StatusOK); err != nil {
+		return domain.GetBindingSpec{}, err
+	}
+	return domain.GetBindingSpec{
+		Credentials:     response.Credentials,
+		SyslogDrainURL:  response.SyslogDrainURL,
+		RouteServiceURL: response.RouteServiceURL,
+		VolumeMounts:    response.VolumeMounts,
+		Parameters:      response.Parameters,
+	}, nil
+}
+
+func (c *Client) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	operation, err := c.lastOperation(ctx, bindingPath(instanceID, bindingID)+"/last_operation", details)
+	return operation, goneAs(err, apiresponses.ErrBindingDoesNotExist)
+}
+
+func (c *Client) lastOperation(ctx context.Context, path string, details domain.PollDetails) (domain.LastOperation, error) {
+	query := url.Values{}
+	setIfNotEmpty(query, "service_id", details.ServiceID)
+	setIfNotEmpty(query, "plan_id", details.PlanID)
+	setIfNotEmpty(query, "operation", details.OperationData)
+
+	var response apiresponses.LastOperationResponse
+	if _, err := c.do(ctx, http.MethodGet, path, query, nil, &response, http.StatusOK); err != nil {
+		return domain.LastOperation{}, err
+	}
+	return domain.LastOperation{
+		State:            response.State,
+		Description:      response.Description,
+		InstanceUsable:   response.InstanceUsable,
+		UpdateRepeatable: response.UpdateRepeatable,
+	}, nil
+}
+
+// do sends a request and decodes the response into response when its status
+// is one of expected, returning the status. GET requests are retried when
+// the Client has retries.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, response interface{}, expected ...int) (int, error) {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+
+	if c.retries == nil || method != http.MethodGet {
+		status, _, err := c.attempt(ctx, method, path, query, encoded, response, expected)
+		return status, err
+	}
+	for attempt := 1; ; attempt++ {
+		status, retryAfter, err := c.attempt(ctx, method, path, query, encoded, response, expected)
+		if err == nil || attempt >= c.retries.Attempts || !retryable(ctx, status, err) {
+			return status, err
+		}
+		delay := c.retries.delay(attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+			if delay > c.retries.Max {
+				delay = c.retries.Max
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return status, err
+		}
+		if !sleep(ctx, delay) {
+			return status, err
+		}
+	}
+}
+
+// attempt makes a single request for do, also returning the delay asked for
+// by the Retry-After header of a failed response.
+func (c *Client) attempt(ctx context.Context, method, path string, query url.Values, body []byte, response interface{}, expected []int) (int, time.Duration, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	var err error
+
+	// path is already escaped, so that IDs containing a slash stay in one
+	// segment. Path gets its unescaped form, which url.URL escapes back to
+	// RawPath rather than escaping it twice.
+	target := *c.url
+	target.RawPath = target.EscapedPath() + path
+	if target.Path, err = url.PathUnescape(target.RawPath); err != nil {
+		return 0, 0, err
+	}
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bodyReader)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("X-Broker-API-Version", c.apiVersion)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	identity, err := c.originatingIdentity(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if identity != "" {
+		req.Header.Set(OriginatingIdentityHeader, identity)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, 0, transportError{err}
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, 0, err
+	}
+
+	for _, status := range expected {
+		if resp.StatusCode != status {
+			continue
+		}
+		if len(bytes.TrimSpace(contents)) == 0 {
+			return status, 0, nil
+		}
+		if err := json.Unmarshal(contents, response); err != nil {
+			return status, 0, fmt.Errorf("could not decode the %s %s response: %s", method, path, err)
+		}
+		return status, 0, nil
+	}
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	return resp.StatusCode, retryAfter, failure(resp.StatusCode, retryAfter, contents)
+}
+
+// retryable reports whether a GET request which failed with status and err
+// is worth repeating.
+func retryable(ctx context.Context, status int, err error) bool {
+	switch status {
+	case 0:
+		return ctx.Err() == nil && errors.As(err, new(transportError))
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given either in
+// seconds or as an HTTP date, or zero when there is none.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// failure returns the *Error for a response with an unexpected status,
+// keeping the description and error code the broker sent.
+func failure(status int, retryAfter time.Duration, contents []byte) error {
+	var body errorBody
+	if json.Unmarshal(contents, &body) != nil || body.Description == "" {
+		body.Description = fmt.Sprintf("broker responded with %d %s", status, http.StatusText(status))
+	}
+	builder := apiresponses.NewFailureResponseBuilder(errors.New(body.Description), status, brokerErrorKey).
+		WithRetryAfter(retryAfter)
+	if body.Error != "" {
+		builder.WithErrorKey(body.Error)
+	}
+	return &Error{
+		StatusCode:       status,
+		ErrorCode:        body.Error,
+		Description:      body.Description,
+		InstanceUsable:   body.InstanceUsable,
+		UpdateRepeatable: body.UpdateRepeatable,
+		RetryAfter:       retryAfter,
+		failure:          builder.Build(),
+	}
+}
+
+// goneAs replaces a 410 Gone failure with gone, which the handlers send with
+// the empty body the Open Service Broker API expects.
+func goneAs(err error, gone *apiresponses.FailureResponse) error {
+	var failure *apiresponses.FailureResponse
+	if errors.As(err, &failure) && failure.ValidatedStatusCode(nil) == http.StatusGone {
+		return gone
+	}
+	return err
+}
+
+func acceptsIncomplete(asyncAllowed bool) url.Values {
+	query := url.Values{}
+	if asyncAllowed {
+		query.Set("accepts_incomplete", "true")
+	}
+	return query
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+func instancePath(instanceID string) string {
+	return "/v2/service_instances/" + url.PathEscape(instanceID)
+}
+
+func bindingPath(instanceID, bindingID string) string {
+	return instancePath(instanceID) + "/service_bindings/" + url.PathEscape(bindingID)
+}
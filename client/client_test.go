@@ -0,0 +1,162 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		fakeBroker *fakes.AutoFakeServiceBroker
+		server     *httptest.Server
+		c          *client.Client
+		ctx        context.Context
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+
+	BeforeEach(func() {
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.ServicesReturns([]brokerapi.Service{{
+			ID:                   "service-id",
+			Name:                 "service",
+			Bindable:             true,
+			InstancesRetrievable: true,
+			BindingsRetrievable:  true,
+			Plans:                []brokerapi.ServicePlan{{ID: "plan-id", Name: "plan"}},
+		}}, nil)
+		server = httptest.NewServer(brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials))
+
+		var err error
+		c, err = client.New(server.URL+"/", credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches the catalog", func() {
+		services, err := c.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].Plans[0].ID).To(Equal("plan-id"))
+	})
+
+	It("provisions synchronously and asynchronously", func() {
+		fakeBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{DashboardURL: "https://example.com/dashboard"}, nil)
+		details := brokerapi.ProvisionDetails{ServiceID: "service-id", PlanID: "plan-id", RawParameters: []byte(`{"size":"large"}`)}
+
+		spec, err := c.Provision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(brokerapi.ProvisionedServiceSpec{DashboardURL: "https://example.com/dashboard"}))
+
+		_, instanceID, received, asyncAllowed := fakeBroker.ProvisionArgsForCall(0)
+		Expect(instanceID).To(Equal("instance-id"))
+		Expect(received.RawParameters).To(MatchJSON(`{"size":"large"}`))
+		Expect(asyncAllowed).To(BeFalse())
+
+		fakeBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "operation"}, nil)
+		spec, err = c.Provision(ctx, "instance-id", details, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(brokerapi.ProvisionedServiceSpec{IsAsync: true, OperationData: "operation"}))
+	})
+
+	It("polls the last operation with the operation and plan", func() {
+		fakeBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress, Description: "halfway"}, nil)
+
+		operation, err := c.LastOperation(ctx, "instance-id", brokerapi.PollDetails{ServiceID: "service-id", PlanID: "plan-id", OperationData: "operation"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation).To(Equal(brokerapi.LastOperation{State: brokerapi.InProgress, Description: "halfway"}))
+
+		_, _, details := fakeBroker.LastOperationArgsForCall(0)
+		Expect(details).To(Equal(brokerapi.PollDetails{ServiceID: "service-id", PlanID: "plan-id", OperationData: "operation"}))
+	})
+
+	It("binds, fetches and unbinds", func() {
+		fakeBroker.BindReturns(brokerapi.Binding{Credentials: map[string]interface{}{"password": "secret"}}, nil)
+		binding, err := c.Bind(ctx, "instance-id", "binding-id", brokerapi.BindDetails{ServiceID: "service-id", PlanID: "plan-id"}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.Credentials).To(Equal(map[string]interface{}{"password": "secret"}))
+
+		fakeBroker.BindReturns(brokerapi.Binding{IsAsync: true, OperationData: "binding-operation"}, nil)
+		binding, err = c.Bind(ctx, "instance-id", "binding-id", brokerapi.BindDetails{ServiceID: "service-id", PlanID: "plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding).To(Equal(brokerapi.Binding{IsAsync: true, OperationData: "binding-operation"}))
+
+		fakeBroker.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: map[string]interface{}{"password": "secret"}}, nil)
+		spec, err := c.GetBinding(ctx, "instance-id", "binding-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Credentials).To(Equal(map[string]interface{}{"password": "secret"}))
+
+		_, err = c.Unbind(ctx, "instance-id", "binding-id", brokerapi.UnbindDetails{ServiceID: "service-id", PlanID: "plan-id"}, false)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, _, details, _ := fakeBroker.UnbindArgsForCall(0)
+		Expect(details).To(Equal(brokerapi.UnbindDetails{ServiceID: "service-id", PlanID: "plan-id"}))
+	})
+
+	It("escapes IDs into a single path segment", func() {
+		paths := make(chan string, 1)
+		recorder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			paths <- req.URL.EscapedPath()
+			w.Write([]byte(`{}`))
+		}))
+		defer recorder.Close()
+
+		c, err := client.New(recorder.URL+"/broker", credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.GetBinding(ctx, "team/instance 100%", "binding?id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(<-paths).To(Equal("/broker/v2/service_instances/team%2Finstance%20100%25/service_bindings/binding%3Fid"))
+	})
+
+	It("returns the errors of the broker as failure responses", func() {
+		fakeBroker.UpdateReturns(brokerapi.UpdateServiceSpec{}, brokerapi.ErrPlanChangeNotSupported)
+
+		_, err := c.Update(ctx, "instance-id", brokerapi.UpdateDetails{ServiceID: "service-id", PlanID: "plan-id"}, false)
+		var failure *brokerapi.FailureResponse
+		Expect(errors.As(err, &failure)).To(BeTrue())
+		Expect(failure.ValidatedStatusCode(nil)).To(Equal(http.StatusUnprocessableEntity))
+		Expect(failure.ErrorResponse()).To(Equal(brokerapi.ErrorResponse{
+			Error:       "PlanChangeNotSupported",
+			Description: "The requested plan migration cannot be performed",
+		}))
+	})
+
+	It("returns the gone errors of the domain package", func() {
+		fakeBroker.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{}, brokerapi.ErrInstanceDoesNotExist)
+
+		_, err := c.Deprovision(ctx, "instance-id", brokerapi.DeprovisionDetails{ServiceID: "service-id", PlanID: "plan-id"}, true)
+		Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+	})
+
+	It("rejects URLs which are not http", func() {
+		_, err := client.New("ftp://broker", "username", "password")
+		Expect(err).To(MatchError(ContainSubstring("the scheme must be http or https")))
+	})
+})
@@ -0,0 +1,118 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// The error codes the Open Service Broker API defines for the error field of
+// error responses.
+const (
+	ErrorCodeAsyncRequired           = "AsyncRequired"
+	ErrorCodeConcurrencyError        = "ConcurrencyError"
+	ErrorCodeRequiresApp             = "RequiresApp"
+	ErrorCodeMaintenanceInfoConflict = "MaintenanceInfoConflict"
+)
+
+// Error is returned when a broker responds with a status the request does
+// not expect. It unwraps to the equivalent *apiresponses.FailureResponse, so
+// that a broker passing requests on to another sends its errors on
+// unchanged, and errors.Is matches it with the failure responses of the
+// apiresponses package of the same status and error code:
+//
+//	if errors.Is(err, apiresponses.ErrAsyncRequired) {
+//		// provision again with asyncAllowed
+//	}
+type Error struct {
+	StatusCode int
+	// ErrorCode is the error field of the response, such as
+	// ErrorCodeAsyncRequired, and is empty for plain failures.
+	ErrorCode   string
+	Description string
+
+	// InstanceUsable and UpdateRepeatable are set when the broker says
+	// whether the instance can still be used, or the update tried again,
+	// after a failed update.
+	InstanceUsable   *bool
+	UpdateRepeatable *bool
+
+	// RetryAfter is the delay asked for by the Retry-After header.
+	RetryAfter time.Duration
+
+	failure *apiresponses.FailureResponse
+}
+
+func (e *Error) Error() string {
+	return e.Description
+}
+
+// Unwrap returns the failure response of the error.
+func (e *Error) Unwrap() error {
+	return e.failure
+}
+
+// Is reports whether target is a failure response with the status of the
+// error and, when target has one, its error code.
+func (e *Error) Is(target error) bool {
+	failure, ok := target.(*apiresponses.FailureResponse)
+	if !ok || failure.ValidatedStatusCode(nil) != e.StatusCode {
+		return false
+	}
+	if response, ok := failure.ErrorResponse().(apiresponses.ErrorResponse); ok && response.Error != "" {
+		return response.Error == e.ErrorCode
+	}
+	return true
+}
+
+// IsAsyncRequired reports whether the broker only serves the request
+// asynchronously.
+func (e *Error) IsAsyncRequired() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity && e.ErrorCode == ErrorCodeAsyncRequired
+}
+
+// IsConcurrencyError reports whether the broker rejected the request because
+// another operation on the instance or binding is in progress.
+func (e *Error) IsConcurrencyError() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity && e.ErrorCode == ErrorCodeConcurrencyError
+}
+
+// IsRequiresApp reports whether the broker only binds to applications.
+func (e *Error) IsRequiresApp() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity && e.ErrorCode == ErrorCodeRequiresApp
+}
+
+// IsMaintenanceInfoConflict reports whether the maintenance_info of the
+// request does not match the catalog of the broker.
+func (e *Error) IsMaintenanceInfoConflict() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity && e.ErrorCode == ErrorCodeMaintenanceInfoConflict
+}
+
+// IsServerError reports whether the broker failed with a 5xx.
+func (e *Error) IsServerError() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// errorBody is the body of error responses.
+type errorBody struct {
+	Error            string `json:"error"`
+	Description      string `json:"description"`
+	InstanceUsable   *bool  `json:"instance_usable"`
+	UpdateRepeatable *bool  `json:"update_repeatable"`
+}
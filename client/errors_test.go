@@ -0,0 +1,114 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+)
+
+var _ = Describe("Error", func() {
+	var (
+		status int
+		header http.Header
+		body   string
+		server *httptest.Server
+		c      *client.Client
+	)
+
+	BeforeEach(func() {
+		header = http.Header{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+
+		var err error
+		c, err = client.New(server.URL, "username", "password")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	update := func() *client.Error {
+		_, err := c.Update(context.Background(), "instance-id", brokerapi.UpdateDetails{}, false)
+		var clientError *client.Error
+		Expect(errors.As(err, &clientError)).To(BeTrue())
+		return clientError
+	}
+
+	It("exposes the status, error code and description", func() {
+		status = http.StatusUnprocessableEntity
+		body = `{"error": "AsyncRequired", "description": "This service plan requires client support for asynchronous service operations."}`
+
+		clientError := update()
+		Expect(clientError.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		Expect(clientError.ErrorCode).To(Equal(client.ErrorCodeAsyncRequired))
+		Expect(clientError).To(MatchError("This service plan requires client support for asynchronous service operations."))
+		Expect(clientError.IsAsyncRequired()).To(BeTrue())
+		Expect(clientError.IsConcurrencyError()).To(BeFalse())
+		Expect(clientError.IsServerError()).To(BeFalse())
+	})
+
+	It("matches the failure responses of the same status and error code", func() {
+		status = http.StatusUnprocessableEntity
+		body = `{"error": "ConcurrencyError", "description": "busy"}`
+
+		clientError := update()
+		Expect(clientError.IsConcurrencyError()).To(BeTrue())
+		Expect(errors.Is(clientError, brokerapi.ErrConcurrentInstanceAccess.Build())).To(BeTrue())
+		Expect(errors.Is(clientError, brokerapi.ErrAsyncRequired)).To(BeFalse())
+		Expect(errors.Is(clientError, brokerapi.ErrInstanceAlreadyExists)).To(BeFalse())
+	})
+
+	It("keeps whether the instance is usable and the retry delay", func() {
+		status = http.StatusServiceUnavailable
+		header.Set("Retry-After", "30")
+		body = `{"description": "down for maintenance", "instance_usable": true, "update_repeatable": false}`
+
+		clientError := update()
+		Expect(clientError.IsServerError()).To(BeTrue())
+		Expect(*clientError.InstanceUsable).To(BeTrue())
+		Expect(*clientError.UpdateRepeatable).To(BeFalse())
+		Expect(clientError.RetryAfter).To(Equal(30 * time.Second))
+
+		var failure *brokerapi.FailureResponse
+		Expect(errors.As(clientError, &failure)).To(BeTrue())
+		Expect(failure.RetryAfter()).To(Equal(30 * time.Second))
+	})
+
+	It("describes plain failures by their status", func() {
+		status = http.StatusInternalServerError
+		body = `oops`
+
+		clientError := update()
+		Expect(clientError.ErrorCode).To(BeEmpty())
+		Expect(clientError).To(MatchError("broker responded with 500 Internal Server Error"))
+	})
+})
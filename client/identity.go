@@ -0,0 +1,97 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+// OriginatingIdentityHeader is the header identifying the user on whose
+// behalf a request is made.
+const OriginatingIdentityHeader = "X-Broker-API-Originating-Identity"
+
+// OriginatingIdentity identifies the platform user a request is made for. It
+// is sent as the name of the platform followed by the base64 encoded JSON of
+// Value, such as "cloudfoundry eyJ1c2VyX2lkIjoiMTIzIn0=".
+//
+// Each request sends, in order of preference, the identity set on its context
+// with ContextWithOriginatingIdentity, the identity of the request being
+// served, as stored in the context by the brokerapi handlers, or the identity
+// set with WithOriginatingIdentity. Brokers passing requests on to other
+// brokers so keep the identity of the end user without further code.
+type OriginatingIdentity struct {
+	Platform string
+	Value    interface{}
+}
+
+// ParseOriginatingIdentity parses the value of an
+// X-Broker-API-Originating-Identity header. The Value of the result is the
+// json.RawMessage of the decoded JSON.
+func ParseOriginatingIdentity(header string) (OriginatingIdentity, error) {
+	identity, err := domain.ParseOriginatingIdentity(header)
+	if err != nil {
+		return OriginatingIdentity{}, err
+	}
+	return OriginatingIdentity{Platform: identity.Platform, Value: identity.Value}, nil
+}
+
+// Header returns the identity in the form of the
+// X-Broker-API-Originating-Identity header.
+func (o OriginatingIdentity) Header() (string, error) {
+	if o.Platform == "" || strings.ContainsAny(o.Platform, " \t") {
+		return "", fmt.Errorf("invalid originating identity platform %q", o.Platform)
+	}
+	value, err := json.Marshal(o.Value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode originating identity: %s", err)
+	}
+	return o.Platform + " " + base64.StdEncoding.EncodeToString(value), nil
+}
+
+type identityKey struct{}
+
+// ContextWithOriginatingIdentity returns a copy of ctx whose requests are made
+// on behalf of identity.
+func ContextWithOriginatingIdentity(ctx context.Context, identity OriginatingIdentity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// originatingIdentity returns the header to send for a request with ctx, or
+// an empty string when there is no identity to send.
+func (c *Client) originatingIdentity(ctx context.Context) (string, error) {
+	if identity, ok := ctx.Value(identityKey{}).(OriginatingIdentity); ok {
+		return identity.Header()
+	}
+	if header, _ := contextkeys.OriginatingIdentity(ctx); header != "" {
+		// Re-encode the identity, so that what is passed on is well-formed.
+		identity, err := ParseOriginatingIdentity(header)
+		if err != nil {
+			return "", fmt.Errorf("cannot forward the originating identity of the request: %s", err)
+		}
+		return identity.Header()
+	}
+	if c.identity != nil {
+		return c.identity.Header()
+	}
+	return "", nil
+}
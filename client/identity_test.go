@@ -0,0 +1,121 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+var _ = Describe("OriginatingIdentity", func() {
+	var (
+		received []string
+		server   *httptest.Server
+	)
+
+	BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			received = append(received, req.Header.Get(client.OriginatingIdentityHeader))
+			w.Write([]byte(`{"services":[]}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	identity := client.OriginatingIdentity{Platform: "cloudfoundry", Value: map[string]string{"user_id": "683ea748-3092-4ff4-b656-39cacc4d5360"}}
+	const header = "cloudfoundry eyJ1c2VyX2lkIjoiNjgzZWE3NDgtMzA5Mi00ZmY0LWI2NTYtMzljYWNjNGQ1MzYwIn0="
+
+	It("encodes the value as base64 JSON", func() {
+		Expect(identity.Header()).To(Equal(header))
+	})
+
+	It("parses headers, with or without padding", func() {
+		parsed, err := client.ParseOriginatingIdentity(header)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Platform).To(Equal("cloudfoundry"))
+		Expect(parsed.Header()).To(Equal(header))
+
+		parsed, err = client.ParseOriginatingIdentity("kubernetes eyJ1c2VybmFtZSI6ImFkbWluIn0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Header()).To(Equal("kubernetes eyJ1c2VybmFtZSI6ImFkbWluIn0="))
+	})
+
+	It("rejects malformed headers", func() {
+		_, err := client.ParseOriginatingIdentity("cloudfoundry")
+		Expect(err).To(HaveOccurred())
+		_, err = client.ParseOriginatingIdentity("cloudfoundry not-base64!")
+		Expect(err).To(MatchError(ContainSubstring("not base64 encoded")))
+		_, err = client.ParseOriginatingIdentity("cloudfoundry bm90IGpzb24=")
+		Expect(err).To(MatchError("originating identity value is not JSON"))
+	})
+
+	It("sends the identity of the context in preference to the default one", func() {
+		c, err := client.New(server.URL, "username", "password", client.WithOriginatingIdentity(client.OriginatingIdentity{Platform: "default", Value: map[string]string{}}))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.Services(client.ContextWithOriginatingIdentity(context.Background(), identity))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(received).To(Equal([]string{"default e30=", header}))
+	})
+
+	It("sends no identity when there is none", func() {
+		c, err := client.New(server.URL, "username", "password")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.Services(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(Equal([]string{""}))
+	})
+
+	It("passes on the identity of the request being served", func() {
+		c, err := client.New(server.URL, "username", "password")
+		Expect(err).NotTo(HaveOccurred())
+		credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+		proxy := brokerapi.New(c, lager.NewLogger("proxy"), credentials)
+
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+		request.Header.Set("X-Broker-API-Version", "2.14")
+		request.Header.Set(client.OriginatingIdentityHeader, "kubernetes eyJ1c2VybmFtZSI6ImFkbWluIn0")
+		recorder := httptest.NewRecorder()
+		proxy.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(received).To(Equal([]string{"kubernetes eyJ1c2VybmFtZSI6ImFkbWluIn0="}))
+	})
+
+	It("refuses to pass on a malformed identity", func() {
+		c, err := client.New(server.URL, "username", "password")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.Services(contextkeys.WithOriginatingIdentity(context.Background(), "cloudfoundry"))
+		Expect(err).To(MatchError(ContainSubstring("cannot forward the originating identity")))
+		Expect(received).To(BeEmpty())
+	})
+})
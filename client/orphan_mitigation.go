@@ -0,0 +1,139 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// OrphanMitigation configures the clean up a Client does when a provision or
+// bind fails in a way which may have left an instance or binding behind on
+// the broker, as platforms do: the request timed out or failed in transit,
+// the broker responded with 408 or a 5xx, or it responded with another 2xx
+// status or a body which could not be decoded. The Client then deprovisions
+// or unbinds, retrying with Backoff until the broker responds with a 200, 202
+// or 410.
+type OrphanMitigation struct {
+	Backoff Backoff
+
+	// Async runs the clean up in the background, so that the failed request
+	// returns without waiting for it.
+	Async bool
+
+	// Done, when set, is called with the outcome of each clean up.
+	Done func(OrphanMitigationResult)
+}
+
+// OrphanMitigationResult is the outcome of cleaning up after a failed
+// provision or bind. BindingID is empty for instances.
+type OrphanMitigationResult struct {
+	InstanceID string
+	BindingID  string
+	Attempts   int
+	// Err is the error of the last attempt, or nil when the orphan was
+	// removed.
+	Err error
+}
+
+// WithOrphanMitigation cleans up after failed provisions and binds as
+// described by mitigation.
+func WithOrphanMitigation(mitigation OrphanMitigation) Option {
+	return func(c *Client) {
+		mitigation.Backoff = mitigation.Backoff.withDefaults()
+		c.orphanMitigation = &mitigation
+	}
+}
+
+// transportError is returned for requests which were sent but got no
+// response, so that they can be told apart from requests which could not be
+// made at all.
+type transportError struct {
+	err error
+}
+
+func (e transportError) Error() string { return e.err.Error() }
+
+func (e transportError) Unwrap() error { return e.err }
+
+// mayHaveOrphan reports whether a request which failed with status and err
+// may have created a resource on the broker.
+func mayHaveOrphan(status int, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case status == 0:
+		return errors.As(err, new(transportError))
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return true
+	default:
+		return status >= http.StatusOK && status < http.StatusMultipleChoices
+	}
+}
+
+func (c *Client) mitigateInstanceOrphan(ctx context.Context, status int, err error, instanceID string, details domain.ProvisionDetails) {
+	if c.orphanMitigation == nil || !mayHaveOrphan(status, err) {
+		return
+	}
+	c.mitigate(ctx, OrphanMitigationResult{InstanceID: instanceID}, func(ctx context.Context) error {
+		_, err := c.Deprovision(ctx, instanceID, domain.DeprovisionDetails{ServiceID: details.ServiceID, PlanID: details.PlanID}, true)
+		return err
+	})
+}
+
+func (c *Client) mitigateBindingOrphan(ctx context.Context, status int, err error, instanceID, bindingID string, details domain.BindDetails) {
+	if c.orphanMitigation == nil || !mayHaveOrphan(status, err) {
+		return
+	}
+	c.mitigate(ctx, OrphanMitigationResult{InstanceID: instanceID, BindingID: bindingID}, func(ctx context.Context) error {
+		_, err := c.Unbind(ctx, instanceID, bindingID, domain.UnbindDetails{ServiceID: details.ServiceID, PlanID: details.PlanID}, true)
+		return err
+	})
+}
+
+// mitigate calls remove until it succeeds or the attempts are used up. It
+// keeps the values of ctx, such as the originating identity, but not its
+// cancellation, since the failed request may have been cancelled by a
+// timeout.
+func (c *Client) mitigate(ctx context.Context, result OrphanMitigationResult, remove func(context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	run := func() {
+		backoff := c.orphanMitigation.Backoff
+		for result.Attempts = 1; ; result.Attempts++ {
+			result.Err = remove(ctx)
+			if result.Err == apiresponses.ErrInstanceDoesNotExist || result.Err == apiresponses.ErrBindingDoesNotExist {
+				result.Err = nil
+			}
+			if result.Err == nil || result.Attempts >= backoff.Attempts {
+				break
+			}
+			sleep(ctx, backoff.delay(result.Attempts))
+		}
+		if c.orphanMitigation.Done != nil {
+			c.orphanMitigation.Done(result)
+		}
+	}
+
+	if c.orphanMitigation.Async {
+		go run()
+		return
+	}
+	run()
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("orphan mitigation", func() {
+	var (
+		fakeBroker *fakes.AutoFakeServiceBroker
+		server     *httptest.Server
+		results    chan client.OrphanMitigationResult
+		mitigation client.OrphanMitigation
+		ctx        context.Context
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+	provisionDetails := brokerapi.ProvisionDetails{ServiceID: "service-id", PlanID: "plan-id"}
+	bindDetails := brokerapi.BindDetails{ServiceID: "service-id", PlanID: "plan-id"}
+
+	newClient := func() *client.Client {
+		c, err := client.New(server.URL, credentials.Username, credentials.Password, client.WithOrphanMitigation(mitigation))
+		Expect(err).NotTo(HaveOccurred())
+		return c
+	}
+
+	BeforeEach(func() {
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.ServicesReturns([]brokerapi.Service{{
+			ID:       "service-id",
+			Bindable: true,
+			Plans:    []brokerapi.ServicePlan{{ID: "plan-id"}},
+		}}, nil)
+		server = httptest.NewServer(brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials))
+		results = make(chan client.OrphanMitigationResult, 1)
+		mitigation = client.OrphanMitigation{
+			Backoff: client.Backoff{Attempts: 3, Initial: time.Millisecond},
+			Done:    func(result client.OrphanMitigationResult) { results <- result },
+		}
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("deprovisions instances whose provision failed with a 5xx", func() {
+		fakeBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, errors.New("boom"))
+
+		_, err := newClient().Provision(ctx, "instance-id", provisionDetails, false)
+		Expect(err).To(MatchError("boom"))
+
+		Expect(fakeBroker.DeprovisionCallCount()).To(Equal(1))
+		_, instanceID, details, asyncAllowed := fakeBroker.DeprovisionArgsForCall(0)
+		Expect(instanceID).To(Equal("instance-id"))
+		Expect(details).To(Equal(brokerapi.DeprovisionDetails{ServiceID: "service-id", PlanID: "plan-id"}))
+		Expect(asyncAllowed).To(BeTrue())
+		Expect(results).To(Receive(Equal(client.OrphanMitigationResult{InstanceID: "instance-id", Attempts: 1})))
+	})
+
+	It("retries until the orphan is removed", func() {
+		fakeBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, errors.New("boom"))
+		fakeBroker.DeprovisionReturnsOnCall(0, brokerapi.DeprovisionServiceSpec{}, errors.New("still busy"))
+		fakeBroker.DeprovisionReturnsOnCall(1, brokerapi.DeprovisionServiceSpec{}, brokerapi.ErrInstanceDoesNotExist)
+
+		newClient().Provision(ctx, "instance-id", provisionDetails, false)
+
+		Expect(fakeBroker.DeprovisionCallCount()).To(Equal(2))
+		Expect(results).To(Receive(Equal(client.OrphanMitigationResult{InstanceID: "instance-id", Attempts: 2})))
+	})
+
+	It("reports the last error when the attempts are used up", func() {
+		fakeBroker.BindReturns(brokerapi.Binding{}, errors.New("boom"))
+		fakeBroker.UnbindReturns(brokerapi.UnbindSpec{}, errors.New("still busy"))
+
+		newClient().Bind(ctx, "instance-id", "binding-id", bindDetails, false)
+
+		Expect(fakeBroker.UnbindCallCount()).To(Equal(3))
+		var result client.OrphanMitigationResult
+		Expect(results).To(Receive(&result))
+		Expect(result.BindingID).To(Equal("binding-id"))
+		Expect(result.Attempts).To(Equal(3))
+		Expect(result.Err).To(MatchError("still busy"))
+	})
+
+	It("deprovisions after requests which timed out", func() {
+		fakeBroker.ProvisionStub = func(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+			<-ctx.Done()
+			return brokerapi.ProvisionedServiceSpec{}, ctx.Err()
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := newClient().Provision(timeoutCtx, "instance-id", provisionDetails, false)
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		Expect(results).To(Receive(Equal(client.OrphanMitigationResult{InstanceID: "instance-id", Attempts: 1})))
+	})
+
+	It("does not clean up after requests the broker rejected", func() {
+		fakeBroker.ProvisionReturns(brokerapi.ProvisionedServiceSpec{}, brokerapi.ErrInstanceAlreadyExists)
+		fakeBroker.BindReturns(brokerapi.Binding{}, brokerapi.ErrAsyncRequired)
+
+		newClient().Provision(ctx, "instance-id", provisionDetails, false)
+		newClient().Bind(ctx, "instance-id", "binding-id", bindDetails, false)
+
+		Expect(fakeBroker.DeprovisionCallCount()).To(Equal(0))
+		Expect(fakeBroker.UnbindCallCount()).To(Equal(0))
+	})
+
+	It("cleans up in the background when async", func() {
+		mitigation.Async = true
+		fakeBroker.BindReturns(brokerapi.Binding{}, errors.New("boom"))
+
+		newClient().Bind(ctx, "instance-id", "binding-id", bindDetails, false)
+
+		Eventually(results).Should(Receive(Equal(client.OrphanMitigationResult{InstanceID: "instance-id", BindingID: "binding-id", Attempts: 1})))
+	})
+})
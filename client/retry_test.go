@@ -0,0 +1,160 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("retries", func() {
+	var (
+		fakeBroker *fakes.AutoFakeServiceBroker
+		server     *httptest.Server
+		requests   int32
+		failures   []func(http.ResponseWriter)
+		c          *client.Client
+		ctx        context.Context
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+
+	respondWith := func(status int, retryAfter string) func(http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+		}
+	}
+
+	BeforeEach(func() {
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.ServicesReturns([]brokerapi.Service{{ID: "service-id", Plans: []brokerapi.ServicePlan{{ID: "plan-id"}}}}, nil)
+		handler := brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials)
+		requests = 0
+		failures = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			request := int(atomic.AddInt32(&requests, 1))
+			if request <= len(failures) {
+				failures[request-1](w)
+				return
+			}
+			handler.ServeHTTP(w, req)
+		}))
+
+		var err error
+		c, err = client.New(server.URL, credentials.Username, credentials.Password,
+			client.WithRetries(client.Backoff{Attempts: 3, Initial: time.Millisecond, Jitter: 0.5}))
+		Expect(err).NotTo(HaveOccurred())
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("retries GET requests the broker could not serve", func() {
+		failures = []func(http.ResponseWriter){
+			respondWith(http.StatusServiceUnavailable, ""),
+			respondWith(http.StatusTooManyRequests, ""),
+		}
+
+		services, err := c.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(1))
+		Expect(requests).To(BeEquivalentTo(3))
+	})
+
+	It("gives up after the last attempt", func() {
+		failures = []func(http.ResponseWriter){
+			respondWith(http.StatusRequestTimeout, ""),
+			respondWith(http.StatusBadGateway, ""),
+			respondWith(http.StatusGatewayTimeout, ""),
+		}
+
+		_, err := c.LastOperation(ctx, "instance-id", brokerapi.PollDetails{})
+		Expect(err).To(MatchError("broker responded with 504 Gateway Timeout"))
+		Expect(requests).To(BeEquivalentTo(3))
+	})
+
+	It("waits as long as the Retry-After header asks", func() {
+		failures = []func(http.ResponseWriter){respondWith(http.StatusServiceUnavailable, "1")}
+
+		start := time.Now()
+		_, err := c.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", time.Second))
+	})
+
+	It("waits no longer than the maximum backoff", func() {
+		failures = []func(http.ResponseWriter){respondWith(http.StatusServiceUnavailable, "3600")}
+		var err error
+		c, err = client.New(server.URL, credentials.Username, credentials.Password,
+			client.WithRetries(client.Backoff{Attempts: 3, Initial: time.Millisecond, Max: 10 * time.Millisecond}))
+		Expect(err).NotTo(HaveOccurred())
+
+		start := time.Now()
+		_, err = c.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("gives up when the context would be done before the next attempt", func() {
+		failures = []func(http.ResponseWriter){respondWith(http.StatusServiceUnavailable, "20")}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		start := time.Now()
+		_, err := c.Services(timeoutCtx)
+		Expect(err).To(MatchError(ContainSubstring("503")))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(requests).To(BeEquivalentTo(1))
+	})
+
+	It("stops waiting when the context is done", func() {
+		failures = []func(http.ResponseWriter){respondWith(http.StatusServiceUnavailable, "60")}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := c.Services(timeoutCtx)
+		Expect(err).To(MatchError(ContainSubstring("503")))
+		Expect(requests).To(BeEquivalentTo(1))
+	})
+
+	It("does not retry other errors or requests which are not safe to repeat", func() {
+		failures = []func(http.ResponseWriter){
+			respondWith(http.StatusInternalServerError, ""),
+			respondWith(http.StatusServiceUnavailable, ""),
+		}
+
+		_, err := c.GetInstance(ctx, "instance-id")
+		Expect(err).To(MatchError("broker responded with 500 Internal Server Error"))
+		_, err = c.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "service-id", PlanID: "plan-id"}, false)
+		Expect(err).To(MatchError("broker responded with 503 Service Unavailable"))
+		Expect(requests).To(BeEquivalentTo(2))
+	})
+})
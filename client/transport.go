@@ -0,0 +1,101 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportConfig sets the connection handling of the transport of a Client.
+type TransportConfig struct {
+	// MaxConnsPerHost limits the connections to a broker, making further
+	// requests wait for one to be free, so that bursts of polls reuse
+	// connections instead of exhausting ephemeral ports. Zero means no limit.
+	MaxConnsPerHost int
+	// MaxIdleConnsPerHost is how many connections to a broker are kept open
+	// for reuse.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections which have been idle this long.
+	IdleConnTimeout time.Duration
+
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the wait for the response once the
+	// request is sent.
+	ResponseHeaderTimeout time.Duration
+
+	TLSClientConfig *tls.Config
+}
+
+// DefaultTransportConfig keeps up to 100 connections per broker open, rather
+// than the 2 of http.DefaultTransport, and gives up on brokers which do not
+// respond within the 60 seconds platforms wait.
+var DefaultTransportConfig = TransportConfig{
+	MaxConnsPerHost:       100,
+	MaxIdleConnsPerHost:   100,
+	IdleConnTimeout:       90 * time.Second,
+	DialTimeout:           30 * time.Second,
+	KeepAlive:             30 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 60 * time.Second,
+}
+
+// defaultTransport is shared by the Clients using the default transport, so
+// that Clients for the same broker share its connections.
+var defaultTransport = NewTransport(DefaultTransportConfig)
+
+// NewTransport returns a transport set up as config says, which is safe for
+// concurrent use by several Clients.
+func NewTransport(config TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          config.MaxIdleConnsPerHost * 10,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       config.TLSClientConfig,
+	}
+}
+
+// WithTransport makes requests through a transport set up as config says,
+// instead of the transport shared by Clients with the
+// DefaultTransportConfig. It has no effect with WithHTTPClient.
+func WithTransport(config TransportConfig) Option {
+	return func(c *Client) {
+		c.transport = NewTransport(config)
+	}
+}
+
+// WithTimeout limits each request, including reading its response, to
+// timeout. It has no effect with WithHTTPClient.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("transport", func() {
+	var (
+		fakeBroker  *fakes.AutoFakeServiceBroker
+		server      *httptest.Server
+		connections int32
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+
+	BeforeEach(func() {
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.LastOperationStub = func(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+			time.Sleep(10 * time.Millisecond)
+			return brokerapi.LastOperation{State: brokerapi.InProgress}, nil
+		}
+		connections = 0
+		server = httptest.NewUnstartedServer(brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&connections, 1)
+			}
+		}
+		server.Start()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	poll := func(c *client.Client, requests int) {
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := c.LastOperation(context.Background(), "instance-id", brokerapi.PollDetails{})
+				Expect(err).NotTo(HaveOccurred())
+			}()
+		}
+		wg.Wait()
+	}
+
+	It("limits the connections to a broker and reuses them", func() {
+		config := client.DefaultTransportConfig
+		config.MaxConnsPerHost = 2
+		c, err := client.New(server.URL, credentials.Username, credentials.Password, client.WithTransport(config))
+		Expect(err).NotTo(HaveOccurred())
+
+		poll(c, 20)
+		poll(c, 20)
+		Expect(atomic.LoadInt32(&connections)).To(BeNumerically("<=", 2))
+	})
+
+	It("keeps connections open for reuse by default", func() {
+		c, err := client.New(server.URL, credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+
+		poll(c, 10)
+		opened := atomic.LoadInt32(&connections)
+		poll(c, 10)
+		Expect(atomic.LoadInt32(&connections)).To(Equal(opened))
+	})
+
+	It("times requests out", func() {
+		c, err := client.New(server.URL, credentials.Username, credentials.Password, client.WithTimeout(time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.LastOperation(context.Background(), "instance-id", brokerapi.PollDetails{})
+		Expect(err).To(MatchError(ContainSubstring("Client.Timeout exceeded")))
+	})
+})
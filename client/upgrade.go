@@ -0,0 +1,223 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// DefaultUpgradePollInterval is how often the last operation of an
+// asynchronous upgrade is polled unless FleetUpgrade sets another interval.
+const DefaultUpgradePollInterval = 10 * time.Second
+
+// ErrUpgradeFailed is wrapped by the error of an upgrade whose last operation
+// failed.
+var ErrUpgradeFailed = errors.New("upgrade failed")
+
+// FleetUpgrade describes the upgrade of instances of one plan to the
+// maintenance_info of that plan in the catalog, such as the candidates listed
+// by the GET /admin/upgrade_candidates extension endpoint.
+type FleetUpgrade struct {
+	ServiceID       string
+	PlanID          string
+	MaintenanceInfo domain.MaintenanceInfo
+
+	// Concurrency is how many instances are upgraded at once. It defaults
+	// to 1.
+	Concurrency int
+
+	// PollInterval is how often the last operation of an asynchronous
+	// upgrade is polled. It defaults to DefaultUpgradePollInterval.
+	PollInterval time.Duration
+
+	// PollBackoff retries the polls which fail in transit or get a 408, 429,
+	// 502, 503 or 504, waiting as long as the Retry-After header of the
+	// response asks, up to its Max, or otherwise as long as it says. Its
+	// Attempts are the number of polls in a row which may fail before the
+	// upgrade does. Zero fields take the values of DefaultBackoff.
+	PollBackoff Backoff
+
+	// Timeout, when set, limits how long each instance may take to upgrade.
+	Timeout time.Duration
+
+	// Done, when set, is called with the outcome of each upgrade as soon as
+	// it is known. It may be called from several goroutines at once.
+	Done func(UpgradeResult)
+}
+
+// UpgradeResult is the outcome of upgrading one instance.
+type UpgradeResult struct {
+	InstanceID string
+	// Async is true when the broker upgraded the instance asynchronously.
+	Async    bool
+	Duration time.Duration
+	// Err is nil when the instance was upgraded.
+	Err error
+}
+
+// UpgradeSummary is the outcome of a FleetUpgrade. Results are in the order
+// of the instance IDs given to UpgradeFleet.
+type UpgradeSummary struct {
+	Results   []UpgradeResult
+	Succeeded int
+	Failed    int
+}
+
+// Failures returns the results of the instances which were not upgraded.
+func (s UpgradeSummary) Failures() []UpgradeResult {
+	var failures []UpgradeResult
+	for _, result := range s.Results {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// UpgradeFleet upgrades each of the instances by sending an update request
+// with only the maintenance_info of upgrade, polling the last operation of
+// asynchronous updates until they finish. Once ctx is done, the instances
+// which have not been upgraded yet fail with its error.
+func (c *Client) UpgradeFleet(ctx context.Context, instanceIDs []string, upgrade FleetUpgrade) UpgradeSummary {
+	if upgrade.Concurrency < 1 {
+		upgrade.Concurrency = 1
+	}
+	if upgrade.PollInterval <= 0 {
+		upgrade.PollInterval = DefaultUpgradePollInterval
+	}
+	upgrade.PollBackoff = upgrade.PollBackoff.withDefaults()
+
+	results := make([]UpgradeResult, len(instanceIDs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < upgrade.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = c.upgradeInstance(ctx, instanceIDs[index], upgrade)
+				if upgrade.Done != nil {
+					upgrade.Done(results[index])
+				}
+			}
+		}()
+	}
+	for index := range instanceIDs {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	summary := UpgradeSummary{Results: results}
+	for _, result := range results {
+		if result.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+	}
+	return summary
+}
+
+func (c *Client) upgradeInstance(ctx context.Context, instanceID string, upgrade FleetUpgrade) UpgradeResult {
+	started := time.Now()
+	result := UpgradeResult{InstanceID: instanceID}
+	if upgrade.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, upgrade.Timeout)
+		defer cancel()
+	}
+
+	result.Async, result.Err = c.upgrade(ctx, instanceID, upgrade)
+	result.Duration = time.Since(started)
+	return result
+}
+
+func (c *Client) upgrade(ctx context.Context, instanceID string, upgrade FleetUpgrade) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	spec, err := c.Update(ctx, instanceID, domain.UpdateDetails{
+		ServiceID:       upgrade.ServiceID,
+		PlanID:          upgrade.PlanID,
+		MaintenanceInfo: upgrade.MaintenanceInfo,
+		PreviousValues: domain.PreviousValues{
+			ServiceID: upgrade.ServiceID,
+			PlanID:    upgrade.PlanID,
+		},
+	}, true)
+	if err != nil || !spec.IsAsync {
+		return false, err
+	}
+
+	details := domain.PollDetails{
+		ServiceID:     upgrade.ServiceID,
+		PlanID:        upgrade.PlanID,
+		OperationData: spec.OperationData,
+	}
+	delay := upgrade.PollInterval
+	for failures := 0; ; {
+		if !sleep(ctx, delay) {
+			return true, ctx.Err()
+		}
+		operation, err := c.LastOperation(ctx, instanceID, details)
+		if err != nil {
+			failures++
+			if failures >= upgrade.PollBackoff.Attempts || !transientPollError(ctx, err) {
+				return true, err
+			}
+			delay = pollRetryDelay(upgrade.PollBackoff, failures, err)
+			continue
+		}
+		failures, delay = 0, upgrade.PollInterval
+		switch operation.State {
+		case domain.Succeeded:
+			return true, nil
+		case domain.Failed:
+			return true, fmt.Errorf("%w: %s", ErrUpgradeFailed, operation.Description)
+		}
+	}
+}
+
+// transientPollError reports whether a poll which failed with err is worth
+// repeating, as a GET retried by WithRetries would be.
+func transientPollError(ctx context.Context, err error) bool {
+	var clientErr *Error
+	if errors.As(err, &clientErr) {
+		return retryable(ctx, clientErr.StatusCode, err)
+	}
+	return retryable(ctx, 0, err)
+}
+
+// pollRetryDelay returns how long to wait before polling again after the
+// given number of polls in a row failed, the last one with err.
+func pollRetryDelay(backoff Backoff, failures int, err error) time.Duration {
+	var clientErr *Error
+	if errors.As(err, &clientErr) && clientErr.RetryAfter > 0 {
+		if clientErr.RetryAfter > backoff.Max {
+			return backoff.Max
+		}
+		return clientErr.RetryAfter
+	}
+	return backoff.delay(failures)
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// DefaultUpgradeCheckInterval is how often an UpgradeSchedule checks for
+// candidates while one of its windows is open, unless it sets another
+// interval.
+const DefaultUpgradeCheckInterval = 10 * time.Minute
+
+// MaintenanceWindow is a time of day during which instances may be upgraded,
+// such as three hours from 02:00 on weekdays.
+type MaintenanceWindow struct {
+	// Start is how long after midnight the window opens.
+	Start    time.Duration
+	Duration time.Duration
+
+	// Days limits the window to the given days of the week. It opens every
+	// day when empty.
+	Days []time.Weekday
+
+	// Location is the time zone of Start and Days. It defaults to UTC.
+	Location *time.Location
+}
+
+// UpgradeSchedule upgrades instances which are behind the maintenance_info of
+// their plan, but only while one of its Windows is open.
+type UpgradeSchedule struct {
+	Windows []MaintenanceWindow
+
+	// Candidates returns the instances to upgrade each time the schedule
+	// checks, such as those listed by the GET /admin/upgrade_candidates
+	// extension endpoint or returned by brokerapi.UpgradeCandidates.
+	Candidates func(context.Context) ([]domain.UpgradeCandidate, error)
+
+	// Upgrade configures the upgrades of the candidates of each plan. Its
+	// ServiceID, PlanID and MaintenanceInfo are taken from the candidates.
+	Upgrade FleetUpgrade
+
+	// CheckInterval is how long to wait between checks for candidates while
+	// a window is open. It defaults to DefaultUpgradeCheckInterval.
+	CheckInterval time.Duration
+}
+
+// RunUpgradeSchedule upgrades the candidates of schedule while its windows
+// are open, until ctx is done or fetching the candidates fails, and returns
+// the error. When a window closes, the upgrades still running are cancelled
+// like those of a FleetUpgrade whose context is done; instances they left
+// behind are candidates again in the next window.
+func (c *Client) RunUpgradeSchedule(ctx context.Context, schedule UpgradeSchedule) error {
+	if schedule.CheckInterval <= 0 {
+		schedule.CheckInterval = DefaultUpgradeCheckInterval
+	}
+	for {
+		start, end, ok := nextWindow(schedule.Windows, time.Now())
+		if !ok {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if !sleep(ctx, time.Until(start)) {
+			return ctx.Err()
+		}
+		if err := c.upgradeUntil(ctx, end, schedule); err != nil {
+			return err
+		}
+	}
+}
+
+// upgradeUntil upgrades the candidates of schedule until the window closing
+// at end does. It returns nil when the window closed first.
+func (c *Client) upgradeUntil(ctx context.Context, end time.Time, schedule UpgradeSchedule) error {
+	window, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+	for {
+		candidates, err := schedule.Candidates(window)
+		if err != nil {
+			if window.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		for _, plan := range candidatesByPlan(candidates) {
+			upgrade := schedule.Upgrade
+			upgrade.ServiceID = plan.serviceID
+			upgrade.PlanID = plan.planID
+			upgrade.MaintenanceInfo = plan.maintenanceInfo
+			c.UpgradeFleet(window, plan.instanceIDs, upgrade)
+		}
+		if !sleep(window, schedule.CheckInterval) {
+			return ctx.Err()
+		}
+	}
+}
+
+type planCandidates struct {
+	serviceID       string
+	planID          string
+	maintenanceInfo domain.MaintenanceInfo
+	instanceIDs     []string
+}
+
+// candidatesByPlan groups candidates by their plan, in the order the plans
+// first appear. The candidates of a plan share its target maintenance_info.
+func candidatesByPlan(candidates []domain.UpgradeCandidate) []*planCandidates {
+	var plans []*planCandidates
+	indexes := map[string]int{}
+	for _, candidate := range candidates {
+		key := candidate.ServiceID + "/" + candidate.PlanID
+		index, ok := indexes[key]
+		if !ok {
+			index = len(plans)
+			indexes[key] = index
+			plans = append(plans, &planCandidates{
+				serviceID:       candidate.ServiceID,
+				planID:          candidate.PlanID,
+				maintenanceInfo: candidate.TargetMaintenanceInfo,
+			})
+		}
+		plans[index].instanceIDs = append(plans[index].instanceIDs, candidate.InstanceID)
+	}
+	return plans
+}
+
+// nextWindow returns when the first of windows which is open at now, or
+// opens after it, opens and closes.
+func nextWindow(windows []MaintenanceWindow, now time.Time) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	for _, window := range windows {
+		windowStart, windowEnd, ok := window.next(now)
+		if ok && (end.IsZero() || windowStart.Before(start)) {
+			start, end = windowStart, windowEnd
+		}
+	}
+	return start, end, !end.IsZero()
+}
+
+// next returns when the window which is open at now, or the first one after
+// it, opens and closes. Windows may span midnight, so the one which opened
+// the day before is considered too.
+func (w MaintenanceWindow) next(now time.Time) (time.Time, time.Time, bool) {
+	if w.Duration <= 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	location := w.Location
+	if location == nil {
+		location = time.UTC
+	}
+	now = now.In(location)
+	for day := -1; day <= 7; day++ {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+day, 0, 0, 0, 0, location)
+		if !w.opensOn(midnight.Weekday()) {
+			continue
+		}
+		start := midnight.Add(w.Start)
+		if end := start.Add(w.Duration); end.After(now) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (w MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
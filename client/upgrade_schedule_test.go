@@ -0,0 +1,136 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("RunUpgradeSchedule", func() {
+	var (
+		fakeBroker *fakes.AutoFakeServiceBroker
+		server     *httptest.Server
+		c          *client.Client
+		checks     int32
+		schedule   client.UpgradeSchedule
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+	small := brokerapi.MaintenanceInfo{Public: map[string]string{"version": "2"}}
+	large := brokerapi.MaintenanceInfo{Public: map[string]string{"version": "3"}}
+	alwaysOpen := client.MaintenanceWindow{Duration: 24 * time.Hour}
+
+	BeforeEach(func() {
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.ServicesReturns([]brokerapi.Service{{
+			ID:            "service-id",
+			PlanUpdatable: true,
+			Plans: []brokerapi.ServicePlan{
+				{ID: "small-id", MaintenanceInfo: &small},
+				{ID: "large-id", MaintenanceInfo: &large},
+			},
+		}}, nil)
+		server = httptest.NewServer(brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials))
+
+		var err error
+		c, err = client.New(server.URL, credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+
+		checks = 0
+		schedule = client.UpgradeSchedule{
+			Windows: []client.MaintenanceWindow{alwaysOpen},
+			Candidates: func(context.Context) ([]brokerapi.UpgradeCandidate, error) {
+				if atomic.AddInt32(&checks, 1) > 1 {
+					return nil, nil
+				}
+				return []brokerapi.UpgradeCandidate{
+					{InstanceID: "a", ServiceID: "service-id", PlanID: "small-id", TargetMaintenanceInfo: small},
+					{InstanceID: "b", ServiceID: "service-id", PlanID: "large-id", TargetMaintenanceInfo: large},
+					{InstanceID: "c", ServiceID: "service-id", PlanID: "small-id", TargetMaintenanceInfo: small},
+				}, nil
+			},
+			CheckInterval: 10 * time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	run := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		return c.RunUpgradeSchedule(ctx, schedule)
+	}
+
+	It("upgrades the candidates of each plan while a window is open", func() {
+		Expect(run()).To(MatchError(context.DeadlineExceeded))
+		Expect(atomic.LoadInt32(&checks)).To(BeNumerically(">", 1))
+
+		Expect(fakeBroker.UpdateCallCount()).To(Equal(3))
+		upgraded := map[string]brokerapi.UpdateDetails{}
+		for i := 0; i < fakeBroker.UpdateCallCount(); i++ {
+			_, instanceID, details, _ := fakeBroker.UpdateArgsForCall(i)
+			upgraded[instanceID] = details
+		}
+		Expect(upgraded["a"].PlanID).To(Equal("small-id"))
+		Expect(upgraded["a"].MaintenanceInfo).To(Equal(small))
+		Expect(upgraded["b"].PlanID).To(Equal("large-id"))
+		Expect(upgraded["b"].MaintenanceInfo).To(Equal(large))
+		Expect(upgraded["c"].MaintenanceInfo).To(Equal(small))
+	})
+
+	It("waits for a window to open", func() {
+		today := time.Now().UTC().Weekday()
+		schedule.Windows = []client.MaintenanceWindow{{
+			Start:    time.Hour,
+			Duration: time.Hour,
+			Days:     []time.Weekday{(today + 2) % 7, (today + 4) % 7},
+		}}
+
+		Expect(run()).To(MatchError(context.DeadlineExceeded))
+		Expect(atomic.LoadInt32(&checks)).To(BeZero())
+		Expect(fakeBroker.UpdateCallCount()).To(BeZero())
+	})
+
+	It("takes the days of a window in its location", func() {
+		location := time.FixedZone("UTC+14", 14*60*60)
+		today := time.Now().In(location).Weekday()
+		schedule.Windows = []client.MaintenanceWindow{{Duration: 24 * time.Hour, Days: []time.Weekday{today}, Location: location}}
+
+		Expect(run()).To(MatchError(context.DeadlineExceeded))
+		Expect(fakeBroker.UpdateCallCount()).To(Equal(3))
+	})
+
+	It("stops when the candidates cannot be fetched", func() {
+		schedule.Candidates = func(context.Context) ([]brokerapi.UpgradeCandidate, error) {
+			return nil, errors.New("admin API unavailable")
+		}
+
+		Expect(run()).To(MatchError("admin API unavailable"))
+	})
+})
@@ -0,0 +1,206 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("UpgradeFleet", func() {
+	var (
+		fakeBroker *fakes.AutoFakeServiceBroker
+		server     *httptest.Server
+		c          *client.Client
+		upgrade    client.FleetUpgrade
+		polls      map[string]int
+		mutex      sync.Mutex
+	)
+
+	credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+	maintenanceInfo := brokerapi.MaintenanceInfo{Public: map[string]string{"version": "2"}}
+
+	BeforeEach(func() {
+		polls = map[string]int{}
+		fakeBroker = new(fakes.AutoFakeServiceBroker)
+		fakeBroker.ServicesReturns([]brokerapi.Service{{
+			ID:            "service-id",
+			PlanUpdatable: true,
+			Plans:         []brokerapi.ServicePlan{{ID: "plan-id", MaintenanceInfo: &maintenanceInfo}},
+		}}, nil)
+		fakeBroker.UpdateStub = func(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (brokerapi.UpdateServiceSpec, error) {
+			switch instanceID {
+			case "rejected-id":
+				return brokerapi.UpdateServiceSpec{}, brokerapi.ErrMaintenanceInfoConflict
+			case "async-id", "failing-id":
+				return brokerapi.UpdateServiceSpec{IsAsync: true, OperationData: "upgrade-" + instanceID}, nil
+			}
+			return brokerapi.UpdateServiceSpec{}, nil
+		}
+		fakeBroker.LastOperationStub = func(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			polls[instanceID]++
+			switch {
+			case polls[instanceID] < 2:
+				return brokerapi.LastOperation{State: brokerapi.InProgress}, nil
+			case instanceID == "failing-id":
+				return brokerapi.LastOperation{State: brokerapi.Failed, Description: "disk full"}, nil
+			}
+			return brokerapi.LastOperation{State: brokerapi.Succeeded}, nil
+		}
+		server = httptest.NewServer(brokerapi.New(fakeBroker, lager.NewLogger("broker"), credentials))
+
+		var err error
+		c, err = client.New(server.URL, credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+		upgrade = client.FleetUpgrade{
+			ServiceID:       "service-id",
+			PlanID:          "plan-id",
+			MaintenanceInfo: maintenanceInfo,
+			PollInterval:    time.Millisecond,
+			PollBackoff:     client.Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends maintenance_info-only updates and polls asynchronous ones to completion", func() {
+		summary := c.UpgradeFleet(context.Background(), []string{"sync-id", "async-id"}, upgrade)
+		Expect(summary.Succeeded).To(Equal(2))
+		Expect(summary.Failed).To(Equal(0))
+		Expect(summary.Results).To(HaveLen(2))
+		Expect(summary.Results[0].InstanceID).To(Equal("sync-id"))
+		Expect(summary.Results[0].Async).To(BeFalse())
+		Expect(summary.Results[1].InstanceID).To(Equal("async-id"))
+		Expect(summary.Results[1].Async).To(BeTrue())
+
+		_, _, details, asyncAllowed := fakeBroker.UpdateArgsForCall(0)
+		Expect(details.ServiceID).To(Equal("service-id"))
+		Expect(details.PlanID).To(Equal("plan-id"))
+		Expect(details.MaintenanceInfo).To(Equal(maintenanceInfo))
+		Expect(details.RawParameters).To(BeEmpty())
+		Expect(asyncAllowed).To(BeTrue())
+
+		Expect(fakeBroker.LastOperationCallCount()).To(Equal(2))
+		_, instanceID, pollDetails := fakeBroker.LastOperationArgsForCall(1)
+		Expect(instanceID).To(Equal("async-id"))
+		Expect(pollDetails.OperationData).To(Equal("upgrade-async-id"))
+	})
+
+	It("reports the instances which were not upgraded", func() {
+		summary := c.UpgradeFleet(context.Background(), []string{"sync-id", "rejected-id", "failing-id"}, upgrade)
+		Expect(summary.Succeeded).To(Equal(1))
+		Expect(summary.Failed).To(Equal(2))
+
+		failures := summary.Failures()
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[0].InstanceID).To(Equal("rejected-id"))
+		var clientErr *client.Error
+		Expect(errors.As(failures[0].Err, &clientErr)).To(BeTrue())
+		Expect(clientErr.IsMaintenanceInfoConflict()).To(BeTrue())
+		Expect(failures[1].InstanceID).To(Equal("failing-id"))
+		Expect(errors.Is(failures[1].Err, client.ErrUpgradeFailed)).To(BeTrue())
+		Expect(failures[1].Err).To(MatchError("upgrade failed: disk full"))
+	})
+
+	It("retries polls which fail transiently", func() {
+		fakeBroker.LastOperationStub = func(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			polls[instanceID]++
+			if polls[instanceID] < 3 {
+				return brokerapi.LastOperation{}, apiresponses.NewFailureResponse(errors.New("restarting"), http.StatusServiceUnavailable, "restarting")
+			}
+			return brokerapi.LastOperation{State: brokerapi.Succeeded}, nil
+		}
+
+		summary := c.UpgradeFleet(context.Background(), []string{"async-id"}, upgrade)
+		Expect(summary.Results[0].Err).NotTo(HaveOccurred())
+		Expect(fakeBroker.LastOperationCallCount()).To(Equal(3))
+	})
+
+	It("fails when polls keep failing or fail for good", func() {
+		fakeBroker.LastOperationStub = func(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+			if instanceID == "failing-id" {
+				return brokerapi.LastOperation{}, apiresponses.NewFailureResponse(errors.New("bad request"), http.StatusBadRequest, "bad-request")
+			}
+			return brokerapi.LastOperation{}, apiresponses.NewFailureResponse(errors.New("restarting"), http.StatusServiceUnavailable, "restarting")
+		}
+
+		summary := c.UpgradeFleet(context.Background(), []string{"async-id", "failing-id"}, upgrade)
+		Expect(summary.Results[0].Err).To(MatchError("restarting"))
+		Expect(summary.Results[1].Err).To(MatchError("bad request"))
+		Expect(fakeBroker.LastOperationCallCount()).To(Equal(4))
+	})
+
+	It("upgrades at most Concurrency instances at once", func() {
+		var running, peak int32
+		fakeBroker.UpdateStub = func(context.Context, string, brokerapi.UpdateDetails, bool) (brokerapi.UpdateServiceSpec, error) {
+			now := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				seen := atomic.LoadInt32(&peak)
+				if now <= seen || atomic.CompareAndSwapInt32(&peak, seen, now) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return brokerapi.UpdateServiceSpec{}, nil
+		}
+		upgrade.Concurrency = 2
+
+		var done int32
+		upgrade.Done = func(client.UpgradeResult) { atomic.AddInt32(&done, 1) }
+		summary := c.UpgradeFleet(context.Background(), []string{"a", "b", "c", "d", "e"}, upgrade)
+		Expect(summary.Succeeded).To(Equal(5))
+		Expect(atomic.LoadInt32(&peak)).To(Equal(int32(2)))
+		Expect(atomic.LoadInt32(&done)).To(Equal(int32(5)))
+	})
+
+	It("fails the instances left when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		summary := c.UpgradeFleet(ctx, []string{"sync-id"}, upgrade)
+		Expect(summary.Failed).To(Equal(1))
+		Expect(summary.Results[0].Err).To(MatchError(context.Canceled))
+		Expect(fakeBroker.UpdateCallCount()).To(Equal(0))
+	})
+
+	It("limits how long each instance may take", func() {
+		upgrade.PollInterval = time.Hour
+		upgrade.Timeout = 10 * time.Millisecond
+
+		summary := c.UpgradeFleet(context.Background(), []string{"async-id"}, upgrade)
+		Expect(summary.Results[0].Err).To(MatchError(context.DeadlineExceeded))
+	})
+})
@@ -0,0 +1,154 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudevents publishes broker lifecycle events as CloudEvents
+// (https://cloudevents.io) in the structured JSON format.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sharma-tapas/brokerapi"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification implemented.
+	SpecVersion = "1.0"
+	// ContentType is the media type of a CloudEvent in structured JSON mode.
+	ContentType = "application/cloudevents+json"
+	// DefaultTypePrefix is prepended to the lifecycle event type to form the
+	// CloudEvents type attribute, e.g. "org.openservicebroker.instance.provisioned".
+	DefaultTypePrefix = "org.openservicebroker."
+)
+
+// Event is a CloudEvent carrying a brokerapi.LifecycleEvent as its data.
+type Event struct {
+	SpecVersion     string                   `json:"specversion"`
+	ID              string                   `json:"id"`
+	Source          string                   `json:"source"`
+	Type            string                   `json:"type"`
+	Subject         string                   `json:"subject,omitempty"`
+	Time            time.Time                `json:"time"`
+	DataContentType string                   `json:"datacontenttype"`
+	Data            brokerapi.LifecycleEvent `json:"data"`
+}
+
+// Sink delivers CloudEvents to their destination.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Send(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Emitter converts lifecycle events to CloudEvents and passes them to a Sink.
+// It implements brokerapi.LifecycleEventSink, so it can be registered with
+// brokerapi.WithLifecycleEventSink.
+type Emitter struct {
+	sink       Sink
+	source     string
+	typePrefix string
+}
+
+// NewEmitter returns an Emitter which sends events to sink. The source is
+// used as the CloudEvents source attribute and should identify the broker,
+// for example "https://broker.example.com".
+func NewEmitter(source string, sink Sink) *Emitter {
+	return &Emitter{
+		sink:       sink,
+		source:     source,
+		typePrefix: DefaultTypePrefix,
+	}
+}
+
+// WithTypePrefix replaces DefaultTypePrefix in the type of emitted events.
+func (e *Emitter) WithTypePrefix(prefix string) *Emitter {
+	e.typePrefix = prefix
+	return e
+}
+
+func (e *Emitter) Emit(ctx context.Context, event brokerapi.LifecycleEvent) error {
+	return e.sink.Send(ctx, e.NewEvent(event))
+}
+
+// NewEvent wraps a lifecycle event in a CloudEvent. The subject is the
+// instance ID, followed by the binding ID for binding events.
+func (e *Emitter) NewEvent(event brokerapi.LifecycleEvent) Event {
+	subject := event.InstanceID
+	if event.BindingID != "" {
+		subject = fmt.Sprintf("%s/%s", event.InstanceID, event.BindingID)
+	}
+
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.NewRandom().String(),
+		Source:          e.source,
+		Type:            e.typePrefix + string(event.Type),
+		Subject:         subject,
+		Time:            event.Time,
+		DataContentType: "application/json",
+		Data:            event,
+	}
+}
+
+// HTTPSink posts each event to an HTTP endpoint in structured content mode.
+type HTTPSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPSink returns a sink posting to endpoint. If client is nil, a client
+// with a 10 second timeout is used.
+func NewHTTPSink(endpoint string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSink{endpoint: endpoint, client: client}
+}
+
+// Send posts the event and fails unless the endpoint responds with a 2xx status.
+func (s *HTTPSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cloudevents endpoint %s responded with status %d", s.endpoint, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudevents_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/cloudevents"
+)
+
+var _ = Describe("CloudEvents", func() {
+	var lifecycleEvent brokerapi.LifecycleEvent
+
+	BeforeEach(func() {
+		lifecycleEvent = brokerapi.LifecycleEvent{
+			Type:       brokerapi.EventBindingDeleted,
+			Time:       time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+			InstanceID: "instance-id",
+			BindingID:  "binding-id",
+			ServiceID:  "service-id",
+			PlanID:     "plan-id",
+		}
+	})
+
+	Describe("Emitter", func() {
+		It("wraps lifecycle events in CloudEvents", func() {
+			event := cloudevents.NewEmitter("https://broker.example.com", nil).NewEvent(lifecycleEvent)
+
+			Expect(event.SpecVersion).To(Equal("1.0"))
+			Expect(event.ID).NotTo(BeEmpty())
+			Expect(event.Source).To(Equal("https://broker.example.com"))
+			Expect(event.Type).To(Equal("org.openservicebroker.binding.deleted"))
+			Expect(event.Subject).To(Equal("instance-id/binding-id"))
+			Expect(event.Time).To(Equal(lifecycleEvent.Time))
+			Expect(event.DataContentType).To(Equal("application/json"))
+			Expect(event.Data).To(Equal(lifecycleEvent))
+		})
+
+		It("uses the instance ID as the subject of instance events", func() {
+			lifecycleEvent.Type = brokerapi.EventInstanceProvisioned
+			lifecycleEvent.BindingID = ""
+
+			event := cloudevents.NewEmitter("broker", nil).NewEvent(lifecycleEvent)
+			Expect(event.Subject).To(Equal("instance-id"))
+		})
+
+		It("uses a custom type prefix", func() {
+			event := cloudevents.NewEmitter("broker", nil).WithTypePrefix("com.example.").NewEvent(lifecycleEvent)
+			Expect(event.Type).To(Equal("com.example.binding.deleted"))
+		})
+
+		It("passes events to the sink", func() {
+			var sent []cloudevents.Event
+			sink := cloudevents.SinkFunc(func(ctx context.Context, event cloudevents.Event) error {
+				sent = append(sent, event)
+				return nil
+			})
+
+			err := cloudevents.NewEmitter("broker", sink).Emit(context.Background(), lifecycleEvent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent).To(HaveLen(1))
+			Expect(sent[0].Data).To(Equal(lifecycleEvent))
+		})
+	})
+
+	Describe("HTTPSink", func() {
+		var (
+			server      *httptest.Server
+			statusCode  int
+			contentType string
+			body        []byte
+		)
+
+		BeforeEach(func() {
+			statusCode = http.StatusAccepted
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				contentType = req.Header.Get("Content-Type")
+				body, _ = ioutil.ReadAll(req.Body)
+				w.WriteHeader(statusCode)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("posts events in structured mode", func() {
+			emitter := cloudevents.NewEmitter("broker", cloudevents.NewHTTPSink(server.URL, nil))
+
+			Expect(emitter.Emit(context.Background(), lifecycleEvent)).To(Succeed())
+			Expect(contentType).To(Equal("application/cloudevents+json"))
+
+			var received map[string]interface{}
+			Expect(json.Unmarshal(body, &received)).To(Succeed())
+			Expect(received).To(HaveKeyWithValue("specversion", "1.0"))
+			Expect(received).To(HaveKeyWithValue("type", "org.openservicebroker.binding.deleted"))
+			Expect(received).To(HaveKeyWithValue("time", "2019-01-02T03:04:05Z"))
+			Expect(received["data"]).To(HaveKeyWithValue("binding_id", "binding-id"))
+		})
+
+		It("fails when the endpoint does not accept the event", func() {
+			statusCode = http.StatusInternalServerError
+			emitter := cloudevents.NewEmitter("broker", cloudevents.NewHTTPSink(server.URL, nil))
+
+			err := emitter.Emit(context.Background(), lifecycleEvent)
+			Expect(err).To(MatchError(ContainSubstring("responded with status 500")))
+		})
+	})
+})
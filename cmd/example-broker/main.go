@@ -0,0 +1,173 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command example-broker serves the in-memory reference broker, configured
+// with the config package. It is a starting point for new brokers, showing
+// how the pieces of brokerapi fit together, and a broker to run platform
+// integration tests against:
+//
+//	BROKER_CREDENTIALS_USERNAME=admin BROKER_CREDENTIALS_PASSWORD=secret example-broker -async-delay 10s
+//
+// Flags:
+//
+//	-config        configuration file, see the config package (default $BROKER_CONFIG)
+//	-catalog       catalog file or directory, see catalog.NewFileProvider; a
+//	               built-in catalog with one service is used when empty
+//	-async-delay   how long asynchronous operations stay in progress
+//	-metrics       address to serve request counters on, at /debug/vars
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/catalog"
+	"github.com/sharma-tapas/brokerapi/config"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/inmemory"
+	"github.com/sharma-tapas/brokerapi/middlewares/access_log"
+)
+
+const shutdownTimeout = 30 * time.Second
+
+func main() {
+	configPath := flag.String("config", os.Getenv("BROKER_CONFIG"), "configuration file")
+	catalogPath := flag.String("catalog", "", "catalog file or directory")
+	asyncDelay := flag.Duration("async-delay", 0, "how long asynchronous operations stay in progress")
+	metricsAddress := flag.String("metrics", "", "address to serve request counters on")
+	flag.Parse()
+
+	logger := lager.NewLogger("example-broker")
+	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.DEBUG))
+
+	if err := run(logger, *configPath, *catalogPath, *asyncDelay, *metricsAddress); err != nil {
+		logger.Error("exited", err)
+		os.Exit(1)
+	}
+}
+
+func run(logger lager.Logger, configPath, catalogPath string, asyncDelay time.Duration, metricsAddress string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	services := defaultServices
+	if catalogPath != "" {
+		provider, err := catalog.NewFileProvider(catalogPath, logger)
+		if err != nil {
+			return err
+		}
+		if services, err = provider.Services(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	handler, err := newHandler(cfg, inmemory.New(inmemory.Config{Services: services, AsyncDelay: asyncDelay}), logger, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if metricsAddress != "" {
+		go func() {
+			if err := http.ListenAndServe(metricsAddress, metricsHandler()); err != nil {
+				logger.Error("serving-metrics-failed", err)
+			}
+		}()
+	}
+
+	return serve(cfg, handler, logger)
+}
+
+// newHandler wires the broker into the handler configured by cfg, with access
+// logs written to accessLog and request counters.
+func newHandler(cfg *config.Config, serviceBroker domain.ServiceBroker, logger lager.Logger, accessLog io.Writer) (http.Handler, error) {
+	opts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts,
+		brokerapi.WithMiddleware(access_log.New(accessLog, access_log.JSONFormat).Wrap),
+		brokerapi.WithMiddleware(countRequests),
+	)
+	return brokerapi.NewWithOptions(serviceBroker, logger, opts...), nil
+}
+
+// serve runs the server until SIGINT or SIGTERM, then lets in-flight requests
+// finish before returning.
+func serve(cfg *config.Config, handler http.Handler, logger lager.Logger) error {
+	serverOpts, err := cfg.ServerOptions()
+	if err != nil {
+		return err
+	}
+	listener, err := brokerapi.Listen(cfg.Listen)
+	if err != nil {
+		return err
+	}
+	server := brokerapi.NewServer(handler, serverOpts...)
+
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.TLS.CertFile != "" {
+			errs <- server.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			errs <- server.Serve(listener)
+		}
+	}()
+	logger.Info("listening", lager.Data{"address": listener.Addr().String()})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		logger.Info("shutting-down", lager.Data{"signal": sig.String()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down: %s", err)
+	}
+	return nil
+}
+
+var defaultServices = []domain.Service{
+	{
+		ID:                   "2a3e6f8a-5d4b-4c55-9c55-4c06f9b6d8f1",
+		Name:                 "example",
+		Description:          "An example service kept in memory",
+		Bindable:             true,
+		InstancesRetrievable: true,
+		BindingsRetrievable:  true,
+		PlanUpdatable:        true,
+		Plans: []domain.ServicePlan{
+			{ID: "7d3a2f1e-0c5f-4c2d-8a4b-9e6b1f0d2c11", Name: "small", Description: "A small instance", Free: domain.FreeValue(true)},
+			{ID: "c9b4e2a7-3f6d-4b8e-a1c5-2d7f0e9b3a42", Name: "large", Description: "A large instance", Free: domain.FreeValue(false)},
+		},
+	},
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/config"
+	"github.com/sharma-tapas/brokerapi/inmemory"
+)
+
+var _ = Describe("example-broker", func() {
+	var tester brokertest.BrokerTester
+
+	BeforeEach(func() {
+		cfg := config.Default()
+		cfg.Credentials = config.Credentials{Username: "admin", Password: "secret"}
+		Expect(cfg.Validate()).To(Succeed())
+
+		broker := inmemory.New(inmemory.Config{Services: defaultServices, AsyncDelay: 20 * time.Millisecond})
+		handler, err := newHandler(cfg, broker, lagertest.NewTestLogger("example-broker"), ioutil.Discard)
+		Expect(err).NotTo(HaveOccurred())
+		tester = brokertest.New(handler, "admin", "secret")
+	})
+
+	It("provisions, binds and deletes instances", func() {
+		service := defaultServices[0]
+		details := map[string]string{"service_id": service.ID, "plan_id": service.Plans[0].ID}
+
+		Expect(tester.Catalog().Code).To(Equal(http.StatusOK))
+		Expect(tester.Provision("instance-id", details, false).Code).To(Equal(http.StatusCreated))
+		Expect(tester.Bind("instance-id", "binding-id", details, false).Code).To(Equal(http.StatusCreated))
+		Expect(tester.GetBinding("instance-id", "binding-id").Code).To(Equal(http.StatusOK))
+		Expect(tester.Unbind("instance-id", "binding-id", service.ID, service.Plans[0].ID, false).Code).To(Equal(http.StatusOK))
+		Expect(tester.Deprovision("instance-id", service.ID, service.Plans[0].ID, false).Code).To(Equal(http.StatusOK))
+	})
+
+	It("completes asynchronous provisions", func() {
+		service := defaultServices[0]
+		details := map[string]string{"service_id": service.ID, "plan_id": service.Plans[1].ID}
+
+		response := tester.Provision("async-instance-id", details, true)
+		Expect(response.Code).To(Equal(http.StatusAccepted))
+		var body struct {
+			Operation string `json:"operation"`
+		}
+		Expect(json.Unmarshal(response.Body.Bytes(), &body)).To(Succeed())
+
+		Eventually(func() string {
+			return tester.LastOperation("async-instance-id", body.Operation).Body.String()
+		}).Should(ContainSubstring(`"state":"succeeded"`))
+	})
+
+	It("counts requests by operation and status", func() {
+		tester.Catalog()
+		tester.WithoutAuth().Catalog()
+
+		recorder := httptest.NewRecorder()
+		metricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/vars", nil))
+		var vars struct {
+			Requests map[string]int `json:"requests"`
+		}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &vars)).To(Succeed())
+		Expect(vars.Requests["catalog 200"]).To(BeNumerically(">=", 1))
+		Expect(vars.Requests["catalog 401"]).To(BeNumerically(">=", 1))
+	})
+})
@@ -0,0 +1,48 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+// requests counts the requests served, by operation and status, such as
+// "provision 201".
+var requests = expvar.NewMap("requests")
+
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		recorder := statusrecorder.New(w)
+		next.ServeHTTP(recorder, req)
+
+		operation := "unknown"
+		if route := mux.CurrentRoute(req); route != nil && route.GetName() != "" {
+			operation = route.GetName()
+		}
+		requests.Add(fmt.Sprintf("%s %d", operation, recorder.Status()), 1)
+	})
+}
+
+func metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
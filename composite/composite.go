@@ -0,0 +1,294 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package composite serves the services of several upstream brokers from a
+// single broker, as platform teams aggregating vendor brokers do. The catalogs
+// of the upstreams are merged, with a prefix per upstream keeping their IDs
+// and names apart, and each request is passed on to the upstream owning its
+// service.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sharma-tapas/brokerapi/client"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const unknownServiceKey = "unknown-service"
+
+var _ domain.ServiceBroker = (*Broker)(nil)
+
+// Upstream is one of the brokers a composite broker is made of.
+type Upstream struct {
+	// Prefix is added to the IDs of the services and plans of the upstream,
+	// and to the names of its services. It must be unique, and no prefix may
+	// start with another.
+	Prefix string
+	// Broker is usually a *client.Client for a remote broker, but brokers in
+	// the same process can be used directly.
+	Broker domain.ServiceBroker
+}
+
+// NewUpstream returns an Upstream calling the broker at brokerURL.
+func NewUpstream(prefix, brokerURL, username, password string, opts ...client.Option) (Upstream, error) {
+	broker, err := client.New(brokerURL, username, password, opts...)
+	if err != nil {
+		return Upstream{}, err
+	}
+	return Upstream{Prefix: prefix, Broker: broker}, nil
+}
+
+// Broker passes requests on to its upstreams. Requests carrying a service_id
+// go to the upstream of its prefix. Those which do not, such as fetching an
+// instance, go to the upstream which provisioned the instance through this
+// Broker, and when it is not known, to each upstream in turn until one does
+// not respond that the instance or binding is missing. The upstream of an
+// instance is forgotten once it is deprovisioned, for asynchronous
+// deprovisions when LastOperation reports that they succeeded.
+type Broker struct {
+	upstreams []Upstream
+
+	// owners maps the IDs of instances to the index of their upstream.
+	owners sync.Map
+	// deprovisioning holds the IDs of instances whose asynchronous
+	// deprovision has not finished, so that LastOperation can forget their
+	// owner once it has.
+	deprovisioning sync.Map
+}
+
+// New returns a Broker for upstreams. It panics if a prefix is empty or
+// starts with another.
+func New(upstreams ...Upstream) *Broker {
+	for i, upstream := range upstreams {
+		if upstream.Prefix == "" {
+			panic("composite: every upstream needs a prefix")
+		}
+		for j, other := range upstreams {
+			if i != j && strings.HasPrefix(upstream.Prefix, other.Prefix) {
+				panic(fmt.Sprintf("composite: prefix %q starts with prefix %q", upstream.Prefix, other.Prefix))
+			}
+		}
+	}
+	return &Broker{upstreams: upstreams}
+}
+
+// Services returns the merged catalogs of the upstreams. It fails if any of
+// them fails, rather than serve a catalog from which the platform would
+// conclude that services were removed.
+func (b *Broker) Services(ctx context.Context) ([]domain.Service, error) {
+	var services []domain.Service
+	for _, upstream := range b.upstreams {
+		upstreamServices, err := upstream.Broker.Services(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching the catalog of upstream %q: %w", upstream.Prefix, err)
+		}
+		for _, service := range upstreamServices {
+			services = append(services, prefixService(upstream.Prefix, service))
+		}
+	}
+	return services, nil
+}
+
+func prefixService(prefix string, service domain.Service) domain.Service {
+	service.ID = prefix + service.ID
+	service.Name = prefix + service.Name
+	plans := make([]domain.ServicePlan, len(service.Plans))
+	for i, plan := range service.Plans {
+		plan.ID = prefix + plan.ID
+		plans[i] = plan
+	}
+	service.Plans = plans
+	return service
+}
+
+func (b *Broker) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	index, err := b.upstreamFor(details.ServiceID)
+	if err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	prefix := b.upstreams[index].Prefix
+	details.ServiceID = strings.TrimPrefix(details.ServiceID, prefix)
+	details.PlanID = strings.TrimPrefix(details.PlanID, prefix)
+
+	spec, err := b.upstreams[index].Broker.Provision(ctx, instanceID, details, asyncAllowed)
+	if err == nil {
+		b.owners.Store(instanceID, index)
+	}
+	return spec, err
+}
+
+func (b *Broker) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	var spec domain.DeprovisionServiceSpec
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		details.ServiceID = strings.TrimPrefix(details.ServiceID, upstream.Prefix)
+		details.PlanID = strings.TrimPrefix(details.PlanID, upstream.Prefix)
+		spec, err = upstream.Broker.Deprovision(ctx, instanceID, details, asyncAllowed)
+		return err
+	})
+	switch {
+	case err == nil && spec.IsAsync:
+		b.deprovisioning.Store(instanceID, true)
+	case err == nil:
+		b.owners.Delete(instanceID)
+	}
+	return spec, err
+}
+
+func (b *Broker) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	var spec domain.GetInstanceDetailsSpec
+	err := b.route(instanceID, "", func(upstream Upstream) (err error) {
+		spec, err = upstream.Broker.GetInstance(ctx, instanceID)
+		if err == nil {
+			spec.ServiceID = upstream.Prefix + spec.ServiceID
+			spec.PlanID = upstream.Prefix + spec.PlanID
+		}
+		return err
+	})
+	return spec, err
+}
+
+func (b *Broker) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	var spec domain.UpdateServiceSpec
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		details.ServiceID = strings.TrimPrefix(details.ServiceID, upstream.Prefix)
+		details.PlanID = strings.TrimPrefix(details.PlanID, upstream.Prefix)
+		details.PreviousValues.ServiceID = strings.TrimPrefix(details.PreviousValues.ServiceID, upstream.Prefix)
+		details.PreviousValues.PlanID = strings.TrimPrefix(details.PreviousValues.PlanID, upstream.Prefix)
+		spec, err = upstream.Broker.Update(ctx, instanceID, details, asyncAllowed)
+		return err
+	})
+	return spec, err
+}
+
+func (b *Broker) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	var operation domain.LastOperation
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		operation, err = upstream.Broker.LastOperation(ctx, instanceID, unprefixPoll(upstream.Prefix, details))
+		return err
+	})
+	if _, ok := b.deprovisioning.Load(instanceID); ok {
+		switch {
+		case err == nil && operation.State == domain.Succeeded, errors.Is(err, apiresponses.ErrInstanceDoesNotExist):
+			b.deprovisioning.Delete(instanceID)
+			b.owners.Delete(instanceID)
+		case err == nil && operation.State == domain.Failed:
+			b.deprovisioning.Delete(instanceID)
+		}
+	}
+	return operation, err
+}
+
+func (b *Broker) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, asyncAllowed bool) (domain.Binding, error) {
+	var binding domain.Binding
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		details.ServiceID = strings.TrimPrefix(details.ServiceID, upstream.Prefix)
+		details.PlanID = strings.TrimPrefix(details.PlanID, upstream.Prefix)
+		binding, err = upstream.Broker.Bind(ctx, instanceID, bindingID, details, asyncAllowed)
+		return err
+	})
+	return binding, err
+}
+
+func (b *Broker) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	var spec domain.UnbindSpec
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		details.ServiceID = strings.TrimPrefix(details.ServiceID, upstream.Prefix)
+		details.PlanID = strings.TrimPrefix(details.PlanID, upstream.Prefix)
+		spec, err = upstream.Broker.Unbind(ctx, instanceID, bindingID, details, asyncAllowed)
+		return err
+	})
+	return spec, err
+}
+
+func (b *Broker) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	var spec domain.GetBindingSpec
+	err := b.route(instanceID, "", func(upstream Upstream) (err error) {
+		spec, err = upstream.Broker.GetBinding(ctx, instanceID, bindingID)
+		return err
+	})
+	return spec, err
+}
+
+func (b *Broker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	var operation domain.LastOperation
+	err := b.route(instanceID, details.ServiceID, func(upstream Upstream) (err error) {
+		operation, err = upstream.Broker.LastBindingOperation(ctx, instanceID, bindingID, unprefixPoll(upstream.Prefix, details))
+		return err
+	})
+	return operation, err
+}
+
+func unprefixPoll(prefix string, details domain.PollDetails) domain.PollDetails {
+	details.ServiceID = strings.TrimPrefix(details.ServiceID, prefix)
+	details.PlanID = strings.TrimPrefix(details.PlanID, prefix)
+	return details
+}
+
+// upstreamFor returns the index of the upstream owning serviceID.
+func (b *Broker) upstreamFor(serviceID string) (int, error) {
+	for i, upstream := range b.upstreams {
+		if strings.HasPrefix(serviceID, upstream.Prefix) {
+			return i, nil
+		}
+	}
+	return 0, apiresponses.NewFailureResponse(
+		fmt.Errorf("service_id %q is not in the catalog", serviceID), http.StatusBadRequest, unknownServiceKey,
+	)
+}
+
+// route calls call with the upstream of a request for instanceID, found from
+// serviceID if it is set.
+func (b *Broker) route(instanceID, serviceID string, call func(Upstream) error) error {
+	if serviceID != "" {
+		index, err := b.upstreamFor(serviceID)
+		if err != nil {
+			return err
+		}
+		return call(b.upstreams[index])
+	}
+	if index, ok := b.owners.Load(instanceID); ok {
+		return call(b.upstreams[index.(int)])
+	}
+
+	err := error(apiresponses.ErrInstanceDoesNotExist)
+	for i, upstream := range b.upstreams {
+		err = call(upstream)
+		if !missing(err) {
+			if err == nil {
+				b.owners.Store(instanceID, i)
+			}
+			return err
+		}
+	}
+	return err
+}
+
+// missing reports whether err is an upstream responding that an instance or
+// binding does not exist.
+func missing(err error) bool {
+	var failure *apiresponses.FailureResponse
+	if !errors.As(err, &failure) {
+		return false
+	}
+	status := failure.ValidatedStatusCode(nil)
+	return status == http.StatusNotFound || status == http.StatusGone
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/composite"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("Broker", func() {
+	var (
+		aws, gcp *fakes.AutoFakeServiceBroker
+		broker   *composite.Broker
+		ctx      context.Context
+	)
+
+	catalog := func(serviceID, planID string) []brokerapi.Service {
+		return []brokerapi.Service{{ID: serviceID, Name: "db", Plans: []brokerapi.ServicePlan{{ID: planID, Name: "small"}}}}
+	}
+
+	BeforeEach(func() {
+		aws = new(fakes.AutoFakeServiceBroker)
+		aws.ServicesReturns(catalog("service-id", "plan-id"), nil)
+		gcp = new(fakes.AutoFakeServiceBroker)
+		gcp.ServicesReturns(catalog("service-id", "plan-id"), nil)
+
+		broker = composite.New(
+			composite.Upstream{Prefix: "aws-", Broker: aws},
+			composite.Upstream{Prefix: "gcp-", Broker: gcp},
+		)
+		ctx = context.Background()
+	})
+
+	It("merges the catalogs of the upstreams with their prefixes", func() {
+		services, err := broker.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(Equal([]brokerapi.Service{
+			{ID: "aws-service-id", Name: "aws-db", Plans: []brokerapi.ServicePlan{{ID: "aws-plan-id", Name: "small"}}},
+			{ID: "gcp-service-id", Name: "gcp-db", Plans: []brokerapi.ServicePlan{{ID: "gcp-plan-id", Name: "small"}}},
+		}))
+	})
+
+	It("fails the catalog when an upstream fails", func() {
+		gcp.ServicesReturns(nil, errors.New("unreachable"))
+
+		_, err := broker.Services(ctx)
+		Expect(err).To(MatchError(`fetching the catalog of upstream "gcp-": unreachable`))
+	})
+
+	It("routes requests by service_id without the prefix", func() {
+		_, err := broker.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.ProvisionCallCount()).To(Equal(0))
+		_, _, details, _ := gcp.ProvisionArgsForCall(0)
+		Expect(details.ServiceID).To(Equal("service-id"))
+		Expect(details.PlanID).To(Equal("plan-id"))
+
+		_, err = broker.Bind(ctx, "instance-id", "binding-id", brokerapi.BindDetails{ServiceID: "aws-service-id", PlanID: "aws-plan-id"}, false)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, _, bindDetails, _ := aws.BindArgsForCall(0)
+		Expect(bindDetails.PlanID).To(Equal("plan-id"))
+
+		_, err = broker.LastOperation(ctx, "instance-id", brokerapi.PollDetails{ServiceID: "gcp-service-id", OperationData: "operation"})
+		Expect(err).NotTo(HaveOccurred())
+		_, _, pollDetails := gcp.LastOperationArgsForCall(0)
+		Expect(pollDetails).To(Equal(brokerapi.PollDetails{ServiceID: "service-id", OperationData: "operation"}))
+	})
+
+	It("rejects unknown services", func() {
+		_, err := broker.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "azure-service-id"}, true)
+		var failure *brokerapi.FailureResponse
+		Expect(errors.As(err, &failure)).To(BeTrue())
+		Expect(failure.ValidatedStatusCode(nil)).To(Equal(400))
+	})
+
+	It("sends requests without a service_id to the upstream which provisioned the instance", func() {
+		_, err := broker.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		gcp.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{ServiceID: "service-id", PlanID: "plan-id"}, nil)
+
+		spec, err := broker.GetInstance(ctx, "instance-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(brokerapi.GetInstanceDetailsSpec{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}))
+		Expect(aws.GetInstanceCallCount()).To(Equal(0))
+	})
+
+	It("asks each upstream in turn for instances it does not know", func() {
+		aws.GetBindingReturns(brokerapi.GetBindingSpec{}, brokerapi.ErrBindingNotFound)
+		gcp.GetBindingReturns(brokerapi.GetBindingSpec{Credentials: "secret"}, nil)
+
+		spec, err := broker.GetBinding(ctx, "instance-id", "binding-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Credentials).To(Equal("secret"))
+
+		_, err = broker.GetBinding(ctx, "instance-id", "binding-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.GetBindingCallCount()).To(Equal(1))
+		Expect(gcp.GetBindingCallCount()).To(Equal(2))
+	})
+
+	It("forgets the upstream of an instance once its asynchronous deprovision succeeded", func() {
+		_, err := broker.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		gcp.DeprovisionReturns(brokerapi.DeprovisionServiceSpec{IsAsync: true, OperationData: "deprovision"}, nil)
+		_, err = broker.Deprovision(ctx, "instance-id", brokerapi.DeprovisionDetails{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+
+		gcp.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+		_, err = broker.LastOperation(ctx, "instance-id", brokerapi.PollDetails{ServiceID: "gcp-service-id", OperationData: "deprovision"})
+		Expect(err).NotTo(HaveOccurred())
+		gcp.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, nil)
+		_, err = broker.GetInstance(ctx, "instance-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.GetInstanceCallCount()).To(Equal(0))
+
+		gcp.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.Succeeded}, nil)
+		_, err = broker.LastOperation(ctx, "instance-id", brokerapi.PollDetails{ServiceID: "gcp-service-id", OperationData: "deprovision"})
+		Expect(err).NotTo(HaveOccurred())
+		aws.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.ErrInstanceDoesNotExist)
+		gcp.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, brokerapi.ErrInstanceDoesNotExist)
+		_, err = broker.GetInstance(ctx, "instance-id")
+		Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+		Expect(aws.GetInstanceCallCount()).To(Equal(1))
+	})
+
+	It("does not try other upstreams after another error", func() {
+		aws.GetInstanceReturns(brokerapi.GetInstanceDetailsSpec{}, errors.New("unreachable"))
+
+		_, err := broker.GetInstance(ctx, "instance-id")
+		Expect(err).To(MatchError("unreachable"))
+		Expect(gcp.GetInstanceCallCount()).To(Equal(0))
+	})
+
+	It("panics on prefixes which overlap", func() {
+		Expect(func() {
+			composite.New(composite.Upstream{Prefix: "aws", Broker: aws}, composite.Upstream{Prefix: "aws-eu", Broker: gcp})
+		}).To(Panic())
+		Expect(func() { composite.New(composite.Upstream{Broker: aws}) }).To(Panic())
+	})
+
+	It("passes requests on to remote brokers", func() {
+		credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+		server := httptest.NewServer(brokerapi.New(gcp, lager.NewLogger("gcp"), credentials))
+		defer server.Close()
+
+		upstream, err := composite.NewUpstream("gcp-", server.URL, credentials.Username, credentials.Password)
+		Expect(err).NotTo(HaveOccurred())
+		broker = composite.New(composite.Upstream{Prefix: "aws-", Broker: aws}, upstream)
+
+		services, err := broker.Services(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services[1].ID).To(Equal("gcp-service-id"))
+
+		_, err = broker.Provision(ctx, "instance-id", brokerapi.ProvisionDetails{ServiceID: "gcp-service-id", PlanID: "gcp-plan-id"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		_, instanceID, details, _ := gcp.ProvisionArgsForCall(0)
+		Expect(instanceID).To(Equal("instance-id"))
+		Expect(details.ServiceID).To(Equal("service-id"))
+	})
+})
@@ -0,0 +1,144 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+)
+
+const (
+	concurrencyLimitDescription = "the broker is serving too many requests, try again later"
+
+	defaultConcurrencyQueueTimeout = time.Second
+)
+
+// limitedOperations are the operations changing state, which the concurrency
+// limits apply to.
+var limitedOperations = map[Operation]bool{
+	OperationProvision:   true,
+	OperationUpdate:      true,
+	OperationDeprovision: true,
+	OperationBind:        true,
+	OperationUnbind:      true,
+}
+
+type concurrencyLimits struct {
+	limit           int
+	operationLimits map[Operation]int
+	queueTimeout    time.Duration
+}
+
+// WithConcurrencyLimit caps the number of provisions, updates,
+// deprovisions, binds and unbinds served at the same time at limit, so that
+// a burst of them cannot exhaust the connection pools of the broker's
+// backend. Requests over the limit wait up to queueTimeout for another to
+// finish, and are then rejected with a 503 and a Retry-After header. A limit
+// of 0 leaves only the limits set with WithOperationConcurrencyLimit.
+func WithConcurrencyLimit(limit int, queueTimeout time.Duration) Option {
+	return func(c *config) {
+		c.concurrencyLimits.limit = limit
+		c.concurrencyLimits.queueTimeout = queueTimeout
+	}
+}
+
+// WithOperationConcurrencyLimit caps the number of requests for operation,
+// one of those limited by WithConcurrencyLimit, served at the same time. It
+// applies in addition to the overall limit. Requests wait for the queue
+// timeout given to WithConcurrencyLimit, or for a second without one.
+func WithOperationConcurrencyLimit(operation Operation, limit int) Option {
+	return func(c *config) {
+		if c.concurrencyLimits.operationLimits == nil {
+			c.concurrencyLimits.operationLimits = map[Operation]int{}
+		}
+		c.concurrencyLimits.operationLimits[operation] = limit
+	}
+}
+
+func (l concurrencyLimits) enabled() bool {
+	return l.limit > 0 || len(l.operationLimits) > 0
+}
+
+// semaphore holds one token per request being served.
+type semaphore chan struct{}
+
+func (l concurrencyLimits) middleware(logger lager.Logger) mux.MiddlewareFunc {
+	logger = logger.Session("concurrency-limit")
+
+	var global semaphore
+	if l.limit > 0 {
+		global = make(semaphore, l.limit)
+	}
+	operations := map[Operation]semaphore{}
+	for operation, limit := range l.operationLimits {
+		if limit > 0 && limitedOperations[operation] {
+			operations[operation] = make(semaphore, limit)
+		}
+	}
+	queueTimeout := l.queueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultConcurrencyQueueTimeout
+	}
+	retryAfter := strconv.Itoa(int(math.Max(1, math.Ceil(queueTimeout.Seconds()))))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			if route == nil || !limitedOperations[Operation(route.GetName())] {
+				next.ServeHTTP(w, req)
+				return
+			}
+			operation := Operation(route.GetName())
+
+			timer := time.NewTimer(queueTimeout)
+			defer timer.Stop()
+
+			// The operation's own limit is acquired first, so that requests
+			// queued for a busy operation do not hold up the others.
+			for _, s := range []semaphore{operations[operation], global} {
+				if s == nil {
+					continue
+				}
+				select {
+				case s <- struct{}{}:
+					defer func(s semaphore) { <-s }(s)
+				case <-timer.C:
+					rejectOverLimit(w, logger, operation, retryAfter)
+					return
+				case <-req.Context().Done():
+					rejectOverLimit(w, logger, operation, retryAfter)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func rejectOverLimit(w http.ResponseWriter, logger lager.Logger, operation Operation, retryAfter string) {
+	logger.Error("request-rejected", nil, lager.Data{"operation": operation})
+	w.Header().Set("Retry-After", retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(ErrorResponse{Description: concurrencyLimitDescription})
+}
@@ -0,0 +1,330 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads the settings most brokers need, such as the listen
+// address, TLS files, credentials, timeouts and rate limits, from a YAML or
+// JSON file and BROKER_* environment variables, and turns them into options
+// for brokerapi.NewWithOptions and brokerapi.NewServer.
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/interpolate"
+	"github.com/sharma-tapas/brokerapi/middlewares/rate_limit"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables read by Load.
+const EnvPrefix = "BROKER_"
+
+// DefaultListen is the listen address used when none is configured.
+const DefaultListen = ":8080"
+
+// Config holds the settings of a broker. Each field can be set in the file
+// under its yaml key, or in the environment under its env tag, nested tags
+// joined by underscores: the TLS certificate file is read from
+// BROKER_TLS_CERT_FILE. Environment variables take precedence over the file.
+type Config struct {
+	Listen            string `yaml:"listen" env:"LISTEN"`
+	PathPrefix        string `yaml:"path_prefix" env:"PATH_PREFIX"`
+	LogLevel          string `yaml:"log_level" env:"LOG_LEVEL"`
+	MinimumAPIVersion string `yaml:"minimum_api_version" env:"MINIMUM_API_VERSION"`
+
+	TLS         TLS         `yaml:"tls" env:"TLS"`
+	Credentials Credentials `yaml:"credentials" env:"CREDENTIALS"`
+	Timeouts    Timeouts    `yaml:"timeouts" env:"TIMEOUTS"`
+	RateLimit   RateLimit   `yaml:"rate_limit" env:"RATE_LIMIT"`
+	Features    Features    `yaml:"features" env:"FEATURES"`
+}
+
+// TLS holds the PEM files the server is started with. Setting ClientCAFile
+// requires platforms to authenticate with a client certificate.
+type TLS struct {
+	CertFile     string `yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile      string `yaml:"key_file" env:"KEY_FILE"`
+	ClientCAFile string `yaml:"client_ca_file" env:"CLIENT_CA_FILE"`
+}
+
+// Credentials holds the basic auth credentials of the broker, either inline
+// or in a file read with auth.ReadCredentialsFile.
+type Credentials struct {
+	Username string `yaml:"username" env:"USERNAME"`
+	Password string `yaml:"password" env:"PASSWORD"`
+	File     string `yaml:"file" env:"FILE"`
+}
+
+// Timeouts sets the deadlines of broker methods. The keys of Operations are
+// brokerapi.Operation names, such as "provision".
+type Timeouts struct {
+	Default    Duration            `yaml:"default" env:"DEFAULT"`
+	Operations map[string]Duration `yaml:"operations"`
+	Header     string              `yaml:"header" env:"HEADER"`
+}
+
+// RateLimit allows Rate requests per second with bursts of Burst, keyed by
+// "ip", the default, or "principal". Requests are only keyed by principal once
+// authenticated, so with "principal" requests rejected by authentication are
+// not counted. A zero Rate disables rate limiting.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" env:"RATE"`
+	Burst int     `yaml:"burst" env:"BURST"`
+	Key   string  `yaml:"key" env:"KEY"`
+}
+
+// Features turns on optional behaviour of the handlers.
+type Features struct {
+	StrictResponses    bool `yaml:"strict_responses" env:"STRICT_RESPONSES"`
+	RetrieveParameters bool `yaml:"retrieve_parameters" env:"RETRIEVE_PARAMETERS"`
+}
+
+// Duration is a time.Duration read from a Go duration string such as "90s",
+// or from a number of seconds.
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	parsed, err := parseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func parseDuration(value string) (Duration, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return Duration(duration), nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return Duration(seconds * float64(time.Second)), nil
+}
+
+// Default returns the configuration used for settings which are not set.
+func Default() *Config {
+	return &Config{Listen: DefaultListen}
+}
+
+// Load reads the configuration file at path, which may be empty to use the
+// environment only, applies the BROKER_* environment variables and validates
+// the result. Files are parsed as YAML, of which JSON is a subset, after
+// substituting references as described in the interpolate package, such as
+// ${file:/etc/broker/password}. Unknown keys are errors, so that typos do not
+// go unnoticed.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if contents, err = interpolate.Expand(contents); err != nil {
+			return nil, fmt.Errorf("could not interpolate config file %s: %s", filepath.Base(path), err)
+		}
+		if err := yaml.UnmarshalStrict(contents, config); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %s", filepath.Base(path), err)
+		}
+	}
+	if err := config.ApplyEnv(EnvPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate returns an error describing the first invalid setting.
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return errors.New("listen must not be empty")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.TLS.ClientCAFile != "" && c.TLS.CertFile == "" {
+		return errors.New("tls.client_ca_file requires tls.cert_file and tls.key_file")
+	}
+	if c.Credentials.File != "" && (c.Credentials.Username != "" || c.Credentials.Password != "") {
+		return errors.New("credentials.file cannot be combined with credentials.username and credentials.password")
+	}
+	if c.Credentials.File == "" && (c.Credentials.Username == "" || c.Credentials.Password == "") {
+		return errors.New("credentials.username and credentials.password, or credentials.file, must be set")
+	}
+	if _, err := c.logLevel(); err != nil {
+		return err
+	}
+	for operation := range c.Timeouts.Operations {
+		if brokerapi.Operation(operation).PathTemplate() == "" {
+			return fmt.Errorf("timeouts.operations: unknown operation %q", operation)
+		}
+	}
+	if c.RateLimit.Rate < 0 || c.RateLimit.Burst < 0 {
+		return errors.New("rate_limit.rate and rate_limit.burst must not be negative")
+	}
+	if _, err := c.rateLimitKey(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Options returns the options for brokerapi.NewWithOptions. Credentials
+// files are read once; use an auth.CredentialsFileWatcher with
+// brokerapi.WithCustomAuth to pick up rotated credentials.
+func (c *Config) Options() ([]brokerapi.Option, error) {
+	var opts []brokerapi.Option
+
+	if c.Credentials.File != "" {
+		wrapper, err := auth.NewWrapperFromFile(c.Credentials.File)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, brokerapi.WithCustomAuth(wrapper.Wrap))
+	} else {
+		opts = append(opts, brokerapi.WithBrokerCredentials(brokerapi.BrokerCredentials{
+			Username: c.Credentials.Username,
+			Password: c.Credentials.Password,
+		}))
+	}
+
+	if c.PathPrefix != "" {
+		opts = append(opts, brokerapi.WithPathPrefix(c.PathPrefix))
+	}
+	if c.LogLevel != "" {
+		level, err := c.logLevel()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, brokerapi.WithLogLevel(level))
+	}
+	if c.MinimumAPIVersion != "" {
+		opts = append(opts, brokerapi.WithMinimumAPIVersion(c.MinimumAPIVersion))
+	}
+
+	if c.Timeouts.Default > 0 {
+		opts = append(opts, brokerapi.WithDefaultTimeout(time.Duration(c.Timeouts.Default)))
+	}
+	for operation, timeout := range c.Timeouts.Operations {
+		opts = append(opts, brokerapi.WithOperationTimeout(brokerapi.Operation(operation), time.Duration(timeout)))
+	}
+	if c.Timeouts.Header != "" {
+		opts = append(opts, brokerapi.WithTimeoutHeader(c.Timeouts.Header))
+	}
+
+	if c.RateLimit.Rate > 0 {
+		key, err := c.rateLimitKey()
+		if err != nil {
+			return nil, err
+		}
+		burst := c.RateLimit.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		limiter := rate_limit.New(c.RateLimit.Rate, burst, key).Wrap
+		if c.RateLimit.Key == "principal" {
+			opts = append(opts, brokerapi.WithAuthenticatedMiddleware(limiter))
+		} else {
+			opts = append(opts, brokerapi.WithMiddleware(limiter))
+		}
+	}
+
+	if c.Features.StrictResponses {
+		opts = append(opts, brokerapi.WithStrictResponseValidation())
+	}
+	if c.Features.RetrieveParameters {
+		opts = append(opts, brokerapi.WithParameterRetrieval())
+	}
+	return opts, nil
+}
+
+// ServerOptions returns the options for brokerapi.NewServer.
+func (c *Config) ServerOptions() ([]brokerapi.ServerOption, error) {
+	var opts []brokerapi.ServerOption
+	if c.TLS.ClientCAFile != "" {
+		contents, err := ioutil.ReadFile(c.TLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificates found in %s", c.TLS.ClientCAFile)
+		}
+		opts = append(opts, brokerapi.WithClientCAs(clientCAs))
+	}
+	return opts, nil
+}
+
+// ListenAndServe serves handler on the configured address, over TLS when a
+// certificate is configured.
+func (c *Config) ListenAndServe(handler http.Handler) error {
+	serverOpts, err := c.ServerOptions()
+	if err != nil {
+		return err
+	}
+	listener, err := brokerapi.Listen(c.Listen)
+	if err != nil {
+		return err
+	}
+	server := brokerapi.NewServer(handler, serverOpts...)
+	if c.TLS.CertFile != "" {
+		return server.ServeTLS(listener, c.TLS.CertFile, c.TLS.KeyFile)
+	}
+	return server.Serve(listener)
+}
+
+func (c *Config) logLevel() (lager.LogLevel, error) {
+	switch strings.ToLower(c.LogLevel) {
+	case "", "debug":
+		return lager.DEBUG, nil
+	case "info":
+		return lager.INFO, nil
+	case "error":
+		return lager.ERROR, nil
+	case "fatal":
+		return lager.FATAL, nil
+	default:
+		return 0, fmt.Errorf("log_level: unknown level %q", c.LogLevel)
+	}
+}
+
+func (c *Config) rateLimitKey() (rate_limit.KeyFunc, error) {
+	switch c.RateLimit.Key {
+	case "", "ip":
+		return rate_limit.ClientIP, nil
+	case "principal":
+		return rate_limit.Principal, nil
+	default:
+		return nil, fmt.Errorf("rate_limit.key: unknown key %q, expected ip or principal", c.RateLimit.Key)
+	}
+}
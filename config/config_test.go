@@ -0,0 +1,245 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/config"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		dir     string
+		envVars []string
+	)
+
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	setEnv := func(name, value string) {
+		Expect(os.Setenv(name, value)).To(Succeed())
+		envVars = append(envVars, name)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		for _, name := range envVars {
+			os.Unsetenv(name)
+		}
+		envVars = nil
+		os.RemoveAll(dir)
+	})
+
+	Describe("Load", func() {
+		It("reads YAML files", func() {
+			path := writeFile("broker.yml", `
+listen: ":9000"
+log_level: info
+credentials:
+  username: admin
+  password: secret
+timeouts:
+  default: 30s
+  operations:
+    provision: 2m
+    bind: 45
+rate_limit:
+  rate: 10
+  burst: 20
+  key: principal
+features:
+  strict_responses: true
+`)
+			cfg, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Listen).To(Equal(":9000"))
+			Expect(cfg.LogLevel).To(Equal("info"))
+			Expect(cfg.Credentials.Username).To(Equal("admin"))
+			Expect(cfg.Timeouts.Default).To(Equal(config.Duration(30 * time.Second)))
+			Expect(cfg.Timeouts.Operations).To(Equal(map[string]config.Duration{
+				"provision": config.Duration(2 * time.Minute),
+				"bind":      config.Duration(45 * time.Second),
+			}))
+			Expect(cfg.RateLimit).To(Equal(config.RateLimit{Rate: 10, Burst: 20, Key: "principal"}))
+			Expect(cfg.Features.StrictResponses).To(BeTrue())
+		})
+
+		It("reads JSON files", func() {
+			path := writeFile("broker.json", `{"credentials": {"username": "admin", "password": "secret"}, "path_prefix": "/broker"}`)
+			cfg, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Listen).To(Equal(config.DefaultListen))
+			Expect(cfg.PathPrefix).To(Equal("/broker"))
+		})
+
+		It("rejects unknown keys", func() {
+			path := writeFile("broker.yml", "credentials: {username: admin, password: secret}\nlisten_address: \":9000\"\n")
+			_, err := config.Load(path)
+			Expect(err).To(MatchError(ContainSubstring("listen_address")))
+		})
+
+		It("lets the environment override the file", func() {
+			path := writeFile("broker.yml", "listen: \":9000\"\ncredentials: {username: admin, password: secret}\n")
+			setEnv("BROKER_LISTEN", "unix:/tmp/broker.sock")
+			setEnv("BROKER_CREDENTIALS_PASSWORD", "from-env")
+			setEnv("BROKER_TIMEOUTS_DEFAULT", "1m")
+			setEnv("BROKER_RATE_LIMIT_RATE", "2.5")
+			setEnv("BROKER_FEATURES_RETRIEVE_PARAMETERS", "true")
+
+			cfg, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Listen).To(Equal("unix:/tmp/broker.sock"))
+			Expect(cfg.Credentials).To(Equal(config.Credentials{Username: "admin", Password: "from-env"}))
+			Expect(cfg.Timeouts.Default).To(Equal(config.Duration(time.Minute)))
+			Expect(cfg.RateLimit.Rate).To(Equal(2.5))
+			Expect(cfg.Features.RetrieveParameters).To(BeTrue())
+		})
+
+		It("substitutes secret files into the file", func() {
+			passwordFile := writeFile("password", "from-file\n")
+			path := writeFile("broker.yml", "credentials: {username: admin, password: '${file:"+passwordFile+"}'}\n")
+
+			cfg, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Credentials.Password).To(Equal("from-file"))
+		})
+
+		It("loads from the environment alone", func() {
+			setEnv("BROKER_CREDENTIALS_USERNAME", "admin")
+			setEnv("BROKER_CREDENTIALS_PASSWORD", "secret")
+
+			cfg, err := config.Load("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Credentials.Username).To(Equal("admin"))
+		})
+
+		It("names the variable which cannot be parsed", func() {
+			setEnv("BROKER_RATE_LIMIT_BURST", "many")
+			_, err := config.Load("")
+			Expect(err).To(MatchError(`BROKER_RATE_LIMIT_BURST: invalid integer "many"`))
+		})
+	})
+
+	DescribeTable("Validate",
+		func(change func(*config.Config), expected string) {
+			cfg := config.Default()
+			cfg.Credentials = config.Credentials{Username: "admin", Password: "secret"}
+			change(cfg)
+			Expect(cfg.Validate()).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("missing credentials", func(c *config.Config) { c.Credentials = config.Credentials{} }, "credentials.username"),
+		Entry("credentials inline and in a file", func(c *config.Config) { c.Credentials.File = "/creds" }, "credentials.file"),
+		Entry("a certificate without a key", func(c *config.Config) { c.TLS.CertFile = "cert.pem" }, "tls.key_file"),
+		Entry("client CAs without TLS", func(c *config.Config) { c.TLS.ClientCAFile = "ca.pem" }, "tls.client_ca_file"),
+		Entry("an unknown log level", func(c *config.Config) { c.LogLevel = "verbose" }, "log_level"),
+		Entry("an unknown operation", func(c *config.Config) {
+			c.Timeouts.Operations = map[string]config.Duration{"provison": config.Duration(time.Minute)}
+		}, `"provison"`),
+		Entry("an unknown rate limit key", func(c *config.Config) { c.RateLimit.Key = "user" }, "rate_limit.key"),
+	)
+
+	Describe("Options", func() {
+		It("configures the broker handler", func() {
+			cfg := config.Default()
+			cfg.Credentials = config.Credentials{Username: "admin", Password: "secret"}
+			cfg.PathPrefix = "/broker"
+			cfg.RateLimit = config.RateLimit{Rate: 1, Burst: 1}
+			opts, err := cfg.Options()
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceBroker := new(fakes.AutoFakeServiceBroker)
+			handler := brokerapi.NewWithOptions(serviceBroker, lagertest.NewTestLogger("config"), opts...)
+			request := func(username string) int {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/broker/v2/catalog", nil)
+				req.Header.Set("X-Broker-API-Version", "2.14")
+				req.SetBasicAuth(username, "secret")
+				handler.ServeHTTP(recorder, req)
+				return recorder.Code
+			}
+
+			Expect(request("admin")).To(Equal(http.StatusOK))
+			Expect(request("admin")).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("keys the rate limit by principal after authentication", func() {
+			cfg := config.Default()
+			cfg.Credentials = config.Credentials{Username: "admin", Password: "secret"}
+			cfg.RateLimit = config.RateLimit{Rate: 1, Burst: 1, Key: "principal"}
+			opts, err := cfg.Options()
+			Expect(err).NotTo(HaveOccurred())
+
+			handler := brokerapi.NewWithOptions(new(fakes.AutoFakeServiceBroker), lagertest.NewTestLogger("config"), opts...)
+			request := func(username string) int {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/v2/catalog", nil)
+				req.Header.Set("X-Broker-API-Version", "2.14")
+				req.SetBasicAuth(username, "secret")
+				handler.ServeHTTP(recorder, req)
+				return recorder.Code
+			}
+
+			Expect(request("intruder")).To(Equal(http.StatusUnauthorized))
+			Expect(request("intruder")).To(Equal(http.StatusUnauthorized))
+			Expect(request("admin")).To(Equal(http.StatusOK))
+			Expect(request("admin")).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("reads the credentials file", func() {
+			cfg := config.Default()
+			cfg.Credentials.File = writeFile("credentials.json", `{"username": "admin", "password": "secret"}`)
+			opts, err := cfg.Options()
+			Expect(err).NotTo(HaveOccurred())
+
+			handler := brokerapi.NewWithOptions(new(fakes.AutoFakeServiceBroker), lagertest.NewTestLogger("config"), opts...)
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/v2/catalog", nil)
+			req.Header.Set("X-Broker-API-Version", "2.14")
+			req.SetBasicAuth("admin", "wrong")
+			handler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("ServerOptions", func() {
+		It("fails when the client CA file holds no certificates", func() {
+			cfg := config.Default()
+			cfg.TLS.ClientCAFile = writeFile("ca.pem", "not a certificate")
+			_, err := cfg.ServerOptions()
+			Expect(err).To(MatchError(ContainSubstring("no certificates found")))
+		})
+	})
+})
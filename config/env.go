@@ -0,0 +1,94 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// ApplyEnv overrides the settings with the variables returned by lookup, such
+// as os.LookupEnv, named prefix followed by the env tags of the fields.
+func (c *Config) ApplyEnv(prefix string, lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), prefix, lookup)
+}
+
+func applyEnv(value reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		name := prefix + tag
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value.Field(i), name+"_", lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		env, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(value.Field(i), env); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, env string) error {
+	if field.Type() == durationType {
+		duration, err := parseDuration(env)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", env)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", env)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", env)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
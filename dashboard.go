@@ -0,0 +1,65 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const dashboardRouteName = "dashboard"
+
+type dashboard struct {
+	pathPrefix string
+	handler    http.Handler
+}
+
+// WithDashboard serves handler, such as the dashboard of the broker's
+// instances, under pathPrefix, such as "/dashboard", so that a small broker
+// needs a single server. Dashboard requests go through the middlewares of
+// the broker, such as access logs and timeouts, but not through its
+// authentication: handler authenticates its own users, for example through
+// the SSO client of the catalog's dashboard_client. handler is given the full
+// path, use http.StripPrefix to remove the prefix. NewWithOptions panics if
+// pathPrefix does not start with a slash or overlaps the broker API.
+func WithDashboard(pathPrefix string, handler http.Handler) Option {
+	return func(c *config) {
+		c.dashboard = &dashboard{pathPrefix: pathPrefix, handler: handler}
+	}
+}
+
+func attachDashboard(router *mux.Router, d *dashboard) {
+	prefix := strings.TrimSuffix(d.pathPrefix, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		panic(fmt.Sprintf("dashboard path prefix %q must start with a slash", d.pathPrefix))
+	}
+	for _, reserved := range []string{"/v2", "/admin"} {
+		if prefix == reserved || strings.HasPrefix(prefix, reserved+"/") {
+			panic(fmt.Sprintf("dashboard path prefix %q overlaps the broker API", d.pathPrefix))
+		}
+	}
+
+	router.Path(prefix).Handler(d.handler).Name(dashboardRouteName)
+	router.PathPrefix(prefix + "/").Handler(d.handler).Name(dashboardRouteName)
+}
+
+func isDashboardRoute(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	return route != nil && route.GetName() == dashboardRouteName
+}
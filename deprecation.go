@@ -0,0 +1,125 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/handlers"
+)
+
+// Deprecation announces that the broker will stop supporting some requests.
+// Matching requests are logged and their responses carry a Deprecation header,
+// a Warning header with the Message and, if set, a Sunset header.
+type Deprecation struct {
+	// Operations limits the notice to the given endpoints. It applies to all
+	// endpoints when empty.
+	Operations []Operation
+	// BeforeAPIVersion, such as "2.14", limits the notice to requests with an
+	// older X-Broker-API-Version.
+	BeforeAPIVersion string
+	// Message explains what is deprecated and what to do about it.
+	Message string
+	// Sunset is when support will be removed, if known.
+	Sunset time.Time
+}
+
+// WithDeprecation adds a deprecation notice. NewWithOptions panics if the
+// BeforeAPIVersion of the notice is not of the form "major.minor".
+func WithDeprecation(deprecation Deprecation) Option {
+	return func(c *config) {
+		c.deprecations = append(c.deprecations, deprecation)
+	}
+}
+
+type deprecationNotice struct {
+	Deprecation
+	operations       map[Operation]bool
+	beforeAPIVersion handlers.BrokerVersion
+	warning          string
+}
+
+func newDeprecationNotice(deprecation Deprecation) deprecationNotice {
+	notice := deprecationNotice{
+		Deprecation: deprecation,
+		warning:     "299 - " + strconv.Quote(deprecation.Message),
+	}
+	if len(deprecation.Operations) > 0 {
+		notice.operations = make(map[Operation]bool, len(deprecation.Operations))
+		for _, operation := range deprecation.Operations {
+			notice.operations[operation] = true
+		}
+	}
+	if deprecation.BeforeAPIVersion != "" {
+		version, ok := handlers.ParseBrokerVersion(deprecation.BeforeAPIVersion)
+		if !ok {
+			panic(fmt.Sprintf("invalid API version %q in deprecation notice", deprecation.BeforeAPIVersion))
+		}
+		notice.beforeAPIVersion = version
+	}
+	return notice
+}
+
+func (n deprecationNotice) applies(operation Operation, req *http.Request) bool {
+	if n.operations != nil && !n.operations[operation] {
+		return false
+	}
+	if n.BeforeAPIVersion == "" {
+		return true
+	}
+	version, ok := handlers.ParseBrokerVersion(req.Header.Get("X-Broker-API-Version"))
+	return ok && version.OlderThan(n.beforeAPIVersion)
+}
+
+func deprecationMiddleware(deprecations []Deprecation, logger lager.Logger) mux.MiddlewareFunc {
+	notices := make([]deprecationNotice, 0, len(deprecations))
+	for _, deprecation := range deprecations {
+		notices = append(notices, newDeprecationNotice(deprecation))
+	}
+	logger = logger.Session("deprecation")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var operation Operation
+			if route := mux.CurrentRoute(req); route != nil {
+				operation = Operation(route.GetName())
+			}
+
+			for _, notice := range notices {
+				if !notice.applies(operation, req) {
+					continue
+				}
+				header := w.Header()
+				header.Set("Deprecation", "true")
+				header.Add("Warning", notice.warning)
+				if !notice.Sunset.IsZero() {
+					header.Set("Sunset", notice.Sunset.UTC().Format(http.TimeFormat))
+				}
+				logger.Info("deprecated-request", lager.Data{
+					"operation":   operation,
+					"api-version": req.Header.Get("X-Broker-API-Version"),
+					"message":     notice.Message,
+				})
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package description builds the descriptions of asynchronous operations, so
+// that the workers doing the operation and the LastOperation of the broker
+// describe it in the same words, without leaking secrets such as passwords in
+// connection URLs to the platform.
+package description
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// Redacted replaces sensitive values.
+const Redacted = "[redacted]"
+
+var (
+	urlPasswordPattern = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://[^:/@\s]*):[^@\s]*@`)
+	keyValuePattern    = regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)(\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;]+)`)
+)
+
+// Sensitive is a value which is always formatted as Redacted.
+type Sensitive string
+
+// Values holds the values of the placeholders of a Template.
+type Values map[string]interface{}
+
+// Redact replaces the passwords of URLs, such as
+// "postgres://admin:secret@db", and the values of password, secret, token and
+// key assignments, such as "password=secret", with Redacted.
+func Redact(s string) string {
+	s = urlPasswordPattern.ReplaceAllString(s, "$1:"+Redacted+"@")
+	return keyValuePattern.ReplaceAllString(s, "${1}${2}"+Redacted)
+}
+
+// Template is a description with {name} placeholders, such as
+// "creating database {database} on {host}".
+type Template struct {
+	literals []string
+	names    []string
+}
+
+// Parse parses format, in which "{{" and "}}" stand for literal braces.
+func Parse(format string) (*Template, error) {
+	t := &Template{}
+	var literal strings.Builder
+	for i := 0; i < len(format); i++ {
+		switch c := format[i]; {
+		case (c == '{' || c == '}') && i+1 < len(format) && format[i+1] == c:
+			literal.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed placeholder in description %q", format)
+			}
+			name := format[i+1 : i+end]
+			if name == "" || strings.ContainsAny(name, "{ ") {
+				return nil, fmt.Errorf("invalid placeholder {%s} in description %q", name, format)
+			}
+			t.literals = append(t.literals, literal.String())
+			t.names = append(t.names, name)
+			literal.Reset()
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unopened placeholder in description %q", format)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	t.literals = append(t.literals, literal.String())
+	return t, nil
+}
+
+// MustParse is like Parse but panics if format is invalid, for templates
+// declared as package variables.
+func MustParse(format string) *Template {
+	t, err := Parse(format)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Format returns the description with its placeholders replaced by values,
+// redacted with Redact. Placeholders without a value are left as they are.
+func (t *Template) Format(values Values) string {
+	var b strings.Builder
+	for i, name := range t.names {
+		b.WriteString(t.literals[i])
+		if value, ok := values[name]; ok {
+			b.WriteString(formatValue(value))
+		} else {
+			b.WriteString("{" + name + "}")
+		}
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String()
+}
+
+func formatValue(value interface{}) string {
+	if _, ok := value.(Sensitive); ok {
+		return Redacted
+	}
+	return Redact(fmt.Sprint(value))
+}
+
+// Steps names the steps of an operation, in order.
+type Steps []string
+
+// Progress returns the progress of the operation at step, counted from 1.
+func (s Steps) Progress(step int) domain.Progress {
+	var description string
+	if step >= 1 && step <= len(s) {
+		description = s[step-1]
+	}
+	return domain.StepProgress(step, len(s), description)
+}
+
+// Describe returns the description of step, such as
+// "creating database (step 2/5, 20%)".
+func (s Steps) Describe(step int) string {
+	return s.Progress(step).String()
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+	"sort"
+)
+
+// InstanceLister is implemented by brokers which can list their service
+// instances. It backs the GET /admin/service_instances extension endpoint,
+// which lets operators take an inventory of the broker without access to its
+// database.
+type InstanceLister interface {
+	ListInstances(ctx context.Context, request ListInstancesRequest) (InstanceList, error)
+}
+
+// ListInstancesRequest asks for one page of instances.
+type ListInstancesRequest = PageRequest
+
+// InstanceSummary describes a service instance in an InstanceList.
+type InstanceSummary struct {
+	InstanceID   string `json:"instance_id"`
+	ServiceID    string `json:"service_id"`
+	PlanID       string `json:"plan_id"`
+	DashboardURL string `json:"dashboard_url,omitempty"`
+	// MaintenanceInfo is the maintenance_info the instance was last
+	// provisioned or updated with, if the broker records it.
+	MaintenanceInfo *MaintenanceInfo `json:"maintenance_info,omitempty"`
+}
+
+// InstanceList is one page of instances. NextCursor is empty on the last page.
+type InstanceList struct {
+	Instances  []InstanceSummary
+	NextCursor string
+}
+
+// OrphanRemover is implemented by brokers which can delete service instances
+// the platform no longer knows about, such as those left behind by a
+// provision the platform abandoned. It backs the delete mode of the
+// POST /admin/orphan_sweep extension endpoint. Unlike Deprovision, the
+// platform is not involved, so the broker should release the resources of the
+// instance and its bindings.
+type OrphanRemover interface {
+	RemoveOrphanedInstance(ctx context.Context, instanceID string) error
+}
+
+// InstanceRestorer is implemented by brokers which hold back the deletion of
+// deprovisioned instances for a while, such as softdelete.Broker. It backs
+// the POST /admin/service_instances/{instance_id}/restore extension endpoint,
+// which brings back an instance deprovisioned by mistake. RestoreInstance
+// returns apiresponses.ErrInstanceNotAwaitingDeletion if the instance is not
+// waiting to be deleted.
+type InstanceRestorer interface {
+	RestoreInstance(ctx context.Context, instanceID string) error
+}
+
+// OrphanedIDs returns the brokerIDs which are not in platformIDs, sorted.
+func OrphanedIDs(platformIDs, brokerIDs []string) []string {
+	known := make(map[string]bool, len(platformIDs))
+	for _, id := range platformIDs {
+		known[id] = true
+	}
+
+	orphaned := []string{}
+	for _, id := range brokerIDs {
+		if !known[id] {
+			orphaned = append(orphaned, id)
+			known[id] = true
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned
+}
+
+// UpgradeCandidate is a service instance whose maintenance_info is behind
+// that of its plan in the catalog. Updating the instance with
+// TargetMaintenanceInfo upgrades it.
+type UpgradeCandidate struct {
+	InstanceID            string           `json:"instance_id"`
+	ServiceID             string           `json:"service_id"`
+	PlanID                string           `json:"plan_id"`
+	MaintenanceInfo       *MaintenanceInfo `json:"maintenance_info,omitempty"`
+	TargetMaintenanceInfo MaintenanceInfo  `json:"target_maintenance_info"`
+}
+
+// UpgradeCandidates returns the instances which are behind the
+// maintenance_info of their plan in services, ordered by instance ID. An
+// instance with no maintenance_info is behind any plan which has one. Plans
+// without maintenance_info, and instances of plans missing from the catalog,
+// have nothing to upgrade to.
+func UpgradeCandidates(services []Service, instances []InstanceSummary) []UpgradeCandidate {
+	targets := map[string]*MaintenanceInfo{}
+	for _, service := range services {
+		for _, plan := range service.Plans {
+			targets[service.ID+"/"+plan.ID] = plan.MaintenanceInfo
+		}
+	}
+
+	candidates := []UpgradeCandidate{}
+	for _, instance := range instances {
+		target := targets[instance.ServiceID+"/"+instance.PlanID]
+		if target == nil {
+			continue
+		}
+		if instance.MaintenanceInfo != nil && instance.MaintenanceInfo.Equals(*target) {
+			continue
+		}
+		candidates = append(candidates, UpgradeCandidate{
+			InstanceID:            instance.InstanceID,
+			ServiceID:             instance.ServiceID,
+			PlanID:                instance.PlanID,
+			MaintenanceInfo:       instance.MaintenanceInfo,
+			TargetMaintenanceInfo: *target,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].InstanceID < candidates[j].InstanceID
+	})
+	return candidates
+}
+
+// DebugToggle is a debugging aid which can be switched on and off while the
+// broker runs, such as the debug_dump middleware. Toggles given to
+// brokerapi.WithDebugToggle are switched through the /admin/debug extension
+// endpoint.
+type DebugToggle interface {
+	SetEnabled(enabled bool)
+	Enabled() bool
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("Admin", func() {
+	Describe("OrphanedIDs", func() {
+		It("returns the broker IDs unknown to the platform, sorted", func() {
+			orphaned := domain.OrphanedIDs(
+				[]string{"instance-1", "instance-3"},
+				[]string{"instance-4", "instance-3", "instance-2", "instance-1"},
+			)
+			Expect(orphaned).To(Equal([]string{"instance-2", "instance-4"}))
+		})
+
+		It("ignores platform IDs the broker does not know about", func() {
+			Expect(domain.OrphanedIDs([]string{"instance-1"}, nil)).To(BeEmpty())
+		})
+
+		It("returns each orphan once", func() {
+			Expect(domain.OrphanedIDs(nil, []string{"instance-1", "instance-1"})).To(Equal([]string{"instance-1"}))
+		})
+	})
+
+	Describe("UpgradeCandidates", func() {
+		v1 := &domain.MaintenanceInfo{Public: map[string]string{"version": "1"}}
+		v2 := domain.MaintenanceInfo{Public: map[string]string{"version": "2"}}
+		services := []domain.Service{{
+			ID: "service-id",
+			Plans: []domain.ServicePlan{
+				{ID: "current-id", MaintenanceInfo: &v2},
+				{ID: "unversioned-id"},
+			},
+		}}
+
+		It("returns the instances behind the maintenance_info of their plan, sorted", func() {
+			candidates := domain.UpgradeCandidates(services, []domain.InstanceSummary{
+				{InstanceID: "instance-3", ServiceID: "service-id", PlanID: "current-id", MaintenanceInfo: v1},
+				{InstanceID: "instance-2", ServiceID: "service-id", PlanID: "current-id", MaintenanceInfo: &v2},
+				{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "current-id"},
+			})
+			Expect(candidates).To(Equal([]domain.UpgradeCandidate{
+				{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "current-id", TargetMaintenanceInfo: v2},
+				{InstanceID: "instance-3", ServiceID: "service-id", PlanID: "current-id", MaintenanceInfo: v1, TargetMaintenanceInfo: v2},
+			}))
+		})
+
+		It("ignores plans without maintenance_info and plans missing from the catalog", func() {
+			Expect(domain.UpgradeCandidates(services, []domain.InstanceSummary{
+				{InstanceID: "instance-1", ServiceID: "service-id", PlanID: "unversioned-id", MaintenanceInfo: v1},
+				{InstanceID: "instance-2", ServiceID: "service-id", PlanID: "removed-id", MaintenanceInfo: v1},
+			})).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,170 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresponses
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	instanceAlreadyExistsErrorKey = "instance-already-exists"
+	instanceMissingErrorKey       = "instance-missing"
+	instanceLimitReachedErrorKey  = "instance-limit-reached"
+	bindingAlreadyExistsErrorKey  = "binding-already-exists"
+	bindingMissingErrorKey        = "binding-missing"
+	bindingNotFoundErrorKey       = "binding-not-found"
+	asyncRequiredKey              = "async-required"
+	planChangeNotSupportedKey     = "plan-change-not-supported"
+	invalidRawParamsKey           = "invalid-raw-params"
+	appGuidNotProvidedErrorKey    = "app-guid-not-provided"
+	requiresAppKey                = "requires-app"
+	concurrentAccessKey           = "get-instance-during-update"
+	provisionInProgressKey        = "deprovision-during-provision"
+	concurrentOperationKey        = "concurrent-operation"
+	maintenanceInfoConflictKey    = "maintenance-info-conflict"
+	operationTimedOutKey          = "operation-timed-out"
+	serviceUnavailableKey         = "service-unavailable"
+	notAwaitingDeletionKey        = "instance-not-awaiting-deletion"
+)
+
+const (
+	instanceExistsMsg             = "instance already exists"
+	instanceDoesntExistMsg        = "instance does not exist"
+	serviceLimitReachedMsg        = "instance limit for this service has been reached"
+	servicePlanQuotaExceededMsg   = "The quota for this service plan has been exceeded. Please contact your Operator for help."
+	serviceQuotaExceededMsg       = "The quota for this service has been exceeded. Please contact your Operator for help."
+	bindingExistsMsg              = "binding already exists"
+	bindingDoesntExistMsg         = "binding does not exist"
+	bindingNotFoundMsg            = "binding cannot be fetched"
+	asyncRequiredMsg              = "This service plan requires client support for asynchronous service operations."
+	planChangeUnsupportedMsg      = "The requested plan migration cannot be performed"
+	rawInvalidParamsMsg           = "The format of the parameters is not valid JSON"
+	appGuidMissingMsg             = "app_guid is a required field but was not provided"
+	requiresAppMsg                = "This service supports generation of credentials through binding an application only."
+	concurrentInstanceAccessMsg   = "instance is being updated and cannot be retrieved"
+	provisionInProgressMsg        = "instance is being provisioned and cannot be deleted"
+	concurrentOperationMsg        = "another operation is in progress for this instance"
+	maintenanceInfoConflictMsg    = "passed maintenance_info does not match the catalog maintenance_info"
+	maintenanceInfoNilConflictMsg = "maintenance_info was passed, but the broker catalog contains no maintenance_info"
+	operationTimedOutMsg          = "the broker did not complete the operation in time"
+	serviceUnavailableMsg         = "the service is temporarily unavailable, try again later"
+	notAwaitingDeletionMsg        = "instance is not awaiting deletion"
+)
+
+var (
+	ErrInstanceAlreadyExists = NewFailureResponseBuilder(
+		errors.New(instanceExistsMsg), http.StatusConflict, instanceAlreadyExistsErrorKey,
+	).WithEmptyResponse().Build()
+
+	ErrInstanceDoesNotExist = NewFailureResponseBuilder(
+		errors.New(instanceDoesntExistMsg), http.StatusGone, instanceMissingErrorKey,
+	).WithEmptyResponse().Build()
+
+	ErrInstanceLimitMet = NewFailureResponse(
+		errors.New(serviceLimitReachedMsg), http.StatusInternalServerError, instanceLimitReachedErrorKey,
+	)
+
+	ErrBindingAlreadyExists = NewFailureResponse(
+		errors.New(bindingExistsMsg), http.StatusConflict, bindingAlreadyExistsErrorKey,
+	)
+
+	ErrBindingDoesNotExist = NewFailureResponseBuilder(
+		errors.New(bindingDoesntExistMsg), http.StatusGone, bindingMissingErrorKey,
+	).WithEmptyResponse().Build()
+
+	ErrBindingNotFound = NewFailureResponseBuilder(
+		errors.New(bindingNotFoundMsg), http.StatusNotFound, bindingNotFoundErrorKey,
+	).WithEmptyResponse().Build()
+
+	ErrAsyncRequired = NewFailureResponseBuilder(
+		errors.New(asyncRequiredMsg), http.StatusUnprocessableEntity, asyncRequiredKey,
+	).WithErrorKey("AsyncRequired").Build()
+
+	ErrPlanChangeNotSupported = NewFailureResponseBuilder(
+		errors.New(planChangeUnsupportedMsg), http.StatusUnprocessableEntity, planChangeNotSupportedKey,
+	).WithErrorKey("PlanChangeNotSupported").Build()
+
+	ErrRawParamsInvalid = NewFailureResponse(
+		errors.New(rawInvalidParamsMsg), http.StatusUnprocessableEntity, invalidRawParamsKey,
+	)
+
+	ErrAppGuidNotProvided = NewFailureResponse(
+		errors.New(appGuidMissingMsg), http.StatusUnprocessableEntity, appGuidNotProvidedErrorKey,
+	)
+
+	// ErrRequiresApp is returned by the bind handler, without calling the
+	// broker, when a service requiring domain.PermissionApp is bound without
+	// an app_guid.
+	ErrRequiresApp = NewFailureResponseBuilder(
+		errors.New(requiresAppMsg), http.StatusUnprocessableEntity, requiresAppKey,
+	).WithErrorKey("RequiresApp").Build()
+
+	ErrPlanQuotaExceeded    = errors.New(servicePlanQuotaExceededMsg)
+	ErrServiceQuotaExceeded = errors.New(serviceQuotaExceededMsg)
+
+	ErrConcurrentInstanceAccess = NewFailureResponseBuilder(
+		errors.New(concurrentInstanceAccessMsg), http.StatusUnprocessableEntity, concurrentAccessKey,
+	).WithErrorKey("ConcurrencyError")
+
+	// ErrProvisionInProgress is returned by Deprovision when the instance is
+	// still being provisioned asynchronously. Brokers implementing
+	// domain.ProvisionCanceller are then asked to cancel the provision.
+	ErrProvisionInProgress = NewFailureResponseBuilder(
+		errors.New(provisionInProgressMsg), http.StatusUnprocessableEntity, provisionInProgressKey,
+	).WithErrorKey("ConcurrencyError").Build()
+
+	ErrConcurrentOperation = NewFailureResponseBuilder(
+		errors.New(concurrentOperationMsg), http.StatusUnprocessableEntity, concurrentOperationKey,
+	).WithErrorKey("ConcurrencyError").Build()
+
+	ErrMaintenanceInfoConflict = NewFailureResponseBuilder(
+		errors.New(maintenanceInfoConflictMsg), http.StatusUnprocessableEntity, maintenanceInfoConflictKey,
+	).WithErrorKey("MaintenanceInfoConflict").Build()
+
+	ErrMaintenanceInfoNilConflict = NewFailureResponseBuilder(
+		errors.New(maintenanceInfoNilConflictMsg), http.StatusUnprocessableEntity, maintenanceInfoConflictKey,
+	).WithErrorKey("MaintenanceInfoConflict").Build()
+
+	// ErrOperationTimedOut is responded with a 504 Gateway Timeout. The
+	// handlers also use it for any broker error wrapping
+	// context.DeadlineExceeded, so that platforms can tell timeouts, which
+	// are worth retrying later, from other failures.
+	ErrOperationTimedOut = NewFailureResponseBuilder(
+		errors.New(operationTimedOutMsg), http.StatusGatewayTimeout, operationTimedOutKey,
+	).WithErrorKey("OperationTimedOut").Build()
+
+	// ErrServiceUnavailable sheds load with a 503 Service Unavailable, for
+	// example during maintenance or while a backend is saturated. Use
+	// NewServiceUnavailable to also ask the platform when to retry.
+	ErrServiceUnavailable = NewServiceUnavailable(0)
+
+	// ErrInstanceNotAwaitingDeletion is returned by
+	// domain.InstanceRestorer.RestoreInstance for instances which were never
+	// deprovisioned, or which have already been deleted.
+	ErrInstanceNotAwaitingDeletion = NewFailureResponse(
+		errors.New(notAwaitingDeletionMsg), http.StatusNotFound, notAwaitingDeletionKey,
+	)
+)
+
+// NewServiceUnavailable returns a 503 Service Unavailable failure response,
+// which is sent with a Retry-After header when retryAfter is positive.
+func NewServiceUnavailable(retryAfter time.Duration) *FailureResponse {
+	return NewFailureResponseBuilder(
+		errors.New(serviceUnavailableMsg), http.StatusServiceUnavailable, serviceUnavailableKey,
+	).WithErrorKey("ServiceUnavailable").WithRetryAfter(retryAfter).Build()
+}
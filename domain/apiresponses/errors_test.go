@@ -0,0 +1,80 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresponses_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+var _ = Describe("Errors", func() {
+	DescribeTable("status code and body",
+		func(err *apiresponses.FailureResponse, statusCode int, expected string) {
+			Expect(err.ValidatedStatusCode(nil)).To(Equal(statusCode))
+			Expect(json.Marshal(err.ErrorResponse())).To(MatchJSON(expected))
+		},
+		Entry("ErrInstanceAlreadyExists", apiresponses.ErrInstanceAlreadyExists, http.StatusConflict, `{}`),
+		Entry("ErrInstanceDoesNotExist", apiresponses.ErrInstanceDoesNotExist, http.StatusGone, `{}`),
+		Entry("ErrInstanceLimitMet", apiresponses.ErrInstanceLimitMet, http.StatusInternalServerError,
+			`{"description":"instance limit for this service has been reached"}`),
+		Entry("ErrBindingAlreadyExists", apiresponses.ErrBindingAlreadyExists, http.StatusConflict,
+			`{"description":"binding already exists"}`),
+		Entry("ErrBindingDoesNotExist", apiresponses.ErrBindingDoesNotExist, http.StatusGone, `{}`),
+		Entry("ErrBindingNotFound", apiresponses.ErrBindingNotFound, http.StatusNotFound, `{}`),
+		Entry("ErrAsyncRequired", apiresponses.ErrAsyncRequired, http.StatusUnprocessableEntity,
+			`{"error":"AsyncRequired","description":"This service plan requires client support for asynchronous service operations."}`),
+		Entry("ErrPlanChangeNotSupported", apiresponses.ErrPlanChangeNotSupported, http.StatusUnprocessableEntity,
+			`{"error":"PlanChangeNotSupported","description":"The requested plan migration cannot be performed"}`),
+		Entry("ErrRawParamsInvalid", apiresponses.ErrRawParamsInvalid, http.StatusUnprocessableEntity,
+			`{"description":"The format of the parameters is not valid JSON"}`),
+		Entry("ErrAppGuidNotProvided", apiresponses.ErrAppGuidNotProvided, http.StatusUnprocessableEntity,
+			`{"description":"app_guid is a required field but was not provided"}`),
+		Entry("ErrRequiresApp", apiresponses.ErrRequiresApp, http.StatusUnprocessableEntity,
+			`{"error":"RequiresApp","description":"This service supports generation of credentials through binding an application only."}`),
+		Entry("ErrConcurrentInstanceAccess", apiresponses.ErrConcurrentInstanceAccess.Build(), http.StatusUnprocessableEntity,
+			`{"error":"ConcurrencyError","description":"instance is being updated and cannot be retrieved"}`),
+		Entry("ErrProvisionInProgress", apiresponses.ErrProvisionInProgress, http.StatusUnprocessableEntity,
+			`{"error":"ConcurrencyError","description":"instance is being provisioned and cannot be deleted"}`),
+		Entry("ErrConcurrentOperation", apiresponses.ErrConcurrentOperation, http.StatusUnprocessableEntity,
+			`{"error":"ConcurrencyError","description":"another operation is in progress for this instance"}`),
+		Entry("ErrMaintenanceInfoConflict", apiresponses.ErrMaintenanceInfoConflict, http.StatusUnprocessableEntity,
+			`{"error":"MaintenanceInfoConflict","description":"passed maintenance_info does not match the catalog maintenance_info"}`),
+		Entry("ErrMaintenanceInfoNilConflict", apiresponses.ErrMaintenanceInfoNilConflict, http.StatusUnprocessableEntity,
+			`{"error":"MaintenanceInfoConflict","description":"maintenance_info was passed, but the broker catalog contains no maintenance_info"}`),
+		Entry("ErrOperationTimedOut", apiresponses.ErrOperationTimedOut, http.StatusGatewayTimeout,
+			`{"error":"OperationTimedOut","description":"the broker did not complete the operation in time"}`),
+		Entry("ErrServiceUnavailable", apiresponses.ErrServiceUnavailable, http.StatusServiceUnavailable,
+			`{"error":"ServiceUnavailable","description":"the service is temporarily unavailable, try again later"}`),
+	)
+
+	Describe("NewServiceUnavailable", func() {
+		It("asks the platform to retry after the given duration", func() {
+			err := apiresponses.NewServiceUnavailable(30 * time.Second)
+			Expect(err.ValidatedStatusCode(nil)).To(Equal(http.StatusServiceUnavailable))
+			Expect(err.RetryAfter()).To(Equal(30 * time.Second))
+		})
+
+		It("has no Retry-After for ErrServiceUnavailable", func() {
+			Expect(apiresponses.ErrServiceUnavailable.RetryAfter()).To(BeZero())
+		})
+	})
+})
@@ -4,12 +4,13 @@
 // http://www.apache.org/licenses/LICENSE-2.0
 // Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
 
-package brokerapi
+package apiresponses
 
 import (
 	"net/http"
 
 	"fmt"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"errors"
@@ -24,6 +25,7 @@ type FailureResponse struct {
 	loggerAction  string
 	emptyResponse bool
 	errorKey      string
+	retryAfter    time.Duration
 }
 
 // NewFailureResponse returns a pointer to a new instance of FailureResponse.
@@ -76,9 +78,16 @@ func (f *FailureResponse) AppendErrorMessage(msg string) *FailureResponse {
 		loggerAction:  f.loggerAction,
 		emptyResponse: f.emptyResponse,
 		errorKey:      f.errorKey,
+		retryAfter:    f.retryAfter,
 	}
 }
 
+// RetryAfter returns how long the platform is asked to wait before retrying,
+// or zero when no Retry-After header is sent.
+func (f *FailureResponse) RetryAfter() time.Duration {
+	return f.retryAfter
+}
+
 // FailureResponseBuilder provides a fluent set of methods to build a *FailureResponse.
 type FailureResponseBuilder struct {
 	error
@@ -86,6 +95,7 @@ type FailureResponseBuilder struct {
 	loggerAction  string
 	emptyResponse bool
 	errorKey      string
+	retryAfter    time.Duration
 }
 
 // NewFailureResponseBuilder returns a pointer to a newly instantiated FailureResponseBuilder
@@ -113,6 +123,13 @@ func (f *FailureResponseBuilder) WithEmptyResponse() *FailureResponseBuilder {
 	return f
 }
 
+// WithRetryAfter adds a Retry-After header to the HTTP response, rounded up to
+// whole seconds
+func (f *FailureResponseBuilder) WithRetryAfter(retryAfter time.Duration) *FailureResponseBuilder {
+	f.retryAfter = retryAfter
+	return f
+}
+
 // Build returns the generated FailureResponse built using previously configured variables.
 func (f *FailureResponseBuilder) Build() *FailureResponse {
 	return &FailureResponse{
@@ -121,5 +138,11 @@ func (f *FailureResponseBuilder) Build() *FailureResponse {
 		loggerAction:  f.loggerAction,
 		emptyResponse: f.emptyResponse,
 		errorKey:      f.errorKey,
+		retryAfter:    f.retryAfter,
 	}
 }
+
+// Unwrap returns the error the FailureResponse was created with.
+func (f *FailureResponse) Unwrap() error {
+	return f.error
+}
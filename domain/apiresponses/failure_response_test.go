@@ -13,14 +13,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package brokerapi_test
+package apiresponses_test
 
 import (
-	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
 
 	"errors"
 
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	. "github.com/onsi/ginkgo"
@@ -31,16 +32,16 @@ import (
 var _ = Describe("FailureResponse", func() {
 	Describe("ErrorResponse", func() {
 		It("returns a ErrorResponse containing the error message", func() {
-			failureResponse := brokerapi.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
-			Expect(failureResponse.ErrorResponse()).To(Equal(brokerapi.ErrorResponse{
+			failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
+			Expect(failureResponse.ErrorResponse()).To(Equal(apiresponses.ErrorResponse{
 				Description: "my error message",
 			}))
 		})
 
 		Context("when the error key is provided", func() {
 			It("returns a ErrorResponse containing the error message and the error key", func() {
-				failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
-				Expect(failureResponse.ErrorResponse()).To(Equal(brokerapi.ErrorResponse{
+				failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
+				Expect(failureResponse.ErrorResponse()).To(Equal(apiresponses.ErrorResponse{
 					Description: "my error message",
 					Error:       "error key",
 				}))
@@ -49,15 +50,15 @@ var _ = Describe("FailureResponse", func() {
 
 		Context("when created with empty response", func() {
 			It("returns an EmptyResponse", func() {
-				failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithEmptyResponse().Build()
-				Expect(failureResponse.ErrorResponse()).To(Equal(brokerapi.EmptyResponse{}))
+				failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithEmptyResponse().Build()
+				Expect(failureResponse.ErrorResponse()).To(Equal(apiresponses.EmptyResponse{}))
 			})
 		})
 	})
 
 	Describe("AppendErrorMessage", func() {
 		It("returns the error with the additional error message included, with a non-empty body", func() {
-			failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("some-key").Build()
+			failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("some-key").Build()
 			Expect(failureResponse.Error()).To(Equal("my error message"))
 
 			newError := failureResponse.AppendErrorMessage("and some more details")
@@ -66,14 +67,14 @@ var _ = Describe("FailureResponse", func() {
 			Expect(newError.ValidatedStatusCode(nil)).To(Equal(http.StatusForbidden))
 			Expect(newError.LoggerAction()).To(Equal(failureResponse.LoggerAction()))
 
-			errorResponse, typeCast := newError.ErrorResponse().(brokerapi.ErrorResponse)
+			errorResponse, typeCast := newError.ErrorResponse().(apiresponses.ErrorResponse)
 			Expect(typeCast).To(BeTrue())
 			Expect(errorResponse.Error).To(Equal("some-key"))
 			Expect(errorResponse.Description).To(Equal("my error message and some more details"))
 		})
 
 		It("returns the error with the additional error message included, with an empty body", func() {
-			failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithEmptyResponse().Build()
+			failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithEmptyResponse().Build()
 			Expect(failureResponse.Error()).To(Equal("my error message"))
 
 			newError := failureResponse.AppendErrorMessage("and some more details")
@@ -87,18 +88,18 @@ var _ = Describe("FailureResponse", func() {
 
 	Describe("ValidatedStatusCode", func() {
 		It("returns the status code that was passed in", func() {
-			failureResponse := brokerapi.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
+			failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
 			Expect(failureResponse.ValidatedStatusCode(nil)).To(Equal(http.StatusForbidden))
 		})
 
 		It("when error key is provided it returns the status code that was passed in", func() {
-			failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
+			failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
 			Expect(failureResponse.ValidatedStatusCode(nil)).To(Equal(http.StatusForbidden))
 		})
 
 		Context("when the status code is invalid", func() {
 			It("returns 500", func() {
-				failureResponse := brokerapi.NewFailureResponse(errors.New("my error message"), 600, "log-key")
+				failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), 600, "log-key")
 				Expect(failureResponse.ValidatedStatusCode(nil)).To(Equal(http.StatusInternalServerError))
 			})
 
@@ -106,7 +107,7 @@ var _ = Describe("FailureResponse", func() {
 				log := gbytes.NewBuffer()
 				logger := lager.NewLogger("test")
 				logger.RegisterSink(lager.NewWriterSink(log, lager.DEBUG))
-				failureResponse := brokerapi.NewFailureResponse(errors.New("my error message"), 600, "log-key")
+				failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), 600, "log-key")
 				failureResponse.ValidatedStatusCode(logger)
 				Expect(log).To(gbytes.Say("Invalid failure http response code: 600, expected 4xx or 5xx, returning internal server error: 500."))
 			})
@@ -115,13 +116,34 @@ var _ = Describe("FailureResponse", func() {
 
 	Describe("LoggerAction", func() {
 		It("returns the logger action that was passed in", func() {
-			failureResponse := brokerapi.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
+			failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusForbidden, "log-key").WithErrorKey("error key").Build()
 			Expect(failureResponse.LoggerAction()).To(Equal("log-key"))
 		})
 
 		It("when error key is provided it returns the logger action that was passed in", func() {
-			failureResponse := brokerapi.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
+			failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), http.StatusForbidden, "log-key")
 			Expect(failureResponse.LoggerAction()).To(Equal("log-key"))
 		})
 	})
+
+	Describe("RetryAfter", func() {
+		It("returns zero by default", func() {
+			failureResponse := apiresponses.NewFailureResponse(errors.New("my error message"), http.StatusServiceUnavailable, "log-key")
+			Expect(failureResponse.RetryAfter()).To(BeZero())
+		})
+
+		It("returns the duration set on the builder, also after appending to the message", func() {
+			failureResponse := apiresponses.NewFailureResponseBuilder(errors.New("my error message"), http.StatusServiceUnavailable, "log-key").WithRetryAfter(time.Minute).Build()
+			Expect(failureResponse.RetryAfter()).To(Equal(time.Minute))
+			Expect(failureResponse.AppendErrorMessage("and more").RetryAfter()).To(Equal(time.Minute))
+		})
+	})
+
+	Describe("Unwrap", func() {
+		It("returns the error that was passed in", func() {
+			err := errors.New("my error message")
+			failureResponse := apiresponses.NewFailureResponse(err, http.StatusBadGateway, "log-key")
+			Expect(failureResponse.Unwrap()).To(Equal(err))
+		})
+	})
 })
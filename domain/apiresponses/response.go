@@ -0,0 +1,173 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiresponses contains the response bodies and errors a broker sends
+// back to a platform, so that brokers and platform clients can share the exact
+// wire representation. Request bodies are decoded into the details types of
+// the domain package.
+package apiresponses
+
+import "github.com/sharma-tapas/brokerapi/domain"
+
+type EmptyResponse struct{}
+
+type ErrorResponse struct {
+	Error       string `json:"error,omitempty"`
+	Description string `json:"description"`
+}
+
+type CatalogResponse struct {
+	Services []domain.Service `json:"services"`
+}
+
+type ProvisioningResponse struct {
+	DashboardURL  string `json:"dashboard_url,omitempty"`
+	OperationData string `json:"operation,omitempty"`
+}
+
+type GetInstanceResponse struct {
+	ServiceID    string      `json:"service_id"`
+	PlanID       string      `json:"plan_id"`
+	DashboardURL string      `json:"dashboard_url,omitempty"`
+	Parameters   interface{} `json:"parameters,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+}
+
+type UpdateResponse struct {
+	DashboardURL  string `json:"dashboard_url,omitempty"`
+	OperationData string `json:"operation,omitempty"`
+}
+
+type DeprovisionResponse struct {
+	OperationData string `json:"operation,omitempty"`
+}
+
+type LastOperationResponse struct {
+	State            domain.LastOperationState `json:"state"`
+	Description      string                    `json:"description,omitempty"`
+	InstanceUsable   *bool                     `json:"instance_usable,omitempty"`
+	UpdateRepeatable *bool                     `json:"update_repeatable,omitempty"`
+}
+
+type AsyncBindResponse struct {
+	OperationData string `json:"operation,omitempty"`
+}
+
+type BindingResponse struct {
+	Credentials     interface{}          `json:"credentials"`
+	SyslogDrainURL  string               `json:"syslog_drain_url,omitempty"`
+	RouteServiceURL string               `json:"route_service_url,omitempty"`
+	VolumeMounts    []domain.VolumeMount `json:"volume_mounts,omitempty"`
+}
+
+type GetBindingResponse struct {
+	BindingResponse
+	Parameters interface{} `json:"parameters,omitempty"`
+}
+
+type UnbindResponse struct {
+	OperationData string `json:"operation,omitempty"`
+}
+
+// Page is the response of a list extension endpoint. NextCursor is passed back
+// as the cursor query parameter to fetch the next page, and is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage returns a Page of items, which are encoded as an empty list rather
+// than null when there are none.
+func NewPage[T any](items []T, nextCursor string) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{Items: items, NextCursor: nextCursor}
+}
+
+type ListInstancesResponse struct {
+	ServiceInstances []domain.InstanceSummary `json:"service_instances"`
+	NextCursor       string                   `json:"next_cursor,omitempty"`
+}
+
+type OrphanSweepResponse struct {
+	OrphanedInstances  []domain.InstanceSummary `json:"orphaned_instances"`
+	DeletedInstanceIDs []string                 `json:"deleted_instance_ids,omitempty"`
+	FailedDeletions    []FailedDeletion         `json:"failed_deletions,omitempty"`
+}
+
+// InvalidRequestBodyResponse is the response to a request whose body could
+// not be decoded. Offset is the byte offset in the body at which decoding
+// failed, and Field the path of the field holding a value of the wrong type.
+type InvalidRequestBodyResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"description"`
+	Offset      int64  `json:"offset,omitempty"`
+	Field       string `json:"field,omitempty"`
+}
+
+// DryRunResponse is the response to a request made with ?dry_run=true which
+// would have succeeded.
+type DryRunResponse struct {
+	DryRun      bool   `json:"dry_run"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpgradeCandidatesResponse is one page of the instances which are behind the
+// maintenance_info of their plan.
+type UpgradeCandidatesResponse struct {
+	UpgradeCandidates []domain.UpgradeCandidate `json:"upgrade_candidates"`
+	NextCursor        string                    `json:"next_cursor,omitempty"`
+}
+
+// CatalogDiffResponse compares the catalog of a broker with a new one.
+// InstancesChecked is false when the broker cannot list its instances, and
+// so removed plans could not be checked for instances still using them.
+type CatalogDiffResponse struct {
+	domain.CatalogDiff
+	InstancesChecked bool `json:"instances_checked"`
+}
+
+// DebugSettingsResponse is the state of the debugging aids of a broker, as
+// served by the /admin/debug extension endpoint.
+type DebugSettingsResponse struct {
+	LogLevel string          `json:"log_level"`
+	Toggles  map[string]bool `json:"toggles"`
+}
+
+type FailedDeletion struct {
+	InstanceID  string `json:"instance_id"`
+	Description string `json:"description"`
+}
+
+type ExperimentalVolumeMountBindingResponse struct {
+	Credentials     interface{}               `json:"credentials"`
+	SyslogDrainURL  string                    `json:"syslog_drain_url,omitempty"`
+	RouteServiceURL string                    `json:"route_service_url,omitempty"`
+	VolumeMounts    []ExperimentalVolumeMount `json:"volume_mounts,omitempty"`
+}
+
+type ExperimentalVolumeMount struct {
+	ContainerPath string                         `json:"container_path"`
+	Mode          string                         `json:"mode"`
+	Private       ExperimentalVolumeMountPrivate `json:"private"`
+}
+
+type ExperimentalVolumeMountPrivate struct {
+	Driver  string `json:"driver"`
+	GroupID string `json:"group_id"`
+	Config  string `json:"config"`
+}
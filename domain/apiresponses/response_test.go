@@ -0,0 +1,246 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiresponses_test
+
+import (
+	"encoding/json"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+var _ = Describe("Catalog Response", func() {
+	Describe("JSON encoding", func() {
+		It("has a list of services", func() {
+			catalogResponse := apiresponses.CatalogResponse{
+				Services: []domain.Service{},
+			}
+			jsonString := `{"services":[]}`
+
+			Expect(json.Marshal(catalogResponse)).To(MatchJSON(jsonString))
+		})
+	})
+})
+
+var _ = Describe("Provisioning Response", func() {
+	Describe("JSON encoding", func() {
+		Context("when the dashboard URL is not present", func() {
+			It("does not return it in the JSON", func() {
+				provisioningResponse := apiresponses.ProvisioningResponse{}
+				jsonString := `{}`
+
+				Expect(json.Marshal(provisioningResponse)).To(MatchJSON(jsonString))
+			})
+		})
+
+		Context("when the dashboard URL is present", func() {
+			It("returns it in the JSON", func() {
+				provisioningResponse := apiresponses.ProvisioningResponse{
+					DashboardURL: "http://example.com/broker",
+				}
+				jsonString := `{"dashboard_url":"http://example.com/broker"}`
+
+				Expect(json.Marshal(provisioningResponse)).To(MatchJSON(jsonString))
+			})
+		})
+	})
+})
+
+var _ = Describe("Update Response", func() {
+	Describe("JSON encoding", func() {
+		Context("when the dashboard URL is not present", func() {
+			It("does not return it in the JSON", func() {
+				updateResponse := apiresponses.UpdateResponse{}
+				jsonString := `{}`
+
+				Expect(json.Marshal(updateResponse)).To(MatchJSON(jsonString))
+			})
+		})
+
+		Context("when the dashboard URL is present", func() {
+			It("returns it in the JSON", func() {
+				updateResponse := apiresponses.UpdateResponse{
+					DashboardURL: "http://example.com/broker_updated",
+				}
+				jsonString := `{"dashboard_url":"http://example.com/broker_updated"}`
+
+				Expect(json.Marshal(updateResponse)).To(MatchJSON(jsonString))
+			})
+		})
+	})
+})
+
+var _ = Describe("Binding Response", func() {
+	Describe("JSON encoding", func() {
+		It("has a credentials object", func() {
+			binding := apiresponses.BindingResponse{}
+			jsonString := `{"credentials":null}`
+
+			Expect(json.Marshal(binding)).To(MatchJSON(jsonString))
+		})
+	})
+})
+
+var _ = Describe("Error Response", func() {
+	Describe("JSON encoding", func() {
+		It("has a description field", func() {
+			errorResponse := apiresponses.ErrorResponse{
+				Description: "a bad thing happened",
+			}
+			jsonString := `{"description":"a bad thing happened"}`
+
+			Expect(json.Marshal(errorResponse)).To(MatchJSON(jsonString))
+		})
+	})
+})
+
+var _ = Describe("Wire format", func() {
+	volumeMount := domain.VolumeMount{
+		Driver:       "driver",
+		ContainerDir: "/dev/null",
+		Mode:         "rw",
+		DeviceType:   "shared",
+		Device: domain.SharedDevice{
+			VolumeId:    "some-guid",
+			MountConfig: map[string]interface{}{"key": "value"},
+		},
+	}
+
+	DescribeTable("encoding and decoding",
+		func(body interface{}, expected string) {
+			encoded, err := json.Marshal(body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encoded).To(MatchJSON(expected))
+
+			decoded := reflect.New(reflect.TypeOf(body))
+			Expect(json.Unmarshal([]byte(expected), decoded.Interface())).To(Succeed())
+			Expect(decoded.Elem().Interface()).To(Equal(body))
+		},
+		Entry("empty response",
+			apiresponses.EmptyResponse{},
+			`{}`,
+		),
+		Entry("error response",
+			apiresponses.ErrorResponse{Error: "AsyncRequired", Description: "async is required"},
+			`{"error":"AsyncRequired","description":"async is required"}`,
+		),
+		Entry("error response without an error code",
+			apiresponses.ErrorResponse{Description: "a bad thing happened"},
+			`{"description":"a bad thing happened"}`,
+		),
+		Entry("provisioning response",
+			apiresponses.ProvisioningResponse{DashboardURL: "http://dashboard", OperationData: "op"},
+			`{"dashboard_url":"http://dashboard","operation":"op"}`,
+		),
+		Entry("get instance response",
+			apiresponses.GetInstanceResponse{ServiceID: "service-id", PlanID: "plan-id", DashboardURL: "http://dashboard", Parameters: map[string]interface{}{"key": "value"}},
+			`{"service_id":"service-id","plan_id":"plan-id","dashboard_url":"http://dashboard","parameters":{"key":"value"}}`,
+		),
+		Entry("get instance response without optional fields",
+			apiresponses.GetInstanceResponse{ServiceID: "service-id", PlanID: "plan-id"},
+			`{"service_id":"service-id","plan_id":"plan-id"}`,
+		),
+		Entry("update response",
+			apiresponses.UpdateResponse{DashboardURL: "http://dashboard", OperationData: "op"},
+			`{"dashboard_url":"http://dashboard","operation":"op"}`,
+		),
+		Entry("deprovision response",
+			apiresponses.DeprovisionResponse{OperationData: "op"},
+			`{"operation":"op"}`,
+		),
+		Entry("synchronous deprovision response",
+			apiresponses.DeprovisionResponse{},
+			`{}`,
+		),
+		Entry("last operation response",
+			apiresponses.LastOperationResponse{State: domain.InProgress, Description: "halfway there"},
+			`{"state":"in progress","description":"halfway there"}`,
+		),
+		Entry("last operation response without a description",
+			apiresponses.LastOperationResponse{State: domain.Succeeded},
+			`{"state":"succeeded"}`,
+		),
+		Entry("async bind response",
+			apiresponses.AsyncBindResponse{OperationData: "op"},
+			`{"operation":"op"}`,
+		),
+		Entry("binding response",
+			apiresponses.BindingResponse{
+				Credentials:     map[string]interface{}{"password": "secret"},
+				SyslogDrainURL:  "syslog://drain",
+				RouteServiceURL: "https://route",
+				VolumeMounts:    []domain.VolumeMount{volumeMount},
+			},
+			`{
+				"credentials":{"password":"secret"},
+				"syslog_drain_url":"syslog://drain",
+				"route_service_url":"https://route",
+				"volume_mounts":[{
+					"driver":"driver",
+					"container_dir":"/dev/null",
+					"mode":"rw",
+					"device_type":"shared",
+					"device":{"volume_id":"some-guid","mount_config":{"key":"value"}}
+				}]
+			}`,
+		),
+		Entry("get binding response",
+			apiresponses.GetBindingResponse{
+				BindingResponse: apiresponses.BindingResponse{Credentials: "credentials"},
+				Parameters:      map[string]interface{}{"key": "value"},
+			},
+			`{"credentials":"credentials","parameters":{"key":"value"}}`,
+		),
+		Entry("unbind response",
+			apiresponses.UnbindResponse{OperationData: "op"},
+			`{"operation":"op"}`,
+		),
+		Entry("experimental volume mount binding response",
+			apiresponses.ExperimentalVolumeMountBindingResponse{
+				Credentials: "credentials",
+				VolumeMounts: []apiresponses.ExperimentalVolumeMount{{
+					ContainerPath: "/dev/null",
+					Mode:          "rw",
+					Private:       apiresponses.ExperimentalVolumeMountPrivate{Driver: "driver", GroupID: "group", Config: "config"},
+				}},
+			},
+			`{
+				"credentials":"credentials",
+				"volume_mounts":[{
+					"container_path":"/dev/null",
+					"mode":"rw",
+					"private":{"driver":"driver","group_id":"group","config":"config"}
+				}]
+			}`,
+		),
+	)
+})
+
+var _ = Describe("Page", func() {
+	It("encodes the items and the next cursor", func() {
+		page := apiresponses.NewPage([]string{"a", "b"}, "b")
+		Expect(json.Marshal(page)).To(MatchJSON(`{"items":["a","b"],"next_cursor":"b"}`))
+	})
+
+	It("encodes an empty last page as an empty list", func() {
+		page := apiresponses.NewPage[string](nil, "")
+		Expect(json.Marshal(page)).To(MatchJSON(`{"items":[]}`))
+	})
+})
@@ -13,7 +13,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package brokerapi
+package domain
 
 import (
 	"encoding/json"
@@ -46,14 +46,16 @@ type ServiceDashboardClient struct {
 }
 
 type ServicePlan struct {
-	ID              string               `json:"id"`
-	Name            string               `json:"name"`
-	Description     string               `json:"description"`
-	Free            *bool                `json:"free,omitempty"`
-	Bindable        *bool                `json:"bindable,omitempty"`
-	Metadata        *ServicePlanMetadata `json:"metadata,omitempty"`
-	Schemas         *ServiceSchemas      `json:"schemas,omitempty"`
-	MaintenanceInfo *MaintenanceInfo     `json:"maintenance_info,omitempty"`
+	ID               string               `json:"id"`
+	Name             string               `json:"name"`
+	Description      string               `json:"description"`
+	Free             *bool                `json:"free,omitempty"`
+	Bindable         *bool                `json:"bindable,omitempty"`
+	PlanUpdatable    *bool                `json:"plan_updateable,omitempty"`
+	Metadata         *ServicePlanMetadata `json:"metadata,omitempty"`
+	Schemas          *ServiceSchemas      `json:"schemas,omitempty"`
+	MaintenanceInfo  *MaintenanceInfo     `json:"maintenance_info,omitempty"`
+	BindingRotatable bool                 `json:"binding_rotatable,omitempty"`
 }
 
 type ServiceSchemas struct {
@@ -102,12 +104,94 @@ type MaintenanceInfo struct {
 	Private string            `json:"private,omitempty"`
 }
 
-func FreeValue(v bool) *bool {
+// Equals reports whether m and other describe the same maintenance, treating
+// an empty Public map the same as a missing one.
+func (m MaintenanceInfo) Equals(other MaintenanceInfo) bool {
+	if m.Private != other.Private || len(m.Public) != len(other.Public) {
+		return false
+	}
+	for key, value := range m.Public {
+		if otherValue, ok := other.Public[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether m is empty, as when a request has no
+// maintenance_info.
+func (m MaintenanceInfo) IsZero() bool {
+	return m.Private == "" && len(m.Public) == 0
+}
+
+// BoolPtr returns a pointer to v, for setting the optional boolean fields of a
+// ServicePlan, where nil means the field is omitted from the catalog.
+func BoolPtr(v bool) *bool {
 	return &v
 }
 
+func FreeValue(v bool) *bool {
+	return BoolPtr(v)
+}
+
 func BindableValue(v bool) *bool {
-	return &v
+	return BoolPtr(v)
+}
+
+// WithFree returns a copy of the plan with free set to v.
+func (p ServicePlan) WithFree(v bool) ServicePlan {
+	p.Free = BoolPtr(v)
+	return p
+}
+
+// WithBindable returns a copy of the plan with bindable set to v, overriding
+// the flag of its service.
+func (p ServicePlan) WithBindable(v bool) ServicePlan {
+	p.Bindable = BoolPtr(v)
+	return p
+}
+
+// WithPlanUpdatable returns a copy of the plan with plan_updateable set to v,
+// overriding the flag of its service.
+func (p ServicePlan) WithPlanUpdatable(v bool) ServicePlan {
+	p.PlanUpdatable = BoolPtr(v)
+	return p
+}
+
+// IsFree reports whether the plan is free. Plans which do not set free are
+// free, as in the Open Service Broker API.
+func (p ServicePlan) IsFree() bool {
+	return p.Free == nil || *p.Free
+}
+
+// RequiresApp reports whether bindings of the service must be for an
+// application, which it declares by requiring PermissionApp.
+func (s Service) RequiresApp() bool {
+	for _, permission := range s.Requires {
+		if permission == PermissionApp {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBindable applies the plan-level bindable flag, when set, in preference to
+// the one of service.
+func (p ServicePlan) IsBindable(service Service) bool {
+	if p.Bindable != nil {
+		return *p.Bindable
+	}
+	return service.Bindable
+}
+
+// IsPlanUpdatable reports whether instances of the plan can change plan,
+// applying the plan-level plan_updateable flag, when set, in preference to the
+// one of service.
+func (p ServicePlan) IsPlanUpdatable(service Service) bool {
+	if p.PlanUpdatable != nil {
+		return *p.PlanUpdatable
+	}
+	return service.PlanUpdatable
 }
 
 type RequiredPermission string
@@ -117,6 +201,11 @@ const (
 	PermissionSyslogDrain     = RequiredPermission("syslog_drain")
 	PermissionVolumeMount     = RequiredPermission("volume_mount")
 
+	// PermissionApp is an extension of this library rather than a platform
+	// permission: bind requests for services requiring it must carry an
+	// app_guid, and are rejected with ErrRequiresApp otherwise.
+	PermissionApp = RequiredPermission("app")
+
 	additionalMetadataName = "AdditionalMetadata"
 )
 
@@ -0,0 +1,204 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// CatalogPlan identifies a plan in a CatalogDiff.
+type CatalogPlan struct {
+	ServiceID string `json:"service_id"`
+	PlanID    string `json:"plan_id"`
+	PlanName  string `json:"plan_name"`
+}
+
+// CatalogPlanChange is a plan found in both catalogs with different
+// settings. ChangedFields names the catalog fields which differ, such as
+// "maintenance_info" or "schemas".
+type CatalogPlanChange struct {
+	CatalogPlan
+	ChangedFields []string `json:"changed_fields"`
+}
+
+// CatalogBreakingChange is a change which existing instances would not
+// survive, such as removing a plan they use.
+type CatalogBreakingChange struct {
+	CatalogPlan
+	Instances   int    `json:"instances"`
+	Description string `json:"description"`
+}
+
+// CatalogDiff lists the differences between two versions of a catalog.
+// Services and plans are matched by ID, and every list is ordered by
+// service ID and plan ID.
+type CatalogDiff struct {
+	AddedServices   []string                `json:"added_services"`
+	RemovedServices []string                `json:"removed_services"`
+	AddedPlans      []CatalogPlan           `json:"added_plans"`
+	RemovedPlans    []CatalogPlan           `json:"removed_plans"`
+	ChangedPlans    []CatalogPlanChange     `json:"changed_plans"`
+	BreakingChanges []CatalogBreakingChange `json:"breaking_changes"`
+}
+
+// Breaking reports whether rolling out the new catalog would break
+// existing instances.
+func (d CatalogDiff) Breaking() bool {
+	return len(d.BreakingChanges) > 0
+}
+
+// DiffCatalogs compares the services of the previous and next version of a
+// catalog. Plans removed while instances still use them are also
+// reported as breaking changes; instances of plans the previous catalog does
+// not list are ignored.
+func DiffCatalogs(previous, next []Service, instances []InstanceSummary) CatalogDiff {
+	diff := CatalogDiff{
+		AddedServices:   []string{},
+		RemovedServices: []string{},
+		AddedPlans:      []CatalogPlan{},
+		RemovedPlans:    []CatalogPlan{},
+		ChangedPlans:    []CatalogPlanChange{},
+		BreakingChanges: []CatalogBreakingChange{},
+	}
+
+	inUse := map[string]int{}
+	for _, instance := range instances {
+		inUse[instance.ServiceID+"/"+instance.PlanID]++
+	}
+
+	previousServices := servicesByID(previous)
+	nextServices := servicesByID(next)
+	for _, id := range sortedIDs(serviceIDs(previous), serviceIDs(next)) {
+		previousService, inPrevious := previousServices[id]
+		nextService, inNext := nextServices[id]
+		switch {
+		case !inPrevious:
+			diff.AddedServices = append(diff.AddedServices, id)
+		case !inNext:
+			diff.RemovedServices = append(diff.RemovedServices, id)
+		}
+
+		previousPlans := plansByID(previousService.Plans)
+		nextPlans := plansByID(nextService.Plans)
+		for _, planID := range sortedIDs(planIDs(previousService.Plans), planIDs(nextService.Plans)) {
+			previousPlan, planInPrevious := previousPlans[planID]
+			nextPlan, planInNext := nextPlans[planID]
+			switch {
+			case !planInPrevious:
+				diff.AddedPlans = append(diff.AddedPlans, CatalogPlan{ServiceID: id, PlanID: planID, PlanName: nextPlan.Name})
+			case !planInNext:
+				plan := CatalogPlan{ServiceID: id, PlanID: planID, PlanName: previousPlan.Name}
+				diff.RemovedPlans = append(diff.RemovedPlans, plan)
+				if count := inUse[id+"/"+planID]; count > 0 {
+					diff.BreakingChanges = append(diff.BreakingChanges, CatalogBreakingChange{
+						CatalogPlan: plan,
+						Instances:   count,
+						Description: fmt.Sprintf("plan %q is removed while instances use it", previousPlan.Name),
+					})
+				}
+			default:
+				if fields := changedPlanFields(previousPlan, nextPlan); len(fields) > 0 {
+					diff.ChangedPlans = append(diff.ChangedPlans, CatalogPlanChange{
+						CatalogPlan:   CatalogPlan{ServiceID: id, PlanID: planID, PlanName: nextPlan.Name},
+						ChangedFields: fields,
+					})
+				}
+			}
+		}
+	}
+	return diff
+}
+
+func servicesByID(services []Service) map[string]Service {
+	byID := make(map[string]Service, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+	return byID
+}
+
+func plansByID(plans []ServicePlan) map[string]ServicePlan {
+	byID := make(map[string]ServicePlan, len(plans))
+	for _, plan := range plans {
+		byID[plan.ID] = plan
+	}
+	return byID
+}
+
+func serviceIDs(services []Service) map[string]bool {
+	ids := make(map[string]bool, len(services))
+	for _, service := range services {
+		ids[service.ID] = true
+	}
+	return ids
+}
+
+func planIDs(plans []ServicePlan) map[string]bool {
+	ids := make(map[string]bool, len(plans))
+	for _, plan := range plans {
+		ids[plan.ID] = true
+	}
+	return ids
+}
+
+// sortedIDs returns the keys of both maps, sorted.
+func sortedIDs(previous, next map[string]bool) []string {
+	var ids []string
+	for id := range previous {
+		ids = append(ids, id)
+	}
+	for id := range next {
+		if !previous[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// changedPlanFields compares the plans in their JSON form, so that the
+// fields are named as in the catalog.
+func changedPlanFields(previous, next ServicePlan) []string {
+	previousFields := planFields(previous)
+	nextFields := planFields(next)
+
+	var changed []string
+	for name, value := range previousFields {
+		if !reflect.DeepEqual(value, nextFields[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range nextFields {
+		if _, ok := previousFields[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func planFields(plan ServicePlan) map[string]interface{} {
+	fields := map[string]interface{}{}
+	raw, err := json.Marshal(plan)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(raw, &fields)
+	return fields
+}
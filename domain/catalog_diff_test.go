@@ -0,0 +1,81 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("DiffCatalogs", func() {
+	v1 := &domain.MaintenanceInfo{Private: "v1"}
+	v2 := &domain.MaintenanceInfo{Private: "v2"}
+	previous := []domain.Service{
+		{ID: "mysql-id", Plans: []domain.ServicePlan{
+			{ID: "small-id", Name: "small", MaintenanceInfo: v1},
+			{ID: "large-id", Name: "large"},
+		}},
+		{ID: "redis-id", Plans: []domain.ServicePlan{{ID: "cache-id", Name: "cache"}}},
+	}
+	next := []domain.Service{
+		{ID: "mysql-id", Plans: []domain.ServicePlan{
+			{ID: "small-id", Name: "small", Description: "A small database", MaintenanceInfo: v2},
+			{ID: "medium-id", Name: "medium"},
+		}},
+		{ID: "postgres-id", Plans: []domain.ServicePlan{{ID: "tiny-id", Name: "tiny"}}},
+	}
+
+	It("lists the added, removed and changed services and plans", func() {
+		diff := domain.DiffCatalogs(previous, next, nil)
+		Expect(diff.AddedServices).To(Equal([]string{"postgres-id"}))
+		Expect(diff.RemovedServices).To(Equal([]string{"redis-id"}))
+		Expect(diff.AddedPlans).To(Equal([]domain.CatalogPlan{
+			{ServiceID: "mysql-id", PlanID: "medium-id", PlanName: "medium"},
+			{ServiceID: "postgres-id", PlanID: "tiny-id", PlanName: "tiny"},
+		}))
+		Expect(diff.RemovedPlans).To(Equal([]domain.CatalogPlan{
+			{ServiceID: "mysql-id", PlanID: "large-id", PlanName: "large"},
+			{ServiceID: "redis-id", PlanID: "cache-id", PlanName: "cache"},
+		}))
+		Expect(diff.ChangedPlans).To(Equal([]domain.CatalogPlanChange{{
+			CatalogPlan:   domain.CatalogPlan{ServiceID: "mysql-id", PlanID: "small-id", PlanName: "small"},
+			ChangedFields: []string{"description", "maintenance_info"},
+		}}))
+		Expect(diff.Breaking()).To(BeFalse())
+	})
+
+	It("reports removed plans which instances use as breaking changes", func() {
+		diff := domain.DiffCatalogs(previous, next, []domain.InstanceSummary{
+			{InstanceID: "instance-1", ServiceID: "redis-id", PlanID: "cache-id"},
+			{InstanceID: "instance-2", ServiceID: "redis-id", PlanID: "cache-id"},
+			{InstanceID: "instance-3", ServiceID: "mysql-id", PlanID: "small-id"},
+		})
+		Expect(diff.Breaking()).To(BeTrue())
+		Expect(diff.BreakingChanges).To(Equal([]domain.CatalogBreakingChange{{
+			CatalogPlan: domain.CatalogPlan{ServiceID: "redis-id", PlanID: "cache-id", PlanName: "cache"},
+			Instances:   2,
+			Description: `plan "cache" is removed while instances use it`,
+		}}))
+	})
+
+	It("finds no differences between identical catalogs", func() {
+		diff := domain.DiffCatalogs(previous, previous, nil)
+		Expect(diff.AddedPlans).To(BeEmpty())
+		Expect(diff.RemovedPlans).To(BeEmpty())
+		Expect(diff.ChangedPlans).To(BeEmpty())
+	})
+})
@@ -13,7 +13,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package brokerapi_test
+package domain_test
 
 import (
 	"encoding/json"
@@ -23,23 +23,23 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/domain"
 )
 
 var _ = Describe("Catalog", func() {
 	Describe("Service", func() {
 		Describe("JSON encoding", func() {
 			It("uses the correct keys", func() {
-				service := brokerapi.Service{
+				service := domain.Service{
 					ID:            "ID-1",
 					Name:          "Cassandra",
 					Description:   "A Cassandra Plan",
 					Bindable:      true,
-					Plans:         []brokerapi.ServicePlan{},
-					Metadata:      &brokerapi.ServiceMetadata{},
+					Plans:         []domain.ServicePlan{},
+					Metadata:      &domain.ServiceMetadata{},
 					Tags:          []string{"test"},
 					PlanUpdatable: true,
-					DashboardClient: &brokerapi.ServiceDashboardClient{
+					DashboardClient: &domain.ServiceDashboardClient{
 						ID:          "Dashboard ID",
 						Secret:      "dashboardsecret",
 						RedirectURI: "the.dashboa.rd",
@@ -75,21 +75,21 @@ var _ = Describe("Catalog", func() {
 		})
 
 		It("encodes the optional 'requires' fields", func() {
-			service := brokerapi.Service{
+			service := domain.Service{
 				ID:            "ID-1",
 				Name:          "Cassandra",
 				Description:   "A Cassandra Plan",
 				Bindable:      true,
-				Plans:         []brokerapi.ServicePlan{},
-				Metadata:      &brokerapi.ServiceMetadata{},
+				Plans:         []domain.ServicePlan{},
+				Metadata:      &domain.ServiceMetadata{},
 				Tags:          []string{"test"},
 				PlanUpdatable: true,
-				Requires: []brokerapi.RequiredPermission{
-					brokerapi.PermissionRouteForwarding,
-					brokerapi.PermissionSyslogDrain,
-					brokerapi.PermissionVolumeMount,
+				Requires: []domain.RequiredPermission{
+					domain.PermissionRouteForwarding,
+					domain.PermissionSyslogDrain,
+					domain.PermissionVolumeMount,
 				},
-				DashboardClient: &brokerapi.ServiceDashboardClient{
+				DashboardClient: &domain.ServiceDashboardClient{
 					ID:          "Dashboard ID",
 					Secret:      "dashboardsecret",
 					RedirectURI: "the.dashboa.rd",
@@ -126,17 +126,17 @@ var _ = Describe("Catalog", func() {
 	Describe("ServicePlan", func() {
 		Describe("JSON encoding", func() {
 			It("uses the correct keys", func() {
-				plan := brokerapi.ServicePlan{
+				plan := domain.ServicePlan{
 					ID:          "ID-1",
 					Name:        "Cassandra",
 					Description: "A Cassandra Plan",
-					Bindable:    brokerapi.BindableValue(true),
-					Free:        brokerapi.FreeValue(true),
-					Metadata: &brokerapi.ServicePlanMetadata{
+					Bindable:    domain.BindableValue(true),
+					Free:        domain.FreeValue(true),
+					Metadata: &domain.ServicePlanMetadata{
 						Bullets:     []string{"hello", "its me"},
 						DisplayName: "name",
 					},
-					MaintenanceInfo: &brokerapi.MaintenanceInfo{
+					MaintenanceInfo: &domain.MaintenanceInfo{
 						Public: map[string]string{
 							"name": "foo",
 						},
@@ -163,13 +163,142 @@ var _ = Describe("Catalog", func() {
 
 				Expect(json.Marshal(plan)).To(MatchJSON(jsonString))
 			})
+
+			It("omits bindable when it is not set", func() {
+				plan := domain.ServicePlan{
+					ID:          "ID-1",
+					Name:        "Cassandra",
+					Description: "A Cassandra Plan",
+				}
+				jsonString := `{
+					"id":"ID-1",
+					"name":"Cassandra",
+					"description":"A Cassandra Plan"
+				}`
+
+				Expect(json.Marshal(plan)).To(MatchJSON(jsonString))
+			})
+
+			It("includes bindable when it is explicitly false", func() {
+				plan := domain.ServicePlan{
+					ID:          "ID-1",
+					Name:        "Cassandra",
+					Description: "A Cassandra Plan",
+					Bindable:    domain.BindableValue(false),
+				}
+				jsonString := `{
+					"id":"ID-1",
+					"name":"Cassandra",
+					"description":"A Cassandra Plan",
+					"bindable": false
+				}`
+
+				Expect(json.Marshal(plan)).To(MatchJSON(jsonString))
+			})
+
+			It("includes binding_rotatable only when it is true", func() {
+				plan := domain.ServicePlan{
+					ID:               "ID-1",
+					Name:             "Cassandra",
+					Description:      "A Cassandra Plan",
+					BindingRotatable: true,
+				}
+				jsonString := `{
+					"id":"ID-1",
+					"name":"Cassandra",
+					"description":"A Cassandra Plan",
+					"binding_rotatable": true
+				}`
+
+				Expect(json.Marshal(plan)).To(MatchJSON(jsonString))
+
+				plan.BindingRotatable = false
+				Expect(json.Marshal(plan)).NotTo(ContainSubstring("binding_rotatable"))
+			})
+
+			It("includes the optional booleans set by the builders, even when false", func() {
+				plan := domain.ServicePlan{
+					ID:          "ID-1",
+					Name:        "Cassandra",
+					Description: "A Cassandra Plan",
+				}.WithFree(false).WithBindable(true).WithPlanUpdatable(false)
+				jsonString := `{
+					"id":"ID-1",
+					"name":"Cassandra",
+					"description":"A Cassandra Plan",
+					"free": false,
+					"bindable": true,
+					"plan_updateable": false
+				}`
+
+				Expect(json.Marshal(plan)).To(MatchJSON(jsonString))
+			})
+		})
+
+		Describe("JSON decoding", func() {
+			It("distinguishes unset optional booleans from false ones", func() {
+				var plan domain.ServicePlan
+				Expect(json.Unmarshal([]byte(`{"id":"ID-1","bindable":false}`), &plan)).To(Succeed())
+
+				Expect(plan.Free).To(BeNil())
+				Expect(plan.Bindable).To(Equal(domain.BoolPtr(false)))
+				Expect(plan.PlanUpdatable).To(BeNil())
+			})
+		})
+
+		Describe("builders", func() {
+			It("do not modify the original plan", func() {
+				plan := domain.ServicePlan{ID: "ID-1"}
+				plan.WithFree(true)
+
+				Expect(plan.Free).To(BeNil())
+			})
+		})
+
+		Describe("IsFree", func() {
+			It("is true unless free is set to false", func() {
+				Expect(domain.ServicePlan{}.IsFree()).To(BeTrue())
+				Expect(domain.ServicePlan{}.WithFree(true).IsFree()).To(BeTrue())
+				Expect(domain.ServicePlan{}.WithFree(false).IsFree()).To(BeFalse())
+			})
+		})
+
+		Describe("IsBindable and IsPlanUpdatable", func() {
+			service := domain.Service{Bindable: true, PlanUpdatable: false}
+
+			It("fall back to the service flags when the plan does not set them", func() {
+				plan := domain.ServicePlan{}
+				Expect(plan.IsBindable(service)).To(BeTrue())
+				Expect(plan.IsPlanUpdatable(service)).To(BeFalse())
+			})
+
+			It("prefer the plan flags when they are set", func() {
+				plan := domain.ServicePlan{}.WithBindable(false).WithPlanUpdatable(true)
+				Expect(plan.IsBindable(service)).To(BeFalse())
+				Expect(plan.IsPlanUpdatable(service)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("MaintenanceInfo", func() {
+		It("compares the private and public fields", func() {
+			info := domain.MaintenanceInfo{Public: map[string]string{"version": "1"}, Private: "sha"}
+			Expect(info.Equals(domain.MaintenanceInfo{Public: map[string]string{"version": "1"}, Private: "sha"})).To(BeTrue())
+			Expect(info.Equals(domain.MaintenanceInfo{Public: map[string]string{"version": "2"}, Private: "sha"})).To(BeFalse())
+			Expect(info.Equals(domain.MaintenanceInfo{Public: map[string]string{"version": "1"}})).To(BeFalse())
+			Expect(domain.MaintenanceInfo{Public: map[string]string{}}.Equals(domain.MaintenanceInfo{})).To(BeTrue())
+		})
+
+		It("is zero when empty", func() {
+			Expect(domain.MaintenanceInfo{Public: map[string]string{}}.IsZero()).To(BeTrue())
+			Expect(domain.MaintenanceInfo{Private: "sha"}.IsZero()).To(BeFalse())
 		})
 	})
 
 	Describe("ServicePlanMetadata", func() {
 		Describe("JSON encoding", func() {
 			It("uses the correct keys", func() {
-				metadata := brokerapi.ServicePlanMetadata{
+				metadata := domain.ServicePlanMetadata{
 					Bullets:     []string{"test"},
 					DisplayName: "Some display name",
 				}
@@ -179,7 +308,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("encodes the AdditionalMetadata fields in the metadata fields", func() {
-				metadata := brokerapi.ServicePlanMetadata{
+				metadata := domain.ServicePlanMetadata{
 					Bullets:     []string{"hello", "its me"},
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
@@ -201,7 +330,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("it can marshal same structure in parallel requests", func() {
-				metadata := brokerapi.ServicePlanMetadata{
+				metadata := domain.ServicePlanMetadata{
 					Bullets:     []string{"hello", "its me"},
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
@@ -231,7 +360,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("returns an error when additional metadata is not marshallable", func() {
-				metadata := brokerapi.ServicePlanMetadata{
+				metadata := domain.ServicePlanMetadata{
 					Bullets:     []string{"hello", "its me"},
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
@@ -245,7 +374,7 @@ var _ = Describe("Catalog", func() {
 
 		Describe("JSON decoding", func() {
 			It("sets the AdditionalMetadata from unrecognized fields", func() {
-				metadata := brokerapi.ServicePlanMetadata{}
+				metadata := domain.ServicePlanMetadata{}
 				jsonString := `{"foo":["test"],"bar":"Some display name"}`
 
 				err := json.Unmarshal([]byte(jsonString), &metadata)
@@ -255,7 +384,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("does not include convention fields into additional metadata", func() {
-				metadata := brokerapi.ServicePlanMetadata{}
+				metadata := domain.ServicePlanMetadata{}
 				jsonString := `{"bullets":["test"],"displayName":"Some display name", "costs": [{"amount": {"usd": 649.0},"unit": "MONTHLY"}]}`
 
 				err := json.Unmarshal([]byte(jsonString), &metadata)
@@ -269,7 +398,7 @@ var _ = Describe("Catalog", func() {
 		Describe("JSON encoding", func() {
 			It("uses the correct keys", func() {
 				shareable := true
-				metadata := brokerapi.ServiceMetadata{
+				metadata := domain.ServiceMetadata{
 					DisplayName:         "Cassandra",
 					LongDescription:     "A long description of Cassandra",
 					DocumentationUrl:    "doc",
@@ -292,7 +421,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("encodes the AdditionalMetadata fields in the metadata fields", func() {
-				metadata := brokerapi.ServiceMetadata{
+				metadata := domain.ServiceMetadata{
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
 						"foo": "bar",
@@ -312,7 +441,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("it can marshal same structure in parallel requests", func() {
-				metadata := brokerapi.ServiceMetadata{
+				metadata := domain.ServiceMetadata{
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
 						"foo": "bar",
@@ -340,7 +469,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("returns an error when additional metadata is not marshallable", func() {
-				metadata := brokerapi.ServiceMetadata{
+				metadata := domain.ServiceMetadata{
 					DisplayName: "name",
 					AdditionalMetadata: map[string]interface{}{
 						"foo": make(chan int),
@@ -353,7 +482,7 @@ var _ = Describe("Catalog", func() {
 
 		Describe("JSON decoding", func() {
 			It("sets the AdditionalMetadata from unrecognized fields", func() {
-				metadata := brokerapi.ServiceMetadata{}
+				metadata := domain.ServiceMetadata{}
 				jsonString := `{"foo":["test"],"bar":"Some display name"}`
 
 				err := json.Unmarshal([]byte(jsonString), &metadata)
@@ -363,7 +492,7 @@ var _ = Describe("Catalog", func() {
 			})
 
 			It("does not include convention fields into additional metadata", func() {
-				metadata := brokerapi.ServiceMetadata{}
+				metadata := domain.ServiceMetadata{}
 				jsonString := `{
 					"displayName":"Cassandra",
 					"longDescription":"A long description of Cassandra",
@@ -388,7 +517,7 @@ var _ = Describe("Catalog", func() {
 		}
 
 		s := Example1{}
-		Expect(brokerapi.GetJsonNames(reflect.ValueOf(&s).Elem())).To(
+		Expect(domain.GetJsonNames(reflect.ValueOf(&s).Elem())).To(
 			ConsistOf([]string{"foo", "bar", "Qux"}))
 	})
 })
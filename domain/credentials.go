@@ -0,0 +1,42 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import "context"
+
+// CredentialsOpener decrypts the binding credentials returned by Bind and
+// GetBinding before they are sent to the platform, so that a broker can keep
+// them encrypted in its store and return them as stored. OpenCredentials
+// returns credentials it does not recognise unchanged.
+type CredentialsOpener interface {
+	OpenCredentials(credentials interface{}) (interface{}, error)
+}
+
+// CredentialsTransformer changes the binding credentials returned by Bind and
+// GetBinding before they are sent to the platform, after any
+// CredentialsOpener, for example to flatten them into the string keys of a
+// Kubernetes secret. The platform making the request can be told from ctx.
+type CredentialsTransformer interface {
+	TransformCredentials(ctx context.Context, credentials interface{}) (interface{}, error)
+}
+
+// CredentialsTransformerFunc is a function used as a CredentialsTransformer.
+type CredentialsTransformerFunc func(ctx context.Context, credentials interface{}) (interface{}, error)
+
+// TransformCredentials calls f.
+func (f CredentialsTransformerFunc) TransformCredentials(ctx context.Context, credentials interface{}) (interface{}, error) {
+	return f(ctx, credentials)
+}
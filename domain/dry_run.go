@@ -0,0 +1,45 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import "context"
+
+// DryRunResult describes what a state-changing request would have done, had
+// it not been a dry run.
+type DryRunResult struct {
+	Description string
+}
+
+// ProvisionValidator is implemented by brokers which support dry runs of
+// provisions, requested with ?dry_run=true. ValidateProvision is called
+// instead of Provision, once the request and the quota have been checked, and
+// returns the error Provision would return, such as invalid parameters,
+// without creating anything.
+type ProvisionValidator interface {
+	ValidateProvision(ctx context.Context, instanceID string, details ProvisionDetails) (DryRunResult, error)
+}
+
+// UpdateValidator is implemented by brokers which support dry runs of
+// updates. It is called instead of Update, as ProvisionValidator is.
+type UpdateValidator interface {
+	ValidateUpdate(ctx context.Context, instanceID string, details UpdateDetails) (DryRunResult, error)
+}
+
+// BindValidator is implemented by brokers which support dry runs of binds.
+// It is called instead of Bind, as ProvisionValidator is.
+type BindValidator interface {
+	ValidateBind(ctx context.Context, instanceID, bindingID string, details BindDetails) (DryRunResult, error)
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+)
+
+// ErrorReport describes a request which the broker API answered with a 5xx status.
+type ErrorReport struct {
+	Operation       Operation
+	StatusCode      int
+	Method          string
+	Path            string
+	InstanceID      string
+	BindingID       string
+	APIVersion      string
+	RequestIdentity string
+	Err             error
+}
+
+// Chain returns Err followed by the errors it wraps, outermost first. Errors
+// are unwrapped through Unwrap() error, as well as Cause() error as used by
+// github.com/pkg/errors.
+func (r ErrorReport) Chain() []error {
+	var chain []error
+	for err := r.Err; err != nil; {
+		chain = append(chain, err)
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			err = nil
+		}
+	}
+	return chain
+}
+
+// ErrorReporter is called for every 5xx response, so that failures can be
+// sent to an error aggregation service such as Sentry. ReportError is called
+// synchronously after the response has been written and should not block.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, report ErrorReport)
+}
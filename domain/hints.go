@@ -0,0 +1,49 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+// Response headers carrying Hints.
+const (
+	QuotaLimitHeader         = "X-Broker-Quota-Limit"
+	QuotaRemainingHeader     = "X-Broker-Quota-Remaining"
+	RateLimitRemainingHeader = "X-Broker-RateLimit-Remaining"
+)
+
+// Hints are advisory values a broker returns with the outcome of a request,
+// which are sent to the platform as response headers so that platforms and
+// operators can show them without calling another endpoint. Hints which are
+// nil are not sent.
+type Hints struct {
+	// QuotaLimit is the number of instances the platform may have, sent as
+	// X-Broker-Quota-Limit.
+	QuotaLimit *int
+	// QuotaRemaining is the number of instances the platform may still
+	// create, sent as X-Broker-Quota-Remaining.
+	QuotaRemaining *int
+	// RateLimitRemaining is the number of requests the platform may still
+	// make in the current window, sent as X-Broker-RateLimit-Remaining.
+	RateLimitRemaining *int
+}
+
+// QuotaHints returns the Hints of a quota of limit instances, used of which
+// are in use.
+func QuotaHints(limit, used int) Hints {
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Hints{QuotaLimit: &limit, QuotaRemaining: &remaining}
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LifecycleEventType names a change in the state of a service instance or
+// binding, reported to the configured LifecycleEventSinks.
+type LifecycleEventType string
+
+const (
+	EventInstanceProvisioned   LifecycleEventType = "instance.provisioned"
+	EventInstanceUpdated       LifecycleEventType = "instance.updated"
+	EventInstanceDeprovisioned LifecycleEventType = "instance.deprovisioned"
+	EventBindingCreated        LifecycleEventType = "binding.created"
+	EventBindingDeleted        LifecycleEventType = "binding.deleted"
+	EventOperationSucceeded    LifecycleEventType = "operation.succeeded"
+	EventOperationFailed       LifecycleEventType = "operation.failed"
+)
+
+// LifecycleEvent is emitted after the broker has successfully handled a
+// provision, update, deprovision, bind or unbind request, and when polling
+// reports that an asynchronous operation has finished. For asynchronous
+// requests IsAsync is set and the event records that the operation was
+// accepted; a later operation.succeeded or operation.failed event follows,
+// once for each operation however often the platform polls it.
+type LifecycleEvent struct {
+	Type          LifecycleEventType `json:"type"`
+	Time          time.Time          `json:"time"`
+	InstanceID    string             `json:"instance_id"`
+	BindingID     string             `json:"binding_id,omitempty"`
+	ServiceID     string             `json:"service_id,omitempty"`
+	PlanID        string             `json:"plan_id,omitempty"`
+	IsAsync       bool               `json:"async,omitempty"`
+	OperationData string             `json:"operation,omitempty"`
+	Description   string             `json:"description,omitempty"`
+	// Tags are set for provisions and updates, to the tags of the service
+	// in the catalog followed by those given to the instance in the request.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// LifecycleEventSink receives lifecycle events. Emit is called once the
+// response has been written, from a goroutine serving only that sink, with the
+// events in order and a context which expires after 30 seconds, so a slow
+// sink delays neither the platform nor the other sinks; errors are logged and
+// otherwise ignored.
+type LifecycleEventSink interface {
+	Emit(ctx context.Context, event LifecycleEvent) error
+}
+
+// Publisher is invoked after lifecycle operations which succeeded, and is
+// the extension point for message-bus integrations such as those in the
+// publishers directory. Failed operations are not published.
+type Publisher interface {
+	Publish(ctx context.Context, event LifecycleEvent) error
+}
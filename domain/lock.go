@@ -0,0 +1,35 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrLockHeld is returned by LockManager.TryLock when the lock is held by
+// another request, possibly on another replica of the broker.
+var ErrLockHeld = errors.New("lock is held")
+
+// LockManager hands out named locks shared by every replica of a broker, so
+// that horizontally scaled deployments can serialize the operations on an
+// instance. Implementations for a single process, SQL advisory locks and
+// Redis are in the locks package.
+type LockManager interface {
+	// TryLock acquires the lock named key without waiting for it, and returns
+	// the function releasing it. It returns ErrLockHeld when the lock is taken.
+	TryLock(ctx context.Context, key string) (release func(), err error)
+}
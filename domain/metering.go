@@ -0,0 +1,57 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// UsageRecordType tells whether a UsageRecord starts or stops the use of a
+// plan.
+type UsageRecordType string
+
+const (
+	UsageStarted UsageRecordType = "start"
+	UsageStopped UsageRecordType = "stop"
+)
+
+// UsageRecord marks the start or the end of the use of a plan by a service
+// instance, for billing. A provision starts the use of its plan and a
+// deprovision stops it; an update changing the plan stops the use of the
+// previous plan and starts that of the new one. Asynchronous requests are
+// recorded when a last_operation poll reports that they succeeded, and not at
+// all if they failed. OrganizationGUID and SpaceGUID are only known for
+// provisions and updates.
+type UsageRecord struct {
+	Type             UsageRecordType `json:"type"`
+	Time             time.Time       `json:"time"`
+	InstanceID       string          `json:"instance_id"`
+	ServiceID        string          `json:"service_id"`
+	PlanID           string          `json:"plan_id"`
+	OrganizationGUID string          `json:"organization_guid,omitempty"`
+	SpaceGUID        string          `json:"space_guid,omitempty"`
+}
+
+// Meter receives usage records. RecordUsage is called on a single goroutine,
+// once the response has been written, with the records in the order they were
+// made and a context which expires after 30 seconds. Requests wait when a
+// thousand records are queued. Errors are logged and otherwise ignored, so a
+// Meter which must not lose records should keep them durably before
+// returning.
+type Meter interface {
+	RecordUsage(ctx context.Context, record UsageRecord) error
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+// Operation identifies one of the Open Service Broker API endpoints. It is used
+// to configure per-endpoint behaviour, such as timeouts, and is the name of the
+// route registered for the endpoint by brokerapi.AttachRoutes.
+type Operation string
+
+const (
+	OperationCatalog              Operation = "catalog"
+	OperationProvision            Operation = "provision"
+	OperationDeprovision          Operation = "deprovision"
+	OperationGetInstance          Operation = "getInstance"
+	OperationUpdate               Operation = "update"
+	OperationLastOperation        Operation = "lastOperation"
+	OperationBind                 Operation = "bind"
+	OperationUnbind               Operation = "unbind"
+	OperationGetBinding           Operation = "getBinding"
+	OperationLastBindingOperation Operation = "lastBindingOperation"
+)
+
+// Operations of the admin extension endpoints, which are only served when
+// enabled with brokerapi.WithAdminAPI.
+const (
+	OperationAdminListInstances     Operation = "adminListInstances"
+	OperationAdminSweepOrphans      Operation = "adminSweepOrphans"
+	OperationAdminDebug             Operation = "adminDebug"
+	OperationAdminUpgradeCandidates Operation = "adminUpgradeCandidates"
+	OperationAdminRestoreInstance   Operation = "adminRestoreInstance"
+	OperationAdminCatalogDiff       Operation = "adminCatalogDiff"
+)
+
+var pathTemplates = map[Operation]string{
+	OperationCatalog:                "/v2/catalog",
+	OperationProvision:              "/v2/service_instances/{instance_id}",
+	OperationDeprovision:            "/v2/service_instances/{instance_id}",
+	OperationGetInstance:            "/v2/service_instances/{instance_id}",
+	OperationUpdate:                 "/v2/service_instances/{instance_id}",
+	OperationLastOperation:          "/v2/service_instances/{instance_id}/last_operation",
+	OperationBind:                   "/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
+	OperationUnbind:                 "/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
+	OperationGetBinding:             "/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
+	OperationLastBindingOperation:   "/v2/service_instances/{instance_id}/service_bindings/{binding_id}/last_operation",
+	OperationAdminListInstances:     "/admin/service_instances",
+	OperationAdminSweepOrphans:      "/admin/orphan_sweep",
+	OperationAdminDebug:             "/admin/debug",
+	OperationAdminUpgradeCandidates: "/admin/upgrade_candidates",
+	OperationAdminRestoreInstance:   "/admin/service_instances/{instance_id}/restore",
+	OperationAdminCatalogDiff:       "/admin/catalog_diff",
+}
+
+// PathTemplate returns the path of the endpoint, such as
+// "/v2/service_instances/{instance_id}", or "" if op is not a known operation.
+func (op Operation) PathTemplate() string {
+	return pathTemplates[op]
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OriginatingIdentity is the parsed X-Broker-API-Originating-Identity header:
+// the name of the platform followed by a base64 encoded JSON object
+// identifying the user. Cloud Foundry sends the user_id, and Kubernetes the
+// user info of the requesting user: username, uid, groups and extra.
+type OriginatingIdentity struct {
+	Platform string
+
+	UserID string
+
+	Username string
+	UID      string
+	Groups   []string
+	Extra    map[string][]string
+
+	// Value is the decoded JSON object, including fields of other platforms.
+	Value json.RawMessage
+}
+
+type identityValue struct {
+	UserID   string              `json:"user_id"`
+	Username string              `json:"username"`
+	UID      string              `json:"uid"`
+	Groups   []string            `json:"groups"`
+	Extra    map[string][]string `json:"extra"`
+}
+
+// ParseOriginatingIdentity parses the value of an
+// X-Broker-API-Originating-Identity header.
+func ParseOriginatingIdentity(header string) (OriginatingIdentity, error) {
+	platform, encoded, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || platform == "" || encoded == "" {
+		return OriginatingIdentity{}, errors.New("originating identity must be a platform followed by a base64 encoded value")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// Some platforms leave out the padding.
+		decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	if err != nil {
+		return OriginatingIdentity{}, fmt.Errorf("originating identity value is not base64 encoded: %s", err)
+	}
+	if !json.Valid(decoded) {
+		return OriginatingIdentity{}, errors.New("originating identity value is not JSON")
+	}
+
+	identity := OriginatingIdentity{Platform: platform, Value: json.RawMessage(decoded)}
+	var value identityValue
+	if json.Unmarshal(decoded, &value) == nil {
+		identity.UserID = value.UserID
+		identity.Username = value.Username
+		identity.UID = value.UID
+		identity.Groups = value.Groups
+		identity.Extra = value.Extra
+	}
+	return identity, nil
+}
+
+// User returns the identifier of the user, the user_id sent by Cloud Foundry
+// or the username sent by Kubernetes.
+func (o OriginatingIdentity) User() string {
+	if o.UserID != "" {
+		return o.UserID
+	}
+	return o.Username
+}
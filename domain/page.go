@@ -0,0 +1,51 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import "sort"
+
+// PageRequest asks a list extension endpoint for one page of results. Pages
+// are requested with a cursor rather than an offset, so that items added or
+// removed while a client pages through the list do not shift later pages.
+type PageRequest struct {
+	// Limit is the maximum number of items to return.
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first page.
+	Cursor string
+}
+
+// Paginate returns the page of items selected by request, and the cursor of the
+// next page, which is empty on the last page. The cursor is the key of the last
+// item returned, so keys must be unique. items does not need to be sorted;
+// they are returned in the order of their keys. A Limit below 1 returns all the
+// remaining items.
+func Paginate[T any](items []T, request PageRequest, key func(T) string) ([]T, string) {
+	page := make([]T, 0, len(items))
+	for _, item := range items {
+		if key(item) > request.Cursor {
+			page = append(page, item)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool {
+		return key(page[i]) < key(page[j])
+	})
+
+	if request.Limit < 1 || len(page) <= request.Limit {
+		return page, ""
+	}
+	page = page[:request.Limit]
+	return page, key(page[len(page)-1])
+}
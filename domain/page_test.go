@@ -0,0 +1,62 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("Paginate", func() {
+	items := []string{"c", "a", "e", "b", "d"}
+	key := func(item string) string { return item }
+
+	It("returns the first page in key order", func() {
+		page, next := domain.Paginate(items, domain.PageRequest{Limit: 2}, key)
+		Expect(page).To(Equal([]string{"a", "b"}))
+		Expect(next).To(Equal("b"))
+	})
+
+	It("continues after the cursor", func() {
+		page, next := domain.Paginate(items, domain.PageRequest{Limit: 2, Cursor: "b"}, key)
+		Expect(page).To(Equal([]string{"c", "d"}))
+		Expect(next).To(Equal("d"))
+	})
+
+	It("has no next cursor on the last page", func() {
+		page, next := domain.Paginate(items, domain.PageRequest{Limit: 2, Cursor: "d"}, key)
+		Expect(page).To(Equal([]string{"e"}))
+		Expect(next).To(BeEmpty())
+	})
+
+	It("has no next cursor when the last page is full", func() {
+		page, next := domain.Paginate(items, domain.PageRequest{Limit: 1, Cursor: "d"}, key)
+		Expect(page).To(Equal([]string{"e"}))
+		Expect(next).To(BeEmpty())
+	})
+
+	It("returns all the remaining items without a limit", func() {
+		page, next := domain.Paginate(items, domain.PageRequest{Cursor: "a"}, key)
+		Expect(page).To(Equal([]string{"b", "c", "d", "e"}))
+		Expect(next).To(BeEmpty())
+	})
+
+	It("does not reorder the given items", func() {
+		domain.Paginate(items, domain.PageRequest{Limit: 2}, key)
+		Expect(items).To(Equal([]string{"c", "a", "e", "b", "d"}))
+	})
+})
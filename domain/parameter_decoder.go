@@ -0,0 +1,37 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ParameterDecoder converts the parameters block of provision, update and
+// bind requests from another format, such as that of the API a broker is
+// migrating from, into the JSON object the broker expects. It is only called
+// for requests which have parameters.
+type ParameterDecoder interface {
+	DecodeParameters(ctx context.Context, raw json.RawMessage) (json.RawMessage, error)
+}
+
+// ParameterDecoderFunc is a function used as a ParameterDecoder.
+type ParameterDecoderFunc func(ctx context.Context, raw json.RawMessage) (json.RawMessage, error)
+
+// DecodeParameters calls f.
+func (f ParameterDecoderFunc) DecodeParameters(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+	return f(ctx, raw)
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+var errParametersNotObject = errors.New("parameters must be an object")
+
+// ParameterChange is the previous and new value of a parameter. Previous is
+// nil for added parameters and New is nil for removed ones.
+type ParameterChange struct {
+	Previous interface{}
+	New      interface{}
+}
+
+// ParametersDiff lists the top-level parameters which differ between two sets
+// of parameters, by name.
+type ParametersDiff map[string]ParameterChange
+
+// DiffParameters compares two JSON objects of parameters. Empty parameters
+// count as an empty object.
+func DiffParameters(previous, next json.RawMessage) (ParametersDiff, error) {
+	previousValues, err := parameterValues(previous)
+	if err != nil {
+		return nil, err
+	}
+	nextValues, err := parameterValues(next)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := ParametersDiff{}
+	for name, value := range previousValues {
+		if nextValue, ok := nextValues[name]; !ok || !reflect.DeepEqual(value, nextValue) {
+			diff[name] = ParameterChange{Previous: value, New: nextValues[name]}
+		}
+	}
+	for name, value := range nextValues {
+		if _, ok := previousValues[name]; !ok {
+			diff[name] = ParameterChange{New: value}
+		}
+	}
+	return diff, nil
+}
+
+// IsEmpty reports whether no parameter changed.
+func (d ParametersDiff) IsEmpty() bool {
+	return len(d) == 0
+}
+
+// Names returns the names of the changed parameters, sorted.
+func (d ParametersDiff) Names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Changed reports whether any of the named parameters changed.
+func (d ParametersDiff) Changed(names ...string) bool {
+	for _, name := range names {
+		if _, ok := d[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlyChanged reports whether every changed parameter is one of names, such
+// as the parameters a broker can change in place. It is true when nothing
+// changed.
+func (d ParametersDiff) OnlyChanged(names ...string) bool {
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	for name := range d {
+		if !allowed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParametersDiff compares the parameters of the update with
+// PreviousValues.Parameters. As platforms only send the parameters being
+// changed, the update is applied as a JSON merge patch (RFC 7396): parameters
+// it leaves out keep their previous value, parameters it sets to null are
+// removed, and objects are merged with the previous object the same way, so
+// that New holds the whole object the parameter ends up as.
+func (d UpdateDetails) ParametersDiff() (ParametersDiff, error) {
+	previousValues, err := parameterValues(d.PreviousValues.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := parameterValues(d.RawParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := ParametersDiff{}
+	for name, value := range patch {
+		previous, existed := previousValues[name]
+		switch {
+		case value == nil && existed:
+			diff[name] = ParameterChange{Previous: previous}
+		case value != nil:
+			if merged := mergePatch(previous, value); !reflect.DeepEqual(previous, merged) {
+				diff[name] = ParameterChange{Previous: previous, New: merged}
+			}
+		}
+	}
+	return diff, nil
+}
+
+// mergePatch applies patch to target as RFC 7396 does.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, _ := target.(map[string]interface{})
+	merged := make(map[string]interface{}, len(targetObject)+len(patchObject))
+	for name, value := range targetObject {
+		merged[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = mergePatch(merged[name], value)
+	}
+	return merged
+}
+
+func parameterValues(raw json.RawMessage) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if len(raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, errParametersNotObject
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return values, nil
+}
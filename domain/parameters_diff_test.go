@@ -0,0 +1,112 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("ParametersDiff", func() {
+	Describe("DiffParameters", func() {
+		It("lists the added, removed and changed parameters", func() {
+			diff, err := domain.DiffParameters(
+				json.RawMessage(`{"size":1,"region":"eu","backups":true}`),
+				json.RawMessage(`{"size":2,"region":"eu","tags":["a"]}`),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal(domain.ParametersDiff{
+				"size":    {Previous: float64(1), New: float64(2)},
+				"backups": {Previous: true},
+				"tags":    {New: []interface{}{"a"}},
+			}))
+			Expect(diff.Names()).To(Equal([]string{"backups", "size", "tags"}))
+		})
+
+		It("treats empty parameters as an empty object", func() {
+			diff, err := domain.DiffParameters(nil, json.RawMessage(`{}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.IsEmpty()).To(BeTrue())
+		})
+
+		It("compares nested values", func() {
+			diff, err := domain.DiffParameters(json.RawMessage(`{"limits":{"cpu":1}}`), json.RawMessage(`{"limits":{"cpu":1}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.IsEmpty()).To(BeTrue())
+		})
+
+		It("rejects parameters which are not an object", func() {
+			_, err := domain.DiffParameters(json.RawMessage(`[1]`), nil)
+			Expect(err).To(MatchError("parameters must be an object"))
+		})
+	})
+
+	Describe("UpdateDetails.ParametersDiff", func() {
+		It("applies the update as a merge patch", func() {
+			details := domain.UpdateDetails{
+				RawParameters:  json.RawMessage(`{"size":2,"region":"eu","backups":null,"missing":null,"tags":["a"]}`),
+				PreviousValues: domain.PreviousValues{Parameters: json.RawMessage(`{"size":1,"region":"eu","backups":true,"zone":"a"}`)},
+			}
+
+			diff, err := details.ParametersDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal(domain.ParametersDiff{
+				"size":    {Previous: float64(1), New: float64(2)},
+				"backups": {Previous: true},
+				"tags":    {New: []interface{}{"a"}},
+			}))
+		})
+
+		It("merges nested objects with their previous value", func() {
+			details := domain.UpdateDetails{
+				RawParameters:  json.RawMessage(`{"backup":{"hour":3,"retention":null},"limits":{"cpu":2},"network":{"public":true},"labels":{"team":null}}`),
+				PreviousValues: domain.PreviousValues{Parameters: json.RawMessage(`{"backup":{"hour":1,"retention":7,"zone":"a"},"limits":{"cpu":2},"network":"private"}`)},
+			}
+
+			diff, err := details.ParametersDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal(domain.ParametersDiff{
+				"backup": {
+					Previous: map[string]interface{}{"hour": float64(1), "retention": float64(7), "zone": "a"},
+					New:      map[string]interface{}{"hour": float64(3), "zone": "a"},
+				},
+				"network": {Previous: "private", New: map[string]interface{}{"public": true}},
+				"labels":  {New: map[string]interface{}{}},
+			}))
+		})
+
+		It("is empty when the update has no parameters", func() {
+			details := domain.UpdateDetails{PreviousValues: domain.PreviousValues{Parameters: json.RawMessage(`{"size":1}`)}}
+
+			diff, err := details.ParametersDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.IsEmpty()).To(BeTrue())
+		})
+	})
+
+	It("tells which parameters changed", func() {
+		diff := domain.ParametersDiff{"size": {Previous: float64(1), New: float64(2)}}
+
+		Expect(diff.Changed("region", "size")).To(BeTrue())
+		Expect(diff.Changed("region")).To(BeFalse())
+		Expect(diff.OnlyChanged("size", "disk")).To(BeTrue())
+		Expect(diff.OnlyChanged("disk")).To(BeFalse())
+		Expect(domain.ParametersDiff{}.OnlyChanged()).To(BeTrue())
+	})
+})
@@ -0,0 +1,104 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The platforms defined by the profiles of the Open Service Broker API, and
+// PlatformOther for any other platform.
+const (
+	PlatformCloudFoundry = "cloudfoundry"
+	PlatformKubernetes   = "kubernetes"
+	PlatformOther        = "other"
+)
+
+// PlatformContext is the context object platforms send with provision,
+// update and bind requests. Cloud Foundry sets the organization and space
+// fields, and Kubernetes the namespace and cluster ID; the fields of other
+// platforms are left in Raw.
+type PlatformContext struct {
+	Platform     string `json:"platform"`
+	InstanceName string `json:"instance_name,omitempty"`
+
+	OrganizationGUID string `json:"organization_guid,omitempty"`
+	OrganizationName string `json:"organization_name,omitempty"`
+	SpaceGUID        string `json:"space_guid,omitempty"`
+	SpaceName        string `json:"space_name,omitempty"`
+
+	Namespace string `json:"namespace,omitempty"`
+	ClusterID string `json:"clusterid,omitempty"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// ParseContext parses the context object of a request. A request without
+// one gives the zero PlatformContext.
+func ParseContext(raw json.RawMessage) (PlatformContext, error) {
+	var platformContext PlatformContext
+	if len(raw) == 0 || string(raw) == "null" {
+		return platformContext, nil
+	}
+	if err := json.Unmarshal(raw, &platformContext); err != nil {
+		return PlatformContext{}, fmt.Errorf("invalid context object: %s", err)
+	}
+	platformContext.Raw = raw
+	return platformContext, nil
+}
+
+// IsKubernetes reports whether the request came from a Kubernetes platform,
+// such as the Kubernetes service catalog, which sends no organization or
+// space GUIDs.
+func (c PlatformContext) IsKubernetes() bool {
+	return c.Platform == PlatformKubernetes
+}
+
+// IsCloudFoundry reports whether the request came from Cloud Foundry.
+func (c PlatformContext) IsCloudFoundry() bool {
+	return c.Platform == PlatformCloudFoundry
+}
+
+// PlatformContext parses the context object of the provision. Platforms
+// which predate context objects only send the organization_guid and
+// space_guid fields, so these fill in for a missing context.
+func (d ProvisionDetails) PlatformContext() (PlatformContext, error) {
+	platformContext, err := ParseContext(d.RawContext)
+	if err != nil {
+		return PlatformContext{}, err
+	}
+	if platformContext.Platform == "" && (d.OrganizationGUID != "" || d.SpaceGUID != "") {
+		platformContext.Platform = PlatformCloudFoundry
+	}
+	if platformContext.OrganizationGUID == "" {
+		platformContext.OrganizationGUID = d.OrganizationGUID
+	}
+	if platformContext.SpaceGUID == "" {
+		platformContext.SpaceGUID = d.SpaceGUID
+	}
+	return platformContext, nil
+}
+
+// PlatformContext parses the context object of the update.
+func (d UpdateDetails) PlatformContext() (PlatformContext, error) {
+	return ParseContext(d.RawContext)
+}
+
+// PlatformContext parses the context object of the binding.
+func (d BindDetails) PlatformContext() (PlatformContext, error) {
+	return ParseContext(d.RawContext)
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("PlatformContext", func() {
+	It("parses the Kubernetes context", func() {
+		details := domain.ProvisionDetails{
+			RawContext: json.RawMessage(`{"platform":"kubernetes","namespace":"default","clusterid":"cluster-1","instance_name":"db"}`),
+		}
+
+		platformContext, err := details.PlatformContext()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(platformContext.IsKubernetes()).To(BeTrue())
+		Expect(platformContext.Namespace).To(Equal("default"))
+		Expect(platformContext.ClusterID).To(Equal("cluster-1"))
+		Expect(platformContext.InstanceName).To(Equal("db"))
+		Expect(platformContext.OrganizationGUID).To(BeEmpty())
+	})
+
+	It("parses the Cloud Foundry context", func() {
+		details := domain.BindDetails{
+			RawContext: json.RawMessage(`{"platform":"cloudfoundry","organization_guid":"org","space_guid":"space","space_name":"dev"}`),
+		}
+
+		platformContext, err := details.PlatformContext()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(platformContext.IsCloudFoundry()).To(BeTrue())
+		Expect(platformContext.OrganizationGUID).To(Equal("org"))
+		Expect(platformContext.SpaceName).To(Equal("dev"))
+		Expect(string(platformContext.Raw)).To(ContainSubstring("space_name"))
+	})
+
+	It("falls back to the organization and space GUIDs of provisions without a context", func() {
+		details := domain.ProvisionDetails{OrganizationGUID: "org", SpaceGUID: "space"}
+
+		platformContext, err := details.PlatformContext()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(platformContext.Platform).To(Equal(domain.PlatformCloudFoundry))
+		Expect(platformContext.SpaceGUID).To(Equal("space"))
+	})
+
+	It("returns the zero context when there is none", func() {
+		platformContext, err := domain.UpdateDetails{}.PlatformContext()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(platformContext).To(Equal(domain.PlatformContext{}))
+	})
+
+	It("rejects invalid context objects", func() {
+		_, err := domain.ParseContext(json.RawMessage(`["not", "an", "object"]`))
+		Expect(err).To(MatchError(ContainSubstring("invalid context object")))
+	})
+})
+
+var _ = Describe("ParseOriginatingIdentity", func() {
+	encode := func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	It("parses Kubernetes user info", func() {
+		identity, err := domain.ParseOriginatingIdentity("kubernetes " + encode(`{"username":"alice","uid":"uid-1","groups":["admins"],"extra":{"scopes":["read"]}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Platform).To(Equal(domain.PlatformKubernetes))
+		Expect(identity.Username).To(Equal("alice"))
+		Expect(identity.UID).To(Equal("uid-1"))
+		Expect(identity.Groups).To(ConsistOf("admins"))
+		Expect(identity.Extra).To(HaveKeyWithValue("scopes", []string{"read"}))
+		Expect(identity.User()).To(Equal("alice"))
+	})
+
+	It("parses the Cloud Foundry user ID", func() {
+		identity, err := domain.ParseOriginatingIdentity("cloudfoundry " + encode(`{"user_id":"user-1"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.User()).To(Equal("user-1"))
+	})
+
+	It("accepts values without padding", func() {
+		identity, err := domain.ParseOriginatingIdentity("kubernetes " + base64.RawStdEncoding.EncodeToString([]byte(`{"username":"bob"}`)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(identity.Username).To(Equal("bob"))
+	})
+
+	It("keeps values which are not objects", func() {
+		identity, err := domain.ParseOriginatingIdentity("other " + encode(`"opaque"`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(identity.Value)).To(Equal(`"opaque"`))
+		Expect(identity.User()).To(BeEmpty())
+	})
+
+	It("rejects malformed headers", func() {
+		_, err := domain.ParseOriginatingIdentity("kubernetes")
+		Expect(err).To(HaveOccurred())
+		_, err = domain.ParseOriginatingIdentity("kubernetes " + encode("not json"))
+		Expect(err).To(MatchError("originating identity value is not JSON"))
+	})
+})
@@ -0,0 +1,81 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Progress describes how far an operation in progress has got, for platforms
+// to show in the description of the last operation. Zero fields are left out.
+type Progress struct {
+	// Step is the current step, counted from 1, out of TotalSteps.
+	Step       int
+	TotalSteps int
+	// StepDescription describes the current step, such as
+	// "creating database".
+	StepDescription string
+	// Percent is how much of the operation is complete, from 0 to 100.
+	Percent int
+}
+
+// String formats the progress as "creating database (step 2/5, 40%)".
+func (p Progress) String() string {
+	var details []string
+	if p.TotalSteps > 0 {
+		details = append(details, fmt.Sprintf("step %d/%d", p.Step, p.TotalSteps))
+	}
+	if p.Percent > 0 {
+		details = append(details, fmt.Sprintf("%d%%", p.Percent))
+	}
+
+	switch {
+	case len(details) == 0:
+		return p.StepDescription
+	case p.StepDescription == "":
+		return strings.Join(details, ", ")
+	default:
+		return p.StepDescription + " (" + strings.Join(details, ", ") + ")"
+	}
+}
+
+// StepProgress returns the progress of an operation at step out of
+// totalSteps, with the percentage of the steps completed before it.
+func StepProgress(step, totalSteps int, description string) Progress {
+	progress := Progress{Step: step, TotalSteps: totalSteps, StepDescription: description}
+	if totalSteps > 0 && step > 1 {
+		progress.Percent = (step - 1) * 100 / totalSteps
+	}
+	return progress
+}
+
+// FullDescription returns the description sent to the platform: Description
+// followed by the formatted Progress, if any.
+func (l LastOperation) FullDescription() string {
+	if l.Progress == nil {
+		return l.Description
+	}
+	progress := l.Progress.String()
+	switch {
+	case progress == "":
+		return l.Description
+	case l.Description == "":
+		return progress
+	default:
+		return l.Description + ": " + progress
+	}
+}
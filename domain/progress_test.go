@@ -0,0 +1,50 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("Progress", func() {
+	table.DescribeTable("formats the progress",
+		func(progress domain.Progress, expected string) {
+			Expect(progress.String()).To(Equal(expected))
+		},
+		table.Entry("in full", domain.Progress{Step: 2, TotalSteps: 5, StepDescription: "creating database", Percent: 40}, "creating database (step 2/5, 40%)"),
+		table.Entry("without a percentage", domain.Progress{Step: 2, TotalSteps: 5, StepDescription: "creating database"}, "creating database (step 2/5)"),
+		table.Entry("without steps", domain.Progress{StepDescription: "creating database", Percent: 40}, "creating database (40%)"),
+		table.Entry("without a step description", domain.Progress{Step: 2, TotalSteps: 5, Percent: 40}, "step 2/5, 40%"),
+		table.Entry("with a step description only", domain.Progress{StepDescription: "creating database"}, "creating database"),
+		table.Entry("empty", domain.Progress{}, ""),
+	)
+
+	It("derives the percentage from the steps completed", func() {
+		Expect(domain.StepProgress(1, 4, "creating network")).To(Equal(domain.Progress{Step: 1, TotalSteps: 4, StepDescription: "creating network"}))
+		Expect(domain.StepProgress(3, 4, "creating database").String()).To(Equal("creating database (step 3/4, 50%)"))
+	})
+
+	It("adds the progress to the description of the last operation", func() {
+		progress := domain.StepProgress(2, 5, "creating database")
+		Expect(domain.LastOperation{Description: "Provisioning", Progress: &progress}.FullDescription()).To(Equal("Provisioning: creating database (step 2/5, 20%)"))
+		Expect(domain.LastOperation{Progress: &progress}.FullDescription()).To(Equal("creating database (step 2/5, 20%)"))
+		Expect(domain.LastOperation{Description: "Provisioning", Progress: &domain.Progress{}}.FullDescription()).To(Equal("Provisioning"))
+		Expect(domain.LastOperation{Description: "Provisioning"}.FullDescription()).To(Equal("Provisioning"))
+	})
+})
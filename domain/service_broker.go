@@ -13,22 +13,23 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package brokerapi
+package domain
 
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"net/http"
 )
 
-//go:generate counterfeiter -o fakes/auto_fake_service_broker.go -fake-name AutoFakeServiceBroker . ServiceBroker
+//go:generate counterfeiter -o ../fakes/auto_fake_service_broker.go -fake-name AutoFakeServiceBroker . ServiceBroker
 
 //Each method of the ServiceBroker interface maps to an individual endpoint of the Open Service Broker API.
 //
 //The specification is available here: https://github.com/openservicebrokerapi/servicebroker/blob/v2.14/spec.md
 //
 //The OpenAPI documentation is available here: http://petstore.swagger.io/?url=https://raw.githubusercontent.com/openservicebrokerapi/servicebroker/v2.14/openapi.yaml
+//
+//The ctx passed to each method is cancelled when the platform disconnects or the
+//configured timeout expires, so long-running backend calls should honour it.
 type ServiceBroker interface {
 	// Services gets the catalog of services offered by the service broker
 	//   GET /v2/catalog
@@ -72,6 +73,14 @@ type ServiceBroker interface {
 	LastBindingOperation(ctx context.Context, instanceID, bindingID string, details PollDetails) (LastOperation, error)
 }
 
+// ProvisionCanceller is implemented by brokers which can abort an
+// asynchronous provision that is still in progress. When Deprovision returns
+// apiresponses.ErrProvisionInProgress, the deprovision request is passed to
+// CancelProvision instead of being rejected with a ConcurrencyError.
+type ProvisionCanceller interface {
+	CancelProvision(ctx context.Context, instanceID string, details DeprovisionDetails, asyncAllowed bool) (DeprovisionServiceSpec, error)
+}
+
 type DetailsWithRawParameters interface {
 	GetRawParameters() json.RawMessage
 }
@@ -110,10 +119,14 @@ type ProvisionDetails struct {
 	MaintenanceInfo  MaintenanceInfo `json:"maintenance_info,omitempty"`
 }
 
+// ProvisionedServiceSpec is returned by Provision. For asynchronous provisions the
+// DashboardURL, when already known, is returned to the platform alongside the
+// OperationData in the 202 response.
 type ProvisionedServiceSpec struct {
 	IsAsync       bool
 	DashboardURL  string
 	OperationData string
+	Hints         Hints
 }
 
 type GetInstanceDetailsSpec struct {
@@ -121,11 +134,16 @@ type GetInstanceDetailsSpec struct {
 	PlanID       string      `json:"plan_id"`
 	DashboardURL string      `json:"dashboard_url"`
 	Parameters   interface{} `json:"parameters"`
+	// Tags are the tags of the instance, such as those it was provisioned
+	// with. The tags of its service in the catalog are added to them in the
+	// response.
+	Tags []string `json:"tags"`
 }
 
 type UnbindSpec struct {
 	IsAsync       bool
 	OperationData string
+	Hints         Hints
 }
 
 type BindDetails struct {
@@ -135,6 +153,21 @@ type BindDetails struct {
 	BindResource  *BindResource   `json:"bind_resource,omitempty"`
 	RawContext    json.RawMessage `json:"context,omitempty"`
 	RawParameters json.RawMessage `json:"parameters,omitempty"`
+
+	// PredecessorBindingID is set when the platform is rotating the
+	// binding it identifies, and is only accepted for plans which are
+	// BindingRotatable.
+	PredecessorBindingID string `json:"predecessor_binding_id,omitempty"`
+}
+
+// BoundAppGUID returns the app_guid of the bind_resource, falling back to the
+// deprecated top-level app_guid. It is empty for bindings not made for an
+// application.
+func (d BindDetails) BoundAppGUID() string {
+	if d.BindResource != nil && d.BindResource.AppGuid != "" {
+		return d.BindResource.AppGuid
+	}
+	return d.AppGUID
 }
 
 type BindResource struct {
@@ -153,11 +186,13 @@ type UpdateServiceSpec struct {
 	IsAsync       bool
 	DashboardURL  string
 	OperationData string
+	Hints         Hints
 }
 
 type DeprovisionServiceSpec struct {
 	IsAsync       bool
 	OperationData string
+	Hints         Hints
 }
 
 type DeprovisionDetails struct {
@@ -174,11 +209,19 @@ type UpdateDetails struct {
 	MaintenanceInfo MaintenanceInfo `json:"maintenance_info,omitempty"`
 }
 
+// PreviousValues holds the state of the service instance before the update,
+// as sent by the platform in the previous_values block of the request.
 type PreviousValues struct {
-	PlanID    string `json:"plan_id"`
-	ServiceID string `json:"service_id"`
-	OrgID     string `json:"organization_id"`
-	SpaceID   string `json:"space_id"`
+	PlanID          string           `json:"plan_id"`
+	ServiceID       string           `json:"service_id"`
+	OrgID           string           `json:"organization_id"`
+	SpaceID         string           `json:"space_id"`
+	MaintenanceInfo *MaintenanceInfo `json:"maintenance_info,omitempty"`
+
+	// Parameters are the parameters of the instance before the update. The
+	// platform does not send them; they are fetched with GetInstance for
+	// handlers created with brokerapi.WithPreviousParameters.
+	Parameters json.RawMessage `json:"-"`
 }
 
 type PollDetails struct {
@@ -190,6 +233,17 @@ type PollDetails struct {
 type LastOperation struct {
 	State       LastOperationState
 	Description string
+
+	// InstanceUsable tells the platform whether the instance can still be
+	// used after a failed update or deprovision.
+	InstanceUsable *bool
+	// UpdateRepeatable tells the platform whether a failed update may be
+	// retried.
+	UpdateRepeatable *bool
+
+	// Progress, for operations in progress, is added to the description
+	// sent to the platform. See FullDescription.
+	Progress *Progress
 }
 
 type LastOperationState string
@@ -207,6 +261,7 @@ type Binding struct {
 	SyslogDrainURL  string        `json:"syslog_drain_url"`
 	RouteServiceURL string        `json:"route_service_url"`
 	VolumeMounts    []VolumeMount `json:"volume_mounts"`
+	Hints           Hints         `json:"-"`
 }
 
 type GetBindingSpec struct {
@@ -229,78 +284,3 @@ type SharedDevice struct {
 	VolumeId    string                 `json:"volume_id"`
 	MountConfig map[string]interface{} `json:"mount_config"`
 }
-
-const (
-	instanceExistsMsg             = "instance already exists"
-	instanceDoesntExistMsg        = "instance does not exist"
-	serviceLimitReachedMsg        = "instance limit for this service has been reached"
-	servicePlanQuotaExceededMsg   = "The quota for this service plan has been exceeded. Please contact your Operator for help."
-	serviceQuotaExceededMsg       = "The quota for this service has been exceeded. Please contact your Operator for help."
-	bindingExistsMsg              = "binding already exists"
-	bindingDoesntExistMsg         = "binding does not exist"
-	bindingNotFoundMsg            = "binding cannot be fetched"
-	asyncRequiredMsg              = "This service plan requires client support for asynchronous service operations."
-	planChangeUnsupportedMsg      = "The requested plan migration cannot be performed"
-	rawInvalidParamsMsg           = "The format of the parameters is not valid JSON"
-	appGuidMissingMsg             = "app_guid is a required field but was not provided"
-	concurrentInstanceAccessMsg   = "instance is being updated and cannot be retrieved"
-	maintenanceInfoConflictMsg    = "passed maintenance_info does not match the catalog maintenance_info"
-	maintenanceInfoNilConflictMsg = "maintenance_info was passed, but the broker catalog contains no maintenance_info"
-)
-
-var (
-	ErrInstanceAlreadyExists = NewFailureResponseBuilder(
-		errors.New(instanceExistsMsg), http.StatusConflict, instanceAlreadyExistsErrorKey,
-	).WithEmptyResponse().Build()
-
-	ErrInstanceDoesNotExist = NewFailureResponseBuilder(
-		errors.New(instanceDoesntExistMsg), http.StatusGone, instanceMissingErrorKey,
-	).WithEmptyResponse().Build()
-
-	ErrInstanceLimitMet = NewFailureResponse(
-		errors.New(serviceLimitReachedMsg), http.StatusInternalServerError, instanceLimitReachedErrorKey,
-	)
-
-	ErrBindingAlreadyExists = NewFailureResponse(
-		errors.New(bindingExistsMsg), http.StatusConflict, bindingAlreadyExistsErrorKey,
-	)
-
-	ErrBindingDoesNotExist = NewFailureResponseBuilder(
-		errors.New(bindingDoesntExistMsg), http.StatusGone, bindingMissingErrorKey,
-	).WithEmptyResponse().Build()
-
-	ErrBindingNotFound = NewFailureResponseBuilder(
-		errors.New(bindingNotFoundMsg), http.StatusNotFound, bindingNotFoundErrorKey,
-	).WithEmptyResponse().Build()
-
-	ErrAsyncRequired = NewFailureResponseBuilder(
-		errors.New(asyncRequiredMsg), http.StatusUnprocessableEntity, asyncRequiredKey,
-	).WithErrorKey("AsyncRequired").Build()
-
-	ErrPlanChangeNotSupported = NewFailureResponseBuilder(
-		errors.New(planChangeUnsupportedMsg), http.StatusUnprocessableEntity, planChangeNotSupportedKey,
-	).WithErrorKey("PlanChangeNotSupported").Build()
-
-	ErrRawParamsInvalid = NewFailureResponse(
-		errors.New(rawInvalidParamsMsg), http.StatusUnprocessableEntity, invalidRawParamsKey,
-	)
-
-	ErrAppGuidNotProvided = NewFailureResponse(
-		errors.New(appGuidMissingMsg), http.StatusUnprocessableEntity, appGuidNotProvidedErrorKey,
-	)
-
-	ErrPlanQuotaExceeded    = errors.New(servicePlanQuotaExceededMsg)
-	ErrServiceQuotaExceeded = errors.New(serviceQuotaExceededMsg)
-
-	ErrConcurrentInstanceAccess = NewFailureResponseBuilder(
-		errors.New(concurrentInstanceAccessMsg), http.StatusUnprocessableEntity, concurrentAccessKey,
-	).WithErrorKey("ConcurrencyError")
-
-	ErrMaintenanceInfoConflict = NewFailureResponseBuilder(
-		errors.New(maintenanceInfoConflictMsg), http.StatusUnprocessableEntity, maintenanceInfoConflictKey,
-	).WithErrorKey("MaintenanceInfoConflict").Build()
-
-	ErrMaintenanceInfoNilConflict = NewFailureResponseBuilder(
-		errors.New(maintenanceInfoNilConflictMsg), http.StatusUnprocessableEntity, maintenanceInfoConflictKey,
-	).WithErrorKey("MaintenanceInfoConflict").Build()
-)
@@ -0,0 +1,71 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain
+
+import "encoding/json"
+
+// MergeTags returns the tags of lists in order, leaving out empty tags and
+// repeats, or nil if there are none.
+func MergeTags(lists ...[]string) []string {
+	var merged []string
+	seen := map[string]bool{}
+	for _, tags := range lists {
+		for _, tag := range tags {
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// Tags returns the tags the platform or the user gave the instance, in the
+// "tags" field of the context object and of the parameters. Fields which are
+// not arrays of strings are ignored.
+func (d ProvisionDetails) Tags() []string {
+	return MergeTags(tagsField(d.RawContext), tagsField(d.RawParameters))
+}
+
+// Tags returns the tags of the instance given in the "tags" field of the
+// context object and of the parameters of the update.
+func (d UpdateDetails) Tags() []string {
+	return MergeTags(tagsField(d.RawContext), tagsField(d.RawParameters))
+}
+
+func tagsField(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var object struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil
+	}
+	return object.Tags
+}
+
+// ServiceTags returns the tags of the service with serviceID in services.
+func ServiceTags(services []Service, serviceID string) []string {
+	for _, service := range services {
+		if service.ID == serviceID {
+			return service.Tags
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domain_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var _ = Describe("Tags", func() {
+	Describe("MergeTags", func() {
+		It("keeps the first occurrence of each tag", func() {
+			Expect(domain.MergeTags([]string{"a", "b"}, []string{"b", "", "c"})).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("returns nil when there are no tags", func() {
+			Expect(domain.MergeTags(nil, []string{""})).To(BeNil())
+		})
+	})
+
+	Describe("ProvisionDetails.Tags", func() {
+		It("reads the tags of the context and the parameters", func() {
+			details := domain.ProvisionDetails{
+				RawContext:    json.RawMessage(`{"platform":"cloudfoundry","tags":["team-a"]}`),
+				RawParameters: json.RawMessage(`{"tags":["billing","team-a"]}`),
+			}
+			Expect(details.Tags()).To(Equal([]string{"team-a", "billing"}))
+		})
+
+		It("ignores tags which are not a list of strings", func() {
+			details := domain.ProvisionDetails{
+				RawContext:    json.RawMessage(`{"tags":"team-a"}`),
+				RawParameters: json.RawMessage(`{"tags":["billing"]}`),
+			}
+			Expect(details.Tags()).To(Equal([]string{"billing"}))
+		})
+	})
+})
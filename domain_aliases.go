@@ -0,0 +1,264 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// The types, constants and errors shared by brokers and handlers live in the
+// domain package, and the response bodies and errors sent to the platform in
+// the domain/apiresponses package. They are aliased here so that brokers
+// written against the brokerapi package keep compiling.
+
+type (
+	BindDetails                = domain.BindDetails
+	BindResource               = domain.BindResource
+	BindValidator              = domain.BindValidator
+	Binding                    = domain.Binding
+	CatalogBreakingChange      = domain.CatalogBreakingChange
+	CatalogDiff                = domain.CatalogDiff
+	CatalogPlan                = domain.CatalogPlan
+	CatalogPlanChange          = domain.CatalogPlanChange
+	CredentialsOpener          = domain.CredentialsOpener
+	CredentialsTransformer     = domain.CredentialsTransformer
+	CredentialsTransformerFunc = domain.CredentialsTransformerFunc
+	DebugToggle                = domain.DebugToggle
+	DeprovisionDetails         = domain.DeprovisionDetails
+	DeprovisionServiceSpec     = domain.DeprovisionServiceSpec
+	DetailsWithRawContext      = domain.DetailsWithRawContext
+	DetailsWithRawParameters   = domain.DetailsWithRawParameters
+	DryRunResult               = domain.DryRunResult
+	ErrorReport                = domain.ErrorReport
+	ErrorReporter              = domain.ErrorReporter
+	GetBindingSpec             = domain.GetBindingSpec
+	GetInstanceDetailsSpec     = domain.GetInstanceDetailsSpec
+	Hints                      = domain.Hints
+	InstanceList               = domain.InstanceList
+	InstanceLister             = domain.InstanceLister
+	InstanceRestorer           = domain.InstanceRestorer
+	InstanceSummary            = domain.InstanceSummary
+	LastOperation              = domain.LastOperation
+	LastOperationState         = domain.LastOperationState
+	LifecycleEvent             = domain.LifecycleEvent
+	ListInstancesRequest       = domain.ListInstancesRequest
+	LockManager                = domain.LockManager
+	LifecycleEventSink         = domain.LifecycleEventSink
+	LifecycleEventType         = domain.LifecycleEventType
+	MaintenanceInfo            = domain.MaintenanceInfo
+	Meter                      = domain.Meter
+	Operation                  = domain.Operation
+	OriginatingIdentity        = domain.OriginatingIdentity
+	OrphanRemover              = domain.OrphanRemover
+	PageRequest                = domain.PageRequest
+	ParameterChange            = domain.ParameterChange
+	ParameterDecoder           = domain.ParameterDecoder
+	ParameterDecoderFunc       = domain.ParameterDecoderFunc
+	ParametersDiff             = domain.ParametersDiff
+	PlatformContext            = domain.PlatformContext
+	PollDetails                = domain.PollDetails
+	PreviousValues             = domain.PreviousValues
+	Progress                   = domain.Progress
+	ProvisionCanceller         = domain.ProvisionCanceller
+	ProvisionDetails           = domain.ProvisionDetails
+	ProvisionValidator         = domain.ProvisionValidator
+	ProvisionedServiceSpec     = domain.ProvisionedServiceSpec
+	Publisher                  = domain.Publisher
+	QuotaChecker               = domain.QuotaChecker
+	RequiredPermission         = domain.RequiredPermission
+	Schema                     = domain.Schema
+	Service                    = domain.Service
+	ServiceBindingSchema       = domain.ServiceBindingSchema
+	ServiceBroker              = domain.ServiceBroker
+	ServiceDashboardClient     = domain.ServiceDashboardClient
+	ServiceInstanceSchema      = domain.ServiceInstanceSchema
+	ServiceMetadata            = domain.ServiceMetadata
+	ServicePlan                = domain.ServicePlan
+	ServicePlanCost            = domain.ServicePlanCost
+	ServicePlanMetadata        = domain.ServicePlanMetadata
+	ServiceSchemas             = domain.ServiceSchemas
+	SharedDevice               = domain.SharedDevice
+	UnbindDetails              = domain.UnbindDetails
+	UnbindSpec                 = domain.UnbindSpec
+	UpdateDetails              = domain.UpdateDetails
+	UpdateServiceSpec          = domain.UpdateServiceSpec
+	UpdateValidator            = domain.UpdateValidator
+	UpgradeCandidate           = domain.UpgradeCandidate
+	UsageRecord                = domain.UsageRecord
+	UsageRecordType            = domain.UsageRecordType
+	VolumeMount                = domain.VolumeMount
+)
+
+const (
+	EventBindingCreated             = domain.EventBindingCreated
+	EventBindingDeleted             = domain.EventBindingDeleted
+	EventInstanceDeprovisioned      = domain.EventInstanceDeprovisioned
+	EventInstanceProvisioned        = domain.EventInstanceProvisioned
+	EventInstanceUpdated            = domain.EventInstanceUpdated
+	EventOperationFailed            = domain.EventOperationFailed
+	EventOperationSucceeded         = domain.EventOperationSucceeded
+	Failed                          = domain.Failed
+	InProgress                      = domain.InProgress
+	OperationAdminCatalogDiff       = domain.OperationAdminCatalogDiff
+	OperationAdminDebug             = domain.OperationAdminDebug
+	OperationAdminListInstances     = domain.OperationAdminListInstances
+	OperationAdminRestoreInstance   = domain.OperationAdminRestoreInstance
+	OperationAdminSweepOrphans      = domain.OperationAdminSweepOrphans
+	OperationAdminUpgradeCandidates = domain.OperationAdminUpgradeCandidates
+	OperationBind                   = domain.OperationBind
+	OperationCatalog                = domain.OperationCatalog
+	OperationDeprovision            = domain.OperationDeprovision
+	OperationGetBinding             = domain.OperationGetBinding
+	OperationGetInstance            = domain.OperationGetInstance
+	OperationLastBindingOperation   = domain.OperationLastBindingOperation
+	OperationLastOperation          = domain.OperationLastOperation
+	OperationProvision              = domain.OperationProvision
+	OperationUnbind                 = domain.OperationUnbind
+	OperationUpdate                 = domain.OperationUpdate
+	PermissionApp                   = domain.PermissionApp
+	PermissionRouteForwarding       = domain.PermissionRouteForwarding
+	PermissionSyslogDrain           = domain.PermissionSyslogDrain
+	PermissionVolumeMount           = domain.PermissionVolumeMount
+	PlatformCloudFoundry            = domain.PlatformCloudFoundry
+	PlatformKubernetes              = domain.PlatformKubernetes
+	PlatformOther                   = domain.PlatformOther
+	QuotaLimitHeader                = domain.QuotaLimitHeader
+	QuotaRemainingHeader            = domain.QuotaRemainingHeader
+	RateLimitRemainingHeader        = domain.RateLimitRemainingHeader
+	Succeeded                       = domain.Succeeded
+	UsageStarted                    = domain.UsageStarted
+	UsageStopped                    = domain.UsageStopped
+)
+
+type (
+	AsyncBindResponse                      = apiresponses.AsyncBindResponse
+	BindingResponse                        = apiresponses.BindingResponse
+	CatalogDiffResponse                    = apiresponses.CatalogDiffResponse
+	CatalogResponse                        = apiresponses.CatalogResponse
+	DeprovisionResponse                    = apiresponses.DeprovisionResponse
+	DryRunResponse                         = apiresponses.DryRunResponse
+	EmptyResponse                          = apiresponses.EmptyResponse
+	ErrorResponse                          = apiresponses.ErrorResponse
+	ExperimentalVolumeMount                = apiresponses.ExperimentalVolumeMount
+	ExperimentalVolumeMountBindingResponse = apiresponses.ExperimentalVolumeMountBindingResponse
+	ExperimentalVolumeMountPrivate         = apiresponses.ExperimentalVolumeMountPrivate
+	FailedDeletion                         = apiresponses.FailedDeletion
+	FailureResponse                        = apiresponses.FailureResponse
+	FailureResponseBuilder                 = apiresponses.FailureResponseBuilder
+	GetBindingResponse                     = apiresponses.GetBindingResponse
+	GetInstanceResponse                    = apiresponses.GetInstanceResponse
+	LastOperationResponse                  = apiresponses.LastOperationResponse
+	ListInstancesResponse                  = apiresponses.ListInstancesResponse
+	OrphanSweepResponse                    = apiresponses.OrphanSweepResponse
+	ProvisioningResponse                   = apiresponses.ProvisioningResponse
+	UnbindResponse                         = apiresponses.UnbindResponse
+	UpdateResponse                         = apiresponses.UpdateResponse
+	UpgradeCandidatesResponse              = apiresponses.UpgradeCandidatesResponse
+)
+
+var (
+	ErrAppGuidNotProvided          = apiresponses.ErrAppGuidNotProvided
+	ErrAsyncRequired               = apiresponses.ErrAsyncRequired
+	ErrBindingAlreadyExists        = apiresponses.ErrBindingAlreadyExists
+	ErrBindingDoesNotExist         = apiresponses.ErrBindingDoesNotExist
+	ErrBindingNotFound             = apiresponses.ErrBindingNotFound
+	ErrConcurrentInstanceAccess    = apiresponses.ErrConcurrentInstanceAccess
+	ErrConcurrentOperation         = apiresponses.ErrConcurrentOperation
+	ErrInstanceAlreadyExists       = apiresponses.ErrInstanceAlreadyExists
+	ErrInstanceDoesNotExist        = apiresponses.ErrInstanceDoesNotExist
+	ErrInstanceLimitMet            = apiresponses.ErrInstanceLimitMet
+	ErrInstanceNotAwaitingDeletion = apiresponses.ErrInstanceNotAwaitingDeletion
+	ErrLockHeld                    = domain.ErrLockHeld
+	ErrMaintenanceInfoConflict     = apiresponses.ErrMaintenanceInfoConflict
+	ErrMaintenanceInfoNilConflict  = apiresponses.ErrMaintenanceInfoNilConflict
+	ErrOperationTimedOut           = apiresponses.ErrOperationTimedOut
+	ErrPlanChangeNotSupported      = apiresponses.ErrPlanChangeNotSupported
+	ErrPlanQuotaExceeded           = apiresponses.ErrPlanQuotaExceeded
+	ErrProvisionInProgress         = apiresponses.ErrProvisionInProgress
+	ErrRawParamsInvalid            = apiresponses.ErrRawParamsInvalid
+	ErrRequiresApp                 = apiresponses.ErrRequiresApp
+	ErrServiceQuotaExceeded        = apiresponses.ErrServiceQuotaExceeded
+	ErrServiceUnavailable          = apiresponses.ErrServiceUnavailable
+)
+
+func BoolPtr(v bool) *bool {
+	return domain.BoolPtr(v)
+}
+
+func FreeValue(v bool) *bool {
+	return domain.FreeValue(v)
+}
+
+func BindableValue(v bool) *bool {
+	return domain.BindableValue(v)
+}
+
+func DiffParameters(previous, next json.RawMessage) (ParametersDiff, error) {
+	return domain.DiffParameters(previous, next)
+}
+
+func QuotaHints(limit, used int) Hints {
+	return domain.QuotaHints(limit, used)
+}
+
+func OrphanedIDs(platformIDs, brokerIDs []string) []string {
+	return domain.OrphanedIDs(platformIDs, brokerIDs)
+}
+
+func UpgradeCandidates(services []Service, instances []InstanceSummary) []UpgradeCandidate {
+	return domain.UpgradeCandidates(services, instances)
+}
+
+func DiffCatalogs(previous, next []Service, instances []InstanceSummary) CatalogDiff {
+	return domain.DiffCatalogs(previous, next, instances)
+}
+
+func ParseContext(raw json.RawMessage) (PlatformContext, error) {
+	return domain.ParseContext(raw)
+}
+
+func ParseOriginatingIdentity(header string) (OriginatingIdentity, error) {
+	return domain.ParseOriginatingIdentity(header)
+}
+
+func StepProgress(step, totalSteps int, description string) Progress {
+	return domain.StepProgress(step, totalSteps, description)
+}
+
+func Paginate[T any](items []T, request PageRequest, key func(T) string) ([]T, string) {
+	return domain.Paginate(items, request, key)
+}
+
+func GetJsonNames(s reflect.Value) []string {
+	return domain.GetJsonNames(s)
+}
+
+func NewFailureResponse(err error, statusCode int, loggerAction string) *FailureResponse {
+	return apiresponses.NewFailureResponse(err, statusCode, loggerAction)
+}
+
+func NewFailureResponseBuilder(err error, statusCode int, loggerAction string) *FailureResponseBuilder {
+	return apiresponses.NewFailureResponseBuilder(err, statusCode, loggerAction)
+}
+
+func NewServiceUnavailable(retryAfter time.Duration) *FailureResponse {
+	return apiresponses.NewServiceUnavailable(retryAfter)
+}
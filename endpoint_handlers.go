@@ -0,0 +1,217 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/handlers"
+)
+
+const (
+	instanceIDPattern = "{instance_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}"
+	bindingIDPattern  = "{binding_id:[A-Za-z-0-9?:$&,@;=_!\\/\\-\\.\\+\\*\\'\\(\\)]+}"
+
+	instancePath = "/v2/service_instances/" + instanceIDPattern
+	bindingPath  = instancePath + "/service_bindings/" + bindingIDPattern
+)
+
+type route struct {
+	operation Operation
+	methods   []string
+	path      string
+}
+
+// routes lists the endpoints in the order they are matched. IDs may contain
+// slashes, so the longer binding and last_operation paths must be registered
+// before the instance paths which would otherwise swallow them.
+var routes = []route{
+	{OperationCatalog, []string{"GET", "HEAD"}, "/v2/catalog"},
+	{OperationLastBindingOperation, []string{"GET"}, bindingPath + "/last_operation"},
+	{OperationGetBinding, []string{"GET"}, bindingPath},
+	{OperationBind, []string{"PUT"}, bindingPath},
+	{OperationUnbind, []string{"DELETE"}, bindingPath},
+	{OperationLastOperation, []string{"GET"}, instancePath + "/last_operation"},
+	{OperationGetInstance, []string{"GET"}, instancePath},
+	{OperationProvision, []string{"PUT"}, instancePath},
+	{OperationDeprovision, []string{"DELETE"}, instancePath},
+	{OperationUpdate, []string{"PATCH"}, instancePath},
+}
+
+// EndpointHandlers gives access to the handler of each endpoint, for brokers
+// which compose their own routing, apply middleware to individual routes or
+// serve a subset of the API.
+//
+// The handlers read the instance and binding IDs from the gorilla/mux route
+// variables "instance_id" and "binding_id". When mounting them on another
+// router, set these with mux.SetURLVars before calling the handler.
+// Options configuring the handlers apply, such as WithPublisher or
+// WithDynamicPlans; options configuring middleware, such as authentication
+// and timeouts, do not.
+type EndpointHandlers struct {
+	handler handlers.APIHandler
+}
+
+func NewEndpointHandlers(serviceBroker ServiceBroker, logger lager.Logger, opts ...Option) *EndpointHandlers {
+	cfg := newDefaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return newEndpointHandlers(serviceBroker, logger, cfg)
+}
+
+func newEndpointHandlers(serviceBroker ServiceBroker, logger lager.Logger, cfg *config) *EndpointHandlers {
+	return &EndpointHandlers{
+		handler: handlers.NewAPIHandler(serviceBroker, logger, handlers.Config{
+			EventSinks:              cfg.eventSinks,
+			ErrorReporter:           cfg.errorReporter,
+			CredentialsOpener:       cfg.credentialsOpener,
+			CredentialsTransformers: cfg.credentialsTransformers,
+			ParameterDecoders:       cfg.parameterDecoders,
+			Quotas:                  cfg.quotas,
+			Meter:                   cfg.meter,
+			StrictResponses:         cfg.strictResponses,
+			Locks:                   cfg.locks,
+			LogLevel:                cfg.logLevel,
+			LogLevelSink:            cfg.logLevelSink,
+			DebugToggles:            cfg.debugToggles,
+
+			MinimumAPIVersion: cfg.minimumAPIVersion,
+
+			RetrieveParameters:        cfg.retrieveParameters,
+			ParametersOmittedServices: cfg.parametersOmittedServices,
+			PreviousParameters:        cfg.previousParameters,
+			DynamicPlans:              cfg.dynamicPlans,
+		}),
+	}
+}
+
+// Handler returns the handler for op, or nil if op is not a known operation.
+func (e *EndpointHandlers) Handler(op Operation) http.Handler {
+	switch op {
+	case OperationCatalog:
+		return e.CatalogHandler()
+	case OperationProvision:
+		return e.ProvisionHandler()
+	case OperationDeprovision:
+		return e.DeprovisionHandler()
+	case OperationGetInstance:
+		return e.GetInstanceHandler()
+	case OperationUpdate:
+		return e.UpdateHandler()
+	case OperationLastOperation:
+		return e.LastOperationHandler()
+	case OperationBind:
+		return e.BindHandler()
+	case OperationUnbind:
+		return e.UnbindHandler()
+	case OperationGetBinding:
+		return e.GetBindingHandler()
+	case OperationLastBindingOperation:
+		return e.LastBindingOperationHandler()
+	case OperationAdminListInstances:
+		return e.ListInstancesHandler()
+	case OperationAdminSweepOrphans:
+		return e.SweepOrphansHandler()
+	case OperationAdminDebug:
+		return e.DebugSettingsHandler()
+	case OperationAdminUpgradeCandidates:
+		return e.UpgradeCandidatesHandler()
+	case OperationAdminRestoreInstance:
+		return e.RestoreInstanceHandler()
+	case OperationAdminCatalogDiff:
+		return e.CatalogDiffHandler()
+	default:
+		return nil
+	}
+}
+
+func (e *EndpointHandlers) CatalogHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Catalog)
+}
+
+func (e *EndpointHandlers) ProvisionHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Provision)
+}
+
+func (e *EndpointHandlers) DeprovisionHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Deprovision)
+}
+
+func (e *EndpointHandlers) GetInstanceHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.GetInstance)
+}
+
+func (e *EndpointHandlers) UpdateHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Update)
+}
+
+func (e *EndpointHandlers) LastOperationHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.LastOperation)
+}
+
+func (e *EndpointHandlers) BindHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Bind)
+}
+
+func (e *EndpointHandlers) UnbindHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.Unbind)
+}
+
+func (e *EndpointHandlers) GetBindingHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.GetBinding)
+}
+
+func (e *EndpointHandlers) LastBindingOperationHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.LastBindingOperation)
+}
+
+// ListInstancesHandler serves the admin extension endpoint which lists the
+// instances of a broker implementing InstanceLister.
+func (e *EndpointHandlers) ListInstancesHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.ListInstances)
+}
+
+// SweepOrphansHandler serves the admin extension endpoint which finds, and
+// optionally deletes, the instances the platform no longer knows about.
+func (e *EndpointHandlers) SweepOrphansHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.SweepOrphans)
+}
+
+// DebugSettingsHandler serves the admin extension endpoint which shows and
+// changes the log level and the debug toggles at runtime.
+func (e *EndpointHandlers) DebugSettingsHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.DebugSettings)
+}
+
+// UpgradeCandidatesHandler serves the admin extension endpoint which lists the
+// instances behind the maintenance_info of their plan.
+func (e *EndpointHandlers) UpgradeCandidatesHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.UpgradeCandidates)
+}
+
+// RestoreInstanceHandler serves the admin extension endpoint which restores an
+// instance whose deletion a broker implementing InstanceRestorer holds back.
+func (e *EndpointHandlers) RestoreInstanceHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.RestoreInstance)
+}
+
+// CatalogDiffHandler serves the admin extension endpoint comparing the catalog
+// of the broker with a new version.
+func (e *EndpointHandlers) CatalogDiffHandler() http.Handler {
+	return e.handler.NegotiateContent(e.handler.CatalogDiff)
+}
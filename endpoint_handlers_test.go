@@ -0,0 +1,101 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("EndpointHandlers", func() {
+	var (
+		fakeServiceBroker *fakes.FakeServiceBroker
+		handlers          *brokerapi.EndpointHandlers
+	)
+
+	BeforeEach(func() {
+		fakeServiceBroker = &fakes.FakeServiceBroker{
+			InstanceLimit: 3,
+			ServiceID:     "0A789746-596F-4CEA-BFAC-A0795DA056E3",
+			PlanID:        "plan-id",
+		}
+		handlers = brokerapi.NewEndpointHandlers(fakeServiceBroker, lagertest.NewTestLogger("handlers"))
+	})
+
+	It("returns a handler for every operation", func() {
+		for _, op := range []brokerapi.Operation{
+			brokerapi.OperationCatalog,
+			brokerapi.OperationProvision,
+			brokerapi.OperationDeprovision,
+			brokerapi.OperationGetInstance,
+			brokerapi.OperationUpdate,
+			brokerapi.OperationLastOperation,
+			brokerapi.OperationBind,
+			brokerapi.OperationUnbind,
+			brokerapi.OperationGetBinding,
+			brokerapi.OperationLastBindingOperation,
+		} {
+			Expect(handlers.Handler(op)).NotTo(BeNil(), string(op))
+		}
+		Expect(handlers.Handler("unknown")).To(BeNil())
+	})
+
+	It("can be mounted on a custom route", func() {
+		router := mux.NewRouter()
+		router.Handle("/provision/{instance_id}", handlers.ProvisionHandler()).Methods("PUT")
+
+		request := httptest.NewRequest("PUT", "/provision/some-instance", strings.NewReader(`{
+			"service_id": "0A789746-596F-4CEA-BFAC-A0795DA056E3",
+			"plan_id": "plan-id",
+			"organization_guid": "org-guid",
+			"space_guid": "space-guid"
+		}`))
+		request.Header.Set("X-Broker-API-Version", "2.14")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(fakeServiceBroker.GetProvisionedInstanceIDs()).To(ConsistOf("some-instance"))
+	})
+
+	It("reads IDs set with mux.SetURLVars when mounted on another router", func() {
+		fakeServiceBroker.ProvisionedInstanceIDs = []string{"some-instance"}
+		fakeServiceBroker.BoundBindingIDs = []string{"some-binding"}
+
+		serveMux := http.NewServeMux()
+		serveMux.HandleFunc("/bindings/", func(w http.ResponseWriter, req *http.Request) {
+			ids := strings.Split(strings.TrimPrefix(req.URL.Path, "/bindings/"), "/")
+			req = mux.SetURLVars(req, map[string]string{"instance_id": ids[0], "binding_id": ids[1]})
+			handlers.UnbindHandler().ServeHTTP(w, req)
+		})
+
+		request := httptest.NewRequest("DELETE", "/bindings/some-instance/some-binding?service_id=service-id&plan_id=plan-id", nil)
+		request.Header.Set("X-Broker-API-Version", "2.14")
+		recorder := httptest.NewRecorder()
+		serveMux.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(fakeServiceBroker.GetUnbindingDetails().ServiceID).To(Equal("service-id"))
+	})
+})
@@ -0,0 +1,269 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envelope encrypts the binding credentials a broker keeps in its own
+// store. Each value is encrypted with AES-GCM under a data key of its own,
+// which is in turn encrypted with a key encryption key from a KeyRing, so
+// that keys can be rotated by re-encrypting the data keys only. A KeyRing is
+// a brokerapi.CredentialsOpener: credentials sealed with SealCredentials can
+// be returned by Bind and GetBinding as stored, and are decrypted before they
+// are sent to the platform.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+const (
+	version = "v1"
+
+	// KeySize is the length of the key encryption keys, for AES-256.
+	KeySize     = 32
+	dataKeySize = 32
+)
+
+var (
+	// ErrInvalidEnvelope is returned by Open when the value was not sealed by
+	// a KeyRing, or has been altered.
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+
+	// ErrUnknownKey is returned by Open when the value was sealed with a key
+	// which is not in the KeyRing.
+	ErrUnknownKey = errors.New("envelope was sealed with an unknown key")
+
+	errNoPrimaryKey = errors.New("the key ring has no primary key")
+
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	encoding     = base64.RawURLEncoding
+)
+
+var _ domain.CredentialsOpener = (*KeyRing)(nil)
+
+// Sealed is a value sealed by a KeyRing, in the form
+// "v1.<key id>.<encrypted data key>.<ciphertext>". It can be stored as a
+// string.
+type Sealed string
+
+// KeyID returns the ID of the key which sealed the value.
+func (s Sealed) KeyID() string {
+	parts := strings.Split(string(s), ".")
+	if len(parts) != 4 || parts[0] != version {
+		return ""
+	}
+	return parts[1]
+}
+
+// KeyRing holds the key encryption keys, one of which is the primary key
+// used to seal new values. It is safe for concurrent use.
+type KeyRing struct {
+	mutex   sync.RWMutex
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+// NewKeyRing returns a KeyRing whose primary key is key, identified by id.
+func NewKeyRing(id string, key []byte) (*KeyRing, error) {
+	ring := &KeyRing{keys: map[string]cipher.AEAD{}}
+	if err := ring.AddKey(id, key); err != nil {
+		return nil, err
+	}
+	ring.primary = id
+	return ring, nil
+}
+
+// AddKey adds a key which opens values sealed with it. The ID, made of
+// letters, digits, '-' and '_', is stored with each sealed value, and the key
+// must be KeySize bytes long.
+func (r *KeyRing) AddKey(id string, key []byte) error {
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	if len(key) != KeySize {
+		return fmt.Errorf("key %q is %d bytes long, it must be %d", id, len(key), KeySize)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.keys[id] = aead
+	return nil
+}
+
+// Rotate makes the key identified by id, which must have been added, the
+// primary key. Values sealed with the previous primary key still open, and
+// can be sealed with the new one with Reseal.
+func (r *KeyRing) Rotate(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.keys[id]; !ok {
+		return fmt.Errorf("key %q is not in the key ring", id)
+	}
+	r.primary = id
+	return nil
+}
+
+// Seal encrypts plaintext with a new data key, encrypted with the primary
+// key.
+func (r *KeyRing) Seal(plaintext []byte) (Sealed, error) {
+	r.mutex.RLock()
+	id, kek := r.primary, r.keys[r.primary]
+	r.mutex.RUnlock()
+	if kek == nil {
+		return "", errNoPrimaryKey
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	// The key ID is authenticated with both layers, so that an envelope
+	// cannot be moved to another key.
+	additionalData := []byte(version + "." + id)
+	encryptedKey, err := seal(kek, dataKey, additionalData)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return Sealed(strings.Join([]string{version, id, encoding.EncodeToString(encryptedKey), encoding.EncodeToString(ciphertext)}, ".")), nil
+}
+
+// Open decrypts a value returned by Seal.
+func (r *KeyRing) Open(sealed Sealed) ([]byte, error) {
+	parts := strings.Split(string(sealed), ".")
+	if len(parts) != 4 || parts[0] != version {
+		return nil, ErrInvalidEnvelope
+	}
+	id := parts[1]
+	encryptedKey, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	ciphertext, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	r.mutex.RLock()
+	kek := r.keys[id]
+	r.mutex.RUnlock()
+	if kek == nil {
+		return nil, ErrUnknownKey
+	}
+
+	additionalData := []byte(version + "." + id)
+	dataKey, err := open(kek, encryptedKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	return open(dek, ciphertext, additionalData)
+}
+
+// NeedsReseal reports whether sealed was sealed with another key than the
+// primary key.
+func (r *KeyRing) NeedsReseal(sealed Sealed) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return sealed.KeyID() != r.primary
+}
+
+// Reseal returns sealed sealed with the primary key, so that old keys can be
+// removed from the ring once every stored value has been resealed.
+func (r *KeyRing) Reseal(sealed Sealed) (Sealed, error) {
+	plaintext, err := r.Open(sealed)
+	if err != nil {
+		return "", err
+	}
+	return r.Seal(plaintext)
+}
+
+// SealCredentials seals the JSON encoding of credentials, for the broker to
+// store instead of the credentials.
+func (r *KeyRing) SealCredentials(credentials interface{}) (Sealed, error) {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+	return r.Seal(plaintext)
+}
+
+// OpenCredentials returns the credentials sealed in a Sealed value as a
+// json.RawMessage, and other values unchanged.
+func (r *KeyRing) OpenCredentials(credentials interface{}) (interface{}, error) {
+	sealed, ok := credentials.(Sealed)
+	if !ok {
+		return credentials, nil
+	}
+	plaintext, err := r.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prefixes the ciphertext with its random nonce.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, ErrInvalidEnvelope
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,144 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/envelope"
+)
+
+var _ = Describe("KeyRing", func() {
+	var ring *envelope.KeyRing
+
+	key := func(b byte) []byte {
+		return bytes.Repeat([]byte{b}, envelope.KeySize)
+	}
+
+	BeforeEach(func() {
+		var err error
+		ring, err = envelope.NewKeyRing("key-1", key(1))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("opens what it seals", func() {
+		sealed, err := ring.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(sealed)).To(HavePrefix("v1.key-1."))
+		Expect(string(sealed)).NotTo(ContainSubstring("secret"))
+
+		plaintext, err := ring.Open(sealed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal("secret"))
+	})
+
+	It("uses a new data key for each value", func() {
+		first, err := ring.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+		second, err := ring.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Split(string(first), ".")[2]).NotTo(Equal(strings.Split(string(second), ".")[2]))
+	})
+
+	It("rejects altered values", func() {
+		sealed, err := ring.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+		parts := strings.Split(string(sealed), ".")
+		parts[3] = strings.ToUpper(parts[3])
+
+		_, err = ring.Open(envelope.Sealed(strings.Join(parts, ".")))
+		Expect(err).To(MatchError(envelope.ErrInvalidEnvelope))
+		_, err = ring.Open("not an envelope")
+		Expect(err).To(MatchError(envelope.ErrInvalidEnvelope))
+	})
+
+	It("rejects values which were moved to another key", func() {
+		Expect(ring.AddKey("key-2", key(1))).To(Succeed())
+		sealed, err := ring.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = ring.Open(envelope.Sealed(strings.Replace(string(sealed), "key-1", "key-2", 1)))
+		Expect(err).To(MatchError(envelope.ErrInvalidEnvelope))
+	})
+
+	It("rejects values sealed with an unknown key", func() {
+		other, err := envelope.NewKeyRing("key-9", key(9))
+		Expect(err).NotTo(HaveOccurred())
+		sealed, err := other.Seal([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = ring.Open(sealed)
+		Expect(err).To(MatchError(envelope.ErrUnknownKey))
+	})
+
+	It("rejects invalid keys", func() {
+		Expect(ring.AddKey("key 2", key(2))).To(MatchError(ContainSubstring("invalid key id")))
+		Expect(ring.AddKey("key-2", []byte("short"))).To(MatchError(ContainSubstring("must be 32")))
+		Expect(ring.Rotate("key-3")).To(MatchError(ContainSubstring("not in the key ring")))
+	})
+
+	Describe("rotation", func() {
+		var sealed envelope.Sealed
+
+		BeforeEach(func() {
+			var err error
+			sealed, err = ring.Seal([]byte("secret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ring.AddKey("key-2", key(2))).To(Succeed())
+			Expect(ring.Rotate("key-2")).To(Succeed())
+		})
+
+		It("keeps opening values sealed with the previous key", func() {
+			Expect(ring.NeedsReseal(sealed)).To(BeTrue())
+			plaintext, err := ring.Open(sealed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plaintext)).To(Equal("secret"))
+		})
+
+		It("reseals values with the primary key", func() {
+			resealed, err := ring.Reseal(sealed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resealed.KeyID()).To(Equal("key-2"))
+			Expect(ring.NeedsReseal(resealed)).To(BeFalse())
+
+			plaintext, err := ring.Open(resealed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plaintext)).To(Equal("secret"))
+		})
+	})
+
+	Describe("credentials", func() {
+		It("opens sealed credentials as JSON", func() {
+			sealed, err := ring.SealCredentials(map[string]string{"password": "secret"})
+			Expect(err).NotTo(HaveOccurred())
+
+			credentials, err := ring.OpenCredentials(sealed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(credentials).To(BeAssignableToTypeOf(json.RawMessage{}))
+			Expect(credentials).To(MatchJSON(`{"password":"secret"}`))
+		})
+
+		It("passes other credentials on unchanged", func() {
+			credentials, err := ring.OpenCredentials(map[string]string{"password": "plain"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(credentials).To(Equal(map[string]string{"password": "plain"}))
+		})
+	})
+})
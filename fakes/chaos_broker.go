@@ -0,0 +1,271 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi"
+)
+
+const chaosOperationPrefix = "chaos-"
+
+// ErrInjected is returned by a ChaosBroker for injected failures unless
+// ChaosConfig.Error is set.
+var ErrInjected = brokerapi.NewFailureResponse(errors.New("injected failure"), http.StatusServiceUnavailable, "injected-failure")
+
+// ChaosConfig configures a ChaosBroker.
+type ChaosConfig struct {
+	// Latency delays every call, plus a random duration of up to Jitter.
+	// Calls return the error of their context if it is done first.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// FailEvery fails every nth call of each operation, and FailureRate
+	// fails calls at random with the given probability, from 0 to 1.
+	FailEvery   int
+	FailureRate float64
+	// Error is returned by failed calls, ErrInjected by default.
+	Error error
+
+	// Operations lists the operations which are delayed and failed. When it
+	// is empty every operation but the catalog is, since the handlers also
+	// read the catalog to validate requests.
+	Operations []brokerapi.Operation
+
+	// AsyncPolls, when positive, makes provision, update, deprovision, bind
+	// and unbind asynchronous whenever the platform allows it. Their last
+	// operation is in progress for AsyncPolls polls, then AsyncOutcome,
+	// which is succeeded by default, with AsyncDescription.
+	AsyncPolls       int
+	AsyncOutcome     brokerapi.LastOperationState
+	AsyncDescription string
+
+	// Seed seeds the random jitter and failures, so that runs can be
+	// repeated.
+	Seed int64
+}
+
+// ChaosBroker wraps a ServiceBroker, such as an inmemory.Broker, with
+// artificial latency, intermittent failures and asynchronous operations which
+// finish after a number of polls, for testing how platforms retry and time
+// out.
+type ChaosBroker struct {
+	broker brokerapi.ServiceBroker
+	config ChaosConfig
+
+	mutex      sync.Mutex
+	random     *rand.Rand
+	calls      map[brokerapi.Operation]int
+	operations map[string]*chaosOperation
+	nextID     int
+}
+
+type chaosOperation struct {
+	polls int
+}
+
+var _ brokerapi.ServiceBroker = (*ChaosBroker)(nil)
+
+// NewChaosBroker returns a ChaosBroker passing calls on to broker.
+func NewChaosBroker(broker brokerapi.ServiceBroker, config ChaosConfig) *ChaosBroker {
+	if config.Error == nil {
+		config.Error = ErrInjected
+	}
+	if config.AsyncOutcome == "" {
+		config.AsyncOutcome = brokerapi.Succeeded
+	}
+	return &ChaosBroker{
+		broker:     broker,
+		config:     config,
+		random:     rand.New(rand.NewSource(config.Seed)),
+		calls:      map[brokerapi.Operation]int{},
+		operations: map[string]*chaosOperation{},
+	}
+}
+
+// Calls returns how many times operation has been called.
+func (b *ChaosBroker) Calls(operation brokerapi.Operation) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.calls[operation]
+}
+
+func (b *ChaosBroker) Services(ctx context.Context) ([]brokerapi.Service, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationCatalog); err != nil {
+		return nil, err
+	}
+	return b.broker.Services(ctx)
+}
+
+func (b *ChaosBroker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationProvision); err != nil {
+		return brokerapi.ProvisionedServiceSpec{}, err
+	}
+	spec, err := b.broker.Provision(ctx, instanceID, details, asyncAllowed)
+	if err == nil && !spec.IsAsync && b.async(asyncAllowed) {
+		spec.IsAsync, spec.OperationData = true, b.startOperation()
+	}
+	return spec, err
+}
+
+func (b *ChaosBroker) Deprovision(ctx context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationDeprovision); err != nil {
+		return brokerapi.DeprovisionServiceSpec{}, err
+	}
+	spec, err := b.broker.Deprovision(ctx, instanceID, details, asyncAllowed)
+	if err == nil && !spec.IsAsync && b.async(asyncAllowed) {
+		spec.IsAsync, spec.OperationData = true, b.startOperation()
+	}
+	return spec, err
+}
+
+func (b *ChaosBroker) GetInstance(ctx context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationGetInstance); err != nil {
+		return brokerapi.GetInstanceDetailsSpec{}, err
+	}
+	return b.broker.GetInstance(ctx, instanceID)
+}
+
+func (b *ChaosBroker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (brokerapi.UpdateServiceSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationUpdate); err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+	spec, err := b.broker.Update(ctx, instanceID, details, asyncAllowed)
+	if err == nil && !spec.IsAsync && b.async(asyncAllowed) {
+		spec.IsAsync, spec.OperationData = true, b.startOperation()
+	}
+	return spec, err
+}
+
+func (b *ChaosBroker) LastOperation(ctx context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationLastOperation); err != nil {
+		return brokerapi.LastOperation{}, err
+	}
+	if lastOperation, ok := b.poll(details.OperationData); ok {
+		return lastOperation, nil
+	}
+	return b.broker.LastOperation(ctx, instanceID, details)
+}
+
+func (b *ChaosBroker) Bind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokerapi.Binding, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationBind); err != nil {
+		return brokerapi.Binding{}, err
+	}
+	binding, err := b.broker.Bind(ctx, instanceID, bindingID, details, asyncAllowed)
+	if err == nil && !binding.IsAsync && b.async(asyncAllowed) {
+		// The credentials are fetched with GetBinding once the binding has
+		// been created.
+		binding = brokerapi.Binding{IsAsync: true, OperationData: b.startOperation()}
+	}
+	return binding, err
+}
+
+func (b *ChaosBroker) Unbind(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokerapi.UnbindSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationUnbind); err != nil {
+		return brokerapi.UnbindSpec{}, err
+	}
+	spec, err := b.broker.Unbind(ctx, instanceID, bindingID, details, asyncAllowed)
+	if err == nil && !spec.IsAsync && b.async(asyncAllowed) {
+		spec.IsAsync, spec.OperationData = true, b.startOperation()
+	}
+	return spec, err
+}
+
+func (b *ChaosBroker) GetBinding(ctx context.Context, instanceID, bindingID string) (brokerapi.GetBindingSpec, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationGetBinding); err != nil {
+		return brokerapi.GetBindingSpec{}, err
+	}
+	return b.broker.GetBinding(ctx, instanceID, bindingID)
+}
+
+func (b *ChaosBroker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	if err := b.disrupt(ctx, brokerapi.OperationLastBindingOperation); err != nil {
+		return brokerapi.LastOperation{}, err
+	}
+	if lastOperation, ok := b.poll(details.OperationData); ok {
+		return lastOperation, nil
+	}
+	return b.broker.LastBindingOperation(ctx, instanceID, bindingID, details)
+}
+
+// disrupt counts the call, delays it and decides whether it fails.
+func (b *ChaosBroker) disrupt(ctx context.Context, operation brokerapi.Operation) error {
+	b.mutex.Lock()
+	b.calls[operation]++
+	if !b.disrupted(operation) {
+		b.mutex.Unlock()
+		return nil
+	}
+	delay := b.config.Latency
+	if b.config.Jitter > 0 {
+		delay += time.Duration(b.random.Int63n(int64(b.config.Jitter)))
+	}
+	fail := (b.config.FailEvery > 0 && b.calls[operation]%b.config.FailEvery == 0) ||
+		(b.config.FailureRate > 0 && b.random.Float64() < b.config.FailureRate)
+	b.mutex.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if fail {
+		return b.config.Error
+	}
+	return nil
+}
+
+func (b *ChaosBroker) disrupted(operation brokerapi.Operation) bool {
+	if len(b.config.Operations) == 0 {
+		return operation != brokerapi.OperationCatalog
+	}
+	for _, disrupted := range b.config.Operations {
+		if disrupted == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *ChaosBroker) async(asyncAllowed bool) bool {
+	return asyncAllowed && b.config.AsyncPolls > 0
+}
+
+func (b *ChaosBroker) startOperation() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.nextID++
+	id := fmt.Sprintf("%s%d", chaosOperationPrefix, b.nextID)
+	b.operations[id] = &chaosOperation{}
+	return id
+}
+
+// poll returns the state of an operation started by the ChaosBroker.
+func (b *ChaosBroker) poll(operationData string) (brokerapi.LastOperation, bool) {
+	if !strings.HasPrefix(operationData, chaosOperationPrefix) {
+		return brokerapi.LastOperation{}, false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	op, ok := b.operations[operationData]
+	if !ok {
+		return brokerapi.LastOperation{}, false
+	}
+
+	op.polls++
+	if op.polls <= b.config.AsyncPolls {
+		progress := brokerapi.StepProgress(op.polls, b.config.AsyncPolls, "")
+		return brokerapi.LastOperation{State: brokerapi.InProgress, Progress: &progress}, true
+	}
+	return brokerapi.LastOperation{State: b.config.AsyncOutcome, Description: b.config.AsyncDescription}, true
+}
@@ -3,11 +3,14 @@ package fakes
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/sharma-tapas/brokerapi"
 )
 
 type FakeServiceBroker struct {
+	mutex sync.Mutex
+
 	ProvisionDetails   brokerapi.ProvisionDetails
 	UpdateDetails      brokerapi.UpdateDetails
 	DeprovisionDetails brokerapi.DeprovisionDetails
@@ -41,6 +44,9 @@ type FakeServiceBroker struct {
 	LastOperationState       brokerapi.LastOperationState
 	LastOperationDescription string
 
+	LastOperationInstanceUsable   *bool
+	LastOperationUpdateRepeatable *bool
+
 	AsyncAllowed bool
 
 	ShouldReturnAsync     bool
@@ -57,15 +63,18 @@ type FakeServiceBroker struct {
 }
 
 type FakeAsyncServiceBroker struct {
-	FakeServiceBroker
+	*FakeServiceBroker
 	ShouldProvisionAsync bool
 }
 
 type FakeAsyncOnlyServiceBroker struct {
-	FakeServiceBroker
+	*FakeServiceBroker
 }
 
 func (fakeBroker *FakeServiceBroker) Services(ctx context.Context) ([]brokerapi.Service, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := ctx.Value("test_context").(bool); ok {
@@ -78,11 +87,13 @@ func (fakeBroker *FakeServiceBroker) Services(ctx context.Context) ([]brokerapi.
 
 	return []brokerapi.Service{
 		{
-			ID:            fakeBroker.ServiceID,
-			Name:          "p-cassandra",
-			Description:   "Cassandra service for application development and testing",
-			Bindable:      true,
-			PlanUpdatable: true,
+			ID:                   fakeBroker.ServiceID,
+			Name:                 "p-cassandra",
+			Description:          "Cassandra service for application development and testing",
+			Bindable:             true,
+			InstancesRetrievable: true,
+			BindingsRetrievable:  true,
+			PlanUpdatable:        true,
 			Plans: []brokerapi.ServicePlan{
 				{
 					ID:          fakeBroker.PlanID,
@@ -156,6 +167,9 @@ func (fakeBroker *FakeServiceBroker) Services(ctx context.Context) ([]brokerapi.
 }
 
 func (fakeBroker *FakeServiceBroker) Provision(context context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -180,6 +194,9 @@ func (fakeBroker *FakeServiceBroker) Provision(context context.Context, instance
 }
 
 func (fakeBroker *FakeAsyncServiceBroker) Provision(context context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if fakeBroker.ProvisionError != nil {
@@ -200,6 +217,9 @@ func (fakeBroker *FakeAsyncServiceBroker) Provision(context context.Context, ins
 }
 
 func (fakeBroker *FakeAsyncOnlyServiceBroker) Provision(context context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (brokerapi.ProvisionedServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if fakeBroker.ProvisionError != nil {
@@ -224,6 +244,9 @@ func (fakeBroker *FakeAsyncOnlyServiceBroker) Provision(context context.Context,
 }
 
 func (fakeBroker *FakeServiceBroker) Update(context context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (brokerapi.UpdateServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -241,6 +264,9 @@ func (fakeBroker *FakeServiceBroker) Update(context context.Context, instanceID
 }
 
 func (fakeBroker *FakeServiceBroker) GetInstance(context context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -259,6 +285,9 @@ func (fakeBroker *FakeServiceBroker) GetInstance(context context.Context, instan
 }
 
 func (fakeBroker *FakeServiceBroker) Deprovision(context context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -279,6 +308,9 @@ func (fakeBroker *FakeServiceBroker) Deprovision(context context.Context, instan
 }
 
 func (fakeBroker *FakeAsyncOnlyServiceBroker) Deprovision(context context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if fakeBroker.DeprovisionError != nil {
@@ -300,6 +332,9 @@ func (fakeBroker *FakeAsyncOnlyServiceBroker) Deprovision(context context.Contex
 }
 
 func (fakeBroker *FakeAsyncServiceBroker) Deprovision(context context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (brokerapi.DeprovisionServiceSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if fakeBroker.DeprovisionError != nil {
@@ -317,6 +352,9 @@ func (fakeBroker *FakeAsyncServiceBroker) Deprovision(context context.Context, i
 }
 
 func (fakeBroker *FakeServiceBroker) GetBinding(context context.Context, instanceID, bindingID string) (brokerapi.GetBindingSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -337,6 +375,13 @@ func (fakeBroker *FakeServiceBroker) GetBinding(context context.Context, instanc
 }
 
 func (fakeBroker *FakeAsyncServiceBroker) Bind(context context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokerapi.Binding, error) {
+	if !asyncAllowed {
+		return fakeBroker.FakeServiceBroker.Bind(context, instanceID, bindingID, details, false)
+	}
+
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	fakeBroker.BoundBindingDetails = details
@@ -344,17 +389,16 @@ func (fakeBroker *FakeAsyncServiceBroker) Bind(context context.Context, instance
 	fakeBroker.BoundInstanceIDs = append(fakeBroker.BoundInstanceIDs, instanceID)
 	fakeBroker.BoundBindingIDs = append(fakeBroker.BoundBindingIDs, bindingID)
 
-	if asyncAllowed {
-		return brokerapi.Binding{
-			IsAsync:       true,
-			OperationData: "0xDEADBEEF",
-		}, nil
-	} else {
-		return fakeBroker.FakeServiceBroker.Bind(context, instanceID, bindingID, details, false)
-	}
+	return brokerapi.Binding{
+		IsAsync:       true,
+		OperationData: "0xDEADBEEF",
+	}, nil
 }
 
 func (fakeBroker *FakeServiceBroker) Bind(context context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokerapi.Binding, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -384,6 +428,9 @@ func (fakeBroker *FakeServiceBroker) Bind(context context.Context, instanceID, b
 }
 
 func (fakeBroker *FakeServiceBroker) Unbind(context context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokerapi.UnbindSpec, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.BrokerCalled = true
 
 	if val, ok := context.Value("test_context").(bool); ok {
@@ -407,6 +454,8 @@ func (fakeBroker *FakeServiceBroker) Unbind(context context.Context, instanceID,
 }
 
 func (fakeBroker *FakeServiceBroker) LastBindingOperation(context context.Context, instanceID, bindingID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
 
 	if val, ok := context.Value("test_context").(bool); ok {
 		fakeBroker.ReceivedContext = val
@@ -420,6 +469,9 @@ func (fakeBroker *FakeServiceBroker) LastBindingOperation(context context.Contex
 }
 
 func (fakeBroker *FakeServiceBroker) LastOperation(context context.Context, instanceID string, details brokerapi.PollDetails) (brokerapi.LastOperation, error) {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
 	fakeBroker.LastOperationInstanceID = instanceID
 	fakeBroker.LastOperationData = details.OperationData
 
@@ -431,7 +483,116 @@ func (fakeBroker *FakeServiceBroker) LastOperation(context context.Context, inst
 		return brokerapi.LastOperation{}, fakeBroker.LastOperationError
 	}
 
-	return brokerapi.LastOperation{State: fakeBroker.LastOperationState, Description: fakeBroker.LastOperationDescription}, nil
+	return brokerapi.LastOperation{
+		State:            fakeBroker.LastOperationState,
+		Description:      fakeBroker.LastOperationDescription,
+		InstanceUsable:   fakeBroker.LastOperationInstanceUsable,
+		UpdateRepeatable: fakeBroker.LastOperationUpdateRepeatable,
+	}, nil
+}
+
+// GetProvisionedInstanceIDs returns a copy of the instance IDs passed to Provision.
+func (fakeBroker *FakeServiceBroker) GetProvisionedInstanceIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.ProvisionedInstanceIDs)
+}
+
+// GetDeprovisionedInstanceIDs returns a copy of the instance IDs passed to Deprovision.
+func (fakeBroker *FakeServiceBroker) GetDeprovisionedInstanceIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.DeprovisionedInstanceIDs)
+}
+
+// GetUpdatedInstanceIDs returns a copy of the instance IDs passed to Update.
+func (fakeBroker *FakeServiceBroker) GetUpdatedInstanceIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.UpdatedInstanceIDs)
+}
+
+// GetGetInstanceIDs returns a copy of the instance IDs passed to GetInstance.
+func (fakeBroker *FakeServiceBroker) GetGetInstanceIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.GetInstanceIDs)
+}
+
+// GetBoundInstanceIDs returns a copy of the instance IDs passed to Bind.
+func (fakeBroker *FakeServiceBroker) GetBoundInstanceIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.BoundInstanceIDs)
+}
+
+// GetBoundBindingIDs returns a copy of the binding IDs passed to Bind.
+func (fakeBroker *FakeServiceBroker) GetBoundBindingIDs() []string {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return copyStrings(fakeBroker.BoundBindingIDs)
+}
+
+// GetProvisionDetails returns the details most recently passed to Provision.
+func (fakeBroker *FakeServiceBroker) GetProvisionDetails() brokerapi.ProvisionDetails {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.ProvisionDetails
+}
+
+// GetUpdateDetails returns the details most recently passed to Update.
+func (fakeBroker *FakeServiceBroker) GetUpdateDetails() brokerapi.UpdateDetails {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.UpdateDetails
+}
+
+// GetDeprovisionDetails returns the details most recently passed to Deprovision.
+func (fakeBroker *FakeServiceBroker) GetDeprovisionDetails() brokerapi.DeprovisionDetails {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.DeprovisionDetails
+}
+
+// GetBoundBindingDetails returns the details most recently passed to Bind.
+func (fakeBroker *FakeServiceBroker) GetBoundBindingDetails() brokerapi.BindDetails {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.BoundBindingDetails
+}
+
+// GetUnbindingDetails returns the details most recently passed to Unbind.
+func (fakeBroker *FakeServiceBroker) GetUnbindingDetails() brokerapi.UnbindDetails {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.UnbindingDetails
+}
+
+// WasBrokerCalled reports whether any broker method has been invoked.
+func (fakeBroker *FakeServiceBroker) WasBrokerCalled() bool {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.BrokerCalled
+}
+
+// HasReceivedContext reports whether a broker method saw the "test_context" value.
+func (fakeBroker *FakeServiceBroker) HasReceivedContext() bool {
+	fakeBroker.mutex.Lock()
+	defer fakeBroker.mutex.Unlock()
+
+	return fakeBroker.ReceivedContext
 }
 
 type FakeCredentials struct {
@@ -449,3 +610,10 @@ func sliceContains(needle string, haystack []string) bool {
 	}
 	return false
 }
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const invalidCatalogDiffRequestKey = "invalid-catalog-diff-request"
+
+var servicesMissingError = errors.New("services missing")
+
+type catalogDiffRequest struct {
+	Services []domain.Service `json:"services"`
+}
+
+// CatalogDiff serves POST /admin/catalog_diff. It compares the catalog of the
+// broker with the one in the request, given in the format of the
+// GET /v2/catalog response, so that operators can check a new catalog
+// before rolling it out. When the broker is an InstanceLister, removed plans
+// which instances still use are reported as breaking changes.
+func (h APIHandler) CatalogDiff(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(adminCatalogDiffLogKey, nil)
+
+	var request catalogDiffRequest
+	if !h.decodeRequestBody(w, logger, invalidCatalogDiffRequestKey, req, &request) {
+		return
+	}
+	// As with the orphan sweep, an absent list would report every service as
+	// removed, so it has to be given explicitly.
+	if request.Services == nil {
+		logger.Error(invalidCatalogDiffRequestKey, servicesMissingError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: servicesMissingError.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	var instances []domain.InstanceSummary
+	lister, instancesChecked := h.serviceBroker.(domain.InstanceLister)
+	if instancesChecked {
+		instances, err = listAllInstances(req, lister)
+		if err != nil {
+			h.respondWithBrokerError(w, req, logger, err)
+			return
+		}
+	}
+
+	h.respond(w, http.StatusOK, apiresponses.CatalogDiffResponse{
+		CatalogDiff:      domain.DiffCatalogs(services, request.Services, instances),
+		InstancesChecked: instancesChecked,
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	invalidDebugSettingsKey = "invalid-debug-settings"
+	debugSettingsChangedKey = "debug-settings-changed"
+)
+
+var logLevelFixedError = errors.New("the log level of the broker cannot be changed at runtime")
+
+type debugSettingsRequest struct {
+	LogLevel *string         `json:"log_level"`
+	Toggles  map[string]bool `json:"toggles"`
+}
+
+// DebugSettings serves GET and PUT /admin/debug. GET responds with the log
+// level and the state of the debug toggles; PUT changes those given in the
+// request and responds with the new state. Nothing is changed when any of the
+// requested changes is invalid.
+func (h APIHandler) DebugSettings(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(adminDebugLogKey, nil)
+
+	if req.Method == http.MethodPut {
+		var request debugSettingsRequest
+		if !h.decodeRequestBody(w, logger, invalidDebugSettingsKey, req, &request) {
+			return
+		}
+		if status, err := h.applyDebugSettings(request); err != nil {
+			logger.Error(invalidDebugSettingsKey, err)
+			h.respond(w, status, apiresponses.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+		// Logged at INFO whatever the new level, so that the change itself
+		// is on record.
+		h.logger.Info(debugSettingsChangedKey, lager.Data{
+			"log_level": request.LogLevel,
+			"toggles":   request.Toggles,
+		})
+	}
+
+	h.respond(w, http.StatusOK, h.debugSettings())
+}
+
+func (h APIHandler) applyDebugSettings(request debugSettingsRequest) (int, error) {
+	var level lager.LogLevel
+	if request.LogLevel != nil {
+		if h.logLevelSink == nil {
+			return http.StatusNotImplemented, logLevelFixedError
+		}
+		var err error
+		if level, err = lager.LogLevelFromString(strings.ToLower(*request.LogLevel)); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	for name := range request.Toggles {
+		if _, ok := h.debugToggles[name]; !ok {
+			return http.StatusBadRequest, fmt.Errorf("unknown debug toggle %q", name)
+		}
+	}
+
+	if request.LogLevel != nil {
+		h.logLevelSink.SetMinLevel(level)
+	}
+	for name, enabled := range request.Toggles {
+		h.debugToggles[name].SetEnabled(enabled)
+	}
+	return http.StatusOK, nil
+}
+
+func (h APIHandler) debugSettings() apiresponses.DebugSettingsResponse {
+	settings := apiresponses.DebugSettingsResponse{
+		LogLevel: h.minLogLevel().String(),
+		Toggles:  make(map[string]bool, len(h.debugToggles)),
+	}
+	for name, toggle := range h.debugToggles {
+		settings.Toggles[name] = toggle.Enabled()
+	}
+	return settings
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const listNotSupportedKey = "list-instances-not-supported"
+
+var listNotSupportedError = errors.New("the broker does not support listing service instances")
+
+// ListInstances serves GET /admin/service_instances, returning a page of at
+// most limit instances, 100 by default and 1000 at most, starting at cursor.
+func (h APIHandler) ListInstances(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(adminListInstancesLogKey, nil)
+
+	lister, ok := h.serviceBroker.(domain.InstanceLister)
+	if !ok {
+		logger.Error(listNotSupportedKey, listNotSupportedError)
+		h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+			Description: listNotSupportedError.Error(),
+		})
+		return
+	}
+
+	page, err := pageRequest(req)
+	if err != nil {
+		logger.Error(invalidLimitKey, err)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	list, err := lister.ListInstances(req.Context(), page)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	instances := list.Instances
+	if instances == nil {
+		instances = []domain.InstanceSummary{}
+	}
+	setNextPageLink(w, req, list.NextCursor)
+	h.respond(w, http.StatusOK, apiresponses.ListInstancesResponse{
+		ServiceInstances: instances,
+		NextCursor:       list.NextCursor,
+	})
+}
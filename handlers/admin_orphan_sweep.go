@@ -0,0 +1,134 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	invalidSweepRequestKey = "invalid-orphan-sweep-request"
+	removeNotSupportedKey  = "remove-orphans-not-supported"
+	removeOrphanFailedKey  = "remove-orphan-failed"
+)
+
+var (
+	platformIDsMissingError = errors.New("platform_instance_ids missing")
+	removeNotSupportedError = errors.New("the broker does not support deleting orphaned service instances")
+)
+
+type orphanSweepRequest struct {
+	PlatformInstanceIDs []string `json:"platform_instance_ids"`
+	Delete              bool     `json:"delete"`
+}
+
+// SweepOrphans serves POST /admin/orphan_sweep. It compares the instance IDs
+// known to the platform, given in the request, with those listed by the
+// broker, and responds with the instances only the broker knows about. When
+// delete is set, those instances are also removed from the broker.
+func (h APIHandler) SweepOrphans(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(adminSweepOrphansLogKey, nil)
+
+	lister, ok := h.serviceBroker.(domain.InstanceLister)
+	if !ok {
+		logger.Error(listNotSupportedKey, listNotSupportedError)
+		h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+			Description: listNotSupportedError.Error(),
+		})
+		return
+	}
+
+	var request orphanSweepRequest
+	if !h.decodeRequestBody(w, logger, invalidSweepRequestKey, req, &request) {
+		return
+	}
+	// An absent list would make every instance an orphan, so it has to be
+	// given explicitly, even when the platform knows about no instances.
+	if request.PlatformInstanceIDs == nil {
+		logger.Error(invalidSweepRequestKey, platformIDsMissingError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: platformIDsMissingError.Error(),
+		})
+		return
+	}
+
+	remover, canRemove := h.serviceBroker.(domain.OrphanRemover)
+	if request.Delete && !canRemove {
+		logger.Error(removeNotSupportedKey, removeNotSupportedError)
+		h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+			Description: removeNotSupportedError.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	instances, err := listAllInstances(req, lister)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	brokerIDs := make([]string, len(instances))
+	byID := make(map[string]domain.InstanceSummary, len(instances))
+	for i, instance := range instances {
+		brokerIDs[i] = instance.InstanceID
+		byID[instance.InstanceID] = instance
+	}
+
+	response := apiresponses.OrphanSweepResponse{OrphanedInstances: []domain.InstanceSummary{}}
+	for _, id := range domain.OrphanedIDs(request.PlatformInstanceIDs, brokerIDs) {
+		response.OrphanedInstances = append(response.OrphanedInstances, byID[id])
+		if !request.Delete {
+			continue
+		}
+
+		if err := remover.RemoveOrphanedInstance(req.Context(), id); err != nil {
+			logger.Error(removeOrphanFailedKey, err, lager.Data{instanceIDLogKey: id})
+			response.FailedDeletions = append(response.FailedDeletions, apiresponses.FailedDeletion{
+				InstanceID:  id,
+				Description: err.Error(),
+			})
+			continue
+		}
+		response.DeletedInstanceIDs = append(response.DeletedInstanceIDs, id)
+	}
+
+	h.respond(w, http.StatusOK, response)
+}
+
+func listAllInstances(req *http.Request, lister domain.InstanceLister) ([]domain.InstanceSummary, error) {
+	var instances []domain.InstanceSummary
+	request := domain.ListInstancesRequest{Limit: maxListLimit}
+	for {
+		list, err := lister.ListInstances(req.Context(), request)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, list.Instances...)
+		if list.NextCursor == "" || list.NextCursor == request.Cursor {
+			return instances, nil
+		}
+		request.Cursor = list.NextCursor
+	}
+}
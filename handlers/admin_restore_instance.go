@@ -0,0 +1,61 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const restoreNotSupportedKey = "restore-instance-not-supported"
+
+var restoreNotSupportedError = errors.New("the broker does not support restoring deleted service instances")
+
+// RestoreInstance serves POST /admin/service_instances/{instance_id}/restore,
+// bringing back an instance whose deletion the broker is holding back.
+func (h APIHandler) RestoreInstance(w http.ResponseWriter, req *http.Request) {
+	instanceID := mux.Vars(req)["instance_id"]
+	logger := h.session(adminRestoreInstanceLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	restorer, ok := h.serviceBroker.(domain.InstanceRestorer)
+	if !ok {
+		logger.Error(restoreNotSupportedKey, restoreNotSupportedError)
+		h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+			Description: restoreNotSupportedError.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	if err := restorer.RestoreInstance(req.Context(), instanceID); err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	h.respond(w, http.StatusOK, apiresponses.EmptyResponse{})
+}
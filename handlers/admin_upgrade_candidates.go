@@ -0,0 +1,84 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// UpgradeCandidates serves GET /admin/upgrade_candidates, listing a page of
+// the instances whose maintenance_info is behind that of their plan, so that
+// operators can upgrade them with update requests. The service_id and plan_id
+// query parameters narrow the list to one service or plan. Pages are read as
+// for ListInstances.
+func (h APIHandler) UpgradeCandidates(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(adminUpgradeCandidatesLogKey, nil)
+
+	lister, ok := h.serviceBroker.(domain.InstanceLister)
+	if !ok {
+		logger.Error(listNotSupportedKey, listNotSupportedError)
+		h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+			Description: listNotSupportedError.Error(),
+		})
+		return
+	}
+
+	page, err := pageRequest(req)
+	if err != nil {
+		logger.Error(invalidLimitKey, err)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	instances, err := listAllInstances(req, lister)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	serviceID := req.FormValue("service_id")
+	planID := req.FormValue("plan_id")
+	var candidates []domain.UpgradeCandidate
+	for _, candidate := range domain.UpgradeCandidates(services, instances) {
+		if (serviceID == "" || candidate.ServiceID == serviceID) && (planID == "" || candidate.PlanID == planID) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	candidates, nextCursor := domain.Paginate(candidates, page, func(candidate domain.UpgradeCandidate) string {
+		return candidate.InstanceID
+	})
+	setNextPageLink(w, req, nextCursor)
+	h.respond(w, http.StatusOK, apiresponses.UpgradeCandidatesResponse{
+		UpgradeCandidates: candidates,
+		NextCursor:        nextCursor,
+	})
+}
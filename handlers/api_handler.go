@@ -0,0 +1,285 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package handlers implements the HTTP handlers of the Open Service Broker API
+// endpoints, on top of a domain.ServiceBroker.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+const (
+	provisionLogKey              = "provision"
+	deprovisionLogKey            = "deprovision"
+	bindLogKey                   = "bind"
+	getBindLogKey                = "getBinding"
+	getInstanceLogKey            = "getInstance"
+	unbindLogKey                 = "unbind"
+	updateLogKey                 = "update"
+	lastOperationLogKey          = "lastOperation"
+	lastBindingOperationLogKey   = "lastBindingOperation"
+	catalogLogKey                = "catalog"
+	adminListInstancesLogKey     = "adminListInstances"
+	adminSweepOrphansLogKey      = "adminSweepOrphans"
+	adminDebugLogKey             = "adminDebug"
+	adminUpgradeCandidatesLogKey = "adminUpgradeCandidates"
+	adminRestoreInstanceLogKey   = "adminRestoreInstance"
+	adminCatalogDiffLogKey       = "adminCatalogDiff"
+
+	instanceIDLogKey      = "instance-id"
+	instanceDetailsLogKey = "instance-details"
+	bindingIDLogKey       = "binding-id"
+
+	invalidServiceDetailsErrorKey = "invalid-service-details"
+	invalidBindDetailsErrorKey    = "invalid-bind-details"
+	unknownErrorKey               = "unknown-error"
+	apiVersionInvalidKey          = "broker-api-version-invalid"
+	serviceIdMissingKey           = "service-id-missing"
+	planIdMissingKey              = "plan-id-missing"
+	planNotBindableKey            = "plan-not-bindable"
+	planNotRotatableKey           = "plan-not-binding-rotatable"
+	instanceNotRetrievableKey     = "instance-not-retrievable"
+	bindingNotRetrievableKey      = "binding-not-retrievable"
+	invalidServiceID              = "invalid-service-id"
+	invalidPlanID                 = "invalid-plan-id"
+	requestCancelledKey           = "request-cancelled"
+	invalidFieldsKey              = "invalid-fields"
+	openCredentialsFailedKey      = "open-credentials-failed"
+	transformCredentialsFailedKey = "transform-credentials-failed"
+	decodeParametersFailedKey     = "decode-parameters-failed"
+	quotaExceededKey              = "quota-exceeded"
+)
+
+var (
+	serviceIdError        = errors.New("service_id missing")
+	planIdError           = errors.New("plan_id missing")
+	invalidServiceIDError = errors.New("service-id not in the catalog")
+	invalidPlanIDError    = errors.New("plan-id not in the catalog")
+	planNotBindableError  = errors.New("plan is not bindable")
+	planNotRotatableError = errors.New("plan does not support binding rotation")
+
+	instanceNotRetrievableError = errors.New("service does not support fetching instances")
+	bindingNotRetrievableError  = errors.New("service does not support fetching bindings")
+
+	openCredentialsError      = errors.New("the binding credentials could not be decrypted")
+	transformCredentialsError = errors.New("the binding credentials could not be transformed")
+)
+
+// Config holds the optional hooks used by an APIHandler.
+type Config struct {
+	EventSinks    []domain.LifecycleEventSink
+	ErrorReporter domain.ErrorReporter
+
+	// CredentialsOpener, when set, decrypts the credentials of bindings
+	// before they are sent to the platform.
+	CredentialsOpener domain.CredentialsOpener
+
+	// CredentialsTransformers change the credentials of bindings, in order,
+	// after they are opened.
+	CredentialsTransformers []domain.CredentialsTransformer
+
+	// ParameterDecoders convert the parameters of provision, update and bind
+	// requests before they are passed to the broker.
+	ParameterDecoders []ParameterDecoderRule
+
+	// Quotas, when set, is checked before provisioning an instance or
+	// changing its plan, and the request fails with a 422 when the instance
+	// would go over quota.
+	Quotas domain.QuotaChecker
+
+	// StrictResponses rejects broker responses which break the Open
+	// Service Broker API with a 500 instead of passing them on.
+	StrictResponses bool
+
+	// Locks, when set, serializes the requests changing an instance or its
+	// bindings across the replicas of the broker.
+	Locks domain.LockManager
+
+	// MinimumAPIVersion, such as "2.13", rejects requests for older versions
+	// of the Open Service Broker API with a 412. NewAPIHandler panics if it is
+	// not of the form "2.minor".
+	MinimumAPIVersion string
+
+	// LogLevel is the lowest level logged. Requests which log nothing at or
+	// above it do not create a lager session.
+	LogLevel lager.LogLevel
+
+	// LogLevelSink, when set, replaces LogLevel with the current level of the
+	// sink, which the admin debug endpoint changes at runtime.
+	LogLevelSink *lager.ReconfigurableSink
+
+	// DebugToggles are the debugging aids the admin debug endpoint switches
+	// on and off, by name.
+	DebugToggles map[string]domain.DebugToggle
+
+	// RetrieveParameters includes the parameters of instances and bindings
+	// in the responses of the fetch endpoints, for services whose catalog
+	// entry sets InstancesRetrievable or BindingsRetrievable. They are left
+	// out otherwise.
+	RetrieveParameters bool
+
+	// ParametersOmittedServices lists the IDs of services whose parameters
+	// are never returned, such as those passing secrets as parameters.
+	ParametersOmittedServices []string
+
+	// PreviousParameters fetches the parameters of an instance with
+	// GetInstance before it is updated, and passes them to Update in
+	// PreviousValues.Parameters.
+	PreviousParameters bool
+
+	// DynamicPlans accepts provision, update and bind requests for services
+	// and plans which are not in the catalog.
+	DynamicPlans bool
+
+	// Meter receives a usage record when a provision, deprovision or plan
+	// change completes.
+	Meter domain.Meter
+}
+
+// APIHandler serves the Open Service Broker API endpoints. Each exported method
+// handles one endpoint and expects the "instance_id" and "binding_id" gorilla/mux
+// route variables to be set where applicable.
+type APIHandler struct {
+	serviceBroker domain.ServiceBroker
+	logger        lager.Logger
+	events        *eventEmitter
+	errorReporter domain.ErrorReporter
+
+	credentialsOpener       domain.CredentialsOpener
+	credentialsTransformers []domain.CredentialsTransformer
+	parameterDecoders       []ParameterDecoderRule
+	quotas                  domain.QuotaChecker
+	usage                   *usageRecorder
+
+	strictResponses bool
+	locks           domain.LockManager
+	logLevel        lager.LogLevel
+	logLevelSink    *lager.ReconfigurableSink
+	debugToggles    map[string]domain.DebugToggle
+
+	minimumAPIVersion BrokerVersion
+
+	retrieveParameters bool
+	parametersOmitted  map[string]bool
+	previousParameters bool
+	dynamicPlans       bool
+}
+
+func NewAPIHandler(serviceBroker domain.ServiceBroker, logger lager.Logger, config Config) APIHandler {
+	var minimumAPIVersion BrokerVersion
+	if config.MinimumAPIVersion != "" {
+		var ok bool
+		minimumAPIVersion, ok = ParseBrokerVersion(config.MinimumAPIVersion)
+		if !ok || minimumAPIVersion.Major != 2 {
+			panic(fmt.Sprintf("invalid minimum Open Service Broker API version %q", config.MinimumAPIVersion))
+		}
+	}
+
+	parametersOmitted := make(map[string]bool, len(config.ParametersOmittedServices))
+	for _, serviceID := range config.ParametersOmittedServices {
+		parametersOmitted[serviceID] = true
+	}
+
+	return APIHandler{
+		serviceBroker: serviceBroker,
+		logger:        logger,
+		events:        newEventEmitter(config.EventSinks),
+		errorReporter: config.ErrorReporter,
+
+		credentialsOpener:       config.CredentialsOpener,
+		credentialsTransformers: config.CredentialsTransformers,
+		parameterDecoders:       config.ParameterDecoders,
+		quotas:                  config.Quotas,
+		usage:                   newUsageRecorder(config.Meter),
+
+		strictResponses: config.StrictResponses,
+		locks:           config.Locks,
+		logLevel:        config.LogLevel,
+		logLevelSink:    config.LogLevelSink,
+		debugToggles:    config.DebugToggles,
+
+		minimumAPIVersion: minimumAPIVersion,
+
+		retrieveParameters: config.RetrieveParameters,
+		parametersOmitted:  parametersOmitted,
+		previousParameters: config.PreviousParameters,
+		dynamicPlans:       config.DynamicPlans,
+	}
+}
+
+// statusClientClosedRequest is the non-standard status, taken from nginx, that
+// records requests the client gave up on before they were answered.
+const statusClientClosedRequest = 499
+
+// requestCancelled reports whether the platform has given up on the request, in
+// which case there is no point in starting work in the broker on its behalf.
+// The request is answered with a 499 so that access logs and metrics record it
+// as cancelled rather than as a 200 without a body.
+func (h APIHandler) requestCancelled(w http.ResponseWriter, req *http.Request, logger lager.Logger) bool {
+	if req.Context().Err() != context.Canceled {
+		return false
+	}
+	logger.Info(requestCancelledKey)
+	w.WriteHeader(statusClientClosedRequest)
+	return true
+}
+
+// BrokerVersion is an X-Broker-API-Version, such as 2.14.
+type BrokerVersion struct {
+	Major int
+	Minor int
+}
+
+func (v BrokerVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// OlderThan reports whether v precedes other.
+func (v BrokerVersion) OlderThan(other BrokerVersion) bool {
+	return v.Major < other.Major || (v.Major == other.Major && v.Minor < other.Minor)
+}
+
+// ParseBrokerVersion parses a version of the form "major.minor".
+func ParseBrokerVersion(value string) (BrokerVersion, bool) {
+	var version BrokerVersion
+	n, err := fmt.Sscanf(value, "%d.%d", &version.Major, &version.Minor)
+	return version, err == nil && n == 2
+}
+
+func (h APIHandler) checkBrokerAPIVersionHdr(req *http.Request) (BrokerVersion, error) {
+	apiVersion := req.Header.Get("X-Broker-API-Version")
+	if apiVersion == "" {
+		return BrokerVersion{}, errors.New("X-Broker-API-Version Header not set")
+	}
+	version, ok := ParseBrokerVersion(apiVersion)
+	if !ok {
+		return version, errors.New("X-Broker-API-Version Header must contain a version")
+	}
+
+	if version.Major != 2 {
+		return version, errors.New("X-Broker-API-Version Header must be 2.x")
+	}
+	if version.OlderThan(h.minimumAPIVersion) {
+		return version, fmt.Errorf("X-Broker-API-Version %s is not supported, this broker supports versions %s to 2.x", version, h.minimumAPIVersion)
+	}
+	return version, nil
+}
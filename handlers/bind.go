@@ -0,0 +1,208 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) Bind(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+
+	logger := h.session(bindLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		}
+	})
+
+	versionCompatibility, err := h.checkBrokerAPIVersionHdr(req)
+	if err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	var details domain.BindDetails
+	if !h.decodeRequestBody(w, logger, invalidBindDetailsErrorKey, req, &details) {
+		return
+	}
+	req = withRequestPlatform(req, details.RawContext)
+
+	if !h.requireCatalogIDs(w, logger, details.ServiceID, details.PlanID) {
+		return
+	}
+	service, plan, ok := h.checkCatalogIDs(w, req, logger, details.ServiceID, details.PlanID)
+	if !ok {
+		return
+	}
+	if plan != nil && !plan.IsBindable(*service) {
+		logger.Error(planNotBindableKey, planNotBindableError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: planNotBindableError.Error(),
+		})
+		return
+	}
+
+	if plan != nil && details.PredecessorBindingID != "" && !plan.BindingRotatable {
+		logger.Error(planNotRotatableKey, planNotRotatableError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: planNotRotatableError.Error(),
+		})
+		return
+	}
+
+	if service != nil && service.RequiresApp() && details.BoundAppGUID() == "" {
+		err := apiresponses.ErrRequiresApp
+		logger.Error(err.LoggerAction(), err)
+		h.respond(w, err.ValidatedStatusCode(logger), err.ErrorResponse())
+		return
+	}
+
+	if !h.decodeParameters(w, req, logger, details.ServiceID, details.PlanID, &details.RawParameters) {
+		return
+	}
+
+	asyncAllowed := false
+	if versionCompatibility.Minor >= 14 {
+		asyncAllowed = req.FormValue("accepts_incomplete") == "true"
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	if isDryRun(req) {
+		h.dryRunBind(w, req, logger, instanceID, bindingID, details)
+		return
+	}
+
+	release, locked := h.lockInstance(w, req, logger, instanceID)
+	if !locked {
+		return
+	}
+	defer release()
+
+	binding, err := h.serviceBroker.Bind(req.Context(), instanceID, bindingID, details, asyncAllowed)
+	if err != nil {
+		switch err := translateBrokerError(err).(type) {
+		case *apiresponses.FailureResponse:
+			statusCode := err.ValidatedStatusCode(logger)
+			errorResponse := err.ErrorResponse()
+			if err == apiresponses.ErrInstanceDoesNotExist {
+				// work around apiresponses.ErrInstanceDoesNotExist having different pre-refactor behaviour to other actions
+				errorResponse = apiresponses.ErrorResponse{
+					Description: err.Error(),
+				}
+				statusCode = http.StatusNotFound
+			}
+			logger.Error(err.LoggerAction(), err)
+			setRetryAfter(w, err)
+			h.respond(w, statusCode, errorResponse)
+			h.reportError(req, statusCode, err)
+		default:
+			logger.Error(unknownErrorKey, err)
+			h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+				Description: err.Error(),
+			})
+			h.reportError(req, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateBindResponse(service, binding)) {
+		return
+	}
+	if binding.Credentials, err = h.openCredentials(binding.Credentials); err != nil {
+		h.respondWithOpenCredentialsError(w, req, logger, err)
+		return
+	}
+	if binding.Credentials, err = h.transformCredentials(req.Context(), binding.Credentials); err != nil {
+		h.respondWithTransformCredentialsError(w, req, logger, err)
+		return
+	}
+	setHints(w, binding.Hints)
+
+	boundEvent := domain.LifecycleEvent{
+		Type:          domain.EventBindingCreated,
+		InstanceID:    instanceID,
+		BindingID:     bindingID,
+		ServiceID:     details.ServiceID,
+		PlanID:        details.PlanID,
+		IsAsync:       binding.IsAsync,
+		OperationData: binding.OperationData,
+	}
+
+	if binding.IsAsync {
+		h.respond(w, http.StatusAccepted, apiresponses.AsyncBindResponse{
+			OperationData: binding.OperationData,
+		})
+		h.emit(logger, boundEvent)
+		return
+	}
+
+	if versionCompatibility.Minor == 8 || versionCompatibility.Minor == 9 {
+		experimentalVols := []apiresponses.ExperimentalVolumeMount{}
+
+		for _, vol := range binding.VolumeMounts {
+			experimentalConfig, err := json.Marshal(vol.Device.MountConfig)
+			if err != nil {
+				logger.Error(unknownErrorKey, err)
+				h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{Description: err.Error()})
+				h.reportError(req, http.StatusInternalServerError, err)
+				return
+			}
+
+			experimentalVols = append(experimentalVols, apiresponses.ExperimentalVolumeMount{
+				ContainerPath: vol.ContainerDir,
+				Mode:          vol.Mode,
+				Private: apiresponses.ExperimentalVolumeMountPrivate{
+					Driver:  vol.Driver,
+					GroupID: vol.Device.VolumeId,
+					Config:  string(experimentalConfig),
+				},
+			})
+		}
+
+		experimentalBinding := apiresponses.ExperimentalVolumeMountBindingResponse{
+			Credentials:     binding.Credentials,
+			RouteServiceURL: binding.RouteServiceURL,
+			SyslogDrainURL:  binding.SyslogDrainURL,
+			VolumeMounts:    experimentalVols,
+		}
+		h.respond(w, http.StatusCreated, experimentalBinding)
+		h.emit(logger, boundEvent)
+		return
+	}
+
+	h.respond(w, http.StatusCreated, apiresponses.BindingResponse{
+		Credentials:     binding.Credentials,
+		SyslogDrainURL:  binding.SyslogDrainURL,
+		RouteServiceURL: binding.RouteServiceURL,
+		VolumeMounts:    binding.VolumeMounts,
+	})
+	h.emit(logger, boundEvent)
+}
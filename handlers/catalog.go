@@ -0,0 +1,76 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+func (h APIHandler) Catalog(w http.ResponseWriter, req *http.Request) {
+	logger := h.session(catalogLogKey, nil)
+
+	if _, err := h.checkBrokerAPIVersionHdr(req); err != nil {
+		logger.Error("Check failed", err)
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		h.reportError(req, http.StatusInternalServerError, err)
+		return
+	}
+
+	if contextkeys.Anonymous(req.Context()) {
+		services = withoutDashboardSecrets(services)
+	}
+
+	catalog := apiresponses.CatalogResponse{
+		Services: services,
+	}
+
+	h.respondWithETag(w, req, http.StatusOK, catalog)
+}
+
+// withoutDashboardSecrets returns a copy of services whose dashboard clients
+// have no secret, for catalogs served without authentication. The services of
+// the broker are left as they are.
+func withoutDashboardSecrets(services []domain.Service) []domain.Service {
+	public := make([]domain.Service, len(services))
+	for i, service := range services {
+		if service.DashboardClient != nil {
+			client := *service.DashboardClient
+			client.Secret = ""
+			service.DashboardClient = &client
+		}
+		public[i] = service
+	}
+	return public
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// requireCatalogIDs responds with a 400 and returns false unless a request
+// names both a service and a plan.
+func (h APIHandler) requireCatalogIDs(w http.ResponseWriter, logger lager.Logger, serviceID, planID string) bool {
+	if serviceID == "" {
+		h.respondWithInvalidCatalogIDs(w, logger, serviceIdMissingKey, serviceIdError)
+		return false
+	}
+	if planID == "" {
+		h.respondWithInvalidCatalogIDs(w, logger, planIdMissingKey, planIdError)
+		return false
+	}
+	return true
+}
+
+// checkCatalogIDs looks up serviceID in the catalog and, when planID is given,
+// its plan, so that brokers are never called for ones they do not offer. It
+// responds with a 400 and returns false when either is unknown, and with the
+// error of the broker when the catalog cannot be fetched. Brokers with dynamic
+// plans skip the check, so for them the service and plan returned are nil when
+// not in the catalog; the plan is also nil when planID is empty.
+func (h APIHandler) checkCatalogIDs(w http.ResponseWriter, req *http.Request, logger lager.Logger, serviceID, planID string) (*domain.Service, *domain.ServicePlan, bool) {
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return nil, nil, false
+	}
+
+	service, plan := findServicePlan(services, serviceID, planID)
+	if h.dynamicPlans {
+		return service, plan, true
+	}
+	if service == nil {
+		h.respondWithInvalidCatalogIDs(w, logger, invalidServiceID, fmt.Errorf("%w: %q", invalidServiceIDError, serviceID))
+		return nil, nil, false
+	}
+	if planID != "" && plan == nil {
+		h.respondWithInvalidCatalogIDs(w, logger, invalidPlanID,
+			fmt.Errorf("%w: service %q has no plan %q", invalidPlanIDError, serviceID, planID))
+		return nil, nil, false
+	}
+	return service, plan, true
+}
+
+func (h APIHandler) respondWithInvalidCatalogIDs(w http.ResponseWriter, logger lager.Logger, logKey string, err error) {
+	logger.Error(logKey, err)
+	h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+		Description: err.Error(),
+	})
+}
+
+// findServicePlan returns the service with serviceID and its plan with
+// planID, or nil for those not in the catalog.
+func findServicePlan(services []domain.Service, serviceID, planID string) (*domain.Service, *domain.ServicePlan) {
+	for i := range services {
+		service := &services[i]
+		if service.ID != serviceID {
+			continue
+		}
+		for j := range service.Plans {
+			if service.Plans[j].ID == planID {
+				return service, &service.Plans[j]
+			}
+		}
+		return service, nil
+	}
+	return nil, nil
+}
+
+// serviceTags returns the tags of service, which is nil when not in the
+// catalog.
+func serviceTags(service *domain.Service) []string {
+	if service == nil {
+		return nil
+	}
+	return service.Tags
+}
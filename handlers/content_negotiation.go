@@ -0,0 +1,118 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	jsonContentType = "application/json; charset=utf-8"
+
+	notAcceptableKey        = "not-acceptable"
+	unsupportedMediaTypeKey = "unsupported-media-type"
+)
+
+var (
+	notAcceptableError        = errors.New("responses are only available as application/json")
+	unsupportedMediaTypeError = errors.New("request bodies must be application/json encoded as UTF-8")
+)
+
+// NegotiateContent responds with 406 to requests whose Accept header does not
+// allow application/json, and with 415 to request bodies which are declared as
+// something else than UTF-8 encoded JSON. The Content-Type of requests without
+// a body, such as most GET and DELETE requests, is ignored. Media types are compared without
+// regard to case, and parameters other than charset and q are ignored, so
+// that "Application/JSON;charset=UTF-8" is treated like "application/json".
+func (h APIHandler) NegotiateContent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !acceptsJSON(req.Header.Get("Accept")) {
+			h.logger.Error(notAcceptableKey, notAcceptableError, lager.Data{"accept": req.Header.Get("Accept")})
+			h.respond(w, http.StatusNotAcceptable, apiresponses.ErrorResponse{
+				Description: notAcceptableError.Error(),
+			})
+			return
+		}
+		if hasBody(req) && !isJSONContentType(req.Header.Get("Content-Type")) {
+			h.logger.Error(unsupportedMediaTypeKey, unsupportedMediaTypeError, lager.Data{"content-type": req.Header.Get("Content-Type")})
+			h.respond(w, http.StatusUnsupportedMediaType, apiresponses.ErrorResponse{
+				Description: unsupportedMediaTypeError.Error(),
+			})
+			return
+		}
+		next(w, req)
+	}
+}
+
+// acceptsJSON reports whether an Accept header allows application/json. A
+// missing or unparsable header allows anything.
+func acceptsJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	parsed := false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		parsed = true
+		if q, ok := params["q"]; ok {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "application/*", "application/json":
+			return true
+		}
+	}
+	return !parsed
+}
+
+// hasBody reports whether a request carries a body. Requests of unknown length
+// are assumed to have one.
+func hasBody(req *http.Request) bool {
+	return req.ContentLength != 0
+}
+
+// isJSONContentType reports whether a request Content-Type declares JSON,
+// including structured syntax types such as application/merge-patch+json.
+// Requests without a Content-Type are assumed to be JSON.
+func isJSONContentType(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
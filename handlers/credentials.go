@@ -0,0 +1,63 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) openCredentials(credentials interface{}) (interface{}, error) {
+	if h.credentialsOpener == nil || credentials == nil {
+		return credentials, nil
+	}
+	return h.credentialsOpener.OpenCredentials(credentials)
+}
+
+func (h APIHandler) transformCredentials(ctx context.Context, credentials interface{}) (interface{}, error) {
+	if credentials == nil {
+		return nil, nil
+	}
+	for _, transformer := range h.credentialsTransformers {
+		var err error
+		if credentials, err = transformer.TransformCredentials(ctx, credentials); err != nil {
+			return nil, err
+		}
+	}
+	return credentials, nil
+}
+
+// respondWithOpenCredentialsError responds with a 500 whose description does
+// not include err, which may tell more about the encryption than the platform
+// needs to know.
+func (h APIHandler) respondWithOpenCredentialsError(w http.ResponseWriter, req *http.Request, logger lager.Logger, err error) {
+	logger.Error(openCredentialsFailedKey, err)
+	h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+		Description: openCredentialsError.Error(),
+	})
+	h.reportError(req, http.StatusInternalServerError, err)
+}
+
+func (h APIHandler) respondWithTransformCredentialsError(w http.ResponseWriter, req *http.Request, logger lager.Logger, err error) {
+	logger.Error(transformCredentialsFailedKey, err)
+	h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+		Description: transformCredentialsError.Error(),
+	})
+	h.reportError(req, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) Deprovision(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	logger := h.session(deprovisionLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	if _, err := h.checkBrokerAPIVersionHdr(req); err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	details := domain.DeprovisionDetails{
+		PlanID:    req.FormValue("plan_id"),
+		ServiceID: req.FormValue("service_id"),
+	}
+
+	if details.ServiceID == "" {
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: serviceIdError.Error(),
+		})
+		logger.Error(serviceIdMissingKey, serviceIdError)
+		return
+	}
+
+	if details.PlanID == "" {
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: planIdError.Error(),
+		})
+		logger.Error(planIdMissingKey, planIdError)
+		return
+	}
+
+	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	release, locked := h.lockInstance(w, req, logger, instanceID)
+	if !locked {
+		return
+	}
+	defer release()
+
+	deprovisionSpec, err := h.serviceBroker.Deprovision(req.Context(), instanceID, details, asyncAllowed)
+	if err == apiresponses.ErrProvisionInProgress {
+		if canceller, ok := h.serviceBroker.(domain.ProvisionCanceller); ok {
+			logger.Info("cancelling-provision")
+			deprovisionSpec, err = canceller.CancelProvision(req.Context(), instanceID, details, asyncAllowed)
+		}
+	}
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateDeprovisionResponse(deprovisionSpec)) {
+		return
+	}
+	setHints(w, deprovisionSpec.Hints)
+
+	if deprovisionSpec.IsAsync {
+		h.respond(w, http.StatusAccepted, apiresponses.DeprovisionResponse{OperationData: deprovisionSpec.OperationData})
+	} else {
+		h.respond(w, http.StatusOK, apiresponses.EmptyResponse{})
+	}
+
+	h.emit(logger, domain.LifecycleEvent{
+		Type:          domain.EventInstanceDeprovisioned,
+		InstanceID:    instanceID,
+		ServiceID:     details.ServiceID,
+		PlanID:        details.PlanID,
+		IsAsync:       deprovisionSpec.IsAsync,
+		OperationData: deprovisionSpec.OperationData,
+	})
+	h.recordUsage(logger, deprovisionSpec.IsAsync, deprovisionSpec.OperationData, domain.UsageRecord{
+		Type:       domain.UsageStopped,
+		InstanceID: instanceID,
+		ServiceID:  details.ServiceID,
+		PlanID:     details.PlanID,
+	})
+}
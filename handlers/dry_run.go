@@ -0,0 +1,100 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	dryRunQueryKey = "dry_run"
+
+	dryRunNotSupportedKey = "dry-run-not-supported"
+)
+
+var dryRunNotSupportedError = errors.New("the broker does not support dry runs of this request")
+
+// isDryRun reports whether the request only asks what it would do, with the
+// dry_run=true extension query parameter.
+func isDryRun(req *http.Request) bool {
+	return req.URL.Query().Get(dryRunQueryKey) == "true"
+}
+
+func (h APIHandler) dryRunProvision(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID string, details domain.ProvisionDetails) {
+	validator, ok := h.serviceBroker.(domain.ProvisionValidator)
+	if !ok {
+		h.respondDryRunNotSupported(w, logger)
+		return
+	}
+	if !h.checkQuota(w, req, logger, instanceID, details.ServiceID, details.PlanID) {
+		return
+	}
+	result, err := validator.ValidateProvision(req.Context(), instanceID, details)
+	h.respondWithDryRun(w, req, logger, result, err)
+}
+
+func (h APIHandler) dryRunUpdate(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID string, details domain.UpdateDetails) {
+	validator, ok := h.serviceBroker.(domain.UpdateValidator)
+	if !ok {
+		h.respondDryRunNotSupported(w, logger)
+		return
+	}
+	if !h.fetchPreviousParameters(w, req, logger, instanceID, &details) {
+		return
+	}
+	if details.PlanID != "" && details.PlanID != details.PreviousValues.PlanID {
+		if !h.checkQuota(w, req, logger, instanceID, details.ServiceID, details.PlanID) {
+			return
+		}
+	}
+	result, err := validator.ValidateUpdate(req.Context(), instanceID, details)
+	h.respondWithDryRun(w, req, logger, result, err)
+}
+
+func (h APIHandler) dryRunBind(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID, bindingID string, details domain.BindDetails) {
+	validator, ok := h.serviceBroker.(domain.BindValidator)
+	if !ok {
+		h.respondDryRunNotSupported(w, logger)
+		return
+	}
+	result, err := validator.ValidateBind(req.Context(), instanceID, bindingID, details)
+	h.respondWithDryRun(w, req, logger, result, err)
+}
+
+func (h APIHandler) respondDryRunNotSupported(w http.ResponseWriter, logger lager.Logger) {
+	logger.Error(dryRunNotSupportedKey, dryRunNotSupportedError)
+	h.respond(w, http.StatusNotImplemented, apiresponses.ErrorResponse{
+		Description: dryRunNotSupportedError.Error(),
+	})
+}
+
+// respondWithDryRun responds with 200 when the request would have succeeded,
+// and otherwise with the error the broker would have failed it with.
+func (h APIHandler) respondWithDryRun(w http.ResponseWriter, req *http.Request, logger lager.Logger, result domain.DryRunResult, err error) {
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+	h.respond(w, http.StatusOK, apiresponses.DryRunResponse{
+		DryRun:      true,
+		Description: result.Description,
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+const requestIdentityHeader = "X-Broker-API-Request-Identity"
+
+func (h APIHandler) reportError(req *http.Request, statusCode int, err error) {
+	if h.errorReporter == nil || statusCode < http.StatusInternalServerError {
+		return
+	}
+
+	vars := mux.Vars(req)
+	report := domain.ErrorReport{
+		StatusCode:      statusCode,
+		Method:          req.Method,
+		Path:            contextkeys.ExternalPath(req),
+		InstanceID:      vars["instance_id"],
+		BindingID:       vars["binding_id"],
+		APIVersion:      req.Header.Get("X-Broker-API-Version"),
+		RequestIdentity: req.Header.Get(requestIdentityHeader),
+		Err:             err,
+	}
+	if route := mux.CurrentRoute(req); route != nil {
+		report.Operation = domain.Operation(route.GetName())
+	}
+
+	h.errorReporter.ReportError(req.Context(), report)
+}
+
+// translateBrokerError returns the failure response err wraps, such as
+// apiresponses.ErrServiceUnavailable or the failure of another broker called
+// with the client package, and apiresponses.ErrOperationTimedOut for other
+// errors caused by a deadline.
+func translateBrokerError(err error) error {
+	var failure *apiresponses.FailureResponse
+	if errors.As(err, &failure) {
+		return failure
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apiresponses.ErrOperationTimedOut
+	}
+	return err
+}
+
+// setRetryAfter sets the Retry-After header asked for by a failure response.
+func setRetryAfter(w http.ResponseWriter, failure *apiresponses.FailureResponse) {
+	if retryAfter := failure.RetryAfter(); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+}
+
+// respondWithBrokerError responds with the status and body of a
+// *apiresponses.FailureResponse, with a 504 when the broker timed out, or with
+// a 500 for any other error returned by the broker.
+func (h APIHandler) respondWithBrokerError(w http.ResponseWriter, req *http.Request, logger lager.Logger, err error) {
+	switch err := translateBrokerError(err).(type) {
+	case *apiresponses.FailureResponse:
+		logger.Error(err.LoggerAction(), err)
+		statusCode := err.ValidatedStatusCode(logger)
+		setRetryAfter(w, err)
+		h.respond(w, statusCode, err.ErrorResponse())
+		h.reportError(req, statusCode, err)
+	default:
+		logger.Error(unknownErrorKey, err)
+		h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		h.reportError(req, http.StatusInternalServerError, err)
+	}
+}
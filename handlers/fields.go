@@ -0,0 +1,90 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const fieldsQueryKey = "fields"
+
+// The top-level fields of the fetch responses which can be selected with the
+// fields query parameter.
+var (
+	instanceFields = []string{"service_id", "plan_id", "dashboard_url", "parameters", "tags"}
+	bindingFields  = []string{"credentials", "syslog_drain_url", "route_service_url", "volume_mounts", "parameters"}
+)
+
+// requestedFields returns the fields listed, separated by commas, in the
+// fields query parameter of req. It returns nil when the parameter is not
+// given, meaning that the whole response is wanted.
+func requestedFields(req *http.Request, known []string) ([]string, error) {
+	value := req.URL.Query().Get(fieldsQueryKey)
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !containsField(known, field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(known, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFields returns response with only the given top-level fields, or the
+// whole response if fields is nil. Fields which the response omits stay
+// omitted.
+func selectFields(response interface{}, fields []string) (interface{}, error) {
+	if fields == nil {
+		return response, nil
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
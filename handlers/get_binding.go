@@ -0,0 +1,118 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) GetBinding(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+
+	logger := h.session(getBindLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		}
+	})
+
+	versionCompatibility, err := h.checkBrokerAPIVersionHdr(req)
+	if err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+	if versionCompatibility.Minor < 14 {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: "get binding endpoint only supported starting with OSB version 2.14",
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	fields, err := requestedFields(req, bindingFields)
+	if err != nil {
+		logger.Error(invalidFieldsKey, err)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+	if !retrievalAdvertised(services, req.FormValue("service_id"), bindingsRetrievable) {
+		logger.Error(bindingNotRetrievableKey, bindingNotRetrievableError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: bindingNotRetrievableError.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	binding, err := h.serviceBroker.GetBinding(req.Context(), instanceID, bindingID)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateGetBindingResponse(binding)) {
+		return
+	}
+	if binding.Credentials, err = h.openCredentials(binding.Credentials); err != nil {
+		h.respondWithOpenCredentialsError(w, req, logger, err)
+		return
+	}
+	if binding.Credentials, err = h.transformCredentials(req.Context(), binding.Credentials); err != nil {
+		h.respondWithTransformCredentialsError(w, req, logger, err)
+		return
+	}
+
+	parameters := binding.Parameters
+	if !h.parametersRetrievable(services, req.FormValue("service_id"), bindingsRetrievable) {
+		parameters = nil
+	}
+
+	response, err := selectFields(apiresponses.GetBindingResponse{
+		BindingResponse: apiresponses.BindingResponse{
+			Credentials:     binding.Credentials,
+			SyslogDrainURL:  binding.SyslogDrainURL,
+			RouteServiceURL: binding.RouteServiceURL,
+			VolumeMounts:    binding.VolumeMounts,
+		},
+		Parameters: parameters,
+	}, fields)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	h.respond(w, http.StatusOK, response)
+}
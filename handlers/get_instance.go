@@ -0,0 +1,113 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) GetInstance(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+
+	logger := h.session(getInstanceLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	versionCompatibility, err := h.checkBrokerAPIVersionHdr(req)
+	if err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+	if versionCompatibility.Minor < 14 {
+		err = errors.New("get instance endpoint only supported starting with OSB version 2.14")
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	fields, err := requestedFields(req, instanceFields)
+	if err != nil {
+		logger.Error(invalidFieldsKey, err)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+	if !retrievalAdvertised(services, req.FormValue("service_id"), instancesRetrievable) {
+		logger.Error(instanceNotRetrievableKey, instanceNotRetrievableError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: instanceNotRetrievableError.Error(),
+		})
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	instanceDetails, err := h.serviceBroker.GetInstance(req.Context(), instanceID)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateGetInstanceResponse(instanceDetails)) {
+		return
+	}
+
+	serviceID := req.FormValue("service_id")
+	if serviceID == "" {
+		serviceID = instanceDetails.ServiceID
+	}
+	parameters := instanceDetails.Parameters
+	if !h.parametersRetrievable(services, serviceID, instancesRetrievable) {
+		parameters = nil
+	}
+
+	response, err := selectFields(apiresponses.GetInstanceResponse{
+		ServiceID:    instanceDetails.ServiceID,
+		PlanID:       instanceDetails.PlanID,
+		DashboardURL: instanceDetails.DashboardURL,
+		Parameters:   parameters,
+		Tags:         domain.MergeTags(domain.ServiceTags(services, instanceDetails.ServiceID), instanceDetails.Tags),
+	}, fields)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	h.respond(w, http.StatusOK, response)
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// setHints sets the response headers of the hints the broker returned.
+func setHints(w http.ResponseWriter, hints domain.Hints) {
+	setIntHeader(w, domain.QuotaLimitHeader, hints.QuotaLimit)
+	setIntHeader(w, domain.QuotaRemainingHeader, hints.QuotaRemaining)
+	setIntHeader(w, domain.RateLimitRemainingHeader, hints.RateLimitRemaining)
+}
+
+func setIntHeader(w http.ResponseWriter, name string, value *int) {
+	if value != nil {
+		w.Header().Set(name, strconv.Itoa(*value))
+	}
+}
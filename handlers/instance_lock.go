@@ -0,0 +1,47 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const instanceLockedKey = "instance-locked"
+
+// lockInstance takes the lock of an instance for the duration of a request
+// changing it or its bindings. When another request holds the lock the
+// platform gets a ConcurrencyError, and ok is false.
+func (h APIHandler) lockInstance(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID string) (release func(), ok bool) {
+	if h.locks == nil {
+		return func() {}, true
+	}
+
+	release, err := h.locks.TryLock(req.Context(), instanceID)
+	switch err {
+	case nil:
+		return release, true
+	case domain.ErrLockHeld:
+		logger.Info(instanceLockedKey)
+		h.respondWithBrokerError(w, req, logger, apiresponses.ErrConcurrentOperation)
+	default:
+		h.respondWithBrokerError(w, req, logger, err)
+	}
+	return nil, false
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) LastBindingOperation(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+	pollDetails := domain.PollDetails{
+		PlanID:        req.FormValue("plan_id"),
+		ServiceID:     req.FormValue("service_id"),
+		OperationData: req.FormValue("operation"),
+	}
+
+	logger := h.session(lastBindingOperationLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	versionCompatibility, err := h.checkBrokerAPIVersionHdr(req)
+	if err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+	if versionCompatibility.Minor < 14 {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: "get binding endpoint only supported starting with OSB version 2.14",
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	logger.Info("starting-check-for-binding-operation")
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	lastOperation, err := h.serviceBroker.LastBindingOperation(req.Context(), instanceID, bindingID, pollDetails)
+
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateLastOperationResponse(lastOperation)) {
+		return
+	}
+
+	if h.logs(lager.INFO) {
+		logger.Info("done-check-for-binding-operation", lager.Data{"state": lastOperation.State})
+	}
+
+	// instance_usable and update_repeatable describe instance operations
+	// only, so they are not passed on for bindings.
+	lastOperationResponse := apiresponses.LastOperationResponse{
+		State:       lastOperation.State,
+		Description: lastOperation.FullDescription(),
+	}
+	h.respond(w, http.StatusOK, lastOperationResponse)
+
+	if eventType, finished := operationEventType(lastOperation.State); finished {
+		h.emit(logger, domain.LifecycleEvent{
+			Type:          eventType,
+			InstanceID:    instanceID,
+			BindingID:     bindingID,
+			ServiceID:     pollDetails.ServiceID,
+			PlanID:        pollDetails.PlanID,
+			IsAsync:       true,
+			OperationData: pollDetails.OperationData,
+			Description:   lastOperation.Description,
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) LastOperation(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	pollDetails := domain.PollDetails{
+		PlanID:        req.FormValue("plan_id"),
+		ServiceID:     req.FormValue("service_id"),
+		OperationData: req.FormValue("operation"),
+	}
+
+	logger := h.session(lastOperationLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	if _, err := h.checkBrokerAPIVersionHdr(req); err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	logger.Info("starting-check-for-operation")
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	lastOperation, err := h.serviceBroker.LastOperation(req.Context(), instanceID, pollDetails)
+
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		h.finishUsage(logger, instanceID, pollDetails.OperationData, "", err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateLastOperationResponse(lastOperation)) {
+		return
+	}
+
+	if h.logs(lager.INFO) {
+		logger.Info("done-check-for-operation", lager.Data{"state": lastOperation.State})
+	}
+
+	lastOperationResponse := apiresponses.LastOperationResponse{
+		State:            lastOperation.State,
+		Description:      lastOperation.FullDescription(),
+		InstanceUsable:   lastOperation.InstanceUsable,
+		UpdateRepeatable: lastOperation.UpdateRepeatable,
+	}
+
+	h.respond(w, http.StatusOK, lastOperationResponse)
+	h.finishUsage(logger, instanceID, pollDetails.OperationData, lastOperation.State, nil)
+
+	if eventType, finished := operationEventType(lastOperation.State); finished {
+		h.emit(logger, domain.LifecycleEvent{
+			Type:          eventType,
+			InstanceID:    instanceID,
+			ServiceID:     pollDetails.ServiceID,
+			PlanID:        pollDetails.PlanID,
+			IsAsync:       true,
+			OperationData: pollDetails.OperationData,
+			Description:   lastOperation.Description,
+		})
+	}
+}
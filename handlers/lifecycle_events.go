@@ -0,0 +1,129 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+const (
+	emitLifecycleEventErrorKey = "emit-lifecycle-event-failed"
+
+	// maxFinishedOperations is how many finished operations an eventEmitter
+	// remembers, so that polls repeated after an operation has finished do
+	// not emit its event again.
+	maxFinishedOperations = 10000
+)
+
+// eventEmitter passes lifecycle events to each sink through a worker of its
+// own, so that a slow sink only delays its own events, which it receives in
+// order.
+type eventEmitter struct {
+	sinks []eventSink
+
+	mutex    sync.Mutex
+	finished map[operationKey]bool
+	order    []operationKey
+}
+
+type eventSink struct {
+	sink   domain.LifecycleEventSink
+	worker *worker
+}
+
+func newEventEmitter(sinks []domain.LifecycleEventSink) *eventEmitter {
+	if len(sinks) == 0 {
+		return nil
+	}
+	e := &eventEmitter{finished: map[operationKey]bool{}}
+	for _, sink := range sinks {
+		e.sinks = append(e.sinks, eventSink{sink: sink, worker: newWorker()})
+	}
+	return e
+}
+
+// firstFinish records that the operation has finished, returning false if it
+// had already.
+func (e *eventEmitter) firstFinish(key operationKey) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.finished[key] {
+		return false
+	}
+	if len(e.order) >= maxFinishedOperations {
+		delete(e.finished, e.order[0])
+		e.order = e.order[1:]
+	}
+	e.finished[key] = true
+	e.order = append(e.order, key)
+	return true
+}
+
+// start forgets that an operation with key finished, as a new one accepted
+// with the same operation data, as brokers returning none do, will finish
+// again.
+func (e *eventEmitter) start(key operationKey) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.finished, key)
+}
+
+func (h APIHandler) emit(logger lager.Logger, event domain.LifecycleEvent) {
+	if h.events == nil {
+		return
+	}
+
+	key := operationKey{instanceID: event.InstanceID, bindingID: event.BindingID, operationData: event.OperationData}
+	switch event.Type {
+	case domain.EventOperationSucceeded, domain.EventOperationFailed:
+		if !h.events.firstFinish(key) {
+			return
+		}
+	default:
+		if event.IsAsync {
+			h.events.start(key)
+		}
+	}
+
+	event.Time = time.Now().UTC()
+	for _, s := range h.events.sinks {
+		sink := s.sink
+		s.worker.do(func(ctx context.Context) {
+			if err := sink.Emit(ctx, event); err != nil {
+				logger.Error(emitLifecycleEventErrorKey, err, lager.Data{"event-type": event.Type})
+			}
+		})
+	}
+}
+
+// operationEventType returns the event to emit for a polled operation state,
+// or false while the operation is still in progress.
+func operationEventType(state domain.LastOperationState) (domain.LifecycleEventType, bool) {
+	switch state {
+	case domain.Succeeded:
+		return domain.EventOperationSucceeded, true
+	case domain.Failed:
+		return domain.EventOperationFailed, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"code.cloudfoundry.org/lager"
+)
+
+// session returns a logger for one request. The lager session, and the data
+// returned by data, are only created once something is logged at or above the
+// handler's log level, so that requests which log nothing allocate nothing
+// for it. data may be nil.
+func (h APIHandler) session(task string, data func() lager.Data) lager.Logger {
+	return &lazyLogger{parent: h.logger, task: task, data: data, minLevel: h.minLogLevel()}
+}
+
+// minLogLevel returns the lowest level logged, which follows the log level
+// sink when there is one.
+func (h APIHandler) minLogLevel() lager.LogLevel {
+	if h.logLevelSink != nil {
+		return h.logLevelSink.GetMinLevel()
+	}
+	return h.logLevel
+}
+
+// logs reports whether lines at level are logged, for callers which want to
+// avoid building their lager.Data otherwise.
+func (h APIHandler) logs(level lager.LogLevel) bool {
+	return level >= h.minLogLevel()
+}
+
+type lazyLogger struct {
+	parent   lager.Logger
+	task     string
+	data     func() lager.Data
+	minLevel lager.LogLevel
+
+	logger lager.Logger
+}
+
+func (l *lazyLogger) session() lager.Logger {
+	if l.logger == nil {
+		if l.data == nil {
+			l.logger = l.parent.Session(l.task)
+		} else {
+			l.logger = l.parent.Session(l.task, l.data())
+		}
+	}
+	return l.logger
+}
+
+func (l *lazyLogger) RegisterSink(sink lager.Sink) {
+	l.session().RegisterSink(sink)
+}
+
+func (l *lazyLogger) Session(task string, data ...lager.Data) lager.Logger {
+	return l.session().Session(task, data...)
+}
+
+func (l *lazyLogger) SessionName() string {
+	if l.logger == nil {
+		return l.parent.SessionName() + "." + l.task
+	}
+	return l.logger.SessionName()
+}
+
+func (l *lazyLogger) Debug(action string, data ...lager.Data) {
+	if l.minLevel <= lager.DEBUG {
+		l.session().Debug(action, data...)
+	}
+}
+
+func (l *lazyLogger) Info(action string, data ...lager.Data) {
+	if l.minLevel <= lager.INFO {
+		l.session().Info(action, data...)
+	}
+}
+
+func (l *lazyLogger) Error(action string, err error, data ...lager.Data) {
+	if l.minLevel <= lager.ERROR {
+		l.session().Error(action, err, data...)
+	}
+}
+
+func (l *lazyLogger) Fatal(action string, err error, data ...lager.Data) {
+	l.session().Fatal(action, err, data...)
+}
+
+// WithData keeps the returned logger lazy unless the session already exists.
+func (l *lazyLogger) WithData(data lager.Data) lager.Logger {
+	if l.logger != nil {
+		return l.logger.WithData(data)
+	}
+	base := l.data
+	return &lazyLogger{
+		parent: l.parent,
+		task:   l.task,
+		data: func() lager.Data {
+			merged := lager.Data{}
+			if base != nil {
+				for key, value := range base() {
+					merged[key] = value
+				}
+			}
+			for key, value := range data {
+				merged[key] = value
+			}
+			return merged
+		},
+		minLevel: l.minLevel,
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	recordUsageErrorKey = "record-usage-failed"
+
+	// pendingUsageRetention is how long the records of an asynchronous
+	// operation are kept for a poll to report how it ended. Platforms give up
+	// polling sooner: Cloud Foundry stops after a week by default.
+	pendingUsageRetention = 7 * 24 * time.Hour
+)
+
+// usageRecorder passes usage records to a meter through a worker, so that
+// they reach it in the order they were made. The records of an
+// asynchronous operation are held back until a last_operation poll reports
+// that it succeeded, and dropped if it failed.
+type usageRecorder struct {
+	meter  domain.Meter
+	worker *worker
+
+	mutex   sync.Mutex
+	pending map[operationKey]pendingUsage
+}
+
+// operationKey identifies an asynchronous operation by its instance, its
+// binding for binding operations, and the operation data returned to the
+// platform, which it sends back when polling.
+type operationKey struct {
+	instanceID    string
+	bindingID     string
+	operationData string
+}
+
+type pendingUsage struct {
+	records    []domain.UsageRecord
+	acceptedAt time.Time
+}
+
+func newUsageRecorder(meter domain.Meter) *usageRecorder {
+	if meter == nil {
+		return nil
+	}
+	return &usageRecorder{
+		meter:   meter,
+		worker:  newWorker(),
+		pending: map[operationKey]pendingUsage{},
+	}
+}
+
+func (u *usageRecorder) enqueue(logger lager.Logger, records []domain.UsageRecord) {
+	now := time.Now().UTC()
+	for _, record := range records {
+		record := record
+		record.Time = now
+		u.worker.do(func(ctx context.Context) {
+			if err := u.meter.RecordUsage(ctx, record); err != nil {
+				logger.Error(recordUsageErrorKey, err, lager.Data{"instance-id": record.InstanceID, "usage-type": record.Type})
+			}
+		})
+	}
+}
+
+// recordUsage passes records to the meter, in order, stamped with the current
+// time. The records of an asynchronous operation wait for finishUsage instead.
+func (h APIHandler) recordUsage(logger lager.Logger, async bool, operationData string, records ...domain.UsageRecord) {
+	if h.usage == nil || len(records) == 0 {
+		return
+	}
+	if !async {
+		h.usage.enqueue(logger, records)
+		return
+	}
+
+	u := h.usage
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	now := time.Now()
+	for key, pending := range u.pending {
+		if now.Sub(pending.acceptedAt) > pendingUsageRetention {
+			delete(u.pending, key)
+		}
+	}
+	u.pending[operationKey{instanceID: records[0].InstanceID, operationData: operationData}] = pendingUsage{records: records, acceptedAt: now}
+}
+
+// finishUsage records the usage held back for an asynchronous operation once
+// a poll of its last operation reports that it succeeded, or forgets it once
+// it failed. A poll answered with apiresponses.ErrInstanceDoesNotExist means a
+// deprovision succeeded.
+func (h APIHandler) finishUsage(logger lager.Logger, instanceID, operationData string, state domain.LastOperationState, pollErr error) {
+	if h.usage == nil {
+		return
+	}
+	gone := errors.Is(pollErr, apiresponses.ErrInstanceDoesNotExist)
+	if pollErr != nil && !gone {
+		return
+	}
+	if pollErr == nil && state != domain.Succeeded && state != domain.Failed {
+		return
+	}
+
+	u := h.usage
+	key := operationKey{instanceID: instanceID, operationData: operationData}
+	u.mutex.Lock()
+	pending, ok := u.pending[key]
+	if ok && gone && !stopsUsage(pending.records) {
+		ok = false
+	}
+	if ok {
+		delete(u.pending, key)
+	}
+	u.mutex.Unlock()
+
+	if ok && (gone || state == domain.Succeeded) {
+		u.enqueue(logger, pending.records)
+	}
+}
+
+func stopsUsage(records []domain.UsageRecord) bool {
+	for _, record := range records {
+		if record.Type != domain.UsageStopped {
+			return false
+		}
+	}
+	return true
+}
+
+// updateUsage returns the records of an update, which only changes the use
+// of a plan when the plan changes.
+func updateUsage(instanceID string, details domain.UpdateDetails) []domain.UsageRecord {
+	previous := details.PreviousValues
+	if details.PlanID == "" || previous.PlanID == "" || details.PlanID == previous.PlanID {
+		return nil
+	}
+
+	serviceID := details.ServiceID
+	if serviceID == "" {
+		serviceID = previous.ServiceID
+	}
+	return []domain.UsageRecord{
+		{Type: domain.UsageStopped, InstanceID: instanceID, ServiceID: serviceID, PlanID: previous.PlanID, OrganizationGUID: previous.OrgID, SpaceGUID: previous.SpaceID},
+		{Type: domain.UsageStarted, InstanceID: instanceID, ServiceID: serviceID, PlanID: details.PlanID, OrganizationGUID: previous.OrgID, SpaceGUID: previous.SpaceID},
+	}
+}
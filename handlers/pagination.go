@@ -0,0 +1,67 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+const (
+	limitQueryKey  = "limit"
+	cursorQueryKey = "cursor"
+
+	invalidLimitKey = "invalid-limit"
+
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+var invalidLimitError = errors.New("limit must be a positive integer")
+
+// pageRequest reads the limit and cursor query parameters of a list extension
+// endpoint. The limit defaults to 100 and is capped at 1000.
+func pageRequest(req *http.Request) (domain.PageRequest, error) {
+	limit := defaultListLimit
+	if value := req.FormValue(limitQueryKey); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return domain.PageRequest{}, invalidLimitError
+		}
+		limit = parsed
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	return domain.PageRequest{Limit: limit, Cursor: req.FormValue(cursorQueryKey)}, nil
+}
+
+// setNextPageLink sets a Link header with rel="next", as described in RFC 8288,
+// pointing at the page after the current one. Nothing is set on the last page.
+func setNextPageLink(w http.ResponseWriter, req *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	query := req.URL.Query()
+	query.Set(cursorQueryKey, nextCursor)
+	prefix, _ := contextkeys.PathPrefix(req.Context())
+	w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, prefix, req.URL.EscapedPath(), query.Encode()))
+}
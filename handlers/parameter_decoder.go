@@ -0,0 +1,67 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// ParameterDecoderRule applies Decoder to the parameters of requests for a
+// service and plan. An empty ServiceID or PlanID matches every service or
+// plan.
+type ParameterDecoderRule struct {
+	ServiceID string
+	PlanID    string
+	Decoder   domain.ParameterDecoder
+}
+
+func (r ParameterDecoderRule) matches(serviceID, planID string) bool {
+	return (r.ServiceID == "" || r.ServiceID == serviceID) && (r.PlanID == "" || r.PlanID == planID)
+}
+
+// decodeParameters passes raw through the decoders of serviceID and planID in
+// the order they were registered. It responds and returns false when one of
+// them fails; failure responses are sent as they are, and other errors get a
+// 422.
+func (h APIHandler) decodeParameters(w http.ResponseWriter, req *http.Request, logger lager.Logger, serviceID, planID string, raw *json.RawMessage) bool {
+	if len(*raw) == 0 {
+		return true
+	}
+
+	for _, rule := range h.parameterDecoders {
+		if !rule.matches(serviceID, planID) {
+			continue
+		}
+		decoded, err := rule.Decoder.DecodeParameters(req.Context(), *raw)
+		if err != nil {
+			if _, ok := err.(*apiresponses.FailureResponse); !ok {
+				err = apiresponses.NewFailureResponse(
+					fmt.Errorf("the parameters could not be decoded: %s", err), http.StatusUnprocessableEntity, decodeParametersFailedKey,
+				)
+			}
+			h.respondWithBrokerError(w, req, logger, err)
+			return false
+		}
+		*raw = decoded
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+// withRequestPlatform stores the platform of the context object of the
+// request body, which is more reliable than the headers contextkeys reads.
+func withRequestPlatform(req *http.Request, rawContext json.RawMessage) *http.Request {
+	platformContext, err := domain.ParseContext(rawContext)
+	if err != nil || platformContext.Platform == "" {
+		return req
+	}
+	return req.WithContext(contextkeys.WithPlatform(req.Context(), platformContext.Platform))
+}
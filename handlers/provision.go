@@ -0,0 +1,128 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) Provision(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+
+	logger := h.session(provisionLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	if _, err := h.checkBrokerAPIVersionHdr(req); err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	var details domain.ProvisionDetails
+	if !h.decodeRequestBody(w, logger, invalidServiceDetailsErrorKey, req, &details) {
+		return
+	}
+	req = withRequestPlatform(req, details.RawContext)
+
+	if !h.requireCatalogIDs(w, logger, details.ServiceID, details.PlanID) {
+		return
+	}
+	service, _, ok := h.checkCatalogIDs(w, req, logger, details.ServiceID, details.PlanID)
+	if !ok {
+		return
+	}
+
+	if !h.decodeParameters(w, req, logger, details.ServiceID, details.PlanID, &details.RawParameters) {
+		return
+	}
+
+	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
+
+	logger = logger.WithData(lager.Data{
+		instanceDetailsLogKey: details,
+	})
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	if isDryRun(req) {
+		h.dryRunProvision(w, req, logger, instanceID, details)
+		return
+	}
+
+	release, locked := h.lockInstance(w, req, logger, instanceID)
+	if !locked {
+		return
+	}
+	defer release()
+
+	if !h.checkQuota(w, req, logger, instanceID, details.ServiceID, details.PlanID) {
+		return
+	}
+
+	provisionResponse, err := h.serviceBroker.Provision(req.Context(), instanceID, details, asyncAllowed)
+
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateProvisionResponse(provisionResponse)) {
+		return
+	}
+	setHints(w, provisionResponse.Hints)
+
+	if provisionResponse.IsAsync {
+		h.respond(w, http.StatusAccepted, apiresponses.ProvisioningResponse{
+			DashboardURL:  provisionResponse.DashboardURL,
+			OperationData: provisionResponse.OperationData,
+		})
+	} else {
+		h.respond(w, http.StatusCreated, apiresponses.ProvisioningResponse{
+			DashboardURL: provisionResponse.DashboardURL,
+		})
+	}
+
+	h.emit(logger, domain.LifecycleEvent{
+		Type:          domain.EventInstanceProvisioned,
+		InstanceID:    instanceID,
+		ServiceID:     details.ServiceID,
+		PlanID:        details.PlanID,
+		IsAsync:       provisionResponse.IsAsync,
+		OperationData: provisionResponse.OperationData,
+		Tags:          domain.MergeTags(serviceTags(service), details.Tags()),
+	})
+	h.recordUsage(logger, provisionResponse.IsAsync, provisionResponse.OperationData, domain.UsageRecord{
+		Type:             domain.UsageStarted,
+		InstanceID:       instanceID,
+		ServiceID:        details.ServiceID,
+		PlanID:           details.PlanID,
+		OrganizationGUID: details.OrganizationGUID,
+		SpaceGUID:        details.SpaceGUID,
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// checkQuota responds and returns false when one more instance of planID is
+// over quota. Quota errors get a 422, and any other error, such as a failing
+// store, is handled like an error returned by the broker.
+func (h APIHandler) checkQuota(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID, serviceID, planID string) bool {
+	if h.quotas == nil {
+		return true
+	}
+
+	err := h.quotas.CheckQuota(req.Context(), instanceID, serviceID, planID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, apiresponses.ErrPlanQuotaExceeded) || errors.Is(err, apiresponses.ErrServiceQuotaExceeded) {
+		err = apiresponses.NewFailureResponseBuilder(err, http.StatusUnprocessableEntity, quotaExceededKey).
+			WithErrorKey("QuotaExceeded").Build()
+	}
+	h.respondWithBrokerError(w, req, logger, err)
+	return false
+}
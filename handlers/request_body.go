@@ -0,0 +1,84 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	malformedJSONError  = "MalformedJSON"
+	invalidDetailsError = "InvalidDetails"
+)
+
+// decodeRequestBody decodes the JSON body of req into v. It responds and
+// returns false when that fails: a body which is not JSON at all gets a 400
+// naming the offset of the syntax error, while an empty body or JSON which
+// does not fit v gets a 422, naming the offending field where it is known.
+func (h APIHandler) decodeRequestBody(w http.ResponseWriter, logger lager.Logger, logKey string, req *http.Request, v interface{}) bool {
+	err := json.NewDecoder(req.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	logger.Error(logKey, err)
+
+	status, response := invalidRequestBodyResponse(err)
+	h.respond(w, status, response)
+	return false
+}
+
+func invalidRequestBodyResponse(err error) (int, apiresponses.InvalidRequestBodyResponse) {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return http.StatusBadRequest, apiresponses.InvalidRequestBodyResponse{
+			Error:       malformedJSONError,
+			Description: fmt.Sprintf("the request body is not valid JSON: %s at offset %d", syntaxError, syntaxError.Offset),
+			Offset:      syntaxError.Offset,
+		}
+	case errors.Is(err, io.EOF):
+		return http.StatusUnprocessableEntity, apiresponses.InvalidRequestBodyResponse{
+			Error:       invalidDetailsError,
+			Description: "the request body is empty",
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return http.StatusBadRequest, apiresponses.InvalidRequestBodyResponse{
+			Error:       malformedJSONError,
+			Description: "the request body is not valid JSON: unexpected end of input",
+		}
+	case errors.As(err, &typeError) && typeError.Field != "":
+		return http.StatusUnprocessableEntity, apiresponses.InvalidRequestBodyResponse{
+			Error:       invalidDetailsError,
+			Description: fmt.Sprintf("%s must be of type %s, not %s", typeError.Field, typeError.Type, typeError.Value),
+			Offset:      typeError.Offset,
+			Field:       typeError.Field,
+		}
+	default:
+		return http.StatusUnprocessableEntity, apiresponses.InvalidRequestBodyResponse{
+			Error:       invalidDetailsError,
+			Description: err.Error(),
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	invalidBrokerResponseKey = "invalid-broker-response"
+
+	// maxOperationLength is the longest operation string the platform is
+	// required to store, as set by the Open Service Broker API.
+	maxOperationLength = 10000
+)
+
+// responseViolations collects the ways in which a broker response breaks the
+// Open Service Broker API, so that all of them are reported at once.
+type responseViolations []string
+
+func (v *responseViolations) add(format string, args ...interface{}) {
+	*v = append(*v, fmt.Sprintf(format, args...))
+}
+
+func (v responseViolations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return errors.New("invalid broker response: " + strings.Join(v, "; "))
+}
+
+// rejectInvalidResponse responds with a 500 describing err when strict response
+// validation is enabled and the broker response failed it. It reports whether
+// the response was rejected.
+func (h APIHandler) rejectInvalidResponse(w http.ResponseWriter, req *http.Request, logger lager.Logger, err error) bool {
+	if !h.strictResponses || err == nil {
+		return false
+	}
+	logger.Error(invalidBrokerResponseKey, err)
+	h.respond(w, http.StatusInternalServerError, apiresponses.ErrorResponse{
+		Description: err.Error(),
+	})
+	h.reportError(req, http.StatusInternalServerError, err)
+	return true
+}
+
+func (v *responseViolations) checkURL(field, value string) {
+	if value == "" {
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		v.add("%s %q is not an absolute URL", field, value)
+	}
+}
+
+func (v *responseViolations) checkHTTPSURL(field, value string) {
+	if value == "" {
+		return
+	}
+	v.checkURL(field, value)
+	if parsed, err := url.Parse(value); err == nil && parsed.IsAbs() && parsed.Scheme != "https" {
+		v.add("%s %q must use https", field, value)
+	}
+}
+
+func (v *responseViolations) checkOperation(isAsync bool, operation string) {
+	if !isAsync && operation != "" {
+		v.add("operation is only returned for asynchronous operations")
+	}
+	if len(operation) > maxOperationLength {
+		v.add("operation is %d characters long, the maximum is %d", len(operation), maxOperationLength)
+	}
+}
+
+func (v *responseViolations) checkDashboardURL(dashboardURL string) {
+	v.checkURL("dashboard_url", dashboardURL)
+	if parsed, err := url.Parse(dashboardURL); err == nil && parsed.IsAbs() && parsed.Scheme != "http" && parsed.Scheme != "https" {
+		v.add("dashboard_url %q must use http or https", dashboardURL)
+	}
+}
+
+func validateProvisionResponse(spec domain.ProvisionedServiceSpec) error {
+	var v responseViolations
+	v.checkDashboardURL(spec.DashboardURL)
+	v.checkOperation(spec.IsAsync, spec.OperationData)
+	return v.err()
+}
+
+func validateUpdateResponse(spec domain.UpdateServiceSpec) error {
+	var v responseViolations
+	v.checkDashboardURL(spec.DashboardURL)
+	v.checkOperation(spec.IsAsync, spec.OperationData)
+	return v.err()
+}
+
+func validateDeprovisionResponse(spec domain.DeprovisionServiceSpec) error {
+	var v responseViolations
+	v.checkOperation(spec.IsAsync, spec.OperationData)
+	return v.err()
+}
+
+func validateUnbindResponse(spec domain.UnbindSpec) error {
+	var v responseViolations
+	v.checkOperation(spec.IsAsync, spec.OperationData)
+	return v.err()
+}
+
+func validateGetInstanceResponse(spec domain.GetInstanceDetailsSpec) error {
+	var v responseViolations
+	v.checkDashboardURL(spec.DashboardURL)
+	return v.err()
+}
+
+func validateLastOperationResponse(lastOperation domain.LastOperation) error {
+	var v responseViolations
+	switch lastOperation.State {
+	case domain.InProgress, domain.Succeeded, domain.Failed:
+	default:
+		v.add("state %q is not one of %q, %q or %q", lastOperation.State, domain.InProgress, domain.Succeeded, domain.Failed)
+	}
+	if lastOperation.State != domain.Failed {
+		if lastOperation.InstanceUsable != nil {
+			v.add("instance_usable is only allowed when the state is %q", domain.Failed)
+		}
+		if lastOperation.UpdateRepeatable != nil {
+			v.add("update_repeatable is only allowed when the state is %q", domain.Failed)
+		}
+	}
+	if progress := lastOperation.Progress; progress != nil {
+		if lastOperation.State != domain.InProgress {
+			v.add("progress is only allowed when the state is %q", domain.InProgress)
+		}
+		if progress.Percent < 0 || progress.Percent > 100 {
+			v.add("progress percent %d is not between 0 and 100", progress.Percent)
+		}
+		if progress.TotalSteps > 0 && (progress.Step < 1 || progress.Step > progress.TotalSteps) {
+			v.add("progress step %d is not between 1 and %d", progress.Step, progress.TotalSteps)
+		}
+	}
+	return v.err()
+}
+
+// checkBindingContents checks the parts of a binding shared by the bind and
+// get binding responses.
+func (v *responseViolations) checkBindingContents(credentials interface{}, syslogDrainURL, routeServiceURL string, volumeMounts []domain.VolumeMount) {
+	if credentials == nil && syslogDrainURL == "" && routeServiceURL == "" && len(volumeMounts) == 0 {
+		v.add("binding has no credentials, syslog_drain_url, route_service_url or volume_mounts")
+	}
+	v.checkURL("syslog_drain_url", syslogDrainURL)
+	v.checkHTTPSURL("route_service_url", routeServiceURL)
+	for i, mount := range volumeMounts {
+		if mount.Driver == "" || mount.ContainerDir == "" || mount.Device.VolumeId == "" {
+			v.add("volume_mounts[%d] must have a driver, container_dir and device.volume_id", i)
+		}
+		if mount.Mode != "r" && mount.Mode != "rw" {
+			v.add("volume_mounts[%d] mode %q is not \"r\" or \"rw\"", i, mount.Mode)
+		}
+	}
+}
+
+func validateBindResponse(service *domain.Service, binding domain.Binding) error {
+	var v responseViolations
+	v.checkOperation(binding.IsAsync, binding.OperationData)
+	if binding.IsAsync {
+		return v.err()
+	}
+
+	v.checkBindingContents(binding.Credentials, binding.SyslogDrainURL, binding.RouteServiceURL, binding.VolumeMounts)
+	if service != nil {
+		if binding.SyslogDrainURL != "" && !requires(*service, domain.PermissionSyslogDrain) {
+			v.add("syslog_drain_url is returned but the service does not require %q", domain.PermissionSyslogDrain)
+		}
+		if binding.RouteServiceURL != "" && !requires(*service, domain.PermissionRouteForwarding) {
+			v.add("route_service_url is returned but the service does not require %q", domain.PermissionRouteForwarding)
+		}
+		if len(binding.VolumeMounts) > 0 && !requires(*service, domain.PermissionVolumeMount) {
+			v.add("volume_mounts are returned but the service does not require %q", domain.PermissionVolumeMount)
+		}
+	}
+	return v.err()
+}
+
+func validateGetBindingResponse(binding domain.GetBindingSpec) error {
+	var v responseViolations
+	v.checkBindingContents(binding.Credentials, binding.SyslogDrainURL, binding.RouteServiceURL, binding.VolumeMounts)
+	return v.err()
+}
+
+func requires(service domain.Service, permission domain.RequiredPermission) bool {
+	for _, required := range service.Requires {
+		if required == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+// maxPooledBufferSize caps the buffers returned to the pool so that a single
+// large catalog response does not pin its memory for the life of the process.
+const maxPooledBufferSize = 64 * 1024
+
+var (
+	emptyResponseBody = []byte("{}\n")
+
+	encodingFailedResponseBody = []byte(`{"description":"failed to encode response"}` + "\n")
+
+	responseBufferPool = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+)
+
+func (h APIHandler) respond(w http.ResponseWriter, status int, response interface{}) {
+	if _, ok := response.(apiresponses.EmptyResponse); ok {
+		writeResponseBody(w, status, emptyResponseBody)
+		return
+	}
+
+	buffer := responseBufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer releaseResponseBuffer(buffer)
+
+	if !h.encodeResponse(w, buffer, status, response) {
+		return
+	}
+
+	writeResponseBody(w, status, buffer.Bytes())
+}
+
+// respondWithETag writes the response with an ETag derived from its body.
+// Only the headers are written in reply to HEAD requests.
+func (h APIHandler) respondWithETag(w http.ResponseWriter, req *http.Request, status int, response interface{}) {
+	buffer := responseBufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer releaseResponseBuffer(buffer)
+
+	if !h.encodeResponse(w, buffer, status, response) {
+		return
+	}
+
+	body := buffer.Bytes()
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	if req.Method == http.MethodHead {
+		writeResponseHeader(w, status, len(body))
+		return
+	}
+	writeResponseBody(w, status, body)
+}
+
+func (h APIHandler) encodeResponse(w http.ResponseWriter, buffer *bytes.Buffer, status int, response interface{}) bool {
+	if err := json.NewEncoder(buffer).Encode(response); err != nil {
+		h.logger.Error("encoding response", err, lager.Data{"status": status, "response": response})
+		writeResponseBody(w, http.StatusInternalServerError, encodingFailedResponseBody)
+		return false
+	}
+	return true
+}
+
+func writeResponseBody(w http.ResponseWriter, status int, body []byte) {
+	writeResponseHeader(w, status, len(body))
+	w.Write(body)
+}
+
+func writeResponseHeader(w http.ResponseWriter, status int, contentLength int) {
+	header := w.Header()
+	header.Set("Content-Type", jsonContentType)
+	header.Set("Content-Length", strconv.Itoa(contentLength))
+	w.WriteHeader(status)
+}
+
+func releaseResponseBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	responseBufferPool.Put(buffer)
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import "github.com/sharma-tapas/brokerapi/domain"
+
+// retrievalAdvertised reports whether the catalog allows fetching through the
+// flag of the service with serviceID. Platforms only send the service_id
+// when they know it, so without one the request is allowed if any service
+// advertises the flag. An unknown service, or an empty catalog, is left for
+// the broker to handle.
+func retrievalAdvertised(services []domain.Service, serviceID string, flag func(domain.Service) bool) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, service := range services {
+		if serviceID == "" && flag(service) {
+			return true
+		}
+		if serviceID != "" && service.ID == serviceID {
+			return flag(service)
+		}
+	}
+	return serviceID != ""
+}
+
+// parametersRetrievable reports whether the parameters of an instance or
+// binding of serviceID may be returned. Unlike retrievalAdvertised it needs
+// the catalog to set the flag: the broker must have opted in with
+// Config.RetrieveParameters, and the service must be known, advertise the
+// flag, and not be listed in Config.ParametersOmittedServices. Without a
+// serviceID the parameters are returned only if every service allows it.
+func (h APIHandler) parametersRetrievable(services []domain.Service, serviceID string, flag func(domain.Service) bool) bool {
+	if !h.retrieveParameters {
+		return false
+	}
+	found := false
+	for _, service := range services {
+		if serviceID != "" && service.ID != serviceID {
+			continue
+		}
+		if !flag(service) || h.parametersOmitted[service.ID] {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func instancesRetrievable(service domain.Service) bool {
+	return service.InstancesRetrievable
+}
+
+func bindingsRetrievable(service domain.Service) bool {
+	return service.BindingsRetrievable
+}
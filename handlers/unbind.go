@@ -0,0 +1,116 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) Unbind(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+
+	logger := h.session(unbindLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		}
+	})
+
+	versionCompatibility, err := h.checkBrokerAPIVersionHdr(req)
+	if err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	details := domain.UnbindDetails{
+		PlanID:    req.FormValue("plan_id"),
+		ServiceID: req.FormValue("service_id"),
+	}
+
+	if details.ServiceID == "" {
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: serviceIdError.Error(),
+		})
+		logger.Error(serviceIdMissingKey, serviceIdError)
+		return
+	}
+
+	if details.PlanID == "" {
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: planIdError.Error(),
+		})
+		logger.Error(planIdMissingKey, planIdError)
+		return
+	}
+
+	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
+	if asyncAllowed && versionCompatibility.Minor < 14 {
+		h.respond(w, http.StatusUnprocessableEntity, apiresponses.ErrorResponse{
+			Description: "async unbinding only supported from OSB version 2.14 and up",
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	release, locked := h.lockInstance(w, req, logger, instanceID)
+	if !locked {
+		return
+	}
+	defer release()
+
+	unbindResponse, err := h.serviceBroker.Unbind(req.Context(), instanceID, bindingID, details, asyncAllowed)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateUnbindResponse(unbindResponse)) {
+		return
+	}
+	setHints(w, unbindResponse.Hints)
+
+	if unbindResponse.IsAsync {
+		h.respond(w, http.StatusAccepted, apiresponses.UnbindResponse{
+			OperationData: unbindResponse.OperationData,
+		})
+	} else {
+		h.respond(w, http.StatusOK, apiresponses.EmptyResponse{})
+	}
+
+	h.emit(logger, domain.LifecycleEvent{
+		Type:          domain.EventBindingDeleted,
+		InstanceID:    instanceID,
+		BindingID:     bindingID,
+		ServiceID:     details.ServiceID,
+		PlanID:        details.PlanID,
+		IsAsync:       unbindResponse.IsAsync,
+		OperationData: unbindResponse.OperationData,
+	})
+}
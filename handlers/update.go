@@ -0,0 +1,154 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+func (h APIHandler) Update(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+
+	logger := h.session(updateLogKey, func() lager.Data {
+		return lager.Data{
+			instanceIDLogKey: instanceID,
+		}
+	})
+
+	if _, err := h.checkBrokerAPIVersionHdr(req); err != nil {
+		h.respond(w, http.StatusPreconditionFailed, apiresponses.ErrorResponse{
+			Description: err.Error(),
+		})
+		logger.Error(apiVersionInvalidKey, err)
+		return
+	}
+
+	var details domain.UpdateDetails
+	if !h.decodeRequestBody(w, logger, invalidServiceDetailsErrorKey, req, &details) {
+		return
+	}
+	req = withRequestPlatform(req, details.RawContext)
+
+	if details.ServiceID == "" {
+		logger.Error(serviceIdMissingKey, serviceIdError)
+		h.respond(w, http.StatusBadRequest, apiresponses.ErrorResponse{
+			Description: serviceIdError.Error(),
+		})
+		return
+	}
+
+	service, _, ok := h.checkCatalogIDs(w, req, logger, details.ServiceID, details.PlanID)
+	if !ok {
+		return
+	}
+
+	planID := details.PlanID
+	if planID == "" {
+		planID = details.PreviousValues.PlanID
+	}
+	if !h.decodeParameters(w, req, logger, details.ServiceID, planID, &details.RawParameters) {
+		return
+	}
+
+	acceptsIncompleteFlag, _ := strconv.ParseBool(req.URL.Query().Get("accepts_incomplete"))
+
+	if h.requestCancelled(w, req, logger) {
+		return
+	}
+
+	if isDryRun(req) {
+		h.dryRunUpdate(w, req, logger, instanceID, details)
+		return
+	}
+
+	release, locked := h.lockInstance(w, req, logger, instanceID)
+	if !locked {
+		return
+	}
+	defer release()
+
+	if !h.fetchPreviousParameters(w, req, logger, instanceID, &details) {
+		return
+	}
+
+	if details.PlanID != "" && details.PlanID != details.PreviousValues.PlanID {
+		if !h.checkQuota(w, req, logger, instanceID, details.ServiceID, details.PlanID) {
+			return
+		}
+	}
+
+	updateServiceSpec, err := h.serviceBroker.Update(req.Context(), instanceID, details, acceptsIncompleteFlag)
+	if err != nil {
+		h.respondWithBrokerError(w, req, h.logger, err)
+		return
+	}
+
+	if h.rejectInvalidResponse(w, req, logger, validateUpdateResponse(updateServiceSpec)) {
+		return
+	}
+	setHints(w, updateServiceSpec.Hints)
+
+	statusCode := http.StatusOK
+	if updateServiceSpec.IsAsync {
+		statusCode = http.StatusAccepted
+	}
+	h.respond(w, statusCode, apiresponses.UpdateResponse{
+		OperationData: updateServiceSpec.OperationData,
+		DashboardURL:  updateServiceSpec.DashboardURL,
+	})
+
+	h.emit(logger, domain.LifecycleEvent{
+		Type:          domain.EventInstanceUpdated,
+		InstanceID:    instanceID,
+		ServiceID:     details.ServiceID,
+		PlanID:        details.PlanID,
+		IsAsync:       updateServiceSpec.IsAsync,
+		OperationData: updateServiceSpec.OperationData,
+		Tags:          domain.MergeTags(serviceTags(service), details.Tags()),
+	})
+	h.recordUsage(logger, updateServiceSpec.IsAsync, updateServiceSpec.OperationData, updateUsage(instanceID, details)...)
+}
+
+// fetchPreviousParameters sets the parameters the instance has before the
+// update, when the handler is configured to pass them on. It responds with the
+// error of the broker and returns false when they cannot be fetched.
+func (h APIHandler) fetchPreviousParameters(w http.ResponseWriter, req *http.Request, logger lager.Logger, instanceID string, details *domain.UpdateDetails) bool {
+	if !h.previousParameters {
+		return true
+	}
+
+	instance, err := h.serviceBroker.GetInstance(req.Context(), instanceID)
+	if err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return false
+	}
+	if instance.Parameters == nil {
+		return true
+	}
+	if details.PreviousValues.Parameters, err = json.Marshal(instance.Parameters); err != nil {
+		h.respondWithBrokerError(w, req, logger, err)
+		return false
+	}
+	return true
+}
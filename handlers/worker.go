@@ -0,0 +1,56 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// workerQueueSize is how many jobs may wait for a worker before those
+	// queuing them wait for room.
+	workerQueueSize = 1000
+	// workerJobTimeout bounds each job run by a worker.
+	workerJobTimeout = 30 * time.Second
+)
+
+// worker runs jobs on a single goroutine, one at a time and in the order they
+// were queued, each with a context which expires after workerJobTimeout. It
+// hands the calls to meters and event sinks off the request goroutine without
+// starting a goroutine per call.
+type worker struct {
+	jobs chan func(ctx context.Context)
+}
+
+func newWorker() *worker {
+	w := &worker{jobs: make(chan func(ctx context.Context), workerQueueSize)}
+	go w.run()
+	return w
+}
+
+func (w *worker) run() {
+	for job := range w.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), workerJobTimeout)
+		job(ctx)
+		cancel()
+	}
+}
+
+// do queues job, waiting while the queue is full.
+func (w *worker) do(job func(ctx context.Context)) {
+	w.jobs <- job
+}
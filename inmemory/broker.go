@@ -0,0 +1,668 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inmemory provides a reference implementation of the
+// brokerapi.ServiceBroker interface which keeps its instances and bindings in
+// memory. Asynchronous operations complete after a configurable delay, and
+// failures can be injected into any operation, which makes the broker useful
+// both as an example and as a test double for platform developers.
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const (
+	unknownServiceKey = "unknown-service"
+	unknownPlanKey    = "unknown-plan"
+	operationKey      = "operation-in-progress"
+)
+
+// Config configures a Broker.
+type Config struct {
+	// Services is the catalog offered by the broker.
+	Services []domain.Service
+
+	// AsyncDelay is how long asynchronous operations stay in progress. When
+	// it is zero, or when the platform does not accept asynchronous
+	// operations, every operation completes synchronously.
+	AsyncDelay time.Duration
+
+	// Now returns the current time. It defaults to time.Now and can be
+	// replaced to control the progress of asynchronous operations in tests.
+	Now func() time.Time
+}
+
+// Instance is a service instance held by the Broker.
+type Instance struct {
+	ID           string
+	ServiceID    string
+	PlanID       string
+	DashboardURL string
+	Parameters   map[string]interface{}
+	// MaintenanceInfo is the maintenance_info of the latest provision or
+	// update which had one.
+	MaintenanceInfo *domain.MaintenanceInfo
+}
+
+// Binding is a service binding held by the Broker.
+type Binding struct {
+	ID          string
+	InstanceID  string
+	ServiceID   string
+	PlanID      string
+	AppGUID     string
+	Credentials map[string]interface{}
+	Parameters  map[string]interface{}
+}
+
+type operation struct {
+	id         string
+	kind       domain.Operation
+	completeAt time.Time
+	failure    string
+	complete   func()
+	finished   bool
+	state      domain.LastOperationState
+}
+
+// Broker is an in-memory service broker. It is safe for concurrent use.
+type Broker struct {
+	config Config
+
+	mutex sync.Mutex
+
+	instances  map[string]Instance
+	bindings   map[resourceKey]Binding
+	operations map[resourceKey]*operation
+
+	failures      map[domain.Operation]error
+	asyncFailures map[domain.Operation]string
+}
+
+// New returns a Broker with no instances or bindings.
+func New(config Config) *Broker {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Broker{
+		config:        config,
+		instances:     map[string]Instance{},
+		bindings:      map[resourceKey]Binding{},
+		operations:    map[resourceKey]*operation{},
+		failures:      map[domain.Operation]error{},
+		asyncFailures: map[domain.Operation]string{},
+	}
+}
+
+// InjectFailure makes the next call for operation return err instead of
+// being carried out.
+func (b *Broker) InjectFailure(operation domain.Operation, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures[operation] = err
+}
+
+// InjectAsyncFailure makes the next asynchronous operation started by
+// operation finish in the failed state with the given description. The
+// instance or binding is left as it was before the operation started.
+func (b *Broker) InjectAsyncFailure(operation domain.Operation, description string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.asyncFailures[operation] = description
+}
+
+// Instances returns the service instances which currently exist.
+func (b *Broker) Instances() []Instance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	instances := make([]Instance, 0, len(b.instances))
+	for _, instance := range b.instances {
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// Bindings returns the service bindings which currently exist.
+func (b *Broker) Bindings() []Binding {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	bindings := make([]Binding, 0, len(b.bindings))
+	for _, binding := range b.bindings {
+		bindings = append(bindings, binding)
+	}
+	return bindings
+}
+
+func (b *Broker) Services(ctx context.Context) ([]domain.Service, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationCatalog); err != nil {
+		return nil, err
+	}
+	return b.config.Services, nil
+}
+
+func (b *Broker) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationProvision); err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	if err := b.checkNoOperation(instanceKey(instanceID)); err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	if _, err := b.findPlan(details.ServiceID, details.PlanID); err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	if _, ok := b.instances[instanceID]; ok {
+		return domain.ProvisionedServiceSpec{}, apiresponses.ErrInstanceAlreadyExists
+	}
+	parameters, err := decodeParameters(details.RawParameters)
+	if err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+
+	instance := Instance{
+		ID:           instanceID,
+		ServiceID:    details.ServiceID,
+		PlanID:       details.PlanID,
+		DashboardURL: fmt.Sprintf("https://dashboard.example.com/instances/%s", instanceID),
+		Parameters:   parameters,
+	}
+	if !details.MaintenanceInfo.IsZero() {
+		maintenanceInfo := details.MaintenanceInfo
+		instance.MaintenanceInfo = &maintenanceInfo
+	}
+	create := func() { b.instances[instanceID] = instance }
+
+	if !b.async(asyncAllowed) {
+		create()
+		return domain.ProvisionedServiceSpec{DashboardURL: instance.DashboardURL}, nil
+	}
+	op := b.startOperation(instanceKey(instanceID), domain.OperationProvision, create)
+	return domain.ProvisionedServiceSpec{IsAsync: true, DashboardURL: instance.DashboardURL, OperationData: op.id}, nil
+}
+
+func (b *Broker) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationDeprovision); err != nil {
+		return domain.DeprovisionServiceSpec{}, err
+	}
+	if op := b.pendingOperation(instanceKey(instanceID)); op != nil && op.kind == domain.OperationProvision {
+		return domain.DeprovisionServiceSpec{}, apiresponses.ErrProvisionInProgress
+	}
+	if err := b.checkNoOperation(instanceKey(instanceID)); err != nil {
+		return domain.DeprovisionServiceSpec{}, err
+	}
+	if _, ok := b.instances[instanceID]; !ok {
+		return domain.DeprovisionServiceSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+
+	remove := func() { b.removeInstance(instanceID) }
+
+	if !b.async(asyncAllowed) {
+		remove()
+		return domain.DeprovisionServiceSpec{}, nil
+	}
+	op := b.startOperation(instanceKey(instanceID), domain.OperationDeprovision, remove)
+	return domain.DeprovisionServiceSpec{IsAsync: true, OperationData: op.id}, nil
+}
+
+// CancelProvision abandons a provision in progress, so that the instance is
+// never created.
+func (b *Broker) CancelProvision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	op := b.pendingOperation(instanceKey(instanceID))
+	if op == nil || op.kind != domain.OperationProvision {
+		return domain.DeprovisionServiceSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+	delete(b.operations, instanceKey(instanceID))
+
+	if !b.async(asyncAllowed) {
+		return domain.DeprovisionServiceSpec{}, nil
+	}
+	op = b.startOperation(instanceKey(instanceID), domain.OperationDeprovision, func() {})
+	return domain.DeprovisionServiceSpec{IsAsync: true, OperationData: op.id}, nil
+}
+
+func (b *Broker) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationGetInstance); err != nil {
+		return domain.GetInstanceDetailsSpec{}, err
+	}
+	if op := b.pendingOperation(instanceKey(instanceID)); op != nil && op.kind == domain.OperationUpdate {
+		return domain.GetInstanceDetailsSpec{}, apiresponses.ErrConcurrentInstanceAccess.Build()
+	}
+	instance, ok := b.instances[instanceID]
+	if !ok {
+		return domain.GetInstanceDetailsSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+	return domain.GetInstanceDetailsSpec{
+		ServiceID:    instance.ServiceID,
+		PlanID:       instance.PlanID,
+		DashboardURL: instance.DashboardURL,
+		Parameters:   instance.Parameters,
+	}, nil
+}
+
+func (b *Broker) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationUpdate); err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	if err := b.checkNoOperation(instanceKey(instanceID)); err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	instance, ok := b.instances[instanceID]
+	if !ok {
+		return domain.UpdateServiceSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+
+	updated := instance
+	if details.PlanID != "" && details.PlanID != instance.PlanID {
+		service, err := b.findService(instance.ServiceID)
+		if err != nil {
+			return domain.UpdateServiceSpec{}, err
+		}
+		current, err := b.findPlan(instance.ServiceID, instance.PlanID)
+		if err != nil {
+			return domain.UpdateServiceSpec{}, err
+		}
+		if !current.IsPlanUpdatable(service) {
+			return domain.UpdateServiceSpec{}, apiresponses.ErrPlanChangeNotSupported
+		}
+		if _, err := b.findPlan(instance.ServiceID, details.PlanID); err != nil {
+			return domain.UpdateServiceSpec{}, err
+		}
+		updated.PlanID = details.PlanID
+	}
+	parameters, err := decodeParameters(details.RawParameters)
+	if err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	if len(parameters) > 0 {
+		updated.Parameters = mergeParameters(instance.Parameters, parameters)
+	}
+	if !details.MaintenanceInfo.IsZero() {
+		maintenanceInfo := details.MaintenanceInfo
+		updated.MaintenanceInfo = &maintenanceInfo
+	}
+
+	update := func() { b.instances[instanceID] = updated }
+
+	if !b.async(asyncAllowed) {
+		update()
+		return domain.UpdateServiceSpec{DashboardURL: instance.DashboardURL}, nil
+	}
+	op := b.startOperation(instanceKey(instanceID), domain.OperationUpdate, update)
+	return domain.UpdateServiceSpec{IsAsync: true, DashboardURL: instance.DashboardURL, OperationData: op.id}, nil
+}
+
+func (b *Broker) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationLastOperation); err != nil {
+		return domain.LastOperation{}, err
+	}
+	return b.pollOperation(instanceKey(instanceID), details.OperationData, apiresponses.ErrInstanceDoesNotExist)
+}
+
+func (b *Broker) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, asyncAllowed bool) (domain.Binding, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationBind); err != nil {
+		return domain.Binding{}, err
+	}
+	if err := b.checkNoOperation(instanceKey(instanceID)); err != nil {
+		return domain.Binding{}, err
+	}
+	if err := b.checkNoOperation(bindingKey(instanceID, bindingID)); err != nil {
+		return domain.Binding{}, err
+	}
+	instance, ok := b.instances[instanceID]
+	if !ok {
+		return domain.Binding{}, apiresponses.ErrInstanceDoesNotExist
+	}
+	if _, ok := b.bindings[bindingKey(instanceID, bindingID)]; ok {
+		return domain.Binding{}, apiresponses.ErrBindingAlreadyExists
+	}
+	parameters, err := decodeParameters(details.RawParameters)
+	if err != nil {
+		return domain.Binding{}, err
+	}
+
+	appGUID := details.BoundAppGUID()
+	binding := Binding{
+		ID:         bindingID,
+		InstanceID: instanceID,
+		ServiceID:  instance.ServiceID,
+		PlanID:     instance.PlanID,
+		AppGUID:    appGUID,
+		Credentials: map[string]interface{}{
+			"uri":      fmt.Sprintf("inmemory://%s/%s", instanceID, bindingID),
+			"username": bindingID,
+			"password": uuid.NewRandom().String(),
+		},
+		Parameters: parameters,
+	}
+	create := func() { b.bindings[bindingKey(instanceID, bindingID)] = binding }
+
+	if !b.async(asyncAllowed) {
+		create()
+		return domain.Binding{Credentials: binding.Credentials}, nil
+	}
+	op := b.startOperation(bindingKey(instanceID, bindingID), domain.OperationBind, create)
+	return domain.Binding{IsAsync: true, OperationData: op.id}, nil
+}
+
+func (b *Broker) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationUnbind); err != nil {
+		return domain.UnbindSpec{}, err
+	}
+	key := bindingKey(instanceID, bindingID)
+	if err := b.checkNoOperation(key); err != nil {
+		return domain.UnbindSpec{}, err
+	}
+	if _, ok := b.instances[instanceID]; !ok {
+		return domain.UnbindSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+	if _, ok := b.bindings[key]; !ok {
+		return domain.UnbindSpec{}, apiresponses.ErrBindingDoesNotExist
+	}
+
+	remove := func() { delete(b.bindings, key) }
+
+	if !b.async(asyncAllowed) {
+		remove()
+		return domain.UnbindSpec{}, nil
+	}
+	op := b.startOperation(key, domain.OperationUnbind, remove)
+	return domain.UnbindSpec{IsAsync: true, OperationData: op.id}, nil
+}
+
+func (b *Broker) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationGetBinding); err != nil {
+		return domain.GetBindingSpec{}, err
+	}
+	binding, ok := b.bindings[bindingKey(instanceID, bindingID)]
+	if !ok {
+		return domain.GetBindingSpec{}, apiresponses.ErrBindingNotFound
+	}
+	return domain.GetBindingSpec{
+		Credentials: binding.Credentials,
+		Parameters:  binding.Parameters,
+	}, nil
+}
+
+func (b *Broker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationLastBindingOperation); err != nil {
+		return domain.LastOperation{}, err
+	}
+	return b.pollOperation(bindingKey(instanceID, bindingID), details.OperationData, apiresponses.ErrBindingDoesNotExist)
+}
+
+// ListInstances returns the instances ordered by ID, so that the ID of the
+// last instance of a page is the cursor of the next one.
+func (b *Broker) ListInstances(ctx context.Context, request domain.ListInstancesRequest) (domain.InstanceList, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationAdminListInstances); err != nil {
+		return domain.InstanceList{}, err
+	}
+
+	summaries := make([]domain.InstanceSummary, 0, len(b.instances))
+	for _, instance := range b.instances {
+		summaries = append(summaries, domain.InstanceSummary{
+			InstanceID:      instance.ID,
+			ServiceID:       instance.ServiceID,
+			PlanID:          instance.PlanID,
+			DashboardURL:    instance.DashboardURL,
+			MaintenanceInfo: instance.MaintenanceInfo,
+		})
+	}
+
+	var list domain.InstanceList
+	list.Instances, list.NextCursor = domain.Paginate(summaries, request, func(summary domain.InstanceSummary) string {
+		return summary.InstanceID
+	})
+	return list, nil
+}
+
+// RemoveOrphanedInstance deletes an instance and its bindings, abandoning any
+// operation in progress on them.
+func (b *Broker) RemoveOrphanedInstance(ctx context.Context, instanceID string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.injectedFailure(domain.OperationAdminSweepOrphans); err != nil {
+		return err
+	}
+	if _, ok := b.instances[instanceID]; !ok {
+		return apiresponses.ErrInstanceDoesNotExist
+	}
+	for key := range b.operations {
+		if key.instanceID == instanceID {
+			delete(b.operations, key)
+		}
+	}
+	b.removeInstance(instanceID)
+	return nil
+}
+
+func (b *Broker) removeInstance(instanceID string) {
+	delete(b.instances, instanceID)
+	for id, binding := range b.bindings {
+		if binding.InstanceID == instanceID {
+			delete(b.bindings, id)
+		}
+	}
+}
+
+func (b *Broker) injectedFailure(operation domain.Operation) error {
+	err, ok := b.failures[operation]
+	if !ok {
+		return nil
+	}
+	delete(b.failures, operation)
+	return err
+}
+
+func (b *Broker) async(asyncAllowed bool) bool {
+	return asyncAllowed && b.config.AsyncDelay > 0
+}
+
+func (b *Broker) startOperation(key resourceKey, kind domain.Operation, complete func()) *operation {
+	op := &operation{
+		id:         uuid.NewRandom().String(),
+		kind:       kind,
+		completeAt: b.config.Now().Add(b.config.AsyncDelay),
+		complete:   complete,
+		state:      domain.InProgress,
+	}
+	if failure, ok := b.asyncFailures[kind]; ok {
+		op.failure = failure
+		delete(b.asyncFailures, kind)
+	}
+	b.operations[key] = op
+	return op
+}
+
+// pendingOperation returns the operation on key, if it is still in progress.
+func (b *Broker) pendingOperation(key resourceKey) *operation {
+	op, ok := b.operations[key]
+	if !ok {
+		return nil
+	}
+	b.advance(op)
+	if op.finished {
+		return nil
+	}
+	return op
+}
+
+func (b *Broker) checkNoOperation(key resourceKey) error {
+	if op := b.pendingOperation(key); op != nil {
+		return apiresponses.NewFailureResponse(
+			fmt.Errorf("%s is in progress", op.kind), http.StatusUnprocessableEntity, operationKey,
+		)
+	}
+	return nil
+}
+
+// advance completes op once its delay has elapsed.
+func (b *Broker) advance(op *operation) {
+	if op.finished || b.config.Now().Before(op.completeAt) {
+		return
+	}
+	op.finished = true
+	if op.failure != "" {
+		op.state = domain.Failed
+		return
+	}
+	op.complete()
+	op.state = domain.Succeeded
+}
+
+func (b *Broker) pollOperation(key resourceKey, operationData string, missing error) (domain.LastOperation, error) {
+	op, ok := b.operations[key]
+	if !ok || (operationData != "" && operationData != op.id) {
+		return domain.LastOperation{}, missing
+	}
+	b.advance(op)
+
+	lastOperation := domain.LastOperation{
+		State:       op.state,
+		Description: fmt.Sprintf("%s %s", op.kind, op.state),
+	}
+	if op.state == domain.Failed {
+		lastOperation.Description = op.failure
+		// A failed update or deprovision leaves the instance unchanged.
+		switch op.kind {
+		case domain.OperationUpdate:
+			lastOperation.InstanceUsable = domain.BoolPtr(true)
+			lastOperation.UpdateRepeatable = domain.BoolPtr(true)
+		case domain.OperationDeprovision:
+			lastOperation.InstanceUsable = domain.BoolPtr(true)
+		}
+	}
+	return lastOperation, nil
+}
+
+func (b *Broker) findService(serviceID string) (domain.Service, error) {
+	for _, service := range b.config.Services {
+		if service.ID == serviceID {
+			return service, nil
+		}
+	}
+	return domain.Service{}, apiresponses.NewFailureResponse(
+		fmt.Errorf("service %q is not in the catalog", serviceID), http.StatusBadRequest, unknownServiceKey,
+	)
+}
+
+func (b *Broker) findPlan(serviceID, planID string) (domain.ServicePlan, error) {
+	service, err := b.findService(serviceID)
+	if err != nil {
+		return domain.ServicePlan{}, err
+	}
+	for _, plan := range service.Plans {
+		if plan.ID == planID {
+			return plan, nil
+		}
+	}
+	return domain.ServicePlan{}, apiresponses.NewFailureResponse(
+		fmt.Errorf("plan %q is not in the catalog", planID), http.StatusBadRequest, unknownPlanKey,
+	)
+}
+
+// resourceKey identifies an instance, with an empty bindingID, or one of its
+// bindings. IDs are kept apart rather than joined, so that no instance ID,
+// however it is spelt, can be mistaken for another instance's binding.
+type resourceKey struct {
+	instanceID string
+	bindingID  string
+}
+
+func instanceKey(instanceID string) resourceKey {
+	return resourceKey{instanceID: instanceID}
+}
+
+func bindingKey(instanceID, bindingID string) resourceKey {
+	return resourceKey{instanceID: instanceID, bindingID: bindingID}
+}
+
+func decodeParameters(raw json.RawMessage) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var parameters map[string]interface{}
+	if err := json.Unmarshal(raw, &parameters); err != nil {
+		return nil, apiresponses.ErrRawParamsInvalid
+	}
+	return parameters, nil
+}
+
+func mergeParameters(current, changes map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(changes))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		merged[key] = value
+	}
+	return merged
+}
+
+var (
+	_ domain.ServiceBroker      = (*Broker)(nil)
+	_ domain.InstanceLister     = (*Broker)(nil)
+	_ domain.OrphanRemover      = (*Broker)(nil)
+	_ domain.ProvisionCanceller = (*Broker)(nil)
+)
@@ -0,0 +1,345 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inmemory_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/inmemory"
+)
+
+var _ = Describe("Broker", func() {
+	const (
+		instanceID = "instance-id"
+		bindingID  = "binding-id"
+	)
+
+	var (
+		now    time.Time
+		broker *inmemory.Broker
+		tester brokertest.BrokerTester
+	)
+
+	services := []domain.Service{{
+		ID:            "service-id",
+		Name:          "database",
+		Description:   "an in-memory database",
+		Bindable:      true,
+		PlanUpdatable: true,
+		Plans: []domain.ServicePlan{
+			{ID: "small-id", Name: "small", Description: "a small database"},
+			{ID: "large-id", Name: "large", Description: "a large database"},
+		},
+	}}
+
+	decode := func(recorder *httptest.ResponseRecorder) map[string]interface{} {
+		var body map[string]interface{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		return body
+	}
+
+	newBroker := func(delay time.Duration) {
+		now = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		broker = inmemory.New(inmemory.Config{
+			Services:   services,
+			AsyncDelay: delay,
+			Now:        func() time.Time { return now },
+		})
+		credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+		tester = brokertest.New(brokerapi.New(broker, lager.NewLogger("inmemory"), credentials), "username", "password")
+	}
+
+	provisionPath := "/v2/service_instances/" + instanceID
+	bindingPath := provisionPath + "/service_bindings/" + bindingID
+	provisionBody := `{"service_id":"service-id","plan_id":"small-id","organization_guid":"org","space_guid":"space","parameters":{"size":1}}`
+	bindBody := `{"service_id":"service-id","plan_id":"small-id","app_guid":"app"}`
+
+	Context("when operations are synchronous", func() {
+		BeforeEach(func() {
+			newBroker(0)
+		})
+
+		It("serves the configured catalog", func() {
+			response := tester.Catalog()
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(ContainSubstring(`"id":"service-id"`))
+		})
+
+		It("provisions, updates, binds, unbinds and deprovisions an instance", func() {
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusCreated))
+			Expect(broker.Instances()).To(ConsistOf(inmemory.Instance{
+				ID:           instanceID,
+				ServiceID:    "service-id",
+				PlanID:       "small-id",
+				DashboardURL: "https://dashboard.example.com/instances/instance-id",
+				Parameters:   map[string]interface{}{"size": float64(1)},
+			}))
+
+			response := tester.Do("PATCH", provisionPath, `{"service_id":"service-id","plan_id":"large-id","parameters":{"backups":true}}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			instance := broker.Instances()[0]
+			Expect(instance.PlanID).To(Equal("large-id"))
+			Expect(instance.Parameters).To(Equal(map[string]interface{}{"size": float64(1), "backups": true}))
+
+			response = tester.Do("PUT", bindingPath, bindBody)
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			credentials := decode(response)["credentials"].(map[string]interface{})
+			Expect(credentials).To(HaveKeyWithValue("uri", "inmemory://instance-id/binding-id"))
+			Expect(credentials).To(HaveKeyWithValue("username", bindingID))
+			Expect(credentials["password"]).NotTo(BeEmpty())
+			Expect(broker.Bindings()).To(HaveLen(1))
+			Expect(broker.Bindings()[0].AppGUID).To(Equal("app"))
+
+			Expect(tester.Do("DELETE", bindingPath+"?service_id=service-id&plan_id=large-id", nil).Code).To(Equal(http.StatusOK))
+			Expect(broker.Bindings()).To(BeEmpty())
+
+			Expect(tester.Do("DELETE", provisionPath+"?service_id=service-id&plan_id=large-id", nil).Code).To(Equal(http.StatusOK))
+			Expect(broker.Instances()).To(BeEmpty())
+		})
+
+		It("applies the plan_updateable flag of the current plan", func() {
+			services[0].Plans[0] = services[0].Plans[0].WithPlanUpdatable(false)
+			defer func() { services[0].Plans[0].PlanUpdatable = nil }()
+
+			tester.Do("PUT", provisionPath, provisionBody)
+			response := tester.Do("PATCH", provisionPath, `{"service_id":"service-id","plan_id":"large-id"}`)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(decode(response)).To(HaveKeyWithValue("error", "PlanChangeNotSupported"))
+		})
+
+		It("rejects a second provision of the same instance", func() {
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusConflict))
+		})
+
+		It("rejects plans which are not in the catalog", func() {
+			response := tester.Do("PUT", provisionPath, `{"service_id":"service-id","plan_id":"unknown","organization_guid":"org","space_guid":"space"}`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+			_, err := broker.Provision(context.Background(), instanceID, domain.ProvisionDetails{ServiceID: "service-id", PlanID: "unknown"}, false)
+			Expect(err).To(MatchError(`plan "unknown" is not in the catalog`))
+		})
+
+		It("reports missing instances and bindings", func() {
+			Expect(tester.Do("DELETE", provisionPath+"?service_id=service-id&plan_id=small-id", nil).Code).To(Equal(http.StatusGone))
+			Expect(tester.Do("PUT", bindingPath, bindBody).Code).To(Equal(http.StatusNotFound))
+
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusCreated))
+			Expect(tester.Do("DELETE", bindingPath+"?service_id=service-id&plan_id=small-id", nil).Code).To(Equal(http.StatusGone))
+		})
+
+		It("removes the bindings of a deprovisioned instance", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+			tester.Do("PUT", bindingPath, bindBody)
+
+			tester.Do("DELETE", provisionPath+"?service_id=service-id&plan_id=small-id", nil)
+			Expect(broker.Bindings()).To(BeEmpty())
+		})
+
+		It("returns the instance and binding details", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+			tester.Do("PUT", bindingPath, bindBody)
+
+			instance, err := broker.GetInstance(context.Background(), instanceID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instance.PlanID).To(Equal("small-id"))
+			Expect(instance.Parameters).To(Equal(map[string]interface{}{"size": float64(1)}))
+
+			binding, err := broker.GetBinding(context.Background(), instanceID, bindingID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(binding.Credentials).To(HaveKeyWithValue("username", bindingID))
+		})
+
+		It("lists the instances a page at a time", func() {
+			for _, id := range []string{"c", "a", "b"} {
+				tester.Do("PUT", "/v2/service_instances/"+id, provisionBody)
+			}
+
+			list, err := broker.ListInstances(context.Background(), domain.ListInstancesRequest{Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Instances).To(HaveLen(2))
+			Expect(list.Instances[0].InstanceID).To(Equal("a"))
+			Expect(list.Instances[1].InstanceID).To(Equal("b"))
+			Expect(list.NextCursor).To(Equal("b"))
+
+			list, err = broker.ListInstances(context.Background(), domain.ListInstancesRequest{Limit: 2, Cursor: list.NextCursor})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Instances).To(ConsistOf(domain.InstanceSummary{
+				InstanceID:   "c",
+				ServiceID:    "service-id",
+				PlanID:       "small-id",
+				DashboardURL: "https://dashboard.example.com/instances/c",
+			}))
+			Expect(list.NextCursor).To(BeEmpty())
+		})
+
+		It("records the maintenance_info of the instances", func() {
+			tester.Do("PUT", provisionPath, `{"service_id":"service-id","plan_id":"small-id","organization_guid":"org","space_guid":"space","maintenance_info":{"private":"v1"}}`)
+			Expect(broker.Instances()[0].MaintenanceInfo).To(Equal(&domain.MaintenanceInfo{Private: "v1"}))
+
+			tester.Do("PATCH", provisionPath, `{"service_id":"service-id","parameters":{"size":2}}`)
+			Expect(broker.Instances()[0].MaintenanceInfo).To(Equal(&domain.MaintenanceInfo{Private: "v1"}))
+
+			tester.Do("PATCH", provisionPath, `{"service_id":"service-id","maintenance_info":{"private":"v2"}}`)
+			list, err := broker.ListInstances(context.Background(), domain.ListInstancesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Instances[0].MaintenanceInfo).To(Equal(&domain.MaintenanceInfo{Private: "v2"}))
+		})
+
+		It("removes an orphaned instance and its bindings", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+			tester.Do("PUT", bindingPath, bindBody)
+
+			Expect(broker.RemoveOrphanedInstance(context.Background(), instanceID)).To(Succeed())
+			Expect(broker.Instances()).To(BeEmpty())
+			Expect(broker.Bindings()).To(BeEmpty())
+
+			Expect(broker.RemoveOrphanedInstance(context.Background(), instanceID)).To(MatchError(apiresponses.ErrInstanceDoesNotExist))
+		})
+	})
+
+	Context("when operations are asynchronous", func() {
+		BeforeEach(func() {
+			newBroker(time.Minute)
+		})
+
+		lastOperation := func(path string) map[string]interface{} {
+			response := tester.Do("GET", path+"/last_operation", nil)
+			Expect(response.Code).To(Equal(http.StatusOK))
+			return decode(response)
+		}
+
+		It("keeps the operations of instances whose IDs start with the ID of a removed orphan", func() {
+			ctx := context.Background()
+			details := domain.ProvisionDetails{ServiceID: "service-id", PlanID: "small-id"}
+			_, err := broker.Provision(ctx, "instance", details, false)
+			Expect(err).NotTo(HaveOccurred())
+			spec, err := broker.Provision(ctx, "instance/binding", details, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(broker.RemoveOrphanedInstance(ctx, "instance")).To(Succeed())
+			operation, err := broker.LastOperation(ctx, "instance/binding", domain.PollDetails{OperationData: spec.OperationData})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operation.State).To(Equal(domain.InProgress))
+		})
+
+		It("completes a provision after the delay", func() {
+			response := tester.Do("PUT", provisionPath+"?accepts_incomplete=true", provisionBody)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+			Expect(decode(response)).To(HaveKey("operation"))
+			Expect(broker.Instances()).To(BeEmpty())
+			Expect(lastOperation(provisionPath)).To(HaveKeyWithValue("state", "in progress"))
+
+			now = now.Add(time.Minute)
+			Expect(lastOperation(provisionPath)).To(HaveKeyWithValue("state", "succeeded"))
+			Expect(broker.Instances()).To(HaveLen(1))
+		})
+
+		It("rejects operations on an instance while another is in progress", func() {
+			tester.Do("PUT", provisionPath+"?accepts_incomplete=true", provisionBody)
+
+			response := tester.Do("PUT", bindingPath+"?accepts_incomplete=true", bindBody)
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(decode(response)).To(HaveKeyWithValue("description", "provision is in progress"))
+		})
+
+		It("cancels a provision in progress when the instance is deleted", func() {
+			tester.Do("PUT", provisionPath+"?accepts_incomplete=true", provisionBody)
+
+			response := tester.Do("DELETE", provisionPath+"?service_id=service-id&plan_id=small-id&accepts_incomplete=true", nil)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+
+			now = now.Add(time.Minute)
+			Expect(lastOperation(provisionPath)).To(HaveKeyWithValue("description", "deprovision succeeded"))
+			Expect(broker.Instances()).To(BeEmpty())
+		})
+
+		It("completes an asynchronous binding after the delay", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+
+			response := tester.Do("PUT", bindingPath+"?accepts_incomplete=true", bindBody)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+			Expect(lastOperation(bindingPath)).To(HaveKeyWithValue("state", "in progress"))
+
+			now = now.Add(time.Minute)
+			Expect(lastOperation(bindingPath)).To(HaveKeyWithValue("state", "succeeded"))
+			Expect(broker.Bindings()).To(HaveLen(1))
+		})
+
+		It("reports a concurrency error when fetching an instance that is being updated", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+			tester.Do("PATCH", provisionPath+"?accepts_incomplete=true", `{"service_id":"service-id","plan_id":"large-id"}`)
+
+			_, err := broker.GetInstance(context.Background(), instanceID)
+			Expect(err).To(MatchError("instance is being updated and cannot be retrieved"))
+		})
+
+		It("completes synchronously when the platform does not accept incomplete operations", func() {
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusCreated))
+			Expect(broker.Instances()).To(HaveLen(1))
+		})
+	})
+
+	Describe("failure injection", func() {
+		BeforeEach(func() {
+			newBroker(time.Minute)
+		})
+
+		It("returns an injected error from the next call only", func() {
+			broker.InjectFailure(domain.OperationProvision, brokerapi.ErrInstanceLimitMet)
+
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusInternalServerError))
+			Expect(tester.Do("PUT", provisionPath, provisionBody).Code).To(Equal(http.StatusCreated))
+		})
+
+		It("returns injected errors which are not failure responses as 500s", func() {
+			broker.InjectFailure(domain.OperationCatalog, errors.New("catalog unavailable"))
+
+			response := tester.Catalog()
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(decode(response)).To(HaveKeyWithValue("description", "catalog unavailable"))
+		})
+
+		It("fails the next asynchronous operation and leaves the instance unchanged", func() {
+			tester.Do("PUT", provisionPath, provisionBody)
+			broker.InjectAsyncFailure(domain.OperationUpdate, "out of capacity")
+
+			tester.Do("PATCH", provisionPath+"?accepts_incomplete=true", `{"service_id":"service-id","plan_id":"large-id"}`)
+			now = now.Add(time.Minute)
+
+			response := tester.LastOperation(instanceID, "")
+			Expect(decode(response)).To(Equal(map[string]interface{}{
+				"state":             "failed",
+				"description":       "out of capacity",
+				"instance_usable":   true,
+				"update_repeatable": true,
+			}))
+			Expect(broker.Instances()[0].PlanID).To(Equal("small-id"))
+		})
+	})
+})
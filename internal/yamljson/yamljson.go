@@ -0,0 +1,46 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package yamljson converts documents decoded from YAML into values which
+// encoding/json can marshal.
+package yamljson
+
+import "fmt"
+
+// JSONCompatible replaces the map[interface{}]interface{} values produced by
+// the YAML decoder with maps which encoding/json can marshal. Maps and slices
+// are converted in place where their type allows it.
+func JSONCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = JSONCompatible(v)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			converted[fmt.Sprint(k)] = JSONCompatible(v)
+		}
+		return converted
+	case []interface{}:
+		for i, v := range value {
+			value[i] = JSONCompatible(v)
+		}
+		return value
+	default:
+		return value
+	}
+}
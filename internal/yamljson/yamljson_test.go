@@ -0,0 +1,36 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yamljson_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/internal/yamljson"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var _ = Describe("JSONCompatible", func() {
+	It("converts the nested maps decoded from YAML", func() {
+		var document map[string]interface{}
+		Expect(yaml.Unmarshal([]byte("plans:\n- id: small\n  metadata:\n    1: one\nsize: {gb: 10}\n"), &document)).To(Succeed())
+
+		asJSON, err := json.Marshal(yamljson.JSONCompatible(document))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(asJSON).To(MatchJSON(`{"plans":[{"id":"small","metadata":{"1":"one"}}],"size":{"gb":10}}`))
+	})
+})
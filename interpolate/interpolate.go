@@ -0,0 +1,155 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package interpolate substitutes environment variables and the contents of
+// secret files into configuration and catalog files, so that one file can
+// serve several environments:
+//
+//	plans:
+//	- id: ${SMALL_PLAN_ID}
+//	  name: small
+//	  description: ${SMALL_PLAN_DESCRIPTION:-A small database}
+//	credentials:
+//	  password: ${file:/etc/broker/password}
+//
+// ${NAME} is replaced with the environment variable NAME, and
+// ${NAME:-default} with default when NAME is unset or empty. ${file:path} is
+// replaced with the contents of the file at path, without trailing newlines,
+// as when a Kubernetes secret is mounted into a pod. $$ stands for a literal
+// $. Values are substituted as text, so values which may contain characters
+// such as quotes or colons should be quoted.
+package interpolate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const filePrefix = "file:"
+
+// MissingError lists the variables and files which were referenced but could
+// not be found.
+type MissingError struct {
+	Variables []string
+	Files     []string
+}
+
+func (e *MissingError) Error() string {
+	var missing []string
+	if len(e.Variables) > 0 {
+		missing = append(missing, "environment variables "+strings.Join(e.Variables, ", "))
+	}
+	if len(e.Files) > 0 {
+		missing = append(missing, "files "+strings.Join(e.Files, ", "))
+	}
+	return "missing " + strings.Join(missing, " and ")
+}
+
+// Expand substitutes the references in contents, reading environment
+// variables from the process environment.
+func Expand(contents []byte) ([]byte, error) {
+	return ExpandWith(contents, os.LookupEnv)
+}
+
+// ExpandWith substitutes the references in contents, reading environment
+// variables with lookupEnv. Every missing value is reported in the returned
+// *MissingError, rather than only the first.
+func ExpandWith(contents []byte, lookupEnv func(string) (string, bool)) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		missing MissingError
+	)
+	for i := 0; i < len(contents); i++ {
+		c := contents[i]
+		if c != '$' || i+1 == len(contents) {
+			out.WriteByte(c)
+			continue
+		}
+		if contents[i+1] == '$' {
+			out.WriteByte('$')
+			i++
+			continue
+		}
+		if contents[i+1] != '{' {
+			out.WriteByte(c)
+			continue
+		}
+
+		end := bytes.IndexByte(contents[i+2:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated reference at offset %d", i)
+		}
+		reference := string(contents[i+2 : i+2+end])
+		i += 2 + end
+
+		value, err := resolve(reference, lookupEnv, &missing)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(value)
+	}
+
+	if len(missing.Variables) > 0 || len(missing.Files) > 0 {
+		return nil, &missing
+	}
+	return out.Bytes(), nil
+}
+
+func resolve(reference string, lookupEnv func(string) (string, bool), missing *MissingError) (string, error) {
+	if strings.HasPrefix(reference, filePrefix) {
+		path := strings.TrimPrefix(reference, filePrefix)
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			missing.Files = appendOnce(missing.Files, path)
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+
+	name, fallback, hasFallback := reference, "", false
+	if index := strings.Index(reference, ":-"); index >= 0 {
+		name, fallback, hasFallback = reference[:index], reference[index+2:], true
+	}
+	if name == "" {
+		return "", fmt.Errorf("empty reference ${%s}", reference)
+	}
+
+	value, ok := lookupEnv(name)
+	if ok && value != "" {
+		return value, nil
+	}
+	if hasFallback {
+		return fallback, nil
+	}
+	if !ok {
+		missing.Variables = appendOnce(missing.Variables, name)
+	}
+	return value, nil
+}
+
+func appendOnce(names []string, name string) []string {
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
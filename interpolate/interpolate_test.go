@@ -0,0 +1,80 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/interpolate"
+)
+
+var _ = Describe("ExpandWith", func() {
+	env := map[string]string{"PLAN_ID": "plan-123", "EMPTY": ""}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	DescribeTable("substitutions",
+		func(input, expected string) {
+			output, err := interpolate.ExpandWith([]byte(input), lookupEnv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(output)).To(Equal(expected))
+		},
+		Entry("a variable", "id: ${PLAN_ID}", "id: plan-123"),
+		Entry("a default for an unset variable", "name: ${PLAN_NAME:-small}", "name: small"),
+		Entry("a default for an empty variable", "name: ${EMPTY:-small}", "name: small"),
+		Entry("an empty variable", "name: '${EMPTY}'", "name: ''"),
+		Entry("an escaped dollar", "price: $$5 ${PLAN_ID}", "price: $5 plan-123"),
+		Entry("a dollar without braces", "price: $5", "price: $5"),
+		Entry("a trailing dollar", "price: 5$", "price: 5$"),
+	)
+
+	It("reads secret files without trailing newlines", func() {
+		dir, err := ioutil.TempDir("", "interpolate")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "password")
+		Expect(ioutil.WriteFile(path, []byte("s3cret\n"), 0600)).To(Succeed())
+
+		output, err := interpolate.ExpandWith([]byte("password: ${file:"+path+"}"), lookupEnv)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(Equal("password: s3cret"))
+	})
+
+	It("reports every missing variable and file", func() {
+		_, err := interpolate.ExpandWith([]byte("${A} ${B} ${A} ${file:/does/not/exist}"), lookupEnv)
+		Expect(err).To(MatchError("missing environment variables A, B and files /does/not/exist"))
+
+		missing, ok := err.(*interpolate.MissingError)
+		Expect(ok).To(BeTrue())
+		Expect(missing.Variables).To(Equal([]string{"A", "B"}))
+		Expect(missing.Files).To(Equal([]string{"/does/not/exist"}))
+	})
+
+	It("rejects unterminated and empty references", func() {
+		_, err := interpolate.ExpandWith([]byte("id: ${PLAN_ID"), lookupEnv)
+		Expect(err).To(MatchError("unterminated reference at offset 4"))
+
+		_, err = interpolate.ExpandWith([]byte("id: ${}"), lookupEnv)
+		Expect(err).To(MatchError("empty reference ${}"))
+	})
+})
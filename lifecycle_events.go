@@ -0,0 +1,45 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"context"
+)
+
+// WithLifecycleEventSink registers a sink for lifecycle events. It may be
+// given more than once to deliver events to several sinks.
+func WithLifecycleEventSink(sink LifecycleEventSink) Option {
+	return func(c *config) {
+		c.eventSinks = append(c.eventSinks, sink)
+	}
+}
+
+// WithPublisher registers a Publisher. Events are delivered to it in the same
+// way as to a LifecycleEventSink, except that operation.failed is skipped.
+func WithPublisher(publisher Publisher) Option {
+	return WithLifecycleEventSink(publisherSink{publisher: publisher})
+}
+
+type publisherSink struct {
+	publisher Publisher
+}
+
+func (s publisherSink) Emit(ctx context.Context, event LifecycleEvent) error {
+	if event.Type == EventOperationFailed {
+		return nil
+	}
+	return s.publisher.Publish(ctx, event)
+}
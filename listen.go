@@ -0,0 +1,125 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation.
+const listenFDsStart = 3
+
+// Listen returns a listener for address, which is one of:
+//
+//   - a TCP address, such as ":8080"
+//   - "unix:" followed by the path of a unix domain socket, such as
+//     "unix:/run/broker/broker.sock", for brokers behind a local reverse proxy.
+//     A socket left behind by a previous process is removed.
+//   - "systemd:" for the socket passed by systemd socket activation, or
+//     "systemd:name" for the one named by FileDescriptorName=name when
+//     several are passed.
+//   - "fd:" followed by the number of a file descriptor inherited from another
+//     supervisor, such as "fd:3".
+func Listen(address string) (net.Listener, error) {
+	scheme, rest, found := strings.Cut(address, ":")
+	switch {
+	case found && scheme == "unix":
+		return listenUnix(rest)
+	case found && scheme == "systemd":
+		return listenSystemd(rest)
+	case found && scheme == "fd":
+		fd, err := strconv.Atoi(rest)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor %q", rest)
+		}
+		return listenFD(fd, address)
+	}
+	return net.Listen("tcp", address)
+}
+
+// ListenAndServe serves handler with a server returned by NewServer on a
+// listener for address, as returned by Listen.
+func ListenAndServe(address string, handler http.Handler, opts ...ServerOption) error {
+	listener, err := Listen(address)
+	if err != nil {
+		return err
+	}
+	return NewServer(handler, opts...).Serve(listener)
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("the unix socket path is empty")
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// Only remove the socket if nothing answers on it, so that a second
+		// instance cannot take over that of a running one.
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+func listenSystemd(name string) (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, errors.New("the process was not started by systemd socket activation")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("systemd passed no sockets")
+	}
+
+	if name == "" {
+		if count > 1 {
+			return nil, fmt.Errorf("systemd passed %d sockets, name the one to listen on with systemd:name", count)
+		}
+		return listenFD(listenFDsStart, "systemd")
+	}
+	for i, fdName := range strings.Split(os.Getenv("LISTEN_FDNAMES"), ":") {
+		if fdName == name && i < count {
+			return listenFD(listenFDsStart+i, "systemd:"+name)
+		}
+	}
+	return nil, fmt.Errorf("systemd passed no socket named %q", name)
+}
+
+func listenFD(fd int, name string) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), name)
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	// FileListener duplicates the descriptor, so the original is closed
+	// rather than leaked to child processes.
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not a listening socket: %s", fd, err)
+	}
+	return listener, nil
+}
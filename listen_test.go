@@ -0,0 +1,207 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+)
+
+var _ = Describe("Listen", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})
+	})
+
+	get := func(client *http.Client) string {
+		response, err := client.Get("http://broker/v2/catalog")
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(body)
+	}
+
+	unixClient := func(path string) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+	}
+
+	It("listens on a TCP address", func() {
+		listener, err := brokerapi.Listen("127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		Expect(listener.Addr().Network()).To(Equal("tcp"))
+	})
+
+	Context("on a unix socket", func() {
+		var dir, path string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "brokerapi-listen")
+			Expect(err).NotTo(HaveOccurred())
+			path = filepath.Join(dir, "broker.sock")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("serves requests", func() {
+			listener, err := brokerapi.Listen("unix:" + path)
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			go http.Serve(listener, handler)
+
+			Expect(get(unixClient(path))).To(Equal("hello"))
+		})
+
+		It("replaces a socket left behind by a previous process", func() {
+			stale, err := net.Listen("unix", path)
+			Expect(err).NotTo(HaveOccurred())
+			stale.(*net.UnixListener).SetUnlinkOnClose(false)
+			stale.Close()
+
+			listener, err := brokerapi.Listen("unix:" + path)
+			Expect(err).NotTo(HaveOccurred())
+			listener.Close()
+		})
+
+		It("refuses to take over a socket in use", func() {
+			running, err := net.Listen("unix", path)
+			Expect(err).NotTo(HaveOccurred())
+			defer running.Close()
+
+			_, err = brokerapi.Listen("unix:" + path)
+			Expect(err).To(MatchError(ContainSubstring("in use")))
+		})
+
+		It("rejects an empty path", func() {
+			_, err := brokerapi.Listen("unix:")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("on an inherited file descriptor", func() {
+		It("serves requests", func() {
+			tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer tcpListener.Close()
+			file, err := tcpListener.(*net.TCPListener).File()
+			Expect(err).NotTo(HaveOccurred())
+
+			listener, err := brokerapi.Listen("fd:" + strconv.Itoa(int(file.Fd())))
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			go http.Serve(listener, handler)
+
+			address := tcpListener.Addr().String()
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "tcp", address)
+				},
+			}}
+			Expect(get(client)).To(Equal("hello"))
+		})
+
+		It("rejects a descriptor which is not a number", func() {
+			_, err := brokerapi.Listen("fd:three")
+			Expect(err).To(MatchError(ContainSubstring("invalid file descriptor")))
+		})
+
+		It("rejects a descriptor which is not a listening socket", func() {
+			file, err := ioutil.TempFile("", "brokerapi-listen")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(file.Name())
+			defer file.Close()
+
+			_, err = brokerapi.Listen("fd:" + strconv.Itoa(int(file.Fd())))
+			Expect(err).To(MatchError(ContainSubstring("not a listening socket")))
+		})
+	})
+
+	Context("with systemd socket activation", func() {
+		var restore []func()
+
+		setenv := func(key, value string) {
+			previous, found := os.LookupEnv(key)
+			Expect(os.Setenv(key, value)).To(Succeed())
+			restore = append(restore, func() {
+				if found {
+					os.Setenv(key, previous)
+				} else {
+					os.Unsetenv(key)
+				}
+			})
+		}
+
+		AfterEach(func() {
+			for _, f := range restore {
+				f()
+			}
+			restore = nil
+		})
+
+		It("fails when the process was not socket activated", func() {
+			setenv("LISTEN_PID", "1")
+			setenv("LISTEN_FDS", "1")
+
+			_, err := brokerapi.Listen("systemd:")
+			Expect(err).To(MatchError(ContainSubstring("not started by systemd")))
+		})
+
+		It("fails when no sockets were passed", func() {
+			setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+			setenv("LISTEN_FDS", "0")
+
+			_, err := brokerapi.Listen("systemd:")
+			Expect(err).To(MatchError("systemd passed no sockets"))
+		})
+
+		It("requires a name when several sockets were passed", func() {
+			setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+			setenv("LISTEN_FDS", "2")
+
+			_, err := brokerapi.Listen("systemd:")
+			Expect(err).To(MatchError(ContainSubstring("name the one to listen on")))
+		})
+
+		It("fails when no socket has the name", func() {
+			setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+			setenv("LISTEN_FDS", "2")
+			setenv("LISTEN_FDNAMES", "http:metrics")
+
+			_, err := brokerapi.Listen("systemd:admin")
+			Expect(err).To(MatchError(`systemd passed no socket named "admin"`))
+		})
+	})
+})
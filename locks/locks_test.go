@@ -0,0 +1,237 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locks_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/locks"
+)
+
+var _ = Describe("LockManagers", func() {
+	ctx := context.Background()
+
+	itSerializesLocks := func(newLockManager func() domain.LockManager) {
+		It("hands out each lock once until it is released", func() {
+			lockManager := newLockManager()
+
+			release, err := lockManager.TryLock(ctx, "instance-1")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = lockManager.TryLock(ctx, "instance-1")
+			Expect(err).To(Equal(domain.ErrLockHeld))
+
+			other, err := lockManager.TryLock(ctx, "instance-2")
+			Expect(err).NotTo(HaveOccurred())
+			other()
+
+			release()
+			release, err = lockManager.TryLock(ctx, "instance-1")
+			Expect(err).NotTo(HaveOccurred())
+			release()
+		})
+	}
+
+	Describe("Memory", func() {
+		itSerializesLocks(func() domain.LockManager { return locks.NewMemory() })
+
+		It("ignores repeated releases", func() {
+			lockManager := locks.NewMemory()
+			release, _ := lockManager.TryLock(ctx, "instance-1")
+			release()
+			again, err := lockManager.TryLock(ctx, "instance-1")
+			Expect(err).NotTo(HaveOccurred())
+
+			release()
+			_, err = lockManager.TryLock(ctx, "instance-1")
+			Expect(err).To(Equal(domain.ErrLockHeld))
+			again()
+		})
+	})
+
+	Describe("Redis", func() {
+		var client *fakeRedis
+
+		BeforeEach(func() {
+			client = &fakeRedis{values: map[string]string{}}
+		})
+
+		itSerializesLocks(func() domain.LockManager { return locks.NewRedis(client, time.Minute) })
+
+		It("sets the keys with the TTL", func() {
+			release, err := locks.NewRedis(client, time.Minute).TryLock(ctx, "instance-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.values).To(HaveKey("brokerapi:lock:instance-1"))
+			Expect(client.ttl).To(Equal(time.Minute))
+			release()
+			Expect(client.values).To(BeEmpty())
+		})
+
+		It("does not release a lock which was taken over after expiring", func() {
+			release, _ := locks.NewRedis(client, time.Minute).TryLock(ctx, "instance-1")
+			client.values["brokerapi:lock:instance-1"] = "another-replica"
+
+			release()
+			Expect(client.values).To(HaveKeyWithValue("brokerapi:lock:instance-1", "another-replica"))
+		})
+
+		It("returns the errors of the client", func() {
+			client.err = errors.New("connection refused")
+			_, err := locks.NewRedis(client, time.Minute).TryLock(ctx, "instance-1")
+			Expect(err).To(MatchError("connection refused"))
+		})
+	})
+
+	Describe("SQL", func() {
+		var db *sql.DB
+
+		BeforeEach(func() {
+			db = sql.OpenDB(fakeConnector{})
+			held = map[interface{}]bool{}
+			queries = nil
+		})
+
+		AfterEach(func() {
+			db.Close()
+		})
+
+		Describe("Postgres", func() {
+			itSerializesLocks(func() domain.LockManager { return locks.NewPostgres(db) })
+
+			It("uses advisory locks named by a hash of the key", func() {
+				release, err := locks.NewPostgres(db).TryLock(ctx, "instance-1")
+				Expect(err).NotTo(HaveOccurred())
+				release()
+
+				Expect(queries).To(HaveLen(2))
+				Expect(queries[0].query).To(Equal("SELECT pg_try_advisory_lock($1)"))
+				Expect(queries[0].arg).To(BeAssignableToTypeOf(int64(0)))
+				Expect(queries[1].query).To(Equal("SELECT pg_advisory_unlock($1)"))
+				Expect(queries[1].arg).To(Equal(queries[0].arg))
+			})
+		})
+
+		Describe("MySQL", func() {
+			itSerializesLocks(func() domain.LockManager { return locks.NewMySQL(db) })
+
+			It("uses named locks no longer than MySQL allows", func() {
+				release, err := locks.NewMySQL(db).TryLock(ctx, "an-instance-id-which-is-much-longer-than-the-sixty-four-characters-mysql-allows")
+				Expect(err).NotTo(HaveOccurred())
+				release()
+
+				Expect(queries[0].query).To(Equal("SELECT GET_LOCK(?, 0) = 1"))
+				Expect(len(queries[0].arg.(string))).To(BeNumerically("<=", 64))
+				Expect(queries[1].query).To(Equal("SELECT RELEASE_LOCK(?)"))
+			})
+		})
+	})
+})
+
+type fakeRedis struct {
+	values map[string]string
+	ttl    time.Duration
+	err    error
+}
+
+func (r *fakeRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	if _, ok := r.values[key]; ok {
+		return false, nil
+	}
+	r.values[key] = value
+	r.ttl = ttl
+	return true, nil
+}
+
+func (r *fakeRedis) DeleteIfEquals(ctx context.Context, key, value string) error {
+	if r.values[key] == value {
+		delete(r.values, key)
+	}
+	return nil
+}
+
+// The fake database keeps advisory locks shared by all its connections, and
+// records the queries it receives.
+var (
+	fakeDBMutex sync.Mutex
+	held        map[interface{}]bool
+	queries     []fakeQuery
+)
+
+type fakeQuery struct {
+	query string
+	arg   interface{}
+}
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	fakeDBMutex.Lock()
+	defer fakeDBMutex.Unlock()
+
+	arg := args[0].Value
+	queries = append(queries, fakeQuery{query: query, arg: arg})
+	acquired := !held[arg]
+	held[arg] = true
+	return &fakeRows{value: acquired}, nil
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	fakeDBMutex.Lock()
+	defer fakeDBMutex.Unlock()
+
+	arg := args[0].Value
+	queries = append(queries, fakeQuery{query: query, arg: arg})
+	delete(held, arg)
+	return driver.RowsAffected(0), nil
+}
+
+type fakeRows struct {
+	value bool
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"acquired"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
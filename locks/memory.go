@@ -0,0 +1,60 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package locks provides implementations of brokerapi.LockManager, which
+// serializes the operations on an instance when used with
+// brokerapi.WithInstanceLocking. Memory suits brokers running a single
+// process; the SQL and Redis implementations share their locks between the
+// replicas of a horizontally scaled broker.
+package locks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// Memory is a LockManager for a single broker process.
+type Memory struct {
+	mutex sync.Mutex
+	held  map[string]bool
+}
+
+// NewMemory returns a Memory with no locks held.
+func NewMemory() *Memory {
+	return &Memory{held: map[string]bool{}}
+}
+
+func (m *Memory) TryLock(ctx context.Context, key string) (func(), error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.held[key] {
+		return nil, domain.ErrLockHeld
+	}
+	m.held[key] = true
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			delete(m.held, key)
+		})
+	}, nil
+}
+
+var _ domain.LockManager = (*Memory)(nil)
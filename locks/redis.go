@@ -0,0 +1,80 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locks
+
+import (
+	"context"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+const redisKeyPrefix = "brokerapi:lock:"
+
+// RedisClient is the part of a Redis client used by Redis, so that any client
+// library can be adapted to it. With github.com/go-redis/redis:
+//
+//	func (c adapter) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+//		return c.Client.SetNX(ctx, key, value, ttl).Result()
+//	}
+//
+//	func (c adapter) DeleteIfEquals(ctx context.Context, key, value string) error {
+//		return c.Client.Eval(ctx, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`,
+//			[]string{key}, value).Err()
+//	}
+type RedisClient interface {
+	// SetNX sets key to value with the given expiry unless key exists, and
+	// reports whether it was set.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// DeleteIfEquals deletes key only if it is set to value, in one atomic
+	// step such as a Lua script.
+	DeleteIfEquals(ctx context.Context, key, value string) error
+}
+
+// Redis is a LockManager storing its locks as Redis keys. Each lock expires
+// after its TTL, so that the locks of a crashed replica are eventually freed;
+// the TTL must be longer than the slowest broker call.
+type Redis struct {
+	client RedisClient
+	ttl    time.Duration
+}
+
+// NewRedis returns a LockManager whose locks expire after ttl.
+func NewRedis(client RedisClient, ttl time.Duration) *Redis {
+	return &Redis{client: client, ttl: ttl}
+}
+
+func (r *Redis) TryLock(ctx context.Context, key string) (func(), error) {
+	redisKey := redisKeyPrefix + key
+	// The token makes sure a replica never releases a lock which expired
+	// and was taken by another one in the meantime.
+	token := uuid.NewRandom().String()
+
+	acquired, err := r.client.SetNX(ctx, redisKey, token, r.ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, domain.ErrLockHeld
+	}
+
+	return func() {
+		r.client.DeleteIfEquals(context.Background(), redisKey, token)
+	}, nil
+}
+
+var _ domain.LockManager = (*Redis)(nil)
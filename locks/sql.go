@@ -0,0 +1,103 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locks
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// SQL is a LockManager backed by the advisory locks of a PostgreSQL or MySQL
+// database. Advisory locks belong to a database session, so each lock held
+// keeps a connection of the pool busy until it is released, and locks are
+// released by the database if the broker loses its connection.
+type SQL struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+type sqlDialect struct {
+	lock   string
+	unlock string
+	key    func(key string) interface{}
+}
+
+var (
+	postgresDialect = sqlDialect{
+		lock:   "SELECT pg_try_advisory_lock($1)",
+		unlock: "SELECT pg_advisory_unlock($1)",
+		key:    postgresKey,
+	}
+	mysqlDialect = sqlDialect{
+		lock:   "SELECT GET_LOCK(?, 0) = 1",
+		unlock: "SELECT RELEASE_LOCK(?)",
+		key:    mysqlKey,
+	}
+)
+
+// NewPostgres returns a LockManager using pg_try_advisory_lock. Keys are
+// hashed to the 64-bit integers PostgreSQL uses to name advisory locks.
+func NewPostgres(db *sql.DB) *SQL {
+	return &SQL{db: db, dialect: postgresDialect}
+}
+
+// NewMySQL returns a LockManager using GET_LOCK. Keys are hashed to fit the
+// 64 characters MySQL allows in a lock name.
+func NewMySQL(db *sql.DB) *SQL {
+	return &SQL{db: db, dialect: mysqlDialect}
+}
+
+func (s *SQL) TryLock(ctx context.Context, key string) (func(), error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := s.dialect.key(key)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, s.dialect.lock, name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, domain.ErrLockHeld
+	}
+
+	return func() {
+		// The request context may be done by now, but the lock still has
+		// to be released before the connection returns to the pool.
+		conn.ExecContext(context.Background(), s.dialect.unlock, name)
+		conn.Close()
+	}, nil
+}
+
+func postgresKey(key string) interface{} {
+	sum := sha256.Sum256([]byte(key))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+func mysqlKey(key string) interface{} {
+	sum := sha256.Sum256([]byte(key))
+	return "brokerapi:" + hex.EncodeToString(sum[:24])
+}
+
+var _ domain.LockManager = (*SQL)(nil)
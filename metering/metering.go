@@ -0,0 +1,107 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metering has a reference domain.Meter, writing usage records to a
+// file or any other io.Writer for a billing system to pick up.
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// Format is the encoding of the records written by a Writer.
+type Format int
+
+const (
+	// JSONLines writes each record as a JSON object on its own line.
+	JSONLines Format = iota
+	// CSV writes each record as a row of the columns in CSVHeader, after a
+	// header row.
+	CSV
+)
+
+// CSVHeader lists the columns of the CSV format.
+var CSVHeader = []string{"type", "time", "instance_id", "service_id", "plan_id", "organization_guid", "space_guid"}
+
+var _ domain.Meter = (*Writer)(nil)
+
+// Writer is a domain.Meter writing usage records to an io.Writer. It is safe
+// for concurrent use, and writes each record whole.
+type Writer struct {
+	format Format
+
+	mutex       sync.Mutex
+	out         io.Writer
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+// NewWriter returns a Writer encoding records to out in format. CSV output
+// starts with a header row, so out should be empty.
+func NewWriter(out io.Writer, format Format) *Writer {
+	w := &Writer{format: format, out: out}
+	if format == CSV {
+		w.csv = csv.NewWriter(out)
+	}
+	return w
+}
+
+// RecordUsage writes record.
+func (w *Writer) RecordUsage(ctx context.Context, record domain.UsageRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.format == CSV {
+		return w.writeCSV(record)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(append(line, '\n'))
+	return err
+}
+
+func (w *Writer) writeCSV(record domain.UsageRecord) error {
+	if !w.wroteHeader {
+		if err := w.csv.Write(CSVHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	row := []string{
+		string(record.Type),
+		record.Time.Format(time.RFC3339Nano),
+		record.InstanceID,
+		record.ServiceID,
+		record.PlanID,
+		record.OrganizationGUID,
+		record.SpaceGUID,
+	}
+	if err := w.csv.Write(row); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering_test
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/metering"
+)
+
+var _ = Describe("Writer", func() {
+	var (
+		ctx    context.Context
+		out    *bytes.Buffer
+		record domain.UsageRecord
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		out = &bytes.Buffer{}
+		record = domain.UsageRecord{
+			Type:             domain.UsageStarted,
+			Time:             time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			InstanceID:       "instance-id",
+			ServiceID:        "service-id",
+			PlanID:           "plan-id",
+			OrganizationGUID: "org-guid",
+			SpaceGUID:        "space-guid",
+		}
+	})
+
+	It("writes JSON lines", func() {
+		writer := metering.NewWriter(out, metering.JSONLines)
+		Expect(writer.RecordUsage(ctx, record)).To(Succeed())
+		record.Type = domain.UsageStopped
+		record.OrganizationGUID, record.SpaceGUID = "", ""
+		Expect(writer.RecordUsage(ctx, record)).To(Succeed())
+
+		Expect(out.String()).To(Equal(
+			`{"type":"start","time":"2020-01-02T03:04:05Z","instance_id":"instance-id","service_id":"service-id","plan_id":"plan-id","organization_guid":"org-guid","space_guid":"space-guid"}` + "\n" +
+				`{"type":"stop","time":"2020-01-02T03:04:05Z","instance_id":"instance-id","service_id":"service-id","plan_id":"plan-id"}` + "\n",
+		))
+	})
+
+	It("writes CSV after a header row", func() {
+		writer := metering.NewWriter(out, metering.CSV)
+		Expect(writer.RecordUsage(ctx, record)).To(Succeed())
+		record.Type = domain.UsageStopped
+		Expect(writer.RecordUsage(ctx, record)).To(Succeed())
+
+		Expect(out.String()).To(Equal("" +
+			"type,time,instance_id,service_id,plan_id,organization_guid,space_guid\n" +
+			"start,2020-01-02T03:04:05Z,instance-id,service-id,plan-id,org-guid,space-guid\n" +
+			"stop,2020-01-02T03:04:05Z,instance-id,service-id,plan-id,org-guid,space-guid\n",
+		))
+	})
+})
@@ -0,0 +1,187 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package access_log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+// Format selects how access log entries are written.
+type Format int
+
+const (
+	// CombinedFormat writes NCSA combined log format lines, followed by the
+	// request duration in seconds and the route's path template.
+	CombinedFormat Format = iota
+	// JSONFormat writes one JSON object per line.
+	JSONFormat
+)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Entry is the information recorded about each request.
+type Entry struct {
+	Time         time.Time `json:"time"`
+	ClientIP     string    `json:"client_ip"`
+	User         string    `json:"user,omitempty"`
+	Method       string    `json:"method"`
+	URI          string    `json:"uri"`
+	PathTemplate string    `json:"path_template,omitempty"`
+	Proto        string    `json:"proto"`
+	Status       int       `json:"status"`
+	Bytes        int64     `json:"bytes"`
+	Duration     float64   `json:"duration_seconds"`
+	Referer      string    `json:"referer,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+}
+
+// Logger writes an access log entry for every request it wraps. When added
+// to the broker router, entries include the path template of the matched
+// route, such as "/v2/service_instances/{instance_id}", so that requests can
+// be grouped by endpoint. The client IP is the one resolved by a
+// client_ip.Resolver, if one runs before the Logger.
+type Logger struct {
+	out    io.Writer
+	format Format
+
+	mutex sync.Mutex
+}
+
+func New(out io.Writer, format Format) *Logger {
+	return &Logger{
+		out:    out,
+		format: format,
+	}
+}
+
+func (l *Logger) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := statusrecorder.New(w)
+
+		next.ServeHTTP(recorder, req)
+
+		entry := Entry{
+			Time:      start,
+			ClientIP:  client_ip.FromRequest(req),
+			Method:    req.Method,
+			URI:       req.RequestURI,
+			Proto:     req.Proto,
+			Status:    recorder.Status(),
+			Bytes:     recorder.Bytes(),
+			Duration:  time.Since(start).Seconds(),
+			Referer:   req.Referer(),
+			UserAgent: req.UserAgent(),
+		}
+		if entry.URI == "" {
+			entry.URI = req.URL.RequestURI()
+		}
+		if user, _, ok := req.BasicAuth(); ok {
+			entry.User = user
+		}
+		if route := mux.CurrentRoute(req); route != nil {
+			entry.PathTemplate = pathTemplate(route)
+		}
+
+		l.write(entry)
+	})
+}
+
+func (l *Logger) write(entry Entry) {
+	var line []byte
+	switch l.format {
+	case JSONFormat:
+		line, _ = json.Marshal(entry)
+		line = append(line, '\n')
+	default:
+		line = []byte(fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d %s %s %.6f %s\n",
+			entry.ClientIP,
+			orDash(entry.User),
+			entry.Time.Format(combinedTimeLayout),
+			entry.Method,
+			entry.URI,
+			entry.Proto,
+			entry.Status,
+			entry.Bytes,
+			strconv.Quote(orDash(entry.Referer)),
+			strconv.Quote(orDash(entry.UserAgent)),
+			entry.Duration,
+			strconv.Quote(orDash(entry.PathTemplate)),
+		))
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.out.Write(line)
+}
+
+// pathTemplate falls back to the template of the broker operation the route is
+// named after, as the routes of brokerapi.NewWithOptions match paths without
+// a mux path template.
+func pathTemplate(route *mux.Route) string {
+	if template, err := route.GetPathTemplate(); err == nil {
+		return trimPatterns(template)
+	}
+	return domain.Operation(route.GetName()).PathTemplate()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// trimPatterns removes the patterns of the variables in a mux path template,
+// so that "/v2/service_instances/{instance_id:[a-z0-9-]+}" is logged as
+// "/v2/service_instances/{instance_id}". Patterns may contain braces of their
+// own, as in "{id:[0-9]{3}}".
+func trimPatterns(template string) string {
+	var (
+		trimmed   strings.Builder
+		depth     int
+		inPattern bool
+	)
+	for _, r := range template {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+			if depth == 0 {
+				inPattern = false
+			}
+		case r == ':' && depth == 1:
+			inPattern = true
+		}
+		if !inPattern {
+			trimmed.WriteRune(r)
+		}
+	}
+	return trimmed.String()
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package access_log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/fakes"
+	"github.com/sharma-tapas/brokerapi/middlewares/access_log"
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+)
+
+var _ = Describe("Access log", func() {
+	var (
+		out    *bytes.Buffer
+		router *mux.Router
+	)
+
+	serve := func(format access_log.Format) {
+		router = mux.NewRouter()
+		router.HandleFunc("/v2/service_instances/{instance_id}", func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"dashboard_url":"x"}`))
+		}).Methods("PUT")
+		router.Use(access_log.New(out, format).Wrap)
+
+		request := httptest.NewRequest("PUT", "/v2/service_instances/some-instance?accepts_incomplete=true", nil)
+		request.RemoteAddr = "10.0.0.1:51234"
+		request.Header.Set("User-Agent", "cloud-controller")
+		request.SetBasicAuth("admin", "secret")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+	})
+
+	It("writes combined log format lines", func() {
+		serve(access_log.CombinedFormat)
+
+		Expect(out.String()).To(MatchRegexp(
+			`^10\.0\.0\.1 - admin \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "PUT /v2/service_instances/some-instance\?accepts_incomplete=true HTTP/1\.1" 201 21 "-" "cloud-controller" \d+\.\d{6} "/v2/service_instances/\{instance_id\}"\n$`,
+		))
+	})
+
+	It("writes JSON lines", func() {
+		serve(access_log.JSONFormat)
+
+		var entry access_log.Entry
+		Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+		Expect(entry.ClientIP).To(Equal("10.0.0.1"))
+		Expect(entry.User).To(Equal("admin"))
+		Expect(entry.Method).To(Equal("PUT"))
+		Expect(entry.PathTemplate).To(Equal("/v2/service_instances/{instance_id}"))
+		Expect(entry.Status).To(Equal(http.StatusCreated))
+		Expect(entry.Bytes).To(Equal(int64(21)))
+		Expect(entry.Duration).To(BeNumerically(">=", 0))
+	})
+
+	It("defaults the status to 200 when the handler only writes a body", func() {
+		handler := access_log.New(out, access_log.JSONFormat).Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/catalog", nil))
+
+		var entry access_log.Entry
+		Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+		Expect(entry.Status).To(Equal(http.StatusOK))
+		Expect(entry.PathTemplate).To(BeEmpty())
+		Expect(entry.URI).To(Equal("/v2/catalog"))
+	})
+
+	It("logs the client IP resolved from trusted proxies", func() {
+		resolver, err := client_ip.NewResolver("10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+		handler := resolver.AddToContext(access_log.New(out, access_log.JSONFormat).Wrap(http.NotFoundHandler()))
+
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request.RemoteAddr = "10.0.0.1:51234"
+		request.Header.Set("X-Forwarded-For", "198.51.100.1")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		var entry access_log.Entry
+		Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+		Expect(entry.ClientIP).To(Equal("198.51.100.1"))
+	})
+
+	It("logs path templates without the patterns of their variables", func() {
+		router := mux.NewRouter()
+		router.Handle("/v2/service_instances/{instance_id:[a-z0-9-]+}/service_bindings/{binding_id:[0-9]{3}}", http.NotFoundHandler())
+		router.Use(access_log.New(out, access_log.JSONFormat).Wrap)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/service_instances/some-instance/service_bindings/123", nil))
+
+		var entry access_log.Entry
+		Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+		Expect(entry.PathTemplate).To(Equal("/v2/service_instances/{instance_id}/service_bindings/{binding_id}"))
+	})
+
+	It("logs the path template of the broker routes", func() {
+		brokerAPI := brokerapi.NewWithOptions(new(fakes.AutoFakeServiceBroker), lagertest.NewTestLogger("access-log"),
+			brokerapi.WithMiddleware(access_log.New(out, access_log.JSONFormat).Wrap),
+		)
+		brokertest.New(brokerAPI, "", "").LastOperation("some-instance", "")
+
+		var entry access_log.Entry
+		Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+		Expect(entry.PathTemplate).To(Equal("/v2/service_instances/{instance_id}/last_operation"))
+	})
+})
@@ -0,0 +1,210 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client_ip determines the address of the client which made a request
+// when the broker runs behind proxies such as gorouter or an ingress controller.
+package client_ip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+// Header is a forwarding header which proxies add the address of their
+// client to.
+type Header string
+
+const (
+	// XForwardedFor is the X-Forwarded-For header written by gorouter, nginx
+	// and most load balancers.
+	XForwardedFor Header = "X-Forwarded-For"
+	// Forwarded is the standard Forwarded header of RFC 7239.
+	Forwarded Header = "Forwarded"
+)
+
+// Resolver derives the client IP from a forwarding header, trusting it only
+// when the request arrives from a trusted proxy.
+type Resolver struct {
+	trustedProxies []*net.IPNet
+	header         Header
+}
+
+// NewResolver returns a Resolver trusting the given proxies, each given as a
+// CIDR such as "10.0.0.0/8" or a single IP address, to write X-Forwarded-For.
+// With no trusted proxies the forwarding headers are ignored and the peer
+// address is used.
+func NewResolver(trustedProxies ...string) (*Resolver, error) {
+	networks, err := ParseNetworks(trustedProxies...)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{trustedProxies: networks, header: XForwardedFor}, nil
+}
+
+// WithHeader sets the forwarding header the trusted proxies write. Only that
+// header is read: the other one is passed on by the proxies as the client
+// sent it, so its addresses are whatever the client chose.
+func (r *Resolver) WithHeader(header Header) *Resolver {
+	r.header = header
+	return r
+}
+
+// ClientIP returns the address of the client. Forwarded addresses are read
+// from right to left, skipping trusted proxies, so that a client cannot spoof
+// its address by sending its own forwarding headers.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	remote := peerIP(req)
+	if !r.trusted(remote) {
+		return remote
+	}
+
+	hops := r.forwardedFor(req.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.trusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return remote
+}
+
+// AddToContext stores the client IP in the request context, where it can be
+// read with FromRequest or FromContext by later middlewares and the broker.
+func (r *Resolver) AddToContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		newCtx := contextkeys.WithClientIP(req.Context(), r.ClientIP(req))
+		next.ServeHTTP(w, req.WithContext(newCtx))
+	})
+}
+
+// FromContext returns the client IP stored by AddToContext, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	return contextkeys.ClientIP(ctx)
+}
+
+// FromRequest returns the client IP stored by AddToContext, falling back to
+// the address of the peer when no Resolver is in use.
+func FromRequest(req *http.Request) string {
+	if ip, ok := FromContext(req.Context()); ok {
+		return ip
+	}
+	return peerIP(req)
+}
+
+func (r *Resolver) trusted(ip string) bool {
+	return Contains(r.trustedProxies, ip)
+}
+
+// ParseNetworks parses a list of CIDRs, such as "10.0.0.0/8", and single IP
+// addresses, which are treated as networks containing only that address.
+func ParseNetworks(values ...string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		network, err := parseNetwork(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Contains reports whether ip is in any of the networks.
+func Contains(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %s", value, err)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %q", value)
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func peerIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the addresses forwarded in the header of the
+// Resolver, client first.
+func (r *Resolver) forwardedFor(header http.Header) []string {
+	var hops []string
+	if r.header == Forwarded {
+		for _, value := range header["Forwarded"] {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					pair = strings.TrimSpace(pair)
+					if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+						hops = append(hops, normalizeNode(pair[4:]))
+					}
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range header["X-Forwarded-For"] {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, normalizeNode(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// normalizeNode strips the quotes, brackets and port that may surround an
+// address, e.g. "[2001:db8::17]:4711" or 192.0.2.60:8080.
+func normalizeNode(node string) string {
+	node = strings.Trim(node, `"`)
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
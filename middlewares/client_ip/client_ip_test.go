@@ -0,0 +1,102 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_ip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+)
+
+var _ = Describe("Resolver", func() {
+	var resolver *client_ip.Resolver
+
+	BeforeEach(func() {
+		var err error
+		resolver, err = client_ip.NewResolver("10.0.0.0/8", "192.168.1.1", "fd00::/8")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	newRequest := func(remoteAddr string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest("GET", "/v2/catalog", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+
+	DescribeTable("ClientIP",
+		func(remoteAddr string, headers map[string]string, expected string) {
+			Expect(resolver.ClientIP(newRequest(remoteAddr, headers))).To(Equal(expected))
+		},
+		Entry("uses the peer address without forwarding headers",
+			"203.0.113.7:5000", nil, "203.0.113.7"),
+		Entry("ignores forwarding headers from untrusted peers",
+			"203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"),
+		Entry("uses X-Forwarded-For from trusted peers",
+			"10.1.2.3:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"),
+		Entry("skips trusted proxies from the right",
+			"10.1.2.3:5000", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 192.168.1.1, 10.9.9.9"}, "198.51.100.1"),
+		Entry("uses the leftmost address when every hop is trusted",
+			"10.1.2.3:5000", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.6"}, "10.0.0.5"),
+		Entry("ignores a Forwarded header sent by the client",
+			"10.1.2.3:5000", map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"),
+	)
+
+	DescribeTable("ClientIP with the Forwarded header",
+		func(remoteAddr string, headers map[string]string, expected string) {
+			resolver.WithHeader(client_ip.Forwarded)
+			Expect(resolver.ClientIP(newRequest(remoteAddr, headers))).To(Equal(expected))
+		},
+		Entry("reads the Forwarded header",
+			"10.1.2.3:5000", map[string]string{"Forwarded": `for=198.51.100.2;proto=https, for="10.0.0.9:8080"`}, "198.51.100.2"),
+		Entry("parses bracketed IPv6 addresses",
+			"[fd00::1]:5000", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"),
+		Entry("ignores an X-Forwarded-For header sent by the client",
+			"10.1.2.3:5000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "10.1.2.3"),
+	)
+
+	It("rejects invalid proxies", func() {
+		_, err := client_ip.NewResolver("not-an-ip")
+		Expect(err).To(MatchError(ContainSubstring(`invalid network "not-an-ip"`)))
+
+		_, err = client_ip.NewResolver("10.0.0.0/33")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("adds the client IP to the request context", func() {
+		var clientIP string
+		handler := resolver.AddToContext(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			clientIP = client_ip.FromRequest(req)
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("10.1.2.3:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}))
+		Expect(clientIP).To(Equal("198.51.100.1"))
+	})
+
+	It("falls back to the peer address without a resolver", func() {
+		req := newRequest("203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"})
+		Expect(client_ip.FromRequest(req)).To(Equal("203.0.113.7"))
+
+		_, ok := client_ip.FromContext(req.Context())
+		Expect(ok).To(BeFalse())
+	})
+})
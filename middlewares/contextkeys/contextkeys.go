@@ -0,0 +1,219 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contextkeys holds the values the middlewares store in the request
+// context. Each value has a setter and a getter, and the keys are of an
+// unexported type, so that middlewares and brokers cannot overwrite each
+// other's values by choosing the same string.
+package contextkeys
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+)
+
+type key int
+
+const (
+	regionKey key = iota
+	apiVersionKey
+	requestIDKey
+	originatingIdentityKey
+	tenantKey
+	clientIPKey
+	pathPrefixKey
+	clientCertificateKey
+	platformKey
+	principalKey
+	anonymousKey
+)
+
+// WithRegion returns a copy of ctx holding the region the platform sent in a
+// X-*-Region header.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey, region)
+}
+
+// Region returns the region stored by WithRegion.
+func Region(ctx context.Context) (string, bool) {
+	return stringValue(ctx, regionKey)
+}
+
+// WithAPIVersion returns a copy of ctx holding the X-Broker-API-Version of the
+// request.
+func WithAPIVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, apiVersionKey, version)
+}
+
+// APIVersion returns the version stored by WithAPIVersion.
+func APIVersion(ctx context.Context) (string, bool) {
+	return stringValue(ctx, apiVersionKey)
+}
+
+// WithRequestID returns a copy of ctx holding the X-Broker-API-Request-Identity
+// of the request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request identity stored by WithRequestID.
+func RequestID(ctx context.Context) (string, bool) {
+	return stringValue(ctx, requestIDKey)
+}
+
+// WithOriginatingIdentity returns a copy of ctx holding the
+// X-Broker-API-Originating-Identity of the request, as sent by the platform.
+func WithOriginatingIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, originatingIdentityKey, identity)
+}
+
+// OriginatingIdentity returns the identity stored by WithOriginatingIdentity.
+func OriginatingIdentity(ctx context.Context) (string, bool) {
+	return stringValue(ctx, originatingIdentityKey)
+}
+
+// WithTenant returns a copy of ctx holding the tenant the request is made for.
+// The Open Service Broker API has no tenant header, so it is up to a
+// middleware of the broker to derive one, for example from the platform
+// credentials.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant returns the tenant stored by WithTenant.
+func Tenant(ctx context.Context) (string, bool) {
+	return stringValue(ctx, tenantKey)
+}
+
+// WithClientIP returns a copy of ctx holding the address of the client.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIP returns the address stored by WithClientIP.
+func ClientIP(ctx context.Context) (string, bool) {
+	return stringValue(ctx, clientIPKey)
+}
+
+// WithPathPrefix returns a copy of ctx holding the prefix which was stripped
+// from the path of the request, when the broker is served behind a reverse
+// proxy under a path of its own.
+func WithPathPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, pathPrefixKey, prefix)
+}
+
+// PathPrefix returns the prefix stored by WithPathPrefix.
+func PathPrefix(ctx context.Context) (string, bool) {
+	return stringValue(ctx, pathPrefixKey)
+}
+
+// ExternalPath returns the path of req as sent by the client, including any
+// prefix stored by WithPathPrefix.
+func ExternalPath(req *http.Request) string {
+	prefix, _ := PathPrefix(req.Context())
+	return prefix + req.URL.Path
+}
+
+// WithPlatform returns a copy of ctx holding the platform making the request,
+// such as "cloudfoundry" or "kubernetes".
+func WithPlatform(ctx context.Context, platform string) context.Context {
+	return context.WithValue(ctx, platformKey, platform)
+}
+
+// Platform returns the platform stored by WithPlatform.
+func Platform(ctx context.Context) (string, bool) {
+	return stringValue(ctx, platformKey)
+}
+
+// WithClientCertificate returns a copy of ctx holding the verified certificate
+// the client presented over mutual TLS.
+func WithClientCertificate(ctx context.Context, certificate *x509.Certificate) context.Context {
+	return context.WithValue(ctx, clientCertificateKey, certificate)
+}
+
+// ClientCertificate returns the certificate stored by WithClientCertificate.
+// Its Subject and subject alternative names, such as DNSNames and URIs,
+// identify the platform making the request.
+func ClientCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	certificate, ok := ctx.Value(clientCertificateKey).(*x509.Certificate)
+	return certificate, ok
+}
+
+// WithPrincipal returns a copy of ctx holding the identity the credentials of
+// the request were checked for, such as the basic auth username accepted by an
+// auth.Wrapper. Authentication middlewares of other kinds, such as those
+// verifying bearer tokens, should store the subject they verified.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// Principal returns the identity stored by WithPrincipal. It is only set
+// once the request is authenticated, so middlewares running before
+// authentication never see it.
+func Principal(ctx context.Context) (string, bool) {
+	return stringValue(ctx, principalKey)
+}
+
+// WithAnonymous returns a copy of ctx marking the request as served without
+// authentication, such as a request for a public catalog, so that handlers
+// leave out what only authenticated clients may see.
+func WithAnonymous(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousKey, true)
+}
+
+// Anonymous reports whether the request was marked by WithAnonymous.
+func Anonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousKey).(bool)
+	return anonymous
+}
+
+func stringValue(ctx context.Context, k key) (string, bool) {
+	value, ok := ctx.Value(k).(string)
+	return value, ok
+}
+
+// AddToContext stores the X-Broker-API-Version and
+// X-Broker-API-Request-Identity headers of the request in its context, and
+// the client certificate when the TLS handshake verified one. Certificates
+// which were presented but not verified are ignored. The platform is taken
+// from the X-Broker-API-Originating-Identity header or, for Cloud Foundry
+// requests without one, from the X-Api-Info-Location header; the handlers
+// replace it with the platform of the context object of the request body.
+func AddToContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithAPIVersion(req.Context(), req.Header.Get("X-Broker-API-Version"))
+		ctx = WithRequestID(ctx, req.Header.Get("X-Broker-API-Request-Identity"))
+		if platform := headerPlatform(req.Header); platform != "" {
+			ctx = WithPlatform(ctx, platform)
+		}
+		if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+			ctx = WithClientCertificate(ctx, req.TLS.VerifiedChains[0][0])
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func headerPlatform(header http.Header) string {
+	if identity := strings.TrimSpace(header.Get("X-Broker-API-Originating-Identity")); identity != "" {
+		platform, _, _ := strings.Cut(identity, " ")
+		return platform
+	}
+	if header.Get("X-Api-Info-Location") != "" {
+		return "cloudfoundry"
+	}
+	return ""
+}
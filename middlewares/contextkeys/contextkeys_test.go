@@ -0,0 +1,160 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contextkeys_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+var _ = Describe("Context keys", func() {
+	It("keeps each value apart", func() {
+		ctx := contextkeys.WithRegion(context.Background(), "eu")
+		ctx = contextkeys.WithAPIVersion(ctx, "2.14")
+		ctx = contextkeys.WithRequestID(ctx, "request-id")
+		ctx = contextkeys.WithOriginatingIdentity(ctx, "cloudfoundry e30=")
+		ctx = contextkeys.WithTenant(ctx, "tenant")
+		ctx = contextkeys.WithClientIP(ctx, "10.0.0.1")
+		ctx = contextkeys.WithPathPrefix(ctx, "/broker-name")
+		ctx = contextkeys.WithPlatform(ctx, "kubernetes")
+
+		values := []struct {
+			get      func(context.Context) (string, bool)
+			expected string
+		}{
+			{contextkeys.Region, "eu"},
+			{contextkeys.APIVersion, "2.14"},
+			{contextkeys.RequestID, "request-id"},
+			{contextkeys.OriginatingIdentity, "cloudfoundry e30="},
+			{contextkeys.Tenant, "tenant"},
+			{contextkeys.ClientIP, "10.0.0.1"},
+			{contextkeys.PathPrefix, "/broker-name"},
+			{contextkeys.Platform, "kubernetes"},
+		}
+		for _, value := range values {
+			actual, ok := value.get(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(actual).To(Equal(value.expected))
+		}
+	})
+
+	It("adds the stripped prefix to the external path", func() {
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		Expect(contextkeys.ExternalPath(request)).To(Equal("/v2/catalog"))
+
+		request = request.WithContext(contextkeys.WithPathPrefix(request.Context(), "/broker-name"))
+		Expect(contextkeys.ExternalPath(request)).To(Equal("/broker-name/v2/catalog"))
+	})
+
+	It("does not collide with string keys", func() {
+		ctx := context.WithValue(context.Background(), "X-Region", "eu")
+
+		_, ok := contextkeys.Region(ctx)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("stores the API version and request identity headers", func() {
+		var ctx context.Context
+		handler := contextkeys.AddToContext(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx = req.Context()
+		}))
+
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request.Header.Set("X-Broker-API-Version", "2.15")
+		request.Header.Set("X-Broker-API-Request-Identity", "request-id")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		version, _ := contextkeys.APIVersion(ctx)
+		Expect(version).To(Equal("2.15"))
+		requestID, _ := contextkeys.RequestID(ctx)
+		Expect(requestID).To(Equal("request-id"))
+	})
+
+	It("stores the platform of the originating identity", func() {
+		var ctx context.Context
+		handler := contextkeys.AddToContext(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx = req.Context()
+		}))
+
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request.Header.Set("X-Broker-API-Originating-Identity", "kubernetes e30=")
+		request.Header.Set("X-Api-Info-Location", "api.example.com/v2/info")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		platform, _ := contextkeys.Platform(ctx)
+		Expect(platform).To(Equal("kubernetes"))
+
+		request.Header.Del("X-Broker-API-Originating-Identity")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		platform, _ = contextkeys.Platform(ctx)
+		Expect(platform).To(Equal("cloudfoundry"))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/catalog", nil))
+		_, ok := contextkeys.Platform(ctx)
+		Expect(ok).To(BeFalse())
+	})
+
+	Describe("client certificates", func() {
+		var (
+			ctx     context.Context
+			handler http.Handler
+		)
+
+		BeforeEach(func() {
+			handler = contextkeys.AddToContext(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				ctx = req.Context()
+			}))
+		})
+
+		It("stores the leaf of the verified chain", func() {
+			leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "platform"}, DNSNames: []string{"platform.example.com"}}
+			request := httptest.NewRequest("GET", "/v2/catalog", nil)
+			request.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, {}}},
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			certificate, ok := contextkeys.ClientCertificate(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(certificate.Subject.CommonName).To(Equal("platform"))
+			Expect(certificate.DNSNames).To(ConsistOf("platform.example.com"))
+		})
+
+		It("ignores certificates which were not verified", func() {
+			request := httptest.NewRequest("GET", "/v2/catalog", nil)
+			request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			_, ok := contextkeys.ClientCertificate(ctx)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("stores nothing for plain HTTP requests", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/catalog", nil))
+
+			_, ok := contextkeys.ClientCertificate(ctx)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,222 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug_dump logs the full requests a broker receives and the
+// responses it sends, to troubleshoot platform integrations. Dumping is off
+// until enabled, and can be switched on and off while the broker runs.
+// Authentication headers, binding credentials and secret parameters are
+// redacted from the dumps.
+package debug_dump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/recording"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+// Redacted replaces the values removed from a dump.
+const Redacted = recording.Redacted
+
+const (
+	dumpKey = "request-dump"
+
+	// defaultMaxBodySize limits the part of each body in a dump.
+	defaultMaxBodySize = 64 * 1024
+)
+
+var (
+	defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	defaultRedactedFields  = []string{"credentials"}
+)
+
+// Dumper logs the requests it wraps and their responses while it is enabled.
+// It is safe for concurrent use.
+type Dumper struct {
+	logger      lager.Logger
+	enabled     int32
+	redactor    *recording.Redactor
+	maxBodySize int
+}
+
+// New returns a disabled Dumper logging to logger. The Authorization,
+// Proxy-Authorization, Cookie and Set-Cookie headers, and the credentials
+// of bindings, are redacted.
+func New(logger lager.Logger) *Dumper {
+	redactor := recording.NewRedactor().
+		WithRedactedHeaders(defaultRedactedHeaders...).
+		WithRedactedFields(defaultRedactedFields...)
+	return &Dumper{
+		logger:      logger.Session("debug-dump"),
+		redactor:    redactor,
+		maxBodySize: defaultMaxBodySize,
+	}
+}
+
+// WithRedactedHeaders also redacts the given request and response headers.
+func (d *Dumper) WithRedactedHeaders(headers ...string) *Dumper {
+	d.redactor.WithRedactedHeaders(headers...)
+	return d
+}
+
+// WithRedactedFields also redacts the given fields wherever they appear in
+// JSON request and response bodies.
+func (d *Dumper) WithRedactedFields(fields ...string) *Dumper {
+	d.redactor.WithRedactedFields(fields...)
+	return d
+}
+
+// WithSecretParameters redacts the given parameters from the parameters of
+// requests and responses. See SecretParameters for the parameters a catalog
+// flags as secret.
+func (d *Dumper) WithSecretParameters(names ...string) *Dumper {
+	d.redactor.WithSecretParameters(names...)
+	return d
+}
+
+// WithMaxBodySize sets how much of each body is dumped, 64 KiB by default.
+// No more than that of a body is held in memory.
+func (d *Dumper) WithMaxBodySize(size int) *Dumper {
+	d.maxBodySize = size
+	return d
+}
+
+// SetEnabled switches dumping on or off.
+func (d *Dumper) SetEnabled(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&d.enabled, value)
+}
+
+// Enabled reports whether requests are dumped.
+func (d *Dumper) Enabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+// Wrap dumps the requests handled by next while the Dumper is enabled.
+// Requests are passed on untouched while it is disabled.
+func (d *Dumper) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !d.Enabled() {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Only the part of the body which is dumped is copied; the handler
+		// reads the rest from the original body.
+		var requestBody []byte
+		if req.Body != nil {
+			requestBody, _ = ioutil.ReadAll(io.LimitReader(req.Body, int64(d.maxBodySize)+1))
+			req.Body = prefixedBody{
+				Reader: io.MultiReader(bytes.NewReader(requestBody), req.Body),
+				Closer: req.Body,
+			}
+		}
+
+		recorder := statusrecorder.New(w).CaptureBody(d.maxBodySize + 1)
+		next.ServeHTTP(recorder, req)
+
+		uri := req.RequestURI
+		if uri == "" {
+			uri = req.URL.RequestURI()
+		}
+		d.logger.Info(dumpKey, lager.Data{
+			"request": lager.Data{
+				"method": req.Method,
+				"uri":    uri,
+				"header": d.redactor.Header(req.Header),
+				"body":   d.dumpBody(req.Header, requestBody),
+			},
+			"response": lager.Data{
+				"status": recorder.Status(),
+				"header": d.redactor.Header(w.Header()),
+				"body":   d.dumpBody(w.Header(), recorder.Body()),
+			},
+		})
+	})
+}
+
+// dumpBody redacts body, which holds at most one byte more than the maximum
+// body size. Longer bodies are cut short, but a cut JSON body cannot be
+// parsed to redact it: unless its Content-Type says it is something other
+// than JSON, it is left out of the dump.
+func (d *Dumper) dumpBody(header http.Header, body []byte) string {
+	if len(body) <= d.maxBodySize {
+		return string(d.redactor.Body(body))
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" || strings.Contains(contentType, "json") {
+		return fmt.Sprintf("[body over %d bytes not dumped]", d.maxBodySize)
+	}
+	return string(body[:d.maxBodySize]) + "..."
+}
+
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// SecretParameters returns the names of the parameters the plan schemas of
+// services flag as secret, with "writeOnly": true or "format": "password".
+func SecretParameters(services []domain.Service) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, service := range services {
+		for _, plan := range service.Plans {
+			if plan.Schemas == nil {
+				continue
+			}
+			for _, schema := range []domain.Schema{
+				plan.Schemas.Instance.Create,
+				plan.Schemas.Instance.Update,
+				plan.Schemas.Binding.Create,
+			} {
+				for _, name := range secretProperties(schema.Parameters) {
+					if !seen[name] {
+						seen[name] = true
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func secretProperties(schema map[string]interface{}) []string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	var names []string
+	for name, property := range properties {
+		property, ok := property.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if property["writeOnly"] == true || property["format"] == "password" {
+			names = append(names, name)
+		}
+		names = append(names, secretProperties(property)...)
+	}
+	return names
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug_dump_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/middlewares/debug_dump"
+)
+
+var _ = Describe("Dumper", func() {
+	var (
+		logger       *lagertest.TestLogger
+		dumper       *debug_dump.Dumper
+		receivedBody string
+		handler      http.Handler
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("broker")
+		dumper = debug_dump.New(logger)
+		receivedBody = ""
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			receivedBody = string(body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "session=secret")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"credentials":{"password":"secret"},"parameters":{"admin_password":"hunter2","size":"large"}}`))
+		})
+	})
+
+	serve := func() {
+		req := httptest.NewRequest("PUT", "/v2/service_instances/instance/service_bindings/binding", strings.NewReader(`{"plan_id":"plan","parameters":{"admin_password":"hunter2","nested":{"admin_password":"hunter2"},"size":"large"},"admin_password":"kept"}`))
+		req.SetBasicAuth("username", "password")
+		req.Header.Set("X-Broker-API-Version", "2.14")
+		dumper.Wrap(handler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	dumps := func() []lager.LogFormat {
+		var dumps []lager.LogFormat
+		for _, log := range logger.Logs() {
+			if log.Message == "broker.debug-dump.request-dump" {
+				dumps = append(dumps, log)
+			}
+		}
+		return dumps
+	}
+
+	It("is disabled until enabled", func() {
+		Expect(dumper.Enabled()).To(BeFalse())
+		serve()
+		Expect(dumps()).To(BeEmpty())
+		Expect(receivedBody).To(ContainSubstring("hunter2"))
+	})
+
+	It("can be switched on and off", func() {
+		dumper.SetEnabled(true)
+		serve()
+		dumper.SetEnabled(false)
+		serve()
+		Expect(dumps()).To(HaveLen(1))
+	})
+
+	Context("when enabled", func() {
+		BeforeEach(func() {
+			dumper.WithSecretParameters("admin_password").SetEnabled(true)
+		})
+
+		It("dumps the request and response with secrets redacted", func() {
+			serve()
+			Expect(dumps()).To(HaveLen(1))
+			data := dumps()[0].Data
+
+			request := data["request"].(map[string]interface{})
+			Expect(request["method"]).To(Equal("PUT"))
+			Expect(request["uri"]).To(Equal("/v2/service_instances/instance/service_bindings/binding"))
+			Expect(request["header"]).To(HaveKeyWithValue("Authorization", ConsistOf(debug_dump.Redacted)))
+			Expect(request["header"]).To(HaveKeyWithValue("X-Broker-Api-Version", ConsistOf("2.14")))
+			Expect(request["body"]).To(MatchJSON(`{"plan_id":"plan","parameters":{"admin_password":"[REDACTED]","nested":{"admin_password":"[REDACTED]"},"size":"large"},"admin_password":"kept"}`))
+
+			response := data["response"].(map[string]interface{})
+			Expect(response["status"]).To(BeEquivalentTo(http.StatusCreated))
+			Expect(response["header"]).To(HaveKeyWithValue("Set-Cookie", ConsistOf(debug_dump.Redacted)))
+			Expect(response["body"]).To(MatchJSON(`{"credentials":"[REDACTED]","parameters":{"admin_password":"[REDACTED]","size":"large"}}`))
+		})
+
+		It("passes the body on to the handler unchanged", func() {
+			serve()
+			Expect(receivedBody).To(ContainSubstring(`"admin_password":"hunter2"`))
+		})
+
+		It("leaves out JSON bodies too long to redact, still passing them on in full", func() {
+			dumper.WithMaxBodySize(10)
+			serve()
+			data := dumps()[0].Data
+			Expect(data["request"]).To(HaveKeyWithValue("body", "[body over 10 bytes not dumped]"))
+			Expect(data["response"]).To(HaveKeyWithValue("body", "[body over 10 bytes not dumped]"))
+			Expect(receivedBody).To(ContainSubstring(`"admin_password":"kept"`))
+		})
+
+		It("cuts long bodies which are not JSON", func() {
+			dumper.WithMaxBodySize(10)
+			req := httptest.NewRequest("POST", "/admin/notes", strings.NewReader("a long note which is plain text"))
+			req.Header.Set("Content-Type", "text/plain")
+			dumper.Wrap(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			request := dumps()[0].Data["request"].(map[string]interface{})
+			Expect(request["body"]).To(Equal("a long not..."))
+			Expect(receivedBody).To(Equal("a long note which is plain text"))
+		})
+	})
+
+	Describe("SecretParameters", func() {
+		It("returns the parameters flagged as secret by the plan schemas", func() {
+			services := []domain.Service{{Plans: []domain.ServicePlan{
+				{ID: "no-schemas"},
+				{ID: "plan", Schemas: &domain.ServiceSchemas{
+					Instance: domain.ServiceInstanceSchema{Create: domain.Schema{Parameters: map[string]interface{}{
+						"properties": map[string]interface{}{
+							"size":     map[string]interface{}{"type": "string"},
+							"password": map[string]interface{}{"type": "string", "format": "password"},
+							"tls": map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+								"key": map[string]interface{}{"type": "string", "writeOnly": true},
+							}},
+						},
+					}}},
+					Binding: domain.ServiceBindingSchema{Create: domain.Schema{Parameters: map[string]interface{}{
+						"properties": map[string]interface{}{
+							"password": map[string]interface{}{"type": "string", "format": "password"},
+						},
+					}}},
+				}},
+			}}}
+
+			Expect(debug_dump.SecretParameters(services)).To(ConsistOf("password", "key"))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip_allowlist
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+)
+
+const forbidden = "Forbidden"
+
+// Allowlist rejects requests from clients outside a set of networks, such as
+// the egress ranges of the platform calling the broker.
+type Allowlist struct {
+	networks []*net.IPNet
+}
+
+// New returns an Allowlist of the given CIDRs or IP addresses. The client IP
+// is the one resolved by a client_ip.Resolver when one runs before the
+// Allowlist, and otherwise the address of the peer.
+func New(allowed ...string) (*Allowlist, error) {
+	networks, err := client_ip.ParseNetworks(allowed...)
+	if err != nil {
+		return nil, err
+	}
+	return &Allowlist{networks: networks}, nil
+}
+
+// Allowed reports whether the client which made req is allowed.
+func (a *Allowlist) Allowed(req *http.Request) bool {
+	return client_ip.Contains(a.networks, client_ip.FromRequest(req))
+}
+
+// Wrap responds with 403 Forbidden to requests from clients which are not allowed.
+func (a *Allowlist) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !a.Allowed(req) {
+			http.Error(w, forbidden, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip_allowlist_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+	"github.com/sharma-tapas/brokerapi/middlewares/ip_allowlist"
+)
+
+var _ = Describe("Allowlist", func() {
+	var (
+		handler http.Handler
+		called  bool
+	)
+
+	serve := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/v2/catalog", nil)
+		request.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		called = false
+		allowlist, err := ip_allowlist.New("192.0.2.0/24", "2001:db8::1")
+		Expect(err).NotTo(HaveOccurred())
+		handler = allowlist.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+		}))
+	})
+
+	It("allows clients in the allowed networks", func() {
+		Expect(serve("192.0.2.10:4000", "").Code).To(Equal(http.StatusOK))
+		Expect(called).To(BeTrue())
+
+		Expect(serve("[2001:db8::1]:4000", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects other clients with 403", func() {
+		recorder := serve("198.51.100.1:4000", "")
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body.String()).To(Equal("Forbidden\n"))
+		Expect(called).To(BeFalse())
+	})
+
+	It("does not trust forwarding headers on their own", func() {
+		Expect(serve("198.51.100.1:4000", "192.0.2.10").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("uses the client IP resolved from trusted proxies", func() {
+		resolver, err := client_ip.NewResolver("10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+		handler = resolver.AddToContext(handler)
+
+		Expect(serve("10.0.0.1:4000", "192.0.2.10").Code).To(Equal(http.StatusOK))
+		Expect(serve("10.0.0.1:4000", "198.51.100.1").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("rejects invalid networks", func() {
+		_, err := ip_allowlist.New("192.0.2.0/40")
+		Expect(err).To(HaveOccurred())
+	})
+})
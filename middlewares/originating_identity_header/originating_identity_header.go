@@ -18,17 +18,23 @@ package originating_identity_header
 import (
 	"context"
 	"net/http"
-)
 
-const (
-	originatingIdentityKey = "originatingIdentity"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
 )
 
+// originatingIdentityKey is the string key the originating identity was
+// stored under before contextkeys.OriginatingIdentity. It is still set for
+// brokers reading it.
+//
+// Deprecated: read the identity with contextkeys.OriginatingIdentity. The
+// string key will no longer be set in the next major release.
+const originatingIdentityKey = "originatingIdentity"
+
 func AddToContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		originatingIdentity := req.Header.Get("X-Broker-API-Originating-Identity")
-		newCtx := context.WithValue(req.Context(), originatingIdentityKey, originatingIdentity)
+		newCtx := contextkeys.WithOriginatingIdentity(req.Context(), originatingIdentity)
+		newCtx = context.WithValue(newCtx, originatingIdentityKey, originatingIdentity)
 		next.ServeHTTP(w, req.WithContext(newCtx))
 	})
 }
-
@@ -0,0 +1,178 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rate_limit throttles requests with a token bucket per client,
+// keyed by the client IP or by the authenticated principal, so that platforms
+// sharing an address behind NAT are throttled independently.
+package rate_limit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/client_ip"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+const (
+	tooManyRequests   = "Too Many Requests"
+	sweepInterval     = time.Minute
+	defaultMaxBuckets = 10000
+)
+
+// KeyFunc returns the key of the bucket a request is counted in.
+type KeyFunc func(req *http.Request) string
+
+// ClientIP keys requests by the client IP, as resolved by a
+// client_ip.Resolver when one runs before the Limiter.
+func ClientIP(req *http.Request) string {
+	return "ip:" + client_ip.FromRequest(req)
+}
+
+// Principal keys requests by the principal their credentials were accepted
+// for, as stored in contextkeys.Principal by the authentication middleware,
+// falling back to the client IP. The credentials a request merely claims are
+// never used, so that clients can neither escape the limit by sending a new
+// username each time nor exhaust the bucket of another platform. A Limiter
+// keyed by Principal must therefore run after authentication, added with
+// brokerapi.WithAuthenticatedMiddleware.
+func Principal(req *http.Request) string {
+	if principal, ok := contextkeys.Principal(req.Context()); ok {
+		return "user:" + principal
+	}
+	return ClientIP(req)
+}
+
+// Limiter allows each key rate requests per second on average, in bursts of
+// up to burst requests.
+type Limiter struct {
+	rate       float64
+	burst      float64
+	key        KeyFunc
+	now        func() time.Time
+	maxBuckets int
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// New returns a Limiter allowing rate requests per second per key, in bursts
+// of up to burst requests.
+func New(rate float64, burst int, key KeyFunc) *Limiter {
+	return &Limiter{
+		rate:       rate,
+		burst:      float64(burst),
+		key:        key,
+		now:        time.Now,
+		maxBuckets: defaultMaxBuckets,
+		buckets:    map[string]*bucket{},
+	}
+}
+
+// WithMaxBuckets sets how many buckets the Limiter keeps at most, 10000 by
+// default. When a new key arrives while there are that many, the bucket used
+// least recently is forgotten.
+func (l *Limiter) WithMaxBuckets(max int) *Limiter {
+	l.maxBuckets = max
+	return l
+}
+
+// WithClock replaces time.Now, to control the refilling of buckets in tests.
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.now = now
+	return l
+}
+
+// Allow takes a token from the bucket of req. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(req *http.Request) (bool, time.Duration) {
+	key := l.key(req)
+	now := l.now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxBuckets {
+			l.evict(now)
+		}
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets the buckets which have refilled, so that clients which have
+// gone away do not use memory.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// evict makes room for a new bucket, forgetting those which have refilled or,
+// failing that, the one used least recently.
+func (l *Limiter) evict(now time.Time) {
+	l.lastSweep = time.Time{}
+	l.sweep(now)
+	if len(l.buckets) < l.maxBuckets {
+		return
+	}
+	var oldestKey string
+	var oldest *bucket
+	for key, b := range l.buckets {
+		if oldest == nil || b.updated.Before(oldest.updated) {
+			oldestKey, oldest = key, b
+		}
+	}
+	delete(l.buckets, oldestKey)
+}
+
+// Wrap responds with 429 Too Many Requests and a Retry-After header to
+// requests over the limit.
+func (l *Limiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ok, retryAfter := l.Allow(req); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, tooManyRequests, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recording captures the requests a broker receives and the responses
+// it sends, so that real platform traffic can be replayed against a new
+// version of the broker with brokertest.BrokerTester.Replay. Exchanges are
+// written as JSON lines, with credentials and identities redacted.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+// Redacted replaces the values removed from a recording.
+const Redacted = "[REDACTED]"
+
+var (
+	defaultRedactedHeaders = []string{"Authorization", "X-Broker-API-Originating-Identity"}
+	defaultRedactedFields  = []string{"credentials", "parameters"}
+)
+
+// Exchange is a request and the response the broker sent to it.
+type Exchange struct {
+	Time     time.Time `json:"time"`
+	Request  Request   `json:"request"`
+	Response Response  `json:"response"`
+}
+
+// Request is a recorded request. URI includes the query string.
+type Request struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Recorder writes an Exchange for every request it wraps.
+type Recorder struct {
+	out      io.Writer
+	redactor *Redactor
+
+	mutex sync.Mutex
+}
+
+// New returns a Recorder writing to out. The Authorization and
+// X-Broker-API-Originating-Identity headers, the credentials of bindings, and
+// the parameters of instances and bindings, which often hold secrets, are
+// redacted.
+func New(out io.Writer) *Recorder {
+	redactor := NewRedactor().
+		WithRedactedHeaders(defaultRedactedHeaders...).
+		WithRedactedFields(defaultRedactedFields...)
+	return &Recorder{out: out, redactor: redactor}
+}
+
+// WithRedactedHeaders also redacts the given request and response headers.
+func (r *Recorder) WithRedactedHeaders(headers ...string) *Recorder {
+	r.redactor.WithRedactedHeaders(headers...)
+	return r
+}
+
+// WithRedactedFields also redacts the given fields wherever they appear in
+// JSON request and response bodies, for example "dashboard_url".
+func (r *Recorder) WithRedactedFields(fields ...string) *Recorder {
+	r.redactor.WithRedactedFields(fields...)
+	return r
+}
+
+// Wrap records every exchange handled by next.
+func (r *Recorder) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		var requestBody []byte
+		if req.Body != nil {
+			requestBody, _ = ioutil.ReadAll(req.Body)
+			req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		recorder := statusrecorder.New(w).CaptureBody(-1)
+		next.ServeHTTP(recorder, req)
+
+		uri := req.RequestURI
+		if uri == "" {
+			uri = req.URL.RequestURI()
+		}
+		r.write(Exchange{
+			Time: start,
+			Request: Request{
+				Method: req.Method,
+				URI:    uri,
+				Header: r.redactor.Header(req.Header),
+				Body:   string(r.redactor.Body(requestBody)),
+			},
+			Response: Response{
+				Status: recorder.Status(),
+				Header: r.redactor.Header(w.Header()),
+				Body:   string(r.redactor.Body(recorder.Body())),
+			},
+		})
+	})
+}
+
+func (r *Recorder) write(exchange Exchange) {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.out.Write(line)
+}
+
+// ReadExchanges reads the exchanges written by a Recorder.
+func ReadExchanges(in io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, scanner.Err()
+}
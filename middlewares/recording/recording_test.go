@@ -0,0 +1,118 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/recording"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		out          *bytes.Buffer
+		recorder     *recording.Recorder
+		receivedBody string
+		handler      http.Handler
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		recorder = recording.New(out)
+		receivedBody = ""
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			receivedBody = string(body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"credentials":{"password":"secret"},"route_service_url":"https://route.example.com"}`))
+		})
+	})
+
+	serve := func() []recording.Exchange {
+		req := httptest.NewRequest("PUT", "/v2/service_instances/instance/service_bindings/binding?accepts_incomplete=true", strings.NewReader(`{"plan_id":"plan","parameters":{"size":"large"}}`))
+		req.SetBasicAuth("username", "password")
+		req.Header.Set("X-Broker-API-Version", "2.14")
+		req.Header.Set("X-Broker-API-Originating-Identity", "cloudfoundry eyJ1c2VyX2lkIjoiZGVhZGJlZWYifQ==")
+
+		recorder.Wrap(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		exchanges, err := recording.ReadExchanges(out)
+		Expect(err).NotTo(HaveOccurred())
+		return exchanges
+	}
+
+	It("records the request and the response", func() {
+		exchanges := serve()
+
+		Expect(exchanges).To(HaveLen(1))
+		exchange := exchanges[0]
+		Expect(exchange.Time).NotTo(BeZero())
+		Expect(exchange.Request.Method).To(Equal("PUT"))
+		Expect(exchange.Request.URI).To(Equal("/v2/service_instances/instance/service_bindings/binding?accepts_incomplete=true"))
+		Expect(exchange.Request.Header.Get("X-Broker-API-Version")).To(Equal("2.14"))
+		Expect(exchange.Request.Body).To(MatchJSON(`{"plan_id":"plan","parameters":"[REDACTED]"}`))
+		Expect(exchange.Response.Status).To(Equal(http.StatusCreated))
+		Expect(exchange.Response.Header.Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("passes the request body on to the handler", func() {
+		serve()
+
+		Expect(receivedBody).To(MatchJSON(`{"plan_id":"plan","parameters":{"size":"large"}}`))
+	})
+
+	It("redacts credentials and identities", func() {
+		exchange := serve()[0]
+
+		Expect(exchange.Request.Header.Get("Authorization")).To(Equal(recording.Redacted))
+		Expect(exchange.Request.Header.Get("X-Broker-API-Originating-Identity")).To(Equal(recording.Redacted))
+		Expect(exchange.Response.Body).To(MatchJSON(`{"credentials":"[REDACTED]","route_service_url":"https://route.example.com"}`))
+		Expect(out.String()).NotTo(ContainSubstring("secret"))
+	})
+
+	It("redacts additional headers and fields", func() {
+		recorder.WithRedactedHeaders("x-broker-api-version").WithRedactedFields("plan_id")
+
+		exchange := serve()[0]
+
+		Expect(exchange.Request.Header.Get("X-Broker-API-Version")).To(Equal(recording.Redacted))
+		Expect(exchange.Request.Body).To(MatchJSON(`{"plan_id":"[REDACTED]","parameters":"[REDACTED]"}`))
+	})
+
+	It("records bodies which are not JSON as they are", func() {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("not json"))
+		})
+
+		exchange := serve()[0]
+
+		Expect(exchange.Response.Status).To(Equal(http.StatusOK))
+		Expect(exchange.Response.Body).To(Equal("not json"))
+	})
+
+	It("returns an error when a recording is malformed", func() {
+		_, err := recording.ReadExchanges(strings.NewReader("{not json\n"))
+
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,115 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Redactor replaces credentials, identities and secret parameters in headers
+// and JSON bodies with Redacted. A Recorder redacts its recordings with one,
+// and debug_dump.Dumper its dumps.
+type Redactor struct {
+	headers          []string
+	fields           map[string]bool
+	secretParameters map[string]bool
+}
+
+// NewRedactor returns a Redactor redacting nothing until told what to.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		fields:           map[string]bool{},
+		secretParameters: map[string]bool{},
+	}
+}
+
+// WithRedactedHeaders redacts the given headers.
+func (r *Redactor) WithRedactedHeaders(headers ...string) *Redactor {
+	for _, header := range headers {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(header))
+	}
+	return r
+}
+
+// WithRedactedFields redacts the given fields wherever they appear in JSON
+// bodies.
+func (r *Redactor) WithRedactedFields(fields ...string) *Redactor {
+	for _, field := range fields {
+		r.fields[field] = true
+	}
+	return r
+}
+
+// WithSecretParameters redacts the given fields within parameters objects,
+// at any depth.
+func (r *Redactor) WithSecretParameters(names ...string) *Redactor {
+	for _, name := range names {
+		r.secretParameters[name] = true
+	}
+	return r
+}
+
+// Header returns a copy of header with the redacted headers replaced, or nil
+// for an empty header.
+func (r *Redactor) Header(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		redacted[key] = append([]string{}, values...)
+	}
+	for _, key := range r.headers {
+		if _, ok := redacted[key]; ok {
+			redacted[key] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// Body returns a JSON body with its redacted fields replaced. Bodies which
+// are not JSON are returned as they are.
+func (r *Redactor) Body(body []byte) []byte {
+	var value interface{}
+	if len(body) == 0 || json.Unmarshal(body, &value) != nil {
+		return body
+	}
+	redacted, err := json.Marshal(r.redact(value, false))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func (r *Redactor) redact(value interface{}, inParameters bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			switch {
+			case r.fields[key], inParameters && r.secretParameters[key]:
+				value[key] = Redacted
+			default:
+				value[key] = r.redact(field, inParameters || key == "parameters")
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = r.redact(element, inParameters)
+		}
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statusrecorder keeps the status code and size of the response a
+// handler writes, and optionally its body, for the middlewares which report
+// on requests once they have been served.
+package statusrecorder
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Recorder is an http.ResponseWriter passing the response on to the
+// ResponseWriter it wraps while recording it.
+type Recorder struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+
+	captureBody bool
+	maxBody     int
+	body        bytes.Buffer
+}
+
+// New returns a Recorder writing to w.
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// CaptureBody keeps a copy of the first max bytes of the body, or of all of
+// it when max is negative.
+func (r *Recorder) CaptureBody(max int) *Recorder {
+	r.captureBody = true
+	r.maxBody = max
+	return r
+}
+
+// Body returns the part of the body kept by CaptureBody. It is shorter than
+// Bytes when the body was cut.
+func (r *Recorder) Body() []byte {
+	return r.body.Bytes()
+}
+
+// Status returns the status code written first, which is 200 when the
+// handler wrote a body without one.
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+// Bytes returns the number of bytes of body written.
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
+
+// WriteHeader records status, unless a status or body has already been
+// written, and passes it on.
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes of b, keeping them if the body is captured, and
+// passes them on.
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	if r.captureBody {
+		kept := b[:n]
+		if r.maxBody >= 0 && r.body.Len()+len(kept) > r.maxBody {
+			kept = kept[:r.maxBody-r.body.Len()]
+		}
+		r.body.Write(kept)
+	}
+	r.bytes += int64(n)
+	return n, err
+}
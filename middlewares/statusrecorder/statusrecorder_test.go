@@ -0,0 +1,62 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusrecorder_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/statusrecorder"
+)
+
+var _ = Describe("Recorder", func() {
+	It("records the first status and the size of the body", func() {
+		recorder := statusrecorder.New(httptest.NewRecorder())
+		recorder.WriteHeader(http.StatusCreated)
+		recorder.WriteHeader(http.StatusInternalServerError)
+		recorder.Write([]byte("hello"))
+
+		Expect(recorder.Status()).To(Equal(http.StatusCreated))
+		Expect(recorder.Bytes()).To(Equal(int64(5)))
+		Expect(recorder.Body()).To(BeEmpty())
+	})
+
+	It("defaults to 200 when only a body is written", func() {
+		recorder := statusrecorder.New(httptest.NewRecorder())
+		recorder.Write([]byte("hello"))
+		recorder.WriteHeader(http.StatusInternalServerError)
+		Expect(recorder.Status()).To(Equal(http.StatusOK))
+	})
+
+	It("captures the body up to the limit, passing all of it on", func() {
+		response := httptest.NewRecorder()
+		recorder := statusrecorder.New(response).CaptureBody(7)
+		recorder.Write([]byte("hello "))
+		recorder.Write([]byte("world"))
+
+		Expect(string(recorder.Body())).To(Equal("hello w"))
+		Expect(recorder.Bytes()).To(Equal(int64(11)))
+		Expect(response.Body.String()).To(Equal("hello world"))
+	})
+
+	It("captures the whole body without a limit", func() {
+		recorder := statusrecorder.New(httptest.NewRecorder()).CaptureBody(-1)
+		recorder.Write([]byte("hello world"))
+		Expect(string(recorder.Body())).To(Equal("hello world"))
+	})
+})
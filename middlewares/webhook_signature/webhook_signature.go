@@ -0,0 +1,106 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook_signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultHeader       = "X-Signature"
+	DefaultMaxBodyBytes = 1 << 20
+
+	signaturePrefix  = "sha256="
+	invalidSignature = "Invalid Signature"
+)
+
+// Verifier checks that requests carry an HMAC signature of their body, as sent
+// by backing services calling back into the broker.
+type Verifier struct {
+	header       string
+	secret       []byte
+	hash         func() hash.Hash
+	maxBodyBytes int64
+}
+
+// NewVerifier returns a Verifier which expects the hex encoded HMAC-SHA256 of
+// the request body, keyed with secret, in the given header. The signature may
+// optionally be prefixed with "sha256=".
+func NewVerifier(header string, secret []byte) *Verifier {
+	if header == "" {
+		header = DefaultHeader
+	}
+	return &Verifier{
+		header:       header,
+		secret:       secret,
+		hash:         sha256.New,
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies read for verification.
+func (v *Verifier) WithMaxBodyBytes(maxBodyBytes int64) *Verifier {
+	v.maxBodyBytes = maxBodyBytes
+	return v
+}
+
+// Sign returns the signature of body, in the form expected by Wrap.
+func (v *Verifier) Sign(body []byte) string {
+	mac := hmac.New(v.hash, v.secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (v *Verifier) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, ok := v.verify(req)
+		if !ok {
+			http.Error(w, invalidSignature, http.StatusUnauthorized)
+			return
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (v *Verifier) verify(req *http.Request) ([]byte, bool) {
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get(v.header), signaturePrefix))
+	if err != nil || len(signature) == 0 {
+		return nil, false
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, v.maxBodyBytes+1))
+		req.Body.Close()
+		if err != nil || int64(len(body)) > v.maxBodyBytes {
+			return nil, false
+		}
+	}
+
+	mac := hmac.New(v.hash, v.secret)
+	mac.Write(body)
+	return body, hmac.Equal(signature, mac.Sum(nil))
+}
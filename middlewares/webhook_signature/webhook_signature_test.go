@@ -0,0 +1,91 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook_signature_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/middlewares/webhook_signature"
+)
+
+var _ = Describe("Webhook signature verification", func() {
+	var (
+		verifier     *webhook_signature.Verifier
+		handler      http.Handler
+		receivedBody string
+		recorder     *httptest.ResponseRecorder
+	)
+
+	const body = `{"event":"backup.completed"}`
+
+	makeRequest := func(signature string) {
+		request := httptest.NewRequest("POST", "/callbacks", strings.NewReader(body))
+		if signature != "" {
+			request.Header.Set("X-Callback-Signature", signature)
+		}
+		handler.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		receivedBody = ""
+		recorder = httptest.NewRecorder()
+		verifier = webhook_signature.NewVerifier("X-Callback-Signature", []byte("secret"))
+		handler = verifier.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			receivedBody = string(b)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	})
+
+	It("passes correctly signed requests through with their body intact", func() {
+		makeRequest(verifier.Sign([]byte(body)))
+		Expect(recorder.Code).To(Equal(http.StatusNoContent))
+		Expect(receivedBody).To(Equal(body))
+	})
+
+	It("accepts signatures without the sha256= prefix", func() {
+		makeRequest(strings.TrimPrefix(verifier.Sign([]byte(body)), "sha256="))
+		Expect(recorder.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("rejects requests without a signature", func() {
+		makeRequest("")
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects requests signed with a different secret", func() {
+		other := webhook_signature.NewVerifier("X-Callback-Signature", []byte("other-secret"))
+		makeRequest(other.Sign([]byte(body)))
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects requests whose body does not match the signature", func() {
+		makeRequest(verifier.Sign([]byte(`{"event":"backup.failed"}`)))
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		Expect(receivedBody).To(BeEmpty())
+	})
+
+	It("rejects bodies larger than the configured limit", func() {
+		verifier.WithMaxBodyBytes(4)
+		makeRequest(verifier.Sign([]byte(body)))
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+})
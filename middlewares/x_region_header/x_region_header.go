@@ -4,25 +4,32 @@ import (
 	"context"
 	"net/http"
 	"regexp"
-)
 
-const (
-	xRegionKey = "X-Region"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
 )
 
-//AddToContext the X-*-Region to the context
+// xRegionKey is the string key the region was stored under before
+// contextkeys.Region. It is still set for brokers reading it.
+//
+// Deprecated: read the region with contextkeys.Region. The string key will
+// no longer be set in the next major release.
+const xRegionKey = "X-Region"
+
+var regionHeaderPattern = regexp.MustCompile(`X([-]*[a-zA-Z]*)-Region`)
+
+// AddToContext the X-*-Region to the context
 func AddToContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		pattern := regexp.MustCompile(`X([-]*[a-zA-Z]*)-Region`)
 		value := ""
 		for k := range req.Header {
-			res := pattern.MatchString(k)
+			res := regionHeaderPattern.MatchString(k)
 			if res {
 				value = req.Header.Get(k)
 				break
 			}
 		}
-		newCtx := context.WithValue(req.Context(), xRegionKey, value)
+		newCtx := contextkeys.WithRegion(req.Context(), value)
+		newCtx = context.WithValue(newCtx, xRegionKey, value)
 		next.ServeHTTP(w, req.WithContext(newCtx))
 	})
 }
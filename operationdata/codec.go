@@ -0,0 +1,219 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package operationdata encodes broker state into the operation string
+// returned by asynchronous operations, and decodes it again when the platform
+// polls last_operation. Stateless brokers can so keep the context of an
+// operation, such as the ID of a backing job, with the platform rather than
+// in a database. The state is encoded as JSON and may be encrypted with
+// AES-GCM so that the platform can neither read nor alter it, or signed with
+// HMAC-SHA256 so that it stays readable but cannot be forged.
+package operationdata
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/paramversion"
+)
+
+// MaxOperationLength is the longest operation string the Open Service Broker
+// API allows a broker to return.
+const MaxOperationLength = 10000
+
+// ErrInvalidOperation is returned by Decode when the operation was not
+// produced by the codec, or has been altered. It responds to the platform
+// with a 400.
+var ErrInvalidOperation = apiresponses.NewFailureResponse(
+	errors.New("operation is invalid"), http.StatusBadRequest, "invalid-operation",
+)
+
+// MinSigningKeyLength is the shortest key accepted by NewSignedCodec.
+const MinSigningKeyLength = 16
+
+var encoding = base64.RawURLEncoding
+
+// Codec converts broker state to and from operation strings. A Codec is safe
+// for concurrent use.
+type Codec struct {
+	sealer   sealer
+	versions *paramversion.Versions
+}
+
+// sealer protects the encoded state. open returns ErrInvalidOperation when
+// the data was not sealed with the same key.
+type sealer interface {
+	seal(plaintext []byte) ([]byte, error)
+	open(data []byte) ([]byte, error)
+}
+
+// NewCodec returns a Codec which encodes state as unencrypted JSON. The
+// platform can read the state, so it must not contain secrets.
+func NewCodec() *Codec {
+	return &Codec{}
+}
+
+// NewEncryptedCodec returns a Codec which encrypts state with AES-GCM. The key
+// must be 16, 24 or 32 bytes long and shared by every instance of the broker.
+func NewEncryptedCodec(key []byte) (*Codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{sealer: aeadSealer{aead: aead}}, nil
+}
+
+// NewSignedCodec returns a Codec which signs state with HMAC-SHA256, so that
+// operations which were altered or forged by the platform are rejected. The
+// state itself remains readable. The key must be at least
+// MinSigningKeyLength bytes long and shared by every instance of the broker.
+func NewSignedCodec(key []byte) (*Codec, error) {
+	if len(key) < MinSigningKeyLength {
+		return nil, fmt.Errorf("signing key is %d bytes long, the minimum is %d", len(key), MinSigningKeyLength)
+	}
+	return &Codec{sealer: hmacSealer{key: key}}, nil
+}
+
+// WithVersions stamps encoded state with the current version of versions, and
+// migrates the state of operations encoded with an older version, or before
+// versions were set, when they are decoded. Operations started before a
+// broker is upgraded can so still be polled. The state must encode as a JSON
+// object.
+func (c *Codec) WithVersions(versions *paramversion.Versions) *Codec {
+	c.versions = versions
+	return c
+}
+
+// Encode returns the operation string holding state. It fails when the
+// result would be longer than MaxOperationLength.
+func (c *Codec) Encode(state interface{}) (string, error) {
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	if c.versions != nil {
+		if plaintext, err = c.versions.Stamp(plaintext); err != nil {
+			return "", err
+		}
+	}
+
+	data := plaintext
+	if c.sealer != nil {
+		if data, err = c.sealer.seal(plaintext); err != nil {
+			return "", err
+		}
+	}
+
+	operation := encoding.EncodeToString(data)
+	if len(operation) > MaxOperationLength {
+		return "", fmt.Errorf("encoded operation is %d characters long, the maximum is %d", len(operation), MaxOperationLength)
+	}
+	return operation, nil
+}
+
+// Decode reads the state held by operation into state, which must be a
+// pointer. It returns ErrInvalidOperation when operation cannot be decoded.
+func (c *Codec) Decode(operation string, state interface{}) error {
+	data, err := encoding.DecodeString(operation)
+	if err != nil {
+		return ErrInvalidOperation
+	}
+
+	plaintext := data
+	if c.sealer != nil {
+		if plaintext, err = c.sealer.open(data); err != nil {
+			return err
+		}
+	}
+	if c.versions != nil {
+		plaintext, err = c.versions.Migrate(plaintext)
+		if err == paramversion.ErrNotAnObject {
+			return ErrInvalidOperation
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := json.Unmarshal(plaintext, state); err != nil {
+		return ErrInvalidOperation
+	}
+	return nil
+}
+
+// aeadSealer prefixes the ciphertext with its random nonce.
+type aeadSealer struct {
+	aead cipher.AEAD
+}
+
+func (s aeadSealer) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s aeadSealer) open(data []byte) ([]byte, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, ErrInvalidOperation
+	}
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrInvalidOperation
+	}
+	return plaintext, nil
+}
+
+// hmacSealer appends the MAC of the plaintext to it.
+type hmacSealer struct {
+	key []byte
+}
+
+func (s hmacSealer) seal(plaintext []byte) ([]byte, error) {
+	return append(append([]byte{}, plaintext...), s.mac(plaintext)...), nil
+}
+
+func (s hmacSealer) open(data []byte) ([]byte, error) {
+	if len(data) < sha256.Size {
+		return nil, ErrInvalidOperation
+	}
+	plaintext, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(mac, s.mac(plaintext)) {
+		return nil, ErrInvalidOperation
+	}
+	return plaintext, nil
+}
+
+func (s hmacSealer) mac(plaintext []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(plaintext)
+	return h.Sum(nil)
+}
@@ -0,0 +1,196 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operationdata_test
+
+import (
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/operationdata"
+	"github.com/sharma-tapas/brokerapi/paramversion"
+)
+
+type jobState struct {
+	JobID   string `json:"job_id"`
+	Attempt int    `json:"attempt"`
+}
+
+var _ = Describe("Codec", func() {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	Describe("unencrypted", func() {
+		codec := operationdata.NewCodec()
+
+		It("round-trips the state", func() {
+			operation, err := codec.Encode(jobState{JobID: "job-1", Attempt: 2})
+			Expect(err).NotTo(HaveOccurred())
+
+			var state jobState
+			Expect(codec.Decode(operation, &state)).To(Succeed())
+			Expect(state).To(Equal(jobState{JobID: "job-1", Attempt: 2}))
+		})
+
+		It("rejects operations it did not encode", func() {
+			var state jobState
+			Expect(codec.Decode("not base64!", &state)).To(MatchError(operationdata.ErrInvalidOperation))
+			Expect(codec.Decode("bm90IGpzb24", &state)).To(MatchError(operationdata.ErrInvalidOperation))
+		})
+	})
+
+	Describe("encrypted", func() {
+		var codec *operationdata.Codec
+
+		BeforeEach(func() {
+			var err error
+			codec, err = operationdata.NewEncryptedCodec(key)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("round-trips the state without revealing it", func() {
+			operation, err := codec.Encode(jobState{JobID: "job-1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operation).NotTo(ContainSubstring("job"))
+
+			var state jobState
+			Expect(codec.Decode(operation, &state)).To(Succeed())
+			Expect(state.JobID).To(Equal("job-1"))
+		})
+
+		It("encrypts the same state differently each time", func() {
+			first, _ := codec.Encode(jobState{JobID: "job-1"})
+			second, _ := codec.Encode(jobState{JobID: "job-1"})
+			Expect(first).NotTo(Equal(second))
+		})
+
+		It("rejects altered operations", func() {
+			operation, _ := codec.Encode(jobState{JobID: "job-1"})
+			altered := []byte(operation)
+			altered[len(altered)/2] ^= 1
+
+			var state jobState
+			Expect(codec.Decode(string(altered), &state)).To(MatchError(operationdata.ErrInvalidOperation))
+			Expect(codec.Decode("", &state)).To(MatchError(operationdata.ErrInvalidOperation))
+		})
+
+		It("rejects operations encrypted with another key", func() {
+			other, err := operationdata.NewEncryptedCodec([]byte("fedcba9876543210"))
+			Expect(err).NotTo(HaveOccurred())
+			operation, _ := other.Encode(jobState{JobID: "job-1"})
+
+			var state jobState
+			Expect(codec.Decode(operation, &state)).To(MatchError(operationdata.ErrInvalidOperation))
+		})
+
+		It("rejects keys of the wrong length", func() {
+			_, err := operationdata.NewEncryptedCodec([]byte("short"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("signed", func() {
+		var codec *operationdata.Codec
+
+		BeforeEach(func() {
+			var err error
+			codec, err = operationdata.NewSignedCodec(key)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("round-trips the state", func() {
+			operation, err := codec.Encode(jobState{JobID: "job-1", Attempt: 2})
+			Expect(err).NotTo(HaveOccurred())
+
+			var state jobState
+			Expect(codec.Decode(operation, &state)).To(Succeed())
+			Expect(state).To(Equal(jobState{JobID: "job-1", Attempt: 2}))
+		})
+
+		It("rejects tampered operations", func() {
+			operation, _ := codec.Encode(jobState{JobID: "job-1"})
+			altered := []byte(operation)
+			altered[0] ^= 1
+
+			var state jobState
+			Expect(codec.Decode(string(altered), &state)).To(MatchError(operationdata.ErrInvalidOperation))
+		})
+
+		It("rejects unsigned and forged operations", func() {
+			unsigned, _ := operationdata.NewCodec().Encode(jobState{JobID: "job-1"})
+			forger, err := operationdata.NewSignedCodec([]byte("another signing key"))
+			Expect(err).NotTo(HaveOccurred())
+			forged, _ := forger.Encode(jobState{JobID: "job-1"})
+
+			var state jobState
+			Expect(codec.Decode(unsigned, &state)).To(MatchError(operationdata.ErrInvalidOperation))
+			Expect(codec.Decode(forged, &state)).To(MatchError(operationdata.ErrInvalidOperation))
+		})
+
+		It("rejects short keys", func() {
+			_, err := operationdata.NewSignedCodec([]byte("short"))
+			Expect(err).To(MatchError("signing key is 5 bytes long, the minimum is 16"))
+		})
+	})
+
+	Describe("versioned", func() {
+		var versions *paramversion.Versions
+
+		BeforeEach(func() {
+			versions = paramversion.New().Add(2, func(state map[string]interface{}) error {
+				state["job_id"] = state["job"]
+				delete(state, "job")
+				return nil
+			})
+		})
+
+		It("migrates the state of operations encoded before versioning", func() {
+			legacy, err := operationdata.NewCodec().Encode(map[string]string{"job": "job-1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			var state jobState
+			Expect(operationdata.NewCodec().WithVersions(versions).Decode(legacy, &state)).To(Succeed())
+			Expect(state).To(Equal(jobState{JobID: "job-1"}))
+		})
+
+		It("round-trips the state of the current version", func() {
+			codec := operationdata.NewCodec().WithVersions(versions)
+			operation, err := codec.Encode(jobState{JobID: "job-1", Attempt: 2})
+			Expect(err).NotTo(HaveOccurred())
+
+			var state jobState
+			Expect(codec.Decode(operation, &state)).To(Succeed())
+			Expect(state).To(Equal(jobState{JobID: "job-1", Attempt: 2}))
+		})
+
+		It("only encodes state which is an object", func() {
+			_, err := operationdata.NewCodec().WithVersions(versions).Encode("job-1")
+			Expect(err).To(MatchError(paramversion.ErrNotAnObject))
+		})
+	})
+
+	It("refuses to encode state longer than the platform accepts", func() {
+		_, err := operationdata.NewCodec().Encode(strings.Repeat("x", operationdata.MaxOperationLength))
+		Expect(err).To(MatchError(ContainSubstring("the maximum is 10000")))
+	})
+
+	It("responds to the platform with a 400 for invalid operations", func() {
+		var failure *apiresponses.FailureResponse
+		Expect(operationdata.ErrInvalidOperation).To(BeAssignableToTypeOf(failure))
+		Expect(operationdata.ErrInvalidOperation.ValidatedStatusCode(nil)).To(Equal(http.StatusBadRequest))
+	})
+})
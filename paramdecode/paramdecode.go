@@ -0,0 +1,83 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package paramdecode provides ParameterDecoders for
+// brokerapi.WithParameterDecoder, for brokers accepting the parameter formats
+// of the APIs they are migrating from.
+package paramdecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/internal/yamljson"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var errNotAnObject = errors.New("parameters must be an object")
+
+// YAMLString accepts parameters sent as a single string holding a YAML
+// document, as in {"parameters": "size: large\nbackups: 3"}, and turns the
+// document into a JSON object. Parameters which are already an object are
+// returned unchanged.
+func YAMLString() brokerapi.ParameterDecoder {
+	return brokerapi.ParameterDecoderFunc(func(_ context.Context, raw json.RawMessage) (json.RawMessage, error) {
+		var document string
+		if err := json.Unmarshal(raw, &document); err != nil {
+			return raw, nil
+		}
+
+		var parameters map[string]interface{}
+		if err := yaml.Unmarshal([]byte(document), &parameters); err != nil {
+			return nil, fmt.Errorf("could not parse YAML parameters: %s", err)
+		}
+		if parameters == nil {
+			parameters = map[string]interface{}{}
+		}
+		return json.Marshal(yamljson.JSONCompatible(parameters))
+	})
+}
+
+// RenameKeys renames the top-level parameters named by the keys of renames to
+// the corresponding values, so that legacy parameter names keep working.
+// When a parameter is sent under both names, the new name wins.
+func RenameKeys(renames map[string]string) brokerapi.ParameterDecoder {
+	return brokerapi.ParameterDecoderFunc(func(_ context.Context, raw json.RawMessage) (json.RawMessage, error) {
+		var parameters map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &parameters); err != nil || parameters == nil {
+			return nil, errNotAnObject
+		}
+
+		renamed := false
+		for from, to := range renames {
+			value, ok := parameters[from]
+			if !ok {
+				continue
+			}
+			delete(parameters, from)
+			if _, ok := parameters[to]; !ok {
+				parameters[to] = value
+			}
+			renamed = true
+		}
+		if !renamed {
+			return raw, nil
+		}
+		return json.Marshal(parameters)
+	})
+}
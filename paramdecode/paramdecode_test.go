@@ -0,0 +1,77 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package paramdecode_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/paramdecode"
+)
+
+var _ = Describe("paramdecode", func() {
+	ctx := context.Background()
+
+	Describe("YAMLString", func() {
+		decoder := paramdecode.YAMLString()
+
+		It("decodes parameters sent as a YAML string", func() {
+			decoded, err := decoder.DecodeParameters(ctx, json.RawMessage(`"size: large\nbackups: 3\nnetwork:\n  cidr: 10.0.0.0/24\n"`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(MatchJSON(`{"size": "large", "backups": 3, "network": {"cidr": "10.0.0.0/24"}}`))
+		})
+
+		It("decodes an empty string as an empty object", func() {
+			decoded, err := decoder.DecodeParameters(ctx, json.RawMessage(`""`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(MatchJSON(`{}`))
+		})
+
+		It("leaves objects unchanged", func() {
+			decoded, err := decoder.DecodeParameters(ctx, json.RawMessage(`{"size": "small"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(MatchJSON(`{"size": "small"}`))
+		})
+
+		It("fails for strings which are not YAML objects", func() {
+			_, err := decoder.DecodeParameters(ctx, json.RawMessage(`"- a\n- b"`))
+			Expect(err).To(MatchError(ContainSubstring("could not parse YAML parameters")))
+		})
+	})
+
+	Describe("RenameKeys", func() {
+		decoder := paramdecode.RenameKeys(map[string]string{"db_size": "size"})
+
+		It("renames legacy parameters", func() {
+			decoded, err := decoder.DecodeParameters(ctx, json.RawMessage(`{"db_size": "large", "backups": 3}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(MatchJSON(`{"size": "large", "backups": 3}`))
+		})
+
+		It("prefers the new name when both are sent", func() {
+			decoded, err := decoder.DecodeParameters(ctx, json.RawMessage(`{"db_size": "large", "size": "small"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(MatchJSON(`{"size": "small"}`))
+		})
+
+		It("fails for parameters which are not an object", func() {
+			_, err := decoder.DecodeParameters(ctx, json.RawMessage(`[1]`))
+			Expect(err).To(MatchError("parameters must be an object"))
+		})
+	})
+})
@@ -0,0 +1,142 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package paramversion versions the parameter schemas of a plan, so that
+// parameters written against an older schema, such as one the platform still
+// has cached while a new catalog rolls out, are migrated to the current
+// schema before they reach the broker.
+package paramversion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DefaultKey is the parameter naming the schema version parameters were
+// written against, unless another is set with WithKey.
+const DefaultKey = "schema_version"
+
+// ErrNotAnObject is returned by Migrate and Stamp for JSON values which are
+// not objects.
+var ErrNotAnObject = errors.New("parameters must be an object")
+
+// Migration turns parameters of one version into those of the next, changing
+// parameters in place. Values are decoded as by encoding/json, so numbers are
+// float64s.
+type Migration func(parameters map[string]interface{}) error
+
+// Versions lists the migrations between the versions of a parameter schema.
+// Version 1 is the schema used before versioning, so parameters which do not
+// name a version are of version 1. Versions can be registered with
+// brokerapi.WithParameterDecoder, and is safe for concurrent use once all
+// versions have been added.
+type Versions struct {
+	key        string
+	migrations []Migration
+}
+
+// New returns Versions with only version 1.
+func New() *Versions {
+	return &Versions{key: DefaultKey}
+}
+
+// WithKey sets the parameter naming the version.
+func (v *Versions) WithKey(key string) *Versions {
+	v.key = key
+	return v
+}
+
+// Add registers version, whose parameters migrate produces from those of the
+// version before. Versions must be added in order, starting with 2; Add
+// panics otherwise.
+func (v *Versions) Add(version int, migrate Migration) *Versions {
+	if version != v.Current()+1 {
+		panic(fmt.Sprintf("parameter schema version %d added after version %d", version, v.Current()))
+	}
+	v.migrations = append(v.migrations, migrate)
+	return v
+}
+
+// Current returns the latest version.
+func (v *Versions) Current() int {
+	return len(v.migrations) + 1
+}
+
+// Migrate returns raw, a JSON object, migrated to the current version and
+// without the version parameter. It fails for versions newer than the
+// current one.
+func (v *Versions) Migrate(raw json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, ErrNotAnObject
+	}
+
+	version := 1
+	rawVersion, versioned := fields[v.key]
+	if versioned {
+		if err := json.Unmarshal(rawVersion, &version); err != nil || version < 1 {
+			return nil, fmt.Errorf("%s must be a positive integer, not %s", v.key, rawVersion)
+		}
+		delete(fields, v.key)
+	}
+
+	switch {
+	case version > v.Current():
+		return nil, fmt.Errorf("%s %d is newer than the latest version, %d", v.key, version, v.Current())
+	case version == v.Current() && !versioned:
+		return raw, nil
+	case version == v.Current():
+		return json.Marshal(fields)
+	}
+
+	parameters := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		var decoded interface{}
+		if err := json.Unmarshal(value, &decoded); err != nil {
+			return nil, err
+		}
+		parameters[name] = decoded
+	}
+	for ; version < v.Current(); version++ {
+		if err := v.migrations[version-1](parameters); err != nil {
+			return nil, fmt.Errorf("could not migrate parameters from version %d to %d: %s", version, version+1, err)
+		}
+	}
+	return json.Marshal(parameters)
+}
+
+// Stamp returns raw, a JSON object, with the version parameter set to the
+// current version, for documents which are stored and migrated later. It fails
+// for documents which already have a field named like the version parameter,
+// which Migrate would mistake for their version.
+func (v *Versions) Stamp(raw json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, ErrNotAnObject
+	}
+	if _, ok := fields[v.key]; ok {
+		return nil, fmt.Errorf("%s is the version parameter and cannot be stamped over", v.key)
+	}
+	fields[v.key] = json.RawMessage(fmt.Sprint(v.Current()))
+	return json.Marshal(fields)
+}
+
+// DecodeParameters migrates the parameters of a request to the current
+// version.
+func (v *Versions) DecodeParameters(_ context.Context, raw json.RawMessage) (json.RawMessage, error) {
+	return v.Migrate(raw)
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package paramversion_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/paramversion"
+)
+
+var _ = Describe("Versions", func() {
+	var versions *paramversion.Versions
+
+	BeforeEach(func() {
+		versions = paramversion.New().
+			Add(2, func(parameters map[string]interface{}) error {
+				if size, ok := parameters["db_size"]; ok {
+					parameters["size"] = size
+					delete(parameters, "db_size")
+				}
+				return nil
+			}).
+			Add(3, func(parameters map[string]interface{}) error {
+				sizes := map[interface{}]float64{"small": 10, "large": 100}
+				gigabytes, ok := sizes[parameters["size"]]
+				if !ok {
+					return errors.New("unknown size")
+				}
+				parameters["size_gb"] = gigabytes
+				delete(parameters, "size")
+				return nil
+			})
+	})
+
+	It("migrates parameters without a version from version 1", func() {
+		migrated, err := versions.Migrate(json.RawMessage(`{"db_size":"large","backups":true}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(MatchJSON(`{"size_gb":100,"backups":true}`))
+	})
+
+	It("migrates parameters from the version they name", func() {
+		migrated, err := versions.Migrate(json.RawMessage(`{"schema_version":2,"size":"small"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(MatchJSON(`{"size_gb":10}`))
+	})
+
+	It("only removes the version from parameters of the current version", func() {
+		migrated, err := versions.Migrate(json.RawMessage(`{"schema_version":3,"size_gb":250}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(MatchJSON(`{"size_gb":250}`))
+	})
+
+	It("reads the version from another key", func() {
+		migrated, err := versions.WithKey("v").Migrate(json.RawMessage(`{"v":2,"size":"small"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(MatchJSON(`{"size_gb":10}`))
+	})
+
+	It("rejects versions it does not know", func() {
+		_, err := versions.Migrate(json.RawMessage(`{"schema_version":4}`))
+		Expect(err).To(MatchError("schema_version 4 is newer than the latest version, 3"))
+
+		_, err = versions.Migrate(json.RawMessage(`{"schema_version":"two"}`))
+		Expect(err).To(MatchError(`schema_version must be a positive integer, not "two"`))
+	})
+
+	It("reports failed migrations", func() {
+		_, err := versions.Migrate(json.RawMessage(`{"size":"huge","schema_version":2}`))
+		Expect(err).To(MatchError("could not migrate parameters from version 2 to 3: unknown size"))
+	})
+
+	It("rejects parameters which are not an object", func() {
+		_, err := versions.Migrate(json.RawMessage(`["small"]`))
+		Expect(err).To(MatchError(paramversion.ErrNotAnObject))
+	})
+
+	It("stamps documents with the current version", func() {
+		stamped, err := versions.Stamp(json.RawMessage(`{"size_gb":10}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stamped).To(MatchJSON(`{"size_gb":10,"schema_version":3}`))
+	})
+
+	It("does not stamp over a field named like the version parameter", func() {
+		_, err := versions.Stamp(json.RawMessage(`{"size_gb":10,"schema_version":"v2"}`))
+		Expect(err).To(MatchError("schema_version is the version parameter and cannot be stamped over"))
+	})
+
+	It("migrates the parameters of requests as a ParameterDecoder", func() {
+		decoded, err := versions.DecodeParameters(context.Background(), json.RawMessage(`{"db_size":"small"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(MatchJSON(`{"size_gb":10}`))
+	})
+
+	It("panics when versions are added out of order", func() {
+		Expect(func() { paramversion.New().Add(3, nil) }).To(Panic())
+	})
+})
@@ -0,0 +1,66 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+// WithPathPrefix serves the broker under prefix, such as "/broker-name", for
+// reverse proxies which forward /broker-name/v2/catalog without rewriting the
+// path. The prefix is stripped before routing, and requests without it get a
+// 404. Access logs keep the path as sent, and the path with the prefix is
+// available to middlewares through contextkeys.ExternalPath. NewWithOptions
+// panics if prefix does not start with a slash.
+func WithPathPrefix(prefix string) Option {
+	return func(c *config) {
+		c.pathPrefix = prefix
+	}
+}
+
+func stripPathPrefix(prefix string, next http.Handler) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		panic(fmt.Sprintf("path prefix %q must start with a slash", prefix))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, prefix)
+		if len(path) == len(req.URL.Path) || (path != "" && path[0] != '/') {
+			http.NotFound(w, req)
+			return
+		}
+
+		// The request URI is left as it is, like http.StripPrefix does, so
+		// that it still matches what the proxy sent.
+		u := new(url.URL)
+		*u = *req.URL
+		u.Path = path
+		u.RawPath = ""
+		if rawPath := strings.TrimPrefix(req.URL.RawPath, prefix); rawPath != req.URL.RawPath {
+			u.RawPath = rawPath
+		}
+
+		stripped := req.WithContext(contextkeys.WithPathPrefix(req.Context(), prefix))
+		stripped.URL = u
+		next.ServeHTTP(w, stripped)
+	})
+}
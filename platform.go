@@ -0,0 +1,42 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+// Platform classifies the platform making the request served with ctx as
+// PlatformCloudFoundry, PlatformKubernetes or PlatformOther, so that brokers
+// can tailor what they return, such as credentials keys which are valid
+// Kubernetes secret keys. It uses the platform of the context object of the
+// request, then that of the X-Broker-API-Originating-Identity header, and
+// treats requests with the X-Api-Info-Location header of Cloud Foundry as
+// coming from Cloud Foundry.
+func Platform(ctx context.Context) string {
+	platform, _ := contextkeys.Platform(ctx)
+	switch strings.ToLower(platform) {
+	case PlatformCloudFoundry:
+		return PlatformCloudFoundry
+	case PlatformKubernetes:
+		return PlatformKubernetes
+	default:
+		return PlatformOther
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka_publisher publishes broker lifecycle events to a Kafka topic.
+package kafka_publisher
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sharma-tapas/brokerapi"
+)
+
+// Producer writes a single message to a Kafka topic. Kafka clients differ in
+// their message types, so Producer is usually a small ProducerFunc around the
+// client in use, for example with github.com/segmentio/kafka-go:
+//
+//	kafka_publisher.ProducerFunc(func(ctx context.Context, topic string, key, value []byte) error {
+//		return writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+//	})
+type Producer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// ProducerFunc adapts a function to the Producer interface.
+type ProducerFunc func(ctx context.Context, topic string, key, value []byte) error
+
+func (f ProducerFunc) Produce(ctx context.Context, topic string, key, value []byte) error {
+	return f(ctx, topic, key, value)
+}
+
+// Publisher publishes each lifecycle event as JSON to a single topic. Messages
+// are keyed by instance ID, so that all events for an instance land on the
+// same partition and are consumed in order.
+type Publisher struct {
+	producer Producer
+	topic    string
+}
+
+func NewPublisher(producer Producer, topic string) *Publisher {
+	return &Publisher{producer: producer, topic: topic}
+}
+
+func (p *Publisher) Publish(ctx context.Context, event brokerapi.LifecycleEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, p.topic, []byte(event.InstanceID), value)
+}
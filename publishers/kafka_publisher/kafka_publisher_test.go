@@ -0,0 +1,68 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka_publisher_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/publishers/kafka_publisher"
+)
+
+var _ = Describe("Publisher", func() {
+	var (
+		topic      string
+		key, value []byte
+		produceErr error
+		producer   kafka_publisher.ProducerFunc
+		event      brokerapi.LifecycleEvent
+	)
+
+	BeforeEach(func() {
+		produceErr = nil
+		producer = func(ctx context.Context, t string, k, v []byte) error {
+			topic, key, value = t, k, v
+			return produceErr
+		}
+		event = brokerapi.LifecycleEvent{
+			Type:       brokerapi.EventBindingCreated,
+			InstanceID: "instance-id",
+			BindingID:  "binding-id",
+		}
+	})
+
+	It("produces the event as JSON keyed by instance ID", func() {
+		err := kafka_publisher.NewPublisher(producer, "broker-events").Publish(context.Background(), event)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(topic).To(Equal("broker-events"))
+		Expect(string(key)).To(Equal("instance-id"))
+		var published brokerapi.LifecycleEvent
+		Expect(json.Unmarshal(value, &published)).To(Succeed())
+		Expect(published).To(Equal(event))
+	})
+
+	It("returns producer errors", func() {
+		produceErr = errors.New("kafka: leader not available")
+
+		err := kafka_publisher.NewPublisher(producer, "broker-events").Publish(context.Background(), event)
+		Expect(err).To(MatchError("kafka: leader not available"))
+	})
+})
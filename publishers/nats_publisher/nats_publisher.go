@@ -0,0 +1,64 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nats_publisher publishes broker lifecycle events to NATS.
+package nats_publisher
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sharma-tapas/brokerapi"
+)
+
+// DefaultSubjectPrefix is used when NewPublisher is given an empty prefix.
+const DefaultSubjectPrefix = "osb"
+
+// Conn is the subset of a NATS connection used to publish events. It is
+// satisfied by *nats.Conn from github.com/nats-io/go-nats, so the broker does
+// not need to depend on a particular client version. *nats.EncodedConn does
+// not satisfy it, as its Publish takes an interface{} to encode; publish the
+// JSON through the *nats.Conn it wraps, EncodedConn.Conn, instead.
+type Conn interface {
+	Publish(subject string, data []byte) error
+}
+
+// Publisher publishes each lifecycle event as JSON to the subject
+// "<prefix>.<event type>", e.g. "osb.instance.provisioned", so that consumers
+// can subscribe to "osb.instance.>" or "osb.binding.*".
+type Publisher struct {
+	conn          Conn
+	subjectPrefix string
+}
+
+func NewPublisher(conn Conn, subjectPrefix string) *Publisher {
+	if subjectPrefix == "" {
+		subjectPrefix = DefaultSubjectPrefix
+	}
+	return &Publisher{conn: conn, subjectPrefix: subjectPrefix}
+}
+
+func (p *Publisher) Publish(ctx context.Context, event brokerapi.LifecycleEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.Subject(event.Type), data)
+}
+
+// Subject returns the subject events of the given type are published to.
+func (p *Publisher) Subject(eventType brokerapi.LifecycleEventType) string {
+	return p.subjectPrefix + "." + string(eventType)
+}
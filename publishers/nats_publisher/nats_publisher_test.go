@@ -0,0 +1,77 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nats_publisher_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/publishers/nats_publisher"
+)
+
+type fakeConn struct {
+	subjects []string
+	messages [][]byte
+	err      error
+}
+
+func (c *fakeConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	c.messages = append(c.messages, data)
+	return c.err
+}
+
+var _ = Describe("Publisher", func() {
+	var (
+		conn  *fakeConn
+		event brokerapi.LifecycleEvent
+	)
+
+	BeforeEach(func() {
+		conn = &fakeConn{}
+		event = brokerapi.LifecycleEvent{
+			Type:       brokerapi.EventInstanceProvisioned,
+			InstanceID: "instance-id",
+			PlanID:     "plan-id",
+		}
+	})
+
+	It("publishes the event as JSON to a subject named after its type", func() {
+		err := nats_publisher.NewPublisher(conn, "brokers.mysql").Publish(context.Background(), event)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(conn.subjects).To(Equal([]string{"brokers.mysql.instance.provisioned"}))
+		var published brokerapi.LifecycleEvent
+		Expect(json.Unmarshal(conn.messages[0], &published)).To(Succeed())
+		Expect(published).To(Equal(event))
+	})
+
+	It("uses the default prefix when none is given", func() {
+		publisher := nats_publisher.NewPublisher(conn, "")
+		Expect(publisher.Subject(brokerapi.EventBindingDeleted)).To(Equal("osb.binding.deleted"))
+	})
+
+	It("returns connection errors", func() {
+		conn.err = errors.New("nats: connection closed")
+
+		err := nats_publisher.NewPublisher(conn, "").Publish(context.Background(), event)
+		Expect(err).To(MatchError("nats: connection closed"))
+	})
+})
@@ -0,0 +1,129 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quota limits the number of instances of each service and plan,
+// counting the instances a broker keeps in a state.InstanceStore.
+package quota
+
+import (
+	"context"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+const pageSize = 100
+
+// Limits holds the maximum number of instances, by service ID and by plan ID.
+// Services and plans without an entry are not limited.
+type Limits struct {
+	Services map[string]int
+	Plans    map[string]int
+}
+
+// Checker enforces Limits. It implements domain.QuotaChecker.
+type Checker struct {
+	store  state.InstanceStore
+	limits Limits
+}
+
+// New returns a Checker counting the instances in store. The broker must
+// create an instance in the store when it accepts a provision, including
+// asynchronous ones, for the instance to count towards the limits.
+func New(store state.InstanceStore, limits Limits) *Checker {
+	return &Checker{store: store, limits: limits}
+}
+
+// CheckQuota returns apiresponses.ErrPlanQuotaExceeded or
+// apiresponses.ErrServiceQuotaExceeded when one more instance of planID would
+// exceed a limit. The check and the provision which follows are not atomic, so
+// concurrent requests from replicas not sharing a domain.LockManager can go
+// over a limit by the number of replicas.
+func (c *Checker) CheckQuota(ctx context.Context, instanceID, serviceID, planID string) error {
+	serviceLimit, serviceLimited := c.limits.Services[serviceID]
+	planLimit, planLimited := c.limits.Plans[planID]
+	if !serviceLimited && !planLimited {
+		return nil
+	}
+
+	serviceCount, planCount, err := c.count(ctx, instanceID, serviceID, planID)
+	if err != nil {
+		return err
+	}
+	if planLimited && planCount >= planLimit {
+		return apiresponses.ErrPlanQuotaExceeded
+	}
+	if serviceLimited && serviceCount >= serviceLimit {
+		return apiresponses.ErrServiceQuotaExceeded
+	}
+	return nil
+}
+
+// Usage returns the number of instances of serviceID and of planID.
+func (c *Checker) Usage(ctx context.Context, serviceID, planID string) (serviceCount, planCount int, err error) {
+	return c.count(ctx, "", serviceID, planID)
+}
+
+// Hints returns the quota hints of a request for an instance of planID, to
+// be returned in the spec of the operation. They describe whichever of the
+// service and plan limits has the fewest instances remaining, and are empty
+// when neither is limited. Call it once the instance has been recorded in the
+// store, so that it counts.
+func (c *Checker) Hints(ctx context.Context, serviceID, planID string) (domain.Hints, error) {
+	serviceLimit, serviceLimited := c.limits.Services[serviceID]
+	planLimit, planLimited := c.limits.Plans[planID]
+	if !serviceLimited && !planLimited {
+		return domain.Hints{}, nil
+	}
+
+	serviceCount, planCount, err := c.Usage(ctx, serviceID, planID)
+	if err != nil {
+		return domain.Hints{}, err
+	}
+	switch {
+	case !serviceLimited:
+		return domain.QuotaHints(planLimit, planCount), nil
+	case !planLimited || serviceLimit-serviceCount < planLimit-planCount:
+		return domain.QuotaHints(serviceLimit, serviceCount), nil
+	default:
+		return domain.QuotaHints(planLimit, planCount), nil
+	}
+}
+
+func (c *Checker) count(ctx context.Context, instanceID, serviceID, planID string) (serviceCount, planCount int, err error) {
+	cursor := ""
+	for {
+		instances, err := c.store.ListInstances(ctx, cursor, pageSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, instance := range instances {
+			if instance.ID == instanceID {
+				continue
+			}
+			if instance.ServiceID == serviceID {
+				serviceCount++
+			}
+			if instance.PlanID == planID {
+				planCount++
+			}
+		}
+		if len(instances) < pageSize {
+			return serviceCount, planCount, nil
+		}
+		cursor = instances[len(instances)-1].ID
+	}
+}
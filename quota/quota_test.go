@@ -0,0 +1,118 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/quota"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+type failingStore struct {
+	state.InstanceStore
+}
+
+func (failingStore) ListInstances(ctx context.Context, cursor string, limit int) ([]state.Instance, error) {
+	return nil, errors.New("database unavailable")
+}
+
+var _ = Describe("Checker", func() {
+	var (
+		ctx     context.Context
+		store   *state.Memory
+		checker *quota.Checker
+	)
+
+	createInstances := func(count int, serviceID, planID string) {
+		for i := 0; i < count; i++ {
+			id := fmt.Sprintf("%s-%s-%d", serviceID, planID, i)
+			Expect(store.CreateInstance(ctx, state.Instance{ID: id, ServiceID: serviceID, PlanID: planID})).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = state.NewMemory()
+		checker = quota.New(store, quota.Limits{
+			Services: map[string]int{"service-id": 3},
+			Plans:    map[string]int{"small": 2},
+		})
+	})
+
+	It("allows instances under the limits", func() {
+		createInstances(1, "service-id", "small")
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "small")).To(Succeed())
+	})
+
+	It("rejects instances over the plan limit", func() {
+		createInstances(2, "service-id", "small")
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "small")).To(Equal(apiresponses.ErrPlanQuotaExceeded))
+	})
+
+	It("rejects instances over the service limit", func() {
+		createInstances(1, "service-id", "small")
+		createInstances(2, "service-id", "large")
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "large")).To(Equal(apiresponses.ErrServiceQuotaExceeded))
+	})
+
+	It("does not count the instance being checked", func() {
+		createInstances(2, "service-id", "small")
+		Expect(checker.CheckQuota(ctx, "service-id-small-0", "service-id", "small")).To(Succeed())
+	})
+
+	It("does not limit other services and plans", func() {
+		createInstances(5, "other-service", "other-plan")
+		Expect(checker.CheckQuota(ctx, "new", "other-service", "other-plan")).To(Succeed())
+	})
+
+	It("counts instances across pages", func() {
+		checker = quota.New(store, quota.Limits{Plans: map[string]int{"small": 150}})
+		createInstances(150, "service-id", "small")
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "small")).To(Equal(apiresponses.ErrPlanQuotaExceeded))
+
+		serviceCount, planCount, err := checker.Usage(ctx, "service-id", "small")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serviceCount).To(Equal(150))
+		Expect(planCount).To(Equal(150))
+	})
+
+	It("returns the errors of the store", func() {
+		checker = quota.New(failingStore{}, quota.Limits{Plans: map[string]int{"small": 1}})
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "small")).To(MatchError("database unavailable"))
+	})
+
+	It("returns the hints of the limit with the fewest instances remaining", func() {
+		createInstances(1, "service-id", "small")
+		Expect(checker.Hints(ctx, "service-id", "small")).To(Equal(domain.QuotaHints(2, 1)))
+
+		createInstances(2, "service-id", "large")
+		Expect(checker.Hints(ctx, "service-id", "small")).To(Equal(domain.QuotaHints(3, 3)))
+		Expect(checker.Hints(ctx, "service-id", "large")).To(Equal(domain.QuotaHints(3, 3)))
+		Expect(checker.Hints(ctx, "other-service", "other-plan")).To(Equal(domain.Hints{}))
+	})
+
+	It("does not read the store for unlimited plans", func() {
+		checker = quota.New(failingStore{}, quota.Limits{})
+		Expect(checker.CheckQuota(ctx, "new", "service-id", "small")).To(Succeed())
+	})
+})
@@ -0,0 +1,162 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// pathTemplate is a gorilla/mux path template parsed once, when the routes are
+// attached, so that matching a request does not run a regular expression.
+// It matches the same paths, with the same variables, as the regular
+// expression mux would build from the template.
+type pathTemplate struct {
+	// literals[i] comes before vars[i], and the last literal ends the path.
+	literals []string
+	vars     []pathVar
+}
+
+type pathVar struct {
+	name    string
+	allowed *[256]bool
+}
+
+// parsePathTemplate supports variables of the form {name}, and {name:[...]+}
+// where the pattern is a character class.
+func parsePathTemplate(template string) pathTemplate {
+	var parsed pathTemplate
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			parsed.literals = append(parsed.literals, rest)
+			return parsed
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			panic(fmt.Sprintf("unbalanced braces in route %q", template))
+		}
+		parsed.literals = append(parsed.literals, rest[:start])
+		parsed.vars = append(parsed.vars, parsePathVar(template, rest[start+1:start+end]))
+		rest = rest[start+end+1:]
+	}
+}
+
+func parsePathVar(template, variable string) pathVar {
+	name, pattern := variable, "[^/]+"
+	if colon := strings.IndexByte(variable, ':'); colon >= 0 {
+		name, pattern = variable[:colon], variable[colon+1:]
+	}
+	if !strings.HasPrefix(pattern, "[") || !strings.HasSuffix(pattern, "]+") {
+		panic(fmt.Sprintf("unsupported pattern %q in route %q", pattern, template))
+	}
+
+	class := regexp.MustCompile("^" + strings.TrimSuffix(pattern, "+") + "$")
+	allowed := new([256]bool)
+	for b := 0; b < len(allowed); b++ {
+		allowed[b] = class.MatchString(string([]byte{byte(b)}))
+	}
+	return pathVar{name: name, allowed: allowed}
+}
+
+// match reports whether path matches, and sets values to the values of the
+// variables unless values is nil.
+func (t pathTemplate) match(path string, values []string) bool {
+	if !strings.HasPrefix(path, t.literals[0]) {
+		return false
+	}
+	if len(t.vars) == 0 {
+		return path == t.literals[0]
+	}
+	return t.matchVar(0, path[len(t.literals[0]):], values)
+}
+
+// matchVar matches vars[i] and everything after it against rest. Like the
+// greedy regular expression, the longest value for which the remainder of the
+// path still matches is chosen.
+func (t pathTemplate) matchVar(i int, rest string, values []string) bool {
+	allowed := t.vars[i].allowed
+	longest := 0
+	for longest < len(rest) && allowed[rest[longest]] {
+		longest++
+	}
+
+	literal := t.literals[i+1]
+	last := i == len(t.vars)-1
+	for n := longest; n > 0; n-- {
+		if !strings.HasPrefix(rest[n:], literal) {
+			continue
+		}
+		remainder := rest[n+len(literal):]
+		if last && remainder != "" {
+			continue
+		}
+		if !last && !t.matchVar(i+1, remainder, values) {
+			continue
+		}
+		if values != nil {
+			values[i] = rest[:n]
+		}
+		return true
+	}
+	return false
+}
+
+// matcher returns a mux.MatcherFunc for a route serving methods. The
+// variables are only extracted when the method matches as well, and replace
+// those left by routes which were tried before, so that mux.Vars only holds
+// those of the matched route.
+func (t pathTemplate) matcher(methods []string) mux.MatcherFunc {
+	return func(req *http.Request, match *mux.RouteMatch) bool {
+		if !containsMethod(methods, req.Method) {
+			// mux responds with 405 if the path matches.
+			return t.match(req.URL.Path, nil)
+		}
+
+		var buffer [2]string
+		values := buffer[:0]
+		for range t.vars {
+			values = append(values, "")
+		}
+		if !t.match(req.URL.Path, values) {
+			return false
+		}
+		if len(t.vars) == 0 {
+			match.Vars = nil
+			return true
+		}
+		vars := make(map[string]string, len(t.vars))
+		for i, v := range t.vars {
+			vars[v.name] = values[i]
+		}
+		match.Vars = vars
+		return true
+	}
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/fakes"
+)
+
+var _ = Describe("Routing", func() {
+	details := map[string]string{"service_id": "service-id", "plan_id": "plan-id"}
+
+	// serve returns the status of the response, and the string arguments of
+	// the broker methods which were called.
+	serve := func(method, path string, opts ...brokerapi.Option) (int, []string) {
+		fakeServiceBroker := new(fakes.AutoFakeServiceBroker)
+		fakeServiceBroker.ServicesReturns([]brokerapi.Service{
+			{
+				ID:                   "service-id",
+				Bindable:             true,
+				InstancesRetrievable: true,
+				BindingsRetrievable:  true,
+				Plans:                []brokerapi.ServicePlan{{ID: "plan-id"}},
+			},
+		}, nil)
+		fakeServiceBroker.BindReturns(brokerapi.Binding{Credentials: "credentials"}, nil)
+		fakeServiceBroker.LastOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+		fakeServiceBroker.LastBindingOperationReturns(brokerapi.LastOperation{State: brokerapi.InProgress}, nil)
+
+		credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+		opts = append(opts, brokerapi.WithBrokerCredentials(credentials))
+		brokerAPI := brokerapi.NewWithOptions(fakeServiceBroker, lagertest.NewTestLogger("routing"), opts...)
+		response := brokertest.New(brokerAPI, credentials.Username, credentials.Password).WithAPIVersion("2.14").Do(method, path, details)
+
+		var calls []string
+		for name, invocations := range fakeServiceBroker.Invocations() {
+			if name == "Services" {
+				continue
+			}
+			for _, args := range invocations {
+				call := name
+				for _, arg := range args {
+					if s, ok := arg.(string); ok {
+						call += " " + s
+					}
+				}
+				calls = append(calls, call)
+			}
+		}
+		return response.Code, calls
+	}
+
+	table.DescribeTable("matches the same routes as a mux path template",
+		func(method, path string, expectedCall string) {
+			status, calls := serve(method, path)
+			muxStatus, muxCalls := serve(method, path, brokerapi.WithRouter(mux.NewRouter()))
+
+			Expect(status).To(Equal(muxStatus))
+			Expect(calls).To(Equal(muxCalls))
+			if expectedCall == "" {
+				Expect(calls).To(BeEmpty())
+			} else {
+				Expect(calls).To(ConsistOf(expectedCall))
+			}
+		},
+		table.Entry("catalog", "GET", "/v2/catalog", ""),
+		table.Entry("catalog with a trailing slash", "GET", "/v2/catalog/", ""),
+		table.Entry("provision", "PUT", "/v2/service_instances/instance", "Provision instance"),
+		table.Entry("get instance", "GET", "/v2/service_instances/instance", "GetInstance instance"),
+		table.Entry("update", "PATCH", "/v2/service_instances/instance", "Update instance"),
+		table.Entry("deprovision", "DELETE", "/v2/service_instances/instance?service_id=service-id&plan_id=plan-id", "Deprovision instance"),
+		table.Entry("last operation", "GET", "/v2/service_instances/instance/last_operation", "LastOperation instance"),
+		table.Entry("bind", "PUT", "/v2/service_instances/instance/service_bindings/binding", "Bind instance binding"),
+		table.Entry("get binding", "GET", "/v2/service_instances/instance/service_bindings/binding", "GetBinding instance binding"),
+		table.Entry("unbind", "DELETE", "/v2/service_instances/instance/service_bindings/binding?service_id=service-id&plan_id=plan-id", "Unbind instance binding"),
+		table.Entry("last binding operation", "GET", "/v2/service_instances/instance/service_bindings/binding/last_operation", "LastBindingOperation instance binding"),
+		table.Entry("an instance ID with slashes", "GET", "/v2/service_instances/an/instance", "GetInstance an/instance"),
+		table.Entry("the last operation of an instance ID with slashes", "GET", "/v2/service_instances/an/instance/last_operation", "LastOperation an/instance"),
+		table.Entry("a binding ID with slashes", "GET", "/v2/service_instances/instance/service_bindings/a/binding", "GetBinding instance a/binding"),
+		table.Entry("repeated binding segments", "GET", "/v2/service_instances/a/service_bindings/b/service_bindings/c", "GetBinding a/service_bindings/b c"),
+		table.Entry("an empty binding ID", "GET", "/v2/service_instances/instance/service_bindings/", "GetInstance instance/service_bindings/"),
+		table.Entry("a binding path beyond last_operation", "GET", "/v2/service_instances/instance/service_bindings/binding/last_operation/x", "GetBinding instance binding/last_operation/x"),
+		table.Entry("an ID with punctuation", "GET", "/v2/service_instances/a:b@c;d=e_f!g.h+i*j'k(l)m,n$o&p", "GetInstance a:b@c;d=e_f!g.h+i*j'k(l)m,n$o&p"),
+		table.Entry("an ID with characters which are not allowed", "GET", "/v2/service_instances/a%20b", ""),
+		table.Entry("an empty instance ID", "GET", "/v2/service_instances/", ""),
+		table.Entry("an unknown path", "GET", "/v2/catalogue", ""),
+		table.Entry("an unsupported method", "POST", "/v2/service_instances/instance", ""),
+	)
+
+	It("responds with 405 to an unsupported method", func() {
+		status, _ := serve("POST", "/v2/service_instances/instance")
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("only sets the variables of the matched route", func() {
+		var vars map[string]string
+		captureVars := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				vars = mux.Vars(req)
+				next.ServeHTTP(w, req)
+			})
+		}
+
+		serve("PATCH", "/v2/service_instances/instance/service_bindings/binding", brokerapi.WithMiddleware(captureVars))
+
+		Expect(vars).To(Equal(map[string]string{"instance_id": "instance/service_bindings/binding"}))
+	})
+})
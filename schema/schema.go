@@ -0,0 +1,386 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema generates the JSON Schemas a catalog advertises for plan
+// parameters from the Go structs the broker decodes those parameters into, so
+// that the advertised schemas cannot drift from the code.
+//
+// Property names follow the json struct tags. Constraints are set with a
+// jsonschema tag holding comma separated keywords:
+//
+//	type ProvisionParameters struct {
+//		Size    string `json:"size" jsonschema:"required,enum=small|large,description=Size of the database"`
+//		Backups *int   `json:"backups,omitempty" jsonschema:"minimum=0,maximum=30,default=7"`
+//	}
+//
+// The supported keywords are required, title, description, default, enum
+// (values separated by |), format, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength,
+// minItems, maxItems and uniqueItems. The description takes the rest of the
+// tag, commas included, so it must come last.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+// Draft04 is the JSON Schema version the Open Service Broker API requires
+// plan schemas to use.
+const Draft04 = "http://json-schema.org/draft-04/schema#"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// For returns the schema of the parameters decoded into v, which is a struct,
+// a map with string keys, or a pointer to one, ready to be used as the
+// Parameters of a domain.Schema.
+func For(v interface{}) (map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("cannot generate a schema for nil")
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		return nil, fmt.Errorf("parameters must be an object, %s is not a struct or map", t)
+	}
+
+	g := &generator{visiting: map[reflect.Type]bool{}}
+	document, err := g.schemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	document["$schema"] = Draft04
+	return document, nil
+}
+
+// MustFor is like For but panics if the schema cannot be generated. It is
+// meant for catalogs built from types known at compile time.
+func MustFor(v interface{}) map[string]interface{} {
+	document, err := For(v)
+	if err != nil {
+		panic(err)
+	}
+	return document
+}
+
+// Builder builds the schemas of a plan from the types of its parameters:
+//
+//	schemas, err := schema.NewBuilder().
+//		InstanceCreate(ProvisionParameters{}).
+//		InstanceUpdate(UpdateParameters{}).
+//		BindingCreate(BindParameters{}).
+//		Build()
+//
+// The first error is kept and returned by Build.
+type Builder struct {
+	schemas domain.ServiceSchemas
+	err     error
+}
+
+// NewBuilder returns a Builder with no schemas.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// InstanceCreate sets the schema of the parameters of provision requests.
+func (b *Builder) InstanceCreate(v interface{}) *Builder {
+	b.set(&b.schemas.Instance.Create, v)
+	return b
+}
+
+// InstanceUpdate sets the schema of the parameters of update requests.
+func (b *Builder) InstanceUpdate(v interface{}) *Builder {
+	b.set(&b.schemas.Instance.Update, v)
+	return b
+}
+
+// BindingCreate sets the schema of the parameters of bind requests.
+func (b *Builder) BindingCreate(v interface{}) *Builder {
+	b.set(&b.schemas.Binding.Create, v)
+	return b
+}
+
+func (b *Builder) set(target *domain.Schema, v interface{}) {
+	if b.err != nil {
+		return
+	}
+	parameters, err := For(v)
+	if err != nil {
+		b.err = err
+		return
+	}
+	target.Parameters = parameters
+}
+
+// Build returns the schemas, or the first error met while generating them.
+func (b *Builder) Build() (*domain.ServiceSchemas, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	schemas := b.schemas
+	return &schemas, nil
+}
+
+// MustBuild is like Build but panics on error.
+func (b *Builder) MustBuild() *domain.ServiceSchemas {
+	schemas, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}
+
+type generator struct {
+	// visiting holds the structs being generated, to reject recursive types
+	// which cannot be expressed without references.
+	visiting map[reflect.Type]bool
+}
+
+func (g *generator) schemaOf(t reflect.Type) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case t == rawMessageType:
+		return map[string]interface{}{}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// The JSON form of the type is up to its MarshalJSON method.
+		return map[string]interface{}{}, nil
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings.
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot generate a schema for %s: map keys must be strings", t)
+		}
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.object(t)
+	}
+	return nil, fmt.Errorf("cannot generate a schema for %s", t)
+}
+
+func (g *generator) object(t reflect.Type) (map[string]interface{}, error) {
+	if g.visiting[t] {
+		return nil, fmt.Errorf("cannot generate a schema for %s: recursive types are not supported", t)
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := map[string]interface{}{}
+	var required []string
+	if err := g.fields(t, properties, &required); err != nil {
+		return nil, err
+	}
+
+	object := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object, nil
+}
+
+// fields adds the properties of the fields of t, including those of embedded
+// structs which encoding/json promotes.
+func (g *generator) fields(t reflect.Type, properties map[string]interface{}, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, tagged := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			if err := g.fields(fieldType, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		property, err := g.schemaOf(field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %s", field.Name, err)
+		}
+		isRequired, err := applyKeywords(property, field.Tag.Get("jsonschema"))
+		if err != nil {
+			return fmt.Errorf("field %s: %s", field.Name, err)
+		}
+		properties[name] = property
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+// jsonName returns the name encoding/json uses for field, and whether it is
+// set by a json tag.
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "-", true
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return field.Name, false
+}
+
+// applyKeywords sets the keywords of a jsonschema tag on property, and reports
+// whether the tag marks the property as required.
+func applyKeywords(property map[string]interface{}, tag string) (bool, error) {
+	required := false
+	for _, keyword := range splitKeywords(tag) {
+		key, value, hasValue := strings.Cut(keyword, "=")
+		if key == "required" || key == "uniqueItems" {
+			if hasValue {
+				return false, fmt.Errorf("%s does not take a value", key)
+			}
+			if key == "required" {
+				required = true
+			} else {
+				property[key] = true
+			}
+			continue
+		}
+		if !hasValue {
+			return false, fmt.Errorf("%s needs a value", key)
+		}
+
+		switch key {
+		case "title", "description", "format", "pattern":
+			property[key] = value
+		case "default":
+			typed, err := typedValue(property, value)
+			if err != nil {
+				return false, fmt.Errorf("default: %s", err)
+			}
+			property[key] = typed
+		case "enum":
+			var values []interface{}
+			for _, item := range strings.Split(value, "|") {
+				typed, err := typedValue(property, item)
+				if err != nil {
+					return false, fmt.Errorf("enum: %s", err)
+				}
+				values = append(values, typed)
+			}
+			property[key] = values
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("%s must be a number, got %q", key, value)
+			}
+			property[key] = number
+		case "minLength", "maxLength", "minItems", "maxItems":
+			number, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return false, fmt.Errorf("%s must be a non-negative integer, got %q", key, value)
+			}
+			property[key] = number
+		default:
+			return false, fmt.Errorf("unknown jsonschema keyword %q", key)
+		}
+	}
+	return required, nil
+}
+
+func splitKeywords(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	var keywords []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "description=") {
+			return append(keywords, tag)
+		}
+		keyword, rest, _ := strings.Cut(tag, ",")
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+		tag = rest
+	}
+	return keywords
+}
+
+// typedValue converts a default or enum value from a tag to the JSON type of
+// property.
+func typedValue(property map[string]interface{}, value string) (interface{}, error) {
+	switch property["type"] {
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "string", nil:
+		return value, nil
+	}
+	return nil, fmt.Errorf("only strings, numbers and booleans can be set in a tag, not %s", property["type"])
+}
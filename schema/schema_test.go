@@ -0,0 +1,130 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/cataloglint"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/schema"
+)
+
+type Common struct {
+	Region string `json:"region" jsonschema:"required,description=Region, such as eu-west-1"`
+}
+
+type ProvisionParameters struct {
+	Common
+	Size     string            `json:"size" jsonschema:"required,enum=small|large,default=small"`
+	Backups  *int              `json:"backups,omitempty" jsonschema:"minimum=0,maximum=30,default=7"`
+	Ratio    float64           `json:"ratio,omitempty" jsonschema:"exclusiveMinimum=0"`
+	HA       bool              `json:"ha"`
+	Tags     []string          `json:"tags,omitempty" jsonschema:"uniqueItems,maxItems=10"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	StartAt  time.Time         `json:"start_at"`
+	Extra    json.RawMessage   `json:"extra,omitempty"`
+	Network  Network           `json:"network"`
+	Ignored  string            `json:"-"`
+	unused   string
+	Untagged string
+}
+
+type Network struct {
+	CIDR string `json:"cidr" jsonschema:"pattern=^[0-9./]+$,minLength=9"`
+}
+
+type Recursive struct {
+	Children []Recursive `json:"children"`
+}
+
+var _ = Describe("For", func() {
+	It("describes the fields of a struct", func() {
+		document, err := schema.For(&ProvisionParameters{})
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := json.Marshal(document)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encoded).To(MatchJSON(`{
+			"$schema": "http://json-schema.org/draft-04/schema#",
+			"type": "object",
+			"properties": {
+				"region": {"type": "string", "description": "Region, such as eu-west-1"},
+				"size": {"type": "string", "enum": ["small", "large"], "default": "small"},
+				"backups": {"type": "integer", "minimum": 0, "maximum": 30, "default": 7},
+				"ratio": {"type": "number", "exclusiveMinimum": 0},
+				"ha": {"type": "boolean"},
+				"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 10},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"start_at": {"type": "string", "format": "date-time"},
+				"extra": {},
+				"network": {
+					"type": "object",
+					"properties": {"cidr": {"type": "string", "pattern": "^[0-9./]+$", "minLength": 9}}
+				},
+				"Untagged": {"type": "string"}
+			},
+			"required": ["region", "size"]
+		}`))
+	})
+
+	It("generates schemas the catalog linter accepts", func() {
+		schemas := schema.NewBuilder().
+			InstanceCreate(ProvisionParameters{}).
+			InstanceUpdate(map[string]interface{}{}).
+			BindingCreate(Network{}).
+			MustBuild()
+
+		diagnostics := cataloglint.Lint([]domain.Service{{
+			ID:          "service-id",
+			Name:        "service",
+			Description: "A service",
+			Plans:       []domain.ServicePlan{{ID: "plan-id", Name: "plan", Description: "A plan", Schemas: schemas}},
+		}})
+		Expect(cataloglint.HasErrors(diagnostics)).To(BeFalse(), "%v", diagnostics)
+		Expect(schemas.Binding.Create.Parameters["properties"]).To(HaveKey("cidr"))
+	})
+
+	It("rejects parameters which are not objects", func() {
+		_, err := schema.For("size")
+		Expect(err).To(MatchError("parameters must be an object, string is not a struct or map"))
+	})
+
+	It("rejects recursive types", func() {
+		_, err := schema.For(Recursive{})
+		Expect(err).To(MatchError(ContainSubstring("recursive types are not supported")))
+	})
+
+	It("rejects unknown keywords and mistyped values", func() {
+		_, err := schema.For(struct {
+			Size string `json:"size" jsonschema:"maximun=3"`
+		}{})
+		Expect(err).To(MatchError(`field Size: unknown jsonschema keyword "maximun"`))
+
+		_, err = schema.For(struct {
+			Count int `json:"count" jsonschema:"default=many"`
+		}{})
+		Expect(err).To(MatchError(ContainSubstring("field Count: default:")))
+	})
+
+	It("keeps the first error of a builder", func() {
+		_, err := schema.NewBuilder().InstanceCreate(42).BindingCreate(Network{}).Build()
+		Expect(err).To(MatchError(ContainSubstring("int is not a struct or map")))
+	})
+})
@@ -0,0 +1,74 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+)
+
+// The settings of servers returned by NewServer.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultMaxHeaderBytes    = 64 << 10
+)
+
+// ServerOption changes a server returned by NewServer.
+type ServerOption func(*http.Server)
+
+// NewServer returns a server for handler with timeouts suitable for the
+// internet, unlike the zero http.Server which waits forever for slow clients.
+// No read or write timeout is set, since synchronous broker operations can take
+// minutes; use WithDefaultTimeout to bound them. The fields of the returned
+// server can be changed before it is started.
+func NewServer(handler http.Handler, opts ...ServerOption) *http.Server {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	return server
+}
+
+// WithoutKeepAlives closes connections after each response, for load
+// balancers which do not spread persistent connections evenly.
+func WithoutKeepAlives() ServerOption {
+	return func(server *http.Server) {
+		server.SetKeepAlivesEnabled(false)
+	}
+}
+
+// WithClientCAs requires clients to present a certificate signed by one of
+// clientCAs, for brokers which authenticate platforms with mutual TLS. The
+// verified certificate is available to broker methods through
+// contextkeys.ClientCertificate. The server must be started with ServeTLS or
+// ListenAndServeTLS.
+func WithClientCAs(clientCAs *x509.CertPool) ServerOption {
+	return func(server *http.Server) {
+		if server.TLSConfig == nil {
+			server.TLSConfig = &tls.Config{}
+		}
+		server.TLSConfig.ClientCAs = clientCAs
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+}
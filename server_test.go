@@ -0,0 +1,124 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/middlewares/contextkeys"
+)
+
+var _ = Describe("NewServer", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	It("sets timeouts and a header size limit", func() {
+		server := brokerapi.NewServer(handler)
+
+		Expect(server.Handler).NotTo(BeNil())
+		Expect(server.ReadHeaderTimeout).To(Equal(brokerapi.DefaultReadHeaderTimeout))
+		Expect(server.IdleTimeout).To(Equal(brokerapi.DefaultIdleTimeout))
+		Expect(server.MaxHeaderBytes).To(Equal(brokerapi.DefaultMaxHeaderBytes))
+	})
+
+	It("keeps connections alive by default", func() {
+		server := httptest.NewUnstartedServer(handler)
+		server.Config = brokerapi.NewServer(handler)
+		server.Start()
+		defer server.Close()
+
+		response, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.Close).To(BeFalse())
+	})
+
+	It("can close connections after each response", func() {
+		server := httptest.NewUnstartedServer(handler)
+		server.Config = brokerapi.NewServer(handler, brokerapi.WithoutKeepAlives())
+		server.Start()
+		defer server.Close()
+
+		response, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.Close).To(BeTrue())
+	})
+
+	It("can require verified client certificates", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "platform"},
+			DNSNames:     []string{"platform.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			IsCA:         true,
+
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+		clientCertificate, err := x509.ParseCertificate(der)
+		Expect(err).NotTo(HaveOccurred())
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCertificate)
+
+		handler = contextkeys.AddToContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			certificate, _ := contextkeys.ClientCertificate(r.Context())
+			w.Write([]byte(certificate.Subject.CommonName + " " + certificate.DNSNames[0]))
+		}))
+		server := httptest.NewUnstartedServer(handler)
+		server.Config = brokerapi.NewServer(handler, brokerapi.WithClientCAs(clientCAs))
+		server.TLS = server.Config.TLSConfig
+		server.StartTLS()
+		defer server.Close()
+
+		client := server.Client()
+		_, err = client.Get(server.URL)
+		Expect(err).To(HaveOccurred())
+
+		client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}}
+		response, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("platform platform.example.com"))
+	})
+})
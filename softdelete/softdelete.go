@@ -0,0 +1,580 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package softdelete holds back the deprovisioning of service instances for a
+// retention window, so that operators can restore instances deleted by
+// mistake. The platform is told the instance is gone straight away; the
+// wrapped broker is only asked to deprovision it once the window has passed.
+package softdelete
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+const (
+	defaultPurgeInterval = time.Minute
+	listPageSize         = 100
+)
+
+var (
+	_ domain.ServiceBroker      = (*Broker)(nil)
+	_ domain.InstanceRestorer   = (*Broker)(nil)
+	_ domain.InstanceLister     = (*Broker)(nil)
+	_ domain.OrphanRemover      = (*Broker)(nil)
+	_ domain.ProvisionValidator = (*Broker)(nil)
+	_ domain.UpdateValidator    = (*Broker)(nil)
+	_ domain.BindValidator      = (*Broker)(nil)
+)
+
+// Deletion is a deprovision held back by a Broker.
+type Deletion struct {
+	InstanceID string
+	ServiceID  string
+	PlanID     string
+	DeletedAt  time.Time
+	// PurgeAt is when the retention window ends and the instance is
+	// deprovisioned by the wrapped broker.
+	PurgeAt time.Time
+	// Deprovisioning is set while the wrapped broker deprovisions the
+	// instance asynchronously. It can no longer be restored.
+	Deprovisioning bool
+
+	details       domain.DeprovisionDetails
+	operationData string
+}
+
+// record is what a Broker keeps in the Parameters of the state.Instance
+// standing for a deletion.
+type record struct {
+	DeletedAt      time.Time                 `json:"deleted_at"`
+	PurgeAt        time.Time                 `json:"purge_at"`
+	Details        domain.DeprovisionDetails `json:"details"`
+	Deprovisioning bool                      `json:"deprovisioning,omitempty"`
+	OperationData  string                    `json:"operation_data,omitempty"`
+}
+
+// Broker wraps a ServiceBroker, answering deprovisions itself and recording
+// the instances as pending deletion until their retention window has passed.
+// While an instance is pending, provisions of its ID fail with
+// apiresponses.ErrInstanceAlreadyExists, and every other request for it or
+// its bindings, such as updates, binds, unbinds, fetches and last_operation
+// polls, with apiresponses.ErrInstanceDoesNotExist, as though it was gone.
+// Pending instances are also left out of ListInstances. Deprovisions of
+// instances the wrapped broker does not have are refused rather than held
+// back.
+//
+// The optional interfaces of the admin API and of dry runs are passed on to
+// the wrapped broker; when it does not implement one, the methods fail with a
+// 501, as the handlers do for brokers without them.
+//
+// Pending deletions are kept in a state.InstanceStore, in memory unless
+// WithStore sets a shared one, so that deletions outlive the process and are
+// seen by every replica.
+type Broker struct {
+	domain.ServiceBroker
+
+	retention     time.Duration
+	purgeInterval time.Duration
+	logger        lager.Logger
+	now           func() time.Time
+	store         state.InstanceStore
+}
+
+// New returns a Broker deprovisioning instances through broker retention after
+// the platform deleted them. Run carries out the deletions whose window has
+// passed.
+func New(broker domain.ServiceBroker, retention time.Duration, logger lager.Logger) *Broker {
+	return &Broker{
+		ServiceBroker: broker,
+		retention:     retention,
+		purgeInterval: defaultPurgeInterval,
+		logger:        logger.Session("soft-delete"),
+		now:           time.Now,
+		store:         state.NewMemory(),
+	}
+}
+
+// WithClock replaces time.Now, to control the retention window in tests.
+func (b *Broker) WithClock(now func() time.Time) *Broker {
+	b.now = now
+	return b
+}
+
+// WithPurgeInterval sets how often Run looks for deletions whose retention
+// window has passed. It is a minute by default.
+func (b *Broker) WithPurgeInterval(interval time.Duration) *Broker {
+	b.purgeInterval = interval
+	return b
+}
+
+// WithStore keeps pending deletions in store instead of in memory. It should
+// not be the store holding the instances of the wrapped broker, since the
+// deletions are recorded as instances of their own.
+func (b *Broker) WithStore(store state.InstanceStore) *Broker {
+	b.store = store
+	return b
+}
+
+// Deprovision records the instance as pending deletion and responds that it
+// was deprovisioned, without asking the wrapped broker to deprovision it.
+// Deprovisions of instances the wrapped broker does not have, and second
+// deprovisions of pending instances, fail with
+// apiresponses.ErrInstanceDoesNotExist.
+func (b *Broker) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.DeprovisionServiceSpec{}, err
+	}
+	exists, err := b.instanceExists(ctx, instanceID)
+	if err != nil {
+		return domain.DeprovisionServiceSpec{}, err
+	}
+	if !exists {
+		return domain.DeprovisionServiceSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+
+	now := b.now()
+	deletion := Deletion{
+		InstanceID: instanceID,
+		ServiceID:  details.ServiceID,
+		PlanID:     details.PlanID,
+		DeletedAt:  now,
+		PurgeAt:    now.Add(b.retention),
+		details:    details,
+	}
+	err = b.save(ctx, deletion)
+	if errors.Is(err, state.ErrAlreadyExists) {
+		return domain.DeprovisionServiceSpec{}, apiresponses.ErrInstanceDoesNotExist
+	}
+	if err != nil {
+		return domain.DeprovisionServiceSpec{}, err
+	}
+	b.logger.Info("deletion-held-back", lager.Data{"instance-id": instanceID, "purge-at": deletion.PurgeAt})
+	return domain.DeprovisionServiceSpec{}, nil
+}
+
+// instanceExists reports whether the wrapped broker has the instance, asking
+// GetInstance or, for brokers which cannot fetch instances, looking for it in
+// ListInstances. Instances are assumed to exist when the wrapped broker can do
+// neither.
+func (b *Broker) instanceExists(ctx context.Context, instanceID string) (bool, error) {
+	_, err := b.ServiceBroker.GetInstance(ctx, instanceID)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, apiresponses.ErrInstanceDoesNotExist):
+		return false, nil
+	}
+
+	lister, ok := b.ServiceBroker.(domain.InstanceLister)
+	if !ok {
+		return true, nil
+	}
+	request := domain.ListInstancesRequest{}
+	for {
+		list, err := lister.ListInstances(ctx, request)
+		if err != nil {
+			return false, err
+		}
+		for _, instance := range list.Instances {
+			if instance.InstanceID == instanceID {
+				return true, nil
+			}
+		}
+		if list.NextCursor == "" {
+			return false, nil
+		}
+		request.Cursor = list.NextCursor
+	}
+}
+
+// RestoreInstance cancels the deletion of a pending instance, so that the
+// wrapped broker keeps it. The platform has already forgotten the instance;
+// registering it there again is up to the operator. Instances the wrapped
+// broker is already deprovisioning cannot be restored.
+func (b *Broker) RestoreInstance(ctx context.Context, instanceID string) error {
+	instance, err := b.store.GetInstance(ctx, instanceID)
+	if errors.Is(err, state.ErrNotFound) {
+		return apiresponses.ErrInstanceNotAwaitingDeletion
+	}
+	if err != nil {
+		return err
+	}
+	deletion, err := toDeletion(instance)
+	if err != nil {
+		return err
+	}
+	if deletion.Deprovisioning {
+		return apiresponses.ErrInstanceNotAwaitingDeletion
+	}
+
+	err = b.store.DeleteInstance(ctx, instanceID)
+	if errors.Is(err, state.ErrNotFound) {
+		return apiresponses.ErrInstanceNotAwaitingDeletion
+	}
+	if err != nil {
+		return err
+	}
+	b.logger.Info("instance-restored", lager.Data{"instance-id": instanceID})
+	return nil
+}
+
+// Pending returns the deletions held back, ordered by instance ID.
+func (b *Broker) Pending(ctx context.Context) ([]Deletion, error) {
+	deletions := []Deletion{}
+	cursor := ""
+	for {
+		instances, err := b.store.ListInstances(ctx, cursor, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			deletion, err := toDeletion(instance)
+			if err != nil {
+				return nil, err
+			}
+			deletions = append(deletions, deletion)
+		}
+		if len(instances) < listPageSize {
+			return deletions, nil
+		}
+		cursor = instances[len(instances)-1].ID
+	}
+}
+
+// Purge deprovisions, through the wrapped broker, the instances whose
+// retention window has passed. Asynchronous deprovisions are allowed; their
+// deletions stay pending, and following purges poll their last operation
+// until it succeeds. Deletions which fail, asynchronously or not, stay
+// pending, to be retried by the next purge; each failure is logged, and the
+// first is returned.
+func (b *Broker) Purge(ctx context.Context) error {
+	pending, err := b.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	now := b.now()
+	for _, deletion := range pending {
+		if now.Before(deletion.PurgeAt) {
+			continue
+		}
+		purged, err := b.purge(ctx, deletion)
+		if err != nil {
+			b.logger.Error("purge-failed", err, lager.Data{"instance-id": deletion.InstanceID})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if purged {
+			b.logger.Info("instance-purged", lager.Data{"instance-id": deletion.InstanceID})
+		}
+	}
+	return firstErr
+}
+
+// purge removes the deletion from the store before deprovisioning the
+// instance, or polling its asynchronous deprovision, so that it cannot be
+// restored, or purged by another replica, while this is carried out. It is
+// put back unless the instance is gone, reported by purged.
+func (b *Broker) purge(ctx context.Context, deletion Deletion) (purged bool, err error) {
+	err = b.store.DeleteInstance(ctx, deletion.InstanceID)
+	if errors.Is(err, state.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if deletion.Deprovisioning {
+		purged, err = b.pollDeprovision(ctx, &deletion)
+	} else {
+		purged, err = b.deprovision(ctx, &deletion)
+	}
+	if !purged {
+		if saveErr := b.save(ctx, deletion); saveErr != nil {
+			b.logger.Error("put-back-failed", saveErr, lager.Data{"instance-id": deletion.InstanceID})
+		}
+	}
+	return purged, err
+}
+
+// deprovision asks the wrapped broker to deprovision the instance, marking
+// the deletion as deprovisioning when the broker does so asynchronously.
+func (b *Broker) deprovision(ctx context.Context, deletion *Deletion) (bool, error) {
+	spec, err := b.ServiceBroker.Deprovision(ctx, deletion.InstanceID, deletion.details, true)
+	switch {
+	case errors.Is(err, apiresponses.ErrInstanceDoesNotExist):
+		return true, nil
+	case err != nil:
+		return false, err
+	case spec.IsAsync:
+		deletion.Deprovisioning = true
+		deletion.operationData = spec.OperationData
+		b.logger.Info("deprovision-started", lager.Data{"instance-id": deletion.InstanceID})
+		return false, nil
+	}
+	return true, nil
+}
+
+// pollDeprovision polls the asynchronous deprovision of the instance. When it
+// failed, the deletion is no longer marked as deprovisioning, so that the
+// next purge deprovisions the instance again.
+func (b *Broker) pollDeprovision(ctx context.Context, deletion *Deletion) (bool, error) {
+	operation, err := b.ServiceBroker.LastOperation(ctx, deletion.InstanceID, domain.PollDetails{
+		ServiceID:     deletion.ServiceID,
+		PlanID:        deletion.PlanID,
+		OperationData: deletion.operationData,
+	})
+	switch {
+	case errors.Is(err, apiresponses.ErrInstanceDoesNotExist):
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+
+	switch operation.State {
+	case domain.Succeeded:
+		return true, nil
+	case domain.Failed:
+		deletion.Deprovisioning = false
+		deletion.operationData = ""
+		return false, fmt.Errorf("deprovisioning instance %s failed: %s", deletion.InstanceID, operation.Description)
+	}
+	return false, nil
+}
+
+// Run purges the deletions whose retention window has passed, every purge
+// interval, until ctx is cancelled.
+func (b *Broker) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Purge(ctx)
+		}
+	}
+}
+
+func (b *Broker) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceAlreadyExists); err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	return b.ServiceBroker.Provision(ctx, instanceID, details, asyncAllowed)
+}
+
+func (b *Broker) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	return b.ServiceBroker.Update(ctx, instanceID, details, asyncAllowed)
+}
+
+func (b *Broker) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.GetInstanceDetailsSpec{}, err
+	}
+	return b.ServiceBroker.GetInstance(ctx, instanceID)
+}
+
+func (b *Broker) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.LastOperation{}, err
+	}
+	return b.ServiceBroker.LastOperation(ctx, instanceID, details)
+}
+
+func (b *Broker) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, asyncAllowed bool) (domain.Binding, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.Binding{}, err
+	}
+	return b.ServiceBroker.Bind(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+func (b *Broker) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.UnbindSpec{}, err
+	}
+	return b.ServiceBroker.Unbind(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+func (b *Broker) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.GetBindingSpec{}, err
+	}
+	return b.ServiceBroker.GetBinding(ctx, instanceID, bindingID)
+}
+
+func (b *Broker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.LastOperation{}, err
+	}
+	return b.ServiceBroker.LastBindingOperation(ctx, instanceID, bindingID, details)
+}
+
+// ListInstances lists the instances of the wrapped broker, leaving out those
+// pending deletion. Pages may therefore hold fewer instances than asked for.
+func (b *Broker) ListInstances(ctx context.Context, request domain.ListInstancesRequest) (domain.InstanceList, error) {
+	lister, ok := b.ServiceBroker.(domain.InstanceLister)
+	if !ok {
+		return domain.InstanceList{}, notSupported("listing service instances")
+	}
+	list, err := lister.ListInstances(ctx, request)
+	if err != nil {
+		return domain.InstanceList{}, err
+	}
+	instances := make([]domain.InstanceSummary, 0, len(list.Instances))
+	for _, instance := range list.Instances {
+		pending, err := b.isPending(ctx, instance.InstanceID)
+		if err != nil {
+			return domain.InstanceList{}, err
+		}
+		if !pending {
+			instances = append(instances, instance)
+		}
+	}
+	list.Instances = instances
+	return list, nil
+}
+
+// RemoveOrphanedInstance removes the instance through the wrapped broker,
+// dropping any deletion pending for it.
+func (b *Broker) RemoveOrphanedInstance(ctx context.Context, instanceID string) error {
+	remover, ok := b.ServiceBroker.(domain.OrphanRemover)
+	if !ok {
+		return notSupported("deleting orphaned service instances")
+	}
+	if err := remover.RemoveOrphanedInstance(ctx, instanceID); err != nil {
+		return err
+	}
+	if err := b.store.DeleteInstance(ctx, instanceID); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (b *Broker) ValidateProvision(ctx context.Context, instanceID string, details domain.ProvisionDetails) (domain.DryRunResult, error) {
+	validator, ok := b.ServiceBroker.(domain.ProvisionValidator)
+	if !ok {
+		return domain.DryRunResult{}, notSupported("dry runs of this request")
+	}
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceAlreadyExists); err != nil {
+		return domain.DryRunResult{}, err
+	}
+	return validator.ValidateProvision(ctx, instanceID, details)
+}
+
+func (b *Broker) ValidateUpdate(ctx context.Context, instanceID string, details domain.UpdateDetails) (domain.DryRunResult, error) {
+	validator, ok := b.ServiceBroker.(domain.UpdateValidator)
+	if !ok {
+		return domain.DryRunResult{}, notSupported("dry runs of this request")
+	}
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.DryRunResult{}, err
+	}
+	return validator.ValidateUpdate(ctx, instanceID, details)
+}
+
+func (b *Broker) ValidateBind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails) (domain.DryRunResult, error) {
+	validator, ok := b.ServiceBroker.(domain.BindValidator)
+	if !ok {
+		return domain.DryRunResult{}, notSupported("dry runs of this request")
+	}
+	if err := b.checkNotPending(ctx, instanceID, apiresponses.ErrInstanceDoesNotExist); err != nil {
+		return domain.DryRunResult{}, err
+	}
+	return validator.ValidateBind(ctx, instanceID, bindingID, details)
+}
+
+// checkNotPending returns pendingErr if the instance is pending deletion.
+func (b *Broker) checkNotPending(ctx context.Context, instanceID string, pendingErr error) error {
+	pending, err := b.isPending(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return pendingErr
+	}
+	return nil
+}
+
+func (b *Broker) isPending(ctx context.Context, instanceID string) (bool, error) {
+	_, err := b.store.GetInstance(ctx, instanceID)
+	if errors.Is(err, state.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Broker) save(ctx context.Context, deletion Deletion) error {
+	parameters, err := json.Marshal(record{
+		DeletedAt:      deletion.DeletedAt,
+		PurgeAt:        deletion.PurgeAt,
+		Details:        deletion.details,
+		Deprovisioning: deletion.Deprovisioning,
+		OperationData:  deletion.operationData,
+	})
+	if err != nil {
+		return err
+	}
+	return b.store.CreateInstance(ctx, state.Instance{
+		ID:         deletion.InstanceID,
+		ServiceID:  deletion.ServiceID,
+		PlanID:     deletion.PlanID,
+		Parameters: parameters,
+	})
+}
+
+func toDeletion(instance state.Instance) (Deletion, error) {
+	var r record
+	if err := json.Unmarshal(instance.Parameters, &r); err != nil {
+		return Deletion{}, fmt.Errorf("reading deletion of instance %s: %w", instance.ID, err)
+	}
+	return Deletion{
+		InstanceID:     instance.ID,
+		ServiceID:      instance.ServiceID,
+		PlanID:         instance.PlanID,
+		DeletedAt:      r.DeletedAt,
+		PurgeAt:        r.PurgeAt,
+		Deprovisioning: r.Deprovisioning,
+		details:        r.Details,
+		operationData:  r.OperationData,
+	}, nil
+}
+
+// notSupported is the error returned for an optional interface the wrapped
+// broker does not implement, matching the 501 of the handlers.
+func notSupported(capability string) error {
+	return apiresponses.NewFailureResponse(
+		fmt.Errorf("the broker does not support %s", capability),
+		http.StatusNotImplemented,
+		"not-supported",
+	)
+}
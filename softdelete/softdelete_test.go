@@ -0,0 +1,275 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package softdelete_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/fakes"
+	"github.com/sharma-tapas/brokerapi/softdelete"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+var _ = Describe("Broker", func() {
+	var (
+		ctx     context.Context
+		now     time.Time
+		wrapped *fakes.AutoFakeServiceBroker
+		broker  *softdelete.Broker
+	)
+
+	details := domain.DeprovisionDetails{ServiceID: "service-id", PlanID: "plan-id"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		now = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		wrapped = new(fakes.AutoFakeServiceBroker)
+		broker = softdelete.New(wrapped, time.Hour, lagertest.NewTestLogger("softdelete")).WithClock(func() time.Time { return now })
+	})
+
+	It("holds back deprovisions until the retention window has passed", func() {
+		spec, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.IsAsync).To(BeFalse())
+		Expect(wrapped.DeprovisionCallCount()).To(BeZero())
+		deletions := pending(broker)
+		Expect(deletions).To(HaveLen(1))
+		Expect(deletions[0].InstanceID).To(Equal("instance-id"))
+		Expect(deletions[0].ServiceID).To(Equal("service-id"))
+		Expect(deletions[0].DeletedAt).To(Equal(now))
+		Expect(deletions[0].PurgeAt).To(Equal(now.Add(time.Hour)))
+
+		now = now.Add(59 * time.Minute)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(BeZero())
+
+		now = now.Add(time.Minute)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(Equal(1))
+		_, instanceID, deprovisionDetails, asyncAllowed := wrapped.DeprovisionArgsForCall(0)
+		Expect(instanceID).To(Equal("instance-id"))
+		Expect(deprovisionDetails).To(Equal(details))
+		Expect(asyncAllowed).To(BeTrue())
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("treats pending instances as deleted", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.Provision(ctx, "instance-id", domain.ProvisionDetails{}, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceAlreadyExists))
+		_, err = broker.Update(ctx, "instance-id", domain.UpdateDetails{}, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.GetInstance(ctx, "instance-id")
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.LastOperation(ctx, "instance-id", domain.PollDetails{})
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.Bind(ctx, "instance-id", "binding-id", domain.BindDetails{}, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.GetBinding(ctx, "instance-id", "binding-id")
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.LastBindingOperation(ctx, "instance-id", "binding-id", domain.PollDetails{})
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		_, err = broker.Unbind(ctx, "instance-id", "binding-id", domain.UnbindDetails{}, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+
+		Expect(wrapped.Invocations()).To(HaveLen(1))
+		Expect(wrapped.GetInstanceCallCount()).To(Equal(1))
+
+		_, err = broker.GetInstance(ctx, "other-instance-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wrapped.GetInstanceCallCount()).To(Equal(2))
+	})
+
+	It("fails to deprovision instances the wrapped broker does not have", func() {
+		wrapped.GetInstanceReturns(domain.GetInstanceDetailsSpec{}, apiresponses.ErrInstanceDoesNotExist)
+
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("looks for the instance in ListInstances when the wrapped broker cannot fetch instances", func() {
+		wrapped.GetInstanceReturns(domain.GetInstanceDetailsSpec{}, errors.New("not implemented"))
+		lister := &listingBroker{
+			AutoFakeServiceBroker: wrapped,
+			pages: map[string]domain.InstanceList{
+				"":                  {Instances: []domain.InstanceSummary{{InstanceID: "first-instance-id"}}, NextCursor: "first-instance-id"},
+				"first-instance-id": {Instances: []domain.InstanceSummary{{InstanceID: "instance-id"}}},
+			},
+		}
+		broker = softdelete.New(lister, time.Hour, lagertest.NewTestLogger("softdelete")).WithClock(func() time.Time { return now })
+
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending(broker)).To(HaveLen(1))
+
+		_, err = broker.Deprovision(ctx, "unknown-instance-id", details, false)
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+		Expect(pending(broker)).To(HaveLen(1))
+	})
+
+	It("keeps asynchronous deletions pending until the deprovision succeeds", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(2 * time.Hour)
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{IsAsync: true, OperationData: "operation-data"}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		deletions := pending(broker)
+		Expect(deletions).To(HaveLen(1))
+		Expect(deletions[0].Deprovisioning).To(BeTrue())
+		Expect(broker.RestoreInstance(ctx, "instance-id")).To(Equal(apiresponses.ErrInstanceNotAwaitingDeletion))
+
+		wrapped.LastOperationReturns(domain.LastOperation{State: domain.InProgress}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(Equal(1))
+		Expect(wrapped.LastOperationCallCount()).To(Equal(1))
+		_, instanceID, pollDetails := wrapped.LastOperationArgsForCall(0)
+		Expect(instanceID).To(Equal("instance-id"))
+		Expect(pollDetails).To(Equal(domain.PollDetails{ServiceID: "service-id", PlanID: "plan-id", OperationData: "operation-data"}))
+		Expect(pending(broker)).To(HaveLen(1))
+
+		wrapped.LastOperationReturns(domain.LastOperation{State: domain.Succeeded}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("deprovisions again when an asynchronous deprovision fails", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(2 * time.Hour)
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{IsAsync: true}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+
+		wrapped.LastOperationReturns(domain.LastOperation{State: domain.Failed, Description: "backend unavailable"}, nil)
+		Expect(broker.Purge(ctx)).To(MatchError("deprovisioning instance instance-id failed: backend unavailable"))
+		deletions := pending(broker)
+		Expect(deletions).To(HaveLen(1))
+		Expect(deletions[0].Deprovisioning).To(BeFalse())
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(Equal(2))
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("restores pending instances", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(broker.RestoreInstance(ctx, "instance-id")).To(Succeed())
+		Expect(pending(broker)).To(BeEmpty())
+
+		now = now.Add(2 * time.Hour)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(BeZero())
+		_, err = broker.GetInstance(ctx, "instance-id")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("fails to restore instances which are not pending", func() {
+		Expect(broker.RestoreInstance(ctx, "instance-id")).To(Equal(apiresponses.ErrInstanceNotAwaitingDeletion))
+	})
+
+	It("keeps deletions which fail pending for the next purge", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(2 * time.Hour)
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{}, errors.New("backend unavailable"))
+		Expect(broker.Purge(ctx)).To(MatchError("backend unavailable"))
+		Expect(pending(broker)).To(HaveLen(1))
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{}, nil)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("keeps pending deletions in the store it is given", func() {
+		store := state.NewMemory()
+		broker = broker.WithStore(store)
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		restarted := softdelete.New(wrapped, time.Hour, lagertest.NewTestLogger("softdelete")).WithStore(store).WithClock(func() time.Time { return now })
+		deletions := pending(restarted)
+		Expect(deletions).To(HaveLen(1))
+		Expect(deletions[0].InstanceID).To(Equal("instance-id"))
+		Expect(deletions[0].DeletedAt).To(BeTemporally("==", now))
+		_, err = restarted.GetInstance(ctx, "instance-id")
+		Expect(err).To(Equal(apiresponses.ErrInstanceDoesNotExist))
+
+		now = now.Add(2 * time.Hour)
+		Expect(restarted.Purge(ctx)).To(Succeed())
+		Expect(wrapped.DeprovisionCallCount()).To(Equal(1))
+		_, _, deprovisionDetails, _ := wrapped.DeprovisionArgsForCall(0)
+		Expect(deprovisionDetails).To(Equal(details))
+		Expect(pending(broker)).To(BeEmpty())
+	})
+
+	It("fails the optional interfaces the wrapped broker does not implement with a 501", func() {
+		_, err := broker.ListInstances(ctx, domain.ListInstancesRequest{})
+		var failure *apiresponses.FailureResponse
+		Expect(errors.As(err, &failure)).To(BeTrue())
+		Expect(failure.ValidatedStatusCode(nil)).To(Equal(http.StatusNotImplemented))
+
+		err = broker.RemoveOrphanedInstance(ctx, "instance-id")
+		Expect(errors.As(err, &failure)).To(BeTrue())
+		Expect(failure.ValidatedStatusCode(nil)).To(Equal(http.StatusNotImplemented))
+
+		_, err = broker.ValidateProvision(ctx, "instance-id", domain.ProvisionDetails{})
+		Expect(errors.As(err, &failure)).To(BeTrue())
+		Expect(failure.ValidatedStatusCode(nil)).To(Equal(http.StatusNotImplemented))
+	})
+
+	It("considers instances the wrapped broker no longer has purged", func() {
+		_, err := broker.Deprovision(ctx, "instance-id", details, false)
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(2 * time.Hour)
+
+		wrapped.DeprovisionReturns(domain.DeprovisionServiceSpec{}, apiresponses.ErrInstanceDoesNotExist)
+		Expect(broker.Purge(ctx)).To(Succeed())
+		Expect(pending(broker)).To(BeEmpty())
+	})
+})
+
+type listingBroker struct {
+	*fakes.AutoFakeServiceBroker
+	pages map[string]domain.InstanceList
+}
+
+func (b *listingBroker) ListInstances(ctx context.Context, request domain.ListInstancesRequest) (domain.InstanceList, error) {
+	return b.pages[request.Cursor], nil
+}
+
+func pending(broker *softdelete.Broker) []softdelete.Deletion {
+	deletions, err := broker.Pending(context.Background())
+	Expect(err).NotTo(HaveOccurred())
+	return deletions
+}
@@ -0,0 +1,232 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package boltstore implements state.Store in a bbolt file, so that brokers
+// running on a single node keep their state without running a database.
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/state"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	instancesBucket  = []byte("instances")
+	operationsBucket = []byte("operations")
+	// latestBucket maps the instance and binding IDs of an operation to the
+	// ID of the most recently created operation on them.
+	latestBucket = []byte("latest_operations")
+)
+
+// Store is a state.Store keeping its contents in a bbolt database. It is safe
+// for concurrent use, but bbolt only lets one process open the file at a time.
+type Store struct {
+	db *bolt.DB
+}
+
+type instanceRecord struct {
+	ID           string          `json:"id"`
+	ServiceID    string          `json:"service_id"`
+	PlanID       string          `json:"plan_id"`
+	DashboardURL string          `json:"dashboard_url,omitempty"`
+	Parameters   json.RawMessage `json:"parameters,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+type operationRecord struct {
+	ID          string                    `json:"id"`
+	InstanceID  string                    `json:"instance_id"`
+	BindingID   string                    `json:"binding_id,omitempty"`
+	Type        domain.Operation          `json:"type"`
+	State       domain.LastOperationState `json:"state"`
+	Description string                    `json:"description,omitempty"`
+	Data        json.RawMessage           `json:"data,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+}
+
+// Open opens, or creates, the bbolt database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	store, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// New returns a Store using db, creating its buckets if needed.
+func New(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{instancesBucket, operationsBucket, latestBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) CreateInstance(ctx context.Context, instance state.Instance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(instancesBucket)
+		if bucket.Get([]byte(instance.ID)) != nil {
+			return state.ErrAlreadyExists
+		}
+		now := time.Now().UTC()
+		instance.CreatedAt, instance.UpdatedAt = now, now
+		return put(bucket, instance.ID, instanceRecord(instance))
+	})
+}
+
+func (s *Store) GetInstance(ctx context.Context, instanceID string) (state.Instance, error) {
+	var record instanceRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(instancesBucket), instanceID, &record)
+	})
+	return state.Instance(record), err
+}
+
+func (s *Store) UpdateInstance(ctx context.Context, instance state.Instance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(instancesBucket)
+		var current instanceRecord
+		if err := get(bucket, instance.ID, &current); err != nil {
+			return err
+		}
+		instance.CreatedAt = current.CreatedAt
+		instance.UpdatedAt = time.Now().UTC()
+		return put(bucket, instance.ID, instanceRecord(instance))
+	})
+}
+
+func (s *Store) DeleteInstance(ctx context.Context, instanceID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(instancesBucket)
+		if bucket.Get([]byte(instanceID)) == nil {
+			return state.ErrNotFound
+		}
+		return bucket.Delete([]byte(instanceID))
+	})
+}
+
+func (s *Store) ListInstances(ctx context.Context, cursor string, limit int) ([]state.Instance, error) {
+	instances := []state.Instance{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(instancesBucket).Cursor()
+		key, value := c.Seek([]byte(cursor))
+		if key != nil && bytes.Equal(key, []byte(cursor)) {
+			key, value = c.Next()
+		}
+		for ; key != nil && len(instances) < limit; key, value = c.Next() {
+			var record instanceRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			instances = append(instances, state.Instance(record))
+		}
+		return nil
+	})
+	return instances, err
+}
+
+func (s *Store) SaveOperation(ctx context.Context, operation state.Operation) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(operationsBucket)
+		now := time.Now().UTC()
+		operation.CreatedAt, operation.UpdatedAt = now, now
+
+		var current operationRecord
+		switch err := get(bucket, operation.ID, &current); err {
+		case nil:
+			operation.CreatedAt = current.CreatedAt
+		case state.ErrNotFound:
+			if err := tx.Bucket(latestBucket).Put(targetKey(operation.InstanceID, operation.BindingID), []byte(operation.ID)); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return put(bucket, operation.ID, operationRecord(operation))
+	})
+}
+
+func (s *Store) GetOperation(ctx context.Context, operationID string) (state.Operation, error) {
+	var record operationRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(operationsBucket), operationID, &record)
+	})
+	return state.Operation(record), err
+}
+
+func (s *Store) LatestOperation(ctx context.Context, instanceID, bindingID string) (state.Operation, error) {
+	var record operationRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		operationID := tx.Bucket(latestBucket).Get(targetKey(instanceID, bindingID))
+		if operationID == nil {
+			return state.ErrNotFound
+		}
+		return get(tx.Bucket(operationsBucket), string(operationID), &record)
+	})
+	return state.Operation(record), err
+}
+
+// targetKey prefixes the instance ID with its length, so that no pair of
+// instance and binding IDs shares a key with another.
+func targetKey(instanceID, bindingID string) []byte {
+	key := make([]byte, 4, 4+len(instanceID)+len(bindingID))
+	binary.BigEndian.PutUint32(key, uint32(len(instanceID)))
+	key = append(key, instanceID...)
+	return append(key, bindingID...)
+}
+
+func get(bucket *bolt.Bucket, key string, record interface{}) error {
+	value := bucket.Get([]byte(key))
+	if value == nil {
+		return state.ErrNotFound
+	}
+	return json.Unmarshal(value, record)
+}
+
+func put(bucket *bolt.Bucket, key string, record interface{}) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), value)
+}
+
+var _ state.Store = (*Store)(nil)
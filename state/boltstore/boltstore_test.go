@@ -0,0 +1,84 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/state"
+	"github.com/sharma-tapas/brokerapi/state/boltstore"
+	"github.com/sharma-tapas/brokerapi/state/storetest"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir    string
+		stores []*boltstore.Store
+	)
+
+	open := func(name string) *boltstore.Store {
+		store, err := boltstore.Open(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		stores = append(stores, store)
+		return store
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "boltstore")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+		stores = nil
+		os.RemoveAll(dir)
+	})
+
+	storetest.ItBehavesLikeAStore(func() state.Store { return open("state.db") })
+
+	It("keeps its contents when reopened", func() {
+		ctx := context.Background()
+		store := open("reopened.db")
+		Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1", PlanID: "plan-id"})).To(Succeed())
+		Expect(store.SaveOperation(ctx, state.Operation{ID: "operation-1", InstanceID: "instance-1"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		store = open("reopened.db")
+		instance, err := store.GetInstance(ctx, "instance-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.PlanID).To(Equal("plan-id"))
+		operation, err := store.LatestOperation(ctx, "instance-1", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation.ID).To(Equal("operation-1"))
+	})
+
+	It("does not confuse instance and binding IDs", func() {
+		ctx := context.Background()
+		store := open("targets.db")
+		Expect(store.SaveOperation(ctx, state.Operation{ID: "operation-1", InstanceID: "ab", BindingID: "c"})).To(Succeed())
+
+		_, err := store.LatestOperation(ctx, "a", "bc")
+		Expect(err).To(Equal(state.ErrNotFound))
+	})
+})
@@ -0,0 +1,161 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store which keeps its contents in memory. It is safe for
+// concurrent use.
+type Memory struct {
+	mutex      sync.Mutex
+	instances  map[string]Instance
+	operations map[string]Operation
+	// sequence orders the operations created at the same time.
+	sequence map[string]int
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		instances:  map[string]Instance{},
+		operations: map[string]Operation{},
+		sequence:   map[string]int{},
+	}
+}
+
+func (m *Memory) CreateInstance(ctx context.Context, instance Instance) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.instances[instance.ID]; ok {
+		return ErrAlreadyExists
+	}
+	now := time.Now().UTC()
+	instance.CreatedAt, instance.UpdatedAt = now, now
+	m.instances[instance.ID] = instance
+	return nil
+}
+
+func (m *Memory) GetInstance(ctx context.Context, instanceID string) (Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return Instance{}, ErrNotFound
+	}
+	return instance, nil
+}
+
+func (m *Memory) UpdateInstance(ctx context.Context, instance Instance) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current, ok := m.instances[instance.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	instance.CreatedAt = current.CreatedAt
+	instance.UpdatedAt = time.Now().UTC()
+	m.instances[instance.ID] = instance
+	return nil
+}
+
+func (m *Memory) DeleteInstance(ctx context.Context, instanceID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.instances[instanceID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.instances, instanceID)
+	return nil
+}
+
+func (m *Memory) ListInstances(ctx context.Context, cursor string, limit int) ([]Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := make([]string, 0, len(m.instances))
+	for id := range m.instances {
+		if id > cursor {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	instances := make([]Instance, len(ids))
+	for i, id := range ids {
+		instances[i] = m.instances[id]
+	}
+	return instances, nil
+}
+
+func (m *Memory) SaveOperation(ctx context.Context, operation Operation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now().UTC()
+	operation.CreatedAt, operation.UpdatedAt = now, now
+	if current, ok := m.operations[operation.ID]; ok {
+		operation.CreatedAt = current.CreatedAt
+	} else {
+		m.sequence[operation.ID] = len(m.sequence)
+	}
+	m.operations[operation.ID] = operation
+	return nil
+}
+
+func (m *Memory) GetOperation(ctx context.Context, operationID string) (Operation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operation, ok := m.operations[operationID]
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	return operation, nil
+}
+
+func (m *Memory) LatestOperation(ctx context.Context, instanceID, bindingID string) (Operation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var latest Operation
+	found := false
+	for id, operation := range m.operations {
+		if operation.InstanceID != instanceID || operation.BindingID != bindingID {
+			continue
+		}
+		if !found || m.sequence[id] > m.sequence[latest.ID] {
+			latest, found = operation, true
+		}
+	}
+	if !found {
+		return Operation{}, ErrNotFound
+	}
+	return latest, nil
+}
+
+var _ Store = (*Memory)(nil)
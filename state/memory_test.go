@@ -0,0 +1,26 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/sharma-tapas/brokerapi/state"
+	"github.com/sharma-tapas/brokerapi/state/storetest"
+)
+
+var _ = Describe("Memory", func() {
+	storetest.ItBehavesLikeAStore(func() state.Store { return state.NewMemory() })
+})
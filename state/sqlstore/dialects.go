@@ -0,0 +1,104 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import "strings"
+
+// Migrations are applied in order and never changed once released: schema
+// changes are made by appending a migration to both dialects.
+
+// Postgres is the dialect of PostgreSQL 9.5 and later.
+var Postgres = Dialect{
+	numbered: true,
+	migrations: [][]string{
+		{
+			`CREATE TABLE brokerapi_instances (
+				id            VARCHAR(255) PRIMARY KEY,
+				service_id    VARCHAR(255) NOT NULL,
+				plan_id       VARCHAR(255) NOT NULL,
+				dashboard_url VARCHAR(2048) NOT NULL,
+				parameters    TEXT,
+				created_at    TIMESTAMP NOT NULL,
+				updated_at    TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE brokerapi_operations (
+				id          VARCHAR(255) PRIMARY KEY,
+				seq         BIGSERIAL NOT NULL,
+				instance_id VARCHAR(255) NOT NULL,
+				binding_id  VARCHAR(255) NOT NULL,
+				type        VARCHAR(64) NOT NULL,
+				state       VARCHAR(32) NOT NULL,
+				description TEXT NOT NULL,
+				data        TEXT,
+				created_at  TIMESTAMP NOT NULL,
+				updated_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX brokerapi_operations_target ON brokerapi_operations (instance_id, binding_id, seq)`,
+		},
+	},
+	lockMigrations:  "SELECT true FROM pg_advisory_lock(?)",
+	unlockMigration: "SELECT pg_advisory_unlock(?)",
+	migrationLock:   int64(0x62726f6b65726170), // "brokerap"
+	createInstance:  "INSERT INTO brokerapi_instances (" + instanceColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+	upsertOperation: "INSERT INTO brokerapi_operations (" + operationColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+		"ON CONFLICT (id) DO UPDATE SET instance_id = EXCLUDED.instance_id, binding_id = EXCLUDED.binding_id, " +
+		"type = EXCLUDED.type, state = EXCLUDED.state, description = EXCLUDED.description, data = EXCLUDED.data, " +
+		"updated_at = EXCLUDED.updated_at",
+}
+
+// MySQL is the dialect of MySQL 5.7 and later, using InnoDB tables.
+var MySQL = Dialect{
+	migrations: [][]string{
+		{
+			`CREATE TABLE brokerapi_instances (
+				id            VARCHAR(255) PRIMARY KEY,
+				service_id    VARCHAR(255) NOT NULL,
+				plan_id       VARCHAR(255) NOT NULL,
+				dashboard_url VARCHAR(2048) NOT NULL,
+				parameters    MEDIUMTEXT,
+				created_at    DATETIME(6) NOT NULL,
+				updated_at    DATETIME(6) NOT NULL
+			) ENGINE=InnoDB`,
+			`CREATE TABLE brokerapi_operations (
+				id          VARCHAR(255) PRIMARY KEY,
+				seq         BIGINT NOT NULL AUTO_INCREMENT UNIQUE,
+				instance_id VARCHAR(255) NOT NULL,
+				binding_id  VARCHAR(255) NOT NULL,
+				type        VARCHAR(64) NOT NULL,
+				state       VARCHAR(32) NOT NULL,
+				description TEXT NOT NULL,
+				data        MEDIUMTEXT,
+				created_at  DATETIME(6) NOT NULL,
+				updated_at  DATETIME(6) NOT NULL,
+				INDEX brokerapi_operations_target (instance_id, binding_id, seq)
+			) ENGINE=InnoDB`,
+		},
+	},
+	lockMigrations:  "SELECT COALESCE(GET_LOCK(?, -1), 0) = 1",
+	unlockMigration: "SELECT RELEASE_LOCK(?)",
+	migrationLock:   "brokerapi:schema_migrations",
+	createInstance:  "INSERT INTO brokerapi_instances (" + instanceColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?)",
+	duplicateKey:    mysqlDuplicateKey,
+	upsertOperation: "INSERT INTO brokerapi_operations (" + operationColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE instance_id = VALUES(instance_id), binding_id = VALUES(binding_id), " +
+		"type = VALUES(type), state = VALUES(state), description = VALUES(description), data = VALUES(data), " +
+		"updated_at = VALUES(updated_at)",
+}
+
+// mysqlDuplicateKey reports whether err is the ER_DUP_ENTRY error of MySQL.
+func mysqlDuplicateKey(err error) bool {
+	return strings.HasPrefix(err.Error(), "Error 1062")
+}
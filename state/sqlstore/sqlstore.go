@@ -0,0 +1,313 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlstore implements state.Store on top of database/sql, for
+// PostgreSQL and MySQL. The tables are created and upgraded by Migrate. The
+// driver is chosen by the broker; with MySQL, parseTime=true must be set in
+// the DSN, and instances which already exist are recognised by the error
+// number 1062 which github.com/go-sql-driver/mysql starts its errors with.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+// Dialect holds the SQL which differs between databases.
+type Dialect struct {
+	numbered        bool
+	migrations      [][]string
+	lockMigrations  string
+	unlockMigration string
+	migrationLock   interface{}
+	createInstance  string
+	duplicateKey    func(err error) bool
+	upsertOperation string
+}
+
+var errMigrationLock = errors.New("sqlstore: could not lock the schema migrations")
+
+const (
+	instanceColumns  = "id, service_id, plan_id, dashboard_url, parameters, created_at, updated_at"
+	operationColumns = "id, instance_id, binding_id, type, state, description, data, created_at, updated_at"
+)
+
+// Store is a state.Store keeping its contents in a SQL database. It is safe
+// for concurrent use.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New returns a Store using db, whose schema must have been migrated with
+// Migrate.
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// Migrate creates the tables of the store, or upgrades them to the current
+// schema. Migrations which were already applied are skipped, so Migrate can
+// be called every time the broker starts. Replicas starting together wait
+// for each other on an advisory lock, and each migration is applied in a
+// transaction along with its version. MySQL commits schema changes as they
+// are made, so a migration failing there may have to be finished by hand.
+func (s *Store) Migrate(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, s.rebind(s.dialect.lockMigrations), s.dialect.migrationLock).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return errMigrationLock
+	}
+	// The lock has to be released even once ctx is done, before the
+	// connection returns to the pool.
+	defer conn.ExecContext(context.Background(), s.rebind(s.dialect.unlockMigration), s.dialect.migrationLock)
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS brokerapi_schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i, statements := range s.dialect.migrations {
+		version := i + 1
+		if applied[version] {
+			continue
+		}
+		if err := s.migrate(ctx, conn, version, statements); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM brokerapi_schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// migrate applies the statements of one migration and records its version.
+func (s *Store) migrate(ctx context.Context, conn *sql.Conn, version int, statements []string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind("INSERT INTO brokerapi_schema_migrations (version) VALUES (?)"), version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) CreateInstance(ctx context.Context, instance state.Instance) error {
+	now := time.Now().UTC()
+	result, err := s.exec(ctx, s.dialect.createInstance,
+		instance.ID, instance.ServiceID, instance.PlanID, instance.DashboardURL, nullableJSON(instance.Parameters), now, now,
+	)
+	if err != nil {
+		if s.dialect.duplicateKey != nil && s.dialect.duplicateKey(err) {
+			return state.ErrAlreadyExists
+		}
+		return err
+	}
+	return expectRow(result, state.ErrAlreadyExists)
+}
+
+func (s *Store) GetInstance(ctx context.Context, instanceID string) (state.Instance, error) {
+	row := s.queryRow(ctx, "SELECT "+instanceColumns+" FROM brokerapi_instances WHERE id = ?", instanceID)
+	return scanInstance(row)
+}
+
+func (s *Store) UpdateInstance(ctx context.Context, instance state.Instance) error {
+	result, err := s.exec(ctx,
+		"UPDATE brokerapi_instances SET service_id = ?, plan_id = ?, dashboard_url = ?, parameters = ?, updated_at = ? WHERE id = ?",
+		instance.ServiceID, instance.PlanID, instance.DashboardURL, nullableJSON(instance.Parameters), time.Now().UTC(), instance.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, state.ErrNotFound)
+}
+
+func (s *Store) DeleteInstance(ctx context.Context, instanceID string) error {
+	result, err := s.exec(ctx, "DELETE FROM brokerapi_instances WHERE id = ?", instanceID)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, state.ErrNotFound)
+}
+
+func (s *Store) ListInstances(ctx context.Context, cursor string, limit int) ([]state.Instance, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.rebind("SELECT "+instanceColumns+" FROM brokerapi_instances WHERE id > ? ORDER BY id LIMIT ?"), cursor, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []state.Instance{}
+	for rows.Next() {
+		instance, err := scanInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+func (s *Store) SaveOperation(ctx context.Context, operation state.Operation) error {
+	now := time.Now().UTC()
+	_, err := s.exec(ctx, s.dialect.upsertOperation,
+		operation.ID, operation.InstanceID, operation.BindingID, string(operation.Type), string(operation.State),
+		operation.Description, nullableJSON(operation.Data), now, now,
+	)
+	return err
+}
+
+func (s *Store) GetOperation(ctx context.Context, operationID string) (state.Operation, error) {
+	row := s.queryRow(ctx, "SELECT "+operationColumns+" FROM brokerapi_operations WHERE id = ?", operationID)
+	return scanOperation(row)
+}
+
+func (s *Store) LatestOperation(ctx context.Context, instanceID, bindingID string) (state.Operation, error) {
+	row := s.queryRow(ctx,
+		"SELECT "+operationColumns+" FROM brokerapi_operations WHERE instance_id = ? AND binding_id = ? ORDER BY seq DESC LIMIT 1",
+		instanceID, bindingID,
+	)
+	return scanOperation(row)
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// rebind replaces the ? placeholders of query with $1, $2... for databases
+// which number them.
+func (s *Store) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+	var rebound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			rebound.WriteRune(r)
+			continue
+		}
+		n++
+		rebound.WriteString("$" + strconv.Itoa(n))
+	}
+	return rebound.String()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInstance(row scanner) (state.Instance, error) {
+	var instance state.Instance
+	var parameters []byte
+	err := row.Scan(&instance.ID, &instance.ServiceID, &instance.PlanID, &instance.DashboardURL, &parameters, &instance.CreatedAt, &instance.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return state.Instance{}, state.ErrNotFound
+	}
+	if err != nil {
+		return state.Instance{}, err
+	}
+	instance.Parameters = parameters
+	instance.CreatedAt, instance.UpdatedAt = instance.CreatedAt.UTC(), instance.UpdatedAt.UTC()
+	return instance, nil
+}
+
+func scanOperation(row scanner) (state.Operation, error) {
+	var operation state.Operation
+	var operationType, operationState string
+	var data []byte
+	err := row.Scan(&operation.ID, &operation.InstanceID, &operation.BindingID, &operationType, &operationState,
+		&operation.Description, &data, &operation.CreatedAt, &operation.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return state.Operation{}, state.ErrNotFound
+	}
+	if err != nil {
+		return state.Operation{}, err
+	}
+	operation.Type = domain.Operation(operationType)
+	operation.State = domain.LastOperationState(operationState)
+	operation.Data = data
+	operation.CreatedAt, operation.UpdatedAt = operation.CreatedAt.UTC(), operation.UpdatedAt.UTC()
+	return operation, nil
+}
+
+// nullableJSON stores missing JSON as NULL rather than an empty string.
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// expectRow returns err unless the statement changed a row.
+func expectRow(result sql.Result, err error) error {
+	affected, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return rowsErr
+	}
+	if affected == 0 {
+		return err
+	}
+	return nil
+}
+
+var _ state.Store = (*Store)(nil)
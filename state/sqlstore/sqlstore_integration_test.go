@@ -0,0 +1,93 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/state"
+	"github.com/sharma-tapas/brokerapi/state/sqlstore"
+	"github.com/sharma-tapas/brokerapi/state/storetest"
+)
+
+// The integration specs run the store against the databases named by
+// SQLSTORE_POSTGRES_DSN and SQLSTORE_MYSQL_DSN, whose tables they empty:
+//
+//	SQLSTORE_POSTGRES_DSN=postgres://localhost/brokerapi_test?sslmode=disable \
+//	SQLSTORE_MYSQL_DSN='root@tcp(localhost)/brokerapi_test?parseTime=true' \
+//	go test -tags integration ./state/sqlstore
+var _ = Describe("Store against a database", func() {
+	itUsesTheDatabase("PostgreSQL", "postgres", os.Getenv("SQLSTORE_POSTGRES_DSN"), sqlstore.Postgres)
+	itUsesTheDatabase("MySQL", "mysql", os.Getenv("SQLSTORE_MYSQL_DSN"), sqlstore.MySQL)
+})
+
+func itUsesTheDatabase(name, driverName, dsn string, dialect sqlstore.Dialect) {
+	Describe(name, func() {
+		var db *sql.DB
+
+		BeforeEach(func() {
+			if dsn == "" {
+				Skip("the DSN of the " + name + " database is not set")
+			}
+			var err error
+			db, err = sql.Open(driverName, dsn)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if db != nil {
+				db.Close()
+			}
+		})
+
+		It("migrates the schema from several replicas at once", func() {
+			var wg sync.WaitGroup
+			errs := make(chan error, 4)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					errs <- sqlstore.New(db, dialect).Migrate(context.Background())
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		storetest.ItBehavesLikeAStore(func() state.Store {
+			store := sqlstore.New(db, dialect)
+			Expect(store.Migrate(context.Background())).To(Succeed())
+			for _, table := range []string{"brokerapi_instances", "brokerapi_operations"} {
+				_, err := db.Exec("DELETE FROM " + table)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			return store
+		})
+	})
+}
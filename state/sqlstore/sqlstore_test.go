@@ -0,0 +1,304 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/state"
+	"github.com/sharma-tapas/brokerapi/state/sqlstore"
+)
+
+// The specs run against a fake driver which records the statements it is
+// sent. The SQL itself is checked against real databases by the specs of
+// sqlstore_integration_test.go, built with the integration tag.
+var _ = Describe("Store", func() {
+	var (
+		ctx   context.Context
+		fake  *fakeDatabase
+		db    *sql.DB
+		store *sqlstore.Store
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = &fakeDatabase{versions: map[int64]bool{}, rowsAffected: 1}
+		db = sql.OpenDB(fakeConnector{fake})
+		store = sqlstore.New(db, sqlstore.Postgres)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("Migrate", func() {
+		It("creates the tables", func() {
+			Expect(store.Migrate(ctx)).To(Succeed())
+
+			Expect(fake.statements()).To(ContainElement(ContainSubstring("CREATE TABLE brokerapi_instances")))
+			Expect(fake.statements()).To(ContainElement(ContainSubstring("CREATE TABLE brokerapi_operations")))
+			Expect(fake.versions).To(Equal(map[int64]bool{1: true}))
+		})
+
+		It("skips the migrations already applied", func() {
+			Expect(store.Migrate(ctx)).To(Succeed())
+			fake.executed = nil
+
+			Expect(store.Migrate(ctx)).To(Succeed())
+			Expect(fake.statements()).NotTo(ContainElement(ContainSubstring("CREATE TABLE brokerapi_instances")))
+		})
+
+		It("returns the errors of the database", func() {
+			fake.err = errors.New("permission denied")
+			Expect(store.Migrate(ctx)).To(MatchError("permission denied"))
+		})
+
+		It("applies each migration in a transaction while holding a lock", func() {
+			Expect(store.Migrate(ctx)).To(Succeed())
+
+			statements := fake.statements()
+			Expect(statements[0]).To(Equal("SELECT true FROM pg_advisory_lock($1)"))
+			Expect(statements).To(ContainElement("BEGIN"))
+			Expect(statements[len(statements)-3]).To(HavePrefix("INSERT INTO brokerapi_schema_migrations"))
+			Expect(statements[len(statements)-2]).To(Equal("COMMIT"))
+			Expect(statements[len(statements)-1]).To(Equal("SELECT pg_advisory_unlock($1)"))
+		})
+
+		It("rolls back a migration which fails and releases the lock", func() {
+			fake.failing = "CREATE TABLE brokerapi_operations"
+			Expect(store.Migrate(ctx)).To(MatchError("syntax error"))
+
+			statements := fake.statements()
+			Expect(statements).To(ContainElement("ROLLBACK"))
+			Expect(statements).NotTo(ContainElement("COMMIT"))
+			Expect(statements[len(statements)-1]).To(Equal("SELECT pg_advisory_unlock($1)"))
+			Expect(fake.versions).To(BeEmpty())
+		})
+
+		It("fails when the lock cannot be taken", func() {
+			fake.lockRefused = true
+			store = sqlstore.New(db, sqlstore.MySQL)
+			Expect(store.Migrate(ctx)).To(MatchError("sqlstore: could not lock the schema migrations"))
+			Expect(fake.statements()).To(Equal([]string{"SELECT COALESCE(GET_LOCK(?, -1), 0) = 1"}))
+		})
+	})
+
+	Describe("instances", func() {
+		It("numbers the placeholders for PostgreSQL", func() {
+			Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1"})).To(Succeed())
+			Expect(fake.statements()[0]).To(ContainSubstring("VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING"))
+		})
+
+		It("keeps the placeholders for MySQL", func() {
+			store = sqlstore.New(db, sqlstore.MySQL)
+			Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1"})).To(Succeed())
+			Expect(fake.statements()[0]).To(HavePrefix("INSERT INTO brokerapi_instances"))
+			Expect(fake.statements()[0]).To(HaveSuffix("VALUES (?, ?, ?, ?, ?, ?, ?)"))
+		})
+
+		It("tells duplicate instances from other errors for MySQL", func() {
+			store = sqlstore.New(db, sqlstore.MySQL)
+
+			fake.err = errors.New("Error 1062 (23000): Duplicate entry 'instance-1' for key 'PRIMARY'")
+			Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1"})).To(Equal(state.ErrAlreadyExists))
+
+			fake.err = errors.New("Error 1406 (22001): Data too long for column 'plan_id' at row 1")
+			Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1"})).To(MatchError(fake.err))
+		})
+
+		It("reports instances which already exist or are missing", func() {
+			fake.rowsAffected = 0
+			Expect(store.CreateInstance(ctx, state.Instance{ID: "instance-1"})).To(Equal(state.ErrAlreadyExists))
+			Expect(store.UpdateInstance(ctx, state.Instance{ID: "instance-1"})).To(Equal(state.ErrNotFound))
+			Expect(store.DeleteInstance(ctx, "instance-1")).To(Equal(state.ErrNotFound))
+
+			_, err := store.GetInstance(ctx, "instance-1")
+			Expect(err).To(Equal(state.ErrNotFound))
+		})
+
+		It("reads instances", func() {
+			createdAt := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+			fake.rows = [][]driver.Value{{"instance-1", "service-id", "plan-id", "", []byte(`{"size":1}`), createdAt, createdAt}}
+
+			instance, err := store.GetInstance(ctx, "instance-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instance).To(Equal(state.Instance{
+				ID:         "instance-1",
+				ServiceID:  "service-id",
+				PlanID:     "plan-id",
+				Parameters: []byte(`{"size":1}`),
+				CreatedAt:  createdAt,
+				UpdatedAt:  createdAt,
+			}))
+		})
+	})
+
+	Describe("operations", func() {
+		It("upserts operations", func() {
+			Expect(store.SaveOperation(ctx, state.Operation{ID: "operation-1", Type: domain.OperationProvision, State: domain.InProgress})).To(Succeed())
+			Expect(fake.statements()[0]).To(ContainSubstring("ON CONFLICT (id) DO UPDATE SET"))
+			Expect(fake.executed[0].args).To(ContainElement("in progress"))
+		})
+
+		It("finds the latest operation in creation order", func() {
+			createdAt := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+			fake.rows = [][]driver.Value{{"operation-2", "instance-1", "", "update", "failed", "out of capacity", nil, createdAt, createdAt}}
+
+			operation, err := store.LatestOperation(ctx, "instance-1", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operation.ID).To(Equal("operation-2"))
+			Expect(operation.Type).To(Equal(domain.OperationUpdate))
+			Expect(operation.LastOperation()).To(Equal(domain.LastOperation{State: domain.Failed, Description: "out of capacity"}))
+			Expect(operation.Data).To(BeNil())
+			Expect(fake.statements()[0]).To(ContainSubstring("ORDER BY seq DESC LIMIT 1"))
+		})
+	})
+})
+
+// fakeDatabase answers every query with rows, and records the schema
+// migrations applied. Statements starting with failing fail with a syntax
+// error.
+type fakeDatabase struct {
+	mutex        sync.Mutex
+	executed     []fakeStatement
+	versions     map[int64]bool
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+	failing      string
+	lockRefused  bool
+}
+
+type fakeStatement struct {
+	query string
+	args  []interface{}
+}
+
+func (f *fakeDatabase) statements() []string {
+	var statements []string
+	for _, statement := range f.executed {
+		statements = append(statements, statement.query)
+	}
+	return statements
+}
+
+func (f *fakeDatabase) record(query string, args []driver.NamedValue) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	if f.failing != "" && strings.HasPrefix(strings.TrimSpace(query), f.failing) {
+		return errors.New("syntax error")
+	}
+	statement := fakeStatement{query: query}
+	for _, arg := range args {
+		statement.args = append(statement.args, arg.Value)
+	}
+	f.executed = append(f.executed, statement)
+	if strings.HasPrefix(query, "INSERT INTO brokerapi_schema_migrations") {
+		f.versions[args[0].Value.(int64)] = true
+	}
+	return nil
+}
+
+type fakeConnector struct {
+	database *fakeDatabase
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{database: c.database}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	database *fakeDatabase
+}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	if err := c.database.record("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return fakeTx{database: c.database}, nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.database.record(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(c.database.rowsAffected), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.database.record(query, args); err != nil {
+		return nil, err
+	}
+	if strings.Contains(query, "_lock(") || strings.Contains(query, "GET_LOCK(") {
+		return &fakeRows{values: [][]driver.Value{{!c.database.lockRefused}}}, nil
+	}
+	if query == "SELECT version FROM brokerapi_schema_migrations" {
+		rows := &fakeRows{}
+		for version := range c.database.versions {
+			rows.values = append(rows.values, []driver.Value{version})
+		}
+		return rows, nil
+	}
+	return &fakeRows{values: c.database.rows}, nil
+}
+
+type fakeTx struct {
+	database *fakeDatabase
+}
+
+func (t fakeTx) Commit() error   { return t.database.record("COMMIT", nil) }
+func (t fakeTx) Rollback() error { return t.database.record("ROLLBACK", nil) }
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"column"}
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state defines the stores an asynchronous broker can keep its
+// instances and operations in, so that it can answer last_operation polls and
+// GET requests after a restart or from another replica. Memory keeps them for
+// the lifetime of the process; the sqlstore package keeps them in a
+// PostgreSQL or MySQL database.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+)
+
+var (
+	// ErrNotFound is returned when the instance or operation does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when creating an instance which exists.
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// Instance is a service instance as recorded by the broker.
+type Instance struct {
+	ID           string
+	ServiceID    string
+	PlanID       string
+	DashboardURL string
+	Parameters   json.RawMessage
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Operation is an asynchronous operation on an instance, or on one of its
+// bindings when BindingID is set.
+type Operation struct {
+	ID          string
+	InstanceID  string
+	BindingID   string
+	Type        domain.Operation
+	State       domain.LastOperationState
+	Description string
+	// Data is any context the broker needs to resume the operation, such as
+	// the ID of a job in the backing service.
+	Data      json.RawMessage
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// LastOperation returns the operation as reported to the platform.
+func (o Operation) LastOperation() domain.LastOperation {
+	return domain.LastOperation{State: o.State, Description: o.Description}
+}
+
+// InstanceStore keeps service instances. Stores set CreatedAt and UpdatedAt.
+type InstanceStore interface {
+	// CreateInstance returns ErrAlreadyExists if the instance exists.
+	CreateInstance(ctx context.Context, instance Instance) error
+	// GetInstance returns ErrNotFound if the instance does not exist.
+	GetInstance(ctx context.Context, instanceID string) (Instance, error)
+	// UpdateInstance returns ErrNotFound if the instance does not exist.
+	UpdateInstance(ctx context.Context, instance Instance) error
+	// DeleteInstance returns ErrNotFound if the instance does not exist.
+	DeleteInstance(ctx context.Context, instanceID string) error
+	// ListInstances returns at most limit instances ordered by ID, starting
+	// after the instance with ID cursor. An empty cursor starts at the first.
+	ListInstances(ctx context.Context, cursor string, limit int) ([]Instance, error)
+}
+
+// OperationStore keeps asynchronous operations. Stores set CreatedAt and
+// UpdatedAt.
+type OperationStore interface {
+	// SaveOperation creates the operation, or replaces the one with the
+	// same ID.
+	SaveOperation(ctx context.Context, operation Operation) error
+	// GetOperation returns ErrNotFound if the operation does not exist.
+	GetOperation(ctx context.Context, operationID string) (Operation, error)
+	// LatestOperation returns the most recently created operation on the
+	// instance, or on its binding when bindingID is not empty. It returns
+	// ErrNotFound if there is none.
+	LatestOperation(ctx context.Context, instanceID, bindingID string) (Operation, error)
+}
+
+// Store keeps both instances and operations.
+type Store interface {
+	InstanceStore
+	OperationStore
+}
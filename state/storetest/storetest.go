@@ -0,0 +1,169 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storetest checks that an implementation of the state package
+// interfaces behaves like the others, so that brokers can switch stores
+// without surprises.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/state"
+)
+
+// ItBehavesLikeAStore adds the specs every state.Store must pass to the
+// enclosing ginkgo container. newStore is called before each spec and must
+// return an empty store.
+func ItBehavesLikeAStore(newStore func() state.Store) {
+	var (
+		ctx   context.Context
+		store state.Store
+	)
+
+	instance := state.Instance{
+		ID:           "instance-1",
+		ServiceID:    "service-id",
+		PlanID:       "plan-id",
+		DashboardURL: "https://dashboard.example.com/instance-1",
+		Parameters:   json.RawMessage(`{"size":1}`),
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = newStore()
+	})
+
+	Describe("instances", func() {
+		It("creates, fetches, updates and deletes instances", func() {
+			Expect(store.CreateInstance(ctx, instance)).To(Succeed())
+
+			created, err := store.GetInstance(ctx, instance.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created.PlanID).To(Equal("plan-id"))
+			Expect(created.DashboardURL).To(Equal(instance.DashboardURL))
+			Expect(created.Parameters).To(MatchJSON(`{"size":1}`))
+			Expect(created.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(created.UpdatedAt).To(Equal(created.CreatedAt))
+
+			updated := instance
+			updated.PlanID = "another-plan-id"
+			Expect(store.UpdateInstance(ctx, updated)).To(Succeed())
+			fetched, err := store.GetInstance(ctx, instance.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched.PlanID).To(Equal("another-plan-id"))
+			Expect(fetched.CreatedAt).To(Equal(created.CreatedAt))
+
+			Expect(store.DeleteInstance(ctx, instance.ID)).To(Succeed())
+			_, err = store.GetInstance(ctx, instance.ID)
+			Expect(err).To(Equal(state.ErrNotFound))
+		})
+
+		It("refuses to create an instance twice", func() {
+			Expect(store.CreateInstance(ctx, instance)).To(Succeed())
+			Expect(store.CreateInstance(ctx, instance)).To(Equal(state.ErrAlreadyExists))
+		})
+
+		It("reports missing instances", func() {
+			_, err := store.GetInstance(ctx, "missing")
+			Expect(err).To(Equal(state.ErrNotFound))
+			Expect(store.UpdateInstance(ctx, instance)).To(Equal(state.ErrNotFound))
+			Expect(store.DeleteInstance(ctx, "missing")).To(Equal(state.ErrNotFound))
+		})
+
+		It("lists instances a page at a time", func() {
+			for _, id := range []string{"c", "a", "b"} {
+				created := instance
+				created.ID = id
+				Expect(store.CreateInstance(ctx, created)).To(Succeed())
+			}
+
+			page, err := store.ListInstances(ctx, "", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceIDs(page)).To(Equal([]string{"a", "b"}))
+
+			page, err = store.ListInstances(ctx, "b", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceIDs(page)).To(Equal([]string{"c"}))
+		})
+	})
+
+	Describe("operations", func() {
+		operation := state.Operation{
+			ID:          "operation-1",
+			InstanceID:  "instance-1",
+			Type:        domain.OperationProvision,
+			State:       domain.InProgress,
+			Description: "creating the database",
+			Data:        json.RawMessage(`{"job_id":"job-1"}`),
+		}
+
+		It("saves and fetches operations", func() {
+			Expect(store.SaveOperation(ctx, operation)).To(Succeed())
+
+			saved, err := store.GetOperation(ctx, operation.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.InstanceID).To(Equal("instance-1"))
+			Expect(saved.Type).To(Equal(domain.OperationProvision))
+			Expect(saved.LastOperation()).To(Equal(domain.LastOperation{State: domain.InProgress, Description: "creating the database"}))
+			Expect(saved.Data).To(MatchJSON(`{"job_id":"job-1"}`))
+
+			finished := operation
+			finished.State = domain.Succeeded
+			Expect(store.SaveOperation(ctx, finished)).To(Succeed())
+			saved, err = store.GetOperation(ctx, operation.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.State).To(Equal(domain.Succeeded))
+		})
+
+		It("finds the latest operation of an instance or binding", func() {
+			Expect(store.SaveOperation(ctx, operation)).To(Succeed())
+			update := operation
+			update.ID, update.Type = "operation-2", domain.OperationUpdate
+			Expect(store.SaveOperation(ctx, update)).To(Succeed())
+			bind := operation
+			bind.ID, bind.BindingID, bind.Type = "operation-3", "binding-1", domain.OperationBind
+			Expect(store.SaveOperation(ctx, bind)).To(Succeed())
+
+			latest, err := store.LatestOperation(ctx, "instance-1", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.ID).To(Equal("operation-2"))
+
+			latest, err = store.LatestOperation(ctx, "instance-1", "binding-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.ID).To(Equal("operation-3"))
+		})
+
+		It("reports missing operations", func() {
+			_, err := store.GetOperation(ctx, "missing")
+			Expect(err).To(Equal(state.ErrNotFound))
+			_, err = store.LatestOperation(ctx, "instance-1", "")
+			Expect(err).To(Equal(state.ErrNotFound))
+		})
+	})
+}
+
+func instanceIDs(instances []state.Instance) []string {
+	ids := make([]string, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+	return ids
+}
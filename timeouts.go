@@ -0,0 +1,106 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokerapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type timeouts struct {
+	defaultTimeout    time.Duration
+	operationTimeouts map[Operation]time.Duration
+	header            string
+}
+
+// WithDefaultTimeout sets a deadline on the context passed to every broker method.
+// Operations configured with WithOperationTimeout use their own timeout instead.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeouts.defaultTimeout = timeout
+	}
+}
+
+// WithOperationTimeout sets a deadline on the context passed to the broker method
+// serving operation.
+func WithOperationTimeout(operation Operation, timeout time.Duration) Option {
+	return func(c *config) {
+		if c.timeouts.operationTimeouts == nil {
+			c.timeouts.operationTimeouts = map[Operation]time.Duration{}
+		}
+		c.timeouts.operationTimeouts[operation] = timeout
+	}
+}
+
+// WithTimeoutHeader lets the platform shorten the deadline of a request by sending
+// the given header, containing either a number of seconds or a Go duration string
+// such as "90s". The resulting deadline is never later than a configured timeout.
+func WithTimeoutHeader(header string) Option {
+	return func(c *config) {
+		c.timeouts.header = header
+	}
+}
+
+func (t timeouts) enabled() bool {
+	return t.defaultTimeout > 0 || len(t.operationTimeouts) > 0 || t.header != ""
+}
+
+func (t timeouts) forRequest(req *http.Request) time.Duration {
+	timeout := t.defaultTimeout
+	if route := mux.CurrentRoute(req); route != nil {
+		if operationTimeout, ok := t.operationTimeouts[Operation(route.GetName())]; ok {
+			timeout = operationTimeout
+		}
+	}
+
+	if t.header != "" {
+		if hint, ok := parseTimeoutHint(req.Header.Get(t.header)); ok && (timeout <= 0 || hint < timeout) {
+			timeout = hint
+		}
+	}
+	return timeout
+}
+
+func parseTimeoutHint(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, seconds > 0
+	}
+	duration, err := time.ParseDuration(value)
+	return duration, err == nil && duration > 0
+}
+
+func timeoutMiddleware(t timeouts) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			timeout := t.forRequest(req)
+			if timeout <= 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
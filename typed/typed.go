@@ -0,0 +1,172 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package typed adapts brokers working with their own parameter structs to
+// domain.ServiceBroker. The adapter decodes the parameters of provision,
+// update and bind requests, validates them, and passes them on with the
+// request, so that brokers do not each unmarshal RawParameters themselves:
+//
+//	type ProvisionParams struct {
+//		Size string `json:"size"`
+//	}
+//
+//	func (p ProvisionParams) Validate() error {
+//		if p.Size != "small" && p.Size != "large" {
+//			return errors.New("size must be small or large")
+//		}
+//		return nil
+//	}
+//
+//	handler := brokerapi.New(typed.New[ProvisionParams, BindParams](broker), logger, credentials)
+package typed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+)
+
+const invalidParametersKey = "invalid-parameters"
+
+var emptyParameters = json.RawMessage(`{}`)
+
+var _ domain.ServiceBroker = (*Adapter[struct{}, struct{}])(nil)
+
+// Validator is implemented by parameters which check their values once they
+// are decoded. A *apiresponses.FailureResponse returned by Validate is sent to
+// the platform as it is; other errors fail the request with 400.
+type Validator interface {
+	Validate() error
+}
+
+// Broker is a service broker receiving its parameters decoded. Provision and
+// Update both get ProvisionParams, so fields which updates may leave out
+// should be pointers. Requests without parameters get them decoded from an
+// empty object. The other methods are those of domain.ServiceBroker.
+type Broker[ProvisionParams, BindParams any] interface {
+	Services(ctx context.Context) ([]domain.Service, error)
+	Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, params ProvisionParams, asyncAllowed bool) (domain.ProvisionedServiceSpec, error)
+	Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error)
+	GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error)
+	Update(ctx context.Context, instanceID string, details domain.UpdateDetails, params ProvisionParams, asyncAllowed bool) (domain.UpdateServiceSpec, error)
+	LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error)
+	Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, params BindParams, asyncAllowed bool) (domain.Binding, error)
+	Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error)
+	GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error)
+	LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error)
+}
+
+// Adapter is the domain.ServiceBroker of a typed Broker.
+type Adapter[ProvisionParams, BindParams any] struct {
+	broker Broker[ProvisionParams, BindParams]
+}
+
+// New returns an Adapter serving broker.
+func New[ProvisionParams, BindParams any](broker Broker[ProvisionParams, BindParams]) *Adapter[ProvisionParams, BindParams] {
+	return &Adapter[ProvisionParams, BindParams]{broker: broker}
+}
+
+func (a *Adapter[P, B]) Services(ctx context.Context) ([]domain.Service, error) {
+	return a.broker.Services(ctx)
+}
+
+func (a *Adapter[P, B]) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	params, err := Decode[P](details.RawParameters)
+	if err != nil {
+		return domain.ProvisionedServiceSpec{}, err
+	}
+	return a.broker.Provision(ctx, instanceID, details, params, asyncAllowed)
+}
+
+func (a *Adapter[P, B]) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	return a.broker.Deprovision(ctx, instanceID, details, asyncAllowed)
+}
+
+func (a *Adapter[P, B]) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	return a.broker.GetInstance(ctx, instanceID)
+}
+
+func (a *Adapter[P, B]) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	params, err := Decode[P](details.RawParameters)
+	if err != nil {
+		return domain.UpdateServiceSpec{}, err
+	}
+	return a.broker.Update(ctx, instanceID, details, params, asyncAllowed)
+}
+
+func (a *Adapter[P, B]) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	return a.broker.LastOperation(ctx, instanceID, details)
+}
+
+func (a *Adapter[P, B]) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, asyncAllowed bool) (domain.Binding, error) {
+	params, err := Decode[B](details.RawParameters)
+	if err != nil {
+		return domain.Binding{}, err
+	}
+	return a.broker.Bind(ctx, instanceID, bindingID, details, params, asyncAllowed)
+}
+
+func (a *Adapter[P, B]) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	return a.broker.Unbind(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+func (a *Adapter[P, B]) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	return a.broker.GetBinding(ctx, instanceID, bindingID)
+}
+
+func (a *Adapter[P, B]) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	return a.broker.LastBindingOperation(ctx, instanceID, bindingID, details)
+}
+
+// Decode unmarshals raw parameters into a T and validates it when T, or a
+// pointer to T, is a Validator. Parameters which are not valid JSON fail with
+// apiresponses.ErrRawParamsInvalid.
+func Decode[T any](raw json.RawMessage) (T, error) {
+	var params T
+	if len(bytes.TrimSpace(raw)) == 0 {
+		raw = emptyParameters
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return params, apiresponses.ErrRawParamsInvalid
+		}
+		return params, invalidParameters(err)
+	}
+	validator, ok := interface{}(params).(Validator)
+	if !ok {
+		validator, ok = interface{}(&params).(Validator)
+	}
+	if !ok {
+		return params, nil
+	}
+	if err := validator.Validate(); err != nil {
+		if _, ok := err.(*apiresponses.FailureResponse); ok {
+			return params, err
+		}
+		return params, invalidParameters(err)
+	}
+	return params, nil
+}
+
+func invalidParameters(err error) error {
+	return apiresponses.NewFailureResponseBuilder(
+		fmt.Errorf("invalid parameters: %s", err), http.StatusBadRequest, invalidParametersKey,
+	).WithErrorKey("InvalidParameters").Build()
+}
@@ -0,0 +1,168 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi"
+	"github.com/sharma-tapas/brokerapi/brokertest"
+	"github.com/sharma-tapas/brokerapi/domain"
+	"github.com/sharma-tapas/brokerapi/domain/apiresponses"
+	"github.com/sharma-tapas/brokerapi/typed"
+)
+
+type provisionParams struct {
+	Size    string `json:"size"`
+	Backups *int   `json:"backups,omitempty"`
+}
+
+func (p provisionParams) Validate() error {
+	if p.Size != "" && p.Size != "small" && p.Size != "large" {
+		return errors.New("size must be small or large")
+	}
+	return nil
+}
+
+type bindParams struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+func (p *bindParams) Validate() error {
+	if p.ReadOnly {
+		return apiresponses.NewFailureResponse(errors.New("read only bindings are not supported"), http.StatusUnprocessableEntity, "read-only")
+	}
+	return nil
+}
+
+type typedBroker struct {
+	provisionParams provisionParams
+	updateParams    provisionParams
+	bindParams      bindParams
+}
+
+func (b *typedBroker) Services(ctx context.Context) ([]domain.Service, error) {
+	return []domain.Service{{ID: "service-id", Bindable: true, Plans: []domain.ServicePlan{{ID: "plan-id"}}}}, nil
+}
+
+func (b *typedBroker) Provision(ctx context.Context, instanceID string, details domain.ProvisionDetails, params provisionParams, asyncAllowed bool) (domain.ProvisionedServiceSpec, error) {
+	b.provisionParams = params
+	return domain.ProvisionedServiceSpec{}, nil
+}
+
+func (b *typedBroker) Deprovision(ctx context.Context, instanceID string, details domain.DeprovisionDetails, asyncAllowed bool) (domain.DeprovisionServiceSpec, error) {
+	return domain.DeprovisionServiceSpec{}, nil
+}
+
+func (b *typedBroker) GetInstance(ctx context.Context, instanceID string) (domain.GetInstanceDetailsSpec, error) {
+	return domain.GetInstanceDetailsSpec{}, nil
+}
+
+func (b *typedBroker) Update(ctx context.Context, instanceID string, details domain.UpdateDetails, params provisionParams, asyncAllowed bool) (domain.UpdateServiceSpec, error) {
+	b.updateParams = params
+	return domain.UpdateServiceSpec{}, nil
+}
+
+func (b *typedBroker) LastOperation(ctx context.Context, instanceID string, details domain.PollDetails) (domain.LastOperation, error) {
+	return domain.LastOperation{}, nil
+}
+
+func (b *typedBroker) Bind(ctx context.Context, instanceID, bindingID string, details domain.BindDetails, params bindParams, asyncAllowed bool) (domain.Binding, error) {
+	b.bindParams = params
+	return domain.Binding{Credentials: map[string]interface{}{"read_only": params.ReadOnly}}, nil
+}
+
+func (b *typedBroker) Unbind(ctx context.Context, instanceID, bindingID string, details domain.UnbindDetails, asyncAllowed bool) (domain.UnbindSpec, error) {
+	return domain.UnbindSpec{}, nil
+}
+
+func (b *typedBroker) GetBinding(ctx context.Context, instanceID, bindingID string) (domain.GetBindingSpec, error) {
+	return domain.GetBindingSpec{}, nil
+}
+
+func (b *typedBroker) LastBindingOperation(ctx context.Context, instanceID, bindingID string, details domain.PollDetails) (domain.LastOperation, error) {
+	return domain.LastOperation{}, nil
+}
+
+var _ = Describe("Adapter", func() {
+	var (
+		broker  *typedBroker
+		adapter *typed.Adapter[provisionParams, bindParams]
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		broker = &typedBroker{}
+		adapter = typed.New[provisionParams, bindParams](broker)
+		ctx = context.Background()
+	})
+
+	It("passes the decoded parameters to Provision", func() {
+		_, err := adapter.Provision(ctx, "instance-id", domain.ProvisionDetails{RawParameters: json.RawMessage(`{"size": "large", "backups": 3}`)}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.provisionParams.Size).To(Equal("large"))
+		Expect(*broker.provisionParams.Backups).To(Equal(3))
+	})
+
+	It("passes the decoded parameters to Update", func() {
+		_, err := adapter.Update(ctx, "instance-id", domain.UpdateDetails{RawParameters: json.RawMessage(`{"size": "small"}`)}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.updateParams).To(Equal(provisionParams{Size: "small"}))
+	})
+
+	It("decodes missing parameters as an empty object", func() {
+		_, err := adapter.Bind(ctx, "instance-id", "binding-id", domain.BindDetails{}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.bindParams).To(Equal(bindParams{}))
+	})
+
+	It("fails with ErrRawParamsInvalid for parameters which are not JSON", func() {
+		_, err := adapter.Provision(ctx, "instance-id", domain.ProvisionDetails{RawParameters: json.RawMessage(`{"size"`)}, false)
+		Expect(err).To(Equal(apiresponses.ErrRawParamsInvalid))
+	})
+
+	It("fails with 400 for parameters of the wrong type", func() {
+		_, err := adapter.Provision(ctx, "instance-id", domain.ProvisionDetails{RawParameters: json.RawMessage(`{"size": 1}`)}, false)
+		Expect(err).To(BeAssignableToTypeOf(&apiresponses.FailureResponse{}))
+		Expect(err.(*apiresponses.FailureResponse).ValidatedStatusCode(nil)).To(Equal(http.StatusBadRequest))
+	})
+
+	It("returns failure responses from Validate unchanged", func() {
+		_, err := adapter.Bind(ctx, "instance-id", "binding-id", domain.BindDetails{RawParameters: json.RawMessage(`{"read_only": true}`)}, false)
+		Expect(err).To(MatchError("read only bindings are not supported"))
+		Expect(err.(*apiresponses.FailureResponse).ValidatedStatusCode(nil)).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("responds with 400 and the validation error through the API", func() {
+		logger := lagertest.NewTestLogger("typed")
+		tester := brokertest.New(brokerapi.New(adapter, logger, brokerapi.BrokerCredentials{Username: "username", Password: "password"}), "username", "password")
+
+		response := tester.Provision("instance-id", map[string]interface{}{
+			"service_id": "service-id",
+			"plan_id":    "plan-id",
+			"parameters": map[string]interface{}{"size": "huge"},
+		}, false)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(MatchJSON(`{"error": "InvalidParameters", "description": "invalid parameters: size must be small or large"}`))
+		Expect(broker.provisionParams).To(Equal(provisionParams{}))
+	})
+})
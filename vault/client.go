@@ -0,0 +1,231 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vault reads the secrets of a broker from HashiCorp Vault, so that
+// its basic auth credentials, TLS keys and operation encryption key need not
+// be kept in environment variables. It talks to the Vault HTTP API directly,
+// and a Watcher keeps secrets up to date, renewing their leases.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the Vault HTTP API. It is safe for concurrent use.
+type Client struct {
+	address    *url.URL
+	namespace  string
+	httpClient *http.Client
+
+	mutex sync.RWMutex
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the client used to make requests, which is
+// http.DefaultClient by default. Set its transport to trust the CA of Vault.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace of the requests.
+func WithNamespace(namespace string) Option {
+	return func(c *Client) {
+		c.namespace = namespace
+	}
+}
+
+// New returns a Client for the Vault server at address, such as
+// "https://vault.example.com:8200", authenticating with token.
+func New(address, token string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault address: %s", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Vault address %q: the scheme must be http or https", address)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	c := &Client{address: parsed, token: token, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// SetToken replaces the token of the client, for example after logging in
+// again.
+func (c *Client) SetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = token
+}
+
+// Secret is a secret read from Vault. The data of KV version 2 secrets is
+// unwrapped, so that Data holds the fields of the secret whichever version of
+// the engine stores them.
+type Secret struct {
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+	Data          map[string]interface{}
+}
+
+// String returns the field key of the secret, which must be a non-empty
+// string.
+func (s *Secret) String(key string) (string, error) {
+	value, ok := s.Data[key].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("secret has no %q field", key)
+	}
+	return value, nil
+}
+
+// ResponseError is returned when Vault responds with an error.
+type ResponseError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("vault responded with %d: %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+type secretResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		LeaseDuration int  `json:"lease_duration"`
+		Renewable     bool `json:"renewable"`
+	} `json:"auth"`
+}
+
+// Read reads the secret at path, such as "secret/data/broker" for a KV
+// version 2 engine mounted at secret/.
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	var response secretResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	return &Secret{
+		LeaseID:       response.LeaseID,
+		LeaseDuration: time.Duration(response.LeaseDuration) * time.Second,
+		Renewable:     response.Renewable,
+		Data:          data,
+	}, nil
+}
+
+// RenewLease extends the lease of a secret by increment, and returns the
+// duration granted, which may be shorter when the lease reaches its maximum.
+func (c *Client) RenewLease(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error) {
+	body := map[string]interface{}{"lease_id": leaseID, "increment": int(increment.Seconds())}
+	var response secretResponse
+	if err := c.do(ctx, http.MethodPut, "sys/leases/renew", body, &response); err != nil {
+		return 0, err
+	}
+	return time.Duration(response.LeaseDuration) * time.Second, nil
+}
+
+// RenewToken extends the lease of the token of the client by increment, and
+// returns the duration granted and whether the token can be renewed again.
+func (c *Client) RenewToken(ctx context.Context, increment time.Duration) (time.Duration, bool, error) {
+	body := map[string]interface{}{"increment": int(increment.Seconds())}
+	var response secretResponse
+	if err := c.do(ctx, http.MethodPost, "auth/token/renew-self", body, &response); err != nil {
+		return 0, false, err
+	}
+	if response.Auth == nil {
+		return 0, false, fmt.Errorf("vault returned no token lease")
+	}
+	return time.Duration(response.Auth.LeaseDuration) * time.Second, response.Auth.Renewable, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, response interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(encoded)
+	}
+
+	target := *c.address
+	target.Path += "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bodyReader)
+	if err != nil {
+		return err
+	}
+	c.mutex.RLock()
+	req.Header.Set("X-Vault-Token", c.token)
+	c.mutex.RUnlock()
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		responseErr := &ResponseError{StatusCode: resp.StatusCode}
+		var errorResponse struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(contents, &errorResponse) == nil {
+			responseErr.Errors = errorResponse.Errors
+		}
+		return responseErr
+	}
+	if err := json.Unmarshal(contents, response); err != nil {
+		return fmt.Errorf("could not decode the Vault response to %s %s: %s", method, path, err)
+	}
+	return nil
+}
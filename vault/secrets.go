@@ -0,0 +1,101 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/operationdata"
+)
+
+// CredentialsUpdater returns an apply function for a Watcher which sets the
+// credentials of wrapper from the "username" and "password" fields of a
+// secret.
+func CredentialsUpdater(wrapper *auth.Wrapper) func(*Secret) error {
+	return func(secret *Secret) error {
+		username, err := secret.String("username")
+		if err != nil {
+			return err
+		}
+		password, err := secret.String("password")
+		if err != nil {
+			return err
+		}
+		wrapper.SetCredentials(username, password)
+		return nil
+	}
+}
+
+// Certificate holds a TLS certificate read from the PEM encoded "certificate"
+// and "private_key" fields of a secret, as stored by the PKI engine. Set its
+// GetCertificate method on a tls.Config so that renewed certificates are
+// served without restarting.
+type Certificate struct {
+	certificate atomic.Value
+}
+
+// Update replaces the certificate with the one in secret. It is the apply
+// function of a Watcher.
+func (c *Certificate) Update(secret *Secret) error {
+	certificatePEM, err := secret.String("certificate")
+	if err != nil {
+		return err
+	}
+	keyPEM, err := secret.String("private_key")
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.X509KeyPair([]byte(certificatePEM), []byte(keyPEM))
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %s", err)
+	}
+	c.certificate.Store(&certificate)
+	return nil
+}
+
+// GetCertificate returns the last certificate passed to Update.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate, ok := c.certificate.Load().(*tls.Certificate)
+	if !ok {
+		return nil, errors.New("no certificate has been read from vault")
+	}
+	return certificate, nil
+}
+
+// EncryptedCodec returns an operationdata codec encrypting with the base64
+// encoded key in field of the secret at path. The key is read once: changing
+// it would make the operations in progress unreadable.
+func EncryptedCodec(ctx context.Context, client *Client, path, field string) (*operationdata.Codec, error) {
+	secret, err := client.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := secret.String(field)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("field %q is not base64 encoded: %s", field, err)
+	}
+	return operationdata.NewEncryptedCodec(key)
+}
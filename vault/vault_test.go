@@ -0,0 +1,309 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sharma-tapas/brokerapi/auth"
+	"github.com/sharma-tapas/brokerapi/vault"
+)
+
+// fakeVault serves secrets and lease renewals like a Vault server.
+type fakeVault struct {
+	mutex    sync.Mutex
+	secrets  map[string]string
+	requests []*http.Request
+	bodies   []map[string]interface{}
+	renewals int
+	granted  int
+}
+
+func (f *fakeVault) set(path, response string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.secrets[path] = response
+}
+
+func (f *fakeVault) renewalCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.renewals
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var body map[string]interface{}
+	contents, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(contents, &body)
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, body)
+
+	switch r.URL.Path {
+	case "/v1/sys/leases/renew":
+		f.renewals++
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": f.granted, "renewable": true})
+	case "/v1/auth/token/renew-self":
+		f.renewals++
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{"lease_duration": f.granted, "renewable": f.granted > 0}})
+	default:
+		response, ok := f.secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Write([]byte(response))
+	}
+}
+
+var _ = Describe("Vault", func() {
+	var (
+		fake   *fakeVault
+		server *httptest.Server
+		client *vault.Client
+		logger *lagertest.TestLogger
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		fake = &fakeVault{secrets: map[string]string{}, granted: 3600}
+		server = httptest.NewServer(fake)
+		var err error
+		client, err = vault.New(server.URL+"/", "vault-token", vault.WithNamespace("brokers"))
+		Expect(err).NotTo(HaveOccurred())
+		logger = lagertest.NewTestLogger("broker")
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Client", func() {
+		It("rejects an invalid address", func() {
+			_, err := vault.New("vault.example.com:8200", "token")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("reads KV version 1 secrets", func() {
+			fake.set("/v1/kv/broker", `{"lease_id":"","lease_duration":2764800,"renewable":false,"data":{"username":"admin"}}`)
+
+			secret, err := client.Read(ctx, "kv/broker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Data).To(Equal(map[string]interface{}{"username": "admin"}))
+			Expect(secret.LeaseDuration).To(Equal(768 * time.Hour))
+			Expect(fake.requests[0].Header.Get("X-Vault-Token")).To(Equal("vault-token"))
+			Expect(fake.requests[0].Header.Get("X-Vault-Namespace")).To(Equal("brokers"))
+		})
+
+		It("unwraps the data of KV version 2 secrets", func() {
+			fake.set("/v1/secret/data/broker", `{"data":{"data":{"username":"admin"},"metadata":{"version":3}}}`)
+
+			secret, err := client.Read(ctx, "secret/data/broker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Data).To(Equal(map[string]interface{}{"username": "admin"}))
+		})
+
+		It("reads leased secrets", func() {
+			fake.set("/v1/database/creds/broker", `{"lease_id":"database/creds/broker/1","lease_duration":60,"renewable":true,"data":{"username":"v-broker"}}`)
+
+			secret, err := client.Read(ctx, "database/creds/broker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.LeaseID).To(Equal("database/creds/broker/1"))
+			Expect(secret.LeaseDuration).To(Equal(time.Minute))
+			Expect(secret.Renewable).To(BeTrue())
+		})
+
+		It("returns the errors of Vault", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+			})
+
+			_, err := client.Read(ctx, "secret/data/forbidden")
+			Expect(err).To(MatchError("vault responded with 403: permission denied"))
+			Expect(err).To(BeAssignableToTypeOf(&vault.ResponseError{}))
+		})
+
+		It("renews leases and tokens", func() {
+			duration, err := client.RenewLease(ctx, "database/creds/broker/1", time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(duration).To(Equal(time.Hour))
+			Expect(fake.bodies[0]).To(Equal(map[string]interface{}{"lease_id": "database/creds/broker/1", "increment": float64(60)}))
+
+			client.SetToken("new-token")
+			duration, renewable, err := client.RenewToken(ctx, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(duration).To(Equal(time.Hour))
+			Expect(renewable).To(BeTrue())
+			Expect(fake.requests[1].Header.Get("X-Vault-Token")).To(Equal("new-token"))
+		})
+	})
+
+	Describe("Watcher", func() {
+		var wrapper *auth.Wrapper
+
+		authorized := func(username, password string) bool {
+			request := httptest.NewRequest("GET", "/v2/catalog", nil)
+			request.SetBasicAuth(username, password)
+			recorder := httptest.NewRecorder()
+			wrapper.WrapFunc(func(w http.ResponseWriter, r *http.Request) {}).ServeHTTP(recorder, request)
+			return recorder.Code == http.StatusOK
+		}
+
+		BeforeEach(func() {
+			wrapper = auth.NewWrapper("initial", "initial")
+			fake.set("/v1/secret/data/broker", `{"data":{"data":{"username":"admin","password":"secret-1"},"metadata":{}}}`)
+		})
+
+		It("updates the credentials of a wrapper", func() {
+			watcher := vault.NewWatcher(client, "secret/data/broker", vault.CredentialsUpdater(wrapper), logger)
+			Expect(watcher.Reload(ctx)).To(Succeed())
+			Expect(authorized("admin", "secret-1")).To(BeTrue())
+			Expect(authorized("initial", "initial")).To(BeFalse())
+		})
+
+		It("keeps the previous credentials when the secret is invalid", func() {
+			watcher := vault.NewWatcher(client, "secret/data/broker", vault.CredentialsUpdater(wrapper), logger)
+			Expect(watcher.Reload(ctx)).To(Succeed())
+
+			fake.set("/v1/secret/data/broker", `{"data":{"data":{"username":"admin"},"metadata":{}}}`)
+			Expect(watcher.Reload(ctx)).To(MatchError(`secret has no "password" field`))
+			Expect(authorized("admin", "secret-1")).To(BeTrue())
+		})
+
+		It("reads the secret again every poll interval", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go vault.NewWatcher(client, "secret/data/broker", vault.CredentialsUpdater(wrapper), logger).
+				WithPollInterval(10 * time.Millisecond).
+				Run(ctx)
+			Eventually(func() bool { return authorized("admin", "secret-1") }).Should(BeTrue())
+
+			fake.set("/v1/secret/data/broker", `{"data":{"data":{"username":"admin","password":"secret-2"},"metadata":{}}}`)
+			Eventually(func() bool { return authorized("admin", "secret-2") }).Should(BeTrue())
+		})
+
+		It("renews the lease of renewable secrets before it expires", func() {
+			fake.set("/v1/database/creds/broker", `{"lease_id":"database/creds/broker/1","lease_duration":0,"renewable":true,"data":{"username":"v-broker","password":"p"}}`)
+			fake.granted = 0
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go vault.NewWatcher(client, "database/creds/broker", vault.CredentialsUpdater(wrapper), logger).
+				WithPollInterval(10 * time.Millisecond).
+				Run(ctx)
+
+			Eventually(fake.renewalCount).Should(BeNumerically(">", 1))
+			Expect(authorized("v-broker", "p")).To(BeTrue())
+		})
+	})
+
+	Describe("TokenRenewer", func() {
+		It("stops when the token cannot be renewed", func() {
+			fake.granted = 0
+			done := make(chan struct{})
+			go func() {
+				vault.NewTokenRenewer(client, logger).Run(ctx)
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed())
+			Expect(fake.renewalCount()).To(Equal(1))
+		})
+	})
+
+	Describe("Certificate", func() {
+		It("serves the certificate of the secret", func() {
+			certificatePEM, keyPEM := selfSignedCertificate()
+			secret := &vault.Secret{Data: map[string]interface{}{"certificate": certificatePEM, "private_key": keyPEM}}
+
+			var certificate vault.Certificate
+			_, err := certificate.GetCertificate(nil)
+			Expect(err).To(HaveOccurred())
+
+			Expect(certificate.Update(secret)).To(Succeed())
+			served, err := certificate.GetCertificate(&tls.ClientHelloInfo{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(served.Certificate).To(HaveLen(1))
+		})
+
+		It("rejects invalid certificates", func() {
+			var certificate vault.Certificate
+			secret := &vault.Secret{Data: map[string]interface{}{"certificate": "invalid", "private_key": "invalid"}}
+			Expect(certificate.Update(secret)).To(MatchError(ContainSubstring("invalid certificate")))
+		})
+	})
+
+	Describe("EncryptedCodec", func() {
+		It("encrypts with the key of the secret", func() {
+			key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+			fake.set("/v1/secret/data/operations", `{"data":{"data":{"key":"`+key+`"},"metadata":{}}}`)
+
+			codec, err := vault.EncryptedCodec(ctx, client, "secret/data/operations", "key")
+			Expect(err).NotTo(HaveOccurred())
+			operation, err := codec.Encode(map[string]string{"job": "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			var state map[string]string
+			Expect(codec.Decode(operation, &state)).To(Succeed())
+			Expect(state).To(Equal(map[string]string{"job": "1"}))
+		})
+
+		It("rejects keys which are not base64 encoded", func() {
+			fake.set("/v1/secret/data/operations", `{"data":{"data":{"key":"not base64!"},"metadata":{}}}`)
+
+			_, err := vault.EncryptedCodec(ctx, client, "secret/data/operations", "key")
+			Expect(err).To(MatchError(ContainSubstring("not base64 encoded")))
+		})
+	})
+})
+
+func selfSignedCertificate() (certificatePEM, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "broker.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
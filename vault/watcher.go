@@ -0,0 +1,191 @@
+// Copyright (C) 2015-Present Pivotal Software, Inc. All rights reserved.
+
+// This program and the accompanying materials are made available under
+// the terms of the under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultPollInterval   = 5 * time.Minute
+	tokenRetryInterval    = 30 * time.Second
+	defaultTokenIncrement = time.Hour
+)
+
+// Watcher keeps a secret up to date, passing it to an apply function, such as
+// one returned by CredentialsUpdater, whenever its data changes.
+// Renewable leases are renewed before they expire; other secrets are read
+// again every poll interval, or before their lease expires if sooner.
+type Watcher struct {
+	client       *Client
+	path         string
+	apply        func(*Secret) error
+	logger       lager.Logger
+	pollInterval time.Duration
+
+	mutex  sync.Mutex
+	secret *Secret
+}
+
+// NewWatcher returns a watcher for the secret at path. The secret is read
+// again every 5 minutes unless a different interval is set with
+// WithPollInterval.
+func NewWatcher(client *Client, path string, apply func(*Secret) error, logger lager.Logger) *Watcher {
+	return &Watcher{
+		client:       client,
+		path:         path,
+		apply:        apply,
+		logger:       logger.Session("vault-watcher", lager.Data{"path": path}),
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// WithPollInterval sets how often the secret is read again.
+func (w *Watcher) WithPollInterval(interval time.Duration) *Watcher {
+	w.pollInterval = interval
+	return w
+}
+
+// Reload reads the secret and applies it if its data has changed since it was
+// last applied. When it cannot be read or applied the previous secret remains
+// in effect.
+func (w *Watcher) Reload(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	secret, err := w.client.Read(ctx, w.path)
+	if err != nil {
+		return err
+	}
+	if w.secret != nil && reflect.DeepEqual(secret.Data, w.secret.Data) {
+		w.secret = secret
+		return nil
+	}
+	if err := w.apply(secret); err != nil {
+		return err
+	}
+	w.secret = secret
+	w.logger.Info("secret-updated")
+	return nil
+}
+
+// Run keeps the secret up to date until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	w.reloadAndLog(ctx)
+	for {
+		timer := time.NewTimer(w.nextCheck())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !w.renew(ctx) {
+			w.reloadAndLog(ctx)
+		}
+	}
+}
+
+// renew extends the lease of a renewable secret, and reports whether it did.
+func (w *Watcher) renew(ctx context.Context) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.secret == nil || !w.secret.Renewable || w.secret.LeaseID == "" {
+		return false
+	}
+	duration, err := w.client.RenewLease(ctx, w.secret.LeaseID, w.secret.LeaseDuration)
+	if err != nil {
+		w.logger.Error("renewing-lease-failed", err)
+		return false
+	}
+	if duration < w.secret.LeaseDuration {
+		// The lease is reaching its maximum TTL, so a new secret is read
+		// before this one expires.
+		return false
+	}
+	w.secret.LeaseDuration = duration
+	return true
+}
+
+func (w *Watcher) nextCheck() time.Duration {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	next := w.pollInterval
+	if w.secret != nil && w.secret.LeaseDuration > 0 {
+		if beforeExpiry := w.secret.LeaseDuration * 2 / 3; beforeExpiry < next {
+			next = beforeExpiry
+		}
+	}
+	return next
+}
+
+func (w *Watcher) reloadAndLog(ctx context.Context) {
+	if err := w.Reload(ctx); err != nil {
+		w.logger.Error("reloading-secret-failed", err)
+	}
+}
+
+// TokenRenewer keeps the token of a Client alive by renewing it before it
+// expires.
+type TokenRenewer struct {
+	client    *Client
+	increment time.Duration
+	logger    lager.Logger
+}
+
+// NewTokenRenewer returns a renewer asking for the token to be extended by an
+// hour each time, or by its maximum TTL when shorter.
+func NewTokenRenewer(client *Client, logger lager.Logger) *TokenRenewer {
+	return &TokenRenewer{
+		client:    client,
+		increment: defaultTokenIncrement,
+		logger:    logger.Session("vault-token-renewer"),
+	}
+}
+
+// Run renews the token until ctx is cancelled or the token can no longer be
+// renewed. Failed renewals are retried every 30 seconds.
+func (r *TokenRenewer) Run(ctx context.Context) {
+	for {
+		wait := tokenRetryInterval
+		duration, renewable, err := r.client.RenewToken(ctx, r.increment)
+		switch {
+		case err != nil:
+			r.logger.Error("renewing-token-failed", err)
+		case !renewable:
+			r.logger.Info("token-not-renewable")
+			return
+		default:
+			wait = duration * 2 / 3
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}